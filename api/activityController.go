@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Page sizes of GET /activity
+const (
+	defaultActivityLimit = 50
+	maxActivityLimit     = 200
+)
+
+// ActivityController serves the activity feed
+type ActivityController struct {
+	activityRepo internal.ActivityRepositoryInterface
+}
+
+// NewActivityController creates a new activity controller
+func NewActivityController(activityRepo internal.ActivityRepositoryInterface) *ActivityController {
+	return &ActivityController{activityRepo: activityRepo}
+}
+
+// RegisterRoutes adds the activity endpoint to router
+func (ac *ActivityController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/activity", requireScope(internal.ScopeEventsRead, ac.GetActivity)).Methods("GET")
+}
+
+type activityPageResponse struct {
+	Activity []internal.Activity `json:"activity"`
+	// Cursor is passed back as ?cursor= for the next, older page
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"has_more"`
+}
+
+// GetActivity handles GET /activity?cursor=&limit=&calendar_id=, newest first. Users
+// see the default calendar and the calendars they own; admins and deployments without
+// authentication see every calendar.
+func (ac *ActivityController) GetActivity(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	q := r.URL.Query()
+	cursor, err := internal.ParseTimeCursor(q.Get("cursor"))
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+	filter := internal.ActivityFilter{Before: cursor, Limit: defaultActivityLimit}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxActivityLimit {
+			httpError(w, r, http.StatusBadRequest, "limit must be between 1 and %d", maxActivityLimit)
+			return
+		}
+		filter.Limit = n
+	}
+	if v := q.Get("calendar_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+			return
+		}
+		filter.CalendarID = &id
+	}
+
+	p := internal.PrincipalFromContext(r.Context())
+	filter.User = principalID(r)
+	filter.AllCalendars = p == nil || p.Admin
+	filter.Unpublished = canReview(r)
+
+	page, err := ac.activityRepo.ListActivity(ctx, filter)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing activity", "Failed to get activity")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(activityPageResponse{
+		Activity: page.Activities,
+		Cursor:   page.Next.String(),
+		HasMore:  page.HasMore,
+	})
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"taller_challenge/internal"
+
+	"github.com/gorilla/mux"
+)
+
+// routeFeatures names the features served by a route of their own, by method and route
+// template; handlers report the features chosen by parameters with internal.UseFeature
+var routeFeatures = map[string]string{
+	"GET /events/search":                     "search",
+	"GET /events/suggest":                    "suggest",
+	"GET /events/heatmap":                    "heatmap",
+	"GET /events/stats":                      "stats",
+	"GET /events/export.pdf":                 "pdf_export",
+	"GET /events/{id}/export.pdf":            "pdf_export",
+	"POST /events/quickadd":                  "quick_add",
+	"POST /events/import":                    "import",
+	"POST /ingest/{source}":                  "ingest",
+	"GET /sync/changes":                      "sync",
+	"POST /sync/changes":                     "sync",
+	"POST /batch":                            "batch",
+	"PUT /calendars/{id}/events:declarative": "declarative",
+	"GET /calendars/{id}/feed.ics":           "ics_feed",
+	"GET /embed/calendar/{id}":               "embed",
+	"GET /e/{id}":                            "event_page",
+}
+
+// analyticsMiddleware tracks an anonymous event per request, naming its route template
+// and status class, and one per feature it used
+func analyticsMiddleware(analytics *internal.Analytics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, features := internal.WithFeatures(r.Context())
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			current := mux.CurrentRoute(r)
+			if current == nil {
+				return
+			}
+			route, err := current.GetPathTemplate()
+			if err != nil {
+				return
+			}
+			user := principalID(r)
+			analytics.Track(internal.AnalyticsRequest, user, map[string]string{
+				"method": r.Method,
+				"route":  route,
+				"status": strconv.Itoa(rec.status/100) + "xx",
+			})
+			if rec.status >= 400 {
+				return
+			}
+			used := features()
+			if f, ok := routeFeatures[r.Method+" "+route]; ok {
+				used = append([]string{f}, used...)
+			}
+			for _, f := range used {
+				analytics.Track(internal.AnalyticsFeature, user, map[string]string{"feature": f, "client": clientKind(r)})
+			}
+		})
+	}
+}
+
+// clientKind tells apps authenticating with tokens from users and admins, without
+// naming them
+func clientKind(r *http.Request) string {
+	p := internal.PrincipalFromContext(r.Context())
+	switch {
+	case p == nil:
+		return "anonymous"
+	case p.Admin:
+		return "admin"
+	case p.TokenID != nil:
+		return "token"
+	}
+	return "user"
+}
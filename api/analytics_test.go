@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// analyticsEvents collects the usage events sent, one per batch
+type analyticsEvents chan internal.AnalyticsEvent
+
+func (a analyticsEvents) Send(ctx context.Context, events []internal.AnalyticsEvent) error {
+	for _, e := range events {
+		a <- e
+	}
+	return nil
+}
+
+func TestAnalyticsMiddleware(t *testing.T) {
+	sent := make(analyticsEvents, 10)
+	analytics := internal.NewAnalytics(sent, "salt")
+	analytics.BatchSize = 1
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go analytics.Run(ctx)
+
+	srv, err := NewServer(internal.Config{}, Dependencies{Events: &fakeEventRepository{}, Analytics: analytics})
+	require.NoError(t, err)
+	next := func() internal.AnalyticsEvent {
+		select {
+		case e := <-sent:
+			return e
+		case <-time.After(time.Second):
+			t.Fatal("no analytics event sent")
+			return internal.AnalyticsEvent{}
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events/search?q=standup", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]string{"method": "GET", "route": "/events/search", "status": "2xx"}, next().Properties)
+	assert.Equal(t, map[string]string{"feature": "search", "client": "anonymous"}, next().Properties)
+
+	rec = httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events?view=summary&filter=title:standup", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "/events", next().Properties["route"])
+	assert.Equal(t, "filter", next().Properties["feature"])
+	assert.Equal(t, "summary_view", next().Properties["feature"])
+
+	rec = httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events/search", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "4xx", next().Properties["status"])
+	assert.Empty(t, sent, "failed requests used no feature")
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultPendingLimit = 100
+	maxPendingLimit     = 500
+)
+
+// reviewInput is the body of the approve and reject endpoints
+type reviewInput struct {
+	Comment string `json:"comment"`
+}
+
+// canReview reports whether the caller may review events. Without authentication
+// everyone can.
+func canReview(r *http.Request) bool {
+	p := internal.PrincipalFromContext(r.Context())
+	return p == nil || p.CanReview()
+}
+
+// submitForReview marks an event written by the caller as pending when approval is
+// required and the caller is not a reviewer. Reviewers' writes keep the stored status.
+func (ec *EventController) submitForReview(r *http.Request, event *internal.EventDB) {
+	if !ec.cfg.ApprovalRequired || canReview(r) {
+		return
+	}
+	event.Status = internal.EventStatusPending
+	event.SubmittedBy = principalID(r)
+}
+
+// visible reports whether the caller may see event: published events are public,
+// unpublished ones are shown to their submitter and to reviewers
+func visible(r *http.Request, event internal.EventDB) bool {
+	if event.Published() || canReview(r) {
+		return true
+	}
+	return event.SubmittedBy != "" && event.SubmittedBy == principalID(r)
+}
+
+// loadVisibleEvent fetches the event named in the URL from events, writing an error
+// when it fails or the caller may not see it
+func loadVisibleEvent(ctx context.Context, w http.ResponseWriter, r *http.Request, events internal.EventRepositoryInterface) *internal.EventDB {
+	id, err := internal.ParseEventID(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return nil
+	}
+
+	event, err := events.GetEventByID(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting event by ID", "Failed to get event")
+		return nil
+	}
+	if !visible(r, *event) {
+		httpError(w, r, http.StatusNotFound, "Event not found")
+		return nil
+	}
+	return event
+}
+
+// listed filters a listing down to published events and the caller's own submissions.
+// Reviewers find the others in GET /events/pending.
+func listed(r *http.Request, events []internal.EventDB) []internal.EventDB {
+	user := principalID(r)
+	out := events[:0:0]
+	for _, e := range events {
+		if e.Published() || (user != "" && e.SubmittedBy == user) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// GetPendingEvents handles GET /events/pending?limit=, the review queue
+func (ec *EventController) GetPendingEvents(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	limit := defaultPendingLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPendingLimit {
+			httpError(w, r, http.StatusBadRequest, "limit must be between 1 and %d", maxPendingLimit)
+			return
+		}
+		limit = n
+	}
+
+	events, err := ec.eventRepo.ListPendingEvents(ctx, limit)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing pending events", "Failed to get events")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ec.decorateEvents(ctx, r, events))
+}
+
+// ApproveEvent handles POST /events/{id}/approve, publishing a pending event
+func (ec *EventController) ApproveEvent(w http.ResponseWriter, r *http.Request) {
+	ec.reviewEvent(w, r, internal.EventStatusApproved)
+}
+
+// RejectEvent handles POST /events/{id}/reject. The comment tells the submitter why
+// and is required.
+func (ec *EventController) RejectEvent(w http.ResponseWriter, r *http.Request) {
+	ec.reviewEvent(w, r, internal.EventStatusRejected)
+}
+
+func (ec *EventController) reviewEvent(w http.ResponseWriter, r *http.Request, decision string) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := internal.ParseEventID(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	var in reviewInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	in.Comment = strings.TrimSpace(in.Comment)
+	if decision == internal.EventStatusRejected && in.Comment == "" {
+		httpError(w, r, http.StatusBadRequest, "comment is required when rejecting an event")
+		return
+	}
+	if len(in.Comment) > 2000 {
+		httpError(w, r, http.StatusBadRequest, "comment must be <= 2000 characters")
+		return
+	}
+
+	reviewed, err := ec.eventRepo.ReviewEvent(ctx, internal.EventReview{
+		ID:       uuid.New(),
+		EventID:  id,
+		Decision: decision,
+		Comment:  in.Comment,
+		Reviewer: principalID(r),
+	})
+	if err != nil {
+		repositoryError(ctx, w, r, err, "reviewing event", "Failed to review event")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ec.decorateEvent(ctx, r, *reviewed))
+}
+
+// GetEventReviews handles GET /events/{id}/reviews, the decisions on an event. The
+// submitter may read them to learn why an event was rejected.
+func (ec *EventController) GetEventReviews(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := internal.ParseEventID(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	event, err := ec.eventRepo.GetEventByID(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting event by ID", "Failed to get event")
+		return
+	}
+	if !visible(r, *event) {
+		httpError(w, r, http.StatusNotFound, "Event not found")
+		return
+	}
+
+	reviews, err := ec.eventRepo.ListEventReviews(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing event reviews", "Failed to get event reviews")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reviews)
+}
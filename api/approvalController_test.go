@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeApprovalRepository lists a fixed set of events and keeps the last one created
+type fakeApprovalRepository struct {
+	fakeEventRepository
+	created *internal.EventDB
+}
+
+func (f *fakeApprovalRepository) CreateEvent(ctx context.Context, event internal.EventDB) (*internal.EventDB, error) {
+	f.created = &event
+	return &event, nil
+}
+
+func newApprovalServer(t *testing.T, repo internal.EventRepositoryInterface) *Server {
+	scopes := []string{internal.ScopeEventsRead, internal.ScopeEventsWrite}
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		user := r.Header.Get("X-User")
+		if user == "reviewer" {
+			return &internal.Principal{UserID: user, Scopes: append(scopes, internal.ScopeEventsReview)}, nil
+		}
+		return &internal.Principal{UserID: user, Scopes: scopes}, nil
+	}
+	srv, err := NewServer(internal.Config{ApprovalRequired: true}, Dependencies{Events: repo, Auth: hook})
+	require.NoError(t, err)
+	return srv
+}
+
+func TestApprovalListings(t *testing.T) {
+	start := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+	event := func(title, status, submitter string) internal.EventDB {
+		return internal.EventDB{ID: uuid.New(), Title: title, Status: status, SubmittedBy: submitter, StartTime: start, EndTime: start.Add(time.Hour)}
+	}
+	repo := &fakeApprovalRepository{fakeEventRepository: fakeEventRepository{events: []internal.EventDB{
+		event("legacy", "", ""),
+		event("approved", internal.EventStatusApproved, "alice"),
+		event("alice pending", internal.EventStatusPending, "alice"),
+		event("bob rejected", internal.EventStatusRejected, "bob"),
+	}}}
+	srv := newApprovalServer(t, repo)
+
+	tests := []struct {
+		user string
+		want []string
+	}{
+		{"alice", []string{"legacy", "approved", "alice pending"}},
+		{"bob", []string{"legacy", "approved", "bob rejected"}},
+		{"reviewer", []string{"legacy", "approved"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/events", nil)
+			req.Header.Set("X-User", tt.user)
+			rec := httptest.NewRecorder()
+			srv.Router.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			var got []internal.EventDB
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			titles := make([]string, len(got))
+			for i, e := range got {
+				titles[i] = e.Title
+			}
+			assert.Equal(t, tt.want, titles)
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/events/pending", nil)
+	req.Header.Set("X-User", "alice")
+	rec := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestApprovalSubmission(t *testing.T) {
+	repo := &fakeApprovalRepository{}
+	srv := newApprovalServer(t, repo)
+	body := `{"title":"Standup","start_time":"2025-09-15T09:00:00Z","end_time":"2025-09-15T09:15:00Z"}`
+
+	tests := []struct {
+		user          string
+		wantStatus    string
+		wantSubmitter string
+	}{
+		{"alice", internal.EventStatusPending, "alice"},
+		// Reviewers publish directly; the database defaults the status to approved
+		{"reviewer", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+			req.Header.Set("X-User", tt.user)
+			rec := httptest.NewRecorder()
+			srv.Router.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+			require.NotNil(t, repo.created)
+			assert.Equal(t, tt.wantStatus, repo.created.Status)
+			assert.Equal(t, tt.wantSubmitter, repo.created.SubmittedBy)
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/events/"+uuid.NewString()+"/reject", strings.NewReader(`{"comment":" "}`))
+	req.Header.Set("X-User", "reviewer")
+	rec := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+)
+
+// adminUserID identifies requests authenticated with the deployment API key
+const adminUserID = "admin"
+
+// authMiddleware authenticates requests with the deployment API key or a personal
+// token, sent as "Authorization: Bearer <token>" or "X-API-Key: <token>", with an
+// HMAC signature, or with a verified TLS client certificate for internal services.
+// It is a no-op when auth is not configured. Clients failing to authenticate get slower
+// answers, then are locked out by lockout when it is set.
+func authMiddleware(cfg internal.Config, tokens internal.TokenRepositoryInterface, uses *internal.TokenUses, lockout *internal.AuthLockout) func(http.Handler) http.Handler {
+	nonces := internal.NewNonceCache(2 * cfg.HMACMaxSkew)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Operations of a /batch call arrive already authenticated as the batch caller
+			if !cfg.AuthEnabled() || isPublic(r.URL.Path) || internal.PrincipalFromContext(r.Context()) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+				cert := r.TLS.VerifiedChains[0][0]
+				name, scopes, ok := internal.CertificateIdentity(cert, cfg.MTLSIdentities)
+				if !ok {
+					log.Printf("Security: rejected client certificate %q from %s: no identity mapping", cert.Subject.String(), r.RemoteAddr)
+					httpError(w, r, http.StatusForbidden, "client certificate is not authorized")
+					return
+				}
+				principal := &internal.Principal{UserID: "mtls:" + name, Scopes: scopes}
+				next.ServeHTTP(w, r.WithContext(internal.WithPrincipal(r.Context(), principal)))
+				return
+			}
+
+			// Credentials can be guessed from here on
+			keys := lockoutKeys(r, cfg)
+			if wait, locked := lockout.Locked(r.Context(), keys...); locked {
+				refuseLocked(w, r, "authentication", wait)
+				return
+			}
+
+			if r.Header.Get(internal.HeaderSignature) != "" {
+				principal, reason := verifySignedRequest(r, cfg, nonces)
+				if principal == nil {
+					log.Printf("Security: rejected signed request from %s %s %s: %s", r.RemoteAddr, r.Method, r.URL.Path, reason)
+					delayFailure(r, lockout, keys)
+					w.Header().Set("WWW-Authenticate", `HMAC-SHA256 realm="events"`)
+					httpError(w, r, http.StatusUnauthorized, "invalid request signature")
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(internal.WithPrincipal(r.Context(), principal)))
+				return
+			}
+
+			secret := bearerToken(r)
+			if secret == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="events"`)
+				httpError(w, r, http.StatusUnauthorized, "authentication required")
+				return
+			}
+
+			principal, err := authenticate(r.Context(), cfg, tokens, uses, secret, clientIP(r))
+			if err != nil {
+				if !errors.Is(err, internal.ErrTokenNotFound) {
+					log.Printf("Error authenticating request: %v", err)
+					httpError(w, r, http.StatusServiceUnavailable, "authentication unavailable")
+					return
+				}
+				log.Printf("Security: rejected token from %s %s %s", r.RemoteAddr, r.Method, r.URL.Path)
+				delayFailure(r, lockout, keys)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="events", error="invalid_token"`)
+				httpError(w, r, http.StatusUnauthorized, "invalid or expired token")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(internal.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// lockoutKeys are the keys failed authentications of r count against: the client IP,
+// and the HMAC client named by a signed request. Unknown key IDs are not counted, so
+// made-up ones cannot fill the table.
+func lockoutKeys(r *http.Request, cfg internal.Config) []string {
+	keys := []string{"ip:" + clientIP(r)}
+	if id := r.Header.Get(internal.HeaderSignatureKeyID); id != "" && r.Header.Get(internal.HeaderSignature) != "" {
+		if _, ok := cfg.HMACClients[id]; ok {
+			keys = append(keys, "account:hmac:"+id)
+		}
+	}
+	return keys
+}
+
+// clientIP is the address of the peer of r, without its port
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// refuseLocked answers a locked out client with when to retry
+func refuseLocked(w http.ResponseWriter, r *http.Request, what string, wait time.Duration) {
+	retry := int(math.Ceil(wait.Seconds()))
+	log.Printf("Security: refused %s from %s %s %s: locked out for %ds", what, r.RemoteAddr, r.Method, r.URL.Path, retry)
+	w.Header().Set("Retry-After", strconv.Itoa(retry))
+	httpError(w, r, http.StatusTooManyRequests, "too many failed authentication attempts, retry in %d seconds", retry)
+}
+
+// delayFailure records a failed authentication and holds the response for the delay
+// lockout asks, or until the client goes away
+func delayFailure(r *http.Request, lockout *internal.AuthLockout, keys []string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+	delay := lockout.Failed(ctx, keys...)
+	cancel()
+	if delay <= 0 {
+		return
+	}
+	select {
+	case <-time.After(delay):
+	case <-r.Context().Done():
+	}
+}
+
+// maxSignedBodyBytes bounds how much body is buffered to verify a signature
+const maxSignedBodyBytes = 1 << 20
+
+// verifySignedRequest checks the HMAC headers of r. On failure it returns a nil
+// principal and the reason, which is logged but not sent to the client.
+func verifySignedRequest(r *http.Request, cfg internal.Config, nonces *internal.NonceCache) (*internal.Principal, string) {
+	client, ok := cfg.HMACClients[r.Header.Get(internal.HeaderSignatureKeyID)]
+	if !ok {
+		return nil, "unknown key id"
+	}
+
+	timestamp := r.Header.Get(internal.HeaderSignatureTimestamp)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, "invalid timestamp"
+	}
+	now := time.Now()
+	if skew := now.Sub(time.Unix(unix, 0)); skew > cfg.HMACMaxSkew || skew < -cfg.HMACMaxSkew {
+		return nil, "timestamp outside allowed skew"
+	}
+
+	nonce := r.Header.Get(internal.HeaderSignatureNonce)
+	if len(nonce) < 16 || len(nonce) > 128 {
+		return nil, "nonce must be 16-128 characters"
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBodyBytes+1))
+	if err != nil {
+		return nil, "failed to read body"
+	}
+	if len(body) > maxSignedBodyBytes {
+		return nil, "body too large to verify"
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if !internal.VerifySignature(client.Secret, r.Header.Get(internal.HeaderSignature), r.Method, r.URL.RequestURI(), timestamp, nonce, body) {
+		return nil, "signature mismatch"
+	}
+	// Only remember nonces of valid signatures so forged requests cannot burn them
+	if !nonces.Use(client.ID, nonce, now) {
+		return nil, "replayed nonce"
+	}
+
+	return &internal.Principal{UserID: "hmac:" + client.ID, Scopes: client.Scopes}, ""
+}
+
+// bearerToken extracts the credential from the request headers
+func bearerToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if scheme, token, ok := strings.Cut(auth, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// authenticate resolves a secret, presented from ip, to a principal, recording the use
+// of tokens in uses
+func authenticate(ctx context.Context, cfg internal.Config, tokens internal.TokenRepositoryInterface, uses *internal.TokenUses, secret, ip string) (*internal.Principal, error) {
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(cfg.APIKey)) == 1 {
+		return &internal.Principal{UserID: adminUserID, Scopes: internal.AllScopes, Admin: true}, nil
+	}
+
+	if tokens == nil {
+		// Embedded without a token repository: only the API key is accepted
+		return nil, internal.ErrTokenNotFound
+	}
+	token, err := tokens.GetTokenByHash(ctx, internal.HashToken(secret))
+	if err != nil {
+		return nil, err
+	}
+	if token.RevokedAt != nil {
+		// Whoever holds a revoked token may have stolen it
+		log.Printf("Security: revoked token %s of %s used from %s", token.ID, token.UserID, ip)
+		return nil, internal.ErrTokenNotFound
+	}
+	if token.Expired(time.Now()) {
+		return nil, internal.ErrTokenNotFound
+	}
+
+	// last_used_at is informational, so it must not slow down or fail the request
+	uses.Record(token, ip, time.Now())
+	return &internal.Principal{UserID: token.UserID, Scopes: token.Scopes, TokenID: &token.ID}, nil
+}
+
+// requireScope rejects requests whose principal lacks scope. Without auth configured
+// there is no principal and every request is allowed.
+func requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := internal.PrincipalFromContext(r.Context())
+		if p != nil && !p.HasScope(scope) {
+			httpError(w, r, http.StatusForbidden, "token lacks required scope %s", scope)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireAdmin restricts an endpoint to the deployment API key. Like requireScope,
+// every request is allowed when auth is not configured.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := internal.PrincipalFromContext(r.Context())
+		if p != nil && !p.Admin {
+			httpError(w, r, http.StatusForbidden, "admin access required")
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,265 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTokenRepository keeps tokens in memory, keyed by secret hash
+type fakeTokenRepository struct {
+	tokens map[string]internal.APIToken
+}
+
+func (f *fakeTokenRepository) CreateToken(ctx context.Context, token internal.APIToken, hash []byte) (*internal.APIToken, error) {
+	f.tokens[string(hash)] = token
+	return &token, nil
+}
+
+func (f *fakeTokenRepository) GetTokenByHash(ctx context.Context, hash []byte) (*internal.APIToken, error) {
+	token, ok := f.tokens[string(hash)]
+	if !ok {
+		return nil, internal.ErrTokenNotFound
+	}
+	return &token, nil
+}
+
+func (f *fakeTokenRepository) ListTokens(ctx context.Context, userID string) ([]internal.APIToken, error) {
+	tokens := []internal.APIToken{}
+	for _, token := range f.tokens {
+		if token.UserID == userID {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+func (f *fakeTokenRepository) DeleteToken(ctx context.Context, userID string, id uuid.UUID) error {
+	return nil
+}
+
+func (f *fakeTokenRepository) RevokeToken(ctx context.Context, userID string, id uuid.UUID) error {
+	for hash, token := range f.tokens {
+		if token.ID == id && token.UserID == userID && token.RevokedAt == nil {
+			now := time.Now()
+			token.RevokedAt = &now
+			f.tokens[hash] = token
+			return nil
+		}
+	}
+	return internal.ErrTokenNotFound
+}
+
+func (f *fakeTokenRepository) RevokeTokens(ctx context.Context, userID string, except *uuid.UUID) (int, error) {
+	n := 0
+	for _, token := range f.tokens {
+		if token.UserID == userID && token.RevokedAt == nil && (except == nil || token.ID != *except) {
+			f.RevokeToken(ctx, userID, token.ID)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeTokenRepository) TouchToken(ctx context.Context, id uuid.UUID, ip string) error {
+	return nil
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	repo := &fakeTokenRepository{tokens: map[string]internal.APIToken{}}
+	past := time.Now().Add(-time.Hour)
+	repo.tokens[string(internal.HashToken("tc_reader"))] = internal.APIToken{ID: uuid.New(), UserID: "ana", Scopes: []string{internal.ScopeEventsRead}}
+	repo.tokens[string(internal.HashToken("tc_expired"))] = internal.APIToken{ID: uuid.New(), UserID: "ana", Scopes: internal.AllScopes, ExpiresAt: &past}
+
+	cfg := internal.Config{APIKey: "admin-secret"}
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	handler := authMiddleware(cfg, repo, nil, nil)(requireScope(internal.ScopeEventsWrite, ok))
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+	}{
+		{name: "missing credentials", wantStatus: http.StatusUnauthorized},
+		{name: "admin key", header: "X-API-Key", value: "admin-secret", wantStatus: http.StatusOK},
+		{name: "unknown token", header: "Authorization", value: "Bearer tc_nope", wantStatus: http.StatusUnauthorized},
+		{name: "expired token", header: "Authorization", value: "Bearer tc_expired", wantStatus: http.StatusUnauthorized},
+		{name: "missing scope", header: "Authorization", value: "Bearer tc_reader", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/events", bytes.NewBufferString("{}"))
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestAuthMiddlewareClientCertificate(t *testing.T) {
+	cfg := internal.Config{
+		APIKey:          "admin-secret",
+		TLSClientCAFile: "ca.pem",
+		MTLSIdentities:  map[string][]string{"billing": {internal.ScopeEventsWrite}, "reporting": {internal.ScopeEventsRead}},
+	}
+	var got *internal.Principal
+	handler := authMiddleware(cfg, nil, nil, nil)(requireScope(internal.ScopeEventsWrite, func(w http.ResponseWriter, r *http.Request) {
+		got = internal.PrincipalFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name       string
+		commonName string
+		wantStatus int
+		wantUser   string
+	}{
+		{name: "mapped certificate", commonName: "billing", wantStatus: http.StatusOK, wantUser: "mtls:billing"},
+		{name: "mapped certificate without the scope", commonName: "reporting", wantStatus: http.StatusForbidden},
+		{name: "unmapped certificate", commonName: "intruder", wantStatus: http.StatusForbidden},
+		{name: "no certificate", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			req := httptest.NewRequest(http.MethodPost, "/events", bytes.NewBufferString("{}"))
+			req.TLS = &tls.ConnectionState{}
+			if tt.commonName != "" {
+				cert := &x509.Certificate{Subject: pkix.Name{CommonName: tt.commonName}}
+				req.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantUser != "" {
+				require.NotNil(t, got)
+				assert.Equal(t, tt.wantUser, got.UserID)
+			}
+		})
+	}
+}
+
+// fakeAuthFailures counts failed authentications in memory
+type fakeAuthFailures struct {
+	failures map[string]int
+	locks    map[string]time.Time
+}
+
+func (f *fakeAuthFailures) AddAuthFailure(ctx context.Context, key string, window time.Duration) (int, int, error) {
+	f.failures[key]++
+	return f.failures[key], 0, nil
+}
+
+func (f *fakeAuthFailures) LockAuthKey(ctx context.Context, key string, until time.Time) error {
+	f.locks[key] = until
+	return nil
+}
+
+func (f *fakeAuthFailures) ListAuthLocks(ctx context.Context) (map[string]time.Time, error) {
+	return f.locks, nil
+}
+
+func TestAuthMiddlewareLockout(t *testing.T) {
+	failures := &fakeAuthFailures{failures: map[string]int{}, locks: map[string]time.Time{}}
+	lockout := internal.NewAuthLockout(failures, internal.AuthLockoutPolicy{Threshold: 2, Window: time.Minute, Duration: time.Minute, MaxDuration: time.Hour})
+	handler := authMiddleware(internal.Config{APIKey: "admin-secret"}, nil, nil, lockout)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	send := func(key, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/events", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	start := time.Now()
+	assert.Equal(t, http.StatusUnauthorized, send("guess-1", "203.0.113.7:5000").Code)
+	assert.Equal(t, http.StatusUnauthorized, send("guess-2", "203.0.113.7:5001").Code)
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond, "failures are answered slower and slower")
+	assert.Equal(t, 2, failures.failures["ip:203.0.113.7"], "counted by IP whatever the port")
+
+	// Once locked, even the right key is refused from that IP, but not from others
+	rec := send("admin-secret", "203.0.113.7:5002")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, send("admin-secret", "198.51.100.1:5000").Code)
+}
+
+func TestAuthMiddlewareDisabled(t *testing.T) {
+	handler := authMiddleware(internal.Config{}, nil, nil, nil)(requireScope(internal.ScopeEventsWrite, func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, internal.PrincipalFromContext(r.Context()))
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/events", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestAuthMiddlewareHMAC(t *testing.T) {
+	cfg := internal.Config{
+		HMACClients: map[string]internal.HMACClient{
+			"partner": {ID: "partner", Secret: "s3cret", Scopes: []string{internal.ScopeEventsWrite}},
+		},
+		HMACMaxSkew: 5 * time.Minute,
+	}
+	handler := authMiddleware(cfg, &fakeTokenRepository{}, nil, nil)(requireScope(internal.ScopeEventsWrite, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "hmac:partner", internal.PrincipalFromContext(r.Context()).UserID)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	body := []byte(`{"title":"Sync"}`)
+	signed := func(secret, nonce string, ts time.Time) *http.Request {
+		timestamp := strconv.FormatInt(ts.Unix(), 10)
+		req := httptest.NewRequest(http.MethodPost, "/events?source=erp", bytes.NewReader(body))
+		req.Header.Set(internal.HeaderSignatureKeyID, "partner")
+		req.Header.Set(internal.HeaderSignatureTimestamp, timestamp)
+		req.Header.Set(internal.HeaderSignatureNonce, nonce)
+		req.Header.Set(internal.HeaderSignature, internal.SignRequest(secret, http.MethodPost, "/events?source=erp", timestamp, nonce, body))
+		return req
+	}
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		wantStatus int
+	}{
+		{name: "valid signature", req: signed("s3cret", "nonce-0000000001", time.Now()), wantStatus: http.StatusOK},
+		{name: "replayed nonce", req: signed("s3cret", "nonce-0000000001", time.Now()), wantStatus: http.StatusUnauthorized},
+		{name: "wrong secret", req: signed("other", "nonce-0000000002", time.Now()), wantStatus: http.StatusUnauthorized},
+		{name: "stale timestamp", req: signed("s3cret", "nonce-0000000003", time.Now().Add(-10*time.Minute)), wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, tt.req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
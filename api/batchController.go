@@ -0,0 +1,214 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxBatchOperations bounds the work a single /batch call may queue
+const maxBatchOperations = 100
+
+// transactionalPrefixes are the paths whose repositories join a batch transaction.
+// Operations on other paths would commit on their own, so they are refused.
+var transactionalPrefixes = []string{"/events", "/calendars"}
+
+// BatchController runs several API calls in one request
+type BatchController struct {
+	tx      internal.Transactor
+	handler http.Handler
+}
+
+// NewBatchController creates a new batch controller
+func NewBatchController(tx internal.Transactor) *BatchController {
+	return &BatchController{tx: tx}
+}
+
+// RegisterRoutes adds the batch endpoint to router; operations are dispatched back
+// through router so they get the same routing, scopes and validation as direct calls
+func (bc *BatchController) RegisterRoutes(router *mux.Router) {
+	bc.handler = router
+	router.HandleFunc("/batch", bc.Batch).Methods("POST")
+}
+
+type batchOperation struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body"`
+}
+
+type batchInput struct {
+	// Transaction runs the operations atomically, stopping at the first failure
+	Transaction bool             `json:"transaction"`
+	Operations  []batchOperation `json:"operations"`
+}
+
+type batchResult struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+	// Committed is only set for transactional batches
+	Committed *bool `json:"committed,omitempty"`
+}
+
+// errBatchFailed rolls back a transactional batch after a failed operation
+var errBatchFailed = errors.New("batch operation failed")
+
+// Batch handles POST /batch
+func (bc *BatchController) Batch(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in batchInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	if len(in.Operations) == 0 || len(in.Operations) > maxBatchOperations {
+		httpError(w, r, http.StatusBadRequest, "operations must contain between 1 and %d entries", maxBatchOperations)
+		return
+	}
+	for i, op := range in.Operations {
+		if msg := validateBatchOperation(op, in.Transaction); msg != "" {
+			httpError(w, r, http.StatusBadRequest, "operation %d: %s", i, msg)
+			return
+		}
+	}
+
+	resp := batchResponse{Results: make([]batchResult, 0, len(in.Operations))}
+	if !in.Transaction {
+		for _, op := range in.Operations {
+			resp.Results = append(resp.Results, bc.dispatch(ctx, r, op))
+		}
+		writeBatchResponse(w, resp)
+		return
+	}
+
+	err := bc.tx.InTx(ctx, func(ctx context.Context) error {
+		for _, op := range in.Operations {
+			result := bc.dispatch(ctx, r, op)
+			resp.Results = append(resp.Results, result)
+			if result.Status >= 400 {
+				return errBatchFailed
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBatchFailed) {
+		log.Printf("Error running batch transaction: %v", err)
+		httpError(w, r, http.StatusInternalServerError, "Failed to run batch")
+		return
+	}
+
+	// Operations after the failed one never ran
+	for len(resp.Results) < len(in.Operations) {
+		resp.Results = append(resp.Results, batchResult{Status: http.StatusFailedDependency})
+	}
+	committed := err == nil
+	resp.Committed = &committed
+	writeBatchResponse(w, resp)
+}
+
+// validateBatchOperation returns a client-facing message when op cannot be run
+func validateBatchOperation(op batchOperation, transaction bool) string {
+	switch op.Method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return "method must be GET, POST, PUT, PATCH or DELETE"
+	}
+	if !strings.HasPrefix(op.Path, "/") || strings.HasPrefix(op.Path, "//") {
+		return "path must be an absolute path such as /events"
+	}
+	if op.Path == "/batch" || strings.HasPrefix(op.Path, "/batch?") {
+		return "batches cannot be nested"
+	}
+	if transaction {
+		for _, prefix := range transactionalPrefixes {
+			if op.Path == prefix || strings.HasPrefix(op.Path, prefix+"/") || strings.HasPrefix(op.Path, prefix+"?") {
+				return ""
+			}
+		}
+		return "path cannot be used in a transaction"
+	}
+	return ""
+}
+
+// dispatch runs one operation through the router as the caller of r
+func (bc *BatchController) dispatch(ctx context.Context, r *http.Request, op batchOperation) batchResult {
+	sub, err := http.NewRequestWithContext(ctx, op.Method, op.Path, bytes.NewReader(op.Body))
+	if err != nil {
+		return errorResult(http.StatusBadRequest, err.Error())
+	}
+	sub.RemoteAddr = r.RemoteAddr
+	for _, h := range []string{"Accept-Language", "User-Agent"} {
+		if v := r.Header.Get(h); v != "" {
+			sub.Header.Set(h, v)
+		}
+	}
+	if len(op.Body) > 0 {
+		sub.Header.Set("Content-Type", "application/json")
+	}
+
+	rec := newBatchRecorder()
+	bc.handler.ServeHTTP(rec, sub)
+
+	result := batchResult{Status: rec.status}
+	if b := bytes.TrimSpace(rec.body.Bytes()); len(b) > 0 {
+		if json.Valid(b) {
+			result.Body = b
+		} else {
+			// Errors are plain text; wrap them so the response stays valid JSON
+			result.Body, _ = json.Marshal(string(b))
+		}
+	}
+	return result
+}
+
+func errorResult(status int, msg string) batchResult {
+	body, _ := json.Marshal(msg)
+	return batchResult{Status: status, Body: body}
+}
+
+func writeBatchResponse(w http.ResponseWriter, resp batchResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// batchRecorder captures the response of one batch operation
+type batchRecorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func newBatchRecorder() *batchRecorder {
+	return &batchRecorder{header: http.Header{}, status: http.StatusOK}
+}
+
+func (rec *batchRecorder) Header() http.Header { return rec.header }
+
+func (rec *batchRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.body.Write(b)
+}
+
+func (rec *batchRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status, rec.wroteHeader = status, true
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTransactor records whether the batch committed
+type fakeTransactor struct {
+	committed, rolledBack bool
+}
+
+func (f *fakeTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := fn(ctx)
+	f.committed, f.rolledBack = err == nil, err != nil
+	return err
+}
+
+func newBatchRouter(tx *fakeTransactor) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"1"}`))
+	}).Methods("POST")
+	router.HandleFunc("/events/{id}", func(w http.ResponseWriter, r *http.Request) {
+		httpError(w, r, http.StatusNotFound, "Event not found")
+	}).Methods("DELETE")
+	router.HandleFunc("/tokens", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+	NewBatchController(tx).RegisterRoutes(router)
+	return router
+}
+
+func TestBatch(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		wantStatus    int
+		wantResults   []int
+		wantCommitted *bool
+	}{
+		{
+			name:        "sequential runs every operation",
+			body:        `{"operations": [{"method": "DELETE", "path": "/events/x"}, {"method": "POST", "path": "/events", "body": {}}]}`,
+			wantStatus:  http.StatusOK,
+			wantResults: []int{http.StatusNotFound, http.StatusCreated},
+		},
+		{
+			name:          "transaction commits when every operation succeeds",
+			body:          `{"transaction": true, "operations": [{"method": "POST", "path": "/events", "body": {}}]}`,
+			wantStatus:    http.StatusOK,
+			wantResults:   []int{http.StatusCreated},
+			wantCommitted: boolPtr(true),
+		},
+		{
+			name:          "transaction stops at the first failure",
+			body:          `{"transaction": true, "operations": [{"method": "POST", "path": "/events"}, {"method": "DELETE", "path": "/events/x"}, {"method": "POST", "path": "/events"}]}`,
+			wantStatus:    http.StatusOK,
+			wantResults:   []int{http.StatusCreated, http.StatusNotFound, http.StatusFailedDependency},
+			wantCommitted: boolPtr(false),
+		},
+		{
+			name:       "path outside the transaction",
+			body:       `{"transaction": true, "operations": [{"method": "GET", "path": "/tokens"}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "nested batch",
+			body:       `{"operations": [{"method": "POST", "path": "/batch"}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "no operations",
+			body:       `{"operations": []}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &fakeTransactor{}
+			req := httptest.NewRequest(http.MethodPost, "/batch", bytes.NewBufferString(tt.body))
+			rec := httptest.NewRecorder()
+
+			newBatchRouter(tx).ServeHTTP(rec, req)
+
+			require.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp batchResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			var statuses []int
+			for _, res := range resp.Results {
+				statuses = append(statuses, res.Status)
+			}
+			assert.Equal(t, tt.wantResults, statuses)
+			assert.Equal(t, tt.wantCommitted, resp.Committed)
+			if tt.wantCommitted != nil {
+				assert.Equal(t, *tt.wantCommitted, tx.committed)
+			}
+		})
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// staleWhileRevalidate is how long past their max-age caches may serve the public
+// listings (feeds, widgets and event pages) while fetching a fresh copy, so a CDN in
+// front of them keeps answering instantly as they expire
+const staleWhileRevalidate = time.Hour
+
+// cacheControl returns a Cache-Control value letting caches of visibility (public or
+// private) keep a response for maxAge and serve it stale for staleWhileRevalidate more
+func cacheControl(visibility string, maxAge time.Duration) string {
+	return fmt.Sprintf("%s, max-age=%d, stale-while-revalidate=%d", visibility, int(maxAge.Seconds()), int(staleWhileRevalidate.Seconds()))
+}
+
+// parseCacheRoutes parses CACHE_CONTROL, entries such as
+// "/e/{id} public, max-age=60, stale-while-revalidate=600" separated by semicolons, into
+// header values by route template
+func parseCacheRoutes(spec string) (map[string]string, error) {
+	routes := map[string]string{}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, value, ok := strings.Cut(entry, " ")
+		value = strings.TrimSpace(value)
+		if !ok || !strings.HasPrefix(route, "/") || value == "" {
+			return nil, fmt.Errorf("cache control %q must be a route template and a header value", entry)
+		}
+		routes[route] = value
+	}
+	return routes, nil
+}
+
+// cacheControlMiddleware sets the Cache-Control of successful GET and HEAD responses of
+// the route templates in routes, replacing the handler's own
+func cacheControlMiddleware(routes map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			current := mux.CurrentRoute(r)
+			if current == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			tpl, err := current.GetPathTemplate()
+			value, ok := routes[tpl]
+			if err != nil || !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(&cacheControlWriter{ResponseWriter: w, value: value}, r)
+		})
+	}
+}
+
+// cacheControlWriter sets the configured Cache-Control when a successful response
+// starts, after the handler set its own
+type cacheControlWriter struct {
+	http.ResponseWriter
+	value   string
+	started bool
+}
+
+func (cw *cacheControlWriter) WriteHeader(status int) {
+	if !cw.started {
+		cw.started = true
+		if status == http.StatusOK {
+			cw.Header().Set("Cache-Control", cw.value)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *cacheControlWriter) Write(b []byte) (int, error) {
+	if !cw.started {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,307 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// CalendarController handles HTTP requests for calendars
+type CalendarController struct {
+	calendarRepo internal.CalendarRepositoryInterface
+	eventRepo    internal.EventRepositoryInterface
+	// orgs, when set, lets organization admins manage the organization's calendars
+	orgs     internal.OrganizationRepositoryInterface
+	redactor *internal.EventRedactor
+}
+
+// NewCalendarController creates a new calendar controller
+func NewCalendarController(calendarRepo internal.CalendarRepositoryInterface, eventRepo internal.EventRepositoryInterface, orgs internal.OrganizationRepositoryInterface, redactor *internal.EventRedactor) *CalendarController {
+	return &CalendarController{calendarRepo: calendarRepo, eventRepo: eventRepo, orgs: orgs, redactor: redactor}
+}
+
+// RegisterRoutes adds the calendar endpoints to router
+func (cc *CalendarController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/calendars", requireScope(internal.ScopeEventsWrite, cc.CreateCalendar)).Methods("POST")
+	router.HandleFunc("/calendars", requireScope(internal.ScopeEventsRead, cc.GetCalendars)).Methods("GET")
+	router.HandleFunc("/calendars/{id}", requireScope(internal.ScopeEventsRead, cc.GetCalendar)).Methods("GET")
+	router.HandleFunc("/calendars/{id}", requireScope(internal.ScopeEventsWrite, cc.UpdateCalendar)).Methods("PATCH")
+	router.HandleFunc("/calendars/{id}", requireScope(internal.ScopeEventsWrite, cc.DeleteCalendar)).Methods("DELETE")
+	router.HandleFunc("/calendars/{id}/events", requireScope(internal.ScopeEventsRead, cc.GetCalendarEvents)).Methods("GET")
+}
+
+type createCalendarInput struct {
+	Name      string `json:"name"`
+	Exclusive bool   `json:"exclusive"`
+	// Visibility is full by default; busy shows other users busy blocks
+	Visibility string `json:"visibility"`
+	// OrganizationID makes the calendar the organization's; its admins may
+	OrganizationID *uuid.UUID `json:"organization_id"`
+}
+
+type updateCalendarInput struct {
+	Name       *string `json:"name"`
+	Exclusive  *bool   `json:"exclusive"`
+	Visibility *string `json:"visibility"`
+}
+
+// principalID returns the caller's user ID, or "" when authentication is not enabled
+func principalID(r *http.Request) string {
+	if p := internal.PrincipalFromContext(r.Context()); p != nil {
+		return p.UserID
+	}
+	return ""
+}
+
+// managesCalendar reports whether the caller may change a calendar: its owner, or an
+// admin of the organization owning it. orgs may be nil.
+func managesCalendar(ctx context.Context, orgs internal.OrganizationRepositoryInterface, r *http.Request, c internal.Calendar) (bool, error) {
+	if ownsCalendar(r, c) {
+		return true, nil
+	}
+	if c.OrganizationID == nil {
+		return false, nil
+	}
+	role, err := orgRole(ctx, orgs, r, *c.OrganizationID)
+	return internal.RoleAtLeast(role, internal.OrgRoleAdmin), err
+}
+
+// CreateCalendar handles POST /calendars. Calendars created for an organization
+// need the admin role in it.
+func (cc *CalendarController) CreateCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in createCalendarInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" || len(in.Name) > 100 {
+		httpError(w, r, http.StatusBadRequest, "name is required and must be <= 100 characters")
+		return
+	}
+	if in.Visibility == "" {
+		in.Visibility = internal.CalendarVisibilityFull
+	}
+	if !internal.ValidCalendarVisibility(in.Visibility) {
+		httpError(w, r, http.StatusBadRequest, "visibility must be full or busy")
+		return
+	}
+
+	if in.OrganizationID != nil {
+		role, err := orgRole(ctx, cc.orgs, r, *in.OrganizationID)
+		if err != nil {
+			repositoryError(ctx, w, r, err, "getting membership", "Failed to create calendar")
+			return
+		}
+		if role == "" {
+			repositoryError(ctx, w, r, internal.ErrUnknownOrganization, "creating calendar", "Failed to create calendar")
+			return
+		}
+		if !internal.RoleAtLeast(role, internal.OrgRoleAdmin) {
+			httpError(w, r, http.StatusForbidden, "this requires the %s role in the organization", internal.OrgRoleAdmin)
+			return
+		}
+	}
+
+	calendar, err := cc.calendarRepo.CreateCalendar(ctx, internal.Calendar{
+		ID:             uuid.New(),
+		Name:           in.Name,
+		OwnerID:        principalID(r),
+		OrganizationID: in.OrganizationID,
+		Exclusive:      in.Exclusive,
+		Visibility:     in.Visibility,
+	})
+	if err != nil {
+		repositoryError(ctx, w, r, err, "creating calendar", "Failed to create calendar")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(calendar)
+}
+
+// GetCalendars handles GET /calendars, listing the caller's calendars; admins see
+// every calendar
+func (cc *CalendarController) GetCalendars(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	all, err := cc.calendarRepo.ListCalendars(ctx)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing calendars", "Failed to get calendars")
+		return
+	}
+	calendars := []internal.Calendar{}
+	for _, c := range all {
+		if ownsCalendar(r, c) {
+			calendars = append(calendars, c)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(calendars)
+}
+
+// GetCalendar handles GET /calendars/{id}
+func (cc *CalendarController) GetCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	calendar, err := cc.calendarRepo.GetCalendar(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting calendar", "Failed to get calendar")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(calendar)
+}
+
+// UpdateCalendar handles PATCH /calendars/{id}, changing its name, visibility or
+// whether it is exclusive. Only the owner, or an admin of the organization owning it, may; making a
+// calendar with overlapping events exclusive is answered with a 409.
+func (cc *CalendarController) UpdateCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+	var in updateCalendarInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	calendar, err := cc.calendarRepo.GetCalendar(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting calendar", "Failed to get calendar")
+		return
+	}
+	manages, err := managesCalendar(ctx, cc.orgs, r, *calendar)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting membership", "Failed to update calendar")
+		return
+	}
+	if !manages {
+		httpError(w, r, http.StatusForbidden, "only the calendar's owner can change it")
+		return
+	}
+	if in.Name != nil {
+		calendar.Name = strings.TrimSpace(*in.Name)
+		if calendar.Name == "" || len(calendar.Name) > 100 {
+			httpError(w, r, http.StatusBadRequest, "name is required and must be <= 100 characters")
+			return
+		}
+	}
+	if in.Exclusive != nil {
+		calendar.Exclusive = *in.Exclusive
+	}
+	if in.Visibility != nil {
+		if !internal.ValidCalendarVisibility(*in.Visibility) {
+			httpError(w, r, http.StatusBadRequest, "visibility must be full or busy")
+			return
+		}
+		calendar.Visibility = *in.Visibility
+	}
+
+	updated, err := cc.calendarRepo.UpdateCalendar(ctx, *calendar)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "updating calendar", "Failed to update calendar")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteCalendar handles DELETE /calendars/{id}; the calendar's events are deleted too
+// unless DELETE_POLICIES says otherwise. Only the owner, or an admin of the organization
+// owning it, may: the event hooks checking calendar access do not see this delete.
+func (cc *CalendarController) DeleteCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	calendar, err := cc.calendarRepo.GetCalendar(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting calendar", "Failed to get calendar")
+		return
+	}
+	manages, err := managesCalendar(ctx, cc.orgs, r, *calendar)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting membership", "Failed to delete calendar")
+		return
+	}
+	if !manages {
+		httpError(w, r, http.StatusForbidden, "only the calendar's owner can change it")
+		return
+	}
+
+	if err := cc.calendarRepo.DeleteCalendar(ctx, id); err != nil {
+		repositoryError(ctx, w, r, err, "deleting calendar", "Failed to delete calendar")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetCalendarEvents handles GET /calendars/{id}/events
+func (cc *CalendarController) GetCalendarEvents(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	if _, err := cc.calendarRepo.GetCalendar(ctx, id); err != nil {
+		repositoryError(ctx, w, r, err, "getting calendar", "Failed to get calendar")
+		return
+	}
+
+	events, err := cc.eventRepo.GetEventsByCalendar(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting calendar events", "Failed to get events")
+		return
+	}
+
+	events, hidden := redactEvents(ctx, r, cc.redactor, listed(r, events))
+	out := make([]eventResponse, len(events))
+	for i, e := range events {
+		out[i] = newEventResponse(e, hidden[i])
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// overlappingCalendars refuses to make calendars exclusive, as the database does
+// when their events overlap
+type overlappingCalendars struct {
+	fakeCalendarRepository
+	overlapping bool
+}
+
+func (f *overlappingCalendars) UpdateCalendar(ctx context.Context, c internal.Calendar) (*internal.Calendar, error) {
+	if c.Exclusive && f.overlapping {
+		return nil, internal.ErrCalendarOverlaps
+	}
+	f.calendars[c.ID] = c
+	return &c, nil
+}
+
+func TestUpdateCalendar(t *testing.T) {
+	calendar := internal.Calendar{ID: uuid.New(), Name: "Room 1", OwnerID: "alice"}
+	calendars := &overlappingCalendars{fakeCalendarRepository: fakeCalendarRepository{calendars: map[uuid.UUID]internal.Calendar{calendar.ID: calendar}}, overlapping: true}
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		return &internal.Principal{UserID: r.Header.Get("X-User"), Scopes: []string{internal.ScopeEventsWrite}}, nil
+	}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{Events: &fakeEventRepository{}, Calendars: calendars, Auth: hook})
+	require.NoError(t, err)
+	patch := func(user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/calendars/"+calendar.ID.String(), strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusForbidden, patch("bob", `{"exclusive": true}`).Code)
+	rec := patch("alice", `{"exclusive": true}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "calendar has overlapping events")
+
+	calendars.overlapping = false
+	rec = patch("alice", `{"exclusive": true, "name": " Room A "}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var updated internal.Calendar
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &updated))
+	assert.True(t, updated.Exclusive)
+	assert.Equal(t, "Room A", updated.Name)
+
+	assert.Equal(t, http.StatusBadRequest, patch("alice", `{"name": ""}`).Code)
+}
+
+func TestCalendarOwnership(t *testing.T) {
+	alice := internal.Calendar{ID: uuid.New(), Name: "Alice", OwnerID: "alice"}
+	bob := internal.Calendar{ID: uuid.New(), Name: "Bob", OwnerID: "bob"}
+	calendars := &fakeCalendarRepository{calendars: map[uuid.UUID]internal.Calendar{alice.ID: alice, bob.ID: bob}}
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		return &internal.Principal{UserID: r.Header.Get("X-User"), Scopes: []string{internal.ScopeEventsRead, internal.ScopeEventsWrite}}, nil
+	}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{Events: &fakeEventRepository{}, Calendars: calendars, Auth: hook})
+	require.NoError(t, err)
+	do := func(method, path, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Users list their own calendars only
+	rec := do(http.MethodGet, "/calendars", "alice")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var listed []internal.Calendar
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	require.Len(t, listed, 1)
+	assert.Equal(t, alice.ID, listed[0].ID)
+
+	// and delete them, not those of others
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/calendars/"+alice.ID.String(), "bob").Code)
+	assert.Contains(t, calendars.calendars, alice.ID)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/calendars/"+alice.ID.String(), "alice").Code)
+	assert.NotContains(t, calendars.calendars, alice.ID)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/calendars/"+alice.ID.String(), "alice").Code)
+}
+
+func TestBusyCalendarRedaction(t *testing.T) {
+	calendar := internal.Calendar{ID: uuid.New(), Name: "Alice", OwnerID: "alice", Visibility: internal.CalendarVisibilityBusy}
+	calendars := &fakeCalendarRepository{calendars: map[uuid.UUID]internal.Calendar{calendar.ID: calendar}}
+	description, location := "Salary review with Bob", "Room 4"
+	start := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+	externalID := "hr-42"
+	private := internal.EventDB{ID: uuid.New(), CalendarID: &calendar.ID, Title: "1:1", Description: &description, Location: &location, StartTime: start, EndTime: start.Add(time.Hour), ExternalID: &externalID}
+	public := internal.EventDB{ID: uuid.New(), Title: "All hands", StartTime: start, EndTime: start.Add(time.Hour)}
+	events := &calendarEvents{fakeEventRepository{events: []internal.EventDB{private, public}}}
+	delegates := &fakeDelegateRepository{grants: map[string]internal.Delegate{}}
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		return &internal.Principal{UserID: r.Header.Get("X-User"), Scopes: []string{internal.ScopeEventsRead, internal.ScopeEventsWrite}}, nil
+	}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{Events: events, Calendars: calendars, Delegates: delegates, Auth: hook})
+	require.NoError(t, err)
+	list := func(path, user string) []map[string]any {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var out []map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+		require.Len(t, out, 2)
+		return out
+	}
+
+	// Other users see when Alice is busy, not what she does
+	for _, path := range []string{"/events", "/calendars/" + calendar.ID.String() + "/events"} {
+		seen := list(path, "bob")
+		assert.Equal(t, "Busy", seen[0]["title"], path)
+		assert.Equal(t, true, seen[0]["redacted"], path)
+		assert.Nil(t, seen[0]["description"], path)
+		assert.Nil(t, seen[0]["location"], path)
+		assert.Equal(t, "2025-09-15T09:00:00Z", seen[0]["start_time"], path)
+		assert.Equal(t, "All hands", seen[1]["title"], path)
+		assert.Nil(t, seen[1]["redacted"], path)
+	}
+	assert.Equal(t, "1:1", list("/events", "alice")[0]["title"])
+
+	// Filters and lookups on what busy blocks hide leave them out; filters on times do not
+	filtered := func(path, user string) []map[string]any {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var out []map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+		return out
+	}
+	assert.Empty(t, filtered(`/events?filter=title:"1:1"`, "bob"))
+	assert.Empty(t, filtered(`/events?filter=title:"1:1"&view=summary`, "bob"))
+	assert.Empty(t, filtered("/events?external_id=hr-42", "bob"))
+	assert.Empty(t, filtered("/events?external_id=hr-42&view=summary", "bob"))
+	seen := filtered("/events?filter=start_time>=2025-09-15", "bob")
+	require.Len(t, seen, 2)
+	assert.Equal(t, "Busy", seen[0]["title"])
+	assert.Len(t, filtered(`/events?filter=title:"1:1"`, "alice"), 1)
+	assert.Len(t, filtered("/events?external_id=hr-42", "alice"), 1)
+
+	// Delegates see the events they work on
+	delegates.grants[calendar.ID.String()+"/bob"] = internal.Delegate{CalendarID: calendar.ID, UserID: "bob", Permissions: []string{internal.DelegateEdit}}
+	assert.Equal(t, "1:1", list("/events", "bob")[0]["title"])
+
+	// Only the owner changes the visibility, to full or busy
+	patch := func(user, body string) int {
+		req := httptest.NewRequest(http.MethodPatch, "/calendars/"+calendar.ID.String(), strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusBadRequest, patch("alice", `{"visibility": "private"}`))
+	assert.Equal(t, http.StatusForbidden, patch("carol", `{"visibility": "full"}`))
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"taller_challenge/internal"
+
+	"github.com/gorilla/mux"
+)
+
+// headerChaos names the faults injected into a response, so client logs tell them
+// from real failures
+const headerChaos = "X-Chaos"
+
+// chaosMiddleware injects the faults of the rules matching each request's method and
+// route template: latency before the request is served, then an error response or a
+// dropped connection instead of serving it
+func chaosMiddleware(chaos *internal.Chaos) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := r.URL.Path
+			if current := mux.CurrentRoute(r); current != nil {
+				if tpl, err := current.GetPathTemplate(); err == nil {
+					route = tpl
+				}
+			}
+			latency, fault := chaos.Decide(r.Method, route)
+			if latency > 0 {
+				w.Header().Add(headerChaos, internal.FaultLatency+"="+latency.String())
+				if err := internal.ChaosSleep(r.Context(), latency); err != nil {
+					return
+				}
+			}
+			switch {
+			case fault == nil:
+				next.ServeHTTP(w, r)
+			case fault.Kind == internal.FaultDrop:
+				log.Printf("Chaos: dropping the connection of %s %s", r.Method, r.URL.Path)
+				conn, _, err := http.NewResponseController(w).Hijack()
+				if err != nil {
+					// Aborting the handler makes the server close the connection
+					panic(http.ErrAbortHandler)
+				}
+				conn.Close()
+			default:
+				w.Header().Add(headerChaos, fault.String())
+				if fault.Status == http.StatusServiceUnavailable || fault.Status == http.StatusTooManyRequests {
+					w.Header().Set("Retry-After", "1")
+				}
+				httpError(w, r, fault.Status, "Fault injected for resilience testing")
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"taller_challenge/internal"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaosMiddleware(t *testing.T) {
+	chaos, err := internal.NewChaos(internal.Config{ChaosRules: "GET /events=latency:1ms,error:503;POST /events=drop"})
+	require.NoError(t, err)
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{Events: &fakeEventRepository{}, Chaos: chaos})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("X-API-Key", "admin-secret")
+	rec := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	assert.Equal(t, []string{"latency=1ms", "error:503"}, rec.Header().Values("X-Chaos"))
+
+	// Dropped connections reach clients as transport errors
+	ts := httptest.NewServer(srv.Router)
+	defer ts.Close()
+	_, err = http.Post(ts.URL+"/events", "application/json", nil)
+	assert.Error(t, err)
+
+	rec = httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "routes without rules are served")
+}
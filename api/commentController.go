@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Page sizes of GET /events/{id}/comments
+const (
+	defaultCommentLimit = 50
+	maxCommentLimit     = 200
+)
+
+// maxCommentLength bounds a comment body, in characters
+const maxCommentLength = 5000
+
+// mentionTimeout bounds the mention notifications of one comment, which are sent
+// after the response
+const mentionTimeout = 30 * time.Second
+
+// CommentController handles HTTP requests for event discussion threads
+type CommentController struct {
+	comments internal.CommentRepositoryInterface
+	events   internal.EventRepositoryInterface
+	mentions *internal.MentionNotifier
+}
+
+// NewCommentController creates a new comment controller. mentions may be nil, in
+// which case mentioned users are not notified.
+func NewCommentController(comments internal.CommentRepositoryInterface, events internal.EventRepositoryInterface, mentions *internal.MentionNotifier) *CommentController {
+	return &CommentController{comments: comments, events: events, mentions: mentions}
+}
+
+// RegisterRoutes adds the comment endpoints to router
+func (cc *CommentController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/events/{id}/comments", requireScope(internal.ScopeEventsWrite, cc.CreateComment)).Methods("POST")
+	router.HandleFunc("/events/{id}/comments", requireScope(internal.ScopeEventsRead, cc.GetComments)).Methods("GET")
+	router.HandleFunc("/events/{id}/comments/{commentId}", requireScope(internal.ScopeEventsWrite, cc.DeleteComment)).Methods("DELETE")
+}
+
+type createCommentInput struct {
+	Body string `json:"body"`
+}
+
+type commentPageResponse struct {
+	Comments []internal.EventComment `json:"comments"`
+	// Cursor is passed back as ?cursor= for the next page
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"has_more"`
+}
+
+// CreateComment handles POST /events/{id}/comments. Users written as @user in the
+// body are notified.
+func (cc *CommentController) CreateComment(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in createCommentInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	body := strings.TrimSpace(in.Body)
+	if body == "" || utf8.RuneCountInString(body) > maxCommentLength {
+		httpError(w, r, http.StatusBadRequest, "body is required and must be <= %d characters", maxCommentLength)
+		return
+	}
+
+	event := loadVisibleEvent(ctx, w, r, cc.events)
+	if event == nil {
+		return
+	}
+
+	comment, err := cc.comments.CreateComment(ctx, internal.EventComment{
+		ID:       uuid.New(),
+		EventID:  event.ID,
+		Author:   principalID(r),
+		Body:     body,
+		Mentions: internal.ParseMentions(body),
+	})
+	if err != nil {
+		repositoryError(ctx, w, r, err, "creating comment", "Failed to create comment")
+		return
+	}
+
+	if len(comment.Mentions) > 0 && cc.mentions != nil {
+		notifyCtx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), mentionTimeout)
+		go func() {
+			defer cancel()
+			cc.mentions.NotifyMentions(notifyCtx, *event, *comment)
+		}()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// GetComments handles GET /events/{id}/comments?cursor=&limit=, oldest first
+func (cc *CommentController) GetComments(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := internal.ParseTimeCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+	limit := defaultCommentLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxCommentLimit {
+			httpError(w, r, http.StatusBadRequest, "limit must be between 1 and %d", maxCommentLimit)
+			return
+		}
+		limit = n
+	}
+
+	event := loadVisibleEvent(ctx, w, r, cc.events)
+	if event == nil {
+		return
+	}
+
+	page, err := cc.comments.ListComments(ctx, event.ID, cursor, limit)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing comments", "Failed to get comments")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(commentPageResponse{
+		Comments: page.Comments,
+		Cursor:   page.Next.String(),
+		HasMore:  page.HasMore,
+	})
+}
+
+// DeleteComment handles DELETE /events/{id}/comments/{commentId}. Only the author and
+// admins may delete a comment.
+func (cc *CommentController) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	commentID, err := uuid.Parse(mux.Vars(r)["commentId"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+	event := loadVisibleEvent(ctx, w, r, cc.events)
+	if event == nil {
+		return
+	}
+
+	comment, err := cc.comments.GetComment(ctx, event.ID, commentID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting comment", "Failed to delete comment")
+		return
+	}
+	if p := internal.PrincipalFromContext(r.Context()); p != nil && !p.Admin && p.UserID != comment.Author {
+		httpError(w, r, http.StatusForbidden, "only the author can delete a comment")
+		return
+	}
+
+	if err := cc.comments.DeleteComment(ctx, event.ID, commentID); err != nil {
+		repositoryError(ctx, w, r, err, "deleting comment", "Failed to delete comment")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// coverMaxAge is how long clients may reuse a cover; a new upload changes its URL's ETag
+const coverMaxAge = 24 * time.Hour
+
+// CoverController handles the cover images of events
+type CoverController struct {
+	covers   *internal.CoverStore
+	events   internal.EventRepositoryInterface
+	access   *internal.CalendarAccess
+	maxBytes int
+}
+
+// NewCoverController creates a new cover controller accepting uploads up to maxBytes.
+// Covers are written beside the event repository and its hooks, so changing one takes
+// the edit permission on the event's calendar from access; nil allows every caller.
+func NewCoverController(covers *internal.CoverStore, events internal.EventRepositoryInterface, access *internal.CalendarAccess, maxBytes int) *CoverController {
+	return &CoverController{covers: covers, events: events, access: access, maxBytes: maxBytes}
+}
+
+// RegisterRoutes adds the cover endpoints to router
+func (cc *CoverController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/events/{id}/cover", requireScope(internal.ScopeEventsWrite, cc.PutCover)).Methods("PUT")
+	router.HandleFunc("/events/{id}/cover", requireScope(internal.ScopeEventsRead, cc.GetCover)).Methods("GET")
+	router.HandleFunc("/events/{id}/cover", requireScope(internal.ScopeEventsWrite, cc.DeleteCover)).Methods("DELETE")
+}
+
+// PutCover handles PUT /events/{id}/cover. The image is the request body, or the
+// "cover" field of a multipart form.
+func (cc *CoverController) PutCover(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	data, err := cc.readImage(w, r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			httpError(w, r, http.StatusRequestEntityTooLarge, "cover must be at most %d bytes", cc.maxBytes)
+			return
+		}
+		httpError(w, r, http.StatusBadRequest, "invalid upload: %v", err)
+		return
+	}
+
+	event := cc.loadEditableEvent(ctx, w, r)
+	if event == nil {
+		return
+	}
+
+	cover, err := cc.covers.Upload(ctx, event.ID, data)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "uploading cover", "Failed to upload cover")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cover)
+}
+
+// loadEditableEvent loads the event of the request for a cover change, writing the
+// error response and returning nil when it is missing or the caller may not edit it
+func (cc *CoverController) loadEditableEvent(ctx context.Context, w http.ResponseWriter, r *http.Request) *internal.EventDB {
+	event := loadVisibleEvent(ctx, w, r, cc.events)
+	if event == nil || cc.access == nil {
+		return event
+	}
+	if err := cc.access.Check(ctx, event.CalendarID, internal.DelegateEdit); err != nil {
+		repositoryError(ctx, w, r, err, "checking cover access", "Failed to change cover")
+		return nil
+	}
+	return event
+}
+
+// readImage returns the uploaded image, at most maxBytes long
+func (cc *CoverController) readImage(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	// Leave room for the multipart framing around the image
+	r.Body = http.MaxBytesReader(w, r.Body, int64(cc.maxBytes)+64<<10)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return readLimited(r.Body, cc.maxBytes)
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, errors.New(`no "cover" field`)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "cover" {
+			return readLimited(part, cc.maxBytes)
+		}
+	}
+}
+
+// readLimited reads r, failing with an *http.MaxBytesError past limit bytes
+func readLimited(r io.Reader, limit int) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > limit {
+		return nil, &http.MaxBytesError{Limit: int64(limit)}
+	}
+	return data, nil
+}
+
+// GetCover handles GET /events/{id}/cover?size=, the original image or the thumbnail
+// of a configured width
+func (cc *CoverController) GetCover(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	size := 0
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			httpError(w, r, http.StatusBadRequest, "size must be a positive integer")
+			return
+		}
+		size = n
+	}
+
+	event := loadVisibleEvent(ctx, w, r, cc.events)
+	if event == nil {
+		return
+	}
+	cover, err := cc.covers.Get(ctx, event.ID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting cover", "Failed to get cover")
+		return
+	}
+
+	etag := fmt.Sprintf(`"%s-%d"`, cover.ETag, size)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(coverMaxAge.Seconds())))
+	w.Header().Set("Last-Modified", cover.UpdatedAt.UTC().Format(http.TimeFormat))
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data, contentType, err := cc.covers.Open(ctx, *cover, size)
+	if err != nil {
+		w.Header().Del("ETag")
+		w.Header().Del("Cache-Control")
+		w.Header().Del("Last-Modified")
+		repositoryError(ctx, w, r, err, "reading cover", "Failed to get cover")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing weakly
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteCover handles DELETE /events/{id}/cover
+func (cc *CoverController) DeleteCover(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	event := cc.loadEditableEvent(ctx, w, r)
+	if event == nil {
+		return
+	}
+	if err := cc.covers.Delete(ctx, event.ID); err != nil {
+		repositoryError(ctx, w, r, err, "deleting cover", "Failed to delete cover")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCoverRepository keeps cover metadata in memory
+type fakeCoverRepository struct {
+	covers map[uuid.UUID]internal.EventCover
+}
+
+func (f *fakeCoverRepository) SaveCover(ctx context.Context, c internal.EventCover) (*internal.EventCover, error) {
+	f.covers[c.EventID] = c
+	return &c, nil
+}
+
+func (f *fakeCoverRepository) GetCover(ctx context.Context, eventID uuid.UUID) (*internal.EventCover, error) {
+	c, ok := f.covers[eventID]
+	if !ok {
+		return nil, internal.ErrCoverNotFound
+	}
+	return &c, nil
+}
+
+func (f *fakeCoverRepository) DeleteCover(ctx context.Context, eventID uuid.UUID) error {
+	if _, ok := f.covers[eventID]; !ok {
+		return internal.ErrCoverNotFound
+	}
+	delete(f.covers, eventID)
+	return nil
+}
+
+func TestCoverNeedsEditPermission(t *testing.T) {
+	calendar := internal.Calendar{ID: uuid.New(), Name: "Alice", OwnerID: "alice"}
+	calendars := &fakeCalendarRepository{calendars: map[uuid.UUID]internal.Calendar{calendar.ID: calendar}}
+	start := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+	event := internal.EventDB{ID: uuid.New(), CalendarID: &calendar.ID, Title: "Launch", StartTime: start, EndTime: start.Add(time.Hour)}
+	events := &eventsByID{byID: map[uuid.UUID]internal.EventDB{event.ID: event}}
+	delegates := &fakeDelegateRepository{grants: map[string]internal.Delegate{
+		calendar.ID.String() + "/carol": {CalendarID: calendar.ID, UserID: "carol", Permissions: []string{internal.DelegateEdit}},
+		calendar.ID.String() + "/dave":  {CalendarID: calendar.ID, UserID: "dave", Permissions: []string{internal.DelegateCreate}},
+	}}
+	covers := &fakeCoverRepository{covers: map[uuid.UUID]internal.EventCover{event.ID: {EventID: event.ID, ETag: "v1"}}}
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		return &internal.Principal{UserID: r.Header.Get("X-User"), Scopes: []string{internal.ScopeEventsRead, internal.ScopeEventsWrite}}, nil
+	}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret", CoverMaxBytes: 1 << 20}, Dependencies{
+		Events:    events,
+		Calendars: calendars,
+		Delegates: delegates,
+		Covers:    internal.NewCoverStore(&internal.LocalStorage{Dir: t.TempDir()}, covers, nil),
+		Auth:      hook,
+	})
+	require.NoError(t, err)
+	do := func(method, user string) int {
+		req := httptest.NewRequest(method, "/events/"+event.ID.String()+"/cover", strings.NewReader("not an image"))
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Seeing the event is not enough to change its cover
+	for _, user := range []string{"bob", "dave"} {
+		assert.Equal(t, http.StatusForbidden, do(http.MethodPut, user), user)
+		assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, user), user)
+		assert.Contains(t, covers.covers, event.ID, user)
+	}
+
+	// Editors get past the check: the upload is rejected as an image, and the delete goes through
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "carol"))
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "carol"))
+	assert.NotContains(t, covers.covers, event.ID)
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxDeclaredEvents bounds the events a declarative sync may declare
+const maxDeclaredEvents = 1000
+
+// maxClientKeyLength bounds client keys
+const maxClientKeyLength = 200
+
+// DeclarativeController syncs a calendar to a declared set of events, for clients
+// managing their events as code. Events are validated, screened and submitted for
+// review as by the event endpoints.
+type DeclarativeController struct {
+	events    *EventController
+	tx        internal.Transactor
+	calendars internal.CalendarRepositoryInterface
+	// orgs, when set, lets organization admins sync its calendars
+	orgs internal.OrganizationRepositoryInterface
+}
+
+// NewDeclarativeController creates a new declarative sync controller writing through
+// the repository of events in transactions of tx
+func NewDeclarativeController(events *EventController, tx internal.Transactor, calendars internal.CalendarRepositoryInterface, orgs internal.OrganizationRepositoryInterface) *DeclarativeController {
+	return &DeclarativeController{events: events, tx: tx, calendars: calendars, orgs: orgs}
+}
+
+// RegisterRoutes adds the declarative sync endpoint to router
+func (dc *DeclarativeController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/calendars/{id}/events:declarative", requireScope(internal.ScopeEventsWrite, dc.SyncEvents)).Methods("PUT")
+}
+
+// declaredEventInput is an event of the desired set, named by its client key
+type declaredEventInput struct {
+	ClientKey string `json:"client_key"`
+	createEventInput
+}
+
+type declarativeInput struct {
+	Events []declaredEventInput `json:"events"`
+}
+
+// SyncEvents handles PUT /calendars/{id}/events:declarative. The body is the full set
+// of events the calendar should have, each with a client_key. The events of the
+// calendar with a client key are created, updated and deleted in one transaction to
+// match it, and the plan is returned; with ?dry_run=true it is only computed. Events
+// without a client key are left alone.
+func (dc *DeclarativeController) SyncEvents(w http.ResponseWriter, r *http.Request) {
+	// Large sets write many events
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	var in declarativeInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	if len(in.Events) > maxDeclaredEvents {
+		httpError(w, r, http.StatusBadRequest, "at most %d events can be declared", maxDeclaredEvents)
+		return
+	}
+
+	calendar, err := dc.calendars.GetCalendar(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting calendar", "Failed to get calendar")
+		return
+	}
+	manages, err := managesCalendar(ctx, dc.orgs, r, *calendar)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting membership", "Failed to get calendar")
+		return
+	}
+	if !manages {
+		httpError(w, r, http.StatusForbidden, "only the calendar's owner can sync its events")
+		return
+	}
+
+	declared := make([]internal.EventDB, 0, len(in.Events))
+	for _, e := range in.Events {
+		key := strings.TrimSpace(e.ClientKey)
+		if key == "" || len(key) > maxClientKeyLength {
+			httpError(w, r, http.StatusBadRequest, "client_key is required and must be <= %d characters", maxClientKeyLength)
+			return
+		}
+		if e.CalendarID != nil && *e.CalendarID != id {
+			httpError(w, r, http.StatusBadRequest, "declared events belong to the calendar of the URL")
+			return
+		}
+		if !dc.events.sanitizeEventInput(r, &e.createEventInput) {
+			httpError(w, r, http.StatusBadRequest, "input rejected by security policy")
+			return
+		}
+		if msg := validateEventInput(&e.createEventInput); msg != "" {
+			httpError(w, r, http.StatusBadRequest, "%s: %s", key, msg)
+			return
+		}
+		if msg := internal.ValidateEventLimits(dc.events.cfg, internal.EventDB{StartTime: e.StartTime, EndTime: e.EndTime}); msg != "" {
+			httpError(w, r, http.StatusBadRequest, "%s: %s", key, msg)
+			return
+		}
+		if !dc.events.checkHolidays(ctx, w, r, e.createEventInput) {
+			return
+		}
+
+		event := internal.EventDB{
+			CalendarID:        &id,
+			ClientKey:         &key,
+			Title:             e.Title,
+			Description:       e.Description,
+			DescriptionFormat: e.DescriptionFormat,
+			StartTime:         e.StartTime.UTC(),
+			EndTime:           e.EndTime.UTC(),
+			Location:          e.Location,
+			Latitude:          e.Latitude,
+			Longitude:         e.Longitude,
+			PriceCents:        e.PriceCents,
+			Currency:          e.Currency,
+			TicketQuota:       e.TicketQuota,
+		}
+		dc.events.submitForReview(r, &event)
+		declared = append(declared, event)
+	}
+
+	var plan *internal.DeclarativePlan
+	err = dc.tx.InTx(ctx, func(ctx context.Context) error {
+		current, err := dc.events.eventRepo.GetEventsByCalendar(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !dryRun {
+			for i := range declared {
+				if declared[i].ID, err = dc.events.ids.NewID(); err != nil {
+					return err
+				}
+			}
+		}
+		if plan, err = internal.PlanDeclarativeSync(current, declared); err != nil || dryRun {
+			return err
+		}
+		return plan.Apply(ctx, dc.events.eventRepo)
+	})
+	if err != nil {
+		repositoryError(ctx, w, r, err, "syncing calendar events", "Failed to sync events")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taller_challenge/internal"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// declaredEvents lists the stored events of a calendar
+type declaredEvents struct {
+	storedEvents
+}
+
+func (f *declaredEvents) GetEventsByCalendar(ctx context.Context, id uuid.UUID) ([]internal.EventDB, error) {
+	var out []internal.EventDB
+	for _, e := range f.byID {
+		if e.CalendarID != nil && *e.CalendarID == id {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func TestDeclarativeSync(t *testing.T) {
+	calendar := internal.Calendar{ID: uuid.New(), Name: "Ops", OwnerID: "alice"}
+	calendars := &fakeCalendarRepository{calendars: map[uuid.UUID]internal.Calendar{calendar.ID: calendar}}
+	events := &declaredEvents{storedEvents{eventsByID{byID: map[uuid.UUID]internal.EventDB{}}}}
+	tx := &fakeTransactor{}
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		return &internal.Principal{UserID: r.Header.Get("X-User"), Scopes: []string{internal.ScopeEventsWrite}}, nil
+	}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{Events: events, Calendars: calendars, Tx: tx, Auth: hook})
+	require.NoError(t, err)
+	path := "/calendars/" + calendar.ID.String() + "/events:declarative"
+	sync := func(user, query, body string) (*httptest.ResponseRecorder, internal.DeclarativePlan) {
+		req := httptest.NewRequest(http.MethodPut, path+query, strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		var plan internal.DeclarativePlan
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &plan))
+		}
+		return rec, plan
+	}
+	desired := `{"events": [
+		{"client_key": "standup", "title": "Standup", "start_time": "2025-10-06T09:00:00Z", "end_time": "2025-10-06T09:15:00Z"},
+		{"client_key": "retro", "title": "Retro", "start_time": "2025-10-10T15:00:00Z", "end_time": "2025-10-10T16:00:00Z"}]}`
+
+	rec, _ := sync("bob", "", desired)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec, _ = sync("alice", "", `{"events": [{"title": "Standup", "start_time": "2025-10-06T09:00:00Z", "end_time": "2025-10-06T09:15:00Z"}]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// A dry run returns the plan without writing it
+	rec, plan := sync("alice", "?dry_run=true", desired)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, 2, plan.Create)
+	assert.False(t, plan.Applied)
+	assert.Empty(t, events.byID)
+
+	rec, plan = sync("alice", "", desired)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.True(t, plan.Applied)
+	require.Len(t, events.byID, 2)
+	assert.True(t, tx.committed)
+
+	// Applying the same set again changes nothing
+	rec, plan = sync("alice", "", desired)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, plan.Changes)
+	assert.Equal(t, 2, plan.Unchanged)
+
+	rec, plan = sync("alice", "", `{"events": [{"client_key": "standup", "title": "Daily standup", "start_time": "2025-10-06T09:00:00Z", "end_time": "2025-10-06T09:15:00Z"}]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, 1, plan.Update)
+	assert.Equal(t, 1, plan.Delete)
+	require.Len(t, events.byID, 1)
+	for _, e := range events.byID {
+		assert.Equal(t, "Daily standup", e.Title)
+		assert.Equal(t, "standup", *e.ClientKey)
+	}
+}
+
+func (f *declaredEvents) GetEventByClientKey(ctx context.Context, calendarID *uuid.UUID, key string) (*internal.EventDB, error) {
+	for _, e := range f.byID {
+		if e.ClientKey != nil && *e.ClientKey == key && ((e.CalendarID == nil && calendarID == nil) || (e.CalendarID != nil && calendarID != nil && *e.CalendarID == *calendarID)) {
+			return &e, nil
+		}
+	}
+	return nil, internal.ErrEventNotFound
+}
+
+func TestUpsertEventByClientKey(t *testing.T) {
+	events := &declaredEvents{storedEvents{eventsByID{byID: map[uuid.UUID]internal.EventDB{}}}}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{Events: events})
+	require.NoError(t, err)
+	put := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/events/"+key, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "admin-secret")
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := put("nightly-build", `{"title": "Nightly build", "start_time": "2025-10-06T02:00:00Z", "end_time": "2025-10-06T03:00:00Z"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.Len(t, events.byID, 1)
+	var created internal.EventDB
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.NotNil(t, created.ClientKey)
+	assert.Equal(t, "nightly-build", *created.ClientKey)
+
+	// The same key replaces the event
+	rec = put("nightly-build", `{"title": "Nightly build (slow)", "start_time": "2025-10-06T02:00:00Z", "end_time": "2025-10-06T04:00:00Z"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, events.byID, 1)
+	assert.Equal(t, "Nightly build (slow)", events.byID[created.ID].Title)
+
+	// Keys are scoped to calendars
+	calendar := uuid.New()
+	rec = put("nightly-build", `{"calendar_id": "`+calendar.String()+`", "title": "Nightly build", "start_time": "2025-10-06T02:00:00Z", "end_time": "2025-10-06T03:00:00Z"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Len(t, events.byID, 2)
+
+	assert.Equal(t, http.StatusBadRequest, put(strings.Repeat("k", maxClientKeyLength+1), `{"title": "Too long", "start_time": "2025-10-06T02:00:00Z", "end_time": "2025-10-06T03:00:00Z"}`).Code)
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// DelegateController handles the delegation grants of calendars, which let other
+// users write a calendar's events
+type DelegateController struct {
+	delegates internal.DelegateRepositoryInterface
+	calendars internal.CalendarRepositoryInterface
+	// orgs, when set, lets organization admins manage the grants of its calendars
+	orgs internal.OrganizationRepositoryInterface
+}
+
+// NewDelegateController creates a new delegate controller
+func NewDelegateController(delegates internal.DelegateRepositoryInterface, calendars internal.CalendarRepositoryInterface, orgs internal.OrganizationRepositoryInterface) *DelegateController {
+	return &DelegateController{delegates: delegates, calendars: calendars, orgs: orgs}
+}
+
+// RegisterRoutes adds the delegate endpoints to router
+func (dc *DelegateController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/calendars/{id}/delegates", requireScope(internal.ScopeEventsRead, dc.GetDelegates)).Methods("GET")
+	router.HandleFunc("/calendars/{id}/delegates/{userId}", requireScope(internal.ScopeEventsRead, dc.GetDelegate)).Methods("GET")
+	router.HandleFunc("/calendars/{id}/delegates/{userId}", requireScope(internal.ScopeEventsWrite, dc.SetDelegate)).Methods("PUT")
+	router.HandleFunc("/calendars/{id}/delegates/{userId}", requireScope(internal.ScopeEventsWrite, dc.DeleteDelegate)).Methods("DELETE")
+}
+
+type setDelegateInput struct {
+	Permissions []string `json:"permissions"`
+	// ExpiresAt ends the grant; it never expires when omitted
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// GetDelegates handles GET /calendars/{id}/delegates, for the calendar's managers
+func (dc *DelegateController) GetDelegates(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	calendar := dc.loadCalendar(ctx, w, r, false)
+	if calendar == nil {
+		return
+	}
+
+	delegates, err := dc.delegates.ListDelegates(ctx, calendar.ID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing delegates", "Failed to get delegates")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delegates)
+}
+
+// GetDelegate handles GET /calendars/{id}/delegates/{userId}, for the calendar's
+// managers and the delegate
+func (dc *DelegateController) GetDelegate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	userID := mux.Vars(r)["userId"]
+	calendar := dc.loadCalendar(ctx, w, r, userID == principalID(r))
+	if calendar == nil {
+		return
+	}
+
+	delegate, err := dc.delegates.GetDelegate(ctx, calendar.ID, userID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting delegate", "Failed to get delegate")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delegate)
+}
+
+// SetDelegate handles PUT /calendars/{id}/delegates/{userId}, granting the user the
+// listed permissions on the calendar's events or replacing an earlier grant
+func (dc *DelegateController) SetDelegate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in setDelegateInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	calendar := dc.loadCalendar(ctx, w, r, false)
+	if calendar == nil {
+		return
+	}
+	d := internal.Delegate{
+		CalendarID:  calendar.ID,
+		UserID:      mux.Vars(r)["userId"],
+		Permissions: in.Permissions,
+		ExpiresAt:   in.ExpiresAt,
+		GrantedBy:   principalID(r),
+	}
+	if msg := internal.ValidateDelegate(&d, time.Now()); msg != "" {
+		httpError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+	if d.UserID == calendar.OwnerID {
+		httpError(w, r, http.StatusBadRequest, "the calendar's owner cannot be a delegate")
+		return
+	}
+	if d.ExpiresAt != nil {
+		expires := d.ExpiresAt.UTC()
+		d.ExpiresAt = &expires
+	}
+
+	delegate, err := dc.delegates.SetDelegate(ctx, d)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "setting delegate", "Failed to set delegate")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delegate)
+}
+
+// DeleteDelegate handles DELETE /calendars/{id}/delegates/{userId}. Delegates may give
+// up their own grant.
+func (dc *DelegateController) DeleteDelegate(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	userID := mux.Vars(r)["userId"]
+	calendar := dc.loadCalendar(ctx, w, r, userID == principalID(r))
+	if calendar == nil {
+		return
+	}
+
+	if err := dc.delegates.DeleteDelegate(ctx, calendar.ID, userID); err != nil {
+		repositoryError(ctx, w, r, err, "deleting delegate", "Failed to delete delegate")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadCalendar returns the calendar of the request when the caller manages it, or
+// self is set because the request is about the caller's own grant. It returns nil
+// when the response has already been written.
+func (dc *DelegateController) loadCalendar(ctx context.Context, w http.ResponseWriter, r *http.Request, self bool) *internal.Calendar {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return nil
+	}
+
+	calendar, err := dc.calendars.GetCalendar(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting calendar", "Failed to get calendar")
+		return nil
+	}
+	if self {
+		return calendar
+	}
+	manages, err := managesCalendar(ctx, dc.orgs, r, *calendar)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting membership", "Failed to get calendar")
+		return nil
+	}
+	if !manages {
+		httpError(w, r, http.StatusForbidden, "only the calendar's owner can manage its delegates")
+		return nil
+	}
+	return calendar
+}
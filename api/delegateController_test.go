@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storedEvents keeps the events written through it
+type storedEvents struct {
+	eventsByID
+}
+
+func (f *storedEvents) CreateEvent(ctx context.Context, e internal.EventDB) (*internal.EventDB, error) {
+	f.byID[e.ID] = e
+	return &e, nil
+}
+
+func (f *storedEvents) UpdateEvent(ctx context.Context, e internal.EventDB) (*internal.EventDB, error) {
+	if _, ok := f.byID[e.ID]; !ok {
+		return nil, internal.ErrEventNotFound
+	}
+	f.byID[e.ID] = e
+	return &e, nil
+}
+
+func (f *storedEvents) DeleteEvent(ctx context.Context, id uuid.UUID) error {
+	if _, ok := f.byID[id]; !ok {
+		return internal.ErrEventNotFound
+	}
+	delete(f.byID, id)
+	return nil
+}
+
+// fakeDelegateRepository keeps delegation grants in memory
+type fakeDelegateRepository struct {
+	grants map[string]internal.Delegate
+}
+
+func (f *fakeDelegateRepository) SetDelegate(ctx context.Context, d internal.Delegate) (*internal.Delegate, error) {
+	f.grants[d.CalendarID.String()+"/"+d.UserID] = d
+	return &d, nil
+}
+
+func (f *fakeDelegateRepository) GetDelegate(ctx context.Context, calendarID uuid.UUID, userID string) (*internal.Delegate, error) {
+	d, ok := f.grants[calendarID.String()+"/"+userID]
+	if !ok {
+		return nil, internal.ErrDelegateNotFound
+	}
+	return &d, nil
+}
+
+func (f *fakeDelegateRepository) ListDelegates(ctx context.Context, calendarID uuid.UUID) ([]internal.Delegate, error) {
+	out := []internal.Delegate{}
+	for _, d := range f.grants {
+		if d.CalendarID == calendarID {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeDelegateRepository) DeleteDelegate(ctx context.Context, calendarID uuid.UUID, userID string) error {
+	if _, ok := f.grants[calendarID.String()+"/"+userID]; !ok {
+		return internal.ErrDelegateNotFound
+	}
+	delete(f.grants, calendarID.String()+"/"+userID)
+	return nil
+}
+
+func TestCalendarDelegates(t *testing.T) {
+	calendar := internal.Calendar{ID: uuid.New(), Name: "Alice", OwnerID: "alice"}
+	calendars := &fakeCalendarRepository{calendars: map[uuid.UUID]internal.Calendar{calendar.ID: calendar}}
+	delegates := &fakeDelegateRepository{grants: map[string]internal.Delegate{}}
+	events := &storedEvents{eventsByID{byID: map[uuid.UUID]internal.EventDB{}}}
+	hooks := internal.NewEventHooks()
+	internal.NewCalendarAccess(calendars, nil, delegates, events).Register(hooks)
+
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		return &internal.Principal{UserID: r.Header.Get("X-User"), Scopes: []string{internal.ScopeEventsRead, internal.ScopeEventsWrite}}, nil
+	}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{
+		Events:    internal.NewHookedEventRepository(events, hooks),
+		Calendars: calendars,
+		Delegates: delegates,
+		Auth:      hook,
+	})
+	require.NoError(t, err)
+	do := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	base := "/calendars/" + calendar.ID.String() + "/delegates"
+	event := `{"title": "Standup", "start_time": "2025-09-15T09:00:00Z", "end_time": "2025-09-15T09:15:00Z", "calendar_id": "` + calendar.ID.String() + `"}`
+	id := uuid.New()
+	events.byID[id] = internal.EventDB{ID: id, CalendarID: &calendar.ID, Title: "Planning"}
+
+	// Without a grant Bob cannot write Alice's calendar, nor grant himself access
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/events", "bob", event).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, base+"/bob", "bob", `{"permissions": ["create"]}`).Code)
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/events", "alice", event).Code)
+
+	// A grant allows only what it lists
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, base+"/bob", "alice", `{"permissions": ["share"]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, base+"/alice", "alice", `{"permissions": ["create"]}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPut, base+"/bob", "alice", `{"permissions": ["create", "create"]}`).Code)
+	assert.Equal(t, []string{internal.DelegateCreate}, delegates.grants[calendar.ID.String()+"/bob"].Permissions)
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/events", "bob", event).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/events/"+id.String(), "bob", event).Code)
+
+	// Grants are replaced and expire
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, base+"/bob", "alice", `{"permissions": ["edit"], "expires_at": "2020-01-01T00:00:00Z"}`).Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPut, base+"/bob", "alice", `{"permissions": ["edit"], "expires_at": "`+expires+`"}`).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/events/"+id.String(), "bob", event).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/events/"+id.String(), "bob", "").Code)
+
+	// Delegates see and give up their own grant; only the owner lists them
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, base+"/bob", "bob", "").Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, base, "bob", "").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, base, "alice", "").Code)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, base+"/bob", "bob", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, base+"/bob", "alice", "").Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/events/"+id.String(), "bob", event).Code)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxDeviceTokenLength bounds registration tokens; FCM and APNs tokens are far shorter
+const maxDeviceTokenLength = 4096
+
+// DeviceController handles the caller's mobile devices registered for push
+type DeviceController struct {
+	devices   internal.DeviceTokenRepositoryInterface
+	providers internal.PushProviders
+}
+
+// NewDeviceController creates a new device controller accepting the platforms of providers
+func NewDeviceController(devices internal.DeviceTokenRepositoryInterface, providers internal.PushProviders) *DeviceController {
+	return &DeviceController{devices: devices, providers: providers}
+}
+
+// RegisterRoutes adds the device endpoints to router
+func (dc *DeviceController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/push/devices", requireScope(internal.ScopeEventsRead, dc.RegisterDevice)).Methods("POST")
+	router.HandleFunc("/push/devices", requireScope(internal.ScopeEventsRead, dc.GetDevices)).Methods("GET")
+	router.HandleFunc("/push/devices/{id}", requireScope(internal.ScopeEventsRead, dc.UnregisterDevice)).Methods("DELETE")
+}
+
+type registerDeviceInput struct {
+	Platform string `json:"platform"`
+	Token    string `json:"token"`
+}
+
+// RegisterDevice handles POST /push/devices
+func (dc *DeviceController) RegisterDevice(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in registerDeviceInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	if _, ok := dc.providers[in.Platform]; !ok {
+		httpError(w, r, http.StatusBadRequest, "platform %q is not configured", in.Platform)
+		return
+	}
+	token := strings.TrimSpace(in.Token)
+	if token == "" || len(token) > maxDeviceTokenLength {
+		httpError(w, r, http.StatusBadRequest, "token is required and must be <= %d characters", maxDeviceTokenLength)
+		return
+	}
+
+	device, err := dc.devices.SaveDeviceToken(ctx, internal.DeviceToken{
+		ID:       uuid.New(),
+		UserID:   principalID(r),
+		Platform: in.Platform,
+		Token:    token,
+	})
+	if err != nil {
+		repositoryError(ctx, w, r, err, "saving device token", "Failed to register device")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(device)
+}
+
+// GetDevices handles GET /push/devices
+func (dc *DeviceController) GetDevices(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	devices, err := dc.devices.ListDeviceTokens(ctx, principalID(r))
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing device tokens", "Failed to get devices")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(devices)
+}
+
+// UnregisterDevice handles DELETE /push/devices/{id}
+func (dc *DeviceController) UnregisterDevice(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	if err := dc.devices.DeleteDeviceToken(ctx, principalID(r), id); err != nil {
+		repositoryError(ctx, w, r, err, "deleting device token", "Failed to unregister device")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// DigestController handles the caller's weekly digest subscription
+type DigestController struct {
+	digestRepo internal.DigestRepositoryInterface
+}
+
+// NewDigestController creates a new digest controller
+func NewDigestController(digestRepo internal.DigestRepositoryInterface) *DigestController {
+	return &DigestController{digestRepo: digestRepo}
+}
+
+// RegisterRoutes adds the digest endpoints to router
+func (dc *DigestController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/digest/subscription", requireScope(internal.ScopeEventsRead, dc.GetSubscription)).Methods("GET")
+	router.HandleFunc("/digest/subscription", requireScope(internal.ScopeEventsRead, dc.PutSubscription)).Methods("PUT")
+	router.HandleFunc("/digest/subscription", requireScope(internal.ScopeEventsRead, dc.DeleteSubscription)).Methods("DELETE")
+}
+
+type digestSubscriptionInput struct {
+	Email    string `json:"email"`
+	Timezone string `json:"timezone"`
+	// Language defaults to the request's Accept-Language
+	Language string `json:"language"`
+	// Enabled defaults to true; false pauses the digest without losing the settings
+	Enabled *bool `json:"enabled"`
+}
+
+// GetSubscription handles GET /digest/subscription
+func (dc *DigestController) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := caller(w, r)
+	if p == nil {
+		return
+	}
+
+	sub, err := dc.digestRepo.GetDigestSubscription(ctx, p.UserID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting digest subscription", "Failed to get digest subscription")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sub)
+}
+
+// PutSubscription handles PUT /digest/subscription
+func (dc *DigestController) PutSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := caller(w, r)
+	if p == nil {
+		return
+	}
+
+	var in digestSubscriptionInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	addr, err := mail.ParseAddress(in.Email)
+	if err != nil || addr.Name != "" {
+		httpError(w, r, http.StatusBadRequest, "email must be a valid address")
+		return
+	}
+	if in.Timezone == "" {
+		in.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(in.Timezone); err != nil {
+		httpError(w, r, http.StatusBadRequest, "unknown timezone %q", in.Timezone)
+		return
+	}
+	if in.Language == "" {
+		in.Language = language(r)
+	}
+	in.Language = internal.NegotiateLanguage(in.Language)
+
+	sub, err := dc.digestRepo.SaveDigestSubscription(ctx, internal.DigestSubscription{
+		UserID:   p.UserID,
+		Email:    addr.Address,
+		Timezone: in.Timezone,
+		Language: in.Language,
+		Enabled:  in.Enabled == nil || *in.Enabled,
+	})
+	if err != nil {
+		repositoryError(ctx, w, r, err, "saving digest subscription", "Failed to save digest subscription")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sub)
+}
+
+// DeleteSubscription handles DELETE /digest/subscription
+func (dc *DigestController) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := caller(w, r)
+	if p == nil {
+		return
+	}
+
+	if err := dc.digestRepo.DeleteDigestSubscription(ctx, p.UserID); err != nil {
+		repositoryError(ctx, w, r, err, "deleting digest subscription", "Failed to delete digest subscription")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
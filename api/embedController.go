@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// embedMaxAge is how long browsers may cache a rendered widget
+const embedMaxAge = 5 * time.Minute
+
+// embedScript is served at /embed.js. It replaces its own script tag with an iframe
+// showing the widget, and grows the iframe to the height the widget reports.
+const embedScript = `(function () {
+  var script = document.currentScript;
+  if (!script || !script.dataset.calendar) return;
+  var origin = new URL(script.src).origin;
+  var params = new URLSearchParams({token: script.dataset.token || "", view: script.dataset.view || "month"});
+  try { params.set("tz", Intl.DateTimeFormat().resolvedOptions().timeZone); } catch (e) {}
+  var frame = document.createElement("iframe");
+  frame.src = origin + "/embed/calendar/" + encodeURIComponent(script.dataset.calendar) + "?" + params;
+  frame.title = script.dataset.title || "Calendar";
+  frame.loading = "lazy";
+  frame.style.cssText = "width:100%;min-height:200px;border:0";
+  script.parentNode.insertBefore(frame, script.nextSibling);
+  window.addEventListener("message", function (e) {
+    if (e.source === frame.contentWindow && e.origin === origin && e.data && e.data.type === "calendar-widget-height") {
+      frame.style.height = e.data.height + "px";
+    }
+  });
+})();
+`
+
+// EmbedController serves a calendar widget external sites can embed with one script
+// tag. Like feeds, widgets are authorized by the calendar's signed feed token.
+type EmbedController struct {
+	calendars internal.CalendarRepositoryInterface
+	events    internal.EventRepositoryInterface
+	signer    *internal.FeedSigner
+	publicURL string
+	// eventPages links events to their public pages
+	eventPages bool
+}
+
+// NewEmbedController creates a new embed controller. When eventPages is set, widget
+// events link to their pages under publicURL.
+func NewEmbedController(calendars internal.CalendarRepositoryInterface, events internal.EventRepositoryInterface, signer *internal.FeedSigner, publicURL string, eventPages bool) *EmbedController {
+	return &EmbedController{calendars: calendars, events: events, signer: signer, publicURL: publicURL, eventPages: eventPages}
+}
+
+// RegisterRoutes adds the embed endpoints to router; both are public paths
+func (ec *EmbedController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/embed.js", ec.GetScript).Methods("GET")
+	router.HandleFunc("/embed/calendar/{id}", ec.GetCalendarWidget).Methods("GET")
+}
+
+// embedSnippet is the HTML a site pastes to show a calendar's widget
+func embedSnippet(base string, c internal.Calendar, token string) string {
+	return fmt.Sprintf(`<script src="%s/embed.js" data-calendar="%s" data-token="%s" data-view="month"></script>`, base, c.ID, token)
+}
+
+// GetScript handles GET /embed.js
+func (ec *EmbedController) GetScript(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write([]byte(embedScript))
+}
+
+// GetCalendarWidget handles GET /embed/calendar/{id}?token=&view=month|agenda&month=YYYY-MM&tz=.
+// A wrong token gets the same 404 as a missing calendar.
+func (ec *EmbedController) GetCalendarWidget(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	query := r.URL.Query()
+	view := query.Get("view")
+	if view == "" {
+		view = internal.WidgetViewMonth
+	}
+	if view != internal.WidgetViewMonth && view != internal.WidgetViewAgenda {
+		httpError(w, r, http.StatusBadRequest, "view must be month or agenda")
+		return
+	}
+	loc := displayLocation(r)
+	now := time.Now()
+	month := now.In(loc)
+	if v := query.Get("month"); v != "" {
+		m, err := time.ParseInLocation("2006-01", v, loc)
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "month must be formatted as YYYY-MM")
+			return
+		}
+		month = m
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusNotFound, "Calendar not found")
+		return
+	}
+	calendar, err := ec.calendars.GetCalendar(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting calendar", "Failed to get calendar")
+		return
+	}
+	token := query.Get("token")
+	if !ec.signer.Verify(*calendar, token) {
+		log.Printf("Security: rejected widget request for calendar %s from %s: invalid token", id, r.RemoteAddr)
+		httpError(w, r, http.StatusNotFound, "Calendar not found")
+		return
+	}
+
+	events, err := ec.events.GetEventsByCalendar(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting calendar events", "Failed to get events")
+		return
+	}
+
+	lang := language(r)
+	opts := internal.WidgetOptions{
+		View:     view,
+		Month:    month,
+		Location: loc,
+		Lang:     lang,
+		Now:      now,
+		MonthURL: func(m time.Time) string {
+			q := url.Values{"token": {token}, "view": {internal.WidgetViewMonth}, "month": {m.Format("2006-01")}, "tz": {loc.String()}}
+			return r.URL.Path + "?" + q.Encode()
+		},
+	}
+	if ec.eventPages {
+		base := requestBaseURL(r, ec.publicURL)
+		opts.EventURL = func(e internal.EventDB) string { return base + "/e/" + e.ID.String() }
+	}
+
+	var page bytes.Buffer
+	if err := internal.RenderCalendarWidget(&page, *calendar, events, opts); err != nil {
+		log.Printf("Error rendering widget of calendar %s: %v", id, err)
+		httpError(w, r, http.StatusInternalServerError, "Failed to get events")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Language", lang)
+	w.Header().Set("Cache-Control", cacheControl("private", embedMaxAge))
+	w.Write(page.Bytes())
+}
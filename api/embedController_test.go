@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalendarWidget(t *testing.T) {
+	calendar := internal.Calendar{ID: uuid.New(), Name: "Team", OwnerID: "alice", FeedVersion: 1}
+	calendars := &fakeCalendarRepository{calendars: map[uuid.UUID]internal.Calendar{calendar.ID: calendar}}
+	start := time.Now().UTC().Add(48 * time.Hour).Truncate(time.Hour)
+	events := &calendarEvents{fakeEventRepository{events: []internal.EventDB{
+		{ID: uuid.New(), Title: "Retro <b>", StartTime: start, EndTime: start.Add(time.Hour)},
+		{ID: uuid.New(), Title: "Draft", Status: internal.EventStatusPending, StartTime: start, EndTime: start.Add(time.Hour)},
+		{ID: uuid.New(), Title: "Last year", StartTime: start.AddDate(-1, 0, 0), EndTime: start.AddDate(-1, 0, 0).Add(time.Hour)},
+	}}}
+	cfg := internal.Config{APIKey: "admin-secret", FeedSigningKey: "feed-secret", EventPages: true, PublicURL: "https://cal.example.com"}
+	srv, err := NewServer(cfg, Dependencies{Events: events, Calendars: calendars})
+	require.NoError(t, err)
+	token := internal.NewFeedSigner(cfg.FeedSigningKey).Token(calendar)
+
+	get := func(path string, query url.Values) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path+"?"+query.Encode(), nil))
+		return rec
+	}
+	path := "/embed/calendar/" + calendar.ID.String()
+
+	rec := get("/embed.js", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "/embed/calendar/")
+
+	rec = get(path, url.Values{"token": {token}, "month": {start.Format("2006-01")}})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	page := rec.Body.String()
+	assert.Contains(t, page, start.Format("January")+" "+start.Format("2006"))
+	assert.Contains(t, page, "Retro &lt;b&gt;")
+	assert.Contains(t, page, `href="https://cal.example.com/e/`+events.events[0].ID.String()+`"`)
+	assert.NotContains(t, page, "Draft")
+	assert.Contains(t, page, "month="+start.AddDate(0, 1, 0).Format("2006-01"))
+
+	rec = get(path, url.Values{"token": {token}, "view": {"agenda"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Retro")
+	assert.NotContains(t, rec.Body.String(), "Last year", "the agenda only lists upcoming events")
+
+	assert.Equal(t, http.StatusNotFound, get(path, url.Values{"token": {"forged"}}).Code)
+	assert.Equal(t, http.StatusBadRequest, get(path, url.Values{"token": {token}, "view": {"week"}}).Code)
+	assert.Equal(t, http.StatusBadRequest, get(path, url.Values{"token": {token}, "month": {"Sept"}}).Code)
+
+	// The feed link comes with the snippet embedding the widget
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/calendars/"+calendar.ID.String()+"/feed", nil)
+	req.Header.Set("X-API-Key", "admin-secret")
+	srv.Router.ServeHTTP(rec, req)
+	assert.True(t, strings.Contains(rec.Body.String(), `data-token=\"`+token+`\"`), rec.Body.String())
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"taller_challenge/internal"
+)
+
+// notFoundMessages are the 404 messages of the repositories' not-found errors
+var notFoundMessages = []struct {
+	err error
+	msg string
+}{
+	{internal.ErrEventNotFound, "Event not found"},
+	{internal.ErrCalendarNotFound, "Calendar not found"},
+	{internal.ErrSnapshotNotFound, "Snapshot not found"},
+	{internal.ErrOperationNotFound, "Operation not found"},
+	{internal.ErrScheduleNotFound, "Schedule not found"},
+	{internal.ErrTokenNotFound, "Token not found"},
+	{internal.ErrDigestSubscriptionNotFound, "Not subscribed to the digest"},
+	{internal.ErrUnknownCountry, "No holiday data for country"},
+	{internal.ErrPolicyRuleNotFound, "Policy rule not found"},
+	{internal.ErrCommentNotFound, "Comment not found"},
+	{internal.ErrReminderNotFound, "Reminder not found"},
+	{internal.ErrPushSubscriptionNotFound, "Push subscription not found"},
+	{internal.ErrDeviceTokenNotFound, "Device not found"},
+	{internal.ErrCoverNotFound, "Cover not found"},
+	{internal.ErrResourceNotFound, "Resource not found"},
+	{internal.ErrReservationNotFound, "Reservation not found"},
+	{internal.ErrOrganizationNotFound, "Organization not found"},
+	{internal.ErrMemberNotFound, "Member not found"},
+	{internal.ErrInvitationNotFound, "Invitation not found"},
+	{internal.ErrDelegateNotFound, "Delegate not found"},
+	{internal.ErrWebhookNotFound, "Webhook not found"},
+	{internal.ErrDeliveryNotFound, "Delivery not found"},
+	{internal.ErrIngestSourceNotFound, "Ingest source not found"},
+	{internal.ErrTwoFactorNotEnrolled, "Two-factor authentication is not enabled"},
+	{internal.ErrExportNotFound, "Export not found"},
+}
+
+// repositoryError writes the response for an error returned by a repository, with the
+// status of its domain kind: 404, 400 for validation, 409 for conflicts, 403 when the
+// caller may not make the change and 408 when the request ran out of time. Database
+// errors no repository mapped get the kind of their code, so a violated foreign key is a
+// 422 and an unreachable or overloaded database a 503 with Retry-After. Other errors are
+// logged as "Error <action>" and answered with a 500 and fallback, so database failures
+// are never reported as a missing record.
+func repositoryError(ctx context.Context, w http.ResponseWriter, r *http.Request, err error, action, fallback string) {
+	kind := internal.KindOf(err)
+	if kind == nil && ctx.Err() == context.DeadlineExceeded {
+		// The driver reports a cancelled statement without wrapping the deadline
+		kind = internal.ErrTimeout
+	}
+
+	switch kind {
+	case internal.ErrNotFound:
+		for _, nf := range notFoundMessages {
+			if errors.Is(err, nf.err) {
+				httpError(w, r, http.StatusNotFound, nf.msg)
+				return
+			}
+		}
+		httpError(w, r, http.StatusNotFound, "Not found")
+	case internal.ErrValidation:
+		var ce *internal.ConstraintError
+		if errors.As(err, &ce) {
+			constraintError(w, r, ce)
+			return
+		}
+		httpError(w, r, http.StatusBadRequest, domainMessageOr(err, "Invalid value"))
+	case internal.ErrConflict:
+		var de *internal.DependentsError
+		if errors.As(err, &de) {
+			httpError(w, r, http.StatusConflict, "Cannot delete the %s while it has %s", de.Kind, de.List())
+			return
+		}
+		httpError(w, r, http.StatusConflict, domainMessageOr(err, "Conflicts with an existing record"))
+	case internal.ErrForbidden:
+		httpError(w, r, http.StatusForbidden, internal.DomainMessage(err))
+	case internal.ErrUnprocessable:
+		log.Printf("Error %s: %v", action, err)
+		httpError(w, r, http.StatusUnprocessableEntity, domainMessageOr(err, "Refers to a missing record or breaks a data rule"))
+	case internal.ErrUnavailable:
+		log.Printf("Error %s: %v", action, err)
+		w.Header().Set("Retry-After", "1")
+		httpError(w, r, http.StatusServiceUnavailable, "Service temporarily unavailable, retry shortly")
+	case internal.ErrTimeout:
+		log.Printf("Error %s: %v", action, err)
+		httpError(w, r, http.StatusRequestTimeout, "Request timeout")
+	default:
+		log.Printf("Error %s: %v", action, err)
+		httpError(w, r, http.StatusInternalServerError, fallback)
+	}
+}
+
+// constraintError answers 400 with every violation of a write, one per line
+func constraintError(w http.ResponseWriter, r *http.Request, ce *internal.ConstraintError) {
+	lang := language(r)
+	lines := make([]string, len(ce.Violations))
+	for i, v := range ce.Violations {
+		lines[i] = v.Constraint + ": " + internal.Translate(lang, v.Message)
+	}
+	w.Header().Set("Content-Language", lang)
+	http.Error(w, strings.Join(lines, "\n"), http.StatusBadRequest)
+}
+
+// paramError answers 400 for a malformed path or query parameter, naming it
+func paramError(w http.ResponseWriter, r *http.Request, err error) {
+	var pe *internal.ParamError
+	if !errors.As(err, &pe) {
+		httpError(w, r, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	httpError(w, r, http.StatusBadRequest, "invalid %s %q: expected %s", pe.Param, pe.Value, internal.Translate(language(r), pe.Want))
+}
+
+// domainMessageOr returns the message of the domain error err wraps, or fallback for
+// database errors classified by their code, which carry none meant for clients
+func domainMessageOr(err error, fallback string) string {
+	if msg := internal.DomainMessage(err); msg != "" {
+		return msg
+	}
+	return fallback
+}
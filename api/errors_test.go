@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taller_challenge/internal"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepositoryError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{"not found", fmt.Errorf("lookup: %w", internal.ErrEventNotFound), http.StatusNotFound, "Event not found"},
+		{"not found with its own message", internal.ErrDigestSubscriptionNotFound, http.StatusNotFound, "Not subscribed to the digest"},
+		{"validation", fmt.Errorf("failed to create event: %w", internal.ErrUnknownCalendar), http.StatusBadRequest, "calendar not found"},
+		{"every violated constraint", fmt.Errorf("failed to create event: %w", &internal.ConstraintError{Violations: []internal.Violation{
+			{Constraint: "duration", Message: "events must last at least 15m"},
+			{Constraint: "conflicts", Message: "and more overlapping events"},
+		}}), http.StatusBadRequest, "duration: events must last at least 15m\nconflicts: and more overlapping events"},
+		{"timeout", fmt.Errorf("failed to query events: %w", context.DeadlineExceeded), http.StatusRequestTimeout, "Request timeout"},
+		{"database failure is not a missing record", errors.New("pq: connection refused"), http.StatusInternalServerError, "Failed to get event"},
+		{"forbidden by a delete policy", &internal.DependentsError{Kind: "calendar", Dependents: []internal.Dependent{
+			{Relation: "calendar.webhooks", Count: 1},
+			{Relation: "event.tickets", Count: 4},
+		}}, http.StatusConflict, "Cannot delete the calendar while it has webhooks (1), event tickets (4)"},
+		{"unique violation", fmt.Errorf("failed to insert: %w", &pq.Error{Code: "23505"}), http.StatusConflict, "Conflicts with an existing record"},
+		{"foreign key violation", &pq.Error{Code: "23503"}, http.StatusUnprocessableEntity, "Refers to a missing record or breaks a data rule"},
+		{"serialization failure", &pq.Error{Code: "40001"}, http.StatusServiceUnavailable, "Service temporarily unavailable, retry shortly"},
+		{"connection failure", fmt.Errorf("failed to query events: %w", driver.ErrBadConn), http.StatusServiceUnavailable, "Service temporarily unavailable, retry shortly"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/events/x", nil)
+
+			repositoryError(context.Background(), rec, req, tt.err, "getting event", "Failed to get event")
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantBody, strings.TrimSpace(rec.Body.String()))
+		})
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	assert.Equal(t, internal.ErrNotFound, internal.KindOf(internal.ErrCalendarNotFound))
+	assert.Equal(t, internal.ErrValidation, internal.KindOf(internal.ErrUnknownCalendar))
+	assert.Nil(t, internal.KindOf(errors.New("boom")))
+	assert.Equal(t, internal.ErrConflict, internal.KindOf(&pq.Error{Code: "23P01"}))
+	assert.Equal(t, internal.ErrValidation, internal.KindOf(&pq.Error{Code: "22003"}))
+	assert.Equal(t, internal.ErrTimeout, internal.KindOf(&pq.Error{Code: "57014"}))
+	assert.Equal(t, internal.ErrUnavailable, internal.KindOf(&pq.Error{Code: "57P01"}))
+	assert.Equal(t, internal.ErrUnavailable, internal.KindOf(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.Nil(t, internal.KindOf(&pq.Error{Code: "42P01"}), "bugs such as a missing table stay 500s")
+	assert.False(t, errors.Is(internal.ErrUnknownCalendar, internal.ErrCalendarNotFound), "sentinels stay distinct")
+}
@@ -2,15 +2,15 @@ package api
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"os/signal"
 	"strings"
-	"syscall"
+	"sync/atomic"
 	"taller_challenge/internal"
 	"time"
 
@@ -21,20 +21,53 @@ import (
 // EventController handles HTTP requests for events
 type EventController struct {
 	eventRepo internal.EventRepositoryInterface
+	cfg       internal.Config
+	holidays  internal.HolidayProvider
+	weather   internal.WeatherProvider
+	ids       internal.IDGenerator
+	throttle  *internal.UpdateThrottle
+	redactor  *internal.EventRedactor
+	// preferences, when set, hold the time zone quick-add reads dates in by default
+	preferences internal.PreferencesRepositoryInterface
+	// search, when set, serves /events/search instead of eventRepo
+	search internal.EventSearcher
 }
 
-// NewEventController creates a new event controller
-func NewEventController(eventRepo internal.EventRepositoryInterface) *EventController {
+// NewEventController creates a new event controller.
+// weather may be nil, in which case ?include=weather is ignored, and redactor may be
+// nil, in which case events of busy calendars are shown in full.
+func NewEventController(eventRepo internal.EventRepositoryInterface, cfg internal.Config, holidays internal.HolidayProvider, weather internal.WeatherProvider, redactor *internal.EventRedactor) *EventController {
 	return &EventController{
 		eventRepo: eventRepo,
+		cfg:       cfg,
+		holidays:  holidays,
+		weather:   weather,
+		redactor:  redactor,
+		ids:       internal.NewIDGenerator(cfg.IDStrategy),
+		throttle:  internal.NewUpdateThrottle(cfg.EventUpdateLimit),
 	}
 }
 
 type createEventInput struct {
-	Title       string    `json:"title"`
-	Description *string   `json:"description"`
-	StartTime   time.Time `json:"start_time"`
-	EndTime     time.Time `json:"end_time"`
+	Title             string    `json:"title"`
+	Description       *string   `json:"description"`
+	DescriptionFormat string    `json:"description_format"`
+	StartTime         time.Time `json:"start_time"`
+	EndTime           time.Time `json:"end_time"`
+	// DurationMinutes may replace EndTime, which is then computed from StartTime
+	DurationMinutes *int     `json:"duration_minutes"`
+	Location        *string  `json:"location"`
+	Latitude        *float64 `json:"latitude"`
+	Longitude       *float64 `json:"longitude"`
+	// CalendarID is optional; events without it belong to the default calendar
+	CalendarID *uuid.UUID `json:"calendar_id"`
+	// PriceCents and Currency price a ticket; TicketQuota limits the tickets
+	PriceCents  *int64  `json:"price_cents"`
+	Currency    *string `json:"currency"`
+	TicketQuota *int    `json:"ticket_quota"`
+	// ExternalID and Source link the event to the system it is synced from
+	ExternalID *string `json:"external_id"`
+	Source     *string `json:"source"`
 }
 
 // CreateEvent handles POST /events
@@ -46,157 +79,442 @@ func (ec *EventController) CreateEvent(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
 	if err := dec.Decode(&in); err != nil {
-		http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
 		return
 	}
 
-	if strings.TrimSpace(in.Title) == "" {
-		http.Error(w, "title is required", http.StatusBadRequest)
+	if !ec.sanitizeEventInput(r, &in) {
+		httpError(w, r, http.StatusBadRequest, "input rejected by security policy")
 		return
 	}
-	if len(in.Title) > 100 {
-		http.Error(w, "title must be <= 100 characters", http.StatusBadRequest)
+	if msg := validateEventInput(&in); msg != "" {
+		httpError(w, r, http.StatusBadRequest, msg)
 		return
 	}
-	if in.StartTime.IsZero() || in.EndTime.IsZero() {
-		http.Error(w, "start_time and end_time are required (RFC3339)", http.StatusBadRequest)
+
+	ec.createEvent(ctx, w, r, in, nil)
+}
+
+// maxDurationMinutes bounds duration_minutes to a year
+const maxDurationMinutes = 366 * 24 * 60
+
+// validateEventInput sets the end time of an input with duration_minutes and returns a
+// client-facing message when the input is invalid
+func validateEventInput(in *createEventInput) string {
+	if in.DurationMinutes != nil {
+		if !in.EndTime.IsZero() {
+			return "end_time and duration_minutes are mutually exclusive"
+		}
+		if *in.DurationMinutes <= 0 || *in.DurationMinutes > maxDurationMinutes {
+			return "duration_minutes must be positive and at most a year"
+		}
+		if !in.StartTime.IsZero() {
+			in.EndTime = in.StartTime.Add(time.Duration(*in.DurationMinutes) * time.Minute)
+		}
+	}
+	return internal.ValidateEvent(internal.EventDB{
+		Title:             in.Title,
+		DescriptionFormat: in.DescriptionFormat,
+		StartTime:         in.StartTime,
+		EndTime:           in.EndTime,
+		Latitude:          in.Latitude,
+		Longitude:         in.Longitude,
+		PriceCents:        in.PriceCents,
+		Currency:          in.Currency,
+		TicketQuota:       in.TicketQuota,
+		ExternalID:        in.ExternalID,
+		Source:            in.Source,
+	})
+}
+
+// createEvent persists a validated input and writes the 201 response
+func (ec *EventController) createEvent(ctx context.Context, w http.ResponseWriter, r *http.Request, in createEventInput, clientKey *string) {
+	if msg := internal.ValidateNewEvent(ec.cfg, internal.EventDB{StartTime: in.StartTime, EndTime: in.EndTime}, time.Now()); msg != "" {
+		httpError(w, r, http.StatusBadRequest, msg)
 		return
 	}
-	if !in.StartTime.Before(in.EndTime) {
-		http.Error(w, "start_time must be before end_time", http.StatusBadRequest)
+	if !ec.checkHolidays(ctx, w, r, in) {
 		return
 	}
 
-	id := uuid.New()
+	id, err := ec.ids.NewID()
+	if err != nil {
+		log.Printf("Error generating event ID: %v", err)
+		httpError(w, r, http.StatusInternalServerError, "Failed to create event")
+		return
+	}
 	createdAt := time.Now().UTC()
 
 	event := internal.EventDB{
-		ID:          id,
-		Title:       in.Title,
-		Description: in.Description,
-		StartTime:   in.StartTime.UTC(),
-		EndTime:     in.EndTime.UTC(),
-		CreatedAt:   createdAt,
-		UpdatedAt:   createdAt,
+		ID:                id,
+		CalendarID:        in.CalendarID,
+		Title:             in.Title,
+		Description:       in.Description,
+		DescriptionFormat: in.DescriptionFormat,
+		StartTime:         in.StartTime.UTC(),
+		EndTime:           in.EndTime.UTC(),
+		Location:          in.Location,
+		Latitude:          in.Latitude,
+		Longitude:         in.Longitude,
+		PriceCents:        in.PriceCents,
+		Currency:          in.Currency,
+		TicketQuota:       in.TicketQuota,
+		ExternalID:        in.ExternalID,
+		Source:            in.Source,
+		ClientKey:         clientKey,
+		CreatedAt:         createdAt,
+		UpdatedAt:         createdAt,
 	}
+	ec.submitForReview(r, &event)
 
 	createdEvent, err := ec.eventRepo.CreateEvent(ctx, event)
 	if err != nil {
-		log.Printf("Error creating event: %v", err)
-		if ctx.Err() == context.DeadlineExceeded {
-			http.Error(w, "Request timeout", http.StatusRequestTimeout)
-			return
-		}
-		http.Error(w, "Failed to create event", http.StatusInternalServerError)
+		repositoryError(ctx, w, r, err, "creating event", "Failed to create event")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(createdEvent)
+	json.NewEncoder(w).Encode(ec.writtenEvent(ctx, r, *createdEvent))
 }
 
-// GetEvents handles GET /events
+// GetEvents handles GET /events, or GET /events?external_id=... for synced events.
+// ?view=summary lists EventSummary values instead of whole events.
 func (ec *EventController) GetEvents(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	events, err := ec.eventRepo.GetEvents(ctx)
-	if err != nil {
-		log.Printf("Error getting events: %v", err)
-		if ctx.Err() == context.DeadlineExceeded {
-			http.Error(w, "Request timeout", http.StatusRequestTimeout)
+	source, externalID := r.URL.Query().Get("source"), r.URL.Query().Get("external_id")
+	var filter *internal.EventFilter
+	if expr := r.URL.Query().Get("filter"); expr != "" {
+		var err error
+		if filter, err = internal.ParseEventFilter(expr); err != nil {
+			httpError(w, r, http.StatusBadRequest, "invalid filter: %s", err)
+			return
+		}
+	}
+	view := r.URL.Query().Get("view")
+	if view != "" && view != "full" && view != "summary" {
+		httpError(w, r, http.StatusBadRequest, "view must be full or summary")
+		return
+	}
+	if filter != nil {
+		internal.UseFeature(ctx, "filter")
+	}
+	if view == "summary" {
+		internal.UseFeature(ctx, "summary_view")
+	}
+	// The version is read first, so a write racing the listing makes the ETag stale
+	// rather than the body
+	if etag := ec.eventsETag(ctx, r, source, externalID); etag != "" {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "private, no-cache")
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
 			return
 		}
-		http.Error(w, "Failed to get events", http.StatusInternalServerError)
+	}
+
+	if view == "summary" {
+		ec.getEventSummaries(ctx, w, r, filter, source, externalID)
 		return
 	}
 
+	var events []internal.EventDB
+	var err error
+	// ?external_id= looks up synced events, of one source when ?source= is given
+	// ?filter= is compiled to SQL, or applied to the synced events of ?external_id=
+	switch {
+	case externalID != "":
+		events, err = ec.eventRepo.GetEventsByExternalID(ctx, source, externalID)
+		if err == nil && filter != nil {
+			events = filter.Filter(events)
+		}
+	case filter != nil:
+		events, err = internal.FilterEvents(ctx, ec.eventRepo, filter)
+	default:
+		events, err = ec.eventRepo.GetEvents(ctx)
+	}
+	if err != nil {
+		w.Header().Del("ETag")
+		w.Header().Del("Cache-Control")
+		repositoryError(ctx, w, r, err, "getting events", "Failed to get events")
+		return
+	}
+	if selectsOnContent(filter, externalID) {
+		events = dropBusyBlocks(ctx, ec.redactor, events)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(events)
+	writeEvents(w, r, ec.decorateEvents(ctx, r, listed(r, events)), ec.cfg.FastJSON)
+}
+
+// eventsETag returns the ETag of a listing, derived from the version of its events and
+// everything else its body depends on: the caller, the query and the language. It is
+// "" when the body changes on its own, as with forecasts, or the repository cannot
+// version its listings.
+func (ec *EventController) eventsETag(ctx context.Context, r *http.Request, source, externalID string) string {
+	if includes(r)["weather"] {
+		return ""
+	}
+	v, err := internal.VersionEvents(ctx, ec.eventRepo, source, externalID)
+	if err != nil {
+		log.Printf("Error getting events version: %v", err)
+	}
+	if v == nil {
+		return ""
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n%d\n%s\n%s\n%s", v.Count, v.UpdatedAt.UnixNano(), principalID(r), r.URL.Query().Encode(), language(r))
+	return fmt.Sprintf(`W/"%s"`, hex.EncodeToString(h.Sum(nil)[:16]))
 }
 
 // GetEventByID handles GET /events/{id}
 func (ec *EventController) GetEventByID(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	vars := mux.Vars(r)
-	idStr := vars["id"]
-
-	id, err := uuid.Parse(idStr)
+	id, err := internal.ParseEventIDParam("id", mux.Vars(r)["id"])
 	if err != nil {
-		http.Error(w, "Invalid UUID format", http.StatusBadRequest)
+		paramError(w, r, err)
 		return
 	}
 
 	event, err := ec.eventRepo.GetEventByID(ctx, id)
 	if err != nil {
-		log.Printf("Error getting event by ID: %v", err)
-		http.Error(w, "Event not found", http.StatusNotFound)
+		repositoryError(ctx, w, r, err, "getting event by ID", "Failed to get event")
+		return
+	}
+	if !visible(r, *event) {
+		httpError(w, r, http.StatusNotFound, "Event not found")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(event)
+	writeEvent(w, r, ec.decorateEvent(ctx, r, *event), ec.cfg.FastJSON)
 }
 
-// SetupRoutes configures the HTTP routes
-func (ec *EventController) SetupRoutes() *mux.Router {
-	router := mux.NewRouter()
+// UpdateEvent handles PUT /events/{id}, replacing every field of the event. An {id}
+// that is not an event ID is a client key, naming the event among those of its
+// calendar: the event with it is replaced, or created with it (201) when there is none,
+// so integrations can write their events without keeping track of server IDs.
+func (ec *EventController) UpdateEvent(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
 
-	// Events endpoints
-	router.HandleFunc("/events", ec.CreateEvent).Methods("POST")
-	router.HandleFunc("/events", ec.GetEvents).Methods("GET")
-	router.HandleFunc("/events/{id}", ec.GetEventByID).Methods("GET")
+	key := mux.Vars(r)["id"]
+	id, err := internal.ParseEventID(key)
+	byKey := err != nil
+	if byKey && (strings.TrimSpace(key) != key || len(key) > maxClientKeyLength) {
+		httpError(w, r, http.StatusBadRequest, "client_key is required and must be <= %d characters", maxClientKeyLength)
+		return
+	}
 
-	return router
+	var in createEventInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	if !ec.sanitizeEventInput(r, &in) {
+		httpError(w, r, http.StatusBadRequest, "input rejected by security policy")
+		return
+	}
+	if msg := validateEventInput(&in); msg != "" {
+		httpError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+	var current *internal.EventDB
+	if byKey {
+		// A missing event is created, under the policies of creates
+		current, err = ec.eventRepo.GetEventByClientKey(ctx, in.CalendarID, key)
+		if errors.Is(err, internal.ErrEventNotFound) {
+			ec.createEvent(ctx, w, r, in, &key)
+			return
+		}
+		if err != nil {
+			repositoryError(ctx, w, r, err, "getting event by client key", "Failed to update event")
+			return
+		}
+		id = current.ID
+	} else if current, err = ec.eventRepo.GetEventByID(ctx, id); err != nil {
+		repositoryError(ctx, w, r, err, "getting event by ID", "Failed to update event")
+		return
+	}
+	if msg := internal.ValidateEventUpdate(ec.cfg, *current, internal.EventDB{StartTime: in.StartTime, EndTime: in.EndTime}, time.Now()); msg != "" {
+		httpError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+	if !ec.checkHolidays(ctx, w, r, in) {
+		return
+	}
+	if !ec.allowUpdate(w, r, id) {
+		return
+	}
+
+	event := internal.EventDB{
+		ID:                id,
+		CalendarID:        in.CalendarID,
+		Title:             in.Title,
+		Description:       in.Description,
+		DescriptionFormat: in.DescriptionFormat,
+		StartTime:         in.StartTime.UTC(),
+		EndTime:           in.EndTime.UTC(),
+		Location:          in.Location,
+		Latitude:          in.Latitude,
+		Longitude:         in.Longitude,
+		PriceCents:        in.PriceCents,
+		Currency:          in.Currency,
+		TicketQuota:       in.TicketQuota,
+		ExternalID:        in.ExternalID,
+		Source:            in.Source,
+	}
+	// An edit by a non-reviewer goes back to the review queue
+	ec.submitForReview(r, &event)
+
+	updated, err := ec.eventRepo.UpdateEvent(ctx, event)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "updating event", "Failed to update event")
+		return
+	}
+	ec.throttle.Record(id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ec.writtenEvent(ctx, r, *updated))
 }
 
-// StartServer starts the HTTP server with graceful shutdown
-func StartServer(eventRepo internal.EventRepositoryInterface, port string) {
-	controller := NewEventController(eventRepo)
-	router := controller.SetupRoutes()
+// DeleteEvent handles DELETE /events/{id}
+func (ec *EventController) DeleteEvent(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
 
-	router.Use(loggingMiddleware)
+	id, err := internal.ParseEventIDParam("id", mux.Vars(r)["id"])
+	if err != nil {
+		paramError(w, r, err)
+		return
+	}
 
-	srv := &http.Server{
-		Addr:         ":" + port,
-		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+	if err := ec.eventRepo.DeleteEvent(ctx, id); err != nil {
+		repositoryError(ctx, w, r, err, "deleting event", "Failed to delete event")
+		return
 	}
 
-	// Start server in a goroutine
-	go func() {
-		log.Printf("Server starting on port %s", port)
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalf("Server error: %v", err)
-		}
-	}()
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	// Wait for interrupt signal to gracefully shutdown the server with a timeout
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	log.Println("Server is shutting down...")
+// checkHolidays applies the configured holiday policy to an event.
+// It returns false when the response has already been written.
+func (ec *EventController) checkHolidays(ctx context.Context, w http.ResponseWriter, r *http.Request, in createEventInput) bool {
+	if ec.cfg.HolidayCountry == "" || ec.cfg.HolidayPolicy == internal.HolidayPolicyIgnore {
+		return true
+	}
 
-	// Create a context with timeout for shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	holidays, err := internal.HolidaysOn(ctx, ec.holidays, ec.cfg.HolidayCountry, in.StartTime, in.EndTime)
+	if err != nil {
+		// The holiday check is advisory, so a provider failure must not block writes
+		log.Printf("Error checking holidays: %v", err)
+		return true
+	}
+	if len(holidays) == 0 {
+		return true
+	}
 
-	// Attempt graceful shutdown
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+	if ec.cfg.HolidayPolicy == internal.HolidayPolicyBusy {
+		httpError(w, r, http.StatusConflict, "event falls on a public holiday: %s (%s)", holidays[0].Name, holidays[0].Date)
+		return false
+	}
+	for _, h := range holidays {
+		msg := internal.Translate(language(r), "event falls on a public holiday: %s (%s)", h.Name, h.Date)
+		w.Header().Add("Warning", fmt.Sprintf("299 - %q", msg))
 	}
+	return true
+}
+
+// SetupRoutes configures the HTTP routes
+func (ec *EventController) SetupRoutes() *mux.Router {
+	router := mux.NewRouter()
+
+	// Events endpoints
+	router.HandleFunc("/events", requireScope(internal.ScopeEventsWrite, ec.CreateEvent)).Methods("POST")
+	router.HandleFunc("/events/quickadd", requireScope(internal.ScopeEventsWrite, ec.QuickAddEvent)).Methods("POST")
+	router.HandleFunc("/events", requireScope(internal.ScopeEventsRead, ec.GetEvents)).Methods("GET")
+	router.HandleFunc("/events/pending", requireScope(internal.ScopeEventsReview, ec.GetPendingEvents)).Methods("GET")
+	router.HandleFunc("/events/export.pdf", requireScope(internal.ScopeEventsRead, ec.ExportAgendaPDF)).Methods("GET")
+	router.HandleFunc("/events/heatmap", requireScope(internal.ScopeEventsRead, ec.GetHeatmap)).Methods("GET")
+	router.HandleFunc("/events/stats", requireScope(internal.ScopeEventsRead, ec.GetStats)).Methods("GET")
+	router.HandleFunc("/events/search", requireScope(internal.ScopeEventsRead, ec.SearchEvents)).Methods("GET")
+	router.HandleFunc("/events/suggest", requireScope(internal.ScopeEventsRead, ec.GetSuggestions)).Methods("GET")
+	router.HandleFunc("/events/{id}", requireScope(internal.ScopeEventsRead, ec.GetEventByID)).Methods("GET")
+	router.HandleFunc("/events/{id}", requireScope(internal.ScopeEventsWrite, ec.UpdateEvent)).Methods("PUT")
+	router.HandleFunc("/events/{id}", requireScope(internal.ScopeEventsWrite, ec.DeleteEvent)).Methods("DELETE")
+	router.HandleFunc("/events/{id}/approve", requireScope(internal.ScopeEventsReview, ec.ApproveEvent)).Methods("POST")
+	router.HandleFunc("/events/{id}/reject", requireScope(internal.ScopeEventsReview, ec.RejectEvent)).Methods("POST")
+	router.HandleFunc("/events/{id}/reviews", requireScope(internal.ScopeEventsRead, ec.GetEventReviews)).Methods("GET")
+	router.HandleFunc("/events/{id}/export.pdf", requireScope(internal.ScopeEventsRead, ec.ExportEventPDF)).Methods("GET")
+	router.HandleFunc("/sync/changes", requireScope(internal.ScopeEventsRead, ec.PullChanges)).Methods("GET")
+	router.HandleFunc("/sync/changes", requireScope(internal.ScopeEventsWrite, ec.PushChanges)).Methods("POST")
 
-	log.Println("Server exited")
+	return router
 }
 
-// loggingMiddleware logs incoming HTTP requests
-func loggingMiddleware(next http.Handler) http.Handler {
+// requestIDMiddleware tags each request with an ID, taken from X-Request-ID when the
+// client sends a valid one, and echoes it in the response. The ID travels with the
+// context into database queries and outgoing HTTP calls. Operations of a /batch call
+// keep the ID of the batch. The trace ID of a traceparent header is kept as well, to
+// link metric exemplars to the caller's trace.
+func requestIDMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+		id := internal.RequestIDFromContext(r.Context())
+		if id == "" {
+			supplied := r.Header.Get(internal.HeaderRequestID)
+			id = internal.RequestID(supplied)
+			if id == supplied {
+				r = r.WithContext(internal.WithSuppliedRequestID(r.Context(), id))
+			} else {
+				r = r.WithContext(internal.WithRequestID(r.Context(), id))
+			}
+		}
+		if internal.TraceIDFromContext(r.Context()) == "" {
+			if trace := internal.ParseTraceparent(r.Header.Get(internal.HeaderTraceparent)); trace != "" {
+				r = r.WithContext(internal.WithTraceID(r.Context(), trace))
+			}
+		}
+		w.Header().Set(internal.HeaderRequestID, id)
 		next.ServeHTTP(w, r)
-		log.Printf("%s %s %v", r.Method, r.RequestURI, time.Since(start))
 	})
 }
+
+// loggingMiddleware logs incoming HTTP requests. With a sample rate of N only one in N
+// successful requests is logged, marked sample=1/N so counts can be scaled back up;
+// failed requests and those slower than slow are always logged.
+func loggingMiddleware(sampleRate int, slow time.Duration) func(http.Handler) http.Handler {
+	var seen atomic.Uint64
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			d := time.Since(start)
+
+			sample := ""
+			if rec.status < 400 && (slow <= 0 || d < slow) && sampleRate > 1 {
+				if seen.Add(1)%uint64(sampleRate) != 0 {
+					return
+				}
+				sample = fmt.Sprintf(" sample=1/%d", sampleRate)
+			}
+			log.Printf("%s %s %v status=%d request_id=%s%s", r.Method, loggedURI(r), d, rec.status, internal.RequestIDFromContext(r.Context()), sample)
+		})
+	}
+}
+
+// loggedURI is the request URI with credentials passed in the query, such as feed
+// tokens, masked
+func loggedURI(r *http.Request) string {
+	query := r.URL.Query()
+	if !query.Has("token") {
+		return r.RequestURI
+	}
+	query.Set("token", "REDACTED")
+	return r.URL.Path + "?" + query.Encode()
+}
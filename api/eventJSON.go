@@ -0,0 +1,349 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// maxPooledBuffer keeps the buffers of unusually large responses out of the pool, so
+// one huge listing does not pin its memory for good
+const maxPooledBuffer = 4 << 20
+
+// jsonBuffers pools the buffers responses are encoded into
+var jsonBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// errUnencodable is returned by appendEvent for values encoding/json rejects, such as
+// NaN coordinates, so the caller can let encoding/json report them
+var errUnencodable = errors.New("value cannot be encoded by the fast path")
+
+// writeJSON encodes v into a pooled buffer, then writes it with its length. Unlike
+// encoding into the ResponseWriter directly, an encoding error is still reported as a
+// 500 since nothing was written yet.
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	buf := jsonBuffers.Get().(*bytes.Buffer)
+	defer putBuffer(buf)
+	buf.Reset()
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		httpError(w, r, http.StatusInternalServerError, "Failed to encode response")
+		return
+	}
+	writeBuffer(w, buf.Bytes())
+}
+
+// writeEvents writes events as a JSON array, byte for byte what encoding/json writes,
+// with the event fields encoded without reflection when fast is set
+func writeEvents(w http.ResponseWriter, r *http.Request, events []eventResponse, fast bool) {
+	if !fast {
+		writeJSON(w, r, events)
+		return
+	}
+	buf := jsonBuffers.Get().(*bytes.Buffer)
+	defer putBuffer(buf)
+	buf.Reset()
+	b, err := appendEvents(buf.AvailableBuffer(), events)
+	if err != nil {
+		writeJSON(w, r, events)
+		return
+	}
+	keepBuffer(buf, b)
+	writeBuffer(w, b)
+}
+
+// writeEvent writes a single event like writeEvents
+func writeEvent(w http.ResponseWriter, r *http.Request, event eventResponse, fast bool) {
+	if !fast {
+		writeJSON(w, r, event)
+		return
+	}
+	buf := jsonBuffers.Get().(*bytes.Buffer)
+	defer putBuffer(buf)
+	buf.Reset()
+	b, err := appendEvent(buf.AvailableBuffer(), event)
+	if err != nil {
+		writeJSON(w, r, event)
+		return
+	}
+	b = append(b, '\n')
+	keepBuffer(buf, b)
+	writeBuffer(w, b)
+}
+
+// keepBuffer makes b, appended to the free space of buf, the storage of buf when it
+// outgrew it, so the pool keeps the larger one
+func keepBuffer(buf *bytes.Buffer, b []byte) {
+	if cap(b) > buf.Cap() {
+		*buf = *bytes.NewBuffer(b[:0])
+	}
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		jsonBuffers.Put(buf)
+	}
+}
+
+func writeBuffer(w http.ResponseWriter, b []byte) {
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.Write(b)
+}
+
+// appendEvents appends events as encoding/json's Encoder writes them, newline included
+func appendEvents(b []byte, events []eventResponse) ([]byte, error) {
+	if events == nil {
+		return append(b, "null\n"...), nil
+	}
+	b = append(b, '[')
+	for i, e := range events {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		var err error
+		if b, err = appendEvent(b, e); err != nil {
+			return nil, err
+		}
+	}
+	return append(b, "]\n"...), nil
+}
+
+// appendEvent appends e as encoding/json marshals it. The keys, with their quotes and
+// separators, are constants; only the values are encoded per event. Fields must follow
+// the order and omitempty tags of internal.EventDB and eventResponse.
+func appendEvent(b []byte, e eventResponse) ([]byte, error) {
+	var err error
+	b = append(b, `{"id":`...)
+	b = appendUUID(b, e.ID)
+	b = append(b, `,"calendar_id":`...)
+	if e.CalendarID == nil {
+		b = append(b, "null"...)
+	} else {
+		b = appendUUID(b, *e.CalendarID)
+	}
+	b = append(b, `,"title":`...)
+	b = appendString(b, e.Title)
+	b = append(b, `,"description":`...)
+	b = appendOptionalString(b, e.Description)
+	b = append(b, `,"description_format":`...)
+	b = appendString(b, e.DescriptionFormat)
+	b = append(b, `,"start_time":`...)
+	if b, err = appendTime(b, e.StartTime); err != nil {
+		return nil, err
+	}
+	b = append(b, `,"end_time":`...)
+	if b, err = appendTime(b, e.EndTime); err != nil {
+		return nil, err
+	}
+	if e.Location != nil {
+		b = append(b, `,"location":`...)
+		b = appendString(b, *e.Location)
+	}
+	if e.Latitude != nil {
+		b = append(b, `,"latitude":`...)
+		if b, err = appendFloat(b, *e.Latitude); err != nil {
+			return nil, err
+		}
+	}
+	if e.Longitude != nil {
+		b = append(b, `,"longitude":`...)
+		if b, err = appendFloat(b, *e.Longitude); err != nil {
+			return nil, err
+		}
+	}
+	b = append(b, `,"created_at":`...)
+	if b, err = appendTime(b, e.CreatedAt); err != nil {
+		return nil, err
+	}
+	b = append(b, `,"updated_at":`...)
+	if b, err = appendTime(b, e.UpdatedAt); err != nil {
+		return nil, err
+	}
+	b = append(b, `,"version":`...)
+	b = strconv.AppendInt(b, e.Version, 10)
+	b = append(b, `,"status":`...)
+	b = appendString(b, e.Status)
+	if e.SubmittedBy != "" {
+		b = append(b, `,"submitted_by":`...)
+		b = appendString(b, e.SubmittedBy)
+	}
+	if e.PriceCents != nil {
+		b = append(b, `,"price_cents":`...)
+		b = strconv.AppendInt(b, *e.PriceCents, 10)
+	}
+	if e.Currency != nil {
+		b = append(b, `,"currency":`...)
+		b = appendString(b, *e.Currency)
+	}
+	if e.TicketQuota != nil {
+		b = append(b, `,"ticket_quota":`...)
+		b = strconv.AppendInt(b, int64(*e.TicketQuota), 10)
+	}
+	if e.ClientKey != nil {
+		b = append(b, `,"client_key":`...)
+		b = appendString(b, *e.ClientKey)
+	}
+	if e.ExternalID != nil {
+		b = append(b, `,"external_id":`...)
+		b = appendString(b, *e.ExternalID)
+	}
+	if e.Source != nil {
+		b = append(b, `,"source":`...)
+		b = appendString(b, *e.Source)
+	}
+
+	b = append(b, `,"duration_seconds":`...)
+	b = strconv.AppendInt(b, e.DurationSeconds, 10)
+	if e.ShortID != "" {
+		b = append(b, `,"short_id":`...)
+		b = appendString(b, e.ShortID)
+	}
+	if e.DescriptionHTML != nil {
+		b = append(b, `,"description_html":`...)
+		b = appendString(b, *e.DescriptionHTML)
+	}
+	// The enrichments below are rare enough to leave to encoding/json
+	if e.Weather != nil {
+		b = append(b, `,"weather":`...)
+		if b, err = appendMarshaled(b, e.Weather); err != nil {
+			return nil, err
+		}
+	}
+	if e.Display != nil {
+		b = append(b, `,"display":`...)
+		if b, err = appendMarshaled(b, e.Display); err != nil {
+			return nil, err
+		}
+	}
+	if e.Redacted {
+		b = append(b, `,"redacted":true`...)
+	}
+	if len(e.Warnings) > 0 {
+		b = append(b, `,"warnings":`...)
+		if b, err = appendMarshaled(b, e.Warnings); err != nil {
+			return nil, err
+		}
+	}
+	return append(b, '}'), nil
+}
+
+func appendMarshaled(b []byte, v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, data...), nil
+}
+
+// appendUUID appends id in its canonical form without allocating a string
+func appendUUID(b []byte, id uuid.UUID) []byte {
+	b = append(b, '"')
+	for i, c := range id {
+		if i == 4 || i == 6 || i == 8 || i == 10 {
+			b = append(b, '-')
+		}
+		b = append(b, hexDigits[c>>4], hexDigits[c&0xF])
+	}
+	return append(b, '"')
+}
+
+func appendOptionalString(b []byte, s *string) []byte {
+	if s == nil {
+		return append(b, "null"...)
+	}
+	return appendString(b, *s)
+}
+
+// appendTime appends t as time.Time's MarshalJSON does
+func appendTime(b []byte, t time.Time) ([]byte, error) {
+	if y := t.Year(); y < 0 || y > 9999 {
+		return nil, errUnencodable
+	}
+	b = append(b, '"')
+	b = t.AppendFormat(b, time.RFC3339Nano)
+	return append(b, '"'), nil
+}
+
+// appendFloat appends f as encoding/json does, in the shortest form that round-trips
+// and in exponent notation only for very small or large magnitudes
+func appendFloat(b []byte, f float64) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, errUnencodable
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// Shorten e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b, nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendString appends s quoted as encoding/json does with HTML escaping: <, > and &
+// are escaped for embedding in HTML, invalid UTF-8 becomes U+FFFD, and the line and
+// paragraph separators are escaped for JavaScript
+func appendString(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '\\', '"':
+				b = append(b, '\\', c)
+			case '\b':
+				b = append(b, '\\', 'b')
+			case '\f':
+				b = append(b, '\\', 'f')
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = utf8.AppendRune(b, utf8.RuneError)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
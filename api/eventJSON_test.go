@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"taller_challenge/internal"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleEvents(n int) []eventResponse {
+	start := time.Date(2025, 10, 6, 9, 30, 0, 0, time.UTC)
+	description := "Weekly sync <b>&</b> \"notes\"\n\tline two"
+	location, currency, source := "Sala 3 — piso 2", "EUR", "google"
+	lat, lon := -34.6037, -58.3816
+	price, quota := int64(1500), 40
+	events := make([]eventResponse, n)
+	for i := range events {
+		calendar := uuid.New()
+		events[i] = eventResponse{DurationSeconds: 900, EventDB: internal.EventDB{
+			ID:                uuid.New(),
+			CalendarID:        &calendar,
+			Title:             fmt.Sprintf("Standup #%d", i),
+			Description:       &description,
+			DescriptionFormat: "markdown",
+			StartTime:         start.Add(time.Duration(i) * time.Hour),
+			EndTime:           start.Add(time.Duration(i)*time.Hour + 15*time.Minute),
+			Location:          &location,
+			Latitude:          &lat,
+			Longitude:         &lon,
+			CreatedAt:         start.Add(-time.Duration(i) * time.Microsecond),
+			UpdatedAt:         start.Add(123456789 * time.Nanosecond),
+			Version:           int64(i),
+			Status:            "approved",
+			PriceCents:        &price,
+			Currency:          &currency,
+			TicketQuota:       &quota,
+			ExternalID:        &description,
+			Source:            &source,
+		}}
+	}
+	return events
+}
+
+func TestAppendEventMatchesEncodingJSON(t *testing.T) {
+	// A field added to the event must be added to appendEvent too
+	require.Equal(t, 21, reflect.TypeOf(internal.EventDB{}).NumField(), "update appendEvent")
+	require.Equal(t, 8, reflect.TypeOf(eventResponse{}).NumField(), "update appendEvent")
+
+	html := "<p>Hi</p>"
+	tiny, huge := 1e-7, 1e21
+	events := append(sampleEvents(2),
+		eventResponse{},
+		eventResponse{EventDB: internal.EventDB{Title: "bad \xff utf8, \u2028\u2029 separators, \x01\b\f controls", SubmittedBy: "ana",
+			StartTime: time.Date(2025, 3, 30, 1, 0, 0, 5, time.FixedZone("", -3*3600)), Latitude: &tiny, Longitude: &huge}},
+		eventResponse{EventDB: internal.EventDB{Title: "Enriched"}, ShortID: "3mJr7AoUXx2Wqd", DescriptionHTML: &html, Redacted: true,
+			Display:  &eventDisplay{Language: "es", Start: "lunes", End: "martes"},
+			Warnings: []internal.EventWarning{{Code: internal.WarningPast, Message: "event ended in the past"}},
+			Weather:  &internal.Forecast{}},
+	)
+	for _, list := range [][]eventResponse{events, {}, nil} {
+		var want bytes.Buffer
+		require.NoError(t, json.NewEncoder(&want).Encode(list))
+		got, err := appendEvents(nil, list)
+		require.NoError(t, err)
+		assert.Equal(t, want.String(), string(got))
+	}
+	for _, e := range events {
+		want, err := json.Marshal(e)
+		require.NoError(t, err)
+		got, err := appendEvent(nil, e)
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(got))
+	}
+}
+
+func TestTimestampsRoundTripThroughAPI(t *testing.T) {
+	zones := []*time.Location{time.UTC, time.FixedZone("", -3*3600), time.FixedZone("", 5*3600+45*60)}
+	property := func(sec int64, nsec uint32, zone uint8, minutes uint16) bool {
+		start := time.Unix(sec%4102444800, int64(nsec)%int64(time.Second)).In(zones[int(zone)%len(zones)])
+		end := start.Add(time.Duration(minutes) * time.Minute)
+
+		// The client's timestamps, as the controller decodes them
+		var in struct{ StartTime, EndTime time.Time }
+		body, _ := json.Marshal(map[string]string{"StartTime": start.Format(time.RFC3339Nano), "EndTime": end.Format(time.RFC3339Nano)})
+		if err := json.Unmarshal(body, &in); err != nil {
+			return false
+		}
+		// The repository normalizes them, and the response is decoded by the client
+		event := internal.EventDB{StartTime: internal.NormalizeTime(in.StartTime), EndTime: internal.NormalizeTime(in.EndTime)}
+		encoded, err := appendEvent(nil, newEventResponse(event, false))
+		if err != nil {
+			return false
+		}
+		var out eventResponse
+		if err := json.Unmarshal(encoded, &out); err != nil {
+			return false
+		}
+		return out.StartTime.Equal(start.Round(time.Microsecond)) && out.EndTime.Equal(end.Round(time.Microsecond)) &&
+			out.StartTime.Location() == time.UTC && out.DurationSeconds == int64(minutes)*60
+	}
+	assert.NoError(t, quick.Check(property, &quick.Config{MaxCount: 2000}))
+}
+
+func TestWriteEventsFallsBack(t *testing.T) {
+	nan := math.NaN()
+	events := []eventResponse{{EventDB: internal.EventDB{Title: "Broken", Latitude: &nan}}}
+	_, err := appendEvents(nil, events)
+	assert.ErrorIs(t, err, errUnencodable)
+
+	// encoding/json rejects the event too, before anything is written
+	rec := httptest.NewRecorder()
+	writeEvents(rec, httptest.NewRequest("GET", "/events", nil), events, true)
+	assert.Equal(t, 500, rec.Code)
+
+	rec = httptest.NewRecorder()
+	writeEvents(rec, httptest.NewRequest("GET", "/events", nil), sampleEvents(3), true)
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, fmt.Sprint(rec.Body.Len()), rec.Header().Get("Content-Length"))
+}
+
+// BenchmarkWriteEvents compares encoding a listing straight into the response, as
+// handlers used to, with the pooled buffer and with the reflection-free encoder
+func BenchmarkWriteEvents(b *testing.B) {
+	events := sampleEvents(500)
+	r := httptest.NewRequest("GET", "/events", nil)
+	b.Run("encoder", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			json.NewEncoder(&discardWriter{}).Encode(events)
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			writeEvents(&discardWriter{}, r, events, false)
+		}
+	})
+	b.Run("fast", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			writeEvents(&discardWriter{}, r, events, true)
+		}
+	})
+}
+
+// discardWriter is a ResponseWriter that drops the response
+type discardWriter struct{ header http.Header }
+
+func (d *discardWriter) Header() http.Header {
+	if d.header == nil {
+		d.header = http.Header{}
+	}
+	return d.header
+}
+
+func (d *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+
+func (d *discardWriter) WriteHeader(int) {}
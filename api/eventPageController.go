@@ -0,0 +1,84 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// eventPageMaxAge is how long shared event pages may be cached by browsers and
+// link preview crawlers
+const eventPageMaxAge = 5 * time.Minute
+
+// EventPageController serves published events as public HTML pages, so shared links
+// unfurl in chat apps and events can be indexed by search engines
+type EventPageController struct {
+	events internal.EventRepositoryInterface
+	// calendars, when set, keeps the events of busy calendars off public pages
+	calendars internal.CalendarRepositoryInterface
+	publicURL string
+}
+
+// NewEventPageController creates a new event page controller linking pages under
+// publicURL, or under the host of each request when it is empty. calendars may be nil.
+func NewEventPageController(events internal.EventRepositoryInterface, calendars internal.CalendarRepositoryInterface, publicURL string) *EventPageController {
+	return &EventPageController{events: events, calendars: calendars, publicURL: publicURL}
+}
+
+// RegisterRoutes adds the event page endpoint to router; it is a public path
+func (pc *EventPageController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/e/{id}", pc.GetEventPage).Methods("GET")
+}
+
+// GetEventPage handles GET /e/{id}. The ID may be a UUID or a short ID. Events
+// awaiting review and events of busy calendars are not found.
+func (pc *EventPageController) GetEventPage(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := internal.ParseEventID(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusNotFound, "Event not found")
+		return
+	}
+	event, err := pc.events.GetEventByID(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting event by ID", "Failed to get event")
+		return
+	}
+	if !event.Published() {
+		httpError(w, r, http.StatusNotFound, "Event not found")
+		return
+	}
+	if event.CalendarID != nil && pc.calendars != nil {
+		calendar, err := pc.calendars.GetCalendar(ctx, *event.CalendarID)
+		if err != nil {
+			repositoryError(ctx, w, r, err, "getting calendar", "Failed to get event")
+			return
+		}
+		if calendar.Visibility == internal.CalendarVisibilityBusy {
+			httpError(w, r, http.StatusNotFound, "Event not found")
+			return
+		}
+	}
+
+	lang := language(r)
+	url := requestBaseURL(r, pc.publicURL) + "/e/" + event.ID.String()
+	var page bytes.Buffer
+	if err := internal.RenderEventPage(&page, *event, url, lang); err != nil {
+		log.Printf("Error rendering page of event %s: %v", id, err)
+		httpError(w, r, http.StatusInternalServerError, "Failed to get event")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Language", lang)
+	w.Header().Set("Cache-Control", cacheControl("public", eventPageMaxAge))
+	w.Header().Set("Vary", "Accept-Language")
+	w.Write(page.Bytes())
+}
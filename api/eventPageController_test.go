@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventsByID serves GetEventByID from a fixed set of events
+type eventsByID struct {
+	fakeEventRepository
+	byID map[uuid.UUID]internal.EventDB
+}
+
+func (f *eventsByID) GetEventByID(ctx context.Context, id uuid.UUID) (*internal.EventDB, error) {
+	e, ok := f.byID[id]
+	if !ok {
+		return nil, internal.ErrEventNotFound
+	}
+	return &e, nil
+}
+
+func TestEventPage(t *testing.T) {
+	start := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+	description := "Bring **slides** </script><script>alert(1)</script>"
+	location := "Room 1"
+	published := internal.EventDB{ID: uuid.New(), Title: "Go <Meetup>", Description: &description, DescriptionFormat: internal.DescriptionFormatMarkdown,
+		Location: &location, StartTime: start, EndTime: start.Add(2 * time.Hour)}
+	pending := internal.EventDB{ID: uuid.New(), Title: "Draft", Status: internal.EventStatusPending, StartTime: start, EndTime: start.Add(time.Hour)}
+	repo := &eventsByID{byID: map[uuid.UUID]internal.EventDB{published.ID: published, pending.ID: pending}}
+
+	cfg := internal.Config{APIKey: "admin-secret", EventPages: true, PublicURL: "https://cal.example.com"}
+	srv, err := NewServer(cfg, Dependencies{Events: repo})
+	require.NoError(t, err)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	// Pages need no credentials, even with authentication enabled
+	rec := get("/e/" + internal.ShortID(published.ID))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	page := rec.Body.String()
+	url := "https://cal.example.com/e/" + published.ID.String()
+	assert.Contains(t, page, `<meta property="og:title" content="Go &lt;Meetup&gt;">`)
+	assert.Contains(t, page, `<meta property="og:url" content="`+url+`">`)
+	assert.Contains(t, page, `<meta name="twitter:card" content="summary">`)
+	assert.Contains(t, page, "<strong>slides</strong>")
+	assert.NotContains(t, page, "<script>alert")
+
+	m := regexp.MustCompile(`(?s)<script type="application/ld\+json">(.*?)</script>`).FindStringSubmatch(page)
+	require.Len(t, m, 2)
+	var ld map[string]any
+	require.NoError(t, json.Unmarshal([]byte(m[1]), &ld))
+	assert.Equal(t, "Event", ld["@type"])
+	assert.Equal(t, "Go <Meetup>", ld["name"])
+	assert.Equal(t, "2025-09-15T09:00:00Z", ld["startDate"])
+	assert.Equal(t, url, ld["url"])
+	assert.Equal(t, "Room 1", ld["location"].(map[string]any)["name"])
+
+	assert.Equal(t, http.StatusNotFound, get("/e/"+pending.ID.String()).Code)
+	assert.Equal(t, http.StatusNotFound, get("/e/"+uuid.NewString()).Code)
+	assert.Equal(t, http.StatusUnauthorized, get("/events/"+published.ID.String()).Code)
+}
+
+func TestEventPageCacheControl(t *testing.T) {
+	start := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+	event := internal.EventDB{ID: uuid.New(), Title: "Go Meetup", StartTime: start, EndTime: start.Add(time.Hour)}
+	repo := &eventsByID{byID: map[uuid.UUID]internal.EventDB{event.ID: event}}
+	get := func(cfg internal.Config, path string) *httptest.ResponseRecorder {
+		srv, err := NewServer(cfg, Dependencies{Events: repo})
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	cfg := internal.Config{EventPages: true}
+	assert.Equal(t, "public, max-age=300, stale-while-revalidate=3600", get(cfg, "/e/"+event.ID.String()).Header().Get("Cache-Control"))
+
+	cfg.CacheControl = "/e/{id} public, max-age=60, stale-while-revalidate=86400; /calendars/{id}/feed.ics private, max-age=60"
+	assert.Equal(t, "public, max-age=60, stale-while-revalidate=86400", get(cfg, "/e/"+event.ID.String()).Header().Get("Cache-Control"))
+	// Errors are not given the route's caching
+	assert.Empty(t, get(cfg, "/e/"+uuid.NewString()).Header().Get("Cache-Control"))
+
+	_, err := NewServer(internal.Config{CacheControl: "public, max-age=60"}, Dependencies{Events: repo})
+	assert.Error(t, err)
+}
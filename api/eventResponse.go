@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+)
+
+// eventResponse is the wire format of an event, with optional enrichments
+type eventResponse struct {
+	internal.EventDB
+	// DurationSeconds is end_time minus start_time. Both are instants, so the duration
+	// does not depend on the zone they are shown in, even across DST changes.
+	DurationSeconds int64  `json:"duration_seconds"`
+	ShortID         string `json:"short_id,omitempty"`
+	// DescriptionHTML is the sanitized rendering of the description on ?render=html
+	DescriptionHTML *string            `json:"description_html,omitempty"`
+	Weather         *internal.Forecast `json:"weather,omitempty"`
+	Display         *eventDisplay      `json:"display,omitempty"`
+	// Redacted is set on the busy blocks shown in place of events of busy calendars
+	Redacted bool `json:"redacted,omitempty"`
+	// Warnings are hints about a created or updated event that may be a mistake
+	Warnings []internal.EventWarning `json:"warnings,omitempty"`
+}
+
+// eventDisplay holds human-readable dates in the client's language
+type eventDisplay struct {
+	Language string `json:"language"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+}
+
+// newEventResponse returns the wire format of event without enrichments
+func newEventResponse(event internal.EventDB, redacted bool) eventResponse {
+	return eventResponse{
+		EventDB:         event,
+		DurationSeconds: int64(event.EndTime.Sub(event.StartTime) / time.Second),
+		Redacted:        redacted,
+	}
+}
+
+// includes parses the comma separated ?include= parameter
+func includes(r *http.Request) map[string]bool {
+	set := map[string]bool{}
+	for _, v := range r.URL.Query()["include"] {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				set[part] = true
+			}
+		}
+	}
+	return set
+}
+
+// decorateEvent applies the enrichments requested by r to a single event
+func (ec *EventController) decorateEvent(ctx context.Context, r *http.Request, event internal.EventDB) eventResponse {
+	return ec.decorateEvents(ctx, r, []internal.EventDB{event})[0]
+}
+
+// writtenEvent is the response to a create or update of event, with the warnings the
+// configured heuristics give about it
+func (ec *EventController) writtenEvent(ctx context.Context, r *http.Request, event internal.EventDB) eventResponse {
+	resp := ec.decorateEvent(ctx, r, event)
+	lang := language(r)
+	for _, warning := range internal.EventWarnings(ec.cfg, event, time.Now()) {
+		resp.Warnings = append(resp.Warnings, warning.Localize(lang))
+	}
+	return resp
+}
+
+// decorateEvents applies the enrichments requested by r to each event
+func (ec *EventController) decorateEvents(ctx context.Context, r *http.Request, events []internal.EventDB) []eventResponse {
+	if events == nil {
+		return nil
+	}
+
+	inc := includes(r)
+	renderHTML := r.URL.Query().Get("render") == "html"
+	lang := language(r)
+	loc := displayLocation(r)
+	events, hidden := redactEvents(ctx, r, ec.redactor, events)
+
+	out := make([]eventResponse, len(events))
+	for i, event := range events {
+		out[i] = newEventResponse(event, hidden[i])
+		if ec.cfg.ShortIDs {
+			out[i].ShortID = internal.ShortID(event.ID)
+		}
+		if renderHTML && event.Description != nil {
+			rendered := internal.RenderDescriptionHTML(*event.Description, event.DescriptionFormat)
+			out[i].DescriptionHTML = &rendered
+		}
+		if inc["weather"] {
+			out[i].Weather = ec.forecastFor(ctx, event)
+		}
+		if inc["display"] {
+			out[i].Display = &eventDisplay{
+				Language: lang,
+				Start:    internal.FormatDate(lang, event.StartTime.In(loc)),
+				End:      internal.FormatDate(lang, event.EndTime.In(loc)),
+			}
+		}
+	}
+	return out
+}
+
+// redactEvents returns events with those the caller may only see as busy blocks
+// replaced, and which were. redactor may be nil, in which case nothing is redacted.
+func redactEvents(ctx context.Context, r *http.Request, redactor *internal.EventRedactor, events []internal.EventDB) ([]internal.EventDB, []bool) {
+	if redactor == nil {
+		return events, make([]bool, len(events))
+	}
+	hidden, err := redactor.Redact(ctx, events)
+	if err != nil {
+		log.Printf("Error checking calendar visibility: %v", err)
+	}
+	out := make([]internal.EventDB, len(events))
+	for i, e := range events {
+		out[i] = e
+		if hidden[i] {
+			out[i] = internal.RedactEvent(e)
+			out[i].Title = internal.Translate(language(r), internal.BusyTitle)
+		}
+	}
+	return out, hidden
+}
+
+// selectsOnContent reports whether a listing is selected on what busy blocks hide:
+// looked up by ?external_id=, or filtered on more than times by ?filter=
+func selectsOnContent(filter *internal.EventFilter, externalID string) bool {
+	return externalID != "" || (filter != nil && filter.ComparesContent())
+}
+
+// dropBusyBlocks leaves out the events the caller may only see as busy blocks, from a
+// listing that selectsOnContent: that they matched would tell what they are about.
+// Like /events/search, such listings only show events in full.
+func dropBusyBlocks(ctx context.Context, redactor *internal.EventRedactor, events []internal.EventDB) []internal.EventDB {
+	if redactor == nil {
+		return events
+	}
+	// On error the events of busy calendars count as hidden, and are dropped
+	hidden, err := redactor.Redact(ctx, events)
+	if err != nil {
+		log.Printf("Error checking calendar visibility: %v", err)
+	}
+	out := events[:0:0]
+	for i, e := range events {
+		if !hidden[i] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// displayLocation returns the ?tz= location for human-readable dates, defaulting to UTC
+func displayLocation(r *http.Request) *time.Location {
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		if loc, err := time.LoadLocation(tz); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// forecastFor returns the forecast for upcoming events with coordinates.
+// Weather is best-effort: provider errors are logged and the field is omitted.
+func (ec *EventController) forecastFor(ctx context.Context, event internal.EventDB) *internal.Forecast {
+	if ec.weather == nil || event.Latitude == nil || event.Longitude == nil {
+		return nil
+	}
+	now := time.Now()
+	if event.EndTime.Before(now) || event.StartTime.After(now.Add(internal.WeatherHorizon)) {
+		return nil
+	}
+
+	forecast, err := ec.weather.Forecast(ctx, *event.Latitude, *event.Longitude, event.StartTime)
+	if err != nil {
+		if !errors.Is(err, internal.ErrRateLimited) {
+			log.Printf("Error getting forecast for event %s: %v", event.ID, err)
+		}
+		return nil
+	}
+	return forecast
+}
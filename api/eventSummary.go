@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"taller_challenge/internal"
+)
+
+// getEventSummaries writes the summaries of a listing for GET /events?view=summary.
+// The repository selects only their columns, and they are neither decorated nor
+// decrypted.
+func (ec *EventController) getEventSummaries(ctx context.Context, w http.ResponseWriter, r *http.Request, filter *internal.EventFilter, source, externalID string) {
+	var summaries []internal.EventSummary
+	var err error
+	if externalID != "" {
+		var events []internal.EventDB
+		events, err = ec.eventRepo.GetEventsByExternalID(ctx, source, externalID)
+		if filter != nil {
+			events = filter.Filter(events)
+		}
+		summaries = internal.SummarizeAll(events)
+	} else {
+		summaries, err = internal.SummarizeEvents(ctx, ec.eventRepo, filter)
+	}
+	if err != nil {
+		w.Header().Del("ETag")
+		w.Header().Del("Cache-Control")
+		repositoryError(ctx, w, r, err, "getting event summaries", "Failed to get events")
+		return
+	}
+
+	if selectsOnContent(filter, externalID) {
+		summaries = ec.dropBusySummaries(ctx, summaries)
+	}
+	summaries = listedSummaries(r, summaries)
+	ec.redactSummaries(ctx, r, summaries)
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, summaries)
+}
+
+// listedSummaries filters summaries like listed filters events
+func listedSummaries(r *http.Request, summaries []internal.EventSummary) []internal.EventSummary {
+	user := principalID(r)
+	out := summaries[:0:0]
+	for _, s := range summaries {
+		if s.Published() || (user != "" && s.SubmittedBy == user) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// dropBusySummaries leaves out the summaries of events the caller may only see as busy
+// blocks, like dropBusyBlocks
+func (ec *EventController) dropBusySummaries(ctx context.Context, summaries []internal.EventSummary) []internal.EventSummary {
+	if ec.redactor == nil {
+		return summaries
+	}
+	events := make([]internal.EventDB, len(summaries))
+	for i, s := range summaries {
+		events[i] = internal.EventDB{ID: s.ID, CalendarID: s.CalendarID}
+	}
+	hidden, err := ec.redactor.Redact(ctx, events)
+	if err != nil {
+		log.Printf("Error checking calendar visibility: %v", err)
+	}
+	out := summaries[:0:0]
+	for i, s := range summaries {
+		if !hidden[i] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// redactSummaries retitles the summaries of events the caller may only see as busy
+// blocks, like redactEvents
+func (ec *EventController) redactSummaries(ctx context.Context, r *http.Request, summaries []internal.EventSummary) {
+	if ec.redactor == nil {
+		return
+	}
+	// Visibility depends on the calendar alone
+	events := make([]internal.EventDB, len(summaries))
+	for i, s := range summaries {
+		events[i] = internal.EventDB{ID: s.ID, CalendarID: s.CalendarID}
+	}
+	hidden, err := ec.redactor.Redact(ctx, events)
+	if err != nil {
+		log.Printf("Error checking calendar visibility: %v", err)
+	}
+	for i := range summaries {
+		if hidden[i] {
+			summaries[i].Title = internal.Translate(language(r), internal.BusyTitle)
+			summaries[i].SubmittedBy = ""
+		}
+	}
+}
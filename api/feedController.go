@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// FeedController serves calendars as ICS feeds that calendar apps can subscribe to.
+// Feed URLs carry a signed token instead of the owner's credentials.
+type FeedController struct {
+	calendars internal.CalendarRepositoryInterface
+	events    internal.EventRepositoryInterface
+	signer    *internal.FeedSigner
+	publicURL string
+}
+
+// NewFeedController creates a new feed controller handing out links under publicURL,
+// or under the host of each request when it is empty
+func NewFeedController(calendars internal.CalendarRepositoryInterface, events internal.EventRepositoryInterface, signer *internal.FeedSigner, publicURL string) *FeedController {
+	return &FeedController{calendars: calendars, events: events, signer: signer, publicURL: publicURL}
+}
+
+// RegisterRoutes adds the feed endpoints to router. feed.ics is a public path; the
+// token in its URL is checked instead.
+func (fc *FeedController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/calendars/{id}/feed", requireScope(internal.ScopeEventsRead, fc.GetFeedURL)).Methods("GET")
+	router.HandleFunc("/calendars/{id}/feed/rotate", requireScope(internal.ScopeEventsWrite, fc.RotateFeedToken)).Methods("POST")
+	router.HandleFunc("/calendars/{id}/feed.ics", fc.GetFeed).Methods("GET")
+}
+
+// feedURLResponse is a calendar's subscription link, and the snippet embedding its
+// widget, which is authorized by the same token
+type feedURLResponse struct {
+	URL       string `json:"url"`
+	WebcalURL string `json:"webcal_url"`
+	EmbedHTML string `json:"embed_html"`
+}
+
+// ownsCalendar reports whether the caller may manage a calendar's feed. Admins and
+// deployments without authentication manage every calendar.
+func ownsCalendar(r *http.Request, c internal.Calendar) bool {
+	p := internal.PrincipalFromContext(r.Context())
+	return p == nil || p.Admin || p.UserID == c.OwnerID
+}
+
+// requestBaseURL is publicURL, or the scheme and host the request was made to
+func requestBaseURL(r *http.Request, publicURL string) string {
+	if publicURL != "" {
+		return publicURL
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// GetFeedURL handles GET /calendars/{id}/feed, the subscription link of a calendar
+func (fc *FeedController) GetFeedURL(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	calendar := fc.loadOwnCalendar(ctx, w, r)
+	if calendar == nil {
+		return
+	}
+	fc.writeFeedURL(w, r, *calendar)
+}
+
+// RotateFeedToken handles POST /calendars/{id}/feed/rotate. Links handed out before
+// stop working.
+func (fc *FeedController) RotateFeedToken(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	calendar := fc.loadOwnCalendar(ctx, w, r)
+	if calendar == nil {
+		return
+	}
+	rotated, err := fc.calendars.RotateFeedToken(ctx, calendar.ID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "rotating feed token", "Failed to rotate feed token")
+		return
+	}
+	log.Printf("Security: feed token of calendar %s rotated by %q", rotated.ID, principalID(r))
+	fc.writeFeedURL(w, r, *rotated)
+}
+
+func (fc *FeedController) writeFeedURL(w http.ResponseWriter, r *http.Request, c internal.Calendar) {
+	base := requestBaseURL(r, fc.publicURL)
+	url := base + fc.signer.FeedPath(c)
+	webcal := "webcal://" + url[strings.Index(url, "://")+3:]
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(feedURLResponse{URL: url, WebcalURL: webcal, EmbedHTML: embedSnippet(base, c, fc.signer.Token(c))})
+}
+
+// loadOwnCalendar fetches the calendar named in the URL, writing an error when it
+// fails or the caller does not own it
+func (fc *FeedController) loadOwnCalendar(ctx context.Context, w http.ResponseWriter, r *http.Request) *internal.Calendar {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return nil
+	}
+
+	calendar, err := fc.calendars.GetCalendar(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting calendar", "Failed to get calendar")
+		return nil
+	}
+	if !ownsCalendar(r, *calendar) {
+		httpError(w, r, http.StatusForbidden, "only the calendar's owner can manage its feed")
+		return nil
+	}
+	return calendar
+}
+
+// GetFeed handles GET /calendars/{id}/feed.ics?token=. A wrong token gets the same
+// 404 as a missing calendar, so feed URLs cannot be probed for calendar IDs.
+func (fc *FeedController) GetFeed(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusNotFound, "Calendar not found")
+		return
+	}
+	calendar, err := fc.calendars.GetCalendar(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting calendar", "Failed to get calendar")
+		return
+	}
+	if !fc.signer.Verify(*calendar, r.URL.Query().Get("token")) {
+		log.Printf("Security: rejected feed request for calendar %s from %s: invalid token", id, r.RemoteAddr)
+		httpError(w, r, http.StatusNotFound, "Calendar not found")
+		return
+	}
+
+	events, err := fc.events.GetEventsByCalendar(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting calendar events", "Failed to get events")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s.ics"`, calendar.ID))
+	w.Header().Set("Cache-Control", cacheControl("private", internal.FeedRefreshInterval/4))
+	if err := internal.WriteICS(w, *calendar, events, time.Now()); err != nil {
+		log.Printf("Error writing feed of calendar %s: %v", id, err)
+	}
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCalendarRepository keeps calendars in memory
+type fakeCalendarRepository struct {
+	internal.CalendarRepositoryInterface
+	calendars map[uuid.UUID]internal.Calendar
+}
+
+func (f *fakeCalendarRepository) GetCalendar(ctx context.Context, id uuid.UUID) (*internal.Calendar, error) {
+	c, ok := f.calendars[id]
+	if !ok {
+		return nil, internal.ErrCalendarNotFound
+	}
+	return &c, nil
+}
+
+func (f *fakeCalendarRepository) ListCalendars(ctx context.Context) ([]internal.Calendar, error) {
+	calendars := []internal.Calendar{}
+	for _, c := range f.calendars {
+		calendars = append(calendars, c)
+	}
+	return calendars, nil
+}
+
+func (f *fakeCalendarRepository) DeleteCalendar(ctx context.Context, id uuid.UUID) error {
+	if _, ok := f.calendars[id]; !ok {
+		return internal.ErrCalendarNotFound
+	}
+	delete(f.calendars, id)
+	return nil
+}
+
+func (f *fakeCalendarRepository) RotateFeedToken(ctx context.Context, id uuid.UUID) (*internal.Calendar, error) {
+	c := f.calendars[id]
+	c.FeedVersion++
+	f.calendars[id] = c
+	return &c, nil
+}
+
+// calendarEvents serves the events of every calendar from a fixed list
+type calendarEvents struct {
+	fakeEventRepository
+}
+
+func (f *calendarEvents) GetEventsByCalendar(ctx context.Context, id uuid.UUID) ([]internal.EventDB, error) {
+	return f.events, nil
+}
+
+func (f *calendarEvents) GetEventsByExternalID(ctx context.Context, source, externalID string) ([]internal.EventDB, error) {
+	var out []internal.EventDB
+	for _, e := range f.events {
+		if e.ExternalID != nil && *e.ExternalID == externalID {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func TestCalendarFeed(t *testing.T) {
+	calendar := internal.Calendar{ID: uuid.New(), Name: "Team", OwnerID: "alice", FeedVersion: 1}
+	calendars := &fakeCalendarRepository{calendars: map[uuid.UUID]internal.Calendar{calendar.ID: calendar}}
+	start := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+	events := &calendarEvents{fakeEventRepository{events: []internal.EventDB{
+		{ID: uuid.New(), Title: "Standup", StartTime: start, EndTime: start.Add(15 * time.Minute)},
+	}}}
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		if user := r.Header.Get("X-User"); user != "" {
+			return &internal.Principal{UserID: user, Scopes: []string{internal.ScopeEventsRead, internal.ScopeEventsWrite}}, nil
+		}
+		return nil, nil
+	}
+	cfg := internal.Config{APIKey: "admin-secret", FeedSigningKey: "feed-secret", PublicURL: "https://cal.example.com"}
+	srv, err := NewServer(cfg, Dependencies{Events: events, Calendars: calendars, Auth: hook})
+	require.NoError(t, err)
+
+	do := func(method, path, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if user != "" {
+			req.Header.Set("X-User", user)
+		}
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	feedURL := func(rec *httptest.ResponseRecorder) string {
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp feedURLResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		assert.Equal(t, "webcal://"+strings.TrimPrefix(resp.URL, "https://"), resp.WebcalURL)
+		return strings.TrimPrefix(resp.URL, cfg.PublicURL)
+	}
+
+	base := "/calendars/" + calendar.ID.String()
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, base+"/feed", "bob").Code)
+	path := feedURL(do(http.MethodGet, base+"/feed", "alice"))
+
+	// The feed needs no credentials besides its token
+	rec := do(http.MethodGet, path, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/calendar; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "SUMMARY:Standup\r\n")
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, base+"/feed.ics?token=forged", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, base+"/events", "").Code)
+
+	// Rotating revokes the previous link
+	rotated := feedURL(do(http.MethodPost, base+"/feed/rotate", "alice"))
+	assert.NotEqual(t, path, rotated)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, path, "").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, rotated, "").Code)
+}
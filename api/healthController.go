@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// publicPaths are served without authentication so orchestrator probes, embedding
+// sites and the payment provider's signed webhook work
+var publicPaths = map[string]bool{"/healthz": true, "/readyz": true, "/embed.js": true, "/payments/webhook": true}
+
+// publicPatterns match paths that check their own credentials, like signed feed
+// URLs, or only serve published content
+var publicPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^/calendars/[^/]+/feed\.ics$`),
+	regexp.MustCompile(`^/embed/calendar/[^/]+$`),
+	regexp.MustCompile(`^/e/[^/]+$`),
+	regexp.MustCompile(`^/ingest/[^/]+$`),
+}
+
+// isPublic reports whether path is served without authentication
+func isPublic(path string) bool {
+	if publicPaths[path] {
+		return true
+	}
+	for _, p := range publicPatterns {
+		if p.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// pinger is implemented by repositories that can check their backing store
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthController serves liveness, readiness and metrics endpoints
+type HealthController struct {
+	eventRepo internal.EventRepositoryInterface
+	metrics   *internal.Metrics
+	draining  atomic.Bool
+}
+
+// NewHealthController creates a new health controller
+func NewHealthController(eventRepo internal.EventRepositoryInterface, metrics *internal.Metrics) *HealthController {
+	return &HealthController{eventRepo: eventRepo, metrics: metrics}
+}
+
+// RegisterRoutes adds the health endpoints to router
+func (hc *HealthController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/healthz", hc.Liveness).Methods("GET")
+	router.HandleFunc("/readyz", hc.Readiness).Methods("GET")
+	router.HandleFunc("/metrics", requireScope(internal.ScopeMetricsRead, hc.GetMetrics)).Methods("GET")
+}
+
+// RegisterAdminRoutes adds the health endpoints and unauthenticated metrics to the
+// router of the admin listener
+func (hc *HealthController) RegisterAdminRoutes(router *mux.Router) {
+	router.HandleFunc("/healthz", hc.Liveness).Methods("GET")
+	router.HandleFunc("/readyz", hc.Readiness).Methods("GET")
+	router.HandleFunc("/metrics", hc.GetMetrics).Methods("GET")
+}
+
+// StartDraining makes readiness fail so the load balancer stops sending new traffic
+func (hc *HealthController) StartDraining() {
+	hc.draining.Store(true)
+}
+
+// Liveness handles GET /healthz: the process is up and serving
+func (hc *HealthController) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, "ok")
+}
+
+// Readiness handles GET /readyz: fails while shutting down or when the database is unreachable
+func (hc *HealthController) Readiness(w http.ResponseWriter, r *http.Request) {
+	if hc.draining.Load() {
+		writeHealth(w, http.StatusServiceUnavailable, "draining")
+		return
+	}
+
+	if p, ok := hc.eventRepo.(pinger); ok {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+		if err := p.Ping(ctx); err != nil {
+			log.Printf("Error checking readiness: %v", err)
+			writeHealth(w, http.StatusServiceUnavailable, "database unavailable")
+			return
+		}
+	}
+	writeHealth(w, http.StatusOK, "ok")
+}
+
+// GetMetrics handles GET /metrics in the Prometheus text format, or in the OpenMetrics
+// format, with exemplars, when the scraper accepts it
+func (hc *HealthController) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	write := hc.metrics.WritePrometheus
+	if strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text") {
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+		write = hc.metrics.WriteOpenMetrics
+	} else {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	}
+	if err := write(w); err != nil {
+		log.Printf("Error writing metrics: %v", err)
+	}
+}
+
+func writeHealth(w http.ResponseWriter, status int, state string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"status": state})
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// metricsMiddleware records request counts and latency by route template and, once
+// metricScopeMiddleware has identified them, by tenant and calendar
+func metricsMiddleware(metrics *internal.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			done := metrics.RequestStarted()
+			defer done()
+
+			start := time.Now()
+			ctx, _ := internal.WithMetricScope(r.Context())
+			r = r.WithContext(ctx)
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			route := r.URL.Path
+			if current := mux.CurrentRoute(r); current != nil {
+				if tpl, err := current.GetPathTemplate(); err == nil {
+					route = tpl
+				}
+			}
+			metrics.ObserveRequest(r.Context(), r.Method, route, rec.status, time.Since(start))
+		})
+	}
+}
+
+// metricScopeMiddleware attributes the request to the authenticated user and to the
+// calendar it names, by its path or its calendar_id parameter
+func metricScopeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope := internal.MetricScopeFromContext(r.Context())
+		scope.SetTenant(principalID(r))
+		if id := r.URL.Query().Get("calendar_id"); id != "" {
+			scope.SetCalendar(id)
+		} else if current := mux.CurrentRoute(r); current != nil {
+			if tpl, err := current.GetPathTemplate(); err == nil && (strings.HasPrefix(tpl, "/calendars/{id}") || strings.HasPrefix(tpl, "/embed/calendar/{id}")) {
+				scope.SetCalendar(mux.Vars(r)["id"])
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"taller_challenge/internal"
+	"time"
+)
+
+// heatmapResponse is the occupancy of a period, bucket by bucket
+type heatmapResponse struct {
+	Bucket   string                   `json:"bucket"`
+	Timezone string                   `json:"timezone"`
+	Buckets  []internal.HeatmapBucket `json:"buckets"`
+}
+
+// GetHeatmap handles GET /events/heatmap?from=&to=&bucket=hour|day|week&tz=&calendar_id=,
+// the number of published events overlapping each bucket. The period is widened to
+// whole buckets in tz; days start at midnight and weeks on Monday.
+func (ec *EventController) GetHeatmap(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	query := r.URL.Query()
+	loc := displayLocation(r)
+	bucket := query.Get("bucket")
+	if bucket == "" {
+		bucket = internal.HeatmapBucketHour
+	}
+	if !internal.ValidHeatmapBucket(bucket) {
+		httpError(w, r, http.StatusBadRequest, "bucket must be hour, day or week")
+		return
+	}
+	if query.Get("from") == "" || query.Get("to") == "" {
+		httpError(w, r, http.StatusBadRequest, "from and to are required")
+		return
+	}
+	from, err := parseAgendaTime(query.Get("from"), loc)
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "from must be an RFC 3339 time or a YYYY-MM-DD date")
+		return
+	}
+	to, err := parseAgendaTime(query.Get("to"), loc)
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "to must be an RFC 3339 time or a YYYY-MM-DD date")
+		return
+	}
+	if !to.After(from) {
+		httpError(w, r, http.StatusBadRequest, "to must be after from")
+		return
+	}
+	from, to, n := internal.AlignHeatmapRange(from, to, bucket, loc)
+	if n > internal.MaxHeatmapBuckets {
+		httpError(w, r, http.StatusBadRequest, "a heatmap can have at most %d buckets", internal.MaxHeatmapBuckets)
+		return
+	}
+
+	q := internal.HeatmapQuery{From: from, To: to, Bucket: bucket, Location: loc}
+	if v := query.Get("calendar_id"); v != "" {
+		id, err := internal.ParseUUIDParam("calendar_id", v)
+		if err != nil {
+			paramError(w, r, err)
+			return
+		}
+		q.CalendarID = &id
+	}
+
+	buckets, err := ec.eventRepo.Occupancy(ctx, q)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting occupancy", "Failed to get events")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(heatmapResponse{Bucket: bucket, Timezone: loc.String(), Buckets: buckets})
+}
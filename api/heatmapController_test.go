@@ -0,0 +1,61 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// occupancy records the heatmap query and answers with one empty bucket
+type occupancy struct {
+	fakeEventRepository
+	query internal.HeatmapQuery
+}
+
+func (f *occupancy) Occupancy(ctx context.Context, q internal.HeatmapQuery) ([]internal.HeatmapBucket, error) {
+	f.query = q
+	return []internal.HeatmapBucket{{Start: q.From, End: q.To}}, nil
+}
+
+func TestGetHeatmap(t *testing.T) {
+	repo := &occupancy{}
+	srv, err := NewServer(internal.Config{}, Dependencies{Events: repo})
+	require.NoError(t, err)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	calendarID := uuid.New()
+	rec := get("/events/heatmap?from=2025-09-15T10:30:00Z&to=2025-09-15T12:10:00Z&calendar_id=" + calendarID.String())
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var body heatmapResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "hour", body.Bucket)
+	assert.Equal(t, "UTC", body.Timezone)
+	assert.Len(t, body.Buckets, 1)
+	assert.Equal(t, time.Date(2025, 9, 15, 10, 0, 0, 0, time.UTC), repo.query.From)
+	assert.Equal(t, time.Date(2025, 9, 15, 13, 0, 0, 0, time.UTC), repo.query.To)
+	assert.Equal(t, &calendarID, repo.query.CalendarID)
+
+	rec = get("/events/heatmap?from=2025-09-15&to=2025-09-30&bucket=week&tz=Europe/Madrid")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "2025-09-14T22:00:00Z", repo.query.From.UTC().Format(time.RFC3339))
+	assert.Equal(t, "2025-10-05T22:00:00Z", repo.query.To.UTC().Format(time.RFC3339))
+	assert.Nil(t, repo.query.CalendarID)
+
+	assert.Equal(t, http.StatusBadRequest, get("/events/heatmap?from=2025-09-15").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/events/heatmap?from=2025-09-15&to=2025-09-16&bucket=minute").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/events/heatmap?from=2025-09-16&to=2025-09-15").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/events/heatmap?from=2025-01-01&to=2026-01-01").Code, "too many hourly buckets")
+	assert.Equal(t, http.StatusOK, get("/events/heatmap?from=2025-01-01&to=2026-01-01&bucket=day").Code)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// HolidayController handles HTTP requests for public holidays
+type HolidayController struct {
+	holidays internal.HolidayProvider
+}
+
+// NewHolidayController creates a new holiday controller
+func NewHolidayController(holidays internal.HolidayProvider) *HolidayController {
+	return &HolidayController{holidays: holidays}
+}
+
+// RegisterRoutes adds the holiday endpoints to router
+func (hc *HolidayController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/holidays", requireScope(internal.ScopeEventsRead, hc.GetHolidays)).Methods("GET")
+}
+
+// GetHolidays handles GET /holidays?country=ES&year=2025
+func (hc *HolidayController) GetHolidays(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	country := r.URL.Query().Get("country")
+	if country == "" {
+		httpError(w, r, http.StatusBadRequest, "country is required")
+		return
+	}
+
+	year := time.Now().UTC().Year()
+	if y := r.URL.Query().Get("year"); y != "" {
+		var err error
+		year, err = strconv.Atoi(y)
+		if err != nil || year < 1900 || year > 2200 {
+			httpError(w, r, http.StatusBadRequest, "year must be between 1900 and 2200")
+			return
+		}
+	}
+
+	holidays, err := hc.holidays.Holidays(ctx, country, year)
+	if err != nil {
+		if errors.Is(err, internal.ErrUnknownCountry) {
+			httpError(w, r, http.StatusNotFound, "No holiday data for country")
+			return
+		}
+		log.Printf("Error getting holidays: %v", err)
+		httpError(w, r, http.StatusInternalServerError, "Failed to get holidays")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(holidays)
+}
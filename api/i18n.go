@@ -0,0 +1,18 @@
+package api
+
+import (
+	"net/http"
+	"taller_challenge/internal"
+)
+
+// language returns the catalog language negotiated from the request's Accept-Language
+func language(r *http.Request) string {
+	return internal.NegotiateLanguage(r.Header.Get("Accept-Language"))
+}
+
+// httpError writes a plain-text error message translated to the client's language
+func httpError(w http.ResponseWriter, r *http.Request, status int, format string, args ...any) {
+	lang := language(r)
+	w.Header().Set("Content-Language", lang)
+	http.Error(w, internal.Translate(lang, format, args...), status)
+}
@@ -0,0 +1,322 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxIngestBytes bounds the payloads external systems may POST
+const maxIngestBytes = 1 << 20
+
+// IngestController handles ingest sources and the payloads POSTed to them
+type IngestController struct {
+	sourceRepo internal.IngestRepositoryInterface
+	ingester   *internal.Ingester
+}
+
+// NewIngestController creates a new ingest controller
+func NewIngestController(sourceRepo internal.IngestRepositoryInterface, ingester *internal.Ingester) *IngestController {
+	return &IngestController{sourceRepo: sourceRepo, ingester: ingester}
+}
+
+// RegisterRoutes adds the ingest endpoints to router. /ingest/{source} is public: the
+// payload's signature or token authenticates it.
+func (ic *IngestController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/ingest-sources", requireAdmin(ic.CreateSource)).Methods("POST")
+	router.HandleFunc("/admin/ingest-sources", requireAdmin(ic.GetSources)).Methods("GET")
+	router.HandleFunc("/admin/ingest-sources/{id}", requireAdmin(ic.GetSource)).Methods("GET")
+	router.HandleFunc("/admin/ingest-sources/{id}", requireAdmin(ic.UpdateSource)).Methods("PATCH")
+	router.HandleFunc("/admin/ingest-sources/{id}", requireAdmin(ic.DeleteSource)).Methods("DELETE")
+	router.HandleFunc("/admin/ingest-sources/{id}/test", requireAdmin(ic.TestSource)).Methods("POST")
+	router.HandleFunc("/ingest/{source}", ic.Ingest).Methods("POST")
+}
+
+type createIngestSourceInput struct {
+	Name       string            `json:"name"`
+	CalendarID *uuid.UUID        `json:"calendar_id"`
+	Filter     string            `json:"filter"`
+	Mapping    map[string]string `json:"mapping"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled"`
+}
+
+// updateIngestSourceInput holds the fields PATCH may change. A calendar_id of null
+// files events in no calendar; omit it to keep the current one.
+type updateIngestSourceInput struct {
+	CalendarID json.RawMessage   `json:"calendar_id"`
+	Filter     *string           `json:"filter"`
+	Mapping    map[string]string `json:"mapping"`
+	Enabled    *bool             `json:"enabled"`
+}
+
+// ingestSourceSecretResponse is a source with its secret, shown once on creation
+type ingestSourceSecretResponse struct {
+	internal.IngestSource
+	Secret string `json:"secret"`
+}
+
+// testIngestInput is a sample payload, and optionally headers, to transform
+type testIngestInput struct {
+	Payload any               `json:"payload"`
+	Headers map[string]string `json:"headers"`
+}
+
+// ingestResult is the outcome of an ingested or test payload
+type ingestResult struct {
+	Skipped bool              `json:"skipped"`
+	Event   *internal.EventDB `json:"event,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// CreateSource handles POST /admin/ingest-sources
+func (ic *IngestController) CreateSource(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in createIngestSourceInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	source := internal.IngestSource{
+		ID:         uuid.New(),
+		Name:       strings.TrimSpace(in.Name),
+		CalendarID: in.CalendarID,
+		Filter:     strings.TrimSpace(in.Filter),
+		Mapping:    in.Mapping,
+		Enabled:    in.Enabled == nil || *in.Enabled,
+		CreatedBy:  principalID(r),
+	}
+	if msg := ic.ingester.ValidateSource(source); msg != "" {
+		httpError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+	secret, err := internal.GenerateWebhookSecret()
+	if err != nil {
+		repositoryError(ctx, w, r, err, "generating ingest secret", "Failed to create ingest source")
+		return
+	}
+	source.Secret = secret
+
+	created, err := ic.sourceRepo.CreateIngestSource(ctx, source)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "creating ingest source", "Failed to create ingest source")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ingestSourceSecretResponse{IngestSource: *created, Secret: created.Secret})
+}
+
+// GetSources handles GET /admin/ingest-sources
+func (ic *IngestController) GetSources(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	sources, err := ic.sourceRepo.ListIngestSources(ctx)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing ingest sources", "Failed to get ingest sources")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sources)
+}
+
+// GetSource handles GET /admin/ingest-sources/{id}
+func (ic *IngestController) GetSource(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	source := ic.loadSource(ctx, w, r)
+	if source == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(source)
+}
+
+// UpdateSource handles PATCH /admin/ingest-sources/{id}
+func (ic *IngestController) UpdateSource(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in updateIngestSourceInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	source := ic.loadSource(ctx, w, r)
+	if source == nil {
+		return
+	}
+	if in.CalendarID != nil {
+		var calendarID *uuid.UUID
+		if err := json.Unmarshal(in.CalendarID, &calendarID); err != nil {
+			httpError(w, r, http.StatusBadRequest, "invalid calendar_id: %v", err)
+			return
+		}
+		source.CalendarID = calendarID
+	}
+	if in.Filter != nil {
+		source.Filter = strings.TrimSpace(*in.Filter)
+	}
+	if in.Mapping != nil {
+		source.Mapping = in.Mapping
+	}
+	if in.Enabled != nil {
+		source.Enabled = *in.Enabled
+	}
+	if msg := ic.ingester.ValidateSource(*source); msg != "" {
+		httpError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+
+	updated, err := ic.sourceRepo.UpdateIngestSource(ctx, *source)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "updating ingest source", "Failed to update ingest source")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteSource handles DELETE /admin/ingest-sources/{id}
+func (ic *IngestController) DeleteSource(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	if err := ic.sourceRepo.DeleteIngestSource(ctx, id); err != nil {
+		repositoryError(ctx, w, r, err, "deleting ingest source", "Failed to delete ingest source")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestSource handles POST /admin/ingest-sources/{id}/test: it transforms a sample
+// payload without storing the event, so mappings can be tried before pointing the
+// external system at the source. Transformation errors are reported in the result.
+func (ic *IngestController) TestSource(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in testIngestInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	source := ic.loadSource(ctx, w, r)
+	if source == nil {
+		return
+	}
+	headers := http.Header{}
+	for name, value := range in.Headers {
+		headers.Set(name, value)
+	}
+
+	var result ingestResult
+	event, err := ic.ingester.Transform(*source, in.Payload, headers)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Event, result.Skipped = event, err == nil && event == nil
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// Ingest handles POST /ingest/{source}: it authenticates the payload with the source's
+// secret and creates the event it maps to. Payloads the filter skips are accepted with
+// a 202 so senders do not retry them.
+func (ic *IngestController) Ingest(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			httpError(w, r, http.StatusRequestEntityTooLarge, "payload must be at most %d bytes", maxIngestBytes)
+			return
+		}
+		httpError(w, r, http.StatusBadRequest, "failed to read payload: %v", err)
+		return
+	}
+
+	// Unknown and disabled sources look the same to senders
+	source, err := ic.sourceRepo.GetIngestSourceByName(ctx, mux.Vars(r)["source"])
+	if err == nil && !source.Enabled {
+		err = internal.ErrIngestSourceNotFound
+	}
+	if err != nil {
+		repositoryError(ctx, w, r, err, "loading ingest source", "Failed to ingest payload")
+		return
+	}
+	signature := r.Header.Get(internal.HeaderIngestSignature)
+	if signature == "" {
+		signature = r.Header.Get(internal.HeaderHubSignature)
+	}
+	if !source.Authenticates(body, signature, r.Header.Get(internal.HeaderIngestToken)) {
+		httpError(w, r, http.StatusUnauthorized, "invalid ingest signature")
+		return
+	}
+
+	event, err := ic.ingester.Ingest(ctx, *source, body, r.Header)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "ingesting payload", "Failed to ingest payload")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if event == nil {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(ingestResult{Skipped: true})
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ingestResult{Event: event})
+}
+
+// loadSource fetches the source named in the URL, writing an error when it fails
+func (ic *IngestController) loadSource(ctx context.Context, w http.ResponseWriter, r *http.Request) *internal.IngestSource {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return nil
+	}
+
+	source, err := ic.sourceRepo.GetIngestSource(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting ingest source", "Failed to get ingest source")
+		return nil
+	}
+	return source
+}
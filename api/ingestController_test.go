@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taller_challenge/internal"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIngestRepository keeps ingest sources in memory
+type fakeIngestRepository struct {
+	internal.IngestRepositoryInterface
+	sources map[uuid.UUID]internal.IngestSource
+}
+
+func (f *fakeIngestRepository) CreateIngestSource(ctx context.Context, s internal.IngestSource) (*internal.IngestSource, error) {
+	for _, existing := range f.sources {
+		if existing.Name == s.Name {
+			return nil, internal.ErrIngestSourceExists
+		}
+	}
+	f.sources[s.ID] = s
+	return &s, nil
+}
+
+func (f *fakeIngestRepository) GetIngestSource(ctx context.Context, id uuid.UUID) (*internal.IngestSource, error) {
+	s, ok := f.sources[id]
+	if !ok {
+		return nil, internal.ErrIngestSourceNotFound
+	}
+	return &s, nil
+}
+
+func (f *fakeIngestRepository) GetIngestSourceByName(ctx context.Context, name string) (*internal.IngestSource, error) {
+	for _, s := range f.sources {
+		if s.Name == name {
+			return &s, nil
+		}
+	}
+	return nil, internal.ErrIngestSourceNotFound
+}
+
+func (f *fakeIngestRepository) UpdateIngestSource(ctx context.Context, s internal.IngestSource) (*internal.IngestSource, error) {
+	f.sources[s.ID] = s
+	return &s, nil
+}
+
+func TestIngestEndpoint(t *testing.T) {
+	events := &storedEvents{eventsByID{byID: map[uuid.UUID]internal.EventDB{}}}
+	sources := &fakeIngestRepository{sources: map[uuid.UUID]internal.IngestSource{}}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{Events: events, IngestSources: sources})
+	require.NoError(t, err)
+	do := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	admin := map[string]string{"X-API-Key": "admin-secret"}
+
+	source := `{"name": "ci", "filter": "payload.status == 'success'", "mapping": {"title": "'Deployed ' + payload.version", "start_time": "payload.finished_at"}}`
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/admin/ingest-sources", source, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/admin/ingest-sources", `{"name": "ci", "mapping": {"title": "payload.("}}`, admin).Code)
+	rec := do(http.MethodPost, "/admin/ingest-sources", source, admin)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created ingestSourceSecretResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.NotEmpty(t, created.Secret)
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/admin/ingest-sources", source, admin).Code)
+
+	// The secret is only shown when created
+	rec = do(http.MethodGet, "/admin/ingest-sources/"+created.ID.String(), "", admin)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), created.Secret)
+
+	// Mappings can be tried without creating events
+	rec = do(http.MethodPost, "/admin/ingest-sources/"+created.ID.String()+"/test", `{"payload": {"status": "success", "version": "1.4.0", "finished_at": "2025-10-01T12:00:00Z"}}`, admin)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var tested ingestResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tested))
+	require.NotNil(t, tested.Event)
+	assert.Equal(t, "Deployed 1.4.0", tested.Event.Title)
+	assert.Empty(t, events.byID)
+
+	// Payloads need the source's secret, and are skipped when filtered out
+	payload := `{"status": "success", "version": "1.4.0", "finished_at": "2025-10-01T12:00:00Z"}`
+	token := map[string]string{internal.HeaderIngestToken: created.Secret}
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/ingest/ci", payload, map[string]string{internal.HeaderIngestToken: "wrong"}).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/ingest/other", payload, token).Code)
+	assert.Equal(t, http.StatusAccepted, do(http.MethodPost, "/ingest/ci", `{"status": "failed"}`, token).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/ingest/ci", `{"status": "success"}`, token).Code)
+	rec = do(http.MethodPost, "/ingest/ci", payload, token)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.Len(t, events.byID, 1)
+	for _, e := range events.byID {
+		assert.Equal(t, "Deployed 1.4.0", e.Title)
+	}
+
+	// Disabled sources no longer accept payloads
+	require.Equal(t, http.StatusOK, do(http.MethodPatch, "/admin/ingest-sources/"+created.ID.String(), `{"enabled": false}`, admin).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/ingest/ci", payload, token).Code)
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"taller_challenge/internal"
+)
+
+// listenFDsStart is the first file descriptor systemd passes activated sockets on
+const listenFDsStart = 3
+
+// adminSocketName is the FileDescriptorName= of a systemd socket serving the admin
+// endpoints; sockets with any other name serve the API
+const adminSocketName = "admin"
+
+// listeners returns the listeners of the API and of the admin endpoints: the sockets
+// systemd passed when the process was socket activated, otherwise new ones on the
+// configured addresses
+func (s *Server) listeners() (public, admin []net.Listener, err error) {
+	activated, err := activatedListeners(listenFDsStart)
+	if err != nil {
+		return nil, nil, err
+	}
+	if activated != nil {
+		return s.splitActivated(activated)
+	}
+
+	for _, addr := range listenAddrs(s.cfg) {
+		l, err := listen(addr, s.cfg.UnixSocketMode)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", addr, err)
+		}
+		public = append(public, l)
+	}
+	if s.Admin != nil {
+		l, err := listen(s.cfg.AdminListen, s.cfg.UnixSocketMode)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", s.cfg.AdminListen, err)
+		}
+		admin = append(admin, l)
+	}
+	return public, admin, nil
+}
+
+// splitActivated sorts the sockets systemd passed into those of the API and of the
+// admin endpoints, which must be enabled to receive one
+func (s *Server) splitActivated(activated map[string][]net.Listener) (public, admin []net.Listener, err error) {
+	for name, ls := range activated {
+		if name != adminSocketName {
+			public = append(public, ls...)
+		} else if s.Admin != nil {
+			admin = append(admin, ls...)
+		} else {
+			return nil, nil, errors.New("systemd passed an admin socket but ADMIN_LISTEN is not set")
+		}
+	}
+	return public, admin, nil
+}
+
+// activatedListeners returns the sockets passed by systemd socket activation from
+// firstFD on, by their FileDescriptorName= (the socket unit's name unless set), or nil
+// when the process was not socket activated. The activation variables are cleared so
+// processes started from this one do not take the sockets too.
+func activatedListeners(firstFD int) (map[string][]net.Listener, error) {
+	pid, fds := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS")
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if pid == "" || pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", fds)
+	}
+
+	listeners := map[string][]net.Listener{}
+	for i := 0; i < n; i++ {
+		fd := firstFD + i
+		name := "unknown"
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		syscall.CloseOnExec(fd)
+		f := os.NewFile(uintptr(fd), name)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("socket %d (%s) passed by systemd: %w", fd, name, err)
+		}
+		listeners[name] = append(listeners[name], l)
+	}
+	return listeners, nil
+}
+
+// listenAddrs returns the addresses the API is served on
+func listenAddrs(cfg internal.Config) []string {
+	if len(cfg.ListenAddrs) > 0 {
+		return cfg.ListenAddrs
+	}
+	return []string{":" + cfg.Port}
+}
+
+// listen opens addr, a host:port or unix:/path. A UNIX socket left behind by a process
+// that did not exit cleanly is replaced, but not one another process is listening on.
+func listen(addr string, mode os.FileMode) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use", path)
+		}
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// serve serves srv on l until it shuts down
+func serve(srv *http.Server, l net.Listener, useTLS bool, cfg internal.Config) {
+	var err error
+	if useTLS {
+		err = srv.ServeTLS(l, cfg.TLSCertFile, cfg.TLSKeyFile)
+	} else {
+		err = srv.Serve(l)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("Server error: %v", err)
+	}
+}
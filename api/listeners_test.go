@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"taller_challenge/internal"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	l, err := listen("unix:"+path, 0o660)
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o660), info.Mode().Perm())
+
+	// A live socket is not taken over
+	_, err = listen("unix:"+path, 0o660)
+	assert.ErrorContains(t, err, "in use")
+
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) }))
+	client := &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", path)
+	}}}
+	resp, err := client.Get("http://unix/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTeapot, resp.StatusCode)
+	client.CloseIdleConnections()
+
+	// One left behind by a process that died is replaced
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	l, err = listen("unix:"+path, 0o600)
+	require.NoError(t, err)
+	l.Close()
+
+	assert.Equal(t, []string{":8080"}, listenAddrs(internal.Config{Port: "8080"}))
+	assert.Equal(t, []string{"unix:" + path, ":8443"}, listenAddrs(internal.Config{Port: "8080", ListenAddrs: []string{"unix:" + path, ":8443"}}))
+}
+
+func TestActivatedListeners(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+	t.Setenv("LISTEN_FDS", "")
+	activated, err := activatedListeners(listenFDsStart)
+	require.NoError(t, err)
+	assert.Nil(t, activated, "not socket activated")
+
+	// Another process's sockets are not taken
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+	activated, err = activatedListeners(listenFDsStart)
+	require.NoError(t, err)
+	assert.Nil(t, activated)
+
+	// Pass a socket the way systemd does
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	require.NoError(t, err)
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", adminSocketName)
+	activated, err = activatedListeners(int(f.Fd()))
+	require.NoError(t, err)
+	require.Len(t, activated[adminSocketName], 1)
+	assert.Equal(t, l.Addr().String(), activated[adminSocketName][0].Addr().String())
+	activated[adminSocketName][0].Close()
+	assert.Empty(t, os.Getenv("LISTEN_FDS"), "variables are cleared for child processes")
+
+	// Its admin socket needs the admin endpoints to be enabled
+	l, err = net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	f, err = l.(*net.TCPListener).File()
+	require.NoError(t, err)
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", adminSocketName)
+	activated, err = activatedListeners(int(f.Fd()))
+	require.NoError(t, err)
+	srv := &Server{}
+	_, _, err = srv.splitActivated(activated)
+	assert.ErrorContains(t, err, "ADMIN_LISTEN is not set")
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maintenanceExempt are the paths served whatever the maintenance mode: the probes, the
+// metrics and the switch itself, so maintenance can be lifted
+var maintenanceExempt = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true, "/admin/maintenance": true}
+
+// MaintenanceController handles the maintenance mode switch
+type MaintenanceController struct {
+	maintenance *internal.MaintenanceSwitch
+}
+
+// NewMaintenanceController creates a new maintenance controller
+func NewMaintenanceController(maintenance *internal.MaintenanceSwitch) *MaintenanceController {
+	return &MaintenanceController{maintenance: maintenance}
+}
+
+// RegisterRoutes adds the maintenance endpoints to router
+func (mc *MaintenanceController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/maintenance", requireAdmin(mc.GetMaintenance)).Methods("GET")
+	router.HandleFunc("/admin/maintenance", requireAdmin(mc.SetMaintenance)).Methods("PUT")
+}
+
+// setMaintenanceInput switches the mode; retry_after defaults to 300 seconds
+type setMaintenanceInput struct {
+	Mode       string `json:"mode"`
+	Message    string `json:"message"`
+	RetryAfter *int   `json:"retry_after"`
+}
+
+// GetMaintenance handles GET /admin/maintenance
+func (mc *MaintenanceController) GetMaintenance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mc.maintenance.Current(r.Context()))
+}
+
+// SetMaintenance handles PUT /admin/maintenance
+func (mc *MaintenanceController) SetMaintenance(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in setMaintenanceInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	m := internal.MaintenanceMode{Mode: in.Mode, Message: strings.TrimSpace(in.Message), RetryAfter: 300}
+	if in.RetryAfter != nil {
+		m.RetryAfter = *in.RetryAfter
+	}
+	if msg := m.Validate(); msg != "" {
+		httpError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+	if p := internal.PrincipalFromContext(r.Context()); p != nil {
+		m.UpdatedBy = p.UserID
+	}
+
+	stored, err := mc.maintenance.Set(ctx, m)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "setting maintenance mode", "Failed to set maintenance mode")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stored)
+}
+
+// maintenanceMiddleware refuses requests during maintenance with a 503 and Retry-After:
+// writes in read_only mode, everything but the exempt paths in full mode. Admins are let
+// through so they can carry out the maintenance.
+func maintenanceMiddleware(maintenance *internal.MaintenanceSwitch) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if maintenanceExempt[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			m := maintenance.Current(r.Context())
+			write := r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions
+			if m.Mode == internal.MaintenanceOff || (m.Mode == internal.MaintenanceReadOnly && !write) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if p := internal.PrincipalFromContext(r.Context()); p != nil && p.Admin {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Retry-After", strconv.Itoa(m.RetryAfter))
+			if m.Mode == internal.MaintenanceFull && strings.Contains(r.Header.Get("Accept"), "text/html") {
+				writeMaintenancePage(w, r, m)
+				return
+			}
+			switch {
+			case m.Message != "":
+				http.Error(w, m.Message, http.StatusServiceUnavailable)
+			case m.Mode == internal.MaintenanceReadOnly:
+				httpError(w, r, http.StatusServiceUnavailable, "Read-only during maintenance, changes are not accepted for now")
+			default:
+				httpError(w, r, http.StatusServiceUnavailable, "Down for maintenance, retry later")
+			}
+		})
+	}
+}
+
+// writeMaintenancePage answers browsers during full maintenance with a page showing the
+// maintenance message
+func writeMaintenancePage(w http.ResponseWriter, r *http.Request, m internal.MaintenanceMode) {
+	lang := language(r)
+	msg := m.Message
+	if msg == "" {
+		msg = internal.Translate(lang, "Down for maintenance, retry later")
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Language", lang)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html lang="%s"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>%s</title>
+<style>body{font-family:system-ui,sans-serif;max-width:36rem;margin:20vh auto;padding:0 1rem;color:#333}</style></head>
+<body><h1>%s</h1></body></html>
+`, lang, html.EscapeString(msg), html.EscapeString(msg))
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMaintenanceModeRepository stores the maintenance mode in memory
+type fakeMaintenanceModeRepository struct {
+	mode internal.MaintenanceMode
+}
+
+func (f *fakeMaintenanceModeRepository) GetMaintenanceMode(ctx context.Context) (*internal.MaintenanceMode, error) {
+	m := f.mode
+	return &m, nil
+}
+
+func (f *fakeMaintenanceModeRepository) SetMaintenanceMode(ctx context.Context, m internal.MaintenanceMode) (*internal.MaintenanceMode, error) {
+	m.UpdatedAt = time.Now()
+	f.mode = m
+	return &m, nil
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	tokens := &fakeTokenRepository{tokens: map[string]internal.APIToken{}}
+	tokens.tokens[string(internal.HashToken("tc_writer"))] = internal.APIToken{ID: uuid.New(), UserID: "ana", Scopes: internal.AllScopes}
+	repo := &fakeMaintenanceModeRepository{mode: internal.MaintenanceMode{Mode: internal.MaintenanceOff, RetryAfter: 300}}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{
+		Events:      &storedEvents{eventsByID{byID: map[uuid.UUID]internal.EventDB{}}},
+		Tokens:      tokens,
+		Maintenance: internal.NewMaintenanceSwitch(repo),
+	})
+	require.NoError(t, err)
+	do := func(method, path, body, credential string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if credential == "admin-secret" {
+			req.Header.Set("X-API-Key", credential)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+credential)
+		}
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	event := `{"title": "Standup", "start_time": "2099-01-01T09:00:00Z", "end_time": "2099-01-01T09:15:00Z"}`
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/admin/maintenance", `{"mode": "full"}`, "tc_writer").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/admin/maintenance", `{"mode": "partial"}`, "admin-secret").Code)
+	rec := do(http.MethodPut, "/admin/maintenance", `{"mode": "read_only", "retry_after": 120}`, "admin-secret")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, internal.MaintenanceReadOnly, repo.mode.Mode, "persisted")
+
+	// Read-only: reads work, writes are refused, admins may still write
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/events", "", "tc_writer").Code)
+	rec = do(http.MethodPost, "/events", event, "tc_writer")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "120", rec.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/events", event, "admin-secret").Code)
+
+	// Full: everything but the probes and the switch answers with the message
+	require.Equal(t, http.StatusOK, do(http.MethodPut, "/admin/maintenance", `{"mode": "full", "message": "Back at 10:00 <UTC>"}`, "admin-secret").Code)
+	rec = do(http.MethodGet, "/events", "", "tc_writer")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "300", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "Back at 10:00 <UTC>", strings.TrimSpace(rec.Body.String()))
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Authorization", "Bearer tc_writer")
+	req.Header.Set("Accept", "text/html")
+	rec = httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<h1>Back at 10:00 &lt;UTC&gt;</h1>")
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/healthz", "", "").Code)
+
+	require.Equal(t, http.StatusOK, do(http.MethodPut, "/admin/maintenance", `{"mode": "off"}`, "admin-secret").Code)
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/events", event, "tc_writer").Code)
+}
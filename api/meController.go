@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// MeController handles the profile of the caller and their preferences
+type MeController struct {
+	preferences internal.PreferencesRepositoryInterface
+	twoFactor   internal.TwoFactorRepositoryInterface
+}
+
+// NewMeController creates a new profile controller. twoFactor may be nil, in which
+// case the profile has no two_factor_enabled.
+func NewMeController(preferences internal.PreferencesRepositoryInterface, twoFactor internal.TwoFactorRepositoryInterface) *MeController {
+	return &MeController{preferences: preferences, twoFactor: twoFactor}
+}
+
+// RegisterRoutes adds the profile endpoints to router
+func (mc *MeController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/me", mc.GetMe).Methods("GET")
+	router.HandleFunc("/me/preferences", mc.GetPreferences).Methods("GET")
+	router.HandleFunc("/me/preferences", mc.UpdatePreferences).Methods("PUT")
+}
+
+type meResponse struct {
+	UserID  string     `json:"user_id"`
+	Admin   bool       `json:"admin"`
+	Scopes  []string   `json:"scopes"`
+	TokenID *uuid.UUID `json:"token_id,omitempty"`
+	// TwoFactorEnabled is omitted when two-factor authentication is not available
+	TwoFactorEnabled *bool `json:"two_factor_enabled,omitempty"`
+	// Preferences are omitted for the admin key, which has none
+	Preferences *internal.Preferences `json:"preferences,omitempty"`
+}
+
+type preferencesInput struct {
+	Timezone        *string `json:"timezone"`
+	ReminderOffsets *[]int  `json:"reminder_offsets"`
+	WeekStart       *string `json:"week_start"`
+}
+
+// preferencesUser returns the caller, writing an error when there is none or it is
+// the admin key, which has no preferences
+func preferencesUser(w http.ResponseWriter, r *http.Request) *internal.Principal {
+	p := caller(w, r)
+	if p != nil && p.Admin {
+		httpError(w, r, http.StatusForbidden, "the admin key has no preferences")
+		return nil
+	}
+	return p
+}
+
+// GetMe handles GET /me
+func (mc *MeController) GetMe(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := caller(w, r)
+	if p == nil {
+		return
+	}
+	me := meResponse{UserID: p.UserID, Admin: p.Admin, Scopes: p.Scopes, TokenID: p.TokenID}
+	if !p.Admin {
+		prefs, err := mc.preferences.GetPreferences(ctx, p.UserID)
+		if err != nil {
+			repositoryError(ctx, w, r, err, "getting preferences", "Failed to get profile")
+			return
+		}
+		me.Preferences = prefs
+
+		if mc.twoFactor != nil {
+			tf, err := mc.twoFactor.GetTwoFactor(ctx, p.UserID)
+			if err != nil && !errors.Is(err, internal.ErrTwoFactorNotEnrolled) {
+				repositoryError(ctx, w, r, err, "getting two-factor authentication", "Failed to get profile")
+				return
+			}
+			enabled := tf != nil && tf.Enabled()
+			me.TwoFactorEnabled = &enabled
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(me)
+}
+
+// GetPreferences handles GET /me/preferences
+func (mc *MeController) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := preferencesUser(w, r)
+	if p == nil {
+		return
+	}
+	prefs, err := mc.preferences.GetPreferences(ctx, p.UserID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting preferences", "Failed to get preferences")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
+
+// UpdatePreferences handles PUT /me/preferences. Fields left out keep their value.
+func (mc *MeController) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := preferencesUser(w, r)
+	if p == nil {
+		return
+	}
+
+	var in preferencesInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	prefs, err := mc.preferences.GetPreferences(ctx, p.UserID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting preferences", "Failed to update preferences")
+		return
+	}
+	if in.Timezone != nil {
+		prefs.Timezone = strings.TrimSpace(*in.Timezone)
+	}
+	if in.ReminderOffsets != nil {
+		prefs.ReminderOffsets = *in.ReminderOffsets
+		if prefs.ReminderOffsets == nil {
+			prefs.ReminderOffsets = []int{}
+		}
+	}
+	if in.WeekStart != nil {
+		prefs.WeekStart = strings.ToLower(strings.TrimSpace(*in.WeekStart))
+	}
+	if msg := prefs.Validate(); msg != "" {
+		httpError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+
+	saved, err := mc.preferences.SavePreferences(ctx, *prefs)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "saving preferences", "Failed to update preferences")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(saved)
+}
+
+// preferredLocation returns the time zone the caller prefers, or UTC for the admin key,
+// unauthenticated requests and deployments without preferences. preferences may be nil.
+func preferredLocation(ctx context.Context, preferences internal.PreferencesRepositoryInterface, r *http.Request) (*time.Location, error) {
+	p := internal.PrincipalFromContext(r.Context())
+	if preferences == nil || p == nil || p.Admin {
+		return time.UTC, nil
+	}
+	prefs, err := preferences.GetPreferences(ctx, p.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return prefs.Location(), nil
+}
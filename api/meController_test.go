@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePreferencesRepository keeps preferences in memory
+type fakePreferencesRepository map[string]internal.Preferences
+
+func (f fakePreferencesRepository) GetPreferences(ctx context.Context, userID string) (*internal.Preferences, error) {
+	p, ok := f[userID]
+	if !ok {
+		p = internal.DefaultPreferences(userID)
+	}
+	return &p, nil
+}
+
+func (f fakePreferencesRepository) SavePreferences(ctx context.Context, p internal.Preferences) (*internal.Preferences, error) {
+	now := time.Now()
+	p.UpdatedAt = &now
+	f[p.UserID] = p
+	return &p, nil
+}
+
+func TestMe(t *testing.T) {
+	preferences := fakePreferencesRepository{}
+	twoFactor := newFakeTwoFactorRepository()
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		user := r.Header.Get("X-User")
+		return &internal.Principal{UserID: user, Scopes: []string{internal.ScopeEventsRead, internal.ScopeEventsWrite}, Admin: user == adminUserID}, nil
+	}
+	srv, err := NewServer(internal.Config{}, Dependencies{Events: &fakeEventRepository{}, Preferences: preferences, TwoFactor: twoFactor, Auth: hook})
+	require.NoError(t, err)
+	do := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/me", "ana", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{
+		"user_id": "ana", "admin": false, "scopes": ["events:read", "events:write"], "two_factor_enabled": false,
+		"preferences": {"timezone": "UTC", "reminder_offsets": [], "week_start": "monday", "updated_at": null}
+	}`, rec.Body.String())
+
+	// Fields left out keep their value
+	rec = do(http.MethodPut, "/me/preferences", "ana", `{"timezone": "America/New_York", "reminder_offsets": [10, 60]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = do(http.MethodPut, "/me/preferences", "ana", `{"week_start": "Sunday"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var prefs internal.Preferences
+	require.NoError(t, json.Unmarshal(do(http.MethodGet, "/me/preferences", "ana", "").Body.Bytes(), &prefs))
+	assert.Equal(t, "America/New_York", prefs.Timezone)
+	assert.Equal(t, []int{10, 60}, prefs.ReminderOffsets)
+	assert.Equal(t, internal.WeekStartSunday, prefs.WeekStart)
+	assert.NotNil(t, prefs.UpdatedAt)
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/me/preferences", "ana", `{"timezone": "Nowhere"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/me/preferences", "ana", `{"week_start": "friday"}`).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/me/preferences", "admin", `{"week_start": "sunday"}`).Code)
+	rec = do(http.MethodGet, "/me", "admin", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "preferences")
+
+	// Quick-add reads dates in the preferred time zone unless the request names one
+	draft := func(user, timezone string) internal.QuickAddDraft {
+		rec := do(http.MethodPost, "/events/quickadd", user, `{"text": "Standup tomorrow at 9am", "timezone": "`+timezone+`"}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var d internal.QuickAddDraft
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &d))
+		return d
+	}
+	newYork, _ := time.LoadLocation("America/New_York")
+	assert.Equal(t, 9, draft("ana", "").StartTime.In(newYork).Hour())
+	assert.Equal(t, 9, draft("ana", "UTC").StartTime.UTC().Hour())
+	assert.Equal(t, 9, draft("bob", "").StartTime.UTC().Hour())
+}
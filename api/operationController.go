@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxImportBytes bounds the size of an uploaded import file
+const maxImportBytes = 32 << 20
+
+// OperationController accepts long-running work and reports its progress
+type OperationController struct {
+	operationRepo internal.OperationRepositoryInterface
+	scheduler     *internal.Scheduler
+}
+
+// NewOperationController creates a new operation controller
+func NewOperationController(operationRepo internal.OperationRepositoryInterface, scheduler *internal.Scheduler) *OperationController {
+	return &OperationController{operationRepo: operationRepo, scheduler: scheduler}
+}
+
+// RegisterRoutes adds the operation endpoints to router
+func (oc *OperationController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/events/import", requireScope(internal.ScopeEventsWrite, oc.ImportEvents)).Methods("POST")
+	router.HandleFunc("/operations/{id}", requireScope(internal.ScopeEventsRead, oc.GetOperation)).Methods("GET")
+	router.HandleFunc("/admin/maintenance/reindex", requireAdmin(oc.Reindex)).Methods("POST")
+	router.HandleFunc("/admin/maintenance/refresh-stats", requireAdmin(oc.RefreshStats)).Methods("POST")
+}
+
+// ImportEvents handles POST /events/import. The body is a JSON or CSV file in the
+// backup format, optionally gzipped; it is queued and processed in the background.
+func (oc *OperationController) ImportEvents(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	input, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			httpError(w, r, http.StatusRequestEntityTooLarge, "import file must be at most %d MiB", maxImportBytes>>20)
+			return
+		}
+		httpError(w, r, http.StatusBadRequest, "failed to read import file")
+		return
+	}
+	if len(input) == 0 {
+		httpError(w, r, http.StatusBadRequest, "import file is empty")
+		return
+	}
+
+	oc.queue(ctx, w, r, internal.OperationImportEvents, input, "Failed to queue import")
+}
+
+// Reindex handles POST /admin/maintenance/reindex, queueing a rebuild of the indexes
+// of the optional list of tables, or of every table
+func (oc *OperationController) Reindex(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in internal.MaintenanceInput
+	if err := decodeOptionalJSON(r, &in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	if msg := in.Validate(); msg != "" {
+		httpError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+	input, err := json.Marshal(in)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "Failed to queue maintenance")
+		return
+	}
+	oc.queue(ctx, w, r, internal.OperationReindex, input, "Failed to queue maintenance")
+}
+
+// RefreshStats handles POST /admin/maintenance/refresh-stats, queueing a refresh of
+// the materialized views
+func (oc *OperationController) RefreshStats(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	oc.queue(ctx, w, r, internal.OperationRefreshStats, nil, "Failed to queue maintenance")
+}
+
+// queue creates an operation of kind and answers with it and where to poll its progress
+func (oc *OperationController) queue(ctx context.Context, w http.ResponseWriter, r *http.Request, kind string, input []byte, failure string) {
+	op, err := oc.operationRepo.CreateOperation(ctx, internal.Operation{
+		ID:        uuid.New(),
+		Kind:      kind,
+		CreatedBy: principalID(r),
+	}, input)
+	if err != nil {
+		log.Printf("Error queueing %s: %v", kind, err)
+		httpError(w, r, http.StatusInternalServerError, failure)
+		return
+	}
+	oc.scheduler.Wake()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/operations/"+op.ID.String())
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(op)
+}
+
+// GetOperation handles GET /operations/{id}. Callers only see their own operations.
+func (oc *OperationController) GetOperation(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	op, err := oc.operationRepo.GetOperation(ctx, id)
+	if err == nil {
+		if p := internal.PrincipalFromContext(r.Context()); p != nil && !p.Admin && p.UserID != op.CreatedBy {
+			err = internal.ErrOperationNotFound
+		}
+	}
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting operation", "Failed to get operation")
+		return
+	}
+
+	if !op.Done() {
+		// Hint for pollers; progress is saved every few hundred rows
+		w.Header().Set("Retry-After", "2")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(op)
+}
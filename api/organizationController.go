@@ -0,0 +1,584 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// OrganizationController handles HTTP requests for organizations, their members,
+// invitations and calendars. Organizations are only visible to their members.
+type OrganizationController struct {
+	orgs          internal.OrganizationRepositoryInterface
+	calendars     internal.CalendarRepositoryInterface
+	twoFactor     internal.TwoFactorRepositoryInterface
+	notifier      internal.Notifier
+	invitationURL string
+}
+
+// NewOrganizationController creates a new organization controller emailing
+// invitations through notifier, linking to invitationURL when set. Organizations can
+// only require two-factor authentication when twoFactor is set.
+func NewOrganizationController(orgs internal.OrganizationRepositoryInterface, calendars internal.CalendarRepositoryInterface, twoFactor internal.TwoFactorRepositoryInterface, notifier internal.Notifier, invitationURL string) *OrganizationController {
+	return &OrganizationController{orgs: orgs, calendars: calendars, twoFactor: twoFactor, notifier: notifier, invitationURL: invitationURL}
+}
+
+// RegisterRoutes adds the organization endpoints to router
+func (oc *OrganizationController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/organizations", requireScope(internal.ScopeEventsWrite, oc.CreateOrganization)).Methods("POST")
+	router.HandleFunc("/organizations", requireScope(internal.ScopeEventsRead, oc.GetOrganizations)).Methods("GET")
+	router.HandleFunc("/organizations/{id}", requireScope(internal.ScopeEventsRead, oc.GetOrganization)).Methods("GET")
+	router.HandleFunc("/organizations/{id}", requireScope(internal.ScopeEventsWrite, oc.UpdateOrganization)).Methods("PATCH")
+	router.HandleFunc("/organizations/{id}", requireScope(internal.ScopeEventsWrite, oc.DeleteOrganization)).Methods("DELETE")
+	router.HandleFunc("/organizations/{id}/members", requireScope(internal.ScopeEventsRead, oc.GetMembers)).Methods("GET")
+	router.HandleFunc("/organizations/{id}/members/{userId}", requireScope(internal.ScopeEventsWrite, oc.SetMember)).Methods("PUT")
+	router.HandleFunc("/organizations/{id}/members/{userId}", requireScope(internal.ScopeEventsWrite, oc.RemoveMember)).Methods("DELETE")
+	router.HandleFunc("/organizations/{id}/calendars", requireScope(internal.ScopeEventsRead, oc.GetCalendars)).Methods("GET")
+	router.HandleFunc("/organizations/{id}/invitations", requireScope(internal.ScopeEventsWrite, oc.CreateInvitation)).Methods("POST")
+	router.HandleFunc("/organizations/{id}/invitations", requireScope(internal.ScopeEventsWrite, oc.GetInvitations)).Methods("GET")
+	router.HandleFunc("/organizations/{id}/invitations/{invitationId}", requireScope(internal.ScopeEventsWrite, oc.DeleteInvitation)).Methods("DELETE")
+	router.HandleFunc("/invitations/accept", requireScope(internal.ScopeEventsRead, oc.AcceptInvitation)).Methods("POST")
+}
+
+type organizationInput struct {
+	Name string `json:"name"`
+	// RequireTwoFactor is optional; only owners who enabled two-factor authentication
+	// may change it
+	RequireTwoFactor *bool `json:"require_two_factor"`
+}
+
+type setMemberInput struct {
+	Role string `json:"role"`
+}
+
+type createInvitationInput struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type acceptInvitationInput struct {
+	Token string `json:"token"`
+}
+
+// orgRole returns the caller's role in an organization, "" when they are not a
+// member. Admins and deployments without authentication act as owners.
+func orgRole(ctx context.Context, orgs internal.OrganizationRepositoryInterface, r *http.Request, orgID uuid.UUID) (string, error) {
+	p := internal.PrincipalFromContext(r.Context())
+	if p == nil || p.Admin {
+		return internal.OrgRoleOwner, nil
+	}
+	if orgs == nil {
+		return "", nil
+	}
+	m, err := orgs.GetMembership(ctx, orgID, p.UserID)
+	if errors.Is(err, internal.ErrMemberNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return m.Role, nil
+}
+
+// decodeOrganization reads and checks an organization. The name may be left out of
+// updates changing require_two_factor.
+func decodeOrganization(w http.ResponseWriter, r *http.Request, update bool) (*organizationInput, bool) {
+	var in organizationInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return nil, false
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	if update && in.Name == "" && in.RequireTwoFactor != nil {
+		return &in, true
+	}
+	if in.Name == "" || len(in.Name) > 100 {
+		httpError(w, r, http.StatusBadRequest, "name is required and must be <= 100 characters")
+		return nil, false
+	}
+	return &in, true
+}
+
+// mayRequireTwoFactor reports whether the caller may make an organization require
+// two-factor authentication, writing an error when not. Callers must have enabled it
+// themselves, so they do not lock themselves out.
+func (oc *OrganizationController) mayRequireTwoFactor(ctx context.Context, w http.ResponseWriter, r *http.Request) bool {
+	if oc.twoFactor == nil {
+		httpError(w, r, http.StatusBadRequest, "two-factor authentication is not enabled on this server")
+		return false
+	}
+	p := internal.PrincipalFromContext(r.Context())
+	if p == nil || p.Admin {
+		return true
+	}
+	enabled, err := internal.TwoFactorEnabled(ctx, oc.twoFactor, p.UserID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting two-factor enrollment", "Failed to update organization")
+		return false
+	}
+	if !enabled {
+		httpError(w, r, http.StatusForbidden, "enable two-factor authentication before requiring it")
+		return false
+	}
+	return true
+}
+
+// CreateOrganization handles POST /organizations; the caller becomes its owner
+func (oc *OrganizationController) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	in, ok := decodeOrganization(w, r, false)
+	if !ok {
+		return
+	}
+	org := internal.Organization{ID: uuid.New(), Name: in.Name, CreatedBy: principalID(r)}
+	if in.RequireTwoFactor != nil && *in.RequireTwoFactor {
+		if !oc.mayRequireTwoFactor(ctx, w, r) {
+			return
+		}
+		org.RequireTwoFactor = true
+	}
+
+	created, err := oc.orgs.CreateOrganization(ctx, org)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "creating organization", "Failed to create organization")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// GetOrganizations handles GET /organizations, the organizations the caller belongs
+// to with their role; admins see every organization
+func (oc *OrganizationController) GetOrganizations(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	user := principalID(r)
+	if p := internal.PrincipalFromContext(r.Context()); p != nil && p.Admin {
+		user = ""
+	}
+	orgs, err := oc.orgs.ListOrganizations(ctx, user)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing organizations", "Failed to get organizations")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(orgs)
+}
+
+// GetOrganization handles GET /organizations/{id}
+func (oc *OrganizationController) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	org := oc.loadOrganization(ctx, w, r, internal.OrgRoleMember)
+	if org == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(org)
+}
+
+// UpdateOrganization handles PATCH /organizations/{id}, renaming it, which admins
+// may, or changing whether it requires two-factor authentication, which owners may
+func (oc *OrganizationController) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	org := oc.loadOrganization(ctx, w, r, internal.OrgRoleAdmin)
+	if org == nil {
+		return
+	}
+	in, ok := decodeOrganization(w, r, true)
+	if !ok {
+		return
+	}
+	if in.Name != "" {
+		org.Name = in.Name
+	}
+	if in.RequireTwoFactor != nil && *in.RequireTwoFactor != org.RequireTwoFactor {
+		if !internal.RoleAtLeast(org.Role, internal.OrgRoleOwner) {
+			httpError(w, r, http.StatusForbidden, "this requires the %s role in the organization", internal.OrgRoleOwner)
+			return
+		}
+		if *in.RequireTwoFactor && !oc.mayRequireTwoFactor(ctx, w, r) {
+			return
+		}
+		org.RequireTwoFactor = *in.RequireTwoFactor
+	}
+
+	updated, err := oc.orgs.UpdateOrganization(ctx, *org)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "updating organization", "Failed to update organization")
+		return
+	}
+	updated.Role = org.Role
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteOrganization handles DELETE /organizations/{id}. Owners may, once its
+// calendars are deleted.
+func (oc *OrganizationController) DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	org := oc.loadOrganization(ctx, w, r, internal.OrgRoleOwner)
+	if org == nil {
+		return
+	}
+
+	if err := oc.orgs.DeleteOrganization(ctx, org.ID); err != nil {
+		repositoryError(ctx, w, r, err, "deleting organization", "Failed to delete organization")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMembers handles GET /organizations/{id}/members
+func (oc *OrganizationController) GetMembers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	org := oc.loadOrganization(ctx, w, r, internal.OrgRoleMember)
+	if org == nil {
+		return
+	}
+
+	members, err := oc.orgs.ListMembers(ctx, org.ID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing members", "Failed to get members")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// SetMember handles PUT /organizations/{id}/members/{userId}, adding a user or
+// changing their role. Admins manage members and admins; only owners grant or take
+// away ownership.
+func (oc *OrganizationController) SetMember(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in setMemberInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	if !internal.ValidOrgRole(in.Role) {
+		httpError(w, r, http.StatusBadRequest, "role must be owner, admin or member")
+		return
+	}
+
+	org := oc.loadOrganization(ctx, w, r, internal.OrgRoleAdmin)
+	if org == nil {
+		return
+	}
+	userID := mux.Vars(r)["userId"]
+	if !oc.mayChangeMember(ctx, w, r, org, userID, in.Role) {
+		return
+	}
+
+	member, err := oc.orgs.SetMember(ctx, org.ID, userID, in.Role)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "setting member", "Failed to set member")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(member)
+}
+
+// RemoveMember handles DELETE /organizations/{id}/members/{userId}. Admins remove
+// members, and anyone may leave; the last owner cannot.
+func (oc *OrganizationController) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	userID := mux.Vars(r)["userId"]
+	minRole := internal.OrgRoleAdmin
+	if userID == principalID(r) {
+		minRole = internal.OrgRoleMember
+	}
+	org := oc.loadOrganization(ctx, w, r, minRole)
+	if org == nil {
+		return
+	}
+	if userID != principalID(r) && !oc.mayChangeMember(ctx, w, r, org, userID, "") {
+		return
+	}
+
+	if err := oc.orgs.RemoveMember(ctx, org.ID, userID); err != nil {
+		repositoryError(ctx, w, r, err, "removing member", "Failed to remove member")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mayChangeMember reports whether the caller may give userID role, or remove them
+// when role is "", writing a 403 when not: changes touching ownership need an owner
+func (oc *OrganizationController) mayChangeMember(ctx context.Context, w http.ResponseWriter, r *http.Request, org *internal.Organization, userID, role string) bool {
+	if org.Role == internal.OrgRoleOwner {
+		return true
+	}
+	if role == internal.OrgRoleOwner {
+		httpError(w, r, http.StatusForbidden, "only owners can change who owns the organization")
+		return false
+	}
+	current, err := oc.orgs.GetMembership(ctx, org.ID, userID)
+	if errors.Is(err, internal.ErrMemberNotFound) {
+		return true
+	}
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting membership", "Failed to get members")
+		return false
+	}
+	if current.Role == internal.OrgRoleOwner {
+		httpError(w, r, http.StatusForbidden, "only owners can change who owns the organization")
+		return false
+	}
+	return true
+}
+
+// GetCalendars handles GET /organizations/{id}/calendars, the calendars the
+// organization owns
+func (oc *OrganizationController) GetCalendars(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	org := oc.loadOrganization(ctx, w, r, internal.OrgRoleMember)
+	if org == nil {
+		return
+	}
+
+	calendars, err := oc.calendars.ListOrganizationCalendars(ctx, org.ID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing organization calendars", "Failed to get calendars")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(calendars)
+}
+
+// CreateInvitation handles POST /organizations/{id}/invitations, emailing a token
+// that makes whoever accepts it a member with role. The token is only in the email.
+func (oc *OrganizationController) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	in := createInvitationInput{Role: internal.OrgRoleMember}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	inv := internal.Invitation{
+		ID:        uuid.New(),
+		Email:     strings.TrimSpace(in.Email),
+		Role:      in.Role,
+		InvitedBy: principalID(r),
+		ExpiresAt: time.Now().Add(internal.InvitationTTL),
+	}
+	if msg := internal.ValidateInvitation(inv); msg != "" {
+		httpError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+
+	org := oc.loadOrganization(ctx, w, r, internal.OrgRoleAdmin)
+	if org == nil {
+		return
+	}
+	if inv.Role == internal.OrgRoleOwner && org.Role != internal.OrgRoleOwner {
+		httpError(w, r, http.StatusForbidden, "only owners can change who owns the organization")
+		return
+	}
+	inv.OrganizationID = org.ID
+
+	token, hash, err := internal.GenerateInvitationToken()
+	if err != nil {
+		log.Printf("Error generating invitation token: %v", err)
+		httpError(w, r, http.StatusInternalServerError, "Failed to create invitation")
+		return
+	}
+	created, err := oc.orgs.CreateInvitation(ctx, inv, hash)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "creating invitation", "Failed to create invitation")
+		return
+	}
+	// The invitation stands even if the email fails; it can be revoked and sent again
+	if err := oc.notifier.Notify(ctx, oc.invitationEmail(language(r), *org, *created, token)); err != nil {
+		log.Printf("Error emailing invitation %s: %v", created.ID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// invitationEmail is the message carrying an invitation's token
+func (oc *OrganizationController) invitationEmail(lang string, org internal.Organization, inv internal.Invitation, token string) internal.Notification {
+	inviter := inv.InvitedBy
+	if inviter == "" {
+		inviter = internal.Translate(lang, "Someone")
+	}
+	text := internal.Translate(lang, "%s invited you to join %s as %s.", inviter, org.Name, inv.Role) + "\n\n"
+	if oc.invitationURL != "" {
+		text += internal.Translate(lang, "Accept the invitation at %s", strings.ReplaceAll(oc.invitationURL, "{token}", token))
+	} else {
+		text += internal.Translate(lang, "Accept the invitation by sending this token to POST /invitations/accept: %s", token)
+	}
+	text += "\n\n" + internal.Translate(lang, "The invitation expires on %s.", internal.FormatDate(lang, inv.ExpiresAt))
+	return internal.Notification{
+		To:      inv.Email,
+		Subject: internal.Translate(lang, "Invitation to join %s", org.Name),
+		Text:    text,
+	}
+}
+
+// GetInvitations handles GET /organizations/{id}/invitations, those not accepted yet
+func (oc *OrganizationController) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	org := oc.loadOrganization(ctx, w, r, internal.OrgRoleAdmin)
+	if org == nil {
+		return
+	}
+
+	invitations, err := oc.orgs.ListInvitations(ctx, org.ID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing invitations", "Failed to get invitations")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invitations)
+}
+
+// DeleteInvitation handles DELETE /organizations/{id}/invitations/{invitationId},
+// revoking an invitation not accepted yet
+func (oc *OrganizationController) DeleteInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	invitationID, err := uuid.Parse(mux.Vars(r)["invitationId"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+	org := oc.loadOrganization(ctx, w, r, internal.OrgRoleAdmin)
+	if org == nil {
+		return
+	}
+
+	if err := oc.orgs.DeleteInvitation(ctx, org.ID, invitationID); err != nil {
+		repositoryError(ctx, w, r, err, "deleting invitation", "Failed to delete invitation")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptInvitation handles POST /invitations/accept, making the caller a member of
+// the organization the token invites to
+func (oc *OrganizationController) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in acceptInvitationInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	if in.Token == "" {
+		httpError(w, r, http.StatusBadRequest, "token is required")
+		return
+	}
+	user := principalID(r)
+	if user == "" {
+		httpError(w, r, http.StatusBadRequest, "accepting an invitation requires a user")
+		return
+	}
+
+	member, err := oc.orgs.AcceptInvitation(ctx, internal.HashToken(in.Token), user, time.Now())
+	if err != nil {
+		repositoryError(ctx, w, r, err, "accepting invitation", "Failed to accept invitation")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(member)
+}
+
+// loadOrganization fetches the organization named in the URL with the caller's role,
+// writing an error when it fails, the caller's role is below minRole, or the caller
+// lacks the two-factor authentication it requires. Organizations are reported as not
+// found to non-members.
+func (oc *OrganizationController) loadOrganization(ctx context.Context, w http.ResponseWriter, r *http.Request, minRole string) *internal.Organization {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return nil
+	}
+
+	org, err := oc.orgs.GetOrganization(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting organization", "Failed to get organization")
+		return nil
+	}
+	role, err := orgRole(ctx, oc.orgs, r, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting membership", "Failed to get organization")
+		return nil
+	}
+	if role == "" {
+		httpError(w, r, http.StatusNotFound, "Organization not found")
+		return nil
+	}
+	if !internal.RoleAtLeast(role, minRole) {
+		httpError(w, r, http.StatusForbidden, "this requires the %s role in the organization", minRole)
+		return nil
+	}
+	if org.RequireTwoFactor && oc.twoFactor != nil {
+		if p := internal.PrincipalFromContext(r.Context()); p != nil && !p.Admin {
+			enabled, err := internal.TwoFactorEnabled(ctx, oc.twoFactor, p.UserID)
+			if err != nil {
+				repositoryError(ctx, w, r, err, "getting two-factor enrollment", "Failed to get organization")
+				return nil
+			}
+			if !enabled {
+				httpError(w, r, http.StatusForbidden, "organization %s requires two-factor authentication, enable it at /me/2fa first", org.Name)
+				return nil
+			}
+		}
+	}
+	org.Role = role
+	return org
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOrganizationRepository keeps organizations and members in memory
+type fakeOrganizationRepository struct {
+	internal.OrganizationRepositoryInterface
+	orgs        map[uuid.UUID]internal.Organization
+	members     map[uuid.UUID]map[string]string
+	invitations map[string]internal.Invitation
+}
+
+func newFakeOrganizationRepository() *fakeOrganizationRepository {
+	return &fakeOrganizationRepository{orgs: map[uuid.UUID]internal.Organization{}, members: map[uuid.UUID]map[string]string{}, invitations: map[string]internal.Invitation{}}
+}
+
+func (f *fakeOrganizationRepository) CreateOrganization(ctx context.Context, o internal.Organization) (*internal.Organization, error) {
+	f.orgs[o.ID] = o
+	f.members[o.ID] = map[string]string{o.CreatedBy: internal.OrgRoleOwner}
+	o.Role = internal.OrgRoleOwner
+	return &o, nil
+}
+
+func (f *fakeOrganizationRepository) GetOrganization(ctx context.Context, id uuid.UUID) (*internal.Organization, error) {
+	o, ok := f.orgs[id]
+	if !ok {
+		return nil, internal.ErrOrganizationNotFound
+	}
+	return &o, nil
+}
+
+func (f *fakeOrganizationRepository) GetMembership(ctx context.Context, orgID uuid.UUID, userID string) (*internal.Membership, error) {
+	role, ok := f.members[orgID][userID]
+	if !ok {
+		return nil, internal.ErrMemberNotFound
+	}
+	return &internal.Membership{OrganizationID: orgID, UserID: userID, Role: role}, nil
+}
+
+func (f *fakeOrganizationRepository) SetMember(ctx context.Context, orgID uuid.UUID, userID, role string) (*internal.Membership, error) {
+	f.members[orgID][userID] = role
+	return &internal.Membership{OrganizationID: orgID, UserID: userID, Role: role}, nil
+}
+
+func (f *fakeOrganizationRepository) RemoveMember(ctx context.Context, orgID uuid.UUID, userID string) error {
+	owners := 0
+	for _, role := range f.members[orgID] {
+		if role == internal.OrgRoleOwner {
+			owners++
+		}
+	}
+	if f.members[orgID][userID] == internal.OrgRoleOwner && owners == 1 {
+		return internal.ErrLastOwner
+	}
+	delete(f.members[orgID], userID)
+	return nil
+}
+
+func (f *fakeOrganizationRepository) CreateInvitation(ctx context.Context, inv internal.Invitation, tokenHash []byte) (*internal.Invitation, error) {
+	f.invitations[string(tokenHash)] = inv
+	return &inv, nil
+}
+
+func (f *fakeOrganizationRepository) AcceptInvitation(ctx context.Context, tokenHash []byte, userID string, now time.Time) (*internal.Membership, error) {
+	inv, ok := f.invitations[string(tokenHash)]
+	if !ok {
+		return nil, internal.ErrInvitationNotFound
+	}
+	delete(f.invitations, string(tokenHash))
+	return f.SetMember(ctx, inv.OrganizationID, userID, inv.Role)
+}
+
+// createdCalendars records the calendars created and updated
+type createdCalendars struct {
+	fakeCalendarRepository
+}
+
+func (f *createdCalendars) UpdateCalendar(ctx context.Context, c internal.Calendar) (*internal.Calendar, error) {
+	f.calendars[c.ID] = c
+	return &c, nil
+}
+
+func (f *createdCalendars) CreateCalendar(ctx context.Context, c internal.Calendar) (*internal.Calendar, error) {
+	f.calendars[c.ID] = c
+	return &c, nil
+}
+
+func (f *createdCalendars) ListOrganizationCalendars(ctx context.Context, orgID uuid.UUID) ([]internal.Calendar, error) {
+	out := []internal.Calendar{}
+	for _, c := range f.calendars {
+		if c.OrganizationID != nil && *c.OrganizationID == orgID {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// sentNotifications records the notifications sent
+type sentNotifications struct {
+	sent []internal.Notification
+}
+
+func (s *sentNotifications) Notify(ctx context.Context, n internal.Notification) error {
+	s.sent = append(s.sent, n)
+	return nil
+}
+
+func TestOrganizations(t *testing.T) {
+	orgs := newFakeOrganizationRepository()
+	calendars := &createdCalendars{fakeCalendarRepository{calendars: map[uuid.UUID]internal.Calendar{}}}
+	mail := &sentNotifications{}
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		return &internal.Principal{UserID: r.Header.Get("X-User"), Scopes: []string{internal.ScopeEventsRead, internal.ScopeEventsWrite}}, nil
+	}
+	cfg := internal.Config{APIKey: "admin-secret", InvitationURL: "https://cal.example.com/join/{token}"}
+	srv, err := NewServer(cfg, Dependencies{Events: &fakeEventRepository{}, Calendars: calendars, Organizations: orgs, Notifier: mail, Auth: hook})
+	require.NoError(t, err)
+	do := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/organizations", "alice", `{"name": " Acme "}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var org internal.Organization
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &org))
+	assert.Equal(t, "Acme", org.Name)
+	assert.Equal(t, internal.OrgRoleOwner, org.Role)
+	base := "/organizations/" + org.ID.String()
+
+	// Outsiders do not see the organization
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, base, "bob", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/calendars", "bob", `{"name": "Ops", "organization_id": "`+org.ID.String()+`"}`).Code)
+
+	// Bob accepts an emailed invitation and becomes a member
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, base+"/invitations", "alice", `{"email": "not an address"}`).Code)
+	rec = do(http.MethodPost, base+"/invitations", "alice", `{"email": "bob@example.com"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.NotContains(t, rec.Body.String(), "inv_")
+	require.Len(t, mail.sent, 1)
+	assert.Equal(t, "bob@example.com", mail.sent[0].To)
+	token := regexp.MustCompile(`/join/(inv_[\w-]+)`).FindStringSubmatch(mail.sent[0].Text)
+	require.Len(t, token, 2, mail.sent[0].Text)
+	rec = do(http.MethodPost, "/invitations/accept", "bob", `{"token": "`+token[1]+`"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/invitations/accept", "bob", `{"token": "`+token[1]+`"}`).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, base, "bob", "").Code)
+
+	// Members cannot manage the organization or add calendars to it
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPatch, base, "bob", `{"name": "Bob Inc"}`).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/calendars", "bob", `{"name": "Ops", "organization_id": "`+org.ID.String()+`"}`).Code)
+
+	// Admins do, but cannot hand out ownership
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, base+"/members/bob", "alice", `{"role": "admin"}`).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, base+"/members/carol", "bob", `{"role": "owner"}`).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, base+"/members/alice", "bob", "").Code)
+	rec = do(http.MethodPost, "/calendars", "bob", `{"name": "Ops", "organization_id": "`+org.ID.String()+`"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var calendar internal.Calendar
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &calendar))
+
+	// Other admins of the organization manage its calendars
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, base+"/members/carol", "alice", `{"role": "admin"}`).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPatch, "/calendars/"+calendar.ID.String(), "carol", `{"name": "Operations"}`).Code)
+	rec = do(http.MethodGet, base+"/calendars", "carol", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var listed []internal.Calendar
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	require.Len(t, listed, 1)
+	assert.Equal(t, "Operations", listed[0].Name)
+
+	// The last owner cannot leave; members can
+	assert.Equal(t, http.StatusConflict, do(http.MethodDelete, base+"/members/alice", "alice", "").Code)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, base+"/members/carol", "carol", "").Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPatch, "/calendars/"+calendar.ID.String(), "carol", `{"name": "Mine"}`).Code)
+
+	// Deleting the calendar takes its owner or an admin of the organization too
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/calendars/"+calendar.ID.String(), "carol", "").Code)
+	assert.Contains(t, calendars.calendars, calendar.ID)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/calendars/"+calendar.ID.String(), "alice", "").Code)
+	assert.NotContains(t, calendars.calendars, calendar.ID)
+}
+
+func TestInvitationEmailWithoutURL(t *testing.T) {
+	oc := NewOrganizationController(nil, nil, nil, nil, "")
+	inv := internal.Invitation{Email: "bob@example.com", Role: internal.OrgRoleAdmin, InvitedBy: "alice", ExpiresAt: time.Date(2025, 9, 30, 12, 0, 0, 0, time.UTC)}
+	n := oc.invitationEmail("en", internal.Organization{Name: "Acme"}, inv, "inv_secret")
+	assert.Equal(t, "Invitation to join Acme", n.Subject)
+	assert.Contains(t, n.Text, "alice invited you to join Acme as admin.")
+	assert.Contains(t, n.Text, "POST /invitations/accept: inv_secret")
+}
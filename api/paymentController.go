@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxWebhookBytes bounds a payment webhook delivery
+const maxWebhookBytes = 1 << 20
+
+// PaymentController receives the payment provider's webhook, confirming the
+// reservations paid at checkout
+type PaymentController struct {
+	tickets  internal.TicketRepositoryInterface
+	payments internal.PaymentProvider
+}
+
+// NewPaymentController creates a new payment controller
+func NewPaymentController(tickets internal.TicketRepositoryInterface, payments internal.PaymentProvider) *PaymentController {
+	return &PaymentController{tickets: tickets, payments: payments}
+}
+
+// RegisterRoutes adds the webhook endpoint to router. It is public: deliveries are
+// authenticated by the provider's signature.
+func (pc *PaymentController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/payments/webhook", pc.Webhook).Methods("POST")
+}
+
+// Webhook handles POST /payments/webhook. A paid checkout confirms its reservation,
+// or is refunded when the reservation expired, was cancelled or deleted meanwhile.
+// An expired checkout returns its tickets at once. Failures answer 500 so the
+// provider retries.
+func (pc *PaymentController) Webhook(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBytes))
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid body: %v", err)
+		return
+	}
+	event, err := pc.payments.ParseWebhook(payload, r.Header, time.Now())
+	if errors.Is(err, internal.ErrWebhookSignature) {
+		httpError(w, r, http.StatusBadRequest, "invalid webhook signature")
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "%v", err)
+		return
+	}
+	if event == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	switch event.Type {
+	case internal.PaymentCompleted:
+		_, err = pc.tickets.ConfirmReservation(ctx, event.EventID, event.ReservationID, event.PaymentID)
+		if errors.Is(err, internal.ErrReservationClosed) || errors.Is(err, internal.ErrReservationNotFound) {
+			log.Printf("Reservation %s was paid after it closed; refunding", event.ReservationID)
+			err = pc.payments.Refund(ctx, event.ReservationID, event.PaymentID)
+		}
+	case internal.PaymentExpired:
+		// Only a reservation still waiting for this payment is released
+		var res *internal.TicketReservation
+		res, err = pc.tickets.GetReservation(ctx, event.EventID, event.ReservationID)
+		if err == nil && res.Status == internal.TicketStatusHeld {
+			err = pc.tickets.CancelReservation(ctx, event.EventID, event.ReservationID)
+		}
+		if errors.Is(err, internal.ErrReservationClosed) || errors.Is(err, internal.ErrReservationNotFound) {
+			err = nil
+		}
+	}
+	if err != nil {
+		log.Printf("Error handling %s payment of reservation %s: %v", event.Type, event.ReservationID, err)
+		httpError(w, r, http.StatusInternalServerError, "Failed to handle payment")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
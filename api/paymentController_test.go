@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePayments hands out checkout pages and reports the payment event its webhook
+// deliveries name, when signed "ok"
+type fakePayments struct {
+	checkouts []internal.CheckoutRequest
+	refunds   []string
+	event     *internal.PaymentEvent
+}
+
+func (f *fakePayments) Name() string { return "fake" }
+
+func (f *fakePayments) CreateCheckout(ctx context.Context, req internal.CheckoutRequest) (*internal.Checkout, error) {
+	f.checkouts = append(f.checkouts, req)
+	return &internal.Checkout{SessionID: "cs_" + req.Reservation.ID.String(), URL: "https://pay.example.com/" + req.Reservation.ID.String()}, nil
+}
+
+func (f *fakePayments) ParseWebhook(payload []byte, header http.Header, now time.Time) (*internal.PaymentEvent, error) {
+	if header.Get("X-Signature") != "ok" {
+		return nil, internal.ErrWebhookSignature
+	}
+	return f.event, nil
+}
+
+func (f *fakePayments) Refund(ctx context.Context, reservationID uuid.UUID, paymentID string) error {
+	f.refunds = append(f.refunds, paymentID)
+	return nil
+}
+
+func TestTicketPayments(t *testing.T) {
+	start := time.Date(2025, 9, 15, 20, 0, 0, 0, time.UTC)
+	concert := internal.EventDB{ID: uuid.New(), Title: "Concert", StartTime: start, EndTime: start.Add(2 * time.Hour), Status: "approved"}
+	events := &eventsByID{byID: map[uuid.UUID]internal.EventDB{concert.ID: concert}}
+	tickets := &fakeTicketRepository{quota: 4, price: 1500}
+	payments := &fakePayments{}
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		if r.Header.Get("X-User") == "" {
+			return nil, nil
+		}
+		return &internal.Principal{UserID: r.Header.Get("X-User"), Scopes: []string{internal.ScopeEventsRead}}, nil
+	}
+	cfg := internal.Config{APIKey: "admin-secret", TicketHold: 10 * time.Minute, PaymentReturnURL: "https://shop.example.com/{event}/{reservation}"}
+	srv, err := NewServer(cfg, Dependencies{Events: events, Tickets: tickets, Payments: payments, Auth: hook})
+	require.NoError(t, err)
+	do := func(method, path, user string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"quantity": 2}`))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if user != "" {
+			req.Header.Set("X-User", user)
+		}
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	base := "/events/" + concert.ID.String() + "/tickets"
+	webhook := func(event internal.PaymentEvent) int {
+		payments.event = &event
+		return do(http.MethodPost, "/payments/webhook", "", http.Header{"X-Signature": {"ok"}}).Code
+	}
+
+	// Reserving a paid ticket hands out the checkout page
+	rec := do(http.MethodPost, base, "alice", nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var held internal.TicketReservation
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &held))
+	assert.Equal(t, internal.TicketStatusHeld, held.Status)
+	require.NotNil(t, held.CheckoutURL)
+	assert.Equal(t, "https://pay.example.com/"+held.ID.String(), *held.CheckoutURL)
+	require.Len(t, payments.checkouts, 1)
+	assert.Equal(t, "https://shop.example.com/"+concert.ID.String()+"/"+held.ID.String(), payments.checkouts[0].ReturnURL)
+	assert.Equal(t, "Concert", payments.checkouts[0].EventTitle)
+
+	// The webhook is public but signed
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/payments/webhook", "", nil).Code)
+	assert.Equal(t, http.StatusNoContent, webhook(internal.PaymentEvent{Type: internal.PaymentCompleted, EventID: concert.ID, ReservationID: held.ID, PaymentID: "pi_1"}))
+	paid, err := tickets.GetReservation(context.Background(), concert.ID, held.ID)
+	require.NoError(t, err)
+	assert.Equal(t, internal.TicketStatusConfirmed, paid.Status)
+	assert.Equal(t, "pi_1", *paid.PaymentID)
+
+	// Paid reservations are refunded by an admin rather than cancelled
+	assert.Equal(t, http.StatusConflict, do(http.MethodDelete, base+"/"+held.ID.String(), "alice", nil).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, base+"/"+held.ID.String()+"/refund", "alice", nil).Code)
+	admin := http.Header{"X-Api-Key": {"admin-secret"}}
+	assert.Equal(t, http.StatusNoContent, do(http.MethodPost, base+"/"+held.ID.String()+"/refund", "", admin).Code)
+	assert.Equal(t, []string{"pi_1"}, payments.refunds)
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, base+"/"+held.ID.String()+"/refund", "", admin).Code)
+
+	// An expired checkout releases the tickets; paying it late is refunded
+	rec = do(http.MethodPost, base, "bob", nil)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var late internal.TicketReservation
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &late))
+	assert.Equal(t, http.StatusNoContent, webhook(internal.PaymentEvent{Type: internal.PaymentExpired, EventID: concert.ID, ReservationID: late.ID}))
+	assert.Equal(t, 0, tickets.reserved())
+	assert.Equal(t, http.StatusNoContent, webhook(internal.PaymentEvent{Type: internal.PaymentCompleted, EventID: concert.ID, ReservationID: late.ID, PaymentID: "pi_2"}))
+	assert.Equal(t, []string{"pi_1", "pi_2"}, payments.refunds)
+}
+
+// failingCheckout cannot reach the provider
+type failingCheckout struct{ fakePayments }
+
+func (f *failingCheckout) CreateCheckout(ctx context.Context, req internal.CheckoutRequest) (*internal.Checkout, error) {
+	return nil, errors.New("provider down")
+}
+
+func TestReserveTicketsReleasesTicketsWhenCheckoutFails(t *testing.T) {
+	concert := internal.EventDB{ID: uuid.New(), Title: "Concert", StartTime: time.Now(), EndTime: time.Now().Add(time.Hour), Status: "approved"}
+	tickets := &fakeTicketRepository{quota: 4, price: 1500}
+	srv, err := NewServer(internal.Config{TicketHold: time.Minute}, Dependencies{
+		Events:   &eventsByID{byID: map[uuid.UUID]internal.EventDB{concert.ID: concert}},
+		Tickets:  tickets,
+		Payments: &failingCheckout{},
+	})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/events/"+concert.ID.String()+"/tickets", strings.NewReader(`{}`))
+	rec := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.Equal(t, 0, tickets.reserved())
+}
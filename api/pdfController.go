@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxAgendaRange bounds the period a printed agenda may cover
+const maxAgendaRange = 366 * 24 * time.Hour
+
+// defaultAgendaRange is the period printed when to is not given
+const defaultAgendaRange = 7 * 24 * time.Hour
+
+// parseAgendaTime reads an RFC 3339 time or a YYYY-MM-DD date, which starts at
+// midnight in loc
+func parseAgendaTime(v string, loc *time.Location) (time.Time, error) {
+	if t, err := internal.ParseTime(v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, v, loc)
+}
+
+// writePDF sends a rendered PDF, shown inline by browsers under filename
+func writePDF(w http.ResponseWriter, filename string, pdf []byte) {
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, filename))
+	w.Header().Set("Content-Length", fmt.Sprint(len(pdf)))
+	w.Write(pdf)
+}
+
+// ExportAgendaPDF handles GET /events/export.pdf?from=&to=&tz=&title=, a printable
+// agenda of the events the caller can list. from defaults to today and to to a
+// week after from.
+func (ec *EventController) ExportAgendaPDF(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	query := r.URL.Query()
+	loc := displayLocation(r)
+	now := time.Now()
+	from := time.Date(now.In(loc).Year(), now.In(loc).Month(), now.In(loc).Day(), 0, 0, 0, 0, loc)
+	if v := query.Get("from"); v != "" {
+		t, err := parseAgendaTime(v, loc)
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "from must be an RFC 3339 time or a YYYY-MM-DD date")
+			return
+		}
+		from = t
+	}
+	to := from.Add(defaultAgendaRange)
+	if v := query.Get("to"); v != "" {
+		t, err := parseAgendaTime(v, loc)
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "to must be an RFC 3339 time or a YYYY-MM-DD date")
+			return
+		}
+		to = t
+	}
+	if !to.After(from) || to.Sub(from) > maxAgendaRange {
+		httpError(w, r, http.StatusBadRequest, "to must be after from and at most %d days later", int(maxAgendaRange.Hours()/24))
+		return
+	}
+
+	events, err := ec.eventRepo.GetEventsBetween(ctx, from, to)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting events between", "Failed to get events")
+		return
+	}
+
+	lang := language(r)
+	title := query.Get("title")
+	if title == "" {
+		title = internal.Translate(lang, "Agenda")
+	}
+	events, _ = redactEvents(ctx, r, ec.redactor, listed(r, events))
+	var pdf bytes.Buffer
+	if err := internal.RenderAgendaPDF(&pdf, title, from, to, events, lang, loc, now); err != nil {
+		log.Printf("Error rendering agenda: %v", err)
+		httpError(w, r, http.StatusInternalServerError, "Failed to export events")
+		return
+	}
+	writePDF(w, fmt.Sprintf("agenda-%s.pdf", from.In(loc).Format(time.DateOnly)), pdf.Bytes())
+}
+
+// ExportEventPDF handles GET /events/{id}/export.pdf?tz=, a printable page of one event
+func (ec *EventController) ExportEventPDF(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := internal.ParseEventID(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+	event, err := ec.eventRepo.GetEventByID(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting event by ID", "Failed to get event")
+		return
+	}
+	if !visible(r, *event) {
+		httpError(w, r, http.StatusNotFound, "Event not found")
+		return
+	}
+
+	shown, _ := redactEvents(ctx, r, ec.redactor, []internal.EventDB{*event})
+	var pdf bytes.Buffer
+	if err := internal.RenderEventPDF(&pdf, shown[0], language(r), displayLocation(r), time.Now()); err != nil {
+		log.Printf("Error rendering event %s: %v", id, err)
+		httpError(w, r, http.StatusInternalServerError, "Failed to export events")
+		return
+	}
+	writePDF(w, fmt.Sprintf("event-%s.pdf", event.ID), pdf.Bytes())
+}
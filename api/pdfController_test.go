@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventsBetween records the range asked for and serves the events of eventsByID
+type eventsBetween struct {
+	eventsByID
+	from, to time.Time
+}
+
+func (f *eventsBetween) GetEventsBetween(ctx context.Context, from, to time.Time) ([]internal.EventDB, error) {
+	f.from, f.to = from, to
+	return f.events, nil
+}
+
+func TestExportPDF(t *testing.T) {
+	start := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+	event := internal.EventDB{ID: uuid.New(), Title: "Keynote", StartTime: start, EndTime: start.Add(time.Hour)}
+	repo := &eventsBetween{eventsByID: eventsByID{
+		fakeEventRepository: fakeEventRepository{events: []internal.EventDB{event}},
+		byID:                map[uuid.UUID]internal.EventDB{event.ID: event},
+	}}
+	srv, err := NewServer(internal.Config{}, Dependencies{Events: repo})
+	require.NoError(t, err)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/events/export.pdf?from=2025-09-15&tz=Europe/Madrid")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `inline; filename="agenda-2025-09-15.pdf"`, rec.Header().Get("Content-Disposition"))
+	assert.Contains(t, rec.Body.String(), "(Keynote)")
+	assert.Equal(t, "2025-09-14T22:00:00Z", repo.from.UTC().Format(time.RFC3339), "dates start at midnight in tz")
+	assert.Equal(t, 7*24*time.Hour, repo.to.Sub(repo.from))
+
+	rec = get("/events/" + event.ID.String() + "/export.pdf")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "(Keynote)")
+
+	assert.Equal(t, http.StatusBadRequest, get("/events/export.pdf?from=tomorrow").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/events/export.pdf?from=2025-09-15&to=2025-09-01").Code)
+	assert.Equal(t, http.StatusNotFound, get("/events/"+uuid.NewString()+"/export.pdf").Code)
+}
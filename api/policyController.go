@@ -0,0 +1,272 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// PolicyController handles HTTP requests for policy rules
+type PolicyController struct {
+	policyRepo internal.PolicyRepositoryInterface
+	engine     *internal.PolicyEngine
+}
+
+// NewPolicyController creates a new policy controller. engine is told when rules change
+// and evaluates test requests.
+func NewPolicyController(policyRepo internal.PolicyRepositoryInterface, engine *internal.PolicyEngine) *PolicyController {
+	return &PolicyController{policyRepo: policyRepo, engine: engine}
+}
+
+// RegisterRoutes adds the policy endpoints to router
+func (pc *PolicyController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/policies", requireAdmin(pc.CreatePolicy)).Methods("POST")
+	router.HandleFunc("/policies", requireAdmin(pc.GetPolicies)).Methods("GET")
+	router.HandleFunc("/policies/test", requireAdmin(pc.TestPolicy)).Methods("POST")
+	router.HandleFunc("/policies/{id}", requireAdmin(pc.GetPolicy)).Methods("GET")
+	router.HandleFunc("/policies/{id}", requireAdmin(pc.UpdatePolicy)).Methods("PATCH")
+	router.HandleFunc("/policies/{id}", requireAdmin(pc.DeletePolicy)).Methods("DELETE")
+}
+
+type createPolicyInput struct {
+	Name       string     `json:"name"`
+	Expression string     `json:"expression"`
+	Message    string     `json:"message"`
+	CalendarID *uuid.UUID `json:"calendar_id"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled"`
+}
+
+// updatePolicyInput holds the fields PATCH may change. A calendar_id of null makes the
+// rule apply to every calendar; omit it to keep the current one.
+type updatePolicyInput struct {
+	Name       *string         `json:"name"`
+	Expression *string         `json:"expression"`
+	Message    *string         `json:"message"`
+	CalendarID json.RawMessage `json:"calendar_id"`
+	Enabled    *bool           `json:"enabled"`
+}
+
+// testPolicyInput is an expression and a sample event to run it against
+type testPolicyInput struct {
+	Expression string           `json:"expression"`
+	Event      internal.EventDB `json:"event"`
+}
+
+// testPolicyResult is the outcome of POST /policies/test
+type testPolicyResult struct {
+	Allowed bool   `json:"allowed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// CreatePolicy handles POST /policies
+func (pc *PolicyController) CreatePolicy(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in createPolicyInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	rule := internal.PolicyRule{
+		ID:         uuid.New(),
+		Name:       strings.TrimSpace(in.Name),
+		Expression: strings.TrimSpace(in.Expression),
+		Message:    strings.TrimSpace(in.Message),
+		CalendarID: in.CalendarID,
+		Enabled:    in.Enabled == nil || *in.Enabled,
+	}
+	if p := internal.PrincipalFromContext(r.Context()); p != nil {
+		rule.CreatedBy = p.UserID
+	}
+	if !pc.preparePolicy(w, r, &rule) {
+		return
+	}
+
+	created, err := pc.policyRepo.CreatePolicyRule(ctx, rule)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "creating policy rule", "Failed to create policy rule")
+		return
+	}
+	pc.engine.Invalidate()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// preparePolicy validates a rule and compiles its expression.
+// It returns false when the response has already been written.
+func (pc *PolicyController) preparePolicy(w http.ResponseWriter, r *http.Request, rule *internal.PolicyRule) bool {
+	if rule.Name == "" || len(rule.Name) > 100 {
+		httpError(w, r, http.StatusBadRequest, "name is required and must be <= 100 characters")
+		return false
+	}
+	if rule.Expression == "" {
+		httpError(w, r, http.StatusBadRequest, "expression is required")
+		return false
+	}
+	if _, err := pc.engine.Compile(rule.Expression); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid expression: %v", err)
+		return false
+	}
+	if rule.Message == "" {
+		rule.Message = "event violates policy " + rule.Name
+	}
+	return true
+}
+
+// GetPolicies handles GET /policies
+func (pc *PolicyController) GetPolicies(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	rules, err := pc.policyRepo.ListPolicyRules(ctx)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing policy rules", "Failed to get policy rules")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+// GetPolicy handles GET /policies/{id}
+func (pc *PolicyController) GetPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	rule := pc.loadPolicy(ctx, w, r)
+	if rule == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rule)
+}
+
+// UpdatePolicy handles PATCH /policies/{id}
+func (pc *PolicyController) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in updatePolicyInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	rule := pc.loadPolicy(ctx, w, r)
+	if rule == nil {
+		return
+	}
+	if in.Name != nil {
+		rule.Name = strings.TrimSpace(*in.Name)
+	}
+	if in.Expression != nil {
+		rule.Expression = strings.TrimSpace(*in.Expression)
+	}
+	if in.Message != nil {
+		rule.Message = strings.TrimSpace(*in.Message)
+	}
+	if in.CalendarID != nil {
+		var calendarID *uuid.UUID
+		if err := json.Unmarshal(in.CalendarID, &calendarID); err != nil {
+			httpError(w, r, http.StatusBadRequest, "invalid calendar_id: %v", err)
+			return
+		}
+		rule.CalendarID = calendarID
+	}
+	if in.Enabled != nil {
+		rule.Enabled = *in.Enabled
+	}
+	if !pc.preparePolicy(w, r, rule) {
+		return
+	}
+
+	updated, err := pc.policyRepo.UpdatePolicyRule(ctx, *rule)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "updating policy rule", "Failed to update policy rule")
+		return
+	}
+	pc.engine.Invalidate()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeletePolicy handles DELETE /policies/{id}
+func (pc *PolicyController) DeletePolicy(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	if err := pc.policyRepo.DeletePolicyRule(ctx, id); err != nil {
+		repositoryError(ctx, w, r, err, "deleting policy rule", "Failed to delete policy rule")
+		return
+	}
+	pc.engine.Invalidate()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestPolicy handles POST /policies/test: it evaluates an expression against a sample
+// event without storing anything, so rule authors can try a rule before saving it.
+// Expressions that do not compile are a 400; evaluation errors are reported in the result.
+func (pc *PolicyController) TestPolicy(w http.ResponseWriter, r *http.Request) {
+	var in testPolicyInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	program, err := pc.engine.Compile(strings.TrimSpace(in.Expression))
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid expression: %v", err)
+		return
+	}
+
+	var result testPolicyResult
+	result.Allowed, err = pc.engine.Evaluate(program, in.Event)
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// loadPolicy fetches the rule named in the URL, writing an error when it fails
+func (pc *PolicyController) loadPolicy(ctx context.Context, w http.ResponseWriter, r *http.Request) *internal.PolicyRule {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return nil
+	}
+
+	rule, err := pc.policyRepo.GetPolicyRule(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting policy rule", "Failed to get policy rule")
+		return nil
+	}
+	return rule
+}
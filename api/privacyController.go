@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// PrivacyController lets users take out all their data and erase their account
+type PrivacyController struct {
+	privacy    internal.PrivacyRepositoryInterface
+	operations internal.OperationRepositoryInterface
+	scheduler  *internal.Scheduler
+	storage    internal.Storage
+	covers     *internal.CoverStore
+	twoFactor  internal.TwoFactorRepositoryInterface
+	lockout    *internal.AuthLockout
+}
+
+// NewPrivacyController creates a new privacy controller keeping export archives in
+// storage. covers, twoFactor and lockout may be nil.
+func NewPrivacyController(privacy internal.PrivacyRepositoryInterface, operations internal.OperationRepositoryInterface, scheduler *internal.Scheduler, storage internal.Storage, covers *internal.CoverStore, twoFactor internal.TwoFactorRepositoryInterface, lockout *internal.AuthLockout) *PrivacyController {
+	return &PrivacyController{privacy: privacy, operations: operations, scheduler: scheduler, storage: storage, covers: covers, twoFactor: twoFactor, lockout: lockout}
+}
+
+// RegisterRoutes adds the privacy endpoints to router
+func (pc *PrivacyController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/me/export", pc.ExportUserData).Methods("POST")
+	router.HandleFunc("/me/export/{id}", pc.DownloadUserData).Methods("GET")
+	router.HandleFunc("/me", pc.DeleteMe).Methods("DELETE")
+}
+
+type erasureResponse struct {
+	UserID string `json:"user_id"`
+	// Erased counts the rows deleted or anonymized by kind of data
+	Erased map[string]int `json:"erased"`
+}
+
+// privacyUser returns the caller, writing an error when there is none or it is the
+// admin key, which has no data of its own
+func privacyUser(w http.ResponseWriter, r *http.Request) *internal.Principal {
+	p := caller(w, r)
+	if p != nil && p.Admin {
+		httpError(w, r, http.StatusForbidden, "the admin key has no personal data")
+		return nil
+	}
+	return p
+}
+
+// ExportUserData handles POST /me/export, queueing the archive of the caller's data.
+// The operation's result is where to download it once it succeeded.
+func (pc *PrivacyController) ExportUserData(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := privacyUser(w, r)
+	if p == nil {
+		return
+	}
+	input, err := internal.NewUserExportInput(p.UserID)
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "Failed to queue export")
+		return
+	}
+	op, err := pc.operations.CreateOperation(ctx, internal.Operation{
+		ID:        uuid.New(),
+		Kind:      internal.OperationExportUserData,
+		CreatedBy: p.UserID,
+	}, input)
+	if err != nil {
+		log.Printf("Error queueing %s: %v", internal.OperationExportUserData, err)
+		httpError(w, r, http.StatusInternalServerError, "Failed to queue export")
+		return
+	}
+	pc.scheduler.Wake()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/operations/"+op.ID.String())
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(op)
+}
+
+// DownloadUserData handles GET /me/export/{id}, the archive of a succeeded export of
+// the caller. Archives expire internal.UserExportRetention after they were written.
+func (pc *PrivacyController) DownloadUserData(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	p := privacyUser(w, r)
+	if p == nil {
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	op, err := pc.operations.GetOperation(ctx, id)
+	if errors.Is(err, internal.ErrOperationNotFound) || (err == nil && (op.Kind != internal.OperationExportUserData || op.CreatedBy != p.UserID || op.Status != internal.OperationSucceeded)) {
+		err = internal.ErrExportNotFound
+	}
+	if err == nil && op.FinishedAt != nil && time.Since(*op.FinishedAt) > internal.UserExportRetention {
+		if delErr := pc.storage.Delete(ctx, internal.UserExportKey(id)); delErr != nil && !errors.Is(delErr, internal.ErrObjectNotFound) {
+			log.Printf("Error deleting expired export %s: %v", id, delErr)
+		}
+		err = internal.ErrExportNotFound
+	}
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting export", "Failed to get export")
+		return
+	}
+
+	archive, err := pc.storage.Get(ctx, internal.UserExportKey(id))
+	if errors.Is(err, internal.ErrObjectNotFound) {
+		err = internal.ErrExportNotFound
+	}
+	if err != nil {
+		repositoryError(ctx, w, r, err, "reading export", "Failed to get export")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="export-%s.zip"`, id))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Write(archive)
+}
+
+// DeleteMe handles DELETE /me?confirm={user id}, erasing the caller's account: what
+// only they have is deleted and their name is removed from what they shared. It takes
+// a two-factor code when the caller enabled it, and ends every session.
+func (pc *PrivacyController) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	p := privacyUser(w, r)
+	if p == nil {
+		return
+	}
+	if r.URL.Query().Get("confirm") != p.UserID {
+		httpError(w, r, http.StatusBadRequest, "confirm must be your user ID")
+		return
+	}
+	if pc.twoFactor != nil {
+		enabled, err := internal.TwoFactorEnabled(ctx, pc.twoFactor, p.UserID)
+		if err != nil {
+			repositoryError(ctx, w, r, err, "getting two-factor enrollment", "Failed to delete account")
+			return
+		}
+		if enabled && !verifyTwoFactor(ctx, w, r, pc.twoFactor, pc.lockout, p.UserID) {
+			return
+		}
+	}
+
+	erasure, err := pc.privacy.EraseUserData(ctx, p.UserID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "erasing user data", "Failed to delete account")
+		return
+	}
+	log.Printf("Security: %s deleted their account", p.UserID)
+
+	// The account is gone; files left behind are only logged, as there is no one left
+	// to report them to
+	for _, id := range erasure.Exports {
+		if err := pc.storage.Delete(ctx, internal.UserExportKey(id)); err != nil && !errors.Is(err, internal.ErrObjectNotFound) {
+			log.Printf("Error deleting export %s: %v", id, err)
+		}
+	}
+	if pc.covers != nil {
+		for _, id := range erasure.Events {
+			if err := pc.covers.Delete(ctx, id); err != nil && !errors.Is(err, internal.ErrCoverNotFound) {
+				log.Printf("Error deleting cover of event %s: %v", id, err)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(erasureResponse{UserID: p.UserID, Erased: erasure.Rows})
+}
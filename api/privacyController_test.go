@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOperationRepository keeps operations in memory
+type fakeOperationRepository struct {
+	internal.OperationRepositoryInterface
+	ops map[uuid.UUID]internal.Operation
+}
+
+func (f *fakeOperationRepository) CreateOperation(ctx context.Context, op internal.Operation, input []byte) (*internal.Operation, error) {
+	op.Status, op.CreatedAt = internal.OperationPending, time.Now()
+	f.ops[op.ID] = op
+	return &op, nil
+}
+
+func (f *fakeOperationRepository) GetOperation(ctx context.Context, id uuid.UUID) (*internal.Operation, error) {
+	op, ok := f.ops[id]
+	if !ok {
+		return nil, internal.ErrOperationNotFound
+	}
+	return &op, nil
+}
+
+// erasedUsers records the users erased
+type erasedUsers struct {
+	erased []string
+}
+
+func (e *erasedUsers) ExportUserData(ctx context.Context, userID string) (*internal.UserData, error) {
+	return &internal.UserData{UserID: userID}, nil
+}
+
+func (e *erasedUsers) EraseUserData(ctx context.Context, userID string) (*internal.UserErasure, error) {
+	if userID == "owner" {
+		return nil, internal.ErrLastOwner
+	}
+	e.erased = append(e.erased, userID)
+	return &internal.UserErasure{Rows: map[string]int{"tokens": 2}}, nil
+}
+
+func TestPrivacy(t *testing.T) {
+	ops := &fakeOperationRepository{ops: map[uuid.UUID]internal.Operation{}}
+	privacy := &erasedUsers{}
+	storage := &internal.LocalStorage{Dir: t.TempDir()}
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		user := r.Header.Get("X-User")
+		return &internal.Principal{UserID: user, Scopes: []string{internal.ScopeEventsRead}, Admin: user == adminUserID}, nil
+	}
+	srv, err := NewServer(internal.Config{}, Dependencies{
+		Events: &fakeEventRepository{}, Operations: ops, Privacy: privacy, Storage: storage,
+		Scheduler: internal.NewScheduler(nil, ops), Auth: hook,
+	})
+	require.NoError(t, err)
+	do := func(method, path, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/me/export", "ana")
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	var op internal.Operation
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &op))
+	assert.Equal(t, internal.OperationExportUserData, op.Kind)
+	assert.Equal(t, "/operations/"+op.ID.String(), rec.Header().Get("Location"))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/me/export", "admin").Code)
+
+	// The archive is downloadable by its owner once the export succeeded, until it expires
+	download := "/me/export/" + op.ID.String()
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, download, "ana").Code)
+	require.NoError(t, storage.Put(context.Background(), internal.UserExportKey(op.ID), []byte("PK")))
+	finished := time.Now()
+	op.Status, op.FinishedAt = internal.OperationSucceeded, &finished
+	ops.ops[op.ID] = op
+	rec = do(http.MethodGet, download, "ana")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "PK", rec.Body.String())
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, download, "bob").Code)
+
+	expired := time.Now().Add(-internal.UserExportRetention - time.Minute)
+	op.FinishedAt = &expired
+	ops.ops[op.ID] = op
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, download, "ana").Code)
+	_, err = storage.Get(context.Background(), internal.UserExportKey(op.ID))
+	assert.ErrorIs(t, err, internal.ErrObjectNotFound)
+
+	// Deleting the account takes the user ID as confirmation
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodDelete, "/me", "ana").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodDelete, "/me?confirm=bob", "ana").Code)
+	rec = do(http.MethodDelete, "/me?confirm=ana", "ana")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"user_id": "ana", "erased": {"tokens": 2}}`, rec.Body.String())
+	assert.Equal(t, http.StatusConflict, do(http.MethodDelete, "/me?confirm=owner", "owner").Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/me?confirm=admin", "admin").Code)
+	assert.Equal(t, []string{"ana"}, privacy.erased)
+}
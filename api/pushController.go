@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// PushController handles the caller's browser push subscriptions
+type PushController struct {
+	subscriptions internal.PushSubscriptionRepositoryInterface
+	webPush       *internal.WebPush
+}
+
+// NewPushController creates a new push controller
+func NewPushController(subscriptions internal.PushSubscriptionRepositoryInterface, webPush *internal.WebPush) *PushController {
+	return &PushController{subscriptions: subscriptions, webPush: webPush}
+}
+
+// RegisterRoutes adds the push subscription endpoints to router
+func (pc *PushController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/push/vapid-public-key", requireScope(internal.ScopeEventsRead, pc.GetPublicKey)).Methods("GET")
+	router.HandleFunc("/push/subscriptions", requireScope(internal.ScopeEventsRead, pc.Subscribe)).Methods("POST")
+	router.HandleFunc("/push/subscriptions", requireScope(internal.ScopeEventsRead, pc.GetSubscriptions)).Methods("GET")
+	router.HandleFunc("/push/subscriptions", requireScope(internal.ScopeEventsRead, pc.UnsubscribeEndpoint)).Methods("DELETE")
+	router.HandleFunc("/push/subscriptions/{id}", requireScope(internal.ScopeEventsRead, pc.Unsubscribe)).Methods("DELETE")
+}
+
+// pushSubscriptionInput is the browser's PushSubscription.toJSON()
+type pushSubscriptionInput struct {
+	Endpoint string            `json:"endpoint"`
+	Keys     internal.PushKeys `json:"keys"`
+	// ExpirationTime is sent by browsers and ignored; expired subscriptions are removed
+	// when the push service reports them gone
+	ExpirationTime *int64 `json:"expirationTime"`
+}
+
+type unsubscribeInput struct {
+	Endpoint string `json:"endpoint"`
+}
+
+// GetPublicKey handles GET /push/vapid-public-key, the applicationServerKey browsers
+// subscribe with
+func (pc *PushController) GetPublicKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"public_key": pc.webPush.PublicKey()})
+}
+
+// Subscribe handles POST /push/subscriptions
+func (pc *PushController) Subscribe(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in pushSubscriptionInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	if u, err := url.Parse(in.Endpoint); err != nil || u.Scheme != "https" || u.Host == "" {
+		httpError(w, r, http.StatusBadRequest, "endpoint must be an https URL")
+		return
+	}
+	if msg := in.Keys.Validate(); msg != "" {
+		httpError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+
+	sub, err := pc.subscriptions.SavePushSubscription(ctx, internal.PushSubscription{
+		ID:        uuid.New(),
+		UserID:    principalID(r),
+		Endpoint:  in.Endpoint,
+		Keys:      in.Keys,
+		UserAgent: r.UserAgent(),
+	})
+	if err != nil {
+		repositoryError(ctx, w, r, err, "saving push subscription", "Failed to save push subscription")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sub)
+}
+
+// GetSubscriptions handles GET /push/subscriptions
+func (pc *PushController) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	subs, err := pc.subscriptions.ListPushSubscriptions(ctx, principalID(r))
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing push subscriptions", "Failed to get push subscriptions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subs)
+}
+
+// Unsubscribe handles DELETE /push/subscriptions/{id}
+func (pc *PushController) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+	pc.unsubscribe(ctx, w, r, id)
+}
+
+// UnsubscribeEndpoint handles DELETE /push/subscriptions with the browser's
+// {"endpoint": "..."}, for clients that did not keep the subscription ID
+func (pc *PushController) UnsubscribeEndpoint(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in unsubscribeInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	subs, err := pc.subscriptions.ListPushSubscriptions(ctx, principalID(r))
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing push subscriptions", "Failed to delete push subscription")
+		return
+	}
+	for _, sub := range subs {
+		if sub.Endpoint == in.Endpoint {
+			pc.unsubscribe(ctx, w, r, sub.ID)
+			return
+		}
+	}
+	repositoryError(ctx, w, r, internal.ErrPushSubscriptionNotFound, "deleting push subscription", "Failed to delete push subscription")
+}
+
+func (pc *PushController) unsubscribe(ctx context.Context, w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	if err := pc.subscriptions.DeletePushSubscription(ctx, principalID(r), id); err != nil {
+		repositoryError(ctx, w, r, err, "deleting push subscription", "Failed to delete push subscription")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"taller_challenge/internal"
+	"time"
+)
+
+type quickAddInput struct {
+	Text     string `json:"text"`
+	Timezone string `json:"timezone"`
+	Create   bool   `json:"create"`
+}
+
+// QuickAddEvent handles POST /events/quickadd
+// The parsed event is returned as a draft unless "create" is true, in which case it is stored.
+// Dates are read in "timezone", or in the caller's preferred time zone when it is omitted.
+func (ec *EventController) QuickAddEvent(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in quickAddInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	var loc *time.Location
+	var err error
+	if in.Timezone != "" {
+		loc, err = time.LoadLocation(in.Timezone)
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "unknown timezone %q", in.Timezone)
+			return
+		}
+	} else if loc, err = preferredLocation(ctx, ec.preferences, r); err != nil {
+		repositoryError(ctx, w, r, err, "getting preferences", "Failed to create event")
+		return
+	}
+
+	in.Text = internal.SanitizeText(in.Text, false)
+	if !ec.screenField(r, "text", &in.Text) {
+		httpError(w, r, http.StatusBadRequest, "input rejected by security policy")
+		return
+	}
+
+	draft, err := internal.ParseQuickAdd(in.Text, time.Now().In(loc))
+	if err != nil {
+		httpError(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	event := createEventInput{
+		Title:     draft.Title,
+		StartTime: draft.StartTime,
+		EndTime:   draft.EndTime,
+	}
+	if msg := validateEventInput(&event); msg != "" {
+		httpError(w, r, http.StatusUnprocessableEntity, msg)
+		return
+	}
+
+	if !in.Create {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(draft)
+		return
+	}
+
+	ec.createEvent(ctx, w, r, event, nil)
+}
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxRemindersPerEvent bounds the reminders one user sets on an event
+const maxRemindersPerEvent = 10
+
+// ReminderController handles HTTP requests for event reminders. Reminders are
+// personal: users see and change only their own, admins everyone's.
+type ReminderController struct {
+	reminders internal.ReminderRepositoryInterface
+	events    internal.EventRepositoryInterface
+	channels  []string
+}
+
+// NewReminderController creates a new reminder controller accepting reminders on channels
+func NewReminderController(reminders internal.ReminderRepositoryInterface, events internal.EventRepositoryInterface, channels []string) *ReminderController {
+	return &ReminderController{reminders: reminders, events: events, channels: channels}
+}
+
+// RegisterRoutes adds the reminder endpoints to router
+func (rc *ReminderController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/events/{id}/reminders", requireScope(internal.ScopeEventsRead, rc.CreateReminder)).Methods("POST")
+	router.HandleFunc("/events/{id}/reminders", requireScope(internal.ScopeEventsRead, rc.GetReminders)).Methods("GET")
+	router.HandleFunc("/events/{id}/reminders/{reminderId}", requireScope(internal.ScopeEventsRead, rc.GetReminder)).Methods("GET")
+	router.HandleFunc("/events/{id}/reminders/{reminderId}", requireScope(internal.ScopeEventsRead, rc.UpdateReminder)).Methods("PATCH")
+	router.HandleFunc("/events/{id}/reminders/{reminderId}", requireScope(internal.ScopeEventsRead, rc.DeleteReminder)).Methods("DELETE")
+}
+
+type createReminderInput struct {
+	OffsetMinutes int    `json:"offset_minutes"`
+	Channel       string `json:"channel"`
+	Target        string `json:"target"`
+}
+
+type updateReminderInput struct {
+	OffsetMinutes *int    `json:"offset_minutes"`
+	Channel       *string `json:"channel"`
+	Target        *string `json:"target"`
+}
+
+// ownsReminder reports whether the caller may see and change rem
+func ownsReminder(r *http.Request, rem internal.Reminder) bool {
+	p := internal.PrincipalFromContext(r.Context())
+	return p == nil || p.Admin || p.UserID == rem.OwnerID
+}
+
+// CreateReminder handles POST /events/{id}/reminders
+func (rc *ReminderController) CreateReminder(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in createReminderInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	event := loadVisibleEvent(ctx, w, r, rc.events)
+	if event == nil {
+		return
+	}
+
+	rem := internal.Reminder{
+		ID:            uuid.New(),
+		EventID:       event.ID,
+		OwnerID:       principalID(r),
+		OffsetMinutes: in.OffsetMinutes,
+		Channel:       strings.TrimSpace(in.Channel),
+		Target:        strings.TrimSpace(in.Target),
+		Language:      language(r),
+	}
+	if msg := internal.ValidateReminder(rem, *event, rc.channels, time.Now()); msg != "" {
+		httpError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+
+	existing, err := rc.reminders.ListReminders(ctx, event.ID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing reminders", "Failed to create reminder")
+		return
+	}
+	if len(ownReminders(r, existing, rem.OwnerID)) >= maxRemindersPerEvent {
+		httpError(w, r, http.StatusBadRequest, "at most %d reminders per event", maxRemindersPerEvent)
+		return
+	}
+
+	created, err := rc.reminders.CreateReminder(ctx, rem)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "creating reminder", "Failed to create reminder")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// ownReminders keeps the reminders of owner
+func ownReminders(r *http.Request, reminders []internal.Reminder, owner string) []internal.Reminder {
+	own := reminders[:0:0]
+	for _, rem := range reminders {
+		if rem.OwnerID == owner {
+			own = append(own, rem)
+		}
+	}
+	return own
+}
+
+// GetReminders handles GET /events/{id}/reminders
+func (rc *ReminderController) GetReminders(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	event := loadVisibleEvent(ctx, w, r, rc.events)
+	if event == nil {
+		return
+	}
+
+	reminders, err := rc.reminders.ListReminders(ctx, event.ID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing reminders", "Failed to get reminders")
+		return
+	}
+	visible := reminders[:0:0]
+	for _, rem := range reminders {
+		if ownsReminder(r, rem) {
+			visible = append(visible, rem)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visible)
+}
+
+// GetReminder handles GET /events/{id}/reminders/{reminderId}
+func (rc *ReminderController) GetReminder(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	_, rem := rc.loadReminder(ctx, w, r)
+	if rem == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rem)
+}
+
+// UpdateReminder handles PATCH /events/{id}/reminders/{reminderId}. A changed
+// reminder is sent again, even if it already was.
+func (rc *ReminderController) UpdateReminder(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in updateReminderInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	event, rem := rc.loadReminder(ctx, w, r)
+	if rem == nil {
+		return
+	}
+	if in.OffsetMinutes != nil {
+		rem.OffsetMinutes = *in.OffsetMinutes
+	}
+	if in.Channel != nil {
+		rem.Channel = strings.TrimSpace(*in.Channel)
+	}
+	if in.Target != nil {
+		rem.Target = strings.TrimSpace(*in.Target)
+	}
+	if msg := internal.ValidateReminder(*rem, *event, rc.channels, time.Now()); msg != "" {
+		httpError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+
+	updated, err := rc.reminders.UpdateReminder(ctx, *rem)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "updating reminder", "Failed to update reminder")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteReminder handles DELETE /events/{id}/reminders/{reminderId}
+func (rc *ReminderController) DeleteReminder(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	event, rem := rc.loadReminder(ctx, w, r)
+	if rem == nil {
+		return
+	}
+
+	if err := rc.reminders.DeleteReminder(ctx, event.ID, rem.ID); err != nil {
+		repositoryError(ctx, w, r, err, "deleting reminder", "Failed to delete reminder")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadReminder fetches the event and the caller's reminder named in the URL, writing
+// an error when either fails. Other users' reminders are reported as not found.
+func (rc *ReminderController) loadReminder(ctx context.Context, w http.ResponseWriter, r *http.Request) (*internal.EventDB, *internal.Reminder) {
+	reminderID, err := uuid.Parse(mux.Vars(r)["reminderId"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return nil, nil
+	}
+	event := loadVisibleEvent(ctx, w, r, rc.events)
+	if event == nil {
+		return nil, nil
+	}
+
+	rem, err := rc.reminders.GetReminder(ctx, event.ID, reminderID)
+	if err == nil && !ownsReminder(r, *rem) {
+		err = internal.ErrReminderNotFound
+	}
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting reminder", "Failed to get reminder")
+		return nil, nil
+	}
+	return event, rem
+}
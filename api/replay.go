@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"taller_challenge/internal"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// replayOmitBody are the routes whose request bodies carry credentials, or third-party
+// payloads whose signatures a replay cannot reproduce; replay logs never keep them
+var replayOmitBody = map[string]bool{
+	"/me/2fa":                    true,
+	"/me/2fa/confirm":            true,
+	"/me/2fa/recovery-codes":     true,
+	"/admin/users/{userId}/2fa":  true,
+	"/admin/ingest-sources":      true,
+	"/admin/ingest-sources/{id}": true,
+	"/invitations/accept":        true,
+	"/push/subscriptions":        true,
+	"/push/devices":              true,
+	"/payments/webhook":          true,
+	"/ingest/{source}":           true,
+}
+
+// maxReplayResult bounds how much of a response is read for its id
+const maxReplayResult = 64 << 10
+
+// replayingKey marks requests being recorded, so the operations /batch dispatches
+// through the router are not recorded a second time
+type replayingKey struct{}
+
+// replayMiddleware records every mutating request, its principal and its status in the
+// replay log. It runs after authentication, so the principal is known.
+func replayMiddleware(replay *internal.ReplayLog) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.Context().Value(replayingKey{}) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			entry := internal.ReplayEntry{
+				Time:      time.Now().UTC(),
+				RequestID: internal.RequestIDFromContext(r.Context()),
+				Method:    r.Method,
+				Path:      r.URL.RequestURI(),
+				Headers:   map[string]string{},
+			}
+			if p := internal.PrincipalFromContext(r.Context()); p != nil {
+				entry.UserID, entry.Admin, entry.Scopes = p.UserID, p.Admin, p.Scopes
+			}
+			for _, h := range internal.ReplayHeaders {
+				if v := r.Header.Get(h); v != "" {
+					entry.Headers[h] = v
+				}
+			}
+			route := r.URL.Path
+			if current := mux.CurrentRoute(r); current != nil {
+				if tpl, err := current.GetPathTemplate(); err == nil {
+					route = tpl
+				}
+			}
+			if replayOmitBody[route] {
+				entry.BodyOmitted = "credentials"
+			} else {
+				body, err := io.ReadAll(io.LimitReader(r.Body, internal.MaxReplayBody+1))
+				// The handler reads the body again, including what is past the limit
+				r.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+				switch {
+				case err != nil:
+					entry.BodyOmitted = "unreadable"
+				case len(body) > internal.MaxReplayBody:
+					entry.BodyOmitted = "too large"
+				case !utf8.Valid(body):
+					entry.BodyOmitted = "binary"
+				default:
+					entry.Body = string(body)
+				}
+			}
+
+			rec := &replayRecorder{statusRecorder: statusRecorder{ResponseWriter: w, status: http.StatusOK}}
+			next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), replayingKey{}, true)))
+			entry.Status = rec.status
+			entry.ResultID = internal.ReplayResultID(rec.Header().Get("Content-Type"), rec.head.Bytes())
+			replay.Record(entry)
+		})
+	}
+}
+
+// replayRecorder keeps the status and the beginning of a response
+type replayRecorder struct {
+	statusRecorder
+	head bytes.Buffer
+}
+
+func (rr *replayRecorder) Write(b []byte) (int, error) {
+	if room := maxReplayResult - rr.head.Len(); room > 0 {
+		rr.head.Write(b[:min(room, len(b))])
+	}
+	return rr.statusRecorder.Write(b)
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"taller_challenge/internal"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replay.log")
+	replayLog, err := internal.OpenReplayLog(path)
+	require.NoError(t, err)
+	cfg := internal.Config{APIKey: "admin-secret"}
+	srv, err := NewServer(cfg, Dependencies{Events: internal.NewMemoryEventRepository(nil), ReplayLog: replayLog})
+	require.NoError(t, err)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("X-API-Key", "admin-secret")
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := send(http.MethodPost, "/events", `{"title":"Standup","start_time":"2030-03-04T09:00:00Z","end_time":"2030-03-04T09:15:00Z"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var created internal.EventDB
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	send(http.MethodGet, "/events", "")
+	require.Equal(t, http.StatusOK, send(http.MethodPut, "/events/"+created.ID.String(), `{"title":"Daily standup","start_time":"2030-03-04T09:00:00Z","end_time":"2030-03-04T09:15:00Z"}`).Code)
+	require.Equal(t, http.StatusNotFound, send(http.MethodDelete, "/events/00000000-0000-4000-8000-000000000000", "").Code)
+	require.NoError(t, replayLog.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	entries, err := internal.ReadReplayLog(f)
+	require.NoError(t, err)
+	require.Len(t, entries, 3, "reads are not recorded")
+	assert.Equal(t, http.MethodPost, entries[0].Method)
+	assert.Equal(t, "/events", entries[0].Path)
+	assert.Equal(t, adminUserID, entries[0].UserID)
+	assert.True(t, entries[0].Admin)
+	assert.Equal(t, "application/json", entries[0].Headers["Content-Type"])
+	assert.Contains(t, entries[0].Body, `"title":"Standup"`)
+	assert.Equal(t, http.StatusCreated, entries[0].Status)
+	assert.Equal(t, created.ID.String(), entries[0].ResultID)
+	assert.Equal(t, http.StatusNotFound, entries[2].Status)
+
+	// Replayed into a fresh repository, the update reaches the event created anew
+	fresh := internal.NewMemoryEventRepository(nil)
+	target, err := NewServer(cfg, Dependencies{Events: fresh})
+	require.NoError(t, err)
+	replayer := internal.NewReplayer(target.Router)
+	for _, e := range entries {
+		out := replayer.Replay(context.Background(), e)
+		assert.False(t, out.Mismatch(), "%s %s: %d %s", e.Method, e.Path, out.Status, out.Body)
+	}
+	events, err := fresh.GetEvents(context.Background())
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "Daily standup", events[0].Title)
+	assert.NotEqual(t, created.ID, events[0].ID)
+}
+
+func TestReplayLogOmitsCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replay.log")
+	replayLog, err := internal.OpenReplayLog(path)
+	require.NoError(t, err)
+	handler := replayMiddleware(replayLog)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/invitations/accept", bytes.NewBufferString(`{"token":"secret"}`)))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/events/1/cover", bytes.NewReader([]byte{0xff, 0xd8, 0xff})))
+	require.NoError(t, replayLog.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+	entries, err := internal.ReadReplayLog(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "credentials", entries[0].BodyOmitted)
+	assert.Equal(t, "binary", entries[1].BodyOmitted)
+	assert.Equal(t, "body omitted: binary", internal.NewReplayer(handler).Replay(context.Background(), entries[1]).Skipped)
+}
@@ -0,0 +1,346 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxAvailabilityRange bounds the period one availability query covers
+const maxAvailabilityRange = 93 * 24 * time.Hour
+
+// ResourceController handles HTTP requests for bookable resources and the resources
+// booked for events. Admins manage resources; anyone who may change an event books them.
+type ResourceController struct {
+	resources internal.ResourceRepositoryInterface
+	events    internal.EventRepositoryInterface
+}
+
+// NewResourceController creates a new resource controller
+func NewResourceController(resources internal.ResourceRepositoryInterface, events internal.EventRepositoryInterface) *ResourceController {
+	return &ResourceController{resources: resources, events: events}
+}
+
+// RegisterRoutes adds the resource endpoints to router
+func (rc *ResourceController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/resources", requireAdmin(rc.CreateResource)).Methods("POST")
+	router.HandleFunc("/resources", requireScope(internal.ScopeEventsRead, rc.GetResources)).Methods("GET")
+	router.HandleFunc("/resources/{id}", requireScope(internal.ScopeEventsRead, rc.GetResource)).Methods("GET")
+	router.HandleFunc("/resources/{id}", requireAdmin(rc.UpdateResource)).Methods("PUT")
+	router.HandleFunc("/resources/{id}", requireAdmin(rc.DeleteResource)).Methods("DELETE")
+	router.HandleFunc("/resources/{id}/availability", requireScope(internal.ScopeEventsRead, rc.GetAvailability)).Methods("GET")
+	router.HandleFunc("/events/{id}/resources", requireScope(internal.ScopeEventsRead, rc.GetEventResources)).Methods("GET")
+	router.HandleFunc("/events/{id}/resources", requireScope(internal.ScopeEventsWrite, rc.SetEventResources)).Methods("PUT")
+}
+
+type resourceInput struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Capacity *int   `json:"capacity"`
+}
+
+// resource returns the resource described by in; kind defaults to room
+func (in resourceInput) resource(id uuid.UUID) internal.Resource {
+	res := internal.Resource{ID: id, Name: strings.TrimSpace(in.Name), Kind: strings.TrimSpace(in.Kind), Capacity: in.Capacity}
+	if res.Kind == "" {
+		res.Kind = internal.ResourceKindRoom
+	}
+	return res
+}
+
+type eventResourcesInput struct {
+	ResourceIDs []uuid.UUID `json:"resource_ids"`
+}
+
+// availabilityResponse is the bookings of a resource during a period and the time
+// left free between them
+type availabilityResponse struct {
+	Resource internal.Resource   `json:"resource"`
+	From     time.Time           `json:"from"`
+	To       time.Time           `json:"to"`
+	Busy     []internal.Booking  `json:"busy"`
+	Free     []internal.TimeSlot `json:"free"`
+}
+
+// CreateResource handles POST /resources
+func (rc *ResourceController) CreateResource(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in resourceInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	res := in.resource(uuid.New())
+	if msg := internal.ValidateResource(res); msg != "" {
+		httpError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+
+	created, err := rc.resources.CreateResource(ctx, res)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "creating resource", "Failed to create resource")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// GetResources handles GET /resources?kind=&min_capacity=&from=&to=. With from and
+// to, only the resources free for the whole period are listed.
+func (rc *ResourceController) GetResources(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	query := r.URL.Query()
+	filter := internal.ResourceFilter{Kind: query.Get("kind")}
+	if v := query.Get("min_capacity"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			httpError(w, r, http.StatusBadRequest, "min_capacity must be a non-negative integer")
+			return
+		}
+		filter.MinCapacity = n
+	}
+	if query.Get("from") != "" || query.Get("to") != "" {
+		slot, ok := availabilitySlot(w, r)
+		if !ok {
+			return
+		}
+		filter.FreeDuring = &slot
+	}
+
+	resources, err := rc.resources.ListResources(ctx, filter)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing resources", "Failed to get resources")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resources)
+}
+
+// availabilitySlot reads the from and to parameters, RFC 3339 times or dates starting
+// at midnight in tz, writing an error when they are missing or invalid
+func availabilitySlot(w http.ResponseWriter, r *http.Request) (internal.TimeSlot, bool) {
+	query := r.URL.Query()
+	loc := displayLocation(r)
+	if query.Get("from") == "" || query.Get("to") == "" {
+		httpError(w, r, http.StatusBadRequest, "from and to are required")
+		return internal.TimeSlot{}, false
+	}
+	from, err := parseAgendaTime(query.Get("from"), loc)
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "from must be an RFC 3339 time or a YYYY-MM-DD date")
+		return internal.TimeSlot{}, false
+	}
+	to, err := parseAgendaTime(query.Get("to"), loc)
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "to must be an RFC 3339 time or a YYYY-MM-DD date")
+		return internal.TimeSlot{}, false
+	}
+	if !to.After(from) || to.Sub(from) > maxAvailabilityRange {
+		httpError(w, r, http.StatusBadRequest, "to must be after from and at most %d days later", int(maxAvailabilityRange.Hours()/24))
+		return internal.TimeSlot{}, false
+	}
+	return internal.TimeSlot{Start: from, End: to}, true
+}
+
+// GetResource handles GET /resources/{id}
+func (rc *ResourceController) GetResource(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	res := rc.loadResource(ctx, w, r)
+	if res == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// UpdateResource handles PUT /resources/{id}
+func (rc *ResourceController) UpdateResource(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+	var in resourceInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	res := in.resource(id)
+	if msg := internal.ValidateResource(res); msg != "" {
+		httpError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+
+	updated, err := rc.resources.UpdateResource(ctx, res)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "updating resource", "Failed to update resource")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteResource handles DELETE /resources/{id}. Resources still booked by an event
+// cannot be deleted.
+func (rc *ResourceController) DeleteResource(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	if err := rc.resources.DeleteResource(ctx, id); err != nil {
+		repositoryError(ctx, w, r, err, "deleting resource", "Failed to delete resource")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAvailability handles GET /resources/{id}/availability?from=&to=&tz=, the bookings
+// of a resource during a period and the free time between them
+func (rc *ResourceController) GetAvailability(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	slot, ok := availabilitySlot(w, r)
+	if !ok {
+		return
+	}
+	res := rc.loadResource(ctx, w, r)
+	if res == nil {
+		return
+	}
+
+	bookings, err := rc.resources.ListBookings(ctx, res.ID, slot)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing bookings", "Failed to get bookings")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(availabilityResponse{
+		Resource: *res,
+		From:     slot.Start,
+		To:       slot.End,
+		Busy:     bookings,
+		Free:     internal.FreeSlots(slot.Start, slot.End, bookings),
+	})
+}
+
+// GetEventResources handles GET /events/{id}/resources
+func (rc *ResourceController) GetEventResources(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	event := loadVisibleEvent(ctx, w, r, rc.events)
+	if event == nil {
+		return
+	}
+
+	resources, err := rc.resources.ListEventResources(ctx, event.ID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing event resources", "Failed to get resources")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resources)
+}
+
+// SetEventResources handles PUT /events/{id}/resources, replacing the resources booked
+// for the event. A resource booked by another event at the time is answered with a 409
+// naming that event; the database's exclusion constraint catches bookings racing
+// this check.
+func (rc *ResourceController) SetEventResources(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in eventResourcesInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	ids := make([]uuid.UUID, 0, len(in.ResourceIDs))
+	seen := map[uuid.UUID]bool{}
+	for _, id := range in.ResourceIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	event := loadVisibleEvent(ctx, w, r, rc.events)
+	if event == nil {
+		return
+	}
+
+	if len(ids) > 0 {
+		conflicts, err := rc.resources.Conflicts(ctx, ids, internal.TimeSlot{Start: event.StartTime, End: event.EndTime}, event.ID)
+		if err != nil {
+			repositoryError(ctx, w, r, err, "checking resource conflicts", "Failed to book resources")
+			return
+		}
+		if len(conflicts) > 0 {
+			c := conflicts[0]
+			httpError(w, r, http.StatusConflict, "resource %s is already booked by event %s from %s to %s",
+				c.ResourceID, c.EventID, c.Start.UTC().Format(time.RFC3339), c.End.UTC().Format(time.RFC3339))
+			return
+		}
+	}
+
+	resources, err := rc.resources.SetEventResources(ctx, event.ID, ids)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "booking resources", "Failed to book resources")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resources)
+}
+
+// loadResource fetches the resource named in the URL, writing an error when it fails
+func (rc *ResourceController) loadResource(ctx context.Context, w http.ResponseWriter, r *http.Request) *internal.Resource {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return nil
+	}
+
+	res, err := rc.resources.GetResource(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting resource", "Failed to get resource")
+		return nil
+	}
+	return res
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeResourceRepository keeps resources and bookings in memory, enforcing no
+// overlapping bookings like the database's exclusion constraint
+type fakeResourceRepository struct {
+	internal.ResourceRepositoryInterface
+	resources map[uuid.UUID]internal.Resource
+	bookings  []internal.Booking
+	events    map[uuid.UUID]internal.EventDB
+}
+
+func (f *fakeResourceRepository) GetResource(ctx context.Context, id uuid.UUID) (*internal.Resource, error) {
+	res, ok := f.resources[id]
+	if !ok {
+		return nil, internal.ErrResourceNotFound
+	}
+	return &res, nil
+}
+
+func (f *fakeResourceRepository) overlapping(ids []uuid.UUID, slot internal.TimeSlot, eventID uuid.UUID) []internal.Booking {
+	conflicts := []internal.Booking{}
+	for _, b := range f.bookings {
+		for _, id := range ids {
+			if b.ResourceID == id && b.EventID != eventID && b.Start.Before(slot.End) && b.End.After(slot.Start) {
+				conflicts = append(conflicts, b)
+			}
+		}
+	}
+	return conflicts
+}
+
+func (f *fakeResourceRepository) Conflicts(ctx context.Context, ids []uuid.UUID, slot internal.TimeSlot, eventID uuid.UUID) ([]internal.Booking, error) {
+	return f.overlapping(ids, slot, eventID), nil
+}
+
+func (f *fakeResourceRepository) ListBookings(ctx context.Context, id uuid.UUID, slot internal.TimeSlot) ([]internal.Booking, error) {
+	return f.overlapping([]uuid.UUID{id}, slot, uuid.Nil), nil
+}
+
+func (f *fakeResourceRepository) SetEventResources(ctx context.Context, eventID uuid.UUID, ids []uuid.UUID) ([]internal.Resource, error) {
+	e := f.events[eventID]
+	slot := internal.TimeSlot{Start: e.StartTime, End: e.EndTime}
+	if len(f.overlapping(ids, slot, eventID)) > 0 {
+		return nil, internal.ErrResourceBooked
+	}
+	booked := []internal.Resource{}
+	for _, id := range ids {
+		res, ok := f.resources[id]
+		if !ok {
+			return nil, internal.ErrUnknownResource
+		}
+		booked = append(booked, res)
+		f.bookings = append(f.bookings, internal.Booking{ResourceID: id, EventID: eventID, Start: e.StartTime, End: e.EndTime})
+	}
+	return booked, nil
+}
+
+func TestEventResources(t *testing.T) {
+	start := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+	keynote := internal.EventDB{ID: uuid.New(), Title: "Keynote", StartTime: start, EndTime: start.Add(2 * time.Hour)}
+	workshop := internal.EventDB{ID: uuid.New(), Title: "Workshop", StartTime: start.Add(time.Hour), EndTime: start.Add(3 * time.Hour)}
+	events := map[uuid.UUID]internal.EventDB{keynote.ID: keynote, workshop.ID: workshop}
+	room := internal.Resource{ID: uuid.New(), Name: "Main hall", Kind: internal.ResourceKindRoom}
+	resources := &fakeResourceRepository{resources: map[uuid.UUID]internal.Resource{room.ID: room}, events: events}
+
+	srv, err := NewServer(internal.Config{}, Dependencies{
+		Events:    &eventsByID{byID: events},
+		Resources: resources,
+	})
+	require.NoError(t, err)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+	book := `{"resource_ids":["` + room.ID.String() + `","` + room.ID.String() + `"]}`
+
+	rec := do(http.MethodPut, "/events/"+keynote.ID.String()+"/resources", book)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Len(t, resources.bookings, 1, "duplicate IDs book once")
+
+	rec = do(http.MethodPut, "/events/"+workshop.ID.String()+"/resources", book)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), keynote.ID.String())
+
+	rec = do(http.MethodGet, "/resources/"+room.ID.String()+"/availability?from=2025-09-15&to=2025-09-16", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var availability availabilityResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &availability))
+	assert.Len(t, availability.Busy, 1)
+	assert.Equal(t, []internal.TimeSlot{
+		{Start: start.Add(-9 * time.Hour), End: start},
+		{Start: start.Add(2 * time.Hour), End: start.Add(15 * time.Hour)},
+	}, availability.Free)
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/resources/"+room.ID.String()+"/availability?from=2025-09-15", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/resources/"+uuid.NewString()+"/availability?from=2025-09-15&to=2025-09-16", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/resources", `{"name":"Van","kind":"vehicle"}`).Code)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"taller_challenge/internal"
+)
+
+// maxLoggedInput bounds how much of a rejected value reaches the security log
+const maxLoggedInput = 200
+
+// sanitizeEventInput cleans free-text fields in place and screens them for injection
+// attempts. Suspicious input is always logged for security review; in strict mode it
+// is also rejected, and sanitizeEventInput returns false.
+func (ec *EventController) sanitizeEventInput(r *http.Request, in *createEventInput) bool {
+	in.Title = internal.SanitizeText(in.Title, false)
+	if in.Description != nil {
+		description := internal.SanitizeText(*in.Description, true)
+		in.Description = &description
+	}
+	if in.Location != nil {
+		location := internal.SanitizeText(*in.Location, false)
+		in.Location = &location
+	}
+
+	fields := map[string]*string{"title": &in.Title, "description": in.Description, "location": in.Location}
+	for _, name := range []string{"title", "description", "location"} {
+		if !ec.screenField(r, name, fields[name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// screenField logs suspicious values and reports whether the request may proceed
+func (ec *EventController) screenField(r *http.Request, field string, value *string) bool {
+	if value == nil {
+		return true
+	}
+	reason := internal.SuspiciousInput(*value)
+	if reason == "" {
+		return true
+	}
+
+	logged := *value
+	if len(logged) > maxLoggedInput {
+		logged = logged[:maxLoggedInput] + "..."
+	}
+	action := "accepted (audit mode)"
+	if ec.cfg.SanitizeStrict {
+		action = "rejected"
+	}
+	log.Printf("Security: suspicious %s (%s) %s from %s %s %s: %s", field, reason, action, r.RemoteAddr, r.Method, r.URL.Path, internal.LogPayload(strconv.Quote(logged)))
+
+	return !ec.cfg.SanitizeStrict
+}
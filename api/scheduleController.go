@@ -0,0 +1,277 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// ScheduleController handles HTTP requests for cron schedules
+type ScheduleController struct {
+	scheduleRepo internal.ScheduleRepositoryInterface
+	scheduler    *internal.Scheduler
+}
+
+// NewScheduleController creates a new schedule controller
+func NewScheduleController(scheduleRepo internal.ScheduleRepositoryInterface, scheduler *internal.Scheduler) *ScheduleController {
+	return &ScheduleController{scheduleRepo: scheduleRepo, scheduler: scheduler}
+}
+
+// RegisterRoutes adds the schedule endpoints to router
+func (sc *ScheduleController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/schedules", requireAdmin(sc.CreateSchedule)).Methods("POST")
+	router.HandleFunc("/schedules", requireAdmin(sc.GetSchedules)).Methods("GET")
+	router.HandleFunc("/schedules/jobs", requireAdmin(sc.GetJobs)).Methods("GET")
+	router.HandleFunc("/schedules/{id}", requireAdmin(sc.GetSchedule)).Methods("GET")
+	router.HandleFunc("/schedules/{id}", requireAdmin(sc.UpdateSchedule)).Methods("PATCH")
+	router.HandleFunc("/schedules/{id}", requireAdmin(sc.DeleteSchedule)).Methods("DELETE")
+	router.HandleFunc("/schedules/{id}/runs", requireAdmin(sc.GetRuns)).Methods("GET")
+}
+
+type createScheduleInput struct {
+	Name     string          `json:"name"`
+	Cron     string          `json:"cron"`
+	Timezone string          `json:"timezone"`
+	Job      string          `json:"job"`
+	Params   json.RawMessage `json:"params"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled"`
+}
+
+// updateScheduleInput holds the fields PATCH may change; the job itself is fixed
+type updateScheduleInput struct {
+	Name     *string         `json:"name"`
+	Cron     *string         `json:"cron"`
+	Timezone *string         `json:"timezone"`
+	Params   json.RawMessage `json:"params"`
+	Enabled  *bool           `json:"enabled"`
+}
+
+// CreateSchedule handles POST /schedules
+func (sc *ScheduleController) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in createScheduleInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	if !sc.scheduler.HasJob(in.Job) {
+		httpError(w, r, http.StatusBadRequest, "unknown job %q", in.Job)
+		return
+	}
+
+	sched := internal.Schedule{
+		ID:       uuid.New(),
+		Name:     strings.TrimSpace(in.Name),
+		Cron:     strings.TrimSpace(in.Cron),
+		Timezone: in.Timezone,
+		Job:      in.Job,
+		Params:   in.Params,
+		Enabled:  in.Enabled == nil || *in.Enabled,
+	}
+	if p := internal.PrincipalFromContext(r.Context()); p != nil {
+		sched.CreatedBy = p.UserID
+	}
+	if !sc.prepareSchedule(w, r, &sched) {
+		return
+	}
+
+	created, err := sc.scheduleRepo.CreateSchedule(ctx, sched)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "creating schedule", "Failed to create schedule")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// prepareSchedule validates a schedule and computes its next run.
+// It returns false when the response has already been written.
+func (sc *ScheduleController) prepareSchedule(w http.ResponseWriter, r *http.Request, sched *internal.Schedule) bool {
+	if sched.Name == "" || len(sched.Name) > 100 {
+		httpError(w, r, http.StatusBadRequest, "name is required and must be <= 100 characters")
+		return false
+	}
+	if sched.Timezone == "" {
+		sched.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(sched.Timezone); err != nil {
+		httpError(w, r, http.StatusBadRequest, "unknown timezone %q", sched.Timezone)
+		return false
+	}
+	if _, err := internal.ParseCron(sched.Cron); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid cron expression: %v", err)
+		return false
+	}
+	if len(sched.Params) == 0 || string(sched.Params) == "null" {
+		sched.Params = json.RawMessage(`{}`)
+	}
+
+	next, err := sched.NextActivation(time.Now())
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid cron expression: %v", err)
+		return false
+	}
+	sched.NextRunAt = next
+	return true
+}
+
+// GetSchedules handles GET /schedules
+func (sc *ScheduleController) GetSchedules(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	schedules, err := sc.scheduleRepo.ListSchedules(ctx)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing schedules", "Failed to get schedules")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedules)
+}
+
+// GetJobs handles GET /schedules/jobs, listing the jobs schedules can run
+func (sc *ScheduleController) GetJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sc.scheduler.Jobs())
+}
+
+// GetSchedule handles GET /schedules/{id}
+func (sc *ScheduleController) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	sched := sc.loadSchedule(ctx, w, r)
+	if sched == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sched)
+}
+
+// UpdateSchedule handles PATCH /schedules/{id}
+func (sc *ScheduleController) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in updateScheduleInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	sched := sc.loadSchedule(ctx, w, r)
+	if sched == nil {
+		return
+	}
+	if in.Name != nil {
+		sched.Name = strings.TrimSpace(*in.Name)
+	}
+	if in.Cron != nil {
+		sched.Cron = strings.TrimSpace(*in.Cron)
+	}
+	if in.Timezone != nil {
+		sched.Timezone = *in.Timezone
+	}
+	if in.Params != nil {
+		sched.Params = in.Params
+	}
+	if in.Enabled != nil {
+		sched.Enabled = *in.Enabled
+	}
+	if !sc.prepareSchedule(w, r, sched) {
+		return
+	}
+
+	updated, err := sc.scheduleRepo.UpdateSchedule(ctx, *sched)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "updating schedule", "Failed to update schedule")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteSchedule handles DELETE /schedules/{id}
+func (sc *ScheduleController) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	if err := sc.scheduleRepo.DeleteSchedule(ctx, id); err != nil {
+		repositoryError(ctx, w, r, err, "deleting schedule", "Failed to delete schedule")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetRuns handles GET /schedules/{id}/runs?limit=20
+func (sc *ScheduleController) GetRuns(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			httpError(w, r, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+		limit = n
+	}
+
+	sched := sc.loadSchedule(ctx, w, r)
+	if sched == nil {
+		return
+	}
+
+	runs, err := sc.scheduleRepo.ListRuns(ctx, sched.ID, limit)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing schedule runs", "Failed to get schedule runs")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+// loadSchedule fetches the schedule named in the URL, writing an error when it fails
+func (sc *ScheduleController) loadSchedule(ctx context.Context, w http.ResponseWriter, r *http.Request) *internal.Schedule {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return nil
+	}
+
+	sched, err := sc.scheduleRepo.GetSchedule(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting schedule", "Failed to get schedule")
+		return nil
+	}
+	return sched
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+	"unicode/utf8"
+)
+
+// searchResponse is the events matching a search, best matches first
+type searchResponse struct {
+	Events []internal.EventSummary `json:"events"`
+}
+
+// SearchEvents handles GET /events/search?q=&limit=&calendar_id=, the summaries of the
+// published events matching q, from OpenSearch when configured and PostgreSQL
+// otherwise
+func (ec *EventController) SearchEvents(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	query := r.URL.Query()
+	q := internal.SearchQuery{Text: strings.TrimSpace(query.Get("q")), Limit: internal.DefaultSearchLimit}
+	if q.Text == "" {
+		httpError(w, r, http.StatusBadRequest, "q is required")
+		return
+	}
+	if utf8.RuneCountInString(q.Text) > internal.MaxSearchLength {
+		httpError(w, r, http.StatusBadRequest, "q must be at most %d characters", internal.MaxSearchLength)
+		return
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > internal.MaxSearchLimit {
+			httpError(w, r, http.StatusBadRequest, "limit must be between 1 and %d", internal.MaxSearchLimit)
+			return
+		}
+		q.Limit = n
+	}
+	if v := query.Get("calendar_id"); v != "" {
+		id, err := internal.ParseUUIDParam("calendar_id", v)
+		if err != nil {
+			paramError(w, r, err)
+			return
+		}
+		q.CalendarID = &id
+	}
+
+	var events []internal.EventSummary
+	var err error
+	if ec.search != nil {
+		events, err = ec.search.SearchEvents(ctx, q)
+	} else {
+		events, err = internal.SearchEvents(ctx, ec.eventRepo, q)
+	}
+	if err != nil {
+		repositoryError(ctx, w, r, err, "searching events", "Failed to get events")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, r, searchResponse{Events: events})
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"taller_challenge/internal"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordedSearch records the search query and finds nothing
+type recordedSearch struct {
+	query internal.SearchQuery
+}
+
+func (s *recordedSearch) SearchEvents(ctx context.Context, q internal.SearchQuery) ([]internal.EventSummary, error) {
+	s.query = q
+	return []internal.EventSummary{}, nil
+}
+
+func TestSearchEvents(t *testing.T) {
+	repo := &fakeEventRepository{events: []internal.EventDB{
+		{ID: uuid.New(), Title: "Standup Meeting"},
+		{ID: uuid.New(), Title: "Lunch"},
+	}}
+	srv, err := NewServer(internal.Config{}, Dependencies{Events: repo})
+	require.NoError(t, err)
+	get := func(srv *Server, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get(srv, "/events/search?q=standup")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var body searchResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Events, 1)
+	assert.Equal(t, "Standup Meeting", body.Events[0].Title)
+
+	assert.Equal(t, http.StatusBadRequest, get(srv, "/events/search").Code)
+	assert.Equal(t, http.StatusBadRequest, get(srv, "/events/search?q=standup&limit=101").Code)
+
+	search := &recordedSearch{}
+	srv, err = NewServer(internal.Config{}, Dependencies{Events: repo, Search: search})
+	require.NoError(t, err)
+	calendarID := uuid.New()
+	rec = get(srv, "/events/search?q=standp+metting&limit=5&calendar_id="+calendarID.String())
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"events":[]}`, rec.Body.String())
+	assert.Equal(t, internal.SearchQuery{Text: "standp metting", Limit: 5, CalendarID: &calendarID}, search.query)
+}
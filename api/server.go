@@ -0,0 +1,466 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Dependencies are the collaborators the API is built from. Tests and embedders can
+// pass fakes; Metrics, Holidays and Weather default to the ones configured by cfg
+// when nil. Events is required; the endpoints of any other repository left nil are
+// not registered.
+type Dependencies struct {
+	Tx     internal.Transactor
+	Events internal.EventRepositoryInterface
+	Tokens internal.TokenRepositoryInterface
+	// TwoFactor holds TOTP enrollments; when set, creating tokens takes a code from
+	// users who enabled it, and organizations can require it of their members
+	TwoFactor internal.TwoFactorRepositoryInterface
+	// Preferences are the users' defaults, served on /me with their profile
+	Preferences internal.PreferencesRepositoryInterface
+	// Privacy exports and erases the data of users; its endpoints are registered when
+	// Operations and Storage are set too
+	Privacy internal.PrivacyRepositoryInterface
+	// Storage keeps the archives of user data exports
+	Storage   internal.Storage
+	Schedules internal.ScheduleRepositoryInterface
+	Digests   internal.DigestRepositoryInterface
+	Calendars internal.CalendarRepositoryInterface
+	// Organizations own shared calendars; their endpoints are registered when
+	// Calendars is set too
+	Organizations internal.OrganizationRepositoryInterface
+	// Delegates are the grants letting users write other users' calendars; their
+	// endpoints are registered when Calendars is set too. The grants are enforced by
+	// internal.CalendarAccess, registered on the hooks of Events.
+	Delegates  internal.DelegateRepositoryInterface
+	Snapshots  internal.SnapshotRepositoryInterface
+	Operations internal.OperationRepositoryInterface
+	Policies   internal.PolicyRepositoryInterface
+	// PolicyEngine checks the rules in Policies; it is required with Policies
+	PolicyEngine *internal.PolicyEngine
+	Comments     internal.CommentRepositoryInterface
+	Activity     internal.ActivityRepositoryInterface
+	Reminders    internal.ReminderRepositoryInterface
+	// ReminderSenders are the channels reminders may be set on; email and webhook when nil
+	ReminderSenders internal.ReminderSenders
+	// PushSubscriptions are the browsers registered for Web Push; their endpoints are
+	// registered when WebPush is set too
+	PushSubscriptions internal.PushSubscriptionRepositoryInterface
+	WebPush           *internal.WebPush
+	// DeviceTokens are the mobile devices registered for push; their endpoints are
+	// registered when PushProviders has a provider
+	DeviceTokens  internal.DeviceTokenRepositoryInterface
+	PushProviders internal.PushProviders
+	// Covers stores event cover images and their thumbnails
+	Covers *internal.CoverStore
+	// Resources are the rooms and equipment events book
+	Resources internal.ResourceRepositoryInterface
+	Tickets   internal.TicketRepositoryInterface
+	// Payments takes the payment of held ticket reservations; reservations wait for an
+	// admin to confirm them when nil
+	Payments internal.PaymentProvider
+	// Webhooks are the endpoints event writes are POSTed to by the
+	// internal.WebhookDispatcher registered on the hooks of Events
+	Webhooks internal.WebhookRepositoryInterface
+	// IngestSources are the external systems whose payloads, POSTed to
+	// /ingest/{source}, are turned into events
+	IngestSources internal.IngestRepositoryInterface
+	// Notifier sends comment mention notifications, to the addresses in Digests
+	Notifier  internal.Notifier
+	Scheduler *internal.Scheduler
+	Metrics   *internal.Metrics
+	Holidays  internal.HolidayProvider
+	Weather   internal.WeatherProvider
+	// Search, when set, serves /events/search, e.g. from an OpenSearch index; Events
+	// is searched otherwise
+	Search internal.EventSearcher
+	// Maintenance, when set, is the maintenance mode switch, checked on every request and
+	// toggled through /admin/maintenance
+	Maintenance *internal.MaintenanceSwitch
+	// AuthLockout, when set, locks out clients failing to authenticate too often
+	AuthLockout *internal.AuthLockout
+	// Auth, when set, authenticates requests before the built-in API key and tokens
+	Auth AuthHook
+	// Analytics, when set, receives anonymous usage events of every request
+	Analytics *internal.Analytics
+	// Chaos, when set, injects faults into requests, for resilience testing
+	Chaos *internal.Chaos
+	// ReplayLog, when set, records every mutating request for the replay command
+	ReplayLog *internal.ReplayLog
+}
+
+// AuthHook authenticates a request for an embedding program. It returns the
+// principal to act as, an error to reject the request with a 401, or neither to
+// leave the request to the built-in authentication.
+type AuthHook func(r *http.Request) (*internal.Principal, error)
+
+// Server is the API's router and the http.Server serving it. Construction does not
+// listen, so the router can also be mounted in another server.
+type Server struct {
+	HTTP   *http.Server
+	Router *mux.Router
+	// Admin serves the health and metrics endpoints on cfg.AdminListen; nil when unset
+	Admin *http.Server
+
+	cfg     internal.Config
+	health  *HealthController
+	metrics *internal.Metrics
+	// tokenUses records token use in the background; nil without a token repository
+	tokenUses *internal.TokenUses
+}
+
+// NewServer builds the router with every controller and middleware, and the
+// http.Server for cfg. It returns an error when the event repository is missing or
+// the TLS configuration is invalid.
+func NewServer(cfg internal.Config, deps Dependencies) (*Server, error) {
+	if deps.Events == nil {
+		return nil, errors.New("an event repository is required")
+	}
+
+	if deps.Metrics == nil {
+		deps.Metrics = internal.NewMetrics()
+	}
+	if deps.Holidays == nil {
+		deps.Holidays = internal.NewHolidayProvider(cfg)
+	}
+	if deps.Weather == nil {
+		deps.Weather = internal.NewWeatherProvider(cfg)
+	}
+
+	var redactor *internal.EventRedactor
+	if deps.Calendars != nil {
+		redactor = internal.NewEventRedactor(deps.Calendars, deps.Organizations, deps.Delegates)
+	}
+	controller := NewEventController(deps.Events, cfg, deps.Holidays, deps.Weather, redactor)
+	controller.preferences = deps.Preferences
+	controller.search = deps.Search
+	router := controller.SetupRoutes()
+	NewHolidayController(deps.Holidays).RegisterRoutes(router)
+	if deps.Tokens != nil {
+		NewTokenController(deps.Tokens, deps.TwoFactor, deps.Organizations, deps.AuthLockout).RegisterRoutes(router)
+	}
+	if deps.TwoFactor != nil {
+		NewTwoFactorController(deps.TwoFactor, deps.Organizations, deps.AuthLockout, cfg.TwoFactorIssuer).RegisterRoutes(router)
+	}
+	if deps.Preferences != nil {
+		NewMeController(deps.Preferences, deps.TwoFactor).RegisterRoutes(router)
+	}
+	if deps.Schedules != nil {
+		NewScheduleController(deps.Schedules, deps.Scheduler).RegisterRoutes(router)
+	}
+	if deps.Digests != nil {
+		NewDigestController(deps.Digests).RegisterRoutes(router)
+	}
+	if deps.Calendars != nil {
+		NewCalendarController(deps.Calendars, deps.Events, deps.Organizations, redactor).RegisterRoutes(router)
+	}
+	if deps.Organizations != nil && deps.Calendars != nil {
+		notifier := deps.Notifier
+		if notifier == nil {
+			notifier = internal.LogNotifier{}
+		}
+		NewOrganizationController(deps.Organizations, deps.Calendars, deps.TwoFactor, notifier, cfg.InvitationURL).RegisterRoutes(router)
+	}
+	if deps.Delegates != nil && deps.Calendars != nil {
+		NewDelegateController(deps.Delegates, deps.Calendars, deps.Organizations).RegisterRoutes(router)
+	}
+	if signer := internal.NewFeedSigner(cfg.FeedSigningKey); signer != nil && deps.Calendars != nil {
+		NewFeedController(deps.Calendars, deps.Events, signer, cfg.PublicURL).RegisterRoutes(router)
+		NewEmbedController(deps.Calendars, deps.Events, signer, cfg.PublicURL, cfg.EventPages).RegisterRoutes(router)
+	}
+	if cfg.EventPages {
+		NewEventPageController(deps.Events, deps.Calendars, cfg.PublicURL).RegisterRoutes(router)
+	}
+	if deps.Snapshots != nil {
+		NewSnapshotController(deps.Snapshots).RegisterRoutes(router)
+	}
+	if deps.Operations != nil {
+		NewOperationController(deps.Operations, deps.Scheduler).RegisterRoutes(router)
+	}
+	if deps.Privacy != nil && deps.Operations != nil && deps.Storage != nil {
+		NewPrivacyController(deps.Privacy, deps.Operations, deps.Scheduler, deps.Storage, deps.Covers, deps.TwoFactor, deps.AuthLockout).RegisterRoutes(router)
+	}
+	if deps.Policies != nil && deps.PolicyEngine != nil {
+		NewPolicyController(deps.Policies, deps.PolicyEngine).RegisterRoutes(router)
+	}
+	if deps.Comments != nil {
+		NewCommentController(deps.Comments, deps.Events, internal.NewMentionNotifier(deps.Notifier, deps.Digests)).RegisterRoutes(router)
+	}
+	if deps.Activity != nil {
+		NewActivityController(deps.Activity).RegisterRoutes(router)
+	}
+	if deps.Reminders != nil {
+		senders := deps.ReminderSenders
+		if senders == nil {
+			senders = internal.NewReminderSenders(internal.LogNotifier{})
+		}
+		NewReminderController(deps.Reminders, deps.Events, senders.Channels()).RegisterRoutes(router)
+	}
+	if deps.PushSubscriptions != nil && deps.WebPush != nil {
+		NewPushController(deps.PushSubscriptions, deps.WebPush).RegisterRoutes(router)
+	}
+	if deps.DeviceTokens != nil && len(deps.PushProviders) > 0 {
+		NewDeviceController(deps.DeviceTokens, deps.PushProviders).RegisterRoutes(router)
+	}
+	if deps.Covers != nil {
+		var access *internal.CalendarAccess
+		if deps.Calendars != nil {
+			access = internal.NewCalendarAccess(deps.Calendars, deps.Organizations, deps.Delegates, deps.Events)
+		}
+		NewCoverController(deps.Covers, deps.Events, access, cfg.CoverMaxBytes).RegisterRoutes(router)
+	}
+	if deps.Resources != nil {
+		NewResourceController(deps.Resources, deps.Events).RegisterRoutes(router)
+	}
+	if deps.Tickets != nil {
+		NewTicketController(deps.Tickets, deps.Events, deps.Payments, cfg.TicketHold, cfg.PublicURL, cfg.PaymentReturnURL).RegisterRoutes(router)
+		if deps.Payments != nil {
+			NewPaymentController(deps.Tickets, deps.Payments).RegisterRoutes(router)
+		}
+	}
+	if deps.Webhooks != nil {
+		dispatcher, err := internal.NewWebhookDispatcherFromConfig(deps.Webhooks, deps.Events, cfg)
+		if err != nil {
+			return nil, err
+		}
+		NewWebhookController(deps.Webhooks, dispatcher, deps.Events, deps.Calendars, deps.Organizations).RegisterRoutes(router)
+	}
+	if deps.IngestSources != nil {
+		ingester, err := internal.NewIngester(deps.Events, internal.NewIDGenerator(cfg.IDStrategy))
+		if err != nil {
+			return nil, err
+		}
+		NewIngestController(deps.IngestSources, ingester).RegisterRoutes(router)
+	}
+	if deps.Tx != nil && deps.Calendars != nil {
+		NewDeclarativeController(controller, deps.Tx, deps.Calendars, deps.Organizations).RegisterRoutes(router)
+	}
+	if deps.Tx != nil {
+		NewBatchController(deps.Tx).RegisterRoutes(router)
+	}
+
+	if deps.Maintenance != nil {
+		NewMaintenanceController(deps.Maintenance).RegisterRoutes(router)
+	}
+
+	health := NewHealthController(deps.Events, deps.Metrics)
+	health.RegisterRoutes(router)
+
+	router.Use(requestIDMiddleware)
+	router.Use(consistencyMiddleware)
+	router.Use(loggingMiddleware(cfg.LogSampleRate, cfg.LogSlowRequest))
+	router.Use(metricsMiddleware(deps.Metrics))
+	if deps.Chaos != nil {
+		router.Use(chaosMiddleware(deps.Chaos))
+	}
+	if deps.Auth != nil {
+		router.Use(authHookMiddleware(deps.Auth))
+	}
+	var tokenUses *internal.TokenUses
+	if deps.Tokens != nil {
+		tokenUses = internal.NewTokenUses(deps.Tokens)
+	}
+	router.Use(authMiddleware(cfg, deps.Tokens, tokenUses, deps.AuthLockout))
+	router.Use(metricScopeMiddleware)
+	if deps.Analytics != nil {
+		router.Use(analyticsMiddleware(deps.Analytics))
+	}
+	if deps.ReplayLog != nil {
+		router.Use(replayMiddleware(deps.ReplayLog))
+	}
+	if deps.Maintenance != nil {
+		router.Use(maintenanceMiddleware(deps.Maintenance))
+	}
+	if cfg.CacheControl != "" {
+		routes, err := parseCacheRoutes(cfg.CacheControl)
+		if err != nil {
+			return nil, err
+		}
+		router.Use(cacheControlMiddleware(routes))
+	}
+
+	tlsConfig, err := internal.ServerTLSConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+	}
+
+	srv := &http.Server{
+		Addr:         ":" + cfg.Port,
+		Handler:      router,
+		TLSConfig:    tlsConfig,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	// The admin listener has its own stack: no authentication, access log or request
+	// metrics, so probes and scrapes neither need credentials nor drown real traffic
+	var admin *http.Server
+	if cfg.AdminListen != "" {
+		adminRouter := mux.NewRouter()
+		health.RegisterAdminRoutes(adminRouter)
+		adminRouter.Use(requestIDMiddleware)
+		admin = &http.Server{
+			Addr:         cfg.AdminListen,
+			Handler:      adminRouter,
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 15 * time.Second,
+			IdleTimeout:  60 * time.Second,
+		}
+	}
+
+	return &Server{HTTP: srv, Router: router, Admin: admin, cfg: cfg, health: health, metrics: deps.Metrics, tokenUses: tokenUses}, nil
+}
+
+// consistencyMiddleware tracks the writes of a request so its response can carry a
+// consistency token, and passes the token the client sent on to the repository. The
+// operations of a /batch call share the state of the batch.
+func consistencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := internal.ConsistencyFromContext(r.Context())
+		if c == nil {
+			var ctx context.Context
+			ctx, c = internal.WithConsistency(r.Context(), r.Header.Get(internal.HeaderConsistencyToken))
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(&consistencyWriter{ResponseWriter: w, r: r, c: c}, r)
+	})
+}
+
+// consistencyWriter sets the consistency token header when the response starts, by
+// which time the writes of the request are committed
+type consistencyWriter struct {
+	http.ResponseWriter
+	r       *http.Request
+	c       *internal.Consistency
+	started bool
+}
+
+func (cw *consistencyWriter) start() {
+	if cw.started {
+		return
+	}
+	cw.started = true
+	if token := cw.c.WriteToken(cw.r.Context()); token != "" {
+		cw.Header().Set(internal.HeaderConsistencyToken, token)
+	}
+}
+
+func (cw *consistencyWriter) WriteHeader(status int) {
+	cw.start()
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *consistencyWriter) Write(b []byte) (int, error) {
+	cw.start()
+	return cw.ResponseWriter.Write(b)
+}
+
+// authHookMiddleware runs hook before the built-in authentication, which skips
+// requests that already carry a principal
+func authHookMiddleware(hook AuthHook) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isPublic(r.URL.Path) || internal.PrincipalFromContext(r.Context()) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			principal, err := hook(r)
+			if err != nil {
+				log.Printf("Security: auth hook rejected %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+				httpError(w, r, http.StatusUnauthorized, "authentication required")
+				return
+			}
+			if principal != nil {
+				r = r.WithContext(internal.WithPrincipal(r.Context(), principal))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Run serves until SIGINT or SIGTERM, then drains and shuts down gracefully
+func (s *Server) Run() {
+	// Listen on every address before serving, so a taken port stops startup at once
+	public, admin, err := s.listeners()
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	s.serveUntil(public, admin, quit)
+}
+
+// serveUntil serves on the listeners until quit receives, then drains and shuts down.
+// TLS applies to TCP addresses; UNIX sockets, reached through a local proxy, are plain.
+func (s *Server) serveUntil(public, admin []net.Listener, quit <-chan os.Signal) {
+	srv, cfg := s.HTTP, s.cfg
+
+	for _, l := range public {
+		useTLS := srv.TLSConfig != nil && l.Addr().Network() == "tcp"
+		if useTLS {
+			log.Printf("Server listening on %s (TLS, client certificates: %t)", l.Addr(), srv.TLSConfig.ClientCAs != nil)
+		} else {
+			log.Printf("Server listening on %s", l.Addr())
+		}
+		go serve(srv, l, useTLS, cfg)
+	}
+	for _, l := range admin {
+		log.Printf("Admin endpoints listening on %s", l.Addr())
+		go serve(s.Admin, l, false, cfg)
+	}
+
+	// Wait for interrupt signal to gracefully shutdown the server with a timeout
+	<-quit
+	log.Println("Server is shutting down...")
+
+	// Fail readiness first and keep serving while load balancers notice; a second
+	// signal skips the rest of the delay
+	s.health.StartDraining()
+	srv.SetKeepAlivesEnabled(false)
+	if cfg.ShutdownDrainDelay > 0 {
+		log.Printf("Draining for %s before closing listeners", cfg.ShutdownDrainDelay)
+		select {
+		case <-time.After(cfg.ShutdownDrainDelay):
+		case <-quit:
+		}
+	}
+
+	// Create a context with timeout for shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// Attempt graceful shutdown; in-flight requests are allowed to finish. The admin
+	// listener goes last so probes see the drain to the end.
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Server forced to shutdown: %v", err)
+	}
+	if s.Admin != nil {
+		if err := s.Admin.Shutdown(ctx); err != nil {
+			log.Printf("Admin server forced to shutdown: %v", err)
+		}
+	}
+	if err := s.tokenUses.Close(ctx); err != nil {
+		log.Printf("Error recording token uses: %v", err)
+	}
+
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := s.metrics.Flush(flushCtx, cfg.MetricsPushURL); err != nil {
+		log.Printf("Error flushing metrics: %v", err)
+	}
+
+	log.Println("Server exited")
+}
@@ -0,0 +1,538 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEventRepository serves a fixed list of events; other methods are not implemented
+type fakeEventRepository struct {
+	internal.EventRepositoryInterface
+	events []internal.EventDB
+}
+
+func (f *fakeEventRepository) GetEvents(ctx context.Context) ([]internal.EventDB, error) {
+	return f.events, nil
+}
+
+func (f *fakeEventRepository) EventsVersion(ctx context.Context, source, externalID string) (*internal.EventsVersion, error) {
+	v := internal.EventsVersion{Count: len(f.events)}
+	for _, e := range f.events {
+		if e.UpdatedAt.After(v.UpdatedAt) {
+			v.UpdatedAt = e.UpdatedAt
+		}
+	}
+	return &v, nil
+}
+
+func TestNewServer(t *testing.T) {
+	cfg := internal.Config{Port: "8080", APIKey: "admin-secret"}
+	srv, err := NewServer(cfg, Dependencies{Events: &fakeEventRepository{}, Tokens: &fakeTokenRepository{tokens: map[string]internal.APIToken{}}})
+	require.NoError(t, err)
+	assert.Equal(t, ":8080", srv.HTTP.Addr)
+	assert.Nil(t, srv.HTTP.TLSConfig)
+
+	tests := []struct {
+		name       string
+		path       string
+		apiKey     string
+		wantStatus int
+	}{
+		{"liveness is public", "/healthz", "", http.StatusOK},
+		{"readiness without a database check", "/readyz", "", http.StatusOK},
+		{"events require authentication", "/events", "", http.StatusUnauthorized},
+		{"metrics with the admin key", "/metrics", "admin-secret", http.StatusOK},
+		{"events with the admin key", "/events", "admin-secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			srv.Router.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.NotEmpty(t, rec.Header().Get(internal.HeaderRequestID))
+		})
+	}
+
+	// Endpoints of repositories that were not provided are not registered
+	rec := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/calendars", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	_, err = NewServer(cfg, Dependencies{})
+	assert.Error(t, err)
+	_, err = NewServer(internal.Config{TLSClientCAFile: "ca.pem"}, Dependencies{Events: &fakeEventRepository{}})
+	assert.Error(t, err)
+}
+
+func TestAdminListener(t *testing.T) {
+	cfg := internal.Config{Port: "8080", APIKey: "admin-secret"}
+	srv, err := NewServer(cfg, Dependencies{Events: &fakeEventRepository{}})
+	require.NoError(t, err)
+	assert.Nil(t, srv.Admin)
+
+	cfg.AdminListen = "127.0.0.1:9090"
+	srv, err = NewServer(cfg, Dependencies{Events: &fakeEventRepository{}})
+	require.NoError(t, err)
+	require.NotNil(t, srv.Admin)
+	assert.Equal(t, "127.0.0.1:9090", srv.Admin.Addr)
+
+	// Metrics need no credentials on the admin listener, but still do on the API
+	rec := httptest.NewRecorder()
+	srv.Admin.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// Only the health endpoints are served there
+	rec = httptest.NewRecorder()
+	srv.Admin.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServerDrainsBeforeShutdown(t *testing.T) {
+	cfg := internal.Config{ShutdownDrainDelay: time.Minute, ShutdownTimeout: 5 * time.Second}
+	srv, err := NewServer(cfg, Dependencies{Events: &fakeEventRepository{}})
+	require.NoError(t, err)
+	started, release := make(chan struct{}), make(chan struct{})
+	srv.Router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	base := "http://" + l.Addr().String()
+	quit := make(chan os.Signal, 1)
+	stopped := make(chan struct{})
+	go func() {
+		srv.serveUntil([]net.Listener{l}, nil, quit)
+		close(stopped)
+	}()
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Timeout: 5 * time.Second}
+	ready := func() int {
+		res, err := client.Get(base + "/readyz")
+		if err != nil {
+			return 0
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	require.Equal(t, http.StatusOK, ready())
+
+	slow := make(chan int, 1)
+	go func() {
+		res, err := client.Get(base + "/slow")
+		if err != nil {
+			slow <- 0
+			return
+		}
+		res.Body.Close()
+		slow <- res.StatusCode
+	}()
+	<-started
+
+	// Readiness fails during the drain delay, while the listener still serves
+	quit <- syscall.SIGTERM
+	require.Eventually(t, func() bool { return ready() == http.StatusServiceUnavailable }, time.Second, 10*time.Millisecond)
+
+	// A second signal skips the rest of the delay; shutdown waits for the request in flight
+	quit <- syscall.SIGTERM
+	select {
+	case <-stopped:
+		t.Fatal("shut down with a request in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	assert.Equal(t, http.StatusOK, <-slow)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+	_, err = client.Get(base + "/readyz")
+	assert.Error(t, err, "the listener is closed")
+}
+
+func TestAuthHook(t *testing.T) {
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		switch r.Header.Get("X-User") {
+		case "":
+			return nil, nil
+		case "mallory":
+			return nil, errors.New("unknown user")
+		case "reader":
+			return &internal.Principal{UserID: "reader", Scopes: []string{internal.ScopeEventsRead}}, nil
+		}
+		return &internal.Principal{UserID: r.Header.Get("X-User")}, nil
+	}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{Events: &fakeEventRepository{}, Auth: hook})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		method     string
+		user       string
+		apiKey     string
+		wantStatus int
+	}{
+		{"hook principal with scope", http.MethodGet, "reader", "", http.StatusOK},
+		{"hook principal without scope", http.MethodPost, "reader", "", http.StatusForbidden},
+		{"hook rejects", http.MethodGet, "mallory", "admin-secret", http.StatusUnauthorized},
+		{"falls through to the API key", http.MethodGet, "", "admin-secret", http.StatusOK},
+		{"tokens are not accepted without a repository", http.MethodGet, "", "tc_unknown", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/events", strings.NewReader("{}"))
+			if tt.user != "" {
+				req.Header.Set("X-User", tt.user)
+			}
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			srv.Router.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+// syncedEvents looks events up by their external ID
+type syncedEvents struct {
+	fakeEventRepository
+}
+
+func (f *syncedEvents) GetEventsByExternalID(ctx context.Context, source, externalID string) ([]internal.EventDB, error) {
+	var found []internal.EventDB
+	for _, e := range f.events {
+		if e.ExternalID != nil && *e.ExternalID == externalID && (source == "" || *e.Source == source) {
+			found = append(found, e)
+		}
+	}
+	return found, nil
+}
+
+func TestGetEventsByExternalID(t *testing.T) {
+	ext, google, outlook := "abc123", "google", "outlook"
+	events := &syncedEvents{fakeEventRepository{events: []internal.EventDB{
+		{Title: "Synced from Google", ExternalID: &ext, Source: &google},
+		{Title: "Synced from Outlook", ExternalID: &ext, Source: &outlook},
+		{Title: "Local"},
+	}}}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{Events: events})
+	require.NoError(t, err)
+	get := func(path string) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-API-Key", "admin-secret")
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+
+	body := get("/events?external_id=abc123")
+	assert.Contains(t, body, "Synced from Google")
+	assert.Contains(t, body, "Synced from Outlook")
+	assert.NotContains(t, body, "Local")
+	body = get("/events?external_id=abc123&source=google")
+	assert.Contains(t, body, "Synced from Google")
+	assert.NotContains(t, body, "Synced from Outlook")
+}
+
+func TestGetEventsFilter(t *testing.T) {
+	start := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	events := &fakeEventRepository{events: []internal.EventDB{
+		{Title: "Team standup", StartTime: start, EndTime: start.Add(15 * time.Minute)},
+		{Title: "Planning", StartTime: start.AddDate(0, 1, 0), EndTime: start.AddDate(0, 1, 0).Add(2 * time.Hour)},
+		{Title: "Retro", StartTime: start.AddDate(-1, 0, 0), EndTime: start.AddDate(-1, 0, 0).Add(time.Hour)},
+	}}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{Events: events})
+	require.NoError(t, err)
+	get := func(filter string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/events?filter="+url.QueryEscape(filter), nil)
+		req.Header.Set("X-API-Key", "admin-secret")
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get(`start_time>2025-01-01 AND (title:"STANDUP" OR duration>1h)`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Team standup")
+	assert.Contains(t, rec.Body.String(), "Planning")
+	assert.NotContains(t, rec.Body.String(), "Retro")
+
+	rec = get(`tag:"work"`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `unknown field "tag"`)
+}
+
+func TestGetEventsSummaryView(t *testing.T) {
+	description, location := "Agenda: budget", "Room 4"
+	start := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	events := &fakeEventRepository{events: []internal.EventDB{
+		{ID: uuid.New(), Title: "Planning", Description: &description, Location: &location, StartTime: start, EndTime: start.Add(time.Hour)},
+		{ID: uuid.New(), Title: "Offsite", Status: internal.EventStatusPending, SubmittedBy: "someone", StartTime: start, EndTime: start.Add(time.Hour)},
+	}}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{Events: events})
+	require.NoError(t, err)
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-API-Key", "admin-secret")
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/events?view=summary")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var summaries []map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &summaries))
+	require.Len(t, summaries, 1, "pending events stay hidden")
+	assert.Equal(t, "Planning", summaries[0]["title"])
+	assert.NotContains(t, summaries[0], "description")
+	assert.NotContains(t, summaries[0], "location")
+
+	assert.Equal(t, http.StatusBadRequest, get("/events?view=compact").Code)
+}
+
+func TestGetEventsETag(t *testing.T) {
+	updated := time.Date(2025, 10, 1, 9, 0, 0, 0, time.UTC)
+	events := &fakeEventRepository{events: []internal.EventDB{{Title: "Standup", UpdatedAt: updated}}}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{Events: events})
+	require.NoError(t, err)
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-API-Key", "admin-secret")
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/events", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	rec = get("/events", etag)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	// Another query is another listing
+	rec = get("/events?include=display", etag)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+
+	// Forecasts change on their own
+	rec = get("/events?include=weather", etag)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("ETag"))
+
+	// Edits and deletes change the version
+	events.events[0].UpdatedAt = updated.Add(time.Second)
+	rec = get("/events", etag)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	etag = rec.Header().Get("ETag")
+	events.events = nil
+	rec = get("/events", etag)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestPastEventPolicy(t *testing.T) {
+	id := uuid.New()
+	events := &storedEvents{eventsByID{byID: map[uuid.UUID]internal.EventDB{id: {ID: id, Title: "Retro"}}}}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret", PastEventPolicy: internal.PastEventPolicyReject}, Dependencies{Events: events})
+	require.NoError(t, err)
+	send := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"title": "Retro", "start_time": "2020-01-10T15:00:00Z", "end_time": "2020-01-10T16:00:00Z"}`))
+		req.Header.Set("X-API-Key", "admin-secret")
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send(http.MethodPost, "/events")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "already ended")
+	// Past events can still be corrected
+	assert.Equal(t, http.StatusOK, send(http.MethodPut, "/events/"+id.String()).Code)
+}
+
+func TestUpdateEventBookingWindow(t *testing.T) {
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Second).UTC()
+	id := uuid.New()
+	events := &storedEvents{eventsByID{byID: map[uuid.UUID]internal.EventDB{id: {ID: id, Title: "Room 4", StartTime: start, EndTime: start.Add(time.Hour)}}}}
+	cfg := internal.Config{APIKey: "admin-secret", BookingHorizon: 30 * 24 * time.Hour, MinLeadTime: 24 * time.Hour}
+	srv, err := NewServer(cfg, Dependencies{Events: events})
+	require.NoError(t, err)
+	update := func(start time.Time) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"title": "Room 4", "start_time": %q, "end_time": %q}`, start.Format(time.RFC3339), start.Add(time.Hour).Format(time.RFC3339))
+		req := httptest.NewRequest(http.MethodPut, "/events/"+id.String(), strings.NewReader(body))
+		req.Header.Set("X-API-Key", "admin-secret")
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := update(start.Add(60 * 24 * time.Hour))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "at most 720h ahead")
+	rec = update(time.Now().Add(time.Hour).Truncate(time.Second))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "at least 24h ahead")
+	assert.Equal(t, start, events.byID[id].StartTime)
+
+	// Edits keeping the start, and moves within the window, go through
+	assert.Equal(t, http.StatusOK, update(start).Code)
+	assert.Equal(t, http.StatusOK, update(start.Add(24*time.Hour)).Code)
+	assert.Equal(t, start.Add(24*time.Hour), events.byID[id].StartTime)
+}
+
+// editorOnlyEvents rejects updates by anyone but alice, as CalendarAccess does
+type editorOnlyEvents struct {
+	storedEvents
+}
+
+func (f *editorOnlyEvents) UpdateEvent(ctx context.Context, e internal.EventDB) (*internal.EventDB, error) {
+	if p := internal.PrincipalFromContext(ctx); p == nil || p.UserID != "alice" {
+		return nil, internal.ErrCalendarForbidden
+	}
+	return f.storedEvents.UpdateEvent(ctx, e)
+}
+
+func TestUpdateLimitCountsSuccessfulUpdates(t *testing.T) {
+	id := uuid.New()
+	events := &editorOnlyEvents{storedEvents{eventsByID{byID: map[uuid.UUID]internal.EventDB{id: {ID: id, Title: "Standup"}}}}}
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		return &internal.Principal{UserID: r.Header.Get("X-User"), Scopes: []string{internal.ScopeEventsRead, internal.ScopeEventsWrite}}, nil
+	}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret", EventUpdateLimit: 2}, Dependencies{Events: events, Auth: hook})
+	require.NoError(t, err)
+	update := func(user string) int {
+		req := httptest.NewRequest(http.MethodPut, "/events/"+id.String(), strings.NewReader(`{"title": "Standup", "start_time": "2030-01-10T09:00:00Z", "end_time": "2030-01-10T10:00:00Z"}`))
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Rejected updates leave the event's budget to those who may edit it
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusForbidden, update("mallory"))
+	}
+	assert.Equal(t, http.StatusOK, update("alice"))
+	assert.Equal(t, http.StatusOK, update("alice"))
+	assert.Equal(t, http.StatusTooManyRequests, update("alice"))
+}
+
+func TestCreateEventWithDuration(t *testing.T) {
+	events := &storedEvents{eventsByID{byID: map[uuid.UUID]internal.EventDB{}}}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{Events: events})
+	require.NoError(t, err)
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+		req.Header.Set("X-API-Key", "admin-secret")
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := create(`{"title": "Standup", "start_time": "2030-01-10T09:00:00+01:00", "duration_minutes": 30}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), `"end_time":"2030-01-10T08:30:00Z"`)
+	assert.Contains(t, rec.Body.String(), `"duration_seconds":1800`)
+
+	rec = create(`{"title": "Standup", "start_time": "2030-01-10T09:00:00Z", "end_time": "2030-01-10T10:00:00Z", "duration_minutes": 30}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "mutually exclusive")
+	for _, minutes := range []string{"0", "-15", "600000"} {
+		rec = create(`{"title": "Standup", "start_time": "2030-01-10T09:00:00Z", "duration_minutes": ` + minutes + `}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, minutes)
+	}
+	rec = create(`{"title": "Standup", "duration_minutes": 30}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "start_time and end_time are required")
+}
+
+func TestLoggingMiddlewareSampling(t *testing.T) {
+	var buf strings.Builder
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	handler := loggingMiddleware(3, 50*time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/slow":
+			time.Sleep(60 * time.Millisecond)
+		}
+	}))
+	serve := func(path string) {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	for i := 0; i < 6; i++ {
+		serve("/ok")
+	}
+	serve("/fail")
+	serve("/slow")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Contains(t, lines[0], "GET /ok")
+	assert.Contains(t, lines[0], "status=200")
+	assert.Contains(t, lines[0], "sample=1/3")
+	assert.Contains(t, lines[2], "GET /fail")
+	assert.Contains(t, lines[2], "status=500")
+	assert.NotContains(t, lines[2], "sample=")
+	assert.Contains(t, lines[3], "GET /slow")
+	assert.NotContains(t, lines[3], "sample=")
+}
+
+func TestMetricsTenantLabels(t *testing.T) {
+	calendarID := uuid.New()
+	metrics := internal.NewMetrics()
+	metrics.SetLabeler(internal.NewMetricLabeler(8, []string{adminUserID, calendarID.String()}))
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{Events: &fakeEventRepository{}, Metrics: metrics})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/events?calendar_id="+calendarID.String(), nil)
+	req.Header.Set("X-API-Key", "admin-secret")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	srv.Router.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("X-API-Key", "admin-secret")
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0,text/plain;q=0.5")
+	rec := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "application/openmetrics-text")
+	assert.Contains(t, rec.Body.String(), `http_requests_total{method="GET",route="/events",status="200",tenant="`+adminUserID+`",calendar="`+calendarID.String()+`"} 1 # {trace_id="4bf92f3577b34da6a3ce929d0e0e4736"}`)
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// SnapshotController handles the admin snapshot and restore endpoints
+type SnapshotController struct {
+	snapshotRepo internal.SnapshotRepositoryInterface
+}
+
+// NewSnapshotController creates a new snapshot controller
+func NewSnapshotController(snapshotRepo internal.SnapshotRepositoryInterface) *SnapshotController {
+	return &SnapshotController{snapshotRepo: snapshotRepo}
+}
+
+// RegisterRoutes adds the snapshot endpoints to router
+func (sc *SnapshotController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/snapshots", requireAdmin(sc.CreateSnapshot)).Methods("POST")
+	router.HandleFunc("/admin/snapshots", requireAdmin(sc.GetSnapshots)).Methods("GET")
+	router.HandleFunc("/admin/snapshots/{id}", requireAdmin(sc.GetSnapshot)).Methods("GET")
+	router.HandleFunc("/admin/snapshots/{id}", requireAdmin(sc.DeleteSnapshot)).Methods("DELETE")
+	router.HandleFunc("/admin/snapshots/{id}/restore", requireAdmin(sc.RestoreSnapshot)).Methods("POST")
+}
+
+type createSnapshotInput struct {
+	Name string `json:"name"`
+}
+
+type restoreSnapshotInput struct {
+	// CalendarName names the staging calendar; it defaults to one derived from the snapshot
+	CalendarName string `json:"calendar_name"`
+}
+
+type restoreSnapshotResponse struct {
+	Calendar internal.Calendar `json:"calendar"`
+	Restored int               `json:"restored"`
+}
+
+// decodeOptionalJSON decodes the request body into v, accepting an empty body
+func decodeOptionalJSON(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// CreateSnapshot handles POST /admin/snapshots
+func (sc *SnapshotController) CreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	// Copying a large events table may take a while
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	var in createSnapshotInput
+	if err := decodeOptionalJSON(r, &in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		in.Name = "snapshot-" + time.Now().UTC().Format("20060102T150405Z")
+	}
+	if len(in.Name) > 100 {
+		httpError(w, r, http.StatusBadRequest, "name must be <= 100 characters")
+		return
+	}
+
+	snapshot, err := sc.snapshotRepo.CreateSnapshot(ctx, internal.Snapshot{
+		ID:        uuid.New(),
+		Name:      in.Name,
+		CreatedBy: principalID(r),
+	})
+	if err != nil {
+		repositoryError(ctx, w, r, err, "creating snapshot", "Failed to create snapshot")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// GetSnapshots handles GET /admin/snapshots
+func (sc *SnapshotController) GetSnapshots(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	snapshots, err := sc.snapshotRepo.ListSnapshots(ctx)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing snapshots", "Failed to get snapshots")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshots)
+}
+
+// GetSnapshot handles GET /admin/snapshots/{id}
+func (sc *SnapshotController) GetSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	snapshot, err := sc.snapshotRepo.GetSnapshot(ctx, id)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting snapshot", "Failed to get snapshot")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
+}
+
+// DeleteSnapshot handles DELETE /admin/snapshots/{id}
+func (sc *SnapshotController) DeleteSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	if err := sc.snapshotRepo.DeleteSnapshot(ctx, id); err != nil {
+		repositoryError(ctx, w, r, err, "deleting snapshot", "Failed to delete snapshot")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreSnapshot handles POST /admin/snapshots/{id}/restore. Events are copied into a
+// new staging calendar so they can be reviewed before anything live is touched.
+func (sc *SnapshotController) RestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	var in restoreSnapshotInput
+	if err := decodeOptionalJSON(r, &in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	in.CalendarName = strings.TrimSpace(in.CalendarName)
+	if in.CalendarName == "" {
+		snapshot, err := sc.snapshotRepo.GetSnapshot(ctx, id)
+		if err != nil {
+			repositoryError(ctx, w, r, err, "getting snapshot", "Failed to restore snapshot")
+			return
+		}
+		in.CalendarName = stagingCalendarName(snapshot.Name, time.Now())
+	}
+	if utf8.RuneCountInString(in.CalendarName) > 100 {
+		httpError(w, r, http.StatusBadRequest, "calendar_name must be <= 100 characters")
+		return
+	}
+
+	calendar, restored, err := sc.snapshotRepo.RestoreSnapshot(ctx, id, internal.Calendar{
+		ID:      uuid.New(),
+		Name:    in.CalendarName,
+		OwnerID: principalID(r),
+	})
+	if err != nil {
+		repositoryError(ctx, w, r, err, "restoring snapshot", "Failed to restore snapshot")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(restoreSnapshotResponse{Calendar: *calendar, Restored: restored})
+}
+
+// stagingCalendarName names the calendar a snapshot is restored into, truncating long
+// snapshot names so the result fits the 100 character limit
+func stagingCalendarName(snapshot string, now time.Time) string {
+	suffix := fmt.Sprintf(" (%s)", now.UTC().Format("2006-01-02 15:04"))
+	name := []rune("Restore of " + snapshot)
+	if max := 100 - len(suffix); len(name) > max {
+		name = name[:max]
+	}
+	return string(name) + suffix
+}
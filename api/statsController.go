@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"taller_challenge/internal"
+	"time"
+)
+
+// defaultStatsDays is the period of stats queries without from
+const defaultStatsDays = 30
+
+// statsResponse is the event counts of a period, group by group
+type statsResponse struct {
+	Group string                   `json:"group"`
+	From  string                   `json:"from"`
+	To    string                   `json:"to"`
+	Stats []internal.EventStatsRow `json:"stats"`
+}
+
+// GetStats handles GET /events/stats?from=&to=&group=day|week|calendar&calendar_id=,
+// the number and total minutes of published events starting on each UTC day from
+// from up to to, both YYYY-MM-DD, by day, week or calendar. The period defaults to the
+// last 30 days. Counts come from a view refreshed by the refresh_event_stats job.
+func (ec *EventController) GetStats(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	query := r.URL.Query()
+	group := query.Get("group")
+	if group == "" {
+		group = internal.StatsGroupDay
+	}
+	if !internal.ValidStatsGroup(group) {
+		httpError(w, r, http.StatusBadRequest, "group must be day, week or calendar")
+		return
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	to := today.AddDate(0, 0, 1)
+	if v := query.Get("to"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "to must be a YYYY-MM-DD date")
+			return
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -defaultStatsDays)
+	if v := query.Get("from"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "from must be a YYYY-MM-DD date")
+			return
+		}
+		from = t
+	}
+	if !to.After(from) {
+		httpError(w, r, http.StatusBadRequest, "to must be after from")
+		return
+	}
+	if to.Sub(from) > internal.MaxStatsDays*24*time.Hour {
+		httpError(w, r, http.StatusBadRequest, "stats cover at most %d days", internal.MaxStatsDays)
+		return
+	}
+
+	q := internal.EventStatsQuery{From: from, To: to, Group: group}
+	if v := query.Get("calendar_id"); v != "" {
+		id, err := internal.ParseUUIDParam("calendar_id", v)
+		if err != nil {
+			paramError(w, r, err)
+			return
+		}
+		q.CalendarID = &id
+	}
+
+	stats, err := ec.eventRepo.EventStats(ctx, q)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting event stats", "Failed to get events")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statsResponse{Group: group, From: from.Format(time.DateOnly), To: to.Format(time.DateOnly), Stats: stats})
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventStats records the stats query and answers with one row
+type eventStats struct {
+	fakeEventRepository
+	query internal.EventStatsQuery
+}
+
+func (f *eventStats) EventStats(ctx context.Context, q internal.EventStatsQuery) ([]internal.EventStatsRow, error) {
+	f.query = q
+	return []internal.EventStatsRow{{Key: q.From.Format(time.DateOnly), Events: 3, Minutes: 180}}, nil
+}
+
+func TestGetStats(t *testing.T) {
+	repo := &eventStats{}
+	srv, err := NewServer(internal.Config{}, Dependencies{Events: repo})
+	require.NoError(t, err)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	calendarID := uuid.New()
+	rec := get("/events/stats?from=2025-09-01&to=2025-10-01&group=week&calendar_id=" + calendarID.String())
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var body statsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "week", body.Group)
+	assert.Equal(t, "2025-09-01", body.From)
+	require.Len(t, body.Stats, 1)
+	assert.Equal(t, 3, body.Stats[0].Events)
+	assert.Equal(t, internal.StatsGroupWeek, repo.query.Group)
+	assert.Equal(t, &calendarID, repo.query.CalendarID)
+
+	// The last 30 days by default
+	require.Equal(t, http.StatusOK, get("/events/stats").Code)
+	assert.Equal(t, internal.StatsGroupDay, repo.query.Group)
+	assert.Equal(t, 30*24*time.Hour, repo.query.To.Sub(repo.query.From))
+	assert.True(t, repo.query.To.After(time.Now()))
+
+	assert.Equal(t, http.StatusBadRequest, get("/events/stats?group=tag").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/events/stats?from=2025-10-01&to=2025-09-01").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/events/stats?from=2015-01-01&to=2025-01-01").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/events/stats?from=yesterday").Code)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+	"unicode/utf8"
+)
+
+// suggestMaxAge is how long a browser may reuse suggestions, so retyping the same
+// characters doesn't reach the API again
+const suggestMaxAge = 30 * time.Second
+
+// suggestTimeout bounds a suggestion query; type-ahead answers are useless once the
+// user typed the next character
+const suggestTimeout = 2 * time.Second
+
+// suggestResponse is the titles matching what the user typed
+type suggestResponse struct {
+	Suggestions []internal.TitleSuggestion `json:"suggestions"`
+}
+
+// GetSuggestions handles GET /events/suggest?q=&limit=&calendar_id=&fuzzy=&similarity=,
+// the titles of published events starting with or containing q, with the offsets to
+// highlight. Fuzzy queries, asked with fuzzy=true or a similarity threshold, also
+// suggest titles similar to q.
+func (ec *EventController) GetSuggestions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), suggestTimeout)
+	defer cancel()
+
+	query := r.URL.Query()
+	q := internal.SuggestQuery{Text: strings.TrimSpace(query.Get("q")), Limit: internal.DefaultSuggestLimit}
+	if q.Text == "" {
+		httpError(w, r, http.StatusBadRequest, "q is required")
+		return
+	}
+	if utf8.RuneCountInString(q.Text) > internal.MaxSuggestLength {
+		httpError(w, r, http.StatusBadRequest, "q must be at most %d characters", internal.MaxSuggestLength)
+		return
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > internal.MaxSuggestLimit {
+			httpError(w, r, http.StatusBadRequest, "limit must be between 1 and %d", internal.MaxSuggestLimit)
+			return
+		}
+		q.Limit = n
+	}
+	if v := query.Get("calendar_id"); v != "" {
+		id, err := internal.ParseUUIDParam("calendar_id", v)
+		if err != nil {
+			paramError(w, r, err)
+			return
+		}
+		q.CalendarID = &id
+	}
+
+	if v := query.Get("fuzzy"); v != "" {
+		fuzzy, err := strconv.ParseBool(v)
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "fuzzy must be true or false")
+			return
+		}
+		if fuzzy {
+			q.Similarity = internal.DefaultSimilarity
+		}
+	}
+	if v := query.Get("similarity"); v != "" {
+		similarity, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(similarity > 0 && similarity <= 1) {
+			httpError(w, r, http.StatusBadRequest, "similarity must be a number above 0 and at most 1")
+			return
+		}
+		q.Similarity = similarity
+	}
+
+	if q.Similarity > 0 {
+		internal.UseFeature(ctx, "fuzzy_suggest")
+	}
+	suggestions, err := ec.eventRepo.SuggestTitles(ctx, q)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "suggesting titles", "Failed to get events")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(suggestMaxAge.Seconds())))
+	writeJSON(w, r, suggestResponse{Suggestions: suggestions})
+}
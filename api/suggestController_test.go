@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"taller_challenge/internal"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// suggestions records the suggestion query and answers with one matching title
+type suggestions struct {
+	fakeEventRepository
+	query internal.SuggestQuery
+}
+
+func (f *suggestions) SuggestTitles(ctx context.Context, q internal.SuggestQuery) ([]internal.TitleSuggestion, error) {
+	f.query = q
+	title := "Park run"
+	return []internal.TitleSuggestion{{Title: title, Highlights: internal.MatchOffsets(title, q.Text)}}, nil
+}
+
+func TestGetSuggestions(t *testing.T) {
+	repo := &suggestions{}
+	srv, err := NewServer(internal.Config{}, Dependencies{Events: repo})
+	require.NoError(t, err)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	calendarID := uuid.New()
+	rec := get("/events/suggest?q=par&limit=5&calendar_id=" + calendarID.String())
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "private, max-age=30", rec.Header().Get("Cache-Control"))
+	var body suggestResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Suggestions, 1)
+	assert.Equal(t, [][2]int{{0, 3}}, body.Suggestions[0].Highlights)
+	assert.Equal(t, internal.SuggestQuery{Text: "par", Limit: 5, CalendarID: &calendarID}, repo.query)
+
+	require.Equal(t, http.StatusOK, get("/events/suggest?q=+p+").Code)
+	assert.Equal(t, internal.SuggestQuery{Text: "p", Limit: internal.DefaultSuggestLimit}, repo.query)
+
+	require.Equal(t, http.StatusOK, get("/events/suggest?q=standp+metting&fuzzy=true").Code)
+	assert.Equal(t, internal.DefaultSimilarity, repo.query.Similarity)
+	require.Equal(t, http.StatusOK, get("/events/suggest?q=standp+metting&similarity=0.5").Code)
+	assert.Equal(t, 0.5, repo.query.Similarity)
+
+	assert.Equal(t, http.StatusBadRequest, get("/events/suggest").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/events/suggest?q=par&fuzzy=maybe").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/events/suggest?q=par&similarity=0").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/events/suggest?q=par&similarity=1.5").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/events/suggest?q=par&limit=26").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/events/suggest?q=par&calendar_id=nope").Code)
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Page sizes of GET /sync/changes
+const (
+	defaultSyncLimit = 500
+	maxSyncLimit     = 1000
+)
+
+// maxSyncPush bounds the changes accepted by one POST /sync/changes
+const maxSyncPush = 500
+
+// Statuses of a pushed change that was not attempted: it failed validation, or the
+// event is over the update limit and the change may be pushed again after retry_after
+const (
+	syncRejected  = "rejected"
+	syncThrottled = "throttled"
+)
+
+type syncPullResponse struct {
+	Events  []eventResponse           `json:"events"`
+	Deleted []internal.EventTombstone `json:"deleted"`
+	// Cursor is passed back as ?cursor= on the next pull
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"has_more"`
+}
+
+type syncPushInput struct {
+	Changes []syncChangeInput `json:"changes"`
+}
+
+type syncChangeInput struct {
+	// ID is generated by the client when it creates an event offline
+	ID uuid.UUID `json:"id"`
+	// BaseVersion is the version the client last pulled; 0 for events it created
+	BaseVersion int64             `json:"base_version"`
+	Deleted     bool              `json:"deleted"`
+	Event       *createEventInput `json:"event"`
+}
+
+type syncChangeResult struct {
+	ID      uuid.UUID `json:"id"`
+	Status  string    `json:"status"`
+	Version int64     `json:"version,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	// RetryAfter is the number of seconds to wait before pushing a throttled change again
+	RetryAfter int `json:"retry_after,omitempty"`
+	// Server is the current server copy of a conflicting event
+	Server        *eventResponse `json:"server,omitempty"`
+	ServerDeleted bool           `json:"server_deleted,omitempty"`
+}
+
+type syncPushResponse struct {
+	Results []syncChangeResult `json:"results"`
+}
+
+// PullChanges handles GET /sync/changes?cursor=&limit=
+func (ec *EventController) PullChanges(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := internal.ParseSyncCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+	limit := defaultSyncLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSyncLimit {
+			httpError(w, r, http.StatusBadRequest, "limit must be between 1 and %d", maxSyncLimit)
+			return
+		}
+		limit = n
+	}
+
+	page, err := ec.eventRepo.PullChanges(ctx, cursor, limit)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "pulling changes", "Failed to get changes")
+		return
+	}
+
+	events := ec.decorateEvents(ctx, r, listed(r, page.Events))
+	if events == nil {
+		events = []eventResponse{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(syncPullResponse{
+		Events:  events,
+		Deleted: page.Deleted,
+		Cursor:  page.Next.String(),
+		HasMore: page.HasMore,
+	})
+}
+
+// PushChanges handles POST /sync/changes. Changes are applied in order and each gets
+// its own result; a conflict never overwrites the server copy.
+func (ec *EventController) PushChanges(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in syncPushInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	if len(in.Changes) == 0 || len(in.Changes) > maxSyncPush {
+		httpError(w, r, http.StatusBadRequest, "changes must contain between 1 and %d entries", maxSyncPush)
+		return
+	}
+
+	resp := syncPushResponse{Results: make([]syncChangeResult, 0, len(in.Changes))}
+	for _, change := range in.Changes {
+		result, err := ec.pushChange(ctx, r, change)
+		if err != nil {
+			log.Printf("Error applying sync change to event %s: %v", change.ID, err)
+			if ctx.Err() == context.DeadlineExceeded {
+				httpError(w, r, http.StatusRequestTimeout, "Request timeout")
+				return
+			}
+			httpError(w, r, http.StatusInternalServerError, "Failed to apply changes")
+			return
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// pushChange validates and applies one change. Invalid changes are rejected in the
+// result; the error is only set for server failures.
+func (ec *EventController) pushChange(ctx context.Context, r *http.Request, in syncChangeInput) (syncChangeResult, error) {
+	result := syncChangeResult{ID: in.ID, Status: syncRejected}
+	lang := language(r)
+	reject := func(msg string, args ...any) (syncChangeResult, error) {
+		result.Error = internal.Translate(lang, msg, args...)
+		return result, nil
+	}
+
+	if in.ID == uuid.Nil {
+		return reject("id is required")
+	}
+	if in.BaseVersion < 0 {
+		return reject("base_version must not be negative")
+	}
+	change := internal.SyncChange{BaseVersion: in.BaseVersion, Deleted: in.Deleted, Event: internal.EventDB{ID: in.ID}}
+	if !in.Deleted {
+		if in.Event == nil {
+			return reject("event is required unless deleted is true")
+		}
+		if !ec.sanitizeEventInput(r, in.Event) {
+			return reject("input rejected by security policy")
+		}
+		if msg := validateEventInput(in.Event); msg != "" {
+			return reject(msg)
+		}
+		if h := ec.busyHoliday(ctx, *in.Event); h != nil {
+			return reject("event falls on a public holiday: %s (%s)", h.Name, h.Date)
+		}
+		if in.BaseVersion > 0 {
+			if retry, ok := ec.throttleUpdate(r, in.ID); !ok {
+				result.Status = syncThrottled
+				result.RetryAfter = retry
+				return reject("event updated too often, retry in %d seconds", retry)
+			}
+		}
+		e := in.Event
+		change.Event = internal.EventDB{
+			ID:                in.ID,
+			CalendarID:        e.CalendarID,
+			Title:             e.Title,
+			Description:       e.Description,
+			DescriptionFormat: e.DescriptionFormat,
+			StartTime:         e.StartTime.UTC(),
+			EndTime:           e.EndTime.UTC(),
+			Location:          e.Location,
+			Latitude:          e.Latitude,
+			Longitude:         e.Longitude,
+		}
+		ec.submitForReview(r, &change.Event)
+	}
+
+	outcome, err := ec.eventRepo.ApplySyncChange(ctx, change)
+	if errors.Is(err, internal.ErrValidation) || errors.Is(err, internal.ErrForbidden) {
+		return reject(internal.DomainMessage(err))
+	}
+	var dependents *internal.DependentsError
+	if errors.As(err, &dependents) {
+		return reject("Cannot delete the %s while it has %s", dependents.Kind, dependents.List())
+	}
+	if err != nil {
+		return result, err
+	}
+	if !in.Deleted && in.BaseVersion > 0 && outcome.Status == internal.SyncApplied {
+		ec.throttle.Record(in.ID)
+	}
+
+	result.Status = outcome.Status
+	result.Version = outcome.Version
+	result.ServerDeleted = outcome.ServerDeleted
+	if outcome.Server != nil {
+		server := ec.decorateEvent(ctx, r, *outcome.Server)
+		result.Server = &server
+	}
+	return result, nil
+}
+
+// busyHoliday returns the holiday an event falls on when the busy holiday policy
+// forbids it. Under the warn policy synced changes are accepted without warnings.
+func (ec *EventController) busyHoliday(ctx context.Context, in createEventInput) *internal.Holiday {
+	if ec.cfg.HolidayCountry == "" || ec.cfg.HolidayPolicy != internal.HolidayPolicyBusy {
+		return nil
+	}
+	holidays, err := internal.HolidaysOn(ctx, ec.holidays, ec.cfg.HolidayCountry, in.StartTime, in.EndTime)
+	if err != nil {
+		log.Printf("Error checking holidays: %v", err)
+		return nil
+	}
+	if len(holidays) == 0 {
+		return nil
+	}
+	return &holidays[0]
+}
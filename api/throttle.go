@@ -0,0 +1,50 @@
+package api
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"taller_challenge/internal"
+
+	"github.com/google/uuid"
+)
+
+// allowUpdate applies the per-event update limit to a modification of event id; the
+// update is counted once it succeeds, with ec.throttle.Record. It returns false when
+// the response has already been written.
+func (ec *EventController) allowUpdate(w http.ResponseWriter, r *http.Request, id uuid.UUID) bool {
+	retry, ok := ec.throttleUpdate(r, id)
+	if ok {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retry))
+	httpError(w, r, http.StatusTooManyRequests, "event updated too often, retry in %d seconds", retry)
+	return false
+}
+
+// throttleUpdate checks a modification of event id against the limit. When the event
+// is over it, it logs the client responsible and returns the seconds until it may be
+// updated again.
+func (ec *EventController) throttleUpdate(r *http.Request, id uuid.UUID) (int, bool) {
+	ok, wait := ec.throttle.Allow(id)
+	if ok {
+		return 0, true
+	}
+	log.Printf("Throttle: rejected update of event %s by %s from %s (%q) %s %s: over %d updates per minute",
+		id, clientName(r), r.RemoteAddr, r.UserAgent(), r.Method, r.URL.Path, ec.cfg.EventUpdateLimit)
+	return int(math.Ceil(wait.Seconds())), false
+}
+
+// clientName identifies the caller of r for logs
+func clientName(r *http.Request) string {
+	p := internal.PrincipalFromContext(r.Context())
+	switch {
+	case p == nil:
+		return "anonymous"
+	case p.TokenID != nil:
+		return "token " + p.TokenID.String() + " of " + p.UserID
+	default:
+		return p.UserID
+	}
+}
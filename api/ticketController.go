@@ -0,0 +1,291 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// TicketController handles HTTP requests for event tickets. Reservations are
+// personal: holders see and cancel their own, admins everyone's.
+type TicketController struct {
+	tickets internal.TicketRepositoryInterface
+	events  internal.EventRepositoryInterface
+	// payments, when set, takes the payment of held reservations
+	payments  internal.PaymentProvider
+	hold      time.Duration
+	publicURL string
+	returnURL string
+}
+
+// NewTicketController creates a new ticket controller holding reservations of paid
+// events for hold. With payments, held reservations get a checkout page returning
+// the payer to returnURL.
+func NewTicketController(tickets internal.TicketRepositoryInterface, events internal.EventRepositoryInterface, payments internal.PaymentProvider, hold time.Duration, publicURL, returnURL string) *TicketController {
+	return &TicketController{tickets: tickets, events: events, payments: payments, hold: hold, publicURL: publicURL, returnURL: returnURL}
+}
+
+// RegisterRoutes adds the ticket endpoints to router
+func (tc *TicketController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/events/{id}/tickets", requireScope(internal.ScopeEventsRead, tc.GetTickets)).Methods("GET")
+	router.HandleFunc("/events/{id}/tickets", requireScope(internal.ScopeEventsRead, tc.ReserveTickets)).Methods("POST")
+	router.HandleFunc("/events/{id}/tickets/{reservationId}", requireScope(internal.ScopeEventsRead, tc.GetReservation)).Methods("GET")
+	router.HandleFunc("/events/{id}/tickets/{reservationId}", requireScope(internal.ScopeEventsRead, tc.CancelReservation)).Methods("DELETE")
+	router.HandleFunc("/events/{id}/tickets/{reservationId}/confirm", requireAdmin(tc.ConfirmReservation)).Methods("POST")
+	if tc.payments != nil {
+		router.HandleFunc("/events/{id}/tickets/{reservationId}/refund", requireAdmin(tc.RefundReservation)).Methods("POST")
+	}
+}
+
+type reserveTicketsInput struct {
+	Quantity int `json:"quantity"`
+}
+
+// ticketsResponse is what is left of an event's tickets and the caller's reservations
+type ticketsResponse struct {
+	internal.TicketAvailability
+	PriceCents   *int64                       `json:"price_cents,omitempty"`
+	Currency     *string                      `json:"currency,omitempty"`
+	Reservations []internal.TicketReservation `json:"reservations"`
+}
+
+// holdsReservation reports whether the caller may see and cancel res
+func holdsReservation(r *http.Request, res internal.TicketReservation) bool {
+	p := internal.PrincipalFromContext(r.Context())
+	return p == nil || p.Admin || p.UserID == res.HolderID
+}
+
+// GetTickets handles GET /events/{id}/tickets
+func (tc *TicketController) GetTickets(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	event := loadVisibleEvent(ctx, w, r, tc.events)
+	if event == nil {
+		return
+	}
+	availability, err := tc.tickets.GetAvailability(ctx, event.ID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting ticket availability", "Failed to get tickets")
+		return
+	}
+	reservations, err := tc.tickets.ListReservations(ctx, event.ID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing reservations", "Failed to get tickets")
+		return
+	}
+	own := reservations[:0:0]
+	for _, res := range reservations {
+		if holdsReservation(r, res) {
+			own = append(own, res)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ticketsResponse{
+		TicketAvailability: *availability,
+		PriceCents:         event.PriceCents,
+		Currency:           event.Currency,
+		Reservations:       own,
+	})
+}
+
+// ReserveTickets handles POST /events/{id}/tickets. Tickets of free events are
+// confirmed at once; those of paid events are held until paid or until they expire,
+// with the checkout_url to pay them at when a payment provider is configured.
+func (tc *TicketController) ReserveTickets(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	in := reserveTicketsInput{Quantity: 1}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	if in.Quantity < 1 || in.Quantity > internal.MaxTicketsPerReservation {
+		httpError(w, r, http.StatusBadRequest, "quantity must be between 1 and %d", internal.MaxTicketsPerReservation)
+		return
+	}
+
+	event := loadVisibleEvent(ctx, w, r, tc.events)
+	if event == nil {
+		return
+	}
+	if !event.Published() {
+		httpError(w, r, http.StatusBadRequest, "tickets are only sold for published events")
+		return
+	}
+
+	reservation, err := tc.tickets.Reserve(ctx, internal.TicketReservation{
+		ID:       uuid.New(),
+		EventID:  event.ID,
+		HolderID: principalID(r),
+		Quantity: in.Quantity,
+	}, time.Now().Add(tc.hold))
+	if err != nil {
+		repositoryError(ctx, w, r, err, "reserving tickets", "Failed to reserve tickets")
+		return
+	}
+	if reservation.Status == internal.TicketStatusHeld && tc.payments != nil {
+		if reservation = tc.startCheckout(ctx, w, r, event, reservation); reservation == nil {
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reservation)
+}
+
+// GetReservation handles GET /events/{id}/tickets/{reservationId}
+func (tc *TicketController) GetReservation(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	_, res := tc.loadReservation(ctx, w, r)
+	if res == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// CancelReservation handles DELETE /events/{id}/tickets/{reservationId}, returning the
+// tickets to the event
+func (tc *TicketController) CancelReservation(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	event, res := tc.loadReservation(ctx, w, r)
+	if res == nil {
+		return
+	}
+	if res.Status == internal.TicketStatusConfirmed && res.PaymentID != nil {
+		httpError(w, r, http.StatusConflict, "paid reservations are refunded, not cancelled")
+		return
+	}
+
+	if err := tc.tickets.CancelReservation(ctx, event.ID, res.ID); err != nil {
+		repositoryError(ctx, w, r, err, "cancelling reservation", "Failed to cancel reservation")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ConfirmReservation handles POST /events/{id}/tickets/{reservationId}/confirm,
+// recording that a held reservation was paid
+func (tc *TicketController) ConfirmReservation(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	event, res := tc.loadReservation(ctx, w, r)
+	if res == nil {
+		return
+	}
+
+	confirmed, err := tc.tickets.ConfirmReservation(ctx, event.ID, res.ID, "")
+	if err != nil {
+		repositoryError(ctx, w, r, err, "confirming reservation", "Failed to confirm reservation")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(confirmed)
+}
+
+// RefundReservation handles POST /events/{id}/tickets/{reservationId}/refund,
+// refunding a reservation paid through the payment provider and returning its
+// tickets to the event
+func (tc *TicketController) RefundReservation(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	event, res := tc.loadReservation(ctx, w, r)
+	if res == nil {
+		return
+	}
+	if res.Status != internal.TicketStatusConfirmed || res.PaymentID == nil {
+		repositoryError(ctx, w, r, internal.ErrNotRefundable, "refunding reservation", "Failed to refund reservation")
+		return
+	}
+
+	// The provider refunds a reservation once, so a retry after the update below
+	// failed does not pay out twice
+	if err := tc.payments.Refund(ctx, res.ID, *res.PaymentID); err != nil {
+		log.Printf("Error refunding reservation %s: %v", res.ID, err)
+		httpError(w, r, http.StatusBadGateway, "Failed to refund reservation")
+		return
+	}
+	if err := tc.tickets.RefundReservation(ctx, event.ID, res.ID); err != nil {
+		repositoryError(ctx, w, r, err, "refunding reservation", "Failed to refund reservation")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startCheckout creates the checkout page of a held reservation. When the provider
+// fails, the reservation is cancelled so its tickets are not held for nothing.
+func (tc *TicketController) startCheckout(ctx context.Context, w http.ResponseWriter, r *http.Request, event *internal.EventDB, res *internal.TicketReservation) *internal.TicketReservation {
+	returnURL := tc.returnURL
+	if returnURL == "" {
+		returnURL = requestBaseURL(r, tc.publicURL) + "/events/{event}/tickets/{reservation}"
+	}
+	returnURL = strings.NewReplacer("{event}", event.ID.String(), "{reservation}", res.ID.String()).Replace(returnURL)
+
+	checkout, err := tc.payments.CreateCheckout(ctx, internal.CheckoutRequest{
+		Reservation: *res,
+		EventTitle:  event.Title,
+		ReturnURL:   returnURL,
+		ExpiresAt:   *res.ExpiresAt,
+	})
+	if err == nil {
+		var updated *internal.TicketReservation
+		if updated, err = tc.tickets.SetCheckout(ctx, event.ID, res.ID, tc.payments.Name(), *checkout); err == nil {
+			return updated
+		}
+	}
+
+	log.Printf("Error starting checkout of reservation %s: %v", res.ID, err)
+	if err := tc.tickets.CancelReservation(ctx, event.ID, res.ID); err != nil {
+		log.Printf("Warning: failed to release tickets of reservation %s: %v", res.ID, err)
+	}
+	httpError(w, r, http.StatusBadGateway, "Failed to start checkout")
+	return nil
+}
+
+// loadReservation fetches the event and the caller's reservation named in the URL,
+// writing an error when either fails. Other holders' reservations are reported as
+// not found.
+func (tc *TicketController) loadReservation(ctx context.Context, w http.ResponseWriter, r *http.Request) (*internal.EventDB, *internal.TicketReservation) {
+	reservationID, err := uuid.Parse(mux.Vars(r)["reservationId"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return nil, nil
+	}
+	event := loadVisibleEvent(ctx, w, r, tc.events)
+	if event == nil {
+		return nil, nil
+	}
+
+	res, err := tc.tickets.GetReservation(ctx, event.ID, reservationID)
+	if err == nil && !holdsReservation(r, *res) {
+		err = internal.ErrReservationNotFound
+	}
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting reservation", "Failed to get reservation")
+		return nil, nil
+	}
+	return event, res
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTicketRepository sells tickets from a fixed quota, at price when set
+type fakeTicketRepository struct {
+	internal.TicketRepositoryInterface
+	quota        int
+	price        int64
+	reservations []internal.TicketReservation
+	holdUntil    time.Time
+}
+
+func (f *fakeTicketRepository) reserved() int {
+	n := 0
+	for _, res := range f.reservations {
+		if res.Status == internal.TicketStatusHeld || res.Status == internal.TicketStatusConfirmed {
+			n += res.Quantity
+		}
+	}
+	return n
+}
+
+// update applies change to a reservation and returns it
+func (f *fakeTicketRepository) update(id uuid.UUID, change func(*internal.TicketReservation) error) (*internal.TicketReservation, error) {
+	for i := range f.reservations {
+		if f.reservations[i].ID == id {
+			if err := change(&f.reservations[i]); err != nil {
+				return nil, err
+			}
+			res := f.reservations[i]
+			return &res, nil
+		}
+	}
+	return nil, internal.ErrReservationNotFound
+}
+
+func (f *fakeTicketRepository) Reserve(ctx context.Context, res internal.TicketReservation, holdUntil time.Time) (*internal.TicketReservation, error) {
+	if f.reserved()+res.Quantity > f.quota {
+		return nil, internal.ErrSoldOut
+	}
+	f.holdUntil = holdUntil
+	res.Status = internal.TicketStatusConfirmed
+	if f.price > 0 {
+		amount, currency := f.price*int64(res.Quantity), "EUR"
+		res.Status, res.AmountCents, res.Currency, res.ExpiresAt = internal.TicketStatusHeld, &amount, &currency, &holdUntil
+	}
+	f.reservations = append(f.reservations, res)
+	return &res, nil
+}
+
+func (f *fakeTicketRepository) GetAvailability(ctx context.Context, eventID uuid.UUID) (*internal.TicketAvailability, error) {
+	return &internal.TicketAvailability{Quota: f.quota, Reserved: f.reserved(), Available: f.quota - f.reserved()}, nil
+}
+
+func (f *fakeTicketRepository) ListReservations(ctx context.Context, eventID uuid.UUID) ([]internal.TicketReservation, error) {
+	return f.reservations, nil
+}
+
+func (f *fakeTicketRepository) GetReservation(ctx context.Context, eventID, id uuid.UUID) (*internal.TicketReservation, error) {
+	for _, res := range f.reservations {
+		if res.ID == id {
+			return &res, nil
+		}
+	}
+	return nil, internal.ErrReservationNotFound
+}
+
+func (f *fakeTicketRepository) CancelReservation(ctx context.Context, eventID, id uuid.UUID) error {
+	_, err := f.update(id, func(res *internal.TicketReservation) error {
+		if res.Status != internal.TicketStatusHeld && res.Status != internal.TicketStatusConfirmed {
+			return internal.ErrReservationClosed
+		}
+		res.Status = internal.TicketStatusCancelled
+		return nil
+	})
+	return err
+}
+
+func (f *fakeTicketRepository) SetCheckout(ctx context.Context, eventID, id uuid.UUID, provider string, checkout internal.Checkout) (*internal.TicketReservation, error) {
+	return f.update(id, func(res *internal.TicketReservation) error {
+		res.PaymentProvider, res.CheckoutSessionID, res.CheckoutURL = &provider, &checkout.SessionID, &checkout.URL
+		return nil
+	})
+}
+
+func (f *fakeTicketRepository) ConfirmReservation(ctx context.Context, eventID, id uuid.UUID, paymentID string) (*internal.TicketReservation, error) {
+	return f.update(id, func(res *internal.TicketReservation) error {
+		if res.Status != internal.TicketStatusHeld && res.Status != internal.TicketStatusConfirmed {
+			return internal.ErrReservationClosed
+		}
+		res.Status, res.CheckoutURL = internal.TicketStatusConfirmed, nil
+		if paymentID != "" {
+			res.PaymentID = &paymentID
+		}
+		return nil
+	})
+}
+
+func (f *fakeTicketRepository) RefundReservation(ctx context.Context, eventID, id uuid.UUID) error {
+	_, err := f.update(id, func(res *internal.TicketReservation) error {
+		if res.Status != internal.TicketStatusConfirmed || res.PaymentID == nil {
+			return internal.ErrNotRefundable
+		}
+		res.Status = internal.TicketStatusRefunded
+		return nil
+	})
+	return err
+}
+
+func TestReserveTickets(t *testing.T) {
+	start := time.Date(2025, 9, 15, 20, 0, 0, 0, time.UTC)
+	concert := internal.EventDB{ID: uuid.New(), Title: "Concert", StartTime: start, EndTime: start.Add(2 * time.Hour), Status: "approved"}
+	draft := internal.EventDB{ID: uuid.New(), Title: "Draft", StartTime: start, EndTime: start.Add(time.Hour), Status: "pending", SubmittedBy: "alice"}
+	events := &eventsByID{byID: map[uuid.UUID]internal.EventDB{concert.ID: concert, draft.ID: draft}}
+	tickets := &fakeTicketRepository{quota: 3}
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		return &internal.Principal{UserID: r.Header.Get("X-User"), Scopes: []string{internal.ScopeEventsRead}}, nil
+	}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret", TicketHold: 10 * time.Minute}, Dependencies{Events: events, Tickets: tickets, Auth: hook})
+	require.NoError(t, err)
+	do := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	base := "/events/" + concert.ID.String() + "/tickets"
+
+	rec := do(http.MethodPost, base, "alice", `{"quantity": 2}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var reservation internal.TicketReservation
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reservation))
+	assert.Equal(t, "alice", reservation.HolderID)
+	assert.WithinDuration(t, time.Now().Add(10*time.Minute), tickets.holdUntil, time.Minute)
+
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, base, "bob", `{"quantity": 2}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, base, "bob", `{"quantity": 0}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/events/"+draft.ID.String()+"/tickets", "alice", `{}`).Code)
+
+	// Bob sees the availability but not Alice's reservation
+	rec = do(http.MethodGet, base, "bob", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var summary ticketsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &summary))
+	assert.Equal(t, 1, summary.Available)
+	assert.Empty(t, summary.Reservations)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, base+"/"+reservation.ID.String(), "bob", "").Code)
+
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, base+"/"+reservation.ID.String(), "alice", "").Code)
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, base, "bob", `{"quantity": 3}`).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, base+"/"+reservation.ID.String()+"/confirm", "alice", "").Code)
+}
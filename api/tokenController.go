@@ -0,0 +1,328 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// TokenController handles HTTP requests for personal API tokens
+type TokenController struct {
+	tokenRepo internal.TokenRepositoryInterface
+	twoFactor internal.TwoFactorRepositoryInterface
+	orgs      internal.OrganizationRepositoryInterface
+	lockout   *internal.AuthLockout
+}
+
+// NewTokenController creates a new token controller. When twoFactor is set, users
+// with two-factor authentication enabled, or required by one of their organizations
+// in orgs, need a code to create tokens. twoFactor, orgs and lockout may be nil.
+func NewTokenController(tokenRepo internal.TokenRepositoryInterface, twoFactor internal.TwoFactorRepositoryInterface, orgs internal.OrganizationRepositoryInterface, lockout *internal.AuthLockout) *TokenController {
+	return &TokenController{tokenRepo: tokenRepo, twoFactor: twoFactor, orgs: orgs, lockout: lockout}
+}
+
+// RegisterRoutes adds the token endpoints to router
+func (tc *TokenController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/tokens", tc.CreateToken).Methods("POST")
+	router.HandleFunc("/tokens", tc.GetTokens).Methods("GET")
+	router.HandleFunc("/tokens/{id}", tc.DeleteToken).Methods("DELETE")
+	router.HandleFunc("/me/sessions", tc.GetSessions).Methods("GET")
+	router.HandleFunc("/me/sessions", tc.RevokeOtherSessions).Methods("DELETE")
+	router.HandleFunc("/me/sessions/{id}", tc.RevokeSession).Methods("DELETE")
+	router.HandleFunc("/admin/users/{userId}/sessions", requireAdmin(tc.RevokeUserSessions)).Methods("DELETE")
+}
+
+// maxUserAgent bounds the User-Agent recorded with a token
+const maxUserAgent = 200
+
+type createTokenInput struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is optional; tokens without it never expire
+	ExpiresAt *time.Time `json:"expires_at"`
+	// UserID lets the admin key mint tokens for users
+	UserID string `json:"user_id"`
+}
+
+// createTokenResponse is the only place the token secret is ever returned
+type createTokenResponse struct {
+	internal.APIToken
+	Token string `json:"token"`
+}
+
+// sessionResponse is an active token, flagged when it authenticates the request
+type sessionResponse struct {
+	internal.APIToken
+	Current bool `json:"current"`
+}
+
+type revokedSessionsResponse struct {
+	Revoked int `json:"revoked"`
+}
+
+// caller returns the authenticated principal, writing an error when there is none
+func caller(w http.ResponseWriter, r *http.Request) *internal.Principal {
+	p := internal.PrincipalFromContext(r.Context())
+	if p == nil {
+		httpError(w, r, http.StatusNotFound, "authentication is not enabled")
+	}
+	return p
+}
+
+// CreateToken handles POST /tokens
+func (tc *TokenController) CreateToken(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := caller(w, r)
+	if p == nil {
+		return
+	}
+
+	var in createTokenInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" || len(in.Name) > 100 {
+		httpError(w, r, http.StatusBadRequest, "name is required and must be <= 100 characters")
+		return
+	}
+	if len(in.Scopes) == 0 {
+		httpError(w, r, http.StatusBadRequest, "at least one scope is required")
+		return
+	}
+	for _, scope := range in.Scopes {
+		if !internal.ValidScope(scope) {
+			httpError(w, r, http.StatusBadRequest, "unknown scope %s", scope)
+			return
+		}
+		// A token can never grant more than its creator holds
+		if !p.HasScope(scope) {
+			httpError(w, r, http.StatusForbidden, "token lacks required scope %s", scope)
+			return
+		}
+	}
+	if in.ExpiresAt != nil && !in.ExpiresAt.After(time.Now()) {
+		httpError(w, r, http.StatusBadRequest, "expires_at must be in the future")
+		return
+	}
+
+	userID := p.UserID
+	if in.UserID != "" && in.UserID != userID {
+		if !p.Admin {
+			httpError(w, r, http.StatusForbidden, "only the admin key can create tokens for other users")
+			return
+		}
+		userID = in.UserID
+	}
+	if !p.Admin && tc.twoFactor != nil && !tc.checkTwoFactor(ctx, w, r, userID) {
+		return
+	}
+
+	secret, hash, err := internal.GenerateTokenSecret()
+	if err != nil {
+		log.Printf("Error generating token: %v", err)
+		httpError(w, r, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+
+	token := internal.APIToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      in.Name,
+		Scopes:    in.Scopes,
+		CreatedIP: clientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+	if len(token.UserAgent) > maxUserAgent {
+		token.UserAgent = strings.ToValidUTF8(token.UserAgent[:maxUserAgent], "")
+	}
+	if in.ExpiresAt != nil {
+		expires := in.ExpiresAt.UTC()
+		token.ExpiresAt = &expires
+	}
+
+	created, err := tc.tokenRepo.CreateToken(ctx, token, hash)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "creating token", "Failed to create token")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createTokenResponse{APIToken: *created, Token: secret})
+}
+
+// checkTwoFactor enforces two-factor authentication on creating tokens, writing an
+// error when it fails: users who enabled it give a current code, and users whose
+// organization requires it must enable it first
+func (tc *TokenController) checkTwoFactor(ctx context.Context, w http.ResponseWriter, r *http.Request, userID string) bool {
+	enabled, err := internal.TwoFactorEnabled(ctx, tc.twoFactor, userID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting two-factor enrollment", "Failed to create token")
+		return false
+	}
+	if enabled {
+		return verifyTwoFactor(ctx, w, r, tc.twoFactor, tc.lockout, userID)
+	}
+	required, err := twoFactorRequiredBy(ctx, tc.orgs, userID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing organizations", "Failed to create token")
+		return false
+	}
+	if len(required) > 0 {
+		httpError(w, r, http.StatusForbidden, "organization %s requires two-factor authentication, enable it at /me/2fa first", required[0])
+		return false
+	}
+	return true
+}
+
+// GetTokens handles GET /tokens
+func (tc *TokenController) GetTokens(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := caller(w, r)
+	if p == nil {
+		return
+	}
+
+	tokens, err := tc.tokenRepo.ListTokens(ctx, p.UserID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing tokens", "Failed to get tokens")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// DeleteToken handles DELETE /tokens/{id}
+func (tc *TokenController) DeleteToken(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := caller(w, r)
+	if p == nil {
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	if err := tc.tokenRepo.DeleteToken(ctx, p.UserID, id); err != nil {
+		repositoryError(ctx, w, r, err, "deleting token", "Failed to delete token")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSessions handles GET /me/sessions, the caller's tokens that still authenticate
+func (tc *TokenController) GetSessions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := caller(w, r)
+	if p == nil {
+		return
+	}
+
+	tokens, err := tc.tokenRepo.ListTokens(ctx, p.UserID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing tokens", "Failed to get sessions")
+		return
+	}
+	now := time.Now()
+	sessions := []sessionResponse{}
+	for _, token := range tokens {
+		if token.Active(now) {
+			sessions = append(sessions, sessionResponse{APIToken: token, Current: p.TokenID != nil && *p.TokenID == token.ID})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// RevokeSession handles DELETE /me/sessions/{id}. The token stops authenticating at
+// once, on every instance.
+func (tc *TokenController) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := caller(w, r)
+	if p == nil {
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+
+	if err := tc.tokenRepo.RevokeToken(ctx, p.UserID, id); err != nil {
+		repositoryError(ctx, w, r, err, "revoking token", "Failed to revoke session")
+		return
+	}
+	log.Printf("Security: %s revoked token %s", p.UserID, id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOtherSessions handles DELETE /me/sessions, revoking every token of the caller
+// but the one making the request
+func (tc *TokenController) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := caller(w, r)
+	if p == nil {
+		return
+	}
+
+	n, err := tc.tokenRepo.RevokeTokens(ctx, p.UserID, p.TokenID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "revoking tokens", "Failed to revoke sessions")
+		return
+	}
+	log.Printf("Security: %s revoked %d other tokens", p.UserID, n)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revokedSessionsResponse{Revoked: n})
+}
+
+// RevokeUserSessions handles DELETE /admin/users/{userId}/sessions, revoking every
+// token of a user, such as one whose credentials leaked
+func (tc *TokenController) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	userID := mux.Vars(r)["userId"]
+	n, err := tc.tokenRepo.RevokeTokens(ctx, userID, nil)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "revoking tokens", "Failed to revoke sessions")
+		return
+	}
+	log.Printf("Security: %s revoked %d tokens of %s", principalID(r), n, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revokedSessionsResponse{Revoked: n})
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"taller_challenge/internal"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions(t *testing.T) {
+	tokens := &fakeTokenRepository{tokens: map[string]internal.APIToken{}}
+	laptop, phone, tablet := uuid.New(), uuid.New(), uuid.New()
+	tokens.tokens[string(internal.HashToken("tc_laptop"))] = internal.APIToken{ID: laptop, UserID: "ana", Scopes: []string{internal.ScopeEventsRead}}
+	tokens.tokens[string(internal.HashToken("tc_phone"))] = internal.APIToken{ID: phone, UserID: "ana", Scopes: []string{internal.ScopeEventsRead}}
+	tokens.tokens[string(internal.HashToken("tc_tablet"))] = internal.APIToken{ID: tablet, UserID: "ana", Scopes: []string{internal.ScopeEventsRead}}
+	tokens.tokens[string(internal.HashToken("tc_bob"))] = internal.APIToken{ID: uuid.New(), UserID: "bob", Scopes: []string{internal.ScopeEventsRead}}
+
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{Events: &fakeEventRepository{}, Tokens: tokens})
+	require.NoError(t, err)
+	do := func(method, path, secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+secret)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	sessions := func(secret string) []sessionResponse {
+		rec := do(http.MethodGet, "/me/sessions", secret)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var list []sessionResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+		return list
+	}
+
+	list := sessions("tc_laptop")
+	require.Len(t, list, 3)
+	for _, s := range list {
+		assert.Equal(t, s.ID == laptop, s.Current)
+	}
+
+	// A revoked token stops authenticating at once, and cannot be revoked twice
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/me/sessions/"+phone.String(), "tc_laptop").Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/me/sessions", "tc_phone").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/me/sessions/"+phone.String(), "tc_laptop").Code)
+	assert.Len(t, sessions("tc_laptop"), 2)
+
+	// Users cannot revoke the sessions of others
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/me/sessions/"+laptop.String(), "tc_bob").Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/admin/users/ana/sessions", "tc_bob").Code)
+
+	// Revoking the other sessions keeps the current one
+	rec := do(http.MethodDelete, "/me/sessions", "tc_tablet")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"revoked": 1}`, rec.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/me/sessions", "tc_laptop").Code)
+	require.Len(t, sessions("tc_tablet"), 1)
+
+	rec = do(http.MethodDelete, "/admin/users/ana/sessions", "admin-secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"revoked": 1}`, rec.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/me/sessions", "tc_tablet").Code)
+	assert.Len(t, sessions("tc_bob"), 1)
+}
@@ -0,0 +1,301 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// TwoFactorController handles TOTP two-factor enrollment of the caller, and its
+// reset by the admin key for users who lost their authenticator and recovery codes
+type TwoFactorController struct {
+	twoFactor internal.TwoFactorRepositoryInterface
+	orgs      internal.OrganizationRepositoryInterface
+	lockout   *internal.AuthLockout
+	issuer    string
+}
+
+// NewTwoFactorController creates a new two-factor controller labeling secrets with
+// issuer in authenticator apps. orgs and lockout may be nil.
+func NewTwoFactorController(twoFactor internal.TwoFactorRepositoryInterface, orgs internal.OrganizationRepositoryInterface, lockout *internal.AuthLockout, issuer string) *TwoFactorController {
+	return &TwoFactorController{twoFactor: twoFactor, orgs: orgs, lockout: lockout, issuer: issuer}
+}
+
+// RegisterRoutes adds the two-factor endpoints to router
+func (tc *TwoFactorController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/me/2fa", tc.GetTwoFactor).Methods("GET")
+	router.HandleFunc("/me/2fa", tc.StartTwoFactor).Methods("POST")
+	router.HandleFunc("/me/2fa", tc.DeleteTwoFactor).Methods("DELETE")
+	router.HandleFunc("/me/2fa/confirm", tc.ConfirmTwoFactor).Methods("POST")
+	router.HandleFunc("/me/2fa/recovery-codes", tc.RegenerateRecoveryCodes).Methods("POST")
+	router.HandleFunc("/admin/users/{userId}/2fa", requireAdmin(tc.ResetTwoFactor)).Methods("DELETE")
+}
+
+type twoFactorStatus struct {
+	Enabled bool `json:"enabled"`
+	*internal.TwoFactor
+	// RequiredBy names the caller's organizations requiring two-factor authentication
+	RequiredBy []string `json:"required_by"`
+}
+
+// startTwoFactorResponse is the only place the TOTP secret is ever returned
+type startTwoFactorResponse struct {
+	Secret string `json:"secret"`
+	// ProvisioningURI is the otpauth:// URI to show as a QR code
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type confirmTwoFactorInput struct {
+	Code string `json:"code"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// twoFactorUser returns the caller, writing an error when there is none or it is the
+// admin key, which has no account to protect
+func twoFactorUser(w http.ResponseWriter, r *http.Request) *internal.Principal {
+	p := caller(w, r)
+	if p != nil && p.Admin {
+		httpError(w, r, http.StatusForbidden, "the admin key cannot use two-factor authentication")
+		return nil
+	}
+	return p
+}
+
+// twoFactorRequiredBy returns the names of the organizations of userID requiring
+// two-factor authentication. orgs may be nil.
+func twoFactorRequiredBy(ctx context.Context, orgs internal.OrganizationRepositoryInterface, userID string) ([]string, error) {
+	names := []string{}
+	if orgs == nil {
+		return names, nil
+	}
+	list, err := orgs.ListOrganizations(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range list {
+		if o.RequireTwoFactor {
+			names = append(names, o.Name)
+		}
+	}
+	return names, nil
+}
+
+// verifyTwoFactor checks the code in the X-Two-Factor-Code header against the
+// enrollment of userID, writing an error when it is missing or wrong. Wrong codes
+// count against the user's lockout, so codes cannot be guessed.
+func verifyTwoFactor(ctx context.Context, w http.ResponseWriter, r *http.Request, repo internal.TwoFactorRepositoryInterface, lockout *internal.AuthLockout, userID string) bool {
+	key := "account:2fa:" + userID
+	if wait, locked := lockout.Locked(ctx, key); locked {
+		refuseLocked(w, r, "two-factor code", wait)
+		return false
+	}
+	err := internal.VerifyTwoFactor(ctx, repo, userID, r.Header.Get(internal.HeaderTwoFactorCode), time.Now())
+	if errors.Is(err, internal.ErrInvalidTwoFactorCode) {
+		log.Printf("Security: rejected two-factor code of %s from %s %s %s", userID, r.RemoteAddr, r.Method, r.URL.Path)
+		delayFailure(r, lockout, []string{key})
+	}
+	if err != nil {
+		repositoryError(ctx, w, r, err, "verifying two-factor code", "Failed to verify two-factor code")
+		return false
+	}
+	return true
+}
+
+// GetTwoFactor handles GET /me/2fa
+func (tc *TwoFactorController) GetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := twoFactorUser(w, r)
+	if p == nil {
+		return
+	}
+
+	var status twoFactorStatus
+	tf, err := tc.twoFactor.GetTwoFactor(ctx, p.UserID)
+	if err != nil && !errors.Is(err, internal.ErrTwoFactorNotEnrolled) {
+		repositoryError(ctx, w, r, err, "getting two-factor enrollment", "Failed to get two-factor authentication")
+		return
+	}
+	if tf != nil {
+		status.Enabled, status.TwoFactor = tf.Enabled(), tf
+	}
+	if status.RequiredBy, err = twoFactorRequiredBy(ctx, tc.orgs, p.UserID); err != nil {
+		repositoryError(ctx, w, r, err, "listing organizations", "Failed to get two-factor authentication")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// StartTwoFactor handles POST /me/2fa, generating the secret to add to an
+// authenticator app. Enrollment takes effect once confirmed with a code.
+func (tc *TwoFactorController) StartTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := twoFactorUser(w, r)
+	if p == nil {
+		return
+	}
+
+	secret, err := internal.GenerateTOTPSecret()
+	if err != nil {
+		log.Printf("Error generating TOTP secret: %v", err)
+		httpError(w, r, http.StatusInternalServerError, "Failed to start two-factor enrollment")
+		return
+	}
+	if err := tc.twoFactor.StartTwoFactor(ctx, p.UserID, secret); err != nil {
+		repositoryError(ctx, w, r, err, "starting two-factor enrollment", "Failed to start two-factor enrollment")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(startTwoFactorResponse{Secret: secret, ProvisioningURI: internal.TOTPProvisioningURI(tc.issuer, p.UserID, secret)})
+}
+
+// ConfirmTwoFactor handles POST /me/2fa/confirm, enabling two-factor authentication
+// with a first code and returning the recovery codes, which are never shown again
+func (tc *TwoFactorController) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := twoFactorUser(w, r)
+	if p == nil {
+		return
+	}
+
+	var in confirmTwoFactorInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	key := "account:2fa:" + p.UserID
+	if wait, locked := tc.lockout.Locked(ctx, key); locked {
+		refuseLocked(w, r, "two-factor code", wait)
+		return
+	}
+	tf, err := tc.twoFactor.GetTwoFactor(ctx, p.UserID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting two-factor enrollment", "Failed to confirm two-factor enrollment")
+		return
+	}
+	if tf.Enabled() {
+		repositoryError(ctx, w, r, internal.ErrTwoFactorEnabled, "confirming two-factor enrollment", "Failed to confirm two-factor enrollment")
+		return
+	}
+	step, ok := internal.MatchTOTP(tf.Secret, strings.TrimSpace(in.Code), time.Now())
+	if !ok {
+		delayFailure(r, tc.lockout, []string{key})
+		repositoryError(ctx, w, r, internal.ErrInvalidTwoFactorCode, "confirming two-factor enrollment", "Failed to confirm two-factor enrollment")
+		return
+	}
+
+	codes, hashes, err := internal.GenerateRecoveryCodes()
+	if err != nil {
+		log.Printf("Error generating recovery codes: %v", err)
+		httpError(w, r, http.StatusInternalServerError, "Failed to confirm two-factor enrollment")
+		return
+	}
+	if err := tc.twoFactor.ConfirmTwoFactor(ctx, p.UserID, step, hashes); err != nil {
+		repositoryError(ctx, w, r, err, "confirming two-factor enrollment", "Failed to confirm two-factor enrollment")
+		return
+	}
+	log.Printf("Security: %s enabled two-factor authentication", p.UserID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// RegenerateRecoveryCodes handles POST /me/2fa/recovery-codes, replacing the recovery
+// codes. It takes a current code.
+func (tc *TwoFactorController) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := twoFactorUser(w, r)
+	if p == nil || !verifyTwoFactor(ctx, w, r, tc.twoFactor, tc.lockout, p.UserID) {
+		return
+	}
+
+	codes, hashes, err := internal.GenerateRecoveryCodes()
+	if err != nil {
+		log.Printf("Error generating recovery codes: %v", err)
+		httpError(w, r, http.StatusInternalServerError, "Failed to regenerate recovery codes")
+		return
+	}
+	if err := tc.twoFactor.ReplaceRecoveryCodes(ctx, p.UserID, hashes); err != nil {
+		repositoryError(ctx, w, r, err, "replacing recovery codes", "Failed to regenerate recovery codes")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DeleteTwoFactor handles DELETE /me/2fa. It takes a current code, and is refused
+// while an organization of the caller requires two-factor authentication.
+func (tc *TwoFactorController) DeleteTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p := twoFactorUser(w, r)
+	if p == nil {
+		return
+	}
+
+	required, err := twoFactorRequiredBy(ctx, tc.orgs, p.UserID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing organizations", "Failed to disable two-factor authentication")
+		return
+	}
+	if len(required) > 0 {
+		httpError(w, r, http.StatusConflict, "organization %s requires two-factor authentication", required[0])
+		return
+	}
+	if !verifyTwoFactor(ctx, w, r, tc.twoFactor, tc.lockout, p.UserID) {
+		return
+	}
+
+	if err := tc.twoFactor.DeleteTwoFactor(ctx, p.UserID); err != nil {
+		repositoryError(ctx, w, r, err, "disabling two-factor authentication", "Failed to disable two-factor authentication")
+		return
+	}
+	log.Printf("Security: %s disabled two-factor authentication", p.UserID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResetTwoFactor handles DELETE /admin/users/{userId}/2fa, removing the enrollment of
+// a user so they can enroll again
+func (tc *TwoFactorController) ResetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	userID := mux.Vars(r)["userId"]
+	if err := tc.twoFactor.DeleteTwoFactor(ctx, userID); err != nil {
+		repositoryError(ctx, w, r, err, "resetting two-factor authentication", "Failed to reset two-factor authentication")
+		return
+	}
+	log.Printf("Security: %s reset the two-factor authentication of %s", principalID(r), userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
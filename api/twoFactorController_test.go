@@ -0,0 +1,215 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTwoFactorRepository keeps enrollments and recovery codes in memory
+type fakeTwoFactorRepository struct {
+	enrollments map[string]internal.TwoFactor
+	// codes maps the hash of each unused recovery code to its user
+	codes map[string]string
+}
+
+func newFakeTwoFactorRepository() *fakeTwoFactorRepository {
+	return &fakeTwoFactorRepository{enrollments: map[string]internal.TwoFactor{}, codes: map[string]string{}}
+}
+
+func (f *fakeTwoFactorRepository) GetTwoFactor(ctx context.Context, userID string) (*internal.TwoFactor, error) {
+	tf, ok := f.enrollments[userID]
+	if !ok {
+		return nil, internal.ErrTwoFactorNotEnrolled
+	}
+	for _, user := range f.codes {
+		if user == userID {
+			tf.RecoveryCodesLeft++
+		}
+	}
+	return &tf, nil
+}
+
+func (f *fakeTwoFactorRepository) StartTwoFactor(ctx context.Context, userID, secret string) error {
+	if tf, ok := f.enrollments[userID]; ok && tf.Enabled() {
+		return internal.ErrTwoFactorEnabled
+	}
+	f.enrollments[userID] = internal.TwoFactor{UserID: userID, Secret: secret, CreatedAt: time.Now()}
+	return nil
+}
+
+func (f *fakeTwoFactorRepository) ConfirmTwoFactor(ctx context.Context, userID string, step int64, recoveryHashes [][]byte) error {
+	tf := f.enrollments[userID]
+	now := time.Now()
+	tf.ConfirmedAt, tf.LastUsedStep = &now, step
+	f.enrollments[userID] = tf
+	return f.ReplaceRecoveryCodes(ctx, userID, recoveryHashes)
+}
+
+func (f *fakeTwoFactorRepository) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	tf := f.enrollments[userID]
+	if step <= tf.LastUsedStep {
+		return internal.ErrInvalidTwoFactorCode
+	}
+	tf.LastUsedStep = step
+	f.enrollments[userID] = tf
+	return nil
+}
+
+func (f *fakeTwoFactorRepository) UseRecoveryCode(ctx context.Context, userID string, hash []byte) error {
+	if f.codes[string(hash)] != userID {
+		return internal.ErrInvalidTwoFactorCode
+	}
+	delete(f.codes, string(hash))
+	return nil
+}
+
+func (f *fakeTwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID string, hashes [][]byte) error {
+	for hash, user := range f.codes {
+		if user == userID {
+			delete(f.codes, hash)
+		}
+	}
+	for _, hash := range hashes {
+		f.codes[string(hash)] = userID
+	}
+	return nil
+}
+
+func (f *fakeTwoFactorRepository) DeleteTwoFactor(ctx context.Context, userID string) error {
+	if _, ok := f.enrollments[userID]; !ok {
+		return internal.ErrTwoFactorNotEnrolled
+	}
+	delete(f.enrollments, userID)
+	return f.ReplaceRecoveryCodes(ctx, userID, nil)
+}
+
+// updatedOrganizations lets organizations be updated and listed
+type updatedOrganizations struct {
+	*fakeOrganizationRepository
+}
+
+func (f updatedOrganizations) UpdateOrganization(ctx context.Context, o internal.Organization) (*internal.Organization, error) {
+	f.orgs[o.ID] = o
+	return &o, nil
+}
+
+func (f updatedOrganizations) ListOrganizations(ctx context.Context, userID string) ([]internal.Organization, error) {
+	out := []internal.Organization{}
+	for id, o := range f.orgs {
+		if role, ok := f.members[id][userID]; ok {
+			o.Role = role
+			out = append(out, o)
+		}
+	}
+	return out, nil
+}
+
+func TestTwoFactor(t *testing.T) {
+	twoFactor := newFakeTwoFactorRepository()
+	orgs := updatedOrganizations{newFakeOrganizationRepository()}
+	calendars := &createdCalendars{fakeCalendarRepository{calendars: map[uuid.UUID]internal.Calendar{}}}
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		user := r.Header.Get("X-User")
+		return &internal.Principal{UserID: user, Scopes: []string{internal.ScopeEventsRead, internal.ScopeEventsWrite}, Admin: user == adminUserID}, nil
+	}
+	cfg := internal.Config{TwoFactorIssuer: "Events API"}
+	srv, err := NewServer(cfg, Dependencies{
+		Events: &fakeEventRepository{}, Tokens: &fakeTokenRepository{tokens: map[string]internal.APIToken{}}, TwoFactor: twoFactor,
+		Calendars: calendars, Organizations: orgs, Auth: hook,
+	})
+	require.NoError(t, err)
+	do := func(method, path, user, code, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		if code != "" {
+			req.Header.Set(internal.HeaderTwoFactorCode, code)
+		}
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+	createToken := func(code string) int {
+		return do(http.MethodPost, "/tokens", "ana", code, `{"name": "cli", "scopes": ["events:read"]}`).Code
+	}
+
+	// Tokens need no code before enrolling
+	assert.Equal(t, http.StatusCreated, createToken(""))
+
+	rec := do(http.MethodPost, "/me/2fa", "ana", "", "")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	var started startTwoFactorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &started))
+	assert.Contains(t, started.ProvisioningURI, "otpauth://totp/Events%20API:ana?")
+	assert.Contains(t, started.ProvisioningURI, "secret="+started.Secret)
+
+	// Pending enrollments are not enforced, and confirm with a valid code only
+	assert.Equal(t, http.StatusCreated, createToken(""))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/me/2fa/confirm", "ana", "", `{"code": "000000"}`).Code)
+	code, err := internal.TOTPCode(started.Secret, time.Now())
+	require.NoError(t, err)
+	rec = do(http.MethodPost, "/me/2fa/confirm", "ana", "", `{"code": "`+code+`"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var recovery recoveryCodesResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &recovery))
+	require.Len(t, recovery.RecoveryCodes, internal.RecoveryCodeCount)
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/me/2fa", "ana", "", "").Code)
+
+	rec = do(http.MethodGet, "/me/2fa", "ana", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"enabled":true`)
+	assert.Contains(t, rec.Body.String(), `"recovery_codes_left":10`)
+	assert.NotContains(t, rec.Body.String(), started.Secret)
+
+	// Tokens now need a code; the one that confirmed enrollment is used up
+	assert.Equal(t, http.StatusForbidden, createToken(""))
+	assert.Equal(t, http.StatusForbidden, createToken(code))
+	assert.Equal(t, http.StatusForbidden, createToken("123456"))
+	assert.Equal(t, http.StatusCreated, createToken(recovery.RecoveryCodes[0]))
+	assert.Equal(t, http.StatusForbidden, createToken(recovery.RecoveryCodes[0]))
+	assert.Equal(t, http.StatusCreated, do(http.MethodPost, "/tokens", "admin", "", `{"name": "cli", "scopes": ["events:read"], "user_id": "ana"}`).Code)
+
+	// Organizations requiring two-factor authentication keep out members without it
+	rec = do(http.MethodPost, "/organizations", "bob", "", `{"name": "Acme", "require_two_factor": true}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = do(http.MethodPost, "/organizations", "ana", "", `{"name": "Acme", "require_two_factor": true}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var org internal.Organization
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &org))
+	assert.True(t, org.RequireTwoFactor)
+	orgs.members[org.ID]["bob"] = internal.OrgRoleAdmin
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/organizations/"+org.ID.String(), "bob", "", "").Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/tokens", "bob", "", `{"name": "cli", "scopes": ["events:read"]}`).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/organizations/"+org.ID.String(), "ana", "", "").Code)
+
+	// Only owners lift the requirement, and it must be lifted before disabling
+	assert.Equal(t, http.StatusConflict, do(http.MethodDelete, "/me/2fa", "ana", recovery.RecoveryCodes[1], "").Code)
+	rec = do(http.MethodPatch, "/organizations/"+org.ID.String(), "ana", "", `{"require_two_factor": false}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/organizations/"+org.ID.String(), "bob", "", "").Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPatch, "/organizations/"+org.ID.String(), "bob", "", `{"require_two_factor": true}`).Code)
+
+	rec = do(http.MethodPost, "/me/2fa/recovery-codes", "ana", recovery.RecoveryCodes[1], "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, http.StatusForbidden, createToken(recovery.RecoveryCodes[2]))
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &recovery))
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/me/2fa", "ana", recovery.RecoveryCodes[0], "").Code)
+	assert.Equal(t, http.StatusCreated, createToken(""))
+
+	// The admin key resets users who lost their codes
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/me/2fa", "admin", "", "").Code)
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/me/2fa", "bob", "", "").Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/admin/users/bob/2fa", "ana", "", "").Code)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/admin/users/bob/2fa", "admin", "", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/admin/users/bob/2fa", "admin", "", "").Code)
+}
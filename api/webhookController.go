@@ -0,0 +1,405 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Page sizes of GET /webhooks/{id}/deliveries
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+// WebhookController handles webhooks and their delivery log, and the REST Hooks
+// endpoints integration platforms subscribe through. Users see and change only their
+// own webhooks, admins everyone's.
+type WebhookController struct {
+	webhooks   internal.WebhookRepositoryInterface
+	dispatcher *internal.WebhookDispatcher
+	// events are made into sample payloads
+	events    internal.EventRepositoryInterface
+	calendars internal.CalendarRepositoryInterface
+	// orgs, when set, lets organization admins add webhooks to its calendars
+	orgs internal.OrganizationRepositoryInterface
+}
+
+// NewWebhookController creates a new webhook controller replaying deliveries through
+// dispatcher
+func NewWebhookController(webhooks internal.WebhookRepositoryInterface, dispatcher *internal.WebhookDispatcher, events internal.EventRepositoryInterface, calendars internal.CalendarRepositoryInterface, orgs internal.OrganizationRepositoryInterface) *WebhookController {
+	return &WebhookController{webhooks: webhooks, dispatcher: dispatcher, events: events, calendars: calendars, orgs: orgs}
+}
+
+// RegisterRoutes adds the webhook endpoints to router
+func (wc *WebhookController) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/webhooks", requireScope(internal.ScopeWebhooksManage, wc.CreateWebhook)).Methods("POST")
+	router.HandleFunc("/webhooks", requireScope(internal.ScopeWebhooksManage, wc.GetWebhooks)).Methods("GET")
+	router.HandleFunc("/webhooks/{id}", requireScope(internal.ScopeWebhooksManage, wc.GetWebhook)).Methods("GET")
+	router.HandleFunc("/webhooks/{id}", requireScope(internal.ScopeWebhooksManage, wc.DeleteWebhook)).Methods("DELETE")
+	router.HandleFunc("/webhooks/{id}/secret/rotate", requireScope(internal.ScopeWebhooksManage, wc.RotateSecret)).Methods("POST")
+	router.HandleFunc("/webhooks/{id}/deliveries", requireScope(internal.ScopeWebhooksManage, wc.GetDeliveries)).Methods("GET")
+	router.HandleFunc("/webhooks/{id}/deliveries/{deliveryId}/replay", requireScope(internal.ScopeWebhooksManage, wc.ReplayDelivery)).Methods("POST")
+	// REST Hooks, as Zapier and Make expect them
+	router.HandleFunc("/hooks", requireScope(internal.ScopeWebhooksManage, wc.Subscribe)).Methods("POST")
+	router.HandleFunc("/hooks/samples/{event}", requireScope(internal.ScopeWebhooksManage, wc.GetSamples)).Methods("GET")
+	router.HandleFunc("/hooks/{id}", requireScope(internal.ScopeWebhooksManage, wc.DeleteWebhook)).Methods("DELETE")
+}
+
+type createWebhookInput struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// CalendarID limits the webhook to one calendar; only admins may omit it
+	CalendarID *uuid.UUID `json:"calendar_id"`
+}
+
+// subscribeInput is a REST Hooks subscription to one event type
+type subscribeInput struct {
+	TargetURL string `json:"target_url"`
+	Event     string `json:"event"`
+	// CalendarID limits the subscription to one calendar; only admins may omit it
+	CalendarID *uuid.UUID `json:"calendar_id"`
+}
+
+type rotateSecretInput struct {
+	// GraceMinutes is how long the replaced secret keeps signing deliveries
+	GraceMinutes *int `json:"grace_minutes"`
+}
+
+// webhookSecretResponse is a webhook with its signing secret, shown when it is created
+// and rotated only
+type webhookSecretResponse struct {
+	internal.Webhook
+	Secret string `json:"secret"`
+}
+
+// ownsWebhook reports whether the caller may see and change hook
+func ownsWebhook(r *http.Request, hook internal.Webhook) bool {
+	p := internal.PrincipalFromContext(r.Context())
+	return p == nil || p.Admin || p.UserID == hook.OwnerID
+}
+
+// CreateWebhook handles POST /webhooks
+func (wc *WebhookController) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in createWebhookInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	wc.createWebhook(ctx, w, r, internal.Webhook{ID: uuid.New(), OwnerID: principalID(r), URL: in.URL, Events: in.Events, CalendarID: in.CalendarID})
+}
+
+// Subscribe handles POST /hooks: a REST Hooks subscription of target_url to one event
+// type. The response's id unsubscribes it with DELETE /hooks/{id}.
+func (wc *WebhookController) Subscribe(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in subscribeInput
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	wc.createWebhook(ctx, w, r, internal.Webhook{ID: uuid.New(), OwnerID: principalID(r), URL: in.TargetURL, Events: []string{in.Event}, CalendarID: in.CalendarID})
+}
+
+// createWebhook validates and stores hook with a new secret, and writes it
+func (wc *WebhookController) createWebhook(ctx context.Context, w http.ResponseWriter, r *http.Request, hook internal.Webhook) {
+	if msg := internal.ValidateWebhook(&hook); msg != "" {
+		httpError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+	if !wc.mayWatch(ctx, w, r, hook.CalendarID) {
+		return
+	}
+	secret, err := internal.GenerateWebhookSecret()
+	if err != nil {
+		repositoryError(ctx, w, r, err, "generating webhook secret", "Failed to create webhook")
+		return
+	}
+	hook.Secret = secret
+
+	created, err := wc.webhooks.CreateWebhook(ctx, hook)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "creating webhook", "Failed to create webhook")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhookSecretResponse{Webhook: *created, Secret: created.Secret})
+}
+
+// GetSamples handles GET /hooks/samples/{event}?calendar_id=: payloads of an event type
+// made from recent events, newest first, for integration platforms to offer as trigger
+// samples. Users must name a calendar they manage, as when subscribing.
+func (wc *WebhookController) GetSamples(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	eventType := mux.Vars(r)["event"]
+	if !internal.IsWebhookEventType(eventType) {
+		httpError(w, r, http.StatusNotFound, "unknown event type %s", eventType)
+		return
+	}
+	var calendarID *uuid.UUID
+	if raw := r.URL.Query().Get("calendar_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+			return
+		}
+		calendarID = &id
+	}
+	if !wc.mayWatch(ctx, w, r, calendarID) {
+		return
+	}
+
+	var events []internal.EventDB
+	var err error
+	if calendarID != nil {
+		events, err = wc.events.GetEventsByCalendar(ctx, *calendarID)
+	} else {
+		events, err = wc.events.GetEvents(ctx)
+	}
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting events", "Failed to get events")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(internal.WebhookSamples(eventType, events, time.Now()))
+}
+
+// GetWebhooks handles GET /webhooks
+func (wc *WebhookController) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	webhooks, err := wc.webhooks.ListWebhooks(ctx)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing webhooks", "Failed to get webhooks")
+		return
+	}
+	visible := webhooks[:0:0]
+	for _, hook := range webhooks {
+		if ownsWebhook(r, hook) {
+			visible = append(visible, hook)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visible)
+}
+
+// GetWebhook handles GET /webhooks/{id}
+func (wc *WebhookController) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	hook := wc.loadWebhook(ctx, w, r)
+	if hook == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hook)
+}
+
+// DeleteWebhook handles DELETE /webhooks/{id}; its delivery log goes with it
+func (wc *WebhookController) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	hook := wc.loadWebhook(ctx, w, r)
+	if hook == nil {
+		return
+	}
+	if err := wc.webhooks.DeleteWebhook(ctx, hook.ID); err != nil {
+		repositoryError(ctx, w, r, err, "deleting webhook", "Failed to delete webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RotateSecret handles POST /webhooks/{id}/secret/rotate, returning the new signing
+// secret. Deliveries are signed with the replaced one too for grace_minutes, a day by
+// default, so receivers can switch over without rejecting any.
+func (wc *WebhookController) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	var in rotateSecretInput
+	if err := decodeOptionalJSON(r, &in); err != nil {
+		httpError(w, r, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	grace := internal.DefaultSecretGraceMinutes
+	if in.GraceMinutes != nil {
+		grace = *in.GraceMinutes
+	}
+	if grace < 0 || grace > internal.MaxSecretGraceMinutes {
+		httpError(w, r, http.StatusBadRequest, "grace_minutes must be between 0 and %d", internal.MaxSecretGraceMinutes)
+		return
+	}
+
+	hook := wc.loadWebhook(ctx, w, r)
+	if hook == nil {
+		return
+	}
+	secret, err := internal.GenerateWebhookSecret()
+	if err != nil {
+		repositoryError(ctx, w, r, err, "generating webhook secret", "Failed to rotate webhook secret")
+		return
+	}
+	rotated, err := wc.webhooks.RotateWebhookSecret(ctx, hook.ID, secret, time.Now().Add(time.Duration(grace)*time.Minute).UTC())
+	if err != nil {
+		repositoryError(ctx, w, r, err, "rotating webhook secret", "Failed to rotate webhook secret")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhookSecretResponse{Webhook: *rotated, Secret: rotated.Secret})
+}
+
+// GetDeliveries handles GET /webhooks/{id}/deliveries?status=&limit=, newest first,
+// each with the status code and latency of every attempt
+func (wc *WebhookController) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	q := r.URL.Query()
+	status := q.Get("status")
+	if status != "" && status != internal.DeliveryPending && status != internal.DeliverySucceeded && status != internal.DeliveryFailed {
+		httpError(w, r, http.StatusBadRequest, "status must be pending, succeeded or failed")
+		return
+	}
+	limit := defaultDeliveryLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDeliveryLimit {
+			httpError(w, r, http.StatusBadRequest, "limit must be between 1 and %d", maxDeliveryLimit)
+			return
+		}
+		limit = n
+	}
+
+	hook := wc.loadWebhook(ctx, w, r)
+	if hook == nil {
+		return
+	}
+	deliveries, err := wc.webhooks.ListDeliveries(ctx, hook.ID, status, limit)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "listing deliveries", "Failed to get deliveries")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// ReplayDelivery handles POST /webhooks/{id}/deliveries/{deliveryId}/replay. The
+// payload is sent again right away, whatever the delivery's status, and the delivery
+// is returned with the new attempt; an endpoint failing again is not an error of the
+// request.
+func (wc *WebhookController) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	deliveryID, err := uuid.Parse(mux.Vars(r)["deliveryId"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return
+	}
+	hook := wc.loadWebhook(ctx, w, r)
+	if hook == nil {
+		return
+	}
+	delivery, err := wc.webhooks.GetDelivery(ctx, hook.ID, deliveryID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting delivery", "Failed to get delivery")
+		return
+	}
+
+	if _, err := wc.dispatcher.Deliver(ctx, *hook, *delivery, true); err != nil {
+		repositoryError(ctx, w, r, err, "replaying delivery", "Failed to replay delivery")
+		return
+	}
+	delivery, err = wc.webhooks.GetDelivery(ctx, hook.ID, deliveryID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting delivery", "Failed to get delivery")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// loadWebhook returns the webhook of the request when the caller owns it. It returns
+// nil when the response has already been written.
+func (wc *WebhookController) loadWebhook(ctx context.Context, w http.ResponseWriter, r *http.Request) *internal.Webhook {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "Invalid UUID format")
+		return nil
+	}
+
+	hook, err := wc.webhooks.GetWebhook(ctx, id)
+	if err == nil && !ownsWebhook(r, *hook) {
+		err = internal.ErrWebhookNotFound
+	}
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting webhook", "Failed to get webhook")
+		return nil
+	}
+	return hook
+}
+
+// mayWatch reports whether the caller may receive the events of calendarID: admins any
+// calendar or all of them, users the calendars they manage. It writes the response
+// when not.
+func (wc *WebhookController) mayWatch(ctx context.Context, w http.ResponseWriter, r *http.Request, calendarID *uuid.UUID) bool {
+	p := internal.PrincipalFromContext(r.Context())
+	if p == nil || p.Admin {
+		return true
+	}
+	if calendarID == nil {
+		httpError(w, r, http.StatusForbidden, "only admins can add webhooks for every calendar")
+		return false
+	}
+	if wc.calendars == nil {
+		httpError(w, r, http.StatusNotFound, "Calendar not found")
+		return false
+	}
+	calendar, err := wc.calendars.GetCalendar(ctx, *calendarID)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting calendar", "Failed to get calendar")
+		return false
+	}
+	manages, err := managesCalendar(ctx, wc.orgs, r, *calendar)
+	if err != nil {
+		repositoryError(ctx, w, r, err, "getting membership", "Failed to get calendar")
+		return false
+	}
+	if !manages {
+		httpError(w, r, http.StatusForbidden, "only the calendar's owner can add webhooks to it")
+		return false
+	}
+	return true
+}
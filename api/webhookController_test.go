@@ -0,0 +1,231 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWebhookRepository keeps webhooks and deliveries in memory
+type fakeWebhookRepository struct {
+	internal.WebhookRepositoryInterface
+	webhooks   map[uuid.UUID]internal.Webhook
+	deliveries map[uuid.UUID]*internal.WebhookDelivery
+}
+
+func (f *fakeWebhookRepository) CreateWebhook(ctx context.Context, w internal.Webhook) (*internal.Webhook, error) {
+	f.webhooks[w.ID] = w
+	return &w, nil
+}
+
+func (f *fakeWebhookRepository) GetWebhook(ctx context.Context, id uuid.UUID) (*internal.Webhook, error) {
+	w, ok := f.webhooks[id]
+	if !ok {
+		return nil, internal.ErrWebhookNotFound
+	}
+	return &w, nil
+}
+
+func (f *fakeWebhookRepository) ListWebhooks(ctx context.Context) ([]internal.Webhook, error) {
+	out := []internal.Webhook{}
+	for _, w := range f.webhooks {
+		out = append(out, w)
+	}
+	return out, nil
+}
+
+func (f *fakeWebhookRepository) RotateWebhookSecret(ctx context.Context, id uuid.UUID, secret string, previousExpiresAt time.Time) (*internal.Webhook, error) {
+	w := f.webhooks[id]
+	w.PreviousSecret, w.PreviousSecretExpiresAt, w.Secret = w.Secret, &previousExpiresAt, secret
+	f.webhooks[id] = w
+	return &w, nil
+}
+
+func (f *fakeWebhookRepository) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	delete(f.webhooks, id)
+	return nil
+}
+
+func (f *fakeWebhookRepository) ListDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit int) ([]internal.WebhookDelivery, error) {
+	out := []internal.WebhookDelivery{}
+	for _, d := range f.deliveries {
+		if d.WebhookID == webhookID && (status == "" || d.Status == status) {
+			out = append(out, *d)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeWebhookRepository) GetDelivery(ctx context.Context, webhookID, id uuid.UUID) (*internal.WebhookDelivery, error) {
+	d, ok := f.deliveries[id]
+	if !ok || d.WebhookID != webhookID {
+		return nil, internal.ErrDeliveryNotFound
+	}
+	copied := *d
+	return &copied, nil
+}
+
+func (f *fakeWebhookRepository) RecordAttempt(ctx context.Context, a internal.DeliveryAttempt, status string, next *time.Time) error {
+	d := f.deliveries[a.DeliveryID]
+	d.Attempts = append(d.Attempts, a)
+	d.AttemptCount, d.Status, d.NextAttemptAt = a.Attempt, status, next
+	return nil
+}
+
+func TestWebhookDeliveryReplay(t *testing.T) {
+	var got []string
+	var secrets []string
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := internal.VerifyWebhookSignature(body, r.Header.Get(internal.HeaderWebhookSignature), secrets, internal.WebhookSignatureTolerance, time.Now()); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		got = append(got, r.Header.Get(internal.HeaderWebhookDelivery))
+	}))
+	defer endpoint.Close()
+
+	calendar := internal.Calendar{ID: uuid.New(), Name: "Work", OwnerID: "alice"}
+	calendars := &fakeCalendarRepository{calendars: map[uuid.UUID]internal.Calendar{calendar.ID: calendar}}
+	webhooks := &fakeWebhookRepository{webhooks: map[uuid.UUID]internal.Webhook{}, deliveries: map[uuid.UUID]*internal.WebhookDelivery{}}
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		return &internal.Principal{UserID: r.Header.Get("X-User"), Scopes: []string{internal.ScopeWebhooksManage}}, nil
+	}
+	// The endpoint listens on loopback
+	cfg := internal.Config{APIKey: "admin-secret", WebhookAllowedNetworks: []string{"127.0.0.1"}}
+	srv, err := NewServer(cfg, Dependencies{Events: &fakeEventRepository{}, Calendars: calendars, Webhooks: webhooks, Auth: hook})
+	require.NoError(t, err)
+	do := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Users add webhooks only for the calendars they manage
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/webhooks", "alice", `{"url": "`+endpoint.URL+`", "events": ["event.created"]}`).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/webhooks", "bob", `{"url": "`+endpoint.URL+`", "events": ["event.created"], "calendar_id": "`+calendar.ID.String()+`"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/webhooks", "alice", `{"url": "not a url", "events": ["event.created"], "calendar_id": "`+calendar.ID.String()+`"}`).Code)
+	rec := do(http.MethodPost, "/webhooks", "alice", `{"url": "`+endpoint.URL+`", "events": ["event.created"], "calendar_id": "`+calendar.ID.String()+`"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created webhookSecretResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.True(t, strings.HasPrefix(created.Secret, "whsec_"), created.Secret)
+	base := "/webhooks/" + created.ID.String()
+
+	// The secret is only shown when created and rotated
+	rec = do(http.MethodGet, base, "alice", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), created.Secret)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, base+"/secret/rotate", "alice", `{"grace_minutes": -1}`).Code)
+	rec = do(http.MethodPost, base+"/secret/rotate", "alice", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var rotated webhookSecretResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rotated))
+	assert.NotEqual(t, created.Secret, rotated.Secret)
+	require.NotNil(t, rotated.PreviousSecretExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), *rotated.PreviousSecretExpiresAt, time.Minute)
+
+	code := http.StatusBadGateway
+	failed := &internal.WebhookDelivery{ID: uuid.New(), WebhookID: created.ID, EventType: internal.WebhookEventCreated, Payload: json.RawMessage(`{}`), Status: internal.DeliveryFailed, AttemptCount: 5,
+		Attempts: []internal.DeliveryAttempt{{Attempt: 5, ResponseCode: &code, LatencyMS: 42, Error: "webhook returned 502 Bad Gateway"}}}
+	webhooks.deliveries[failed.ID] = failed
+
+	// Other users see neither the webhook nor its deliveries
+	rec = do(http.MethodGet, "/webhooks", "bob", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, base+"/deliveries", "bob", "").Code)
+
+	rec = do(http.MethodGet, base+"/deliveries?status=failed", "alice", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var deliveries []internal.WebhookDelivery
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &deliveries))
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, http.StatusBadGateway, *deliveries[0].Attempts[0].ResponseCode)
+	assert.Equal(t, int64(42), deliveries[0].Attempts[0].LatencyMS)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, base+"/deliveries?status=lost", "alice", "").Code)
+
+	// A replay sends the payload again, signed with the secret and the one it replaced,
+	// and returns the delivery with the new attempt
+	secrets = []string{created.Secret}
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, base+"/deliveries/"+uuid.NewString()+"/replay", "alice", "").Code)
+	rec = do(http.MethodPost, base+"/deliveries/"+failed.ID.String()+"/replay", "alice", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var replayed internal.WebhookDelivery
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &replayed))
+	assert.Equal(t, internal.DeliverySucceeded, replayed.Status)
+	require.Len(t, replayed.Attempts, 2)
+	assert.True(t, replayed.Attempts[1].Replay)
+	assert.Equal(t, http.StatusOK, *replayed.Attempts[1].ResponseCode)
+	assert.Equal(t, []string{failed.ID.String()}, got)
+
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, base, "alice", "").Code)
+}
+
+func TestRESTHooks(t *testing.T) {
+	calendar := internal.Calendar{ID: uuid.New(), Name: "Work", OwnerID: "alice"}
+	calendars := &fakeCalendarRepository{calendars: map[uuid.UUID]internal.Calendar{calendar.ID: calendar}}
+	start := time.Date(2025, 10, 1, 9, 0, 0, 0, time.UTC)
+	standup := internal.EventDB{ID: uuid.New(), CalendarID: &calendar.ID, Title: "Standup", StartTime: start, EndTime: start.Add(15 * time.Minute), UpdatedAt: start}
+	events := &calendarEvents{fakeEventRepository{events: []internal.EventDB{standup}}}
+	webhooks := &fakeWebhookRepository{webhooks: map[uuid.UUID]internal.Webhook{}, deliveries: map[uuid.UUID]*internal.WebhookDelivery{}}
+	hook := func(r *http.Request) (*internal.Principal, error) {
+		return &internal.Principal{UserID: r.Header.Get("X-User"), Scopes: []string{internal.ScopeWebhooksManage}}, nil
+	}
+	srv, err := NewServer(internal.Config{APIKey: "admin-secret"}, Dependencies{Events: events, Calendars: calendars, Webhooks: webhooks, Auth: hook})
+	require.NoError(t, err)
+	do := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		srv.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Subscribing returns the hook's id, which unsubscribes it
+	subscription := `{"target_url": "https://hooks.zapier.com/hooks/standard/1/abc", "event": "event.created", "calendar_id": "` + calendar.ID.String() + `"}`
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/hooks", "bob", subscription).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/hooks", "alice", `{"target_url": "https://example.com", "event": "event.moved", "calendar_id": "`+calendar.ID.String()+`"}`).Code)
+	rec := do(http.MethodPost, "/hooks", "alice", subscription)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created webhookSecretResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.Contains(t, webhooks.webhooks, created.ID)
+	assert.Equal(t, []string{internal.WebhookEventCreated}, webhooks.webhooks[created.ID].Events)
+
+	// Samples have the shape of deliveries
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/hooks/samples/event.created", "alice", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/hooks/samples/event.moved?calendar_id="+calendar.ID.String(), "alice", "").Code)
+	rec = do(http.MethodGet, "/hooks/samples/event.updated?calendar_id="+calendar.ID.String(), "alice", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var samples []struct {
+		ID    uuid.UUID `json:"id"`
+		Type  string    `json:"type"`
+		Event struct {
+			Title string `json:"title"`
+		} `json:"event"`
+		Changes []internal.FieldChange `json:"changes"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &samples))
+	require.Len(t, samples, 1)
+	assert.Equal(t, standup.ID, samples[0].ID)
+	assert.Equal(t, internal.WebhookEventUpdated, samples[0].Type)
+	assert.Equal(t, "Standup", samples[0].Event.Title)
+	assert.NotEmpty(t, samples[0].Changes)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/hooks/"+created.ID.String(), "bob", "").Code)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/hooks/"+created.ID.String(), "alice", "").Code)
+	assert.Empty(t, webhooks.webhooks)
+}
@@ -0,0 +1,241 @@
+// Package events embeds the events API in another Go program. It mounts the same
+// routes the standalone server serves under a prefix of an existing router:
+//
+//	repo := events.NewRepository(db)
+//	err := events.Mount(router, "/calendar", repo, events.WithAuth(authenticate))
+//
+// Only the event endpoints, holidays, health and metrics are served by default;
+// WithDB adds the endpoints backed by the other tables of the schema.
+package events
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"taller_challenge/api"
+	"taller_challenge/internal"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Types of the API, re-exported so embedding programs can implement and use them
+type (
+	Config     = internal.Config
+	Event      = internal.EventDB
+	Repository = internal.EventRepositoryInterface
+	Principal  = internal.Principal
+	Metrics    = internal.Metrics
+	// Hooks are business rules run around event writes; see WithHooks
+	Hooks = internal.EventHooks
+	// AuthHook returns the principal of a request, an error to reject it with a
+	// 401, or neither to leave it to the API key and tokens of Config
+	AuthHook = api.AuthHook
+)
+
+// Scopes a Principal may be granted
+const (
+	ScopeEventsRead     = internal.ScopeEventsRead
+	ScopeEventsWrite    = internal.ScopeEventsWrite
+	ScopeWebhooksManage = internal.ScopeWebhooksManage
+	ScopeMetricsRead    = internal.ScopeMetricsRead
+	ScopeEventsReview   = internal.ScopeEventsReview
+)
+
+// Kinds of errors a hook can reject a write with, through DomainError
+var (
+	ErrValidation = internal.ErrValidation
+	ErrConflict   = internal.ErrConflict
+)
+
+// HeaderWebhookSignature is the header carrying the signatures of webhook deliveries
+const HeaderWebhookSignature = internal.HeaderWebhookSignature
+
+// ErrWebhookSignature is returned by VerifyWebhook for deliveries that were not signed
+// with the secrets, or were signed too long ago
+var ErrWebhookSignature = internal.ErrWebhookSignature
+
+// VerifyWebhook checks that a webhook delivery was signed by the API with one of
+// secrets in the last five minutes. payload is the raw request body and signature the
+// HeaderWebhookSignature header. Pass the new and the previous secret while rotating.
+func VerifyWebhook(payload []byte, signature string, secrets ...string) error {
+	return internal.VerifyWebhookSignature(payload, signature, secrets, internal.WebhookSignatureTolerance, time.Now())
+}
+
+// DomainError returns an error of kind with msg as the message for the client
+func DomainError(kind error, msg string) error {
+	return internal.DomainError(kind, msg)
+}
+
+// AllScopes returns every scope, for principals with full access
+func AllScopes() []string {
+	return append([]string(nil), internal.AllScopes...)
+}
+
+// ConfigFromEnv reads the configuration from the environment variables documented
+// in the README, like the standalone server
+func ConfigFromEnv() Config {
+	return internal.LoadConfig()
+}
+
+// NewRepository returns the Postgres event repository. The schema is created by the
+// SQL files in migrations/.
+func NewRepository(db *sql.DB) Repository {
+	return internal.NewEventRepository(db, nil)
+}
+
+// Option configures an embedded API
+type Option func(*options)
+
+type options struct {
+	cfg     *Config
+	db      *sql.DB
+	auth    AuthHook
+	metrics *Metrics
+	hooks   *Hooks
+}
+
+// WithConfig sets the configuration; without it an empty Config is used, which
+// disables the built-in authentication and outgoing integrations
+func WithConfig(cfg Config) Option {
+	return func(o *options) { o.cfg = &cfg }
+}
+
+// WithAuth authenticates requests with hook before the built-in authentication
+func WithAuth(hook AuthHook) Option {
+	return func(o *options) { o.auth = hook }
+}
+
+// WithDB serves the endpoints of the tokens, calendars, snapshots, digests, policy
+// rules, comments, webhooks, ingest sources, the activity feed and /batch from db,
+// which must carry the full schema. Policy rules are checked on event writes, which
+// are recorded in the feed and sent to webhooks.
+func WithDB(db *sql.DB) Option {
+	return func(o *options) { o.db = db }
+}
+
+// WithMetrics records request and repository metrics into metrics, for example to
+// serve them from the embedding program
+func WithMetrics(metrics *Metrics) Option {
+	return func(o *options) { o.metrics = metrics }
+}
+
+// WithHooks runs hooks around the event writes of the API
+func WithHooks(hooks *Hooks) Option {
+	return func(o *options) { o.hooks = hooks }
+}
+
+// NewHooks returns an empty set of hooks for WithHooks
+func NewHooks() *Hooks {
+	return internal.NewEventHooks()
+}
+
+// NewMetrics returns an empty metrics registry for WithMetrics
+func NewMetrics() *Metrics {
+	return internal.NewMetrics()
+}
+
+// Handler returns the API as an http.Handler serving paths without a prefix
+func Handler(repo Repository, opts ...Option) (http.Handler, error) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	cfg := Config{}
+	if o.cfg != nil {
+		cfg = *o.cfg
+	}
+	// The embedding program owns the listener and TLS
+	cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile = "", "", ""
+
+	deps := api.Dependencies{Metrics: o.metrics, Auth: o.auth}
+	hooks := o.hooks
+	if o.db != nil {
+		deps.Tx = internal.NewTxManager(o.db)
+		deps.Tokens = internal.NewTokenRepository(o.db)
+		deps.Calendars = internal.NewCalendarRepository(o.db)
+		deps.Organizations = internal.NewOrganizationRepository(o.db)
+		snapshots := internal.NewSnapshotRepository(o.db)
+		snapshots.SetIDGenerator(internal.NewIDGenerator(cfg.IDStrategy))
+		deps.Snapshots = snapshots
+		deps.Digests = internal.NewDigestRepository(o.db)
+		deps.Comments = internal.NewCommentRepository(o.db)
+		deps.Reminders = internal.NewReminderRepository(o.db)
+		deps.Resources = internal.NewResourceRepository(o.db)
+		deps.Tickets = internal.NewTicketRepository(o.db)
+		deps.Payments = internal.NewPaymentProvider(cfg)
+		deps.PushSubscriptions = internal.NewPushSubscriptionRepository(o.db)
+		webPush, err := internal.NewWebPushFromConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		deps.WebPush = webPush
+		deps.DeviceTokens = internal.NewDeviceTokenRepository(o.db)
+		if deps.PushProviders, err = internal.NewPushProviders(cfg); err != nil {
+			return nil, err
+		}
+		if deps.ReminderSenders, err = internal.NewReminderSendersFromConfig(internal.LogNotifier{}, cfg); err != nil {
+			return nil, err
+		}
+		push := internal.NewPushNotifier(deps.PushSubscriptions, webPush, deps.DeviceTokens, deps.PushProviders)
+		if push != nil {
+			deps.ReminderSenders[internal.ReminderChannelPush] = &internal.PushReminderSender{Push: push}
+		}
+
+		policies := internal.NewPolicyRepository(o.db)
+		engine, err := internal.NewPolicyEngine(policies)
+		if err != nil {
+			return nil, err
+		}
+		if hooks == nil {
+			hooks = internal.NewEventHooks()
+		}
+		deps.Delegates = internal.NewDelegateRepository(o.db)
+		if repo != nil {
+			internal.NewCalendarAccess(deps.Calendars, deps.Organizations, deps.Delegates, repo).Register(hooks)
+		}
+		engine.Register(hooks)
+		deps.Policies, deps.PolicyEngine = policies, engine
+
+		activity := internal.NewActivityRepository(o.db)
+		internal.NewActivityLog(activity).Register(hooks)
+		deps.Activity = activity
+		if changes := internal.NewEventChangeNotifier(deps.Reminders, push); changes != nil {
+			changes.Register(hooks)
+		}
+		deps.Webhooks = internal.NewWebhookRepository(o.db)
+		deps.IngestSources = internal.NewIngestRepository(o.db)
+		if repo != nil {
+			dispatcher, err := internal.NewWebhookDispatcherFromConfig(deps.Webhooks, repo, cfg)
+			if err != nil {
+				return nil, err
+			}
+			dispatcher.Register(hooks)
+		}
+	}
+	if repo != nil && hooks != nil {
+		repo = internal.NewHookedEventRepository(repo, hooks)
+	}
+	deps.Events = repo
+
+	srv, err := api.NewServer(cfg, deps)
+	if err != nil {
+		return nil, err
+	}
+	return srv.Router, nil
+}
+
+// Mount serves the API under prefix on router, so GET <prefix>/events lists events
+func Mount(router *mux.Router, prefix string, repo Repository, opts ...Option) error {
+	prefix = strings.TrimRight(prefix, "/")
+	if !strings.HasPrefix(prefix, "/") {
+		return errors.New("prefix must start with /")
+	}
+	h, err := Handler(repo, opts...)
+	if err != nil {
+		return err
+	}
+	router.PathPrefix(prefix + "/").Handler(http.StripPrefix(prefix, h))
+	return nil
+}
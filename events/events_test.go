@@ -0,0 +1,71 @@
+package events
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"taller_challenge/internal"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRepository serves a fixed list of events; other methods are not implemented
+type fakeRepository struct {
+	Repository
+	events []Event
+}
+
+func (f *fakeRepository) GetEvents(ctx context.Context) ([]Event, error) {
+	return f.events, nil
+}
+
+func TestMount(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	hook := func(r *http.Request) (*Principal, error) {
+		if r.Header.Get("X-User") == "" {
+			return nil, nil
+		}
+		return &Principal{UserID: r.Header.Get("X-User"), Scopes: []string{ScopeEventsRead}}, nil
+	}
+	err := Mount(router, "/calendar/", &fakeRepository{events: []Event{{Title: "Standup"}}}, WithConfig(Config{APIKey: "secret"}), WithAuth(hook))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		path       string
+		user       string
+		wantStatus int
+	}{
+		{"health under the prefix", "/calendar/healthz", "", http.StatusOK},
+		{"events with a hook principal", "/calendar/events", "ana", http.StatusOK},
+		{"events without credentials", "/calendar/events", "", http.StatusUnauthorized},
+		{"routes of the embedding program", "/", "", http.StatusTeapot},
+		{"API routes are only served under the prefix", "/events", "ana", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.user != "" {
+				req.Header.Set("X-User", tt.user)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+
+	assert.Error(t, Mount(router, "calendar", &fakeRepository{}))
+	assert.Error(t, Mount(router, "/other", nil))
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	payload := []byte(`{"type": "event.created"}`)
+	header := internal.SignWebhookPayload(payload, []string{"whsec_new"}, time.Now())
+	assert.NoError(t, VerifyWebhook(payload, header, "whsec_new", "whsec_old"))
+	assert.ErrorIs(t, VerifyWebhook(payload, header, "whsec_old"), ErrWebhookSignature)
+}
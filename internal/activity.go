@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Actions of the activity feed
+const (
+	ActivityCreated = "created"
+	ActivityUpdated = "updated"
+	ActivityDeleted = "deleted"
+)
+
+// Activity is one entry of the activity feed: a write to an event and who made it
+type Activity struct {
+	ID         uuid.UUID  `json:"id"`
+	EventID    uuid.UUID  `json:"event_id"`
+	CalendarID *uuid.UUID `json:"calendar_id"`
+	Action     string     `json:"action"`
+	// Title is the event's title at the time; deletions repeat the last known title
+	Title      string    `json:"title"`
+	Actor      string    `json:"actor"`
+	Published  bool      `json:"-"`
+	OccurredAt time.Time `json:"occurred_at"`
+	// Changes are the fields an update changed, so consumers of the feed need not keep
+	// the previous revision; nil for creates and deletes
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// ActivityFilter selects the entries of the feed a caller may read
+type ActivityFilter struct {
+	// User is the caller. Without AllCalendars the feed covers the default calendar
+	// and the calendars User owns.
+	User         string
+	AllCalendars bool
+	// Unpublished includes the writes to events awaiting review; User always sees
+	// their own
+	Unpublished bool
+	// CalendarID narrows the feed to one calendar
+	CalendarID *uuid.UUID
+	Before     TimeCursor
+	Limit      int
+}
+
+// ActivityPage is one page of the feed, newest first
+type ActivityPage struct {
+	Activities []Activity
+	Next       TimeCursor
+	HasMore    bool
+}
+
+type ActivityRepository struct {
+	db *sql.DB
+}
+
+// NewActivityRepository creates a new activity repository
+func NewActivityRepository(db *sql.DB) *ActivityRepository {
+	return &ActivityRepository{db: db}
+}
+
+func scanActivity(row rowScanner, a *Activity) error {
+	var changes []byte
+	if err := row.Scan(&a.ID, &a.EventID, &a.CalendarID, &a.Action, &a.Title, &a.Actor, &a.Published, &a.OccurredAt, &changes); err != nil {
+		return err
+	}
+	if changes == nil {
+		return nil
+	}
+	return json.Unmarshal(changes, &a.Changes)
+}
+
+// RecordActivity adds an entry to the feed
+func (r *ActivityRepository) RecordActivity(ctx context.Context, a Activity) error {
+	var changes []byte
+	if a.Changes != nil {
+		var err error
+		if changes, err = json.Marshal(a.Changes); err != nil {
+			return fmt.Errorf("failed to encode changes: %w", err)
+		}
+	}
+	if _, err := conn(ctx, r.db).ExecContext(ctx, qInsertActivity.SQL, a.ID, a.EventID, a.CalendarID, a.Action, a.Title, a.Actor, a.Published, changes); err != nil {
+		return fmt.Errorf("failed to record activity: %w", err)
+	}
+	return nil
+}
+
+// RecordDeletion adds the deletion of an event to the feed. The calendar comes from
+// the event's tombstone and the title from its last entry.
+func (r *ActivityRepository) RecordDeletion(ctx context.Context, id, eventID uuid.UUID, actor string) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, qInsertDeletionActivity.SQL, id, eventID, actor); err != nil {
+		return fmt.Errorf("failed to record activity: %w", err)
+	}
+	return nil
+}
+
+// ListActivity returns a page of the feed, newest first
+func (r *ActivityRepository) ListActivity(ctx context.Context, f ActivityFilter) (*ActivityPage, error) {
+	b := newSelect(qSelectActivity)
+	if !f.AllCalendars {
+		b.Where("calendar_id IS NULL OR calendar_id IN (SELECT id FROM calendars WHERE owner_id = ?)", f.User)
+	}
+	if f.CalendarID != nil {
+		b.Where("calendar_id = ?", *f.CalendarID)
+	}
+	if !f.Unpublished {
+		b.Where("published OR (actor <> '' AND actor = ?)", f.User)
+	}
+	if !f.Before.IsZero() {
+		b.Where("(occurred_at, id) < (?, ?)", f.Before.At, f.Before.ID)
+	}
+	query, args := b.OrderBy("occurred_at DESC").OrderBy("id DESC").Limit(f.Limit + 1).Build()
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query activity: %w", err)
+	}
+	defer rows.Close()
+
+	page := &ActivityPage{Activities: []Activity{}, Next: f.Before}
+	for rows.Next() {
+		var a Activity
+		if err := scanActivity(rows, &a); err != nil {
+			return nil, fmt.Errorf("failed to scan activity: %w", err)
+		}
+		page.Activities = append(page.Activities, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating activity: %w", err)
+	}
+
+	if len(page.Activities) > f.Limit {
+		page.Activities, page.HasMore = page.Activities[:f.Limit], true
+	}
+	if n := len(page.Activities); n > 0 {
+		last := page.Activities[n-1]
+		page.Next = TimeCursor{At: last.OccurredAt, ID: last.ID}
+	}
+	return page, nil
+}
+
+// ActivityLog records event writes for the activity feed through the after hooks, so
+// API writes, sync pushes and imports all appear in it. Restores bypass the hooks.
+type ActivityLog struct {
+	repo ActivityRepositoryInterface
+}
+
+// NewActivityLog records into repo
+func NewActivityLog(repo ActivityRepositoryInterface) *ActivityLog {
+	return &ActivityLog{repo: repo}
+}
+
+// Register records every create, update and delete. Updates list the fields they
+// changed, unless the previous revision could not be read.
+func (l *ActivityLog) Register(hooks *EventHooks) {
+	hooks.AfterCreate(func(ctx context.Context, event EventDB) { l.record(ctx, ActivityCreated, event, nil) })
+	hooks.AfterChange(func(ctx context.Context, before *EventDB, after EventDB) {
+		var changes []FieldChange
+		if before != nil {
+			changes = DiffEvents(*before, after)
+		}
+		l.record(ctx, ActivityUpdated, after, changes)
+	})
+	hooks.AfterDelete(l.recordDeletion)
+}
+
+// record adds a write to the feed. The write already succeeded, so a failure is only
+// logged.
+func (l *ActivityLog) record(ctx context.Context, action string, event EventDB, changes []FieldChange) {
+	err := l.repo.RecordActivity(ctx, Activity{
+		ID:         uuid.New(),
+		EventID:    event.ID,
+		CalendarID: event.CalendarID,
+		Action:     action,
+		Title:      event.Title,
+		Actor:      actor(ctx),
+		Published:  event.Published(),
+		Changes:    changes,
+	})
+	if err != nil {
+		log.Printf("Error recording %s activity of event %s: %v", action, event.ID, err)
+	}
+}
+
+func (l *ActivityLog) recordDeletion(ctx context.Context, id uuid.UUID) {
+	if err := l.repo.RecordDeletion(ctx, uuid.New(), id, actor(ctx)); err != nil {
+		log.Printf("Error recording deleted activity of event %s: %v", id, err)
+	}
+}
+
+// actor is the user ID of the authenticated caller of ctx, or "" without authentication
+func actor(ctx context.Context) string {
+	if p := PrincipalFromContext(ctx); p != nil {
+		return p.UserID
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeActivityRepository keeps the recorded entries
+type fakeActivityRepository struct {
+	ActivityRepositoryInterface
+	recorded []Activity
+	deleted  []uuid.UUID
+}
+
+func (f *fakeActivityRepository) RecordActivity(ctx context.Context, a Activity) error {
+	f.recorded = append(f.recorded, a)
+	return nil
+}
+
+func (f *fakeActivityRepository) RecordDeletion(ctx context.Context, id, eventID uuid.UUID, actor string) error {
+	f.deleted = append(f.deleted, eventID)
+	return nil
+}
+
+func TestActivityLog(t *testing.T) {
+	repo := &fakeActivityRepository{}
+	hooks := NewEventHooks()
+	NewActivityLog(repo).Register(hooks)
+	events := NewHookedEventRepository(&writeRecorder{}, hooks)
+
+	ctx := WithPrincipal(context.Background(), &Principal{UserID: "alice"})
+	calendar := uuid.New()
+	created, err := events.CreateEvent(ctx, EventDB{ID: uuid.New(), Title: "Standup", CalendarID: &calendar})
+	require.NoError(t, err)
+	_, err = events.UpdateEvent(ctx, EventDB{ID: created.ID, Title: "Standup", Status: EventStatusPending})
+	require.NoError(t, err)
+	require.NoError(t, events.DeleteEvent(context.Background(), created.ID))
+
+	require.Len(t, repo.recorded, 2)
+	assert.Equal(t, ActivityCreated, repo.recorded[0].Action)
+	assert.Equal(t, "alice", repo.recorded[0].Actor)
+	assert.Equal(t, &calendar, repo.recorded[0].CalendarID)
+	assert.True(t, repo.recorded[0].Published)
+	assert.Nil(t, repo.recorded[0].Changes)
+	assert.Equal(t, ActivityUpdated, repo.recorded[1].Action)
+	assert.False(t, repo.recorded[1].Published)
+	assert.Equal(t, []FieldChange{
+		{Field: "calendar_id", Old: calendar},
+		{Field: "status", Old: "", New: EventStatusPending},
+	}, repo.recorded[1].Changes)
+	assert.Equal(t, []uuid.UUID{created.ID}, repo.deleted)
+}
@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Agenda layout: a time column on the left, event details to its right
+const (
+	agendaTimeColumn = 90.0
+	agendaTextWidth  = pdfPageWidth - 2*pdfMargin
+)
+
+// agendaFooter numbers the pages of a printed agenda
+func agendaFooter(lang string, generated time.Time) func(page, pages int) string {
+	return func(page, pages int) string {
+		return Translate(lang, "Page %d of %d", page, pages) + " · " + FormatDate(lang, generated)
+	}
+}
+
+// endsLater reports whether an event ends on a later day than it starts in loc
+func endsLater(e EventDB, loc *time.Location) bool {
+	return e.StartTime.In(loc).Format(time.DateOnly) != e.EndTime.In(loc).Format(time.DateOnly)
+}
+
+// timeRange formats the hours of an event; the end of an event ending on a later
+// day is written out separately
+func timeRange(e EventDB, loc *time.Location) string {
+	if endsLater(e, loc) {
+		return e.StartTime.In(loc).Format("15:04") + " –"
+	}
+	return e.StartTime.In(loc).Format("15:04") + " – " + e.EndTime.In(loc).Format("15:04")
+}
+
+// RenderAgendaPDF writes a printable agenda of events between from and to, grouped
+// by day in loc
+func RenderAgendaPDF(w io.Writer, title string, from, to time.Time, events []EventDB, lang string, loc *time.Location, now time.Time) error {
+	sorted := append([]EventDB(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].StartTime.Before(sorted[j].StartTime) })
+
+	d := newPDFDocument(title)
+	d.paragraph(pdfMargin, agendaTextWidth, pdfBold, 20, title)
+	d.paragraph(pdfMargin, agendaTextWidth, pdfRegular, 11, FormatDay(lang, from.In(loc))+" – "+FormatDay(lang, to.In(loc).Add(-time.Nanosecond)))
+	d.space(12)
+	if len(sorted) == 0 {
+		d.paragraph(pdfMargin, agendaTextWidth, pdfRegular, 11, Translate(lang, "No events scheduled."))
+	}
+
+	day := ""
+	for _, e := range sorted {
+		// Keep a day's heading with its first event
+		if heading := FormatDay(lang, e.StartTime.In(loc)); heading != day {
+			day = heading
+			d.ensure(70)
+			d.space(10)
+			d.paragraph(pdfMargin, agendaTextWidth, pdfBold, 13, day)
+			d.space(4)
+			d.rule()
+		}
+		// The time sits next to the first line of the title, which ensure keeps on this page
+		d.ensure(30)
+		d.space(4)
+		d.textAt(pdfMargin, d.y-11*1.3, pdfRegular, 10, timeRange(e, loc))
+		d.paragraph(pdfMargin+agendaTimeColumn, agendaTextWidth-agendaTimeColumn, pdfBold, 11, e.Title)
+		if endsLater(e, loc) {
+			d.paragraph(pdfMargin+agendaTimeColumn, agendaTextWidth-agendaTimeColumn, pdfRegular, 10, Translate(lang, "until %s", FormatDate(lang, e.EndTime.In(loc))))
+		}
+		if e.Location != nil && *e.Location != "" {
+			d.paragraph(pdfMargin+agendaTimeColumn, agendaTextWidth-agendaTimeColumn, pdfRegular, 10, *e.Location)
+		}
+		if text := DescriptionText(e); text != "" {
+			d.paragraph(pdfMargin+agendaTimeColumn, agendaTextWidth-agendaTimeColumn, pdfRegular, 9, text)
+		}
+		d.space(6)
+	}
+
+	if err := d.write(w, agendaFooter(lang, now.In(loc)), now); err != nil {
+		return fmt.Errorf("failed to write agenda: %w", err)
+	}
+	return nil
+}
+
+// RenderEventPDF writes a printable page with the details of one event
+func RenderEventPDF(w io.Writer, e EventDB, lang string, loc *time.Location, now time.Time) error {
+	d := newPDFDocument(e.Title)
+	d.paragraph(pdfMargin, agendaTextWidth, pdfBold, 20, e.Title)
+	d.space(6)
+	d.paragraph(pdfMargin, agendaTextWidth, pdfRegular, 12, FormatDate(lang, e.StartTime.In(loc)))
+	d.paragraph(pdfMargin, agendaTextWidth, pdfRegular, 12, "– "+FormatDate(lang, e.EndTime.In(loc)))
+	if e.Location != nil && *e.Location != "" {
+		d.space(4)
+		d.paragraph(pdfMargin, agendaTextWidth, pdfBold, 12, *e.Location)
+	}
+	if text := DescriptionText(e); text != "" {
+		d.space(12)
+		d.rule()
+		d.space(6)
+		d.paragraph(pdfMargin, agendaTextWidth, pdfRegular, 11, text)
+	}
+
+	if err := d.write(w, agendaFooter(lang, now.In(loc)), now); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return nil
+}
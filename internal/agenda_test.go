@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkPDF verifies the cross-reference table of a PDF and returns its page count
+func checkPDF(t *testing.T, pdf []byte) int {
+	require.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")))
+	require.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	require.NotNil(t, m)
+	xref, _ := strconv.Atoi(string(m[1]))
+	require.True(t, bytes.HasPrefix(pdf[xref:], []byte("xref\n")))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[xref:], -1)
+	for i, e := range entries {
+		offset, _ := strconv.Atoi(string(e[1]))
+		assert.True(t, bytes.HasPrefix(pdf[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+	}
+	return bytes.Count(pdf, []byte("/Type /Page /Parent"))
+}
+
+func TestRenderAgendaPDF(t *testing.T) {
+	from := time.Date(2025, 9, 15, 0, 0, 0, 0, time.UTC)
+	description := "Bring the (draft) slides\n\nand coffee"
+	var events []EventDB
+	for i := 0; i < 60; i++ {
+		start := from.Add(time.Duration(i) * 3 * time.Hour)
+		events = append(events, EventDB{ID: uuid.New(), Title: fmt.Sprintf("Session %d", i), Description: &description, StartTime: start, EndTime: start.Add(time.Hour)})
+	}
+	events[0].Title = "Café – ☃"
+
+	var buf bytes.Buffer
+	require.NoError(t, RenderAgendaPDF(&buf, "Conference", from, from.AddDate(0, 0, 8), events, "en", time.UTC, from))
+	pages := checkPDF(t, buf.Bytes())
+	assert.Greater(t, pages, 1)
+	assert.Contains(t, buf.String(), "(Caf\xe9 \x96 ?)", "text is WinAnsi encoded")
+	assert.Contains(t, buf.String(), `(Bring the \(draft\) slides)`)
+	assert.Contains(t, buf.String(), fmt.Sprintf("(Page %d of %d", pages, pages))
+
+	buf.Reset()
+	require.NoError(t, RenderEventPDF(&buf, events[1], "de", time.UTC, from))
+	assert.Equal(t, 1, checkPDF(t, buf.Bytes()))
+	assert.Contains(t, buf.String(), "(Seite 1 von 1")
+}
+
+func TestPDFWrap(t *testing.T) {
+	lines := pdfWrap(strings.Repeat("word ", 40)+"\n"+strings.Repeat("x", 200), pdfRegular, 10, 200)
+	for _, line := range lines {
+		assert.LessOrEqual(t, pdfTextWidth(line, pdfRegular, 10), 200.0)
+	}
+	assert.Greater(t, len(lines), 4)
+	assert.Equal(t, []string{"a", "", "b"}, pdfWrap("a\n\nb", pdfRegular, 10, 200))
+}
@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// aggregateEntry is a cached heatmap or stats result
+type aggregateEntry struct {
+	val      any
+	fetched  time.Time
+	lastRead time.Time
+	// read fetches the result again, in the background once the entry is stale
+	read       func(ctx context.Context) (any, error)
+	refreshing bool
+}
+
+// CachedAggregateRepository keeps heatmaps and stats in memory with
+// stale-while-revalidate semantics: results younger than the TTL are served as they
+// are, results stale by less than the grace period are served while a background
+// goroutine reads them again, and older ones are read on the spot. Aggregates span
+// days of events, so a result a few seconds old is as useful as a fresh one and far
+// cheaper.
+type CachedAggregateRepository struct {
+	EventRepositoryInterface
+	ttl, stale time.Duration
+	metrics    *Metrics
+
+	mu         sync.Mutex
+	entries    map[string]*aggregateEntry
+	revalidate chan string
+}
+
+// NewCachedAggregateRepository caches the aggregates of repo for ttl, serving them up to
+// stale longer while they revalidate; hits are recorded in metrics with the results
+// "cached" and "stale". Run must be running for stale entries to be revalidated.
+func NewCachedAggregateRepository(repo EventRepositoryInterface, ttl, stale time.Duration, metrics *Metrics) *CachedAggregateRepository {
+	return &CachedAggregateRepository{
+		EventRepositoryInterface: repo,
+		ttl:                      ttl,
+		stale:                    stale,
+		metrics:                  metrics,
+		entries:                  map[string]*aggregateEntry{},
+		revalidate:               make(chan string, 64),
+	}
+}
+
+// Run revalidates the entries served stale, and every TTL the entries still being read
+// that have gone stale, dropping those no one read for the grace period. It returns
+// when ctx is done.
+func (r *CachedAggregateRepository) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.ttl)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case key := <-r.revalidate:
+			r.refresh(ctx, key)
+		case <-ticker.C:
+			for _, key := range r.sweep(time.Now()) {
+				r.refresh(ctx, key)
+			}
+		}
+	}
+}
+
+// sweep drops the entries unread for longer than ttl plus the grace period, and returns
+// the keys of the stale entries read since they were fetched
+func (r *CachedAggregateRepository) sweep(now time.Time) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var hot []string
+	for key, e := range r.entries {
+		switch {
+		case now.Sub(e.lastRead) > r.ttl+r.stale:
+			delete(r.entries, key)
+		case !e.refreshing && now.Sub(e.fetched) >= r.ttl && e.lastRead.After(e.fetched):
+			e.refreshing = true
+			hot = append(hot, key)
+		}
+	}
+	return hot
+}
+
+// refresh reads the entry of key again
+func (r *CachedAggregateRepository) refresh(ctx context.Context, key string) {
+	r.mu.Lock()
+	e, ok := r.entries[key]
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+	readCtx, cancel := context.WithTimeout(ctx, coalesceTimeout)
+	defer cancel()
+	val, err := e.read(readCtx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.refreshing = false
+	if err != nil {
+		log.Printf("Error revalidating cached %s: %v", key, err)
+		return
+	}
+	e.val, e.fetched = val, time.Now()
+}
+
+// cachedAggregate returns the cached result of read for key, reading it when there is
+// none or it is past the grace period. Like coalesced reads, reads inside a transaction
+// or that must see a write bypass the cache.
+func cachedAggregate[E any](r *CachedAggregateRepository, ctx context.Context, method, key string, read func(ctx context.Context) ([]E, error)) ([]E, error) {
+	if !coalescable(ctx) {
+		return read(ctx)
+	}
+	key = method + "|" + key
+	now := time.Now()
+
+	r.mu.Lock()
+	if e, ok := r.entries[key]; ok && now.Sub(e.fetched) < r.ttl+r.stale {
+		e.lastRead = now
+		result := "cached"
+		if now.Sub(e.fetched) >= r.ttl {
+			result = "stale"
+			if !e.refreshing {
+				select {
+				case r.revalidate <- key:
+					e.refreshing = true
+				default:
+					// The revalidation goroutine is behind; a later read will ask again
+				}
+			}
+		}
+		val, _ := e.val.([]E)
+		r.mu.Unlock()
+		if r.metrics != nil {
+			r.metrics.ObserveRepositoryCall(ctx, method, result, time.Since(now))
+		}
+		return slices.Clone(val), nil
+	}
+	r.mu.Unlock()
+
+	val, err := read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.entries[key] = &aggregateEntry{
+		val:      val,
+		fetched:  time.Now(),
+		lastRead: now,
+		read: func(ctx context.Context) (any, error) {
+			return read(ctx)
+		},
+	}
+	r.mu.Unlock()
+	return slices.Clone(val), nil
+}
+
+func (r *CachedAggregateRepository) Occupancy(ctx context.Context, q HeatmapQuery) ([]HeatmapBucket, error) {
+	key := fmt.Sprintf("%s|%s|%s|%s|%s", q.From.UTC().Format(time.RFC3339Nano), q.To.UTC().Format(time.RFC3339Nano), q.Bucket, q.Location, optionalID(q.CalendarID))
+	return cachedAggregate(r, ctx, "Occupancy", key, func(ctx context.Context) ([]HeatmapBucket, error) {
+		return r.EventRepositoryInterface.Occupancy(ctx, q)
+	})
+}
+
+func (r *CachedAggregateRepository) EventStats(ctx context.Context, q EventStatsQuery) ([]EventStatsRow, error) {
+	key := fmt.Sprintf("%s|%s|%s|%s", q.From.UTC().Format(time.RFC3339Nano), q.To.UTC().Format(time.RFC3339Nano), q.Group, optionalID(q.CalendarID))
+	return cachedAggregate(r, ctx, "EventStats", key, func(ctx context.Context) ([]EventStatsRow, error) {
+		return r.EventRepositoryInterface.EventStats(ctx, q)
+	})
+}
+
+// EventsVersion versions the listings of the wrapped repository when it supports it
+func (r *CachedAggregateRepository) EventsVersion(ctx context.Context, source, externalID string) (*EventsVersion, error) {
+	return VersionEvents(ctx, r.EventRepositoryInterface, source, externalID)
+}
+
+// FilterEvents filters in the wrapped repository when it supports it
+func (r *CachedAggregateRepository) FilterEvents(ctx context.Context, f *EventFilter) ([]EventDB, error) {
+	return FilterEvents(ctx, r.EventRepositoryInterface, f)
+}
+
+// GetEventSummaries summarizes in the wrapped repository when it supports it
+func (r *CachedAggregateRepository) GetEventSummaries(ctx context.Context, f *EventFilter) ([]EventSummary, error) {
+	return SummarizeEvents(ctx, r.EventRepositoryInterface, f)
+}
+
+// SearchEvents searches the wrapped repository when it supports it
+func (r *CachedAggregateRepository) SearchEvents(ctx context.Context, q SearchQuery) ([]EventSummary, error) {
+	return SearchEvents(ctx, r.EventRepositoryInterface, q)
+}
+
+// Ping checks the wrapped repository's database when it supports it
+func (r *CachedAggregateRepository) Ping(ctx context.Context) error {
+	return pingRepository(ctx, r.EventRepositoryInterface)
+}
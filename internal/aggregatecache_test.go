@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countedStats serves EventStats with the number of times it was called
+type countedStats struct {
+	EventRepositoryInterface
+	calls atomic.Int64
+}
+
+func (c *countedStats) EventStats(ctx context.Context, q EventStatsQuery) ([]EventStatsRow, error) {
+	n := c.calls.Add(1)
+	return []EventStatsRow{{Events: int(n)}}, nil
+}
+
+func TestCachedAggregateRepositoryServesStaleWhileRevalidating(t *testing.T) {
+	inner := &countedStats{}
+	repo := NewCachedAggregateRepository(inner, time.Minute, time.Hour, nil)
+	ctx := context.Background()
+	q := EventStatsQuery{From: time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC), Group: StatsGroupDay}
+
+	rows, err := repo.EventStats(ctx, q)
+	require.NoError(t, err)
+	assert.Equal(t, 1, rows[0].Events)
+	rows, _ = repo.EventStats(ctx, q)
+	assert.Equal(t, 1, rows[0].Events, "fresh results are cached")
+	rows[0].Events = 99
+	rows, _ = repo.EventStats(ctx, q)
+	assert.Equal(t, 1, rows[0].Events, "callers get copies")
+
+	// Once stale, the cached result is served and a revalidation queued
+	key := "EventStats|2025-09-01T00:00:00Z|2025-10-01T00:00:00Z|day|"
+	repo.entries[key].fetched = time.Now().Add(-2 * time.Minute)
+	rows, _ = repo.EventStats(ctx, q)
+	assert.Equal(t, 1, rows[0].Events)
+	require.Len(t, repo.revalidate, 1)
+	repo.refresh(ctx, <-repo.revalidate)
+	rows, _ = repo.EventStats(ctx, q)
+	assert.Equal(t, 2, rows[0].Events)
+
+	// Past the grace period the result is read on the spot
+	repo.entries[key].fetched = time.Now().Add(-2 * time.Hour)
+	rows, _ = repo.EventStats(ctx, q)
+	assert.Equal(t, 3, rows[0].Events)
+
+	// Reads that must see a write bypass the cache
+	tokenCtx, _ := WithConsistency(ctx, "16/B374D848")
+	rows, _ = repo.EventStats(tokenCtx, q)
+	assert.Equal(t, 4, rows[0].Events)
+}
+
+func TestCachedAggregateRepositorySweep(t *testing.T) {
+	repo := NewCachedAggregateRepository(&countedStats{}, time.Minute, time.Minute, nil)
+	now := time.Now()
+	repo.entries["hot"] = &aggregateEntry{fetched: now.Add(-90 * time.Second), lastRead: now.Add(-time.Second)}
+	repo.entries["idle"] = &aggregateEntry{fetched: now.Add(-90 * time.Second), lastRead: now.Add(-100 * time.Second)}
+	repo.entries["unread"] = &aggregateEntry{fetched: now.Add(-10 * time.Minute), lastRead: now.Add(-10 * time.Minute)}
+
+	assert.Equal(t, []string{"hot"}, repo.sweep(now))
+	assert.NotContains(t, repo.entries, "unread")
+	assert.Contains(t, repo.entries, "idle")
+}
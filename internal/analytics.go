@@ -0,0 +1,284 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Analytics sinks usage events can be sent to
+const (
+	AnalyticsLog    = "log"
+	AnalyticsStatsD = "statsd"
+	AnalyticsHTTP   = "http"
+)
+
+// Names of the usage events
+const (
+	AnalyticsRequest = "api_request"
+	AnalyticsFeature = "feature_used"
+)
+
+// analyticsBuffer is how many events wait to be sent; more are dropped rather than
+// slowing requests down
+const analyticsBuffer = 4096
+
+// AnalyticsEvent is one anonymous usage event. Properties never hold user IDs,
+// addresses, event contents or paths with IDs in them: requests are named by their route
+// template, and users by an AnonymousID that cannot be traced back to them.
+type AnalyticsEvent struct {
+	Name        string            `json:"event"`
+	AnonymousID string            `json:"anonymousId,omitempty"`
+	Properties  map[string]string `json:"properties"`
+	Timestamp   time.Time         `json:"timestamp"`
+}
+
+// AnalyticsSink sends batches of usage events somewhere they can be analyzed
+type AnalyticsSink interface {
+	Send(ctx context.Context, events []AnalyticsEvent) error
+}
+
+// NewAnalyticsSink builds the sink of cfg.AnalyticsSink, or returns nil when analytics
+// are disabled, the default
+func NewAnalyticsSink(cfg Config) (AnalyticsSink, error) {
+	switch cfg.AnalyticsSink {
+	case "":
+		return nil, nil
+	case AnalyticsLog:
+		return LogAnalyticsSink{}, nil
+	case AnalyticsStatsD:
+		if cfg.AnalyticsURL == "" {
+			return nil, fmt.Errorf("sending analytics to StatsD needs ANALYTICS_URL, its host:port")
+		}
+		client, err := NewStatsD(cfg.AnalyticsURL, "", false, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &StatsDAnalyticsSink{client: client}, nil
+	case AnalyticsHTTP:
+		if cfg.AnalyticsURL == "" {
+			return nil, fmt.Errorf("sending analytics over HTTP needs ANALYTICS_URL")
+		}
+		return &HTTPAnalyticsSink{client: &http.Client{Timeout: 10 * time.Second}, url: cfg.AnalyticsURL, writeKey: cfg.AnalyticsWriteKey}, nil
+	default:
+		return nil, fmt.Errorf("unknown analytics sink %q (available: %s, %s, %s)", cfg.AnalyticsSink, AnalyticsLog, AnalyticsStatsD, AnalyticsHTTP)
+	}
+}
+
+// LogAnalyticsSink logs every event, for trying analytics out
+type LogAnalyticsSink struct{}
+
+func (LogAnalyticsSink) Send(ctx context.Context, events []AnalyticsEvent) error {
+	for _, e := range events {
+		props, _ := json.Marshal(e.Properties)
+		log.Printf("Analytics: event=%s anonymous_id=%s properties=%s", e.Name, e.AnonymousID, props)
+	}
+	return nil
+}
+
+// StatsDAnalyticsSink counts events as StatsD counters: analytics.api_request.<method>
+// per request and analytics.feature_used.<feature> per feature
+type StatsDAnalyticsSink struct {
+	client *StatsD
+}
+
+func (s *StatsDAnalyticsSink) Send(ctx context.Context, events []AnalyticsEvent) error {
+	counts := map[string]int{}
+	for _, e := range events {
+		switch e.Name {
+		case AnalyticsRequest:
+			counts["analytics."+e.Name+"."+strings.ToLower(e.Properties["method"])]++
+		case AnalyticsFeature:
+			counts["analytics."+e.Name+"."+e.Properties["feature"]]++
+		default:
+			counts["analytics."+e.Name]++
+		}
+	}
+	for name, n := range counts {
+		s.client.Count(name, int64(n))
+	}
+	s.client.Flush()
+	return nil
+}
+
+// HTTPAnalyticsSink posts batches in the format of Segment's batch API, which most
+// product analytics tools accept: {"batch": [{"type": "track", "event": ...}]}. The
+// write key is sent as the basic authentication user.
+type HTTPAnalyticsSink struct {
+	client   *http.Client
+	url      string
+	writeKey string
+}
+
+func (s *HTTPAnalyticsSink) Send(ctx context.Context, events []AnalyticsEvent) error {
+	type track struct {
+		Type string `json:"type"`
+		AnalyticsEvent
+	}
+	batch := make([]track, len(events))
+	for i, e := range events {
+		batch[i] = track{Type: "track", AnalyticsEvent: e}
+	}
+	body, err := json.Marshal(map[string]any{"batch": batch})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.writeKey != "" {
+		req.SetBasicAuth(s.writeKey, "")
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("analytics endpoint answered %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Analytics buffers usage events and sends them to a sink in batches, in the
+// background. Tracking never blocks: events beyond the buffer are dropped.
+type Analytics struct {
+	sink AnalyticsSink
+	salt []byte
+	// BatchSize is the most events sent at once; Interval how long events may wait
+	BatchSize int
+	Interval  time.Duration
+	events    chan AnalyticsEvent
+	dropped   atomic.Int64
+}
+
+// NewAnalytics sends the events tracked to sink. Users are identified by an HMAC of
+// their ID keyed with salt; without a salt a random one is drawn, so the same user gets
+// a new anonymous ID at every restart.
+func NewAnalytics(sink AnalyticsSink, salt string) *Analytics {
+	key := []byte(salt)
+	if salt == "" {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	return &Analytics{sink: sink, salt: key, BatchSize: 100, Interval: 10 * time.Second, events: make(chan AnalyticsEvent, analyticsBuffer)}
+}
+
+// AnonymousID returns the identifier analytics know userID by, or "" for anonymous
+// callers
+func (a *Analytics) AnonymousID(userID string) string {
+	if userID == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, a.salt)
+	mac.Write([]byte(userID))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// Track queues an event of the user userID, which may be empty
+func (a *Analytics) Track(name, userID string, properties map[string]string) {
+	e := AnalyticsEvent{Name: name, AnonymousID: a.AnonymousID(userID), Properties: properties, Timestamp: time.Now().UTC()}
+	select {
+	case a.events <- e:
+	default:
+		a.dropped.Add(1)
+	}
+}
+
+// Run sends the tracked events until ctx is done, then sends those left
+func (a *Analytics) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+
+	batch := make([]AnalyticsEvent, 0, a.BatchSize)
+	send := func(ctx context.Context) {
+		if n := a.dropped.Swap(0); n > 0 {
+			log.Printf("Dropped %d analytics events, the buffer was full", n)
+		}
+		if len(batch) == 0 {
+			return
+		}
+		if err := a.sink.Send(ctx, batch); err != nil {
+			log.Printf("Error sending %d analytics events: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case e := <-a.events:
+			batch = append(batch, e)
+			if len(batch) == a.BatchSize {
+				send(ctx)
+			}
+		case <-ticker.C:
+			send(ctx)
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			defer cancel()
+			for drained := false; !drained; {
+				select {
+				case e := <-a.events:
+					batch = append(batch, e)
+				default:
+					drained = true
+				}
+				if drained || len(batch) == a.BatchSize {
+					send(flushCtx)
+				}
+			}
+			return
+		}
+	}
+}
+
+// featuresKey carries the features a request used
+type featuresKey struct{}
+
+// usedFeatures collects the features of one request
+type usedFeatures struct {
+	mu    sync.Mutex
+	names []string
+}
+
+// WithFeatures returns a context collecting the features UseFeature reports, and a
+// function listing them once the request is served
+func WithFeatures(ctx context.Context) (context.Context, func() []string) {
+	f := &usedFeatures{}
+	return context.WithValue(ctx, featuresKey{}, f), func() []string {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.names
+	}
+}
+
+// UseFeature reports that the request of ctx used feature, for analytics. It does
+// nothing unless analytics collect the request's features.
+func UseFeature(ctx context.Context, feature string) {
+	f, ok := ctx.Value(featuresKey{}).(*usedFeatures)
+	if !ok {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, name := range f.names {
+		if name == feature {
+			return
+		}
+	}
+	f.names = append(f.names, feature)
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordedAnalytics collects the batches sent
+type recordedAnalytics chan []AnalyticsEvent
+
+func (r recordedAnalytics) Send(ctx context.Context, events []AnalyticsEvent) error {
+	r <- append([]AnalyticsEvent(nil), events...)
+	return nil
+}
+
+func TestAnalyticsAnonymousID(t *testing.T) {
+	a := NewAnalytics(nil, "salt")
+	assert.Equal(t, a.AnonymousID("alice"), a.AnonymousID("alice"))
+	assert.NotEqual(t, a.AnonymousID("alice"), a.AnonymousID("bob"))
+	assert.Len(t, a.AnonymousID("alice"), 16)
+	assert.NotContains(t, a.AnonymousID("alice"), "alice")
+	assert.NotEqual(t, a.AnonymousID("alice"), NewAnalytics(nil, "other").AnonymousID("alice"), "IDs depend on the salt")
+	assert.NotEqual(t, NewAnalytics(nil, "").AnonymousID("alice"), NewAnalytics(nil, "").AnonymousID("alice"), "no salt draws a random one")
+	assert.Empty(t, a.AnonymousID(""))
+}
+
+func TestAnalyticsRunBatches(t *testing.T) {
+	sent := make(recordedAnalytics, 10)
+	a := NewAnalytics(sent, "salt")
+	a.BatchSize = 2
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(done)
+	}()
+
+	a.Track(AnalyticsFeature, "alice", map[string]string{"feature": "search"})
+	a.Track(AnalyticsFeature, "", map[string]string{"feature": "import"})
+	batch := <-sent
+	require.Len(t, batch, 2, "a full batch is sent at once")
+	assert.Equal(t, "search", batch[0].Properties["feature"])
+	assert.Equal(t, a.AnonymousID("alice"), batch[0].AnonymousID)
+
+	a.Track(AnalyticsRequest, "", map[string]string{"route": "/events"})
+	cancel()
+	<-done
+	require.Len(t, sent, 1, "the events left are sent when stopping")
+	assert.Equal(t, AnalyticsRequest, (<-sent)[0].Name)
+}
+
+func TestHTTPAnalyticsSink(t *testing.T) {
+	var body map[string][]map[string]any
+	var user string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ = r.BasicAuth()
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer srv.Close()
+
+	sink, err := NewAnalyticsSink(Config{AnalyticsSink: AnalyticsHTTP, AnalyticsURL: srv.URL, AnalyticsWriteKey: "key"})
+	require.NoError(t, err)
+	events := []AnalyticsEvent{{Name: AnalyticsFeature, AnonymousID: "abc", Properties: map[string]string{"feature": "search"}, Timestamp: time.Now()}}
+	require.NoError(t, sink.Send(context.Background(), events))
+	assert.Equal(t, "key", user)
+	require.Len(t, body["batch"], 1)
+	assert.Equal(t, "track", body["batch"][0]["type"])
+	assert.Equal(t, AnalyticsFeature, body["batch"][0]["event"])
+	assert.Equal(t, "abc", body["batch"][0]["anonymousId"])
+}
+
+func TestStatsDAnalyticsSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sink, err := NewAnalyticsSink(Config{AnalyticsSink: AnalyticsStatsD, AnalyticsURL: conn.LocalAddr().String()})
+	require.NoError(t, err)
+	require.NoError(t, sink.Send(context.Background(), []AnalyticsEvent{
+		{Name: AnalyticsRequest, Properties: map[string]string{"method": "GET"}},
+		{Name: AnalyticsRequest, Properties: map[string]string{"method": "GET"}},
+		{Name: AnalyticsFeature, Properties: map[string]string{"feature": "search"}},
+	}))
+	buf := make([]byte, 512)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	lines := strings.Split(string(buf[:n]), "\n")
+	sort.Strings(lines)
+	assert.Equal(t, []string{"analytics.api_request.get:2|c", "analytics.feature_used.search:1|c"}, lines)
+}
+
+func TestNewAnalyticsSink(t *testing.T) {
+	sink, err := NewAnalyticsSink(Config{})
+	require.NoError(t, err)
+	assert.Nil(t, sink, "analytics are disabled by default")
+	_, err = NewAnalyticsSink(Config{AnalyticsSink: AnalyticsHTTP})
+	assert.Error(t, err)
+	_, err = NewAnalyticsSink(Config{AnalyticsSink: "segment"})
+	assert.Error(t, err)
+}
+
+func TestUseFeature(t *testing.T) {
+	UseFeature(context.Background(), "search")
+
+	ctx, features := WithFeatures(context.Background())
+	UseFeature(ctx, "filter")
+	UseFeature(ctx, "summary_view")
+	UseFeature(ctx, "filter")
+	assert.Equal(t, []string{"filter", "summary_view"}, features())
+}
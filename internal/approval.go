@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Review statuses of an event
+const (
+	EventStatusPending  = "pending"
+	EventStatusApproved = "approved"
+	EventStatusRejected = "rejected"
+)
+
+// ErrEventNotPending is returned when reviewing an event that is not awaiting review
+var ErrEventNotPending = newDomainError(ErrConflict, "event is not pending review")
+
+// EventReview is a reviewer's decision on a submitted event
+type EventReview struct {
+	ID      uuid.UUID `json:"id"`
+	EventID uuid.UUID `json:"event_id"`
+	// Decision is approved or rejected
+	Decision  string    `json:"decision"`
+	Comment   string    `json:"comment"`
+	Reviewer  string    `json:"reviewer"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Published reports whether the event appears in listings. Events written before
+// the approval workflow have no status and are published.
+func (e EventDB) Published() bool {
+	return e.Status == "" || e.Status == EventStatusApproved
+}
+
+// CanReview reports whether the principal may review events and publish without review
+func (p *Principal) CanReview() bool {
+	return p.Admin || p.HasScope(ScopeEventsReview)
+}
+
+const eventReviewColumns = `id, event_id, decision, comment, reviewer, created_at`
+
+func scanEventReview(row rowScanner, rv *EventReview) error {
+	return row.Scan(&rv.ID, &rv.EventID, &rv.Decision, &rv.Comment, &rv.Reviewer, &rv.CreatedAt)
+}
+
+// ListPendingEvents returns up to limit events awaiting review, oldest submission first
+func (r *EventRepository) ListPendingEvents(ctx context.Context, limit int) ([]EventDB, error) {
+	query, args := newSelect(qSelectEvents).
+		Where("status = ?", EventStatusPending).
+		OrderBy("created_at ASC").
+		OrderBy("id ASC").
+		Limit(limit).
+		Build()
+	events, err := r.queryEvents(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []EventDB{}
+	}
+	return events, nil
+}
+
+// ReviewEvent records a decision on a pending event and sets its status, in one
+// transaction. It fails with ErrEventNotPending when the event was already reviewed.
+func (r *EventRepository) ReviewEvent(ctx context.Context, review EventReview) (*EventDB, error) {
+	var reviewed EventDB
+	err := NewTxManager(r.db).JoinTx(ctx, func(ctx context.Context) error {
+		row := conn(ctx, r.db).QueryRowContext(ctx, qReviewEvent.SQL, review.EventID, review.Decision)
+		if err := scanEvent(row, &reviewed); err != nil {
+			if err != sql.ErrNoRows {
+				return fmt.Errorf("failed to review event: %w", err)
+			}
+			if _, err := r.GetEventByID(ctx, review.EventID); err != nil {
+				return err
+			}
+			return ErrEventNotPending
+		}
+		if _, err := conn(ctx, r.db).ExecContext(ctx, qInsertEventReview.SQL, review.ID, review.EventID, review.Decision, review.Comment, review.Reviewer); err != nil {
+			return fmt.Errorf("failed to record review: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := r.decryptEvent(&reviewed); err != nil {
+		return nil, fmt.Errorf("failed to decrypt event: %w", err)
+	}
+	return &reviewed, nil
+}
+
+// ListEventReviews returns the decisions on an event, oldest first
+func (r *EventRepository) ListEventReviews(ctx context.Context, eventID uuid.UUID) ([]EventReview, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, qListEventReviews.SQL, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event reviews: %w", err)
+	}
+	defer rows.Close()
+
+	reviews := []EventReview{}
+	for rows.Next() {
+		var rv EventReview
+		if err := scanEventReview(rows, &rv); err != nil {
+			return nil, fmt.Errorf("failed to scan event review: %w", err)
+		}
+		reviews = append(reviews, rv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating event reviews: %w", err)
+	}
+	return reviews, nil
+}
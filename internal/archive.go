@@ -0,0 +1,217 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// JobArchiveEvents is the scheduler job that moves ended events to the partitioned
+// archive and detaches its old partitions
+const JobArchiveEvents = "archive_events"
+
+// archivePartitionPrefix names the monthly partitions of events_archive, followed by
+// the year and month: events_archive_2025_01
+const archivePartitionPrefix = "events_archive_"
+
+// ArchiveParams are the params of the archive_events job
+type ArchiveParams struct {
+	// ArchiveAfterMonths is how many whole months after their month events stay live
+	ArchiveAfterMonths int `json:"archive_after_months"`
+	// DetachAfterMonths, when set, detaches the partitions of months this many months
+	// old, leaving them as standalone tables to dump or drop
+	DetachAfterMonths int `json:"detach_after_months,omitempty"`
+}
+
+// ArchivePartition is a monthly partition of the archive
+type ArchivePartition struct {
+	Name  string
+	Month time.Time
+}
+
+// monthStart returns the first instant of the UTC month of t
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// archivePartitionName names the partition of the month starting at month
+func archivePartitionName(month time.Time) string {
+	return fmt.Sprintf("%s%04d_%02d", archivePartitionPrefix, month.Year(), int(month.Month()))
+}
+
+type ArchiveRepository struct {
+	db *sql.DB
+}
+
+// NewArchiveRepository creates a repository moving events to the archive
+func NewArchiveRepository(db *sql.DB) *ArchiveRepository {
+	return &ArchiveRepository{db: db}
+}
+
+// ArchivableMonths returns the UTC months, oldest first, of the events that ended
+// before cutoff and can be archived. Events with ticket reservations stay live, as
+// records of what was paid.
+func (r *ArchiveRepository) ArchivableMonths(ctx context.Context, cutoff time.Time) ([]time.Time, error) {
+	rows, err := traced(ctx, r.db).QueryContext(ctx, `
+		SELECT DISTINCT date_trunc('month', e.start_time AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'
+		FROM events e
+		WHERE e.end_time < $1
+			AND NOT EXISTS (SELECT 1 FROM ticket_reservations t WHERE t.event_id = e.id)
+		ORDER BY 1`, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archivable months: %w", err)
+	}
+	defer rows.Close()
+
+	var months []time.Time
+	for rows.Next() {
+		var m time.Time
+		if err := rows.Scan(&m); err != nil {
+			return nil, fmt.Errorf("failed to scan month: %w", err)
+		}
+		months = append(months, m.UTC())
+	}
+	return months, rows.Err()
+}
+
+// ArchiveMonth moves the archivable events that start in month and ended before
+// cutoff into the month's partition, creating it first. The move is one transaction.
+// Deleting the events records their tombstones for sync clients and deletes their
+// comments, reminders, reviews, bookings and covers.
+func (r *ArchiveRepository) ArchiveMonth(ctx context.Context, month, cutoff time.Time) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	next := month.AddDate(0, 1, 0)
+	_, err = traced(ctx, tx).ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF events_archive FOR VALUES FROM (%s) TO (%s)`,
+		pq.QuoteIdentifier(archivePartitionName(month)), pq.QuoteLiteral(month.Format(time.RFC3339)), pq.QuoteLiteral(next.Format(time.RFC3339))))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create archive partition: %w", err)
+	}
+
+	// The archive has the columns of events in the same order, so rows copy as a whole
+	res, err := traced(ctx, tx).ExecContext(ctx, `
+		WITH moved AS (
+			DELETE FROM events e
+			WHERE e.start_time >= $1 AND e.start_time < $2 AND e.end_time < $3
+				AND NOT EXISTS (SELECT 1 FROM ticket_reservations t WHERE t.event_id = e.id)
+			RETURNING e.*
+		)
+		INSERT INTO events_archive SELECT * FROM moved`, month, next, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive events: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit archive: %w", err)
+	}
+	return int(n), nil
+}
+
+// ListArchivePartitions returns the partitions attached to the archive, oldest first
+func (r *ArchiveRepository) ListArchivePartitions(ctx context.Context) ([]ArchivePartition, error) {
+	rows, err := traced(ctx, r.db).QueryContext(ctx, `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'events_archive'::regclass
+		ORDER BY c.relname`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var partitions []ArchivePartition
+	for rows.Next() {
+		var p ArchivePartition
+		if err := rows.Scan(&p.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan archive partition: %w", err)
+		}
+		month, err := time.Parse("2006_01", strings.TrimPrefix(p.Name, archivePartitionPrefix))
+		if err != nil || !strings.HasPrefix(p.Name, archivePartitionPrefix) {
+			// Not one of ours; leave it alone
+			continue
+		}
+		p.Month = month
+		partitions = append(partitions, p)
+	}
+	return partitions, rows.Err()
+}
+
+// DetachArchivePartition detaches a partition from the archive, leaving it as a
+// standalone table
+func (r *ArchiveRepository) DetachArchivePartition(ctx context.Context, name string) error {
+	if _, err := traced(ctx, r.db).ExecContext(ctx, `ALTER TABLE events_archive DETACH PARTITION `+pq.QuoteIdentifier(name)); err != nil {
+		return fmt.Errorf("failed to detach %s: %w", name, err)
+	}
+	return nil
+}
+
+// ArchiveEventsJob returns the job moving the events of months older than
+// archive_after_months (default 12) to the archive, month by month, then detaching
+// the partitions older than detach_after_months when it is set
+func ArchiveEventsJob(repo ArchiveRepositoryInterface) JobFunc {
+	return func(ctx context.Context, raw json.RawMessage) (string, error) {
+		params := ArchiveParams{ArchiveAfterMonths: 12}
+		if len(raw) > 0 {
+			dec := json.NewDecoder(bytes.NewReader(raw))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&params); err != nil {
+				return "", fmt.Errorf("invalid params: %w", err)
+			}
+		}
+		if params.ArchiveAfterMonths < 1 {
+			return "", fmt.Errorf("archive_after_months must be at least 1")
+		}
+		if params.DetachAfterMonths != 0 && params.DetachAfterMonths <= params.ArchiveAfterMonths {
+			return "", fmt.Errorf("detach_after_months must be more than archive_after_months")
+		}
+
+		thisMonth := monthStart(time.Now())
+		cutoff := thisMonth.AddDate(0, -params.ArchiveAfterMonths, 0)
+		months, err := repo.ArchivableMonths(ctx, cutoff)
+		if err != nil {
+			return "", err
+		}
+		archived := 0
+		for _, month := range months {
+			n, err := repo.ArchiveMonth(ctx, month, cutoff)
+			archived += n
+			if err != nil {
+				return "", fmt.Errorf("archived %d events, then: %w", archived, err)
+			}
+		}
+
+		detached := 0
+		if params.DetachAfterMonths > 0 {
+			partitions, err := repo.ListArchivePartitions(ctx)
+			if err != nil {
+				return "", err
+			}
+			detachBefore := thisMonth.AddDate(0, -params.DetachAfterMonths, 0)
+			for _, p := range partitions {
+				if !p.Month.Before(detachBefore) {
+					continue
+				}
+				if err := repo.DetachArchivePartition(ctx, p.Name); err != nil {
+					return "", err
+				}
+				detached++
+			}
+		}
+		return fmt.Sprintf("archived %d events of %d months, detached %d partitions", archived, len(months), detached), nil
+	}
+}
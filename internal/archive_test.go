@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeArchive records the months archived and partitions detached
+type fakeArchive struct {
+	months     []time.Time
+	cutoff     time.Time
+	archived   []time.Time
+	partitions []ArchivePartition
+	detached   []string
+}
+
+func (f *fakeArchive) ArchivableMonths(ctx context.Context, cutoff time.Time) ([]time.Time, error) {
+	f.cutoff = cutoff
+	return f.months, nil
+}
+
+func (f *fakeArchive) ArchiveMonth(ctx context.Context, month, cutoff time.Time) (int, error) {
+	f.archived = append(f.archived, month)
+	return 10, nil
+}
+
+func (f *fakeArchive) ListArchivePartitions(ctx context.Context) ([]ArchivePartition, error) {
+	return f.partitions, nil
+}
+
+func (f *fakeArchive) DetachArchivePartition(ctx context.Context, name string) error {
+	f.detached = append(f.detached, name)
+	return nil
+}
+
+func TestArchiveEventsJob(t *testing.T) {
+	thisMonth := monthStart(time.Now())
+	old, older := thisMonth.AddDate(-2, 0, 0), thisMonth.AddDate(-3, 0, 0)
+	repo := &fakeArchive{
+		months:     []time.Time{older, old},
+		partitions: []ArchivePartition{{Name: archivePartitionName(older), Month: older}, {Name: archivePartitionName(old), Month: old}},
+	}
+
+	result, err := ArchiveEventsJob(repo)(context.Background(), json.RawMessage(`{"archive_after_months": 12, "detach_after_months": 30}`))
+	require.NoError(t, err)
+	assert.Equal(t, "archived 20 events of 2 months, detached 1 partitions", result)
+	assert.Equal(t, thisMonth.AddDate(-1, 0, 0), repo.cutoff)
+	assert.Equal(t, []time.Time{older, old}, repo.archived)
+	assert.Equal(t, []string{archivePartitionName(older)}, repo.detached)
+
+	for _, params := range []string{`{"archive_after_months": 0}`, `{"archive_after_months": 12, "detach_after_months": 6}`, `{"keep": 3}`} {
+		_, err := ArchiveEventsJob(repo)(context.Background(), json.RawMessage(params))
+		assert.Error(t, err, params)
+	}
+}
+
+func TestArchivePartitionName(t *testing.T) {
+	assert.Equal(t, "events_archive_2025_03", archivePartitionName(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)))
+}
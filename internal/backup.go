@@ -0,0 +1,269 @@
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobExportEvents is the scheduler job that backs up every event to storage
+const JobExportEvents = "export_events"
+
+// Backup file formats
+const (
+	BackupFormatJSON = "json"
+	BackupFormatCSV  = "csv"
+)
+
+// exportNamePattern keeps backup prefixes from escaping the backup location
+var exportNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,50}$`)
+
+// BackupOptions control how a backup file is written
+type BackupOptions struct {
+	// Prefix names the files, e.g. "nightly" writes nightly-20250902T020000Z.json
+	Prefix  string `json:"prefix"`
+	Format  string `json:"format"`
+	Gzip    bool   `json:"gzip"`
+	Encrypt bool   `json:"encrypt"`
+}
+
+// Key returns the storage key of a backup taken at t; the suffixes record the encoding
+func (o BackupOptions) Key(t time.Time) string {
+	key := o.Prefix + "-" + t.UTC().Format("20060102T150405Z") + "." + o.Format
+	if o.Gzip {
+		key += ".gz"
+	}
+	if o.Encrypt {
+		key += ".enc"
+	}
+	return key
+}
+
+// csvHeader is the column order of CSV backups. calendar_id was added last, so files
+// written before calendars existed have one column less.
+var csvHeader = []string{"id", "title", "description", "description_format", "start_time", "end_time", "location", "latitude", "longitude", "created_at", "updated_at", "calendar_id"}
+
+// EncodeBackup serializes events. Encryption uses the primary ENCRYPTION_KEYS key, so
+// cipher is required when opts.Encrypt is set.
+func EncodeBackup(events []EventDB, opts BackupOptions, cipher *FieldCipher) ([]byte, error) {
+	var data []byte
+	switch opts.Format {
+	case BackupFormatJSON:
+		var err error
+		if events == nil {
+			events = []EventDB{}
+		}
+		if data, err = json.Marshal(events); err != nil {
+			return nil, fmt.Errorf("failed to encode events: %w", err)
+		}
+	case BackupFormatCSV:
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write(csvHeader)
+		for _, e := range events {
+			w.Write([]string{
+				e.ID.String(),
+				e.Title,
+				optionalString(e.Description),
+				e.DescriptionFormat,
+				e.StartTime.UTC().Format(time.RFC3339Nano),
+				e.EndTime.UTC().Format(time.RFC3339Nano),
+				optionalString(e.Location),
+				optionalFloat(e.Latitude),
+				optionalFloat(e.Longitude),
+				e.CreatedAt.UTC().Format(time.RFC3339Nano),
+				e.UpdatedAt.UTC().Format(time.RFC3339Nano),
+				optionalUUID(e.CalendarID),
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return nil, fmt.Errorf("failed to encode events: %w", err)
+		}
+		data = buf.Bytes()
+	default:
+		return nil, fmt.Errorf("unknown backup format %q", opts.Format)
+	}
+
+	if opts.Gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(data)
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress backup: %w", err)
+		}
+		data = buf.Bytes()
+	}
+	if opts.Encrypt {
+		if cipher == nil {
+			return nil, errors.New("encrypted backups require ENCRYPTION_KEYS")
+		}
+		return cipher.SealBytes(data)
+	}
+	return data, nil
+}
+
+// DecodeBackup reads a file written by EncodeBackup. Encryption, compression and format
+// are detected from the content, so renamed files restore too.
+func DecodeBackup(data []byte, cipher *FieldCipher) ([]EventDB, error) {
+	if bytes.HasPrefix(data, []byte(sealedBlobMagic)) {
+		if cipher == nil {
+			return nil, errors.New("backup is encrypted; set ENCRYPTION_KEYS")
+		}
+		var err error
+		if data, err = cipher.OpenBytes(data); err != nil {
+			return nil, err
+		}
+	}
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress backup: %w", err)
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("failed to decompress backup: %w", err)
+		}
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var events []EventDB
+		if err := json.Unmarshal(trimmed, &events); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON backup: %w", err)
+		}
+		return events, nil
+	}
+	return decodeCSVBackup(data)
+}
+
+func decodeCSVBackup(data []byte) ([]EventDB, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV backup: %w", err)
+	}
+	if len(records) == 0 || len(records[0]) < len(csvHeader)-1 || records[0][0] != csvHeader[0] {
+		return nil, errors.New("backup is neither JSON nor CSV with the expected header")
+	}
+
+	events := make([]EventDB, 0, len(records)-1)
+	for i, rec := range records[1:] {
+		e, err := parseCSVEvent(rec)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+2, err)
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+func parseCSVEvent(rec []string) (EventDB, error) {
+	var e EventDB
+	var err error
+	if e.ID, err = uuid.Parse(rec[0]); err != nil {
+		return e, fmt.Errorf("invalid id: %w", err)
+	}
+	e.Title = rec[1]
+	e.Description = nonEmpty(rec[2])
+	e.DescriptionFormat = rec[3]
+	for _, f := range []struct {
+		dst *time.Time
+		src string
+	}{{&e.StartTime, rec[4]}, {&e.EndTime, rec[5]}, {&e.CreatedAt, rec[9]}, {&e.UpdatedAt, rec[10]}} {
+		if *f.dst, err = time.Parse(time.RFC3339Nano, f.src); err != nil {
+			return e, fmt.Errorf("invalid timestamp %q", f.src)
+		}
+	}
+	e.Location = nonEmpty(rec[6])
+	for _, f := range []struct {
+		dst **float64
+		src string
+	}{{&e.Latitude, rec[7]}, {&e.Longitude, rec[8]}} {
+		if f.src == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(f.src, 64)
+		if err != nil {
+			return e, fmt.Errorf("invalid coordinate %q", f.src)
+		}
+		*f.dst = &v
+	}
+	if len(rec) > 11 && rec[11] != "" {
+		id, err := uuid.Parse(rec[11])
+		if err != nil {
+			return e, fmt.Errorf("invalid calendar_id: %w", err)
+		}
+		e.CalendarID = &id
+	}
+	return e, nil
+}
+
+func optionalString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func optionalUUID(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+func optionalFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+// nonEmpty maps "" to nil; CSV cannot tell an empty value from a missing one
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// ExportEventsJob returns a job that writes all events to storage. Params are
+// BackupOptions; the default is an uncompressed, unencrypted JSON file.
+func ExportEventsJob(repo EventRepositoryInterface, storage Storage, cipher *FieldCipher) JobFunc {
+	return func(ctx context.Context, raw json.RawMessage) (string, error) {
+		opts := BackupOptions{Prefix: "events", Format: BackupFormatJSON}
+		if len(raw) > 0 {
+			dec := json.NewDecoder(bytes.NewReader(raw))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&opts); err != nil {
+				return "", fmt.Errorf("invalid params: %w", err)
+			}
+		}
+		if !exportNamePattern.MatchString(opts.Prefix) {
+			return "", fmt.Errorf("invalid prefix %q", opts.Prefix)
+		}
+
+		events, err := repo.GetEvents(ctx)
+		if err != nil {
+			return "", err
+		}
+		data, err := EncodeBackup(events, opts, cipher)
+		if err != nil {
+			return "", err
+		}
+
+		key := opts.Key(time.Now())
+		if err := storage.Put(ctx, key, data); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("exported %d events to %s", len(events), storage.Location(key)), nil
+	}
+}
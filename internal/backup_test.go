@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	cipher, err := NewFieldCipher("v1:" + testKey('k'))
+	assert.NoError(t, err)
+
+	desc, loc, lat, lng := "Quarterly, \"all hands\"\nsecond line", "Room 4B", 40.4168, -3.7038
+	start := time.Date(2025, 9, 2, 10, 0, 0, 0, time.UTC)
+	events := []EventDB{
+		{ID: uuid.New(), Title: "Planning", Description: &desc, DescriptionFormat: DescriptionFormatMarkdown, StartTime: start, EndTime: start.Add(time.Hour),
+			Location: &loc, Latitude: &lat, Longitude: &lng, CreatedAt: start.Add(-time.Hour), UpdatedAt: start.Add(-time.Minute)},
+		{ID: uuid.New(), Title: "Standup", DescriptionFormat: DescriptionFormatPlain, StartTime: start, EndTime: start.Add(15 * time.Minute), CreatedAt: start, UpdatedAt: start},
+	}
+
+	tests := []BackupOptions{
+		{Format: BackupFormatJSON},
+		{Format: BackupFormatCSV},
+		{Format: BackupFormatJSON, Gzip: true},
+		{Format: BackupFormatCSV, Gzip: true, Encrypt: true},
+	}
+	for _, opts := range tests {
+		t.Run(opts.Key(start), func(t *testing.T) {
+			data, err := EncodeBackup(events, opts, cipher)
+			assert.NoError(t, err)
+			if opts.Encrypt {
+				assert.NotContains(t, string(data), "Planning")
+			}
+
+			decoded, err := DecodeBackup(data, cipher)
+			assert.NoError(t, err)
+			assert.Len(t, decoded, 2)
+			for i := range events {
+				assert.Equal(t, events[i].ID, decoded[i].ID)
+				assert.Equal(t, events[i].Description, decoded[i].Description)
+				assert.Equal(t, events[i].Latitude, decoded[i].Latitude)
+				assert.True(t, events[i].StartTime.Equal(decoded[i].StartTime))
+				assert.True(t, events[i].UpdatedAt.Equal(decoded[i].UpdatedAt))
+			}
+		})
+	}
+}
+
+func TestBackupEncryptionRequiresKeys(t *testing.T) {
+	_, err := EncodeBackup(nil, BackupOptions{Format: BackupFormatJSON, Encrypt: true}, nil)
+	assert.Error(t, err)
+
+	cipher, _ := NewFieldCipher("v1:" + testKey('k'))
+	data, err := EncodeBackup(nil, BackupOptions{Format: BackupFormatJSON, Encrypt: true}, cipher)
+	assert.NoError(t, err)
+	_, err = DecodeBackup(data, nil)
+	assert.Error(t, err)
+}
+
+func TestLocalStorage(t *testing.T) {
+	s := &LocalStorage{Dir: t.TempDir()}
+	ctx := context.Background()
+
+	assert.NoError(t, s.Put(ctx, "nightly/a.json", []byte("[]")))
+	data, err := s.Get(ctx, "nightly/a.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "[]", string(data))
+
+	_, err = s.Get(ctx, "missing.json")
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+	assert.Error(t, s.Put(ctx, "../escape.json", nil))
+}
@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ErrCalendarNotFound is returned when a calendar does not exist
+var ErrCalendarNotFound = newDomainError(ErrNotFound, "calendar not found")
+
+// ErrUnknownCalendar is returned when an event refers to a calendar that does not exist
+var ErrUnknownCalendar = newDomainError(ErrValidation, "calendar not found")
+
+// ErrEventOverlap is returned when an event would overlap another in an exclusive calendar
+var ErrEventOverlap = newDomainError(ErrConflict, "event overlaps another event in an exclusive calendar")
+
+// ErrCalendarOverlaps is returned when marking a calendar exclusive while its events overlap
+var ErrCalendarOverlaps = newDomainError(ErrConflict, "calendar has overlapping events")
+
+// Calendar groups events. Events without a calendar belong to the default calendar.
+type Calendar struct {
+	ID      uuid.UUID `json:"id"`
+	Name    string    `json:"name"`
+	OwnerID string    `json:"owner_id"`
+	// OrganizationID is the organization owning a shared calendar, whose admins
+	// manage it along with its owner
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	// Exclusive calendars never have two events at the same time; the database rejects
+	// overlapping events that are not rejected
+	Exclusive bool `json:"exclusive"`
+	// Visibility is full, or busy when viewers other than its owner, the organization's
+	// members and delegates only see when the calendar is busy
+	Visibility string `json:"visibility"`
+	// FeedVersion is signed into the calendar's feed token; rotating the token bumps it
+	FeedVersion int `json:"-"`
+}
+
+type CalendarRepository struct {
+	db      *sql.DB
+	cascade *CascadeEngine
+}
+
+// NewCalendarRepository creates a new calendar repository
+func NewCalendarRepository(db *sql.DB) *CalendarRepository {
+	return &CalendarRepository{db: db, cascade: NewCascadeEngine(db, nil)}
+}
+
+// SetCascade handles the dependents of deleted calendars by the policies of cascade
+// rather than the defaults
+func (r *CalendarRepository) SetCascade(cascade *CascadeEngine) {
+	r.cascade = cascade
+}
+
+const calendarColumns = `id, name, owner_id, organization_id, created_at, updated_at, exclusive, visibility, feed_version`
+
+func scanCalendar(row rowScanner, c *Calendar) error {
+	return row.Scan(&c.ID, &c.Name, &c.OwnerID, &c.OrganizationID, &c.CreatedAt, &c.UpdatedAt, &c.Exclusive, &c.Visibility, &c.FeedVersion)
+}
+
+// CreateCalendar stores a new calendar
+func (r *CalendarRepository) CreateCalendar(ctx context.Context, c Calendar) (*Calendar, error) {
+	query := `
+		INSERT INTO calendars (id, name, owner_id, organization_id, exclusive, visibility)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + calendarColumns
+
+	if c.Visibility == "" {
+		c.Visibility = CalendarVisibilityFull
+	}
+	var created Calendar
+	if err := scanCalendar(conn(ctx, r.db).QueryRowContext(ctx, query, c.ID, c.Name, c.OwnerID, c.OrganizationID, c.Exclusive, c.Visibility), &created); err != nil {
+		if isForeignKeyViolation(err, "calendars_organization_id_fkey") {
+			return nil, ErrUnknownOrganization
+		}
+		return nil, fmt.Errorf("failed to create calendar: %w", err)
+	}
+	return &created, nil
+}
+
+// ListCalendars returns every calendar ordered by name
+func (r *CalendarRepository) ListCalendars(ctx context.Context) ([]Calendar, error) {
+	return r.queryCalendars(ctx, `SELECT `+calendarColumns+` FROM calendars ORDER BY name, id`)
+}
+
+// ListOrganizationCalendars returns the calendars an organization owns, ordered by name
+func (r *CalendarRepository) ListOrganizationCalendars(ctx context.Context, orgID uuid.UUID) ([]Calendar, error) {
+	return r.queryCalendars(ctx, `SELECT `+calendarColumns+` FROM calendars WHERE organization_id = $1 ORDER BY name, id`, orgID)
+}
+
+func (r *CalendarRepository) queryCalendars(ctx context.Context, query string, args ...any) ([]Calendar, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query calendars: %w", err)
+	}
+	defer rows.Close()
+
+	calendars := []Calendar{}
+	for rows.Next() {
+		var c Calendar
+		if err := scanCalendar(rows, &c); err != nil {
+			return nil, fmt.Errorf("failed to scan calendar: %w", err)
+		}
+		calendars = append(calendars, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating calendars: %w", err)
+	}
+	return calendars, nil
+}
+
+// GetCalendar retrieves a calendar by ID
+func (r *CalendarRepository) GetCalendar(ctx context.Context, id uuid.UUID) (*Calendar, error) {
+	var c Calendar
+	err := scanCalendar(conn(ctx, r.db).QueryRowContext(ctx, `SELECT `+calendarColumns+` FROM calendars WHERE id = $1`, id), &c)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCalendarNotFound
+		}
+		return nil, fmt.Errorf("failed to get calendar: %w", err)
+	}
+	return &c, nil
+}
+
+// UpdateCalendar changes the name of a calendar and whether it is exclusive. Making
+// a calendar exclusive fails with ErrCalendarOverlaps while its events overlap.
+func (r *CalendarRepository) UpdateCalendar(ctx context.Context, c Calendar) (*Calendar, error) {
+	query := `UPDATE calendars SET name = $2, exclusive = $3, visibility = $4, updated_at = NOW() WHERE id = $1 RETURNING ` + calendarColumns
+
+	var updated Calendar
+	if err := scanCalendar(conn(ctx, r.db).QueryRowContext(ctx, query, c.ID, c.Name, c.Exclusive, c.Visibility), &updated); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCalendarNotFound
+		}
+		if isExclusionViolation(err, "events_exclusive_no_overlap") {
+			return nil, ErrCalendarOverlaps
+		}
+		return nil, fmt.Errorf("failed to update calendar: %w", err)
+	}
+	return &updated, nil
+}
+
+// DeleteCalendar removes a calendar and handles its events and other dependents by
+// their delete policies; by default they are deleted with it. It fails with a
+// DependentsError when a policy forbids the delete.
+func (r *CalendarRepository) DeleteCalendar(ctx context.Context, id uuid.UUID) error {
+	return NewTxManager(r.db).JoinTx(ctx, func(ctx context.Context) error {
+		if _, err := r.cascade.DeleteDependents(ctx, CascadeCalendar, []string{id.String()}); err != nil {
+			return err
+		}
+		res, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM calendars WHERE id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete calendar: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrCalendarNotFound
+		}
+		return nil
+	})
+}
+
+// RotateFeedToken bumps the feed version of a calendar, invalidating its feed URLs
+func (r *CalendarRepository) RotateFeedToken(ctx context.Context, id uuid.UUID) (*Calendar, error) {
+	query := `UPDATE calendars SET feed_version = feed_version + 1, updated_at = NOW() WHERE id = $1 RETURNING ` + calendarColumns
+
+	var c Calendar
+	if err := scanCalendar(conn(ctx, r.db).QueryRowContext(ctx, query, id), &c); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCalendarNotFound
+		}
+		return nil, fmt.Errorf("failed to rotate feed token: %w", err)
+	}
+	return &c, nil
+}
+
+// isForeignKeyViolation reports whether err is a Postgres foreign key violation on constraint
+func isForeignKeyViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503" && pqErr.Constraint == constraint
+}
+
+// isUniqueViolation reports whether err is a violation of the named unique constraint
+// or index
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
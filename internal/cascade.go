@@ -0,0 +1,402 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Policies for the dependents of deleted records
+const (
+	// CascadeDelete deletes the dependents with the record
+	CascadeDelete = "cascade"
+	// CascadeDetach keeps the dependents, no longer linked to the record
+	CascadeDetach = "detach"
+	// CascadeForbid fails the delete while the record has dependents
+	CascadeForbid = "forbid"
+)
+
+// Kinds of records whose deletes go through the cascade engine
+const (
+	CascadeEvent    = "event"
+	CascadeCalendar = "calendar"
+	CascadeUser     = "user"
+)
+
+// cascadeLocks lock the records of each kind being deleted, given as $1, so no
+// dependent can be added to them between the checks and the delete. Users have no
+// table of their own.
+var cascadeLocks = map[string]string{
+	CascadeEvent:    `SELECT id FROM events WHERE id = ANY($1::uuid[]) ORDER BY id FOR UPDATE`,
+	CascadeCalendar: `SELECT id FROM calendars WHERE id = ANY($1::uuid[]) ORDER BY id FOR UPDATE`,
+	CascadeUser:     ``,
+}
+
+// cascadeRelation is a kind of dependents of a kind of records. Its statements take the
+// IDs of the records as an array in $1.
+type cascadeRelation struct {
+	kind, name string
+	// policies are the policies the relation allows, its default first
+	policies []string
+	// child is the kind of the dependents when they have dependents of their own, which
+	// are handled before deleting them
+	child string
+	count string
+	// ids selects the IDs of the dependents, for child
+	ids    string
+	remove string
+	// detach unlinks the dependents; it is empty when deleting the record unlinks them
+	detach string
+	// removed, when set, is called with the rows remove returns and counts them
+	removed func(ctx context.Context, e *CascadeEngine, rows *sql.Rows) (int, error)
+}
+
+// cascadeRelations are the dependents of events, calendars and users. The defaults are
+// what deletes did before the policies could be configured: the dependents of events
+// and calendars go with them, and the personal calendars of erased users are kept,
+// anonymized by the erasure.
+var cascadeRelations = []cascadeRelation{
+	{
+		kind: CascadeEvent, name: "reminders", policies: []string{CascadeDelete, CascadeForbid},
+		count:  `SELECT COUNT(*) FROM event_reminders WHERE event_id = ANY($1::uuid[])`,
+		remove: `DELETE FROM event_reminders WHERE event_id = ANY($1::uuid[])`,
+	},
+	{
+		kind: CascadeEvent, name: "attachments", policies: []string{CascadeDelete, CascadeForbid},
+		count:   `SELECT COUNT(*) FROM event_covers WHERE event_id = ANY($1::uuid[])`,
+		remove:  `DELETE FROM event_covers WHERE event_id = ANY($1::uuid[]) RETURNING event_id, etag`,
+		removed: removeCoverFiles,
+	},
+	{
+		kind: CascadeEvent, name: "comments", policies: []string{CascadeDelete, CascadeForbid},
+		count:  `SELECT COUNT(*) FROM event_comments WHERE event_id = ANY($1::uuid[])`,
+		remove: `DELETE FROM event_comments WHERE event_id = ANY($1::uuid[])`,
+	},
+	{
+		kind: CascadeEvent, name: "tickets", policies: []string{CascadeDelete, CascadeForbid},
+		count:  `SELECT COUNT(*) FROM ticket_reservations WHERE event_id = ANY($1::uuid[])`,
+		remove: `DELETE FROM ticket_reservations WHERE event_id = ANY($1::uuid[])`,
+	},
+	{
+		kind: CascadeEvent, name: "bookings", policies: []string{CascadeDelete, CascadeForbid},
+		count:  `SELECT COUNT(*) FROM resource_bookings WHERE event_id = ANY($1::uuid[])`,
+		remove: `DELETE FROM resource_bookings WHERE event_id = ANY($1::uuid[])`,
+	},
+	{
+		kind: CascadeCalendar, name: "events", policies: []string{CascadeDelete, CascadeDetach, CascadeForbid}, child: CascadeEvent,
+		count:  `SELECT COUNT(*) FROM events WHERE calendar_id = ANY($1::uuid[])`,
+		ids:    `SELECT id FROM events WHERE calendar_id = ANY($1::uuid[])`,
+		remove: `DELETE FROM events WHERE calendar_id = ANY($1::uuid[])`,
+		detach: `UPDATE events SET calendar_id = NULL WHERE calendar_id = ANY($1::uuid[])`,
+	},
+	{
+		// Detached calendar webhooks would receive the changes of every calendar
+		kind: CascadeCalendar, name: "webhooks", policies: []string{CascadeDelete, CascadeForbid},
+		count:  `SELECT COUNT(*) FROM webhooks WHERE calendar_id = ANY($1::uuid[])`,
+		remove: `DELETE FROM webhooks WHERE calendar_id = ANY($1::uuid[])`,
+	},
+	{
+		// Detached sources are disabled, as they would create events outside any calendar
+		kind: CascadeCalendar, name: "ingest_sources", policies: []string{CascadeDelete, CascadeDetach, CascadeForbid},
+		count:  `SELECT COUNT(*) FROM ingest_sources WHERE calendar_id = ANY($1::uuid[])`,
+		remove: `DELETE FROM ingest_sources WHERE calendar_id = ANY($1::uuid[])`,
+		detach: `UPDATE ingest_sources SET calendar_id = NULL, enabled = FALSE WHERE calendar_id = ANY($1::uuid[])`,
+	},
+	{
+		// Detached rules would apply to every calendar
+		kind: CascadeCalendar, name: "policies", policies: []string{CascadeDelete, CascadeForbid},
+		count:  `SELECT COUNT(*) FROM policy_rules WHERE calendar_id = ANY($1::uuid[])`,
+		remove: `DELETE FROM policy_rules WHERE calendar_id = ANY($1::uuid[])`,
+	},
+	{
+		// Calendars of organizations belong to them and always outlive their owner
+		kind: CascadeUser, name: "calendars", policies: []string{CascadeDetach, CascadeDelete, CascadeForbid}, child: CascadeCalendar,
+		count:  `SELECT COUNT(*) FROM calendars WHERE owner_id = ANY($1::text[]) AND organization_id IS NULL`,
+		ids:    `SELECT id FROM calendars WHERE owner_id = ANY($1::text[]) AND organization_id IS NULL`,
+		remove: `DELETE FROM calendars WHERE owner_id = ANY($1::text[]) AND organization_id IS NULL`,
+	},
+	{
+		kind: CascadeUser, name: "webhooks", policies: []string{CascadeDelete, CascadeForbid},
+		count:  `SELECT COUNT(*) FROM webhooks WHERE owner_id = ANY($1::text[])`,
+		remove: `DELETE FROM webhooks WHERE owner_id = ANY($1::text[])`,
+	},
+	{
+		kind: CascadeUser, name: "reminders", policies: []string{CascadeDelete, CascadeForbid},
+		count:  `SELECT COUNT(*) FROM event_reminders WHERE owner_id = ANY($1::text[])`,
+		remove: `DELETE FROM event_reminders WHERE owner_id = ANY($1::text[])`,
+	},
+}
+
+// CascadeRelations returns the relations policies can be set for, as kind.name, with
+// the policies each allows, its default first
+func CascadeRelations() map[string][]string {
+	out := make(map[string][]string, len(cascadeRelations))
+	for _, rel := range cascadeRelations {
+		out[rel.kind+"."+rel.name] = rel.policies
+	}
+	return out
+}
+
+// ParseCascadePolicies parses policies given as kind.relation=policy, such as
+// calendar.events=detach, checking that each relation exists and allows its policy
+func ParseCascadePolicies(items []string) (map[string]string, error) {
+	relations := CascadeRelations()
+	policies := make(map[string]string, len(items))
+	for _, item := range items {
+		name, policy, ok := strings.Cut(item, "=")
+		name, policy = strings.TrimSpace(name), strings.TrimSpace(policy)
+		if !ok || policy == "" {
+			return nil, fmt.Errorf("invalid delete policy %q: expected kind.relation=policy", item)
+		}
+		allowed, ok := relations[name]
+		if !ok {
+			known := make([]string, 0, len(relations))
+			for name := range relations {
+				known = append(known, name)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown relation %q (available: %s)", name, strings.Join(known, ", "))
+		}
+		if !slices.Contains(allowed, policy) {
+			return nil, fmt.Errorf("relation %s does not allow %q (available: %s)", name, policy, strings.Join(allowed, ", "))
+		}
+		if _, dup := policies[name]; dup {
+			return nil, fmt.Errorf("relation %s is listed twice", name)
+		}
+		policies[name] = policy
+	}
+	return policies, nil
+}
+
+// Dependent counts the dependents of one relation that forbid a delete
+type Dependent struct {
+	// Relation is kind.name; the kind differs from the deleted one for the dependents
+	// of records the delete would cascade to
+	Relation string `json:"relation"`
+	Count    int    `json:"count"`
+}
+
+// DependentsError rejects a delete forbidden by the policy of dependents the records
+// still have. It is a conflict.
+type DependentsError struct {
+	Kind       string
+	Dependents []Dependent
+}
+
+func (e *DependentsError) Error() string {
+	return fmt.Sprintf("cannot delete the %s while it has %s", e.Kind, e.List())
+}
+
+// List describes the dependents, such as "events (3), event tickets (2)"
+func (e *DependentsError) List() string {
+	parts := make([]string, len(e.Dependents))
+	for i, d := range e.Dependents {
+		kind, name, _ := strings.Cut(d.Relation, ".")
+		if kind != e.Kind {
+			name = kind + " " + name
+		}
+		parts[i] = fmt.Sprintf("%s (%d)", strings.ReplaceAll(name, "_", " "), d.Count)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Is makes errors.Is(err, ErrConflict) match
+func (e *DependentsError) Is(target error) bool { return target == ErrConflict }
+
+// CascadeEngine handles the dependents of the events, calendars and users being
+// deleted, by the policy of each relation, in the transaction of the delete. Forbidden
+// deletes fail before anything is written. Archiving goes straight to the database,
+// whose foreign keys delete the dependents.
+type CascadeEngine struct {
+	db       *sql.DB
+	policies map[string]string
+	covers   *CoverStore
+}
+
+// NewCascadeEngine applies policies, as ParseCascadePolicies returns them, and the
+// defaults to the other relations
+func NewCascadeEngine(db *sql.DB, policies map[string]string) *CascadeEngine {
+	return &CascadeEngine{db: db, policies: policies}
+}
+
+// NewCascadeEngineFromConfig applies cfg.DeletePolicies
+func NewCascadeEngineFromConfig(db *sql.DB, cfg Config) (*CascadeEngine, error) {
+	policies, err := ParseCascadePolicies(cfg.DeletePolicies)
+	if err != nil {
+		return nil, err
+	}
+	return NewCascadeEngine(db, policies), nil
+}
+
+// SetCoverStore deletes the files of the covers of deleted events once the delete
+// commits. Without it the files are left behind in the storage.
+func (e *CascadeEngine) SetCoverStore(covers *CoverStore) {
+	e.covers = covers
+}
+
+// policy returns the configured policy of rel, or its default
+func (e *CascadeEngine) policy(rel cascadeRelation) string {
+	if policy, ok := e.policies[rel.kind+"."+rel.name]; ok {
+		return policy
+	}
+	return rel.policies[0]
+}
+
+// DeleteDependents handles the dependents of the records of kind with the given IDs,
+// which the caller deletes next in the same transaction. It fails with a
+// DependentsError, writing nothing, when a forbidding relation has dependents, and
+// returns the number of dependents deleted or detached by relation name otherwise.
+func (e *CascadeEngine) DeleteDependents(ctx context.Context, kind string, ids []string) (map[string]int, error) {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); !ok {
+		return nil, fmt.Errorf("deleting dependents needs a transaction")
+	}
+	if lock := cascadeLocks[kind]; lock != "" {
+		rows, err := conn(ctx, e.db).QueryContext(ctx, lock, pq.Array(ids))
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock %ss: %w", kind, err)
+		}
+		rows.Close()
+	}
+
+	var forbidden []Dependent
+	if err := e.check(ctx, kind, ids, &forbidden); err != nil {
+		return nil, err
+	}
+	if len(forbidden) > 0 {
+		return nil, &DependentsError{Kind: kind, Dependents: forbidden}
+	}
+	counts := map[string]int{}
+	if err := e.apply(ctx, kind, ids, counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// check collects the dependents of forbidding relations, following cascades
+func (e *CascadeEngine) check(ctx context.Context, kind string, ids []string, forbidden *[]Dependent) error {
+	for _, rel := range cascadeRelations {
+		if rel.kind != kind {
+			continue
+		}
+		switch e.policy(rel) {
+		case CascadeForbid:
+			var n int
+			if err := conn(ctx, e.db).QueryRowContext(ctx, rel.count, pq.Array(ids)).Scan(&n); err != nil {
+				return fmt.Errorf("failed to count %s %s: %w", kind, rel.name, err)
+			}
+			if n > 0 {
+				*forbidden = append(*forbidden, Dependent{Relation: rel.kind + "." + rel.name, Count: n})
+			}
+		case CascadeDelete:
+			if rel.child == "" {
+				continue
+			}
+			childIDs, err := e.childIDs(ctx, rel, ids)
+			if err != nil {
+				return err
+			}
+			if len(childIDs) > 0 {
+				if err := e.check(ctx, rel.child, childIDs, forbidden); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// apply deletes and detaches the dependents, deleting those of cascaded records first
+func (e *CascadeEngine) apply(ctx context.Context, kind string, ids []string, counts map[string]int) error {
+	for _, rel := range cascadeRelations {
+		if rel.kind != kind {
+			continue
+		}
+		query, verb := "", ""
+		switch e.policy(rel) {
+		case CascadeDetach:
+			query, verb = rel.detach, "detach"
+		case CascadeDelete:
+			if rel.child != "" {
+				childIDs, err := e.childIDs(ctx, rel, ids)
+				if err != nil {
+					return err
+				}
+				if len(childIDs) > 0 {
+					if err := e.apply(ctx, rel.child, childIDs, counts); err != nil {
+						return err
+					}
+				}
+			}
+			query, verb = rel.remove, "delete"
+		}
+		if query == "" {
+			continue
+		}
+
+		var n int
+		if verb == "delete" && rel.removed != nil {
+			rows, err := conn(ctx, e.db).QueryContext(ctx, query, pq.Array(ids))
+			if err != nil {
+				return fmt.Errorf("failed to delete %s %s: %w", kind, rel.name, err)
+			}
+			n, err = rel.removed(ctx, e, rows)
+			rows.Close()
+			if err != nil {
+				return fmt.Errorf("failed to delete %s %s: %w", kind, rel.name, err)
+			}
+		} else {
+			res, err := conn(ctx, e.db).ExecContext(ctx, query, pq.Array(ids))
+			if err != nil {
+				return fmt.Errorf("failed to %s %s %s: %w", verb, kind, rel.name, err)
+			}
+			affected, _ := res.RowsAffected()
+			n = int(affected)
+		}
+		if n > 0 {
+			counts[rel.name] += n
+		}
+	}
+	return nil
+}
+
+func (e *CascadeEngine) childIDs(ctx context.Context, rel cascadeRelation, ids []string) ([]string, error) {
+	scanID := func(row rowScanner, id *string) error { return row.Scan(id) }
+	childIDs, err := queryAll(ctx, e.db, scanID, rel.ids, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s %s: %w", rel.kind, rel.name, err)
+	}
+	return childIDs, nil
+}
+
+// removeCoverFiles deletes the files of the removed covers once the delete commits
+func removeCoverFiles(ctx context.Context, e *CascadeEngine, rows *sql.Rows) (int, error) {
+	type removed struct {
+		eventID uuid.UUID
+		etag    string
+	}
+	var covers []removed
+	for rows.Next() {
+		var c removed
+		if err := rows.Scan(&c.eventID, &c.etag); err != nil {
+			return 0, err
+		}
+		covers = append(covers, c)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if store := e.covers; store != nil && len(covers) > 0 {
+		AfterCommit(ctx, func() {
+			for _, c := range covers {
+				store.removeFiles(ctx, c.eventID, c.etag)
+			}
+		})
+	}
+	return len(covers), nil
+}
@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCascadePolicies(t *testing.T) {
+	policies, err := ParseCascadePolicies([]string{"calendar.events=detach", " event.tickets = forbid "})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"calendar.events": CascadeDetach, "event.tickets": CascadeForbid}, policies)
+
+	for _, items := range [][]string{
+		{"calendar.events"},
+		{"calendar.attendees=cascade"},
+		{"calendar.webhooks=detach"},
+		{"event.reminders=cascade", "event.reminders=forbid"},
+	} {
+		_, err := ParseCascadePolicies(items)
+		assert.Error(t, err, "%v", items)
+	}
+}
+
+func TestCascadeDefaults(t *testing.T) {
+	e := NewCascadeEngine(nil, map[string]string{"calendar.events": CascadeForbid})
+	for _, rel := range cascadeRelations {
+		switch {
+		case rel.kind == CascadeCalendar && rel.name == "events":
+			assert.Equal(t, CascadeForbid, e.policy(rel))
+		case rel.kind == CascadeUser && rel.name == "calendars":
+			assert.Equal(t, CascadeDetach, e.policy(rel), "erasures anonymize personal calendars")
+		default:
+			assert.Equal(t, CascadeDelete, e.policy(rel), "%s.%s", rel.kind, rel.name)
+		}
+		if slices.Contains(rel.policies, CascadeDelete) {
+			assert.NotEmpty(t, rel.remove, "%s.%s", rel.kind, rel.name)
+		}
+		if rel.child != "" {
+			assert.NotEmpty(t, rel.ids, "%s.%s", rel.kind, rel.name)
+		}
+	}
+}
+
+func TestDependentsError(t *testing.T) {
+	err := fmt.Errorf("failed to delete calendar: %w", &DependentsError{Kind: CascadeCalendar, Dependents: []Dependent{
+		{Relation: "calendar.ingest_sources", Count: 2},
+		{Relation: "event.attachments", Count: 1},
+	}})
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, ErrConflict, KindOf(err))
+	assert.EqualError(t, err, "failed to delete calendar: cannot delete the calendar while it has ingest sources (2), event attachments (1)")
+}
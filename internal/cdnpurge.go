@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CDN providers caches can be purged at
+const (
+	CDNCloudflare = "cloudflare"
+	CDNFastly     = "fastly"
+)
+
+// cloudflarePurgeBatch is how many URLs one Cloudflare purge request may name
+const cloudflarePurgeBatch = 30
+
+// cdnPurgeTimeout bounds the purges of one write, which run in the background
+const cdnPurgeTimeout = 10 * time.Second
+
+// CachePurger removes URLs from a CDN's cache, so the next request for them reaches
+// the API
+type CachePurger interface {
+	Purge(ctx context.Context, urls []string) error
+}
+
+// NewCachePurger builds the purger of cfg.CDNProvider, or returns nil when no CDN is
+// configured
+func NewCachePurger(cfg Config) (CachePurger, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	switch cfg.CDNProvider {
+	case "":
+		return nil, nil
+	case CDNCloudflare:
+		if cfg.CDNAPIToken == "" || cfg.CDNZoneID == "" {
+			return nil, errors.New("purging Cloudflare needs CDN_API_TOKEN and CDN_ZONE_ID")
+		}
+		return &cloudflarePurger{client: client, token: cfg.CDNAPIToken,
+			endpoint: "https://api.cloudflare.com/client/v4/zones/" + url.PathEscape(cfg.CDNZoneID) + "/purge_cache"}, nil
+	case CDNFastly:
+		if cfg.CDNAPIToken == "" {
+			return nil, errors.New("purging Fastly needs CDN_API_TOKEN")
+		}
+		return &fastlyPurger{client: client, token: cfg.CDNAPIToken, baseURL: "https://api.fastly.com/purge/"}, nil
+	default:
+		return nil, fmt.Errorf("unknown CDN provider %q (available: %s, %s)", cfg.CDNProvider, CDNCloudflare, CDNFastly)
+	}
+}
+
+// cloudflarePurger purges files by URL through the Cloudflare API
+type cloudflarePurger struct {
+	client   *http.Client
+	token    string
+	endpoint string
+}
+
+func (p *cloudflarePurger) Purge(ctx context.Context, urls []string) error {
+	for start := 0; start < len(urls); start += cloudflarePurgeBatch {
+		batch := urls[start:min(start+cloudflarePurgeBatch, len(urls))]
+		body, err := json.Marshal(map[string][]string{"files": batch})
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+p.token)
+		req.Header.Set("Content-Type", "application/json")
+		if err := doPurge(p.client, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fastlyPurger purges single URLs through the Fastly API
+type fastlyPurger struct {
+	client  *http.Client
+	token   string
+	baseURL string
+}
+
+func (p *fastlyPurger) Purge(ctx context.Context, urls []string) error {
+	for _, u := range urls {
+		// The API takes the URL without its scheme
+		target := u[strings.Index(u, "://")+len("://"):]
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+target, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Fastly-Key", p.token)
+		if err := doPurge(p.client, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// doPurge sends a purge request, failing on any answer but a 2xx
+func doPurge(client *http.Client, req *http.Request) error {
+	SetRequestIDHeader(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("purge answered %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// CDNPurgeNotifier purges the public URLs showing an event when it changes: its page
+// and the ICS feed of its calendar. Purges run in the background once the write is
+// committed; a failed purge is logged and the URL expires with its max-age.
+type CDNPurgeNotifier struct {
+	purger    CachePurger
+	events    EventRepositoryInterface
+	calendars CalendarRepositoryInterface
+	signer    *FeedSigner
+	publicURL string
+	pages     bool
+	// deleting holds the calendars of the events being deleted, read before they go
+	deleting sync.Map
+}
+
+// NewCDNPurgeNotifier purges the URLs under publicURL. Event pages are purged when pages
+// is set, and feeds when signer and calendars are given.
+func NewCDNPurgeNotifier(purger CachePurger, events EventRepositoryInterface, calendars CalendarRepositoryInterface, signer *FeedSigner, publicURL string, pages bool) *CDNPurgeNotifier {
+	return &CDNPurgeNotifier{purger: purger, events: events, calendars: calendars, signer: signer,
+		publicURL: strings.TrimSuffix(publicURL, "/"), pages: pages}
+}
+
+// Register purges after every create, update and delete
+func (n *CDNPurgeNotifier) Register(hooks *EventHooks) {
+	hooks.AfterCreate(func(ctx context.Context, event EventDB) {
+		n.purge(ctx, event.ID, event.CalendarID)
+	})
+	hooks.AfterChange(func(ctx context.Context, before *EventDB, after EventDB) {
+		n.purge(ctx, after.ID, after.CalendarID)
+		// An event moved to another calendar leaves the previous calendar's feed
+		if before != nil && before.CalendarID != nil && !sameCalendar(before.CalendarID, after.CalendarID) {
+			n.purge(ctx, uuid.Nil, before.CalendarID)
+		}
+	})
+	hooks.BeforeDelete(func(ctx context.Context, id uuid.UUID) error {
+		if event, err := n.events.GetEventByID(ctx, id); err == nil {
+			n.deleting.Store(id, event.CalendarID)
+		}
+		return nil
+	})
+	hooks.AfterDelete(func(ctx context.Context, id uuid.UUID) {
+		calendarID, _ := n.deleting.LoadAndDelete(id)
+		cid, _ := calendarID.(*uuid.UUID)
+		n.purge(ctx, id, cid)
+	})
+}
+
+// URLs returns the public URLs showing event id of calendarID; a nil id leaves out the
+// event's pages
+func (n *CDNPurgeNotifier) URLs(ctx context.Context, id uuid.UUID, calendarID *uuid.UUID) []string {
+	var urls []string
+	if n.pages && id != uuid.Nil {
+		urls = append(urls, n.publicURL+"/e/"+id.String(), n.publicURL+"/e/"+ShortID(id))
+	}
+	if n.signer != nil && n.calendars != nil && calendarID != nil {
+		c, err := n.calendars.GetCalendar(ctx, *calendarID)
+		switch {
+		case err == nil:
+			urls = append(urls, n.publicURL+n.signer.FeedPath(*c))
+		case !errors.Is(err, ErrCalendarNotFound):
+			log.Printf("Error reading calendar %s to purge its feed: %v", calendarID, err)
+		}
+	}
+	return urls
+}
+
+// purge purges the URLs of an event once the write is committed
+func (n *CDNPurgeNotifier) purge(ctx context.Context, id uuid.UUID, calendarID *uuid.UUID) {
+	urls := n.URLs(ctx, id, calendarID)
+	if len(urls) == 0 {
+		return
+	}
+	AfterCommit(ctx, func() {
+		purgeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cdnPurgeTimeout)
+		go func() {
+			defer cancel()
+			if err := n.purger.Purge(purgeCtx, urls); err != nil {
+				log.Printf("Error purging %d CDN URLs of event %s: %v", len(urls), id, err)
+			}
+		}()
+	})
+}
@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// knownCalendars serves GetCalendar from a fixed set; other methods are not implemented
+type knownCalendars struct {
+	CalendarRepositoryInterface
+	byID map[uuid.UUID]Calendar
+}
+
+func (k *knownCalendars) GetCalendar(ctx context.Context, id uuid.UUID) (*Calendar, error) {
+	c, ok := k.byID[id]
+	if !ok {
+		return nil, ErrCalendarNotFound
+	}
+	return &c, nil
+}
+
+// recordedPurges collects the URLs purged
+type recordedPurges chan []string
+
+func (p recordedPurges) Purge(ctx context.Context, urls []string) error {
+	p <- urls
+	return nil
+}
+
+func TestCDNPurgeNotifierURLs(t *testing.T) {
+	calendar := Calendar{ID: uuid.New()}
+	signer := NewFeedSigner("feed-key")
+	n := NewCDNPurgeNotifier(nil, nil, &knownCalendars{byID: map[uuid.UUID]Calendar{calendar.ID: calendar}}, signer, "https://cal.example.com/", true)
+
+	id := uuid.New()
+	assert.Equal(t, []string{
+		"https://cal.example.com/e/" + id.String(),
+		"https://cal.example.com/e/" + ShortID(id),
+		"https://cal.example.com" + signer.FeedPath(calendar),
+	}, n.URLs(context.Background(), id, &calendar.ID))
+
+	missing := uuid.New()
+	assert.Len(t, n.URLs(context.Background(), id, &missing), 2)
+	assert.Len(t, n.URLs(context.Background(), id, nil), 2)
+}
+
+func TestCDNPurgeNotifierPurgesAfterWrites(t *testing.T) {
+	purges := make(recordedPurges, 1)
+	hooks := NewEventHooks()
+	NewCDNPurgeNotifier(purges, nil, nil, nil, "https://cal.example.com", true).Register(hooks)
+
+	event := EventDB{ID: uuid.New()}
+	runAfterHooks(context.Background(), hooks.afterCreate, event)
+	select {
+	case urls := <-purges:
+		assert.Contains(t, urls, "https://cal.example.com/e/"+event.ID.String())
+	case <-time.After(time.Second):
+		t.Fatal("no purge after create")
+	}
+}
+
+func TestAfterCommitWaitsForTheTransaction(t *testing.T) {
+	ran := false
+	AfterCommit(context.Background(), func() { ran = true })
+	assert.True(t, ran, "without a transaction it runs right away")
+
+	callbacks := &afterCommit{}
+	ctx := context.WithValue(context.Background(), afterCommitKey{}, callbacks)
+	ran = false
+	AfterCommit(ctx, func() { ran = true })
+	assert.False(t, ran)
+	require.Len(t, callbacks.fns, 1)
+}
+
+func TestCloudflarePurgerBatches(t *testing.T) {
+	var batches [][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer cf-token", r.Header.Get("Authorization"))
+		var body struct{ Files []string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		batches = append(batches, body.Files)
+		w.Write([]byte(`{"success":true}`))
+	}))
+	defer srv.Close()
+	p := &cloudflarePurger{client: srv.Client(), token: "cf-token", endpoint: srv.URL}
+
+	urls := make([]string, 45)
+	for i := range urls {
+		urls[i] = "https://cal.example.com/e/" + uuid.NewString()
+	}
+	require.NoError(t, p.Purge(context.Background(), urls))
+	require.Len(t, batches, 2)
+	assert.Len(t, batches[0], cloudflarePurgeBatch)
+	assert.Len(t, batches[1], 15)
+}
+
+func TestFastlyPurgerFailure(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		http.Error(w, "bad key", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	p := &fastlyPurger{client: srv.Client(), token: "key", baseURL: srv.URL + "/purge/"}
+
+	err := p.Purge(context.Background(), []string{"https://cal.example.com/e/abc"})
+	assert.ErrorContains(t, err, "401")
+	assert.Equal(t, []string{"/purge/cal.example.com/e/abc"}, paths)
+}
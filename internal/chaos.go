@@ -0,0 +1,419 @@
+package internal
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of faults chaos rules inject
+const (
+	FaultLatency = "latency"
+	FaultError   = "error"
+	FaultDrop    = "drop"
+)
+
+// chaosRepoPrefix marks the targets of rules applying to event repository methods
+const chaosRepoPrefix = "repo:"
+
+// chaosExempt are the routes the catch-all rule spares, so probes keep passing and
+// failures can still be watched in the metrics
+var chaosExempt = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// ChaosFault is one fault of a rule, injected into Percent percent of the calls
+type ChaosFault struct {
+	Kind    string
+	Percent float64
+	// MinLatency and MaxLatency bound the latency added, drawn uniformly between them
+	MinLatency time.Duration
+	MaxLatency time.Duration
+	// Status is the HTTP status of errors injected into requests, Err the error
+	// injected into repository calls
+	Status int
+	Err    error
+	// Arg is the argument the fault was configured with
+	Arg string
+}
+
+// String describes the fault as configured, without its percentage
+func (f ChaosFault) String() string {
+	if f.Arg == "" {
+		return f.Kind
+	}
+	return f.Kind + ":" + f.Arg
+}
+
+// ChaosRule injects faults into the requests of a route, or into the calls of an event
+// repository method
+type ChaosRule struct {
+	// Method is the HTTP method of the requests, or "" for all
+	Method string
+	// Target is a route template, or repo:<method> for repository calls; "*" and
+	// "repo:*" match every route and every method
+	Target string
+	Faults []ChaosFault
+}
+
+// ParseChaosRules parses rules separated by semicolons, each a target and its faults:
+//
+//	GET /events/{id}=latency:100ms-2s@50%,error:503@10%;repo:GetEvents=error:timeout@5%;*=drop@1%
+//
+// Faults are latency:<duration>[-<duration>], error:<status> for routes or
+// error:<unavailable|timeout|conflict|error> for repository methods, and drop, which
+// closes the connection of requests and fails repository calls with a broken
+// connection. Each applies to the percentage after @, or to every call without one.
+func ParseChaosRules(spec string) ([]ChaosRule, error) {
+	var rules []ChaosRule
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		target, faults, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("chaos rule %q: expected <target>=<faults>", entry)
+		}
+		rule := ChaosRule{Target: strings.TrimSpace(target)}
+		if method, route, ok := strings.Cut(rule.Target, " "); ok {
+			rule.Method, rule.Target = strings.ToUpper(method), strings.TrimSpace(route)
+		}
+		if rule.Target == "" {
+			return nil, fmt.Errorf("chaos rule %q: missing target", entry)
+		}
+		repo := strings.HasPrefix(rule.Target, chaosRepoPrefix)
+		if repo && rule.Method != "" {
+			return nil, fmt.Errorf("chaos rule %q: repository rules take no HTTP method", entry)
+		}
+		for _, f := range strings.Split(faults, ",") {
+			fault, err := parseChaosFault(strings.TrimSpace(f), repo)
+			if err != nil {
+				return nil, fmt.Errorf("chaos rule %q: %w", entry, err)
+			}
+			rule.Faults = append(rule.Faults, fault)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseChaosFault(spec string, repo bool) (ChaosFault, error) {
+	fault := ChaosFault{Percent: 100}
+	if rest, pct, ok := strings.Cut(spec, "@"); ok {
+		p, err := strconv.ParseFloat(strings.TrimSuffix(pct, "%"), 64)
+		if err != nil || p <= 0 || p > 100 {
+			return fault, fmt.Errorf("fault %q: the percentage must be above 0 and at most 100", spec)
+		}
+		fault.Percent, spec = p, rest
+	}
+	kind, arg, _ := strings.Cut(spec, ":")
+	fault.Kind, fault.Arg = kind, arg
+	switch kind {
+	case FaultLatency:
+		lo, hi, ranged := strings.Cut(arg, "-")
+		min, err := time.ParseDuration(lo)
+		if err != nil || min <= 0 {
+			return fault, fmt.Errorf("fault %q: invalid latency", spec)
+		}
+		max := min
+		if ranged {
+			if max, err = time.ParseDuration(hi); err != nil || max < min {
+				return fault, fmt.Errorf("fault %q: invalid latency range", spec)
+			}
+		}
+		fault.MinLatency, fault.MaxLatency = min, max
+	case FaultError:
+		if repo {
+			kinds := map[string]error{"unavailable": ErrUnavailable, "timeout": ErrTimeout, "conflict": ErrConflict, "error": errChaos}
+			if fault.Err = kinds[arg]; fault.Err == nil {
+				return fault, fmt.Errorf("fault %q: the error must be unavailable, timeout, conflict or error", spec)
+			}
+			break
+		}
+		status, err := strconv.Atoi(arg)
+		if err != nil || status < 400 || status > 599 {
+			return fault, fmt.Errorf("fault %q: the error must be an HTTP status from 400 to 599", spec)
+		}
+		fault.Status = status
+	case FaultDrop:
+		if arg != "" {
+			return fault, fmt.Errorf("fault %q: drop takes no argument", spec)
+		}
+	default:
+		return fault, fmt.Errorf("fault %q: unknown kind %q (available: %s, %s, %s)", spec, kind, FaultLatency, FaultError, FaultDrop)
+	}
+	return fault, nil
+}
+
+// errChaos is the unexpected error injected by error:error faults
+var errChaos = errors.New("unexpected error")
+
+// Chaos injects faults into requests and repository calls, to test how clients and
+// retry logic cope with slow, failing and vanishing servers. It is meant for
+// development: main enables it only in debug environments.
+type Chaos struct {
+	rules []ChaosRule
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewChaos injects the faults of cfg.ChaosRules, drawing from a generator seeded with
+// cfg.ChaosSeed when set, so runs can be replayed. It returns nil without rules.
+func NewChaos(cfg Config) (*Chaos, error) {
+	rules, err := ParseChaosRules(cfg.ChaosRules)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+	seed := cfg.ChaosSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Chaos{rules: rules, rand: rand.New(rand.NewSource(seed))}, nil
+}
+
+// Decide returns the faults to inject into a request of method to route: the latency
+// to add, and the error or drop to inject after it, if any
+func (c *Chaos) Decide(method, route string) (time.Duration, *ChaosFault) {
+	for _, rule := range c.rules {
+		if rule.Method != "" && rule.Method != method {
+			continue
+		}
+		if rule.Target == route || (rule.Target == "*" && !chaosExempt[route] && !strings.HasPrefix(route, chaosRepoPrefix)) || (rule.Target == chaosRepoPrefix+"*" && strings.HasPrefix(route, chaosRepoPrefix)) {
+			return c.roll(rule.Faults)
+		}
+	}
+	return 0, nil
+}
+
+// roll draws which faults of a rule apply
+func (c *Chaos) roll(faults []ChaosFault) (time.Duration, *ChaosFault) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var latency time.Duration
+	var failure *ChaosFault
+	for i, f := range faults {
+		if c.rand.Float64()*100 >= f.Percent {
+			continue
+		}
+		switch {
+		case f.Kind == FaultLatency:
+			latency += f.MinLatency
+			if f.MaxLatency > f.MinLatency {
+				latency += time.Duration(c.rand.Int63n(int64(f.MaxLatency - f.MinLatency)))
+			}
+		case failure == nil:
+			failure = &faults[i]
+		}
+	}
+	return latency, failure
+}
+
+// ChaosSleep waits for d, or until ctx is done
+func ChaosSleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ChaosEventRepository injects the faults of the repo: rules into the calls of an
+// event repository
+type ChaosEventRepository struct {
+	inner EventRepositoryInterface
+	chaos *Chaos
+}
+
+// NewChaosEventRepository wraps inner with the faults of chaos
+func NewChaosEventRepository(inner EventRepositoryInterface, chaos *Chaos) *ChaosEventRepository {
+	return &ChaosEventRepository{inner: inner, chaos: chaos}
+}
+
+// inject delays the call to method and returns the error it should fail with, if any
+func (r *ChaosEventRepository) inject(ctx context.Context, method string) error {
+	latency, fault := r.chaos.Decide("", chaosRepoPrefix+method)
+	if err := ChaosSleep(ctx, latency); err != nil {
+		return err
+	}
+	switch {
+	case fault == nil:
+		return nil
+	case fault.Kind == FaultDrop:
+		return fmt.Errorf("chaos: connection dropped: %w", driver.ErrBadConn)
+	}
+	return fmt.Errorf("chaos: injected failure: %w", fault.Err)
+}
+
+func (r *ChaosEventRepository) CreateEvent(ctx context.Context, event EventDB) (*EventDB, error) {
+	if err := r.inject(ctx, "CreateEvent"); err != nil {
+		return nil, err
+	}
+	return r.inner.CreateEvent(ctx, event)
+}
+
+func (r *ChaosEventRepository) GetEvents(ctx context.Context) ([]EventDB, error) {
+	if err := r.inject(ctx, "GetEvents"); err != nil {
+		return nil, err
+	}
+	return r.inner.GetEvents(ctx)
+}
+
+func (r *ChaosEventRepository) GetEventByID(ctx context.Context, id uuid.UUID) (*EventDB, error) {
+	if err := r.inject(ctx, "GetEventByID"); err != nil {
+		return nil, err
+	}
+	return r.inner.GetEventByID(ctx, id)
+}
+
+func (r *ChaosEventRepository) GetEventsBetween(ctx context.Context, from, to time.Time) ([]EventDB, error) {
+	if err := r.inject(ctx, "GetEventsBetween"); err != nil {
+		return nil, err
+	}
+	return r.inner.GetEventsBetween(ctx, from, to)
+}
+
+func (r *ChaosEventRepository) GetEventsByCalendar(ctx context.Context, calendarID uuid.UUID) ([]EventDB, error) {
+	if err := r.inject(ctx, "GetEventsByCalendar"); err != nil {
+		return nil, err
+	}
+	return r.inner.GetEventsByCalendar(ctx, calendarID)
+}
+
+func (r *ChaosEventRepository) GetEventsByExternalID(ctx context.Context, source, externalID string) ([]EventDB, error) {
+	if err := r.inject(ctx, "GetEventsByExternalID"); err != nil {
+		return nil, err
+	}
+	return r.inner.GetEventsByExternalID(ctx, source, externalID)
+}
+
+func (r *ChaosEventRepository) GetEventByClientKey(ctx context.Context, calendarID *uuid.UUID, clientKey string) (*EventDB, error) {
+	if err := r.inject(ctx, "GetEventByClientKey"); err != nil {
+		return nil, err
+	}
+	return r.inner.GetEventByClientKey(ctx, calendarID, clientKey)
+}
+
+func (r *ChaosEventRepository) UpdateEvent(ctx context.Context, event EventDB) (*EventDB, error) {
+	if err := r.inject(ctx, "UpdateEvent"); err != nil {
+		return nil, err
+	}
+	return r.inner.UpdateEvent(ctx, event)
+}
+
+func (r *ChaosEventRepository) DeleteEvent(ctx context.Context, id uuid.UUID) error {
+	if err := r.inject(ctx, "DeleteEvent"); err != nil {
+		return err
+	}
+	return r.inner.DeleteEvent(ctx, id)
+}
+
+func (r *ChaosEventRepository) ImportEvents(ctx context.Context, events []EventDB) (int, error) {
+	if err := r.inject(ctx, "ImportEvents"); err != nil {
+		return 0, err
+	}
+	return r.inner.ImportEvents(ctx, events)
+}
+
+func (r *ChaosEventRepository) PullChanges(ctx context.Context, after SyncCursor, limit int) (*SyncPage, error) {
+	if err := r.inject(ctx, "PullChanges"); err != nil {
+		return nil, err
+	}
+	return r.inner.PullChanges(ctx, after, limit)
+}
+
+func (r *ChaosEventRepository) ApplySyncChange(ctx context.Context, c SyncChange) (*SyncOutcome, error) {
+	if err := r.inject(ctx, "ApplySyncChange"); err != nil {
+		return nil, err
+	}
+	return r.inner.ApplySyncChange(ctx, c)
+}
+
+func (r *ChaosEventRepository) ListPendingEvents(ctx context.Context, limit int) ([]EventDB, error) {
+	if err := r.inject(ctx, "ListPendingEvents"); err != nil {
+		return nil, err
+	}
+	return r.inner.ListPendingEvents(ctx, limit)
+}
+
+func (r *ChaosEventRepository) ReviewEvent(ctx context.Context, review EventReview) (*EventDB, error) {
+	if err := r.inject(ctx, "ReviewEvent"); err != nil {
+		return nil, err
+	}
+	return r.inner.ReviewEvent(ctx, review)
+}
+
+func (r *ChaosEventRepository) ListEventReviews(ctx context.Context, eventID uuid.UUID) ([]EventReview, error) {
+	if err := r.inject(ctx, "ListEventReviews"); err != nil {
+		return nil, err
+	}
+	return r.inner.ListEventReviews(ctx, eventID)
+}
+
+func (r *ChaosEventRepository) EventStats(ctx context.Context, q EventStatsQuery) ([]EventStatsRow, error) {
+	if err := r.inject(ctx, "EventStats"); err != nil {
+		return nil, err
+	}
+	return r.inner.EventStats(ctx, q)
+}
+
+func (r *ChaosEventRepository) SuggestTitles(ctx context.Context, q SuggestQuery) ([]TitleSuggestion, error) {
+	if err := r.inject(ctx, "SuggestTitles"); err != nil {
+		return nil, err
+	}
+	return r.inner.SuggestTitles(ctx, q)
+}
+
+func (r *ChaosEventRepository) Occupancy(ctx context.Context, q HeatmapQuery) ([]HeatmapBucket, error) {
+	if err := r.inject(ctx, "Occupancy"); err != nil {
+		return nil, err
+	}
+	return r.inner.Occupancy(ctx, q)
+}
+
+func (r *ChaosEventRepository) EventsVersion(ctx context.Context, source, externalID string) (*EventsVersion, error) {
+	if err := r.inject(ctx, "EventsVersion"); err != nil {
+		return nil, err
+	}
+	return VersionEvents(ctx, r.inner, source, externalID)
+}
+
+func (r *ChaosEventRepository) FilterEvents(ctx context.Context, f *EventFilter) ([]EventDB, error) {
+	if err := r.inject(ctx, "FilterEvents"); err != nil {
+		return nil, err
+	}
+	return FilterEvents(ctx, r.inner, f)
+}
+
+func (r *ChaosEventRepository) GetEventSummaries(ctx context.Context, f *EventFilter) ([]EventSummary, error) {
+	if err := r.inject(ctx, "GetEventSummaries"); err != nil {
+		return nil, err
+	}
+	return SummarizeEvents(ctx, r.inner, f)
+}
+
+func (r *ChaosEventRepository) SearchEvents(ctx context.Context, q SearchQuery) ([]EventSummary, error) {
+	if err := r.inject(ctx, "SearchEvents"); err != nil {
+		return nil, err
+	}
+	return SearchEvents(ctx, r.inner, q)
+}
+
+// Ping checks the wrapped repository's database, without faults, so readiness reflects
+// the real database
+func (r *ChaosEventRepository) Ping(ctx context.Context) error {
+	return pingRepository(ctx, r.inner)
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChaosRules(t *testing.T) {
+	rules, err := ParseChaosRules("GET /events/{id}=latency:100ms-2s@50%, error:503@10% ; repo:GetEvents=error:timeout@5%;*=drop@1%")
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+	assert.Equal(t, "GET", rules[0].Method)
+	assert.Equal(t, "/events/{id}", rules[0].Target)
+	assert.Equal(t, ChaosFault{Kind: FaultLatency, Percent: 50, MinLatency: 100 * time.Millisecond, MaxLatency: 2 * time.Second, Arg: "100ms-2s"}, rules[0].Faults[0])
+	assert.Equal(t, ChaosFault{Kind: FaultError, Percent: 10, Status: 503, Arg: "503"}, rules[0].Faults[1])
+	assert.Equal(t, ErrTimeout, rules[1].Faults[0].Err)
+	assert.Equal(t, ChaosFault{Kind: FaultDrop, Percent: 1}, rules[2].Faults[0])
+
+	for _, spec := range []string{
+		"/events",
+		"=drop",
+		"/events=latency:fast",
+		"/events=latency:2s-1s",
+		"/events=error:200",
+		"repo:GetEvents=error:503",
+		"GET repo:GetEvents=drop",
+		"/events=drop@0%",
+		"/events=drop@150%",
+		"/events=explode",
+	} {
+		_, err := ParseChaosRules(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestChaosDecide(t *testing.T) {
+	chaos, err := NewChaos(Config{ChaosRules: "POST /events=error:500;repo:*=latency:10ms;*=drop", ChaosSeed: 1})
+	require.NoError(t, err)
+
+	_, fault := chaos.Decide("POST", "/events")
+	require.NotNil(t, fault)
+	assert.Equal(t, 500, fault.Status, "the first matching rule applies")
+	_, fault = chaos.Decide("GET", "/events")
+	assert.Equal(t, FaultDrop, fault.Kind)
+	latency, fault := chaos.Decide("", "repo:GetEvents")
+	assert.Equal(t, 10*time.Millisecond, latency)
+	assert.Nil(t, fault)
+	_, fault = chaos.Decide("GET", "/healthz")
+	assert.Nil(t, fault, "probes are spared by the catch-all rule")
+
+	chaos, err = NewChaos(Config{})
+	require.NoError(t, err)
+	assert.Nil(t, chaos)
+}
+
+func TestChaosEventRepository(t *testing.T) {
+	chaos, err := NewChaos(Config{ChaosRules: "repo:GetEvents=error:unavailable;repo:DeleteEvent=drop;repo:GetEventsBetween=latency:1h"})
+	require.NoError(t, err)
+	// Every call fails before reaching the wrapped repository
+	repo := NewChaosEventRepository(nil, chaos)
+
+	_, err = repo.GetEvents(context.Background())
+	assert.True(t, errors.Is(err, ErrUnavailable))
+	assert.Equal(t, ResultUnavailable, ClassifyError(repo.DeleteEvent(context.Background(), uuid.New())))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = repo.GetEventsBetween(ctx, time.Now(), time.Now())
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "latency ends with the caller's deadline")
+}
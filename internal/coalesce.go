@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// coalesceTimeout bounds a shared read, which outlives the request that started it
+// when that request goes away while others still wait for the result
+const coalesceTimeout = 10 * time.Second
+
+// flight is a read in progress, whose result every caller asking for it shares
+type flight struct {
+	done chan struct{}
+	val  any
+	err  error
+	// waiters counts the callers sharing the read, guarded by the repository's mutex
+	waiters int
+}
+
+// CoalescedEventRepository shares expensive reads among concurrent identical calls: while
+// a listing, a heatmap or the stats of a period is being read, callers asking for the
+// same one wait for that read instead of starting their own, so a burst of identical
+// requests costs the database one query.
+type CoalescedEventRepository struct {
+	EventRepositoryInterface
+	metrics *Metrics
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// NewCoalescedEventRepository wraps repo; calls served from another's read are
+// recorded in metrics with the result "coalesced"
+func NewCoalescedEventRepository(repo EventRepositoryInterface, metrics *Metrics) *CoalescedEventRepository {
+	return &CoalescedEventRepository{EventRepositoryInterface: repo, metrics: metrics, flights: map[string]*flight{}}
+}
+
+// coalesce returns the result of read for key, running it unless a call with the same
+// key is in progress. Reads inside a transaction, or that must see a write, depend on
+// their request and always run on their own.
+func coalesce[T any](r *CoalescedEventRepository, ctx context.Context, method, key string, read func(ctx context.Context) (T, error)) (T, error) {
+	if !coalescable(ctx) {
+		return read(ctx)
+	}
+	key = method + "|" + key
+
+	r.mu.Lock()
+	if f, ok := r.flights[key]; ok {
+		f.waiters++
+		r.mu.Unlock()
+		start := time.Now()
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+		if r.metrics != nil {
+			r.metrics.ObserveRepositoryCall(ctx, method, "coalesced", time.Since(start))
+		}
+		val, _ := f.val.(T)
+		return val, f.err
+	}
+	f := &flight{done: make(chan struct{})}
+	r.flights[key] = f
+	r.mu.Unlock()
+
+	// Waiters see an error rather than hang should the read panic
+	f.err = errors.New("shared read failed")
+	defer func() {
+		r.mu.Lock()
+		delete(r.flights, key)
+		r.mu.Unlock()
+		close(f.done)
+	}()
+
+	// The read keeps the values of the first caller's context, such as its request ID,
+	// but not its cancellation
+	readCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), coalesceTimeout)
+	defer cancel()
+	val, err := read(readCtx)
+	f.val, f.err = val, err
+	return val, err
+}
+
+// coalescable reports whether a read made with ctx may be shared with other callers
+func coalescable(ctx context.Context) bool {
+	if _, inTx := ctx.Value(txKey{}).(*sql.Tx); inTx {
+		return false
+	}
+	if c := ConsistencyFromContext(ctx); c != nil && (c.Token != "" || c.hasWritten()) {
+		return false
+	}
+	return true
+}
+
+// optionalID formats a nullable ID for coalescing keys
+func optionalID(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+// GetEvents shares the full listing. Like the other shared lists it is cloned for every
+// caller, which may reorder or filter it.
+func (r *CoalescedEventRepository) GetEvents(ctx context.Context) ([]EventDB, error) {
+	events, err := coalesce(r, ctx, "GetEvents", "", r.EventRepositoryInterface.GetEvents)
+	return slices.Clone(events), err
+}
+
+func (r *CoalescedEventRepository) GetEventsBetween(ctx context.Context, from, to time.Time) ([]EventDB, error) {
+	key := from.UTC().Format(time.RFC3339Nano) + "|" + to.UTC().Format(time.RFC3339Nano)
+	events, err := coalesce(r, ctx, "GetEventsBetween", key, func(ctx context.Context) ([]EventDB, error) {
+		return r.EventRepositoryInterface.GetEventsBetween(ctx, from, to)
+	})
+	return slices.Clone(events), err
+}
+
+func (r *CoalescedEventRepository) Occupancy(ctx context.Context, q HeatmapQuery) ([]HeatmapBucket, error) {
+	key := fmt.Sprintf("%s|%s|%s|%s|%s", q.From.UTC().Format(time.RFC3339Nano), q.To.UTC().Format(time.RFC3339Nano), q.Bucket, q.Location, optionalID(q.CalendarID))
+	buckets, err := coalesce(r, ctx, "Occupancy", key, func(ctx context.Context) ([]HeatmapBucket, error) {
+		return r.EventRepositoryInterface.Occupancy(ctx, q)
+	})
+	return slices.Clone(buckets), err
+}
+
+func (r *CoalescedEventRepository) EventStats(ctx context.Context, q EventStatsQuery) ([]EventStatsRow, error) {
+	key := fmt.Sprintf("%s|%s|%s|%s", q.From.UTC().Format(time.RFC3339Nano), q.To.UTC().Format(time.RFC3339Nano), q.Group, optionalID(q.CalendarID))
+	rows, err := coalesce(r, ctx, "EventStats", key, func(ctx context.Context) ([]EventStatsRow, error) {
+		return r.EventRepositoryInterface.EventStats(ctx, q)
+	})
+	return slices.Clone(rows), err
+}
+
+// EventsVersion shares the version of a listing, which polling clients ask for often
+func (r *CoalescedEventRepository) EventsVersion(ctx context.Context, source, externalID string) (*EventsVersion, error) {
+	return coalesce(r, ctx, "EventsVersion", source+"|"+externalID, func(ctx context.Context) (*EventsVersion, error) {
+		return VersionEvents(ctx, r.EventRepositoryInterface, source, externalID)
+	})
+}
+
+// FilterEvents shares filtered listings, keyed by their expression
+func (r *CoalescedEventRepository) FilterEvents(ctx context.Context, f *EventFilter) ([]EventDB, error) {
+	events, err := coalesce(r, ctx, "FilterEvents", f.String(), func(ctx context.Context) ([]EventDB, error) {
+		return FilterEvents(ctx, r.EventRepositoryInterface, f)
+	})
+	return slices.Clone(events), err
+}
+
+// GetEventSummaries shares the summaries grids poll for, keyed by their filter
+func (r *CoalescedEventRepository) GetEventSummaries(ctx context.Context, f *EventFilter) ([]EventSummary, error) {
+	key := ""
+	if f != nil {
+		key = f.String()
+	}
+	summaries, err := coalesce(r, ctx, "GetEventSummaries", key, func(ctx context.Context) ([]EventSummary, error) {
+		return SummarizeEvents(ctx, r.EventRepositoryInterface, f)
+	})
+	return slices.Clone(summaries), err
+}
+
+// SearchEvents searches the wrapped repository when it supports it. Searches follow
+// what users type and rarely repeat at once, so they are not shared.
+func (r *CoalescedEventRepository) SearchEvents(ctx context.Context, q SearchQuery) ([]EventSummary, error) {
+	return SearchEvents(ctx, r.EventRepositoryInterface, q)
+}
+
+// Ping checks the wrapped repository's database when it supports it
+func (r *CoalescedEventRepository) Ping(ctx context.Context) error {
+	return pingRepository(ctx, r.EventRepositoryInterface)
+}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowListing blocks GetEvents until release is closed and counts its calls
+type slowListing struct {
+	EventRepositoryInterface
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *slowListing) GetEvents(ctx context.Context) ([]EventDB, error) {
+	if s.calls.Add(1) == 1 {
+		close(s.started)
+	}
+	<-s.release
+	return []EventDB{{Title: "Standup"}}, nil
+}
+
+func TestCoalescedEventRepository(t *testing.T) {
+	inner := &slowListing{started: make(chan struct{}), release: make(chan struct{})}
+	metrics := NewMetrics()
+	repo := NewCoalescedEventRepository(inner, metrics)
+
+	const callers = 5
+	results := make([][]EventDB, callers)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _ = repo.GetEvents(context.Background())
+	}()
+	<-inner.started
+	for i := 1; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = repo.GetEvents(context.Background())
+		}(i)
+	}
+	// Wait for the others to join the read in progress
+	require.Eventually(t, func() bool {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+		f := repo.flights["GetEvents|"]
+		return f != nil && f.waiters == callers-1
+	}, time.Second, time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), inner.calls.Load())
+	for _, events := range results {
+		require.Len(t, events, 1)
+		assert.Equal(t, "Standup", events[0].Title)
+	}
+	// Every caller gets its own slice
+	results[1][0].Title = "Changed"
+	assert.Equal(t, "Standup", results[2][0].Title)
+
+	var buf bytes.Buffer
+	require.NoError(t, metrics.WritePrometheus(&buf))
+	assert.Contains(t, buf.String(), `repository_calls_total{method="GetEvents",result="coalesced"} 4`)
+
+	// Reads that must see a write run on their own
+	ctx, _ := WithConsistency(context.Background(), "0/10")
+	_, err := repo.GetEvents(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), inner.calls.Load())
+}
@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// maxMentions bounds the users notified by one comment
+const maxMentions = 20
+
+// ErrCommentNotFound is returned when a comment does not exist on the event
+var ErrCommentNotFound = newDomainError(ErrNotFound, "comment not found")
+
+// EventComment is a message in an event's discussion thread
+type EventComment struct {
+	ID      uuid.UUID `json:"id"`
+	EventID uuid.UUID `json:"event_id"`
+	Author  string    `json:"author"`
+	Body    string    `json:"body"`
+	// Mentions are the user IDs written as @user in the body
+	Mentions  []string  `json:"mentions"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CommentPage is one page of a thread, oldest first
+type CommentPage struct {
+	Comments []EventComment
+	Next     TimeCursor
+	HasMore  bool
+}
+
+// mentionPattern matches @user where the @ starts a word, so email addresses in a
+// comment are not mentions
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@([\w][\w.-]{0,63})`)
+
+// ParseMentions returns the distinct users mentioned in body, in order of appearance.
+// Trailing dots are punctuation, not part of the user ID.
+func ParseMentions(body string) []string {
+	mentions := []string{}
+	seen := map[string]bool{}
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		user := strings.TrimRight(m[1], ".")
+		if user == "" || seen[user] {
+			continue
+		}
+		seen[user] = true
+		mentions = append(mentions, user)
+		if len(mentions) == maxMentions {
+			break
+		}
+	}
+	return mentions
+}
+
+type CommentRepository struct {
+	db *sql.DB
+}
+
+// NewCommentRepository creates a new event comment repository
+func NewCommentRepository(db *sql.DB) *CommentRepository {
+	return &CommentRepository{db: db}
+}
+
+const commentColumns = `id, event_id, author, body, mentions, created_at`
+
+func scanComment(row rowScanner, c *EventComment) error {
+	return row.Scan(&c.ID, &c.EventID, &c.Author, &c.Body, pq.Array(&c.Mentions), &c.CreatedAt)
+}
+
+// CreateComment adds a comment to an event's thread
+func (r *CommentRepository) CreateComment(ctx context.Context, c EventComment) (*EventComment, error) {
+	query := `
+		INSERT INTO event_comments (id, event_id, author, body, mentions)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + commentColumns
+
+	var created EventComment
+	if err := scanComment(traced(ctx, r.db).QueryRowContext(ctx, query, c.ID, c.EventID, c.Author, c.Body, pq.Array(c.Mentions)), &created); err != nil {
+		if isForeignKeyViolation(err, "event_comments_event_id_fkey") {
+			return nil, ErrEventNotFound
+		}
+		return nil, fmt.Errorf("failed to create comment: %w", err)
+	}
+	return &created, nil
+}
+
+// ListComments returns up to limit comments of an event after the cursor, oldest first
+func (r *CommentRepository) ListComments(ctx context.Context, eventID uuid.UUID, after TimeCursor, limit int) (*CommentPage, error) {
+	query := `
+		SELECT ` + commentColumns + ` FROM event_comments
+		WHERE event_id = $1 AND (created_at, id) > ($2, $3)
+		ORDER BY created_at, id
+		LIMIT $4`
+	rows, err := traced(ctx, r.db).QueryContext(ctx, query, eventID, after.At, after.ID, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comments: %w", err)
+	}
+	defer rows.Close()
+
+	page := &CommentPage{Comments: []EventComment{}, Next: after}
+	for rows.Next() {
+		var c EventComment
+		if err := scanComment(rows, &c); err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		page.Comments = append(page.Comments, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating comments: %w", err)
+	}
+
+	if len(page.Comments) > limit {
+		page.Comments, page.HasMore = page.Comments[:limit], true
+	}
+	if n := len(page.Comments); n > 0 {
+		last := page.Comments[n-1]
+		page.Next = TimeCursor{At: last.CreatedAt, ID: last.ID}
+	}
+	return page, nil
+}
+
+// GetComment retrieves a comment of an event
+func (r *CommentRepository) GetComment(ctx context.Context, eventID, id uuid.UUID) (*EventComment, error) {
+	var c EventComment
+	query := `SELECT ` + commentColumns + ` FROM event_comments WHERE event_id = $1 AND id = $2`
+	if err := scanComment(traced(ctx, r.db).QueryRowContext(ctx, query, eventID, id), &c); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCommentNotFound
+		}
+		return nil, fmt.Errorf("failed to get comment: %w", err)
+	}
+	return &c, nil
+}
+
+// DeleteComment removes a comment of an event
+func (r *CommentRepository) DeleteComment(ctx context.Context, eventID, id uuid.UUID) error {
+	res, err := traced(ctx, r.db).ExecContext(ctx, `DELETE FROM event_comments WHERE event_id = $1 AND id = $2`, eventID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrCommentNotFound
+	}
+	return nil
+}
+
+// MentionNotifier tells users they were mentioned in a comment. Addresses come from
+// the users' digest subscriptions, the only email addresses the service stores, so
+// users without one are skipped.
+type MentionNotifier struct {
+	notifier Notifier
+	digests  DigestRepositoryInterface
+}
+
+// NewMentionNotifier returns nil, which sends nothing, when either dependency is missing
+func NewMentionNotifier(notifier Notifier, digests DigestRepositoryInterface) *MentionNotifier {
+	if notifier == nil || digests == nil {
+		return nil
+	}
+	return &MentionNotifier{notifier: notifier, digests: digests}
+}
+
+// NotifyMentions notifies every user mentioned in comment except its author. Failures
+// are logged; one bad address does not stop the others.
+func (m *MentionNotifier) NotifyMentions(ctx context.Context, event EventDB, comment EventComment) {
+	if m == nil {
+		return
+	}
+	for _, user := range comment.Mentions {
+		if user == comment.Author {
+			continue
+		}
+		sub, err := m.digests.GetDigestSubscription(ctx, user)
+		if errors.Is(err, ErrDigestSubscriptionNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Error looking up the address of %s for a mention: %v", user, err)
+			continue
+		}
+
+		lang := sub.Language
+		author := comment.Author
+		if author == "" {
+			author = Translate(lang, "Someone")
+		}
+		n := Notification{
+			To:      sub.Email,
+			Subject: Translate(lang, "%s mentioned you on %s", author, event.Title),
+			Text:    comment.Body,
+		}
+		if err := m.notifier.Notify(ctx, n); err != nil {
+			log.Printf("Error notifying %s of a mention in comment %s: %v", user, comment.ID, err)
+		}
+	}
+}
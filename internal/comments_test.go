@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{"no mentions here", []string{}},
+		{"@alice can you book the room?", []string{"alice"}},
+		{"thanks @bob.smith and @carol-1.", []string{"bob.smith", "carol-1"}},
+		{"@alice @alice (@dave)", []string{"alice", "dave"}},
+		{"mail ops@example.com instead", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseMentions(tt.body))
+		})
+	}
+}
+
+// fakeDigestRepository knows the subscriptions of a fixed set of users
+type fakeDigestRepository struct {
+	DigestRepositoryInterface
+	subs map[string]DigestSubscription
+}
+
+func (f *fakeDigestRepository) GetDigestSubscription(ctx context.Context, userID string) (*DigestSubscription, error) {
+	sub, ok := f.subs[userID]
+	if !ok {
+		return nil, ErrDigestSubscriptionNotFound
+	}
+	return &sub, nil
+}
+
+// recordingNotifier keeps the notifications it is asked to send
+type recordingNotifier struct {
+	sent []Notification
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, msg Notification) error {
+	n.sent = append(n.sent, msg)
+	return nil
+}
+
+func TestNotifyMentions(t *testing.T) {
+	notifier := &recordingNotifier{}
+	digests := &fakeDigestRepository{subs: map[string]DigestSubscription{
+		"alice": {UserID: "alice", Email: "alice@example.com", Language: "es"},
+		"bob":   {UserID: "bob", Email: "bob@example.com", Language: "en"},
+	}}
+	mentions := NewMentionNotifier(notifier, digests)
+
+	comment := EventComment{ID: uuid.New(), Author: "bob", Body: "@alice @bob @carol see the agenda", Mentions: []string{"alice", "bob", "carol"}}
+	mentions.NotifyMentions(context.Background(), EventDB{Title: "Offsite"}, comment)
+
+	// bob wrote the comment and carol has no address
+	require.Len(t, notifier.sent, 1)
+	assert.Equal(t, "alice@example.com", notifier.sent[0].To)
+	assert.Equal(t, "bob te mencionó en Offsite", notifier.sent[0].Subject)
+	assert.Equal(t, comment.Body, notifier.sent[0].Text)
+
+	assert.Nil(t, NewMentionNotifier(notifier, nil))
+	var none *MentionNotifier
+	none.NotifyMentions(context.Background(), EventDB{}, comment)
+}
@@ -3,40 +3,642 @@ package internal
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"log"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type app struct {
 	DB *sql.DB
+	// Connected is closed once the database answered, at once unless connecting lazily
+	Connected chan struct{}
 }
 
-// ConnectionDB: postgres DB connection
-func ConnectionDB() *app {
+// rotatingConnector opens each new connection with the DSN current at that moment, so a
+// rotated DATABASE_URL is picked up as old connections expire without a restart
+type rotatingConnector struct {
+	dsn func() string
+}
 
-	dsn := os.Getenv("DATABASE_URL")
-	if dsn == "" {
-		log.Fatal("Failed to get DB url")
+func (c rotatingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	connector, err := pq.NewConnector(withApplicationName(c.dsn()))
+	if err != nil {
+		return nil, err
 	}
+	return connector.Connect(ctx)
+}
 
-	db, err := sql.Open("postgres", dsn)
-	if err != nil {
-		log.Fatalf("Failed to open DB conn %v", err)
+func (c rotatingConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// ApplicationName names the server's connections in pg_stat_activity and the Postgres
+// logs, unless the DSN or PGAPPNAME sets application_name
+const ApplicationName = "taller_challenge"
+
+// withApplicationName adds application_name to dsn, a URL or key=value DSN, when it
+// does not set one already
+func withApplicationName(dsn string) string {
+	if os.Getenv("PGAPPNAME") != "" {
+		return dsn
+	}
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			// pq reports the malformed DSN
+			return dsn
+		}
+		q := u.Query()
+		if q.Get("application_name") == "" {
+			q.Set("application_name", ApplicationName)
+			u.RawQuery = q.Encode()
+		}
+		return u.String()
+	}
+	if strings.Contains(dsn, "application_name=") {
+		return dsn
+	}
+	return strings.TrimSpace(dsn + " application_name=" + ApplicationName)
+}
+
+// ConnectOptions controls how ConnectionDB waits for the database
+type ConnectOptions struct {
+	// RetryFor is how long to keep retrying the first connection, backing off between
+	// attempts, before giving up
+	RetryFor time.Duration
+	// Lazy starts without the database when it is still unreachable after RetryFor:
+	// queries and readiness fail until a background retry connects
+	Lazy bool
+}
+
+// connectBackoff is the first wait between connection attempts, doubled after each
+// failure up to maxConnectBackoff
+const (
+	connectBackoff    = 500 * time.Millisecond
+	maxConnectBackoff = 10 * time.Second
+)
+
+// ConnectionDB: postgres DB connection. dsn is called for every new connection.
+// Unless opts.Lazy is set, it exits when the database cannot be reached in opts.RetryFor.
+func ConnectionDB(dsn func() string, opts ConnectOptions) *app {
+
+	if dsn() == "" {
+		log.Fatal("Failed to get DB url")
 	}
 
+	db := sql.OpenDB(rotatingConnector{dsn: dsn})
+
 	db.SetConnMaxLifetime(5 * time.Minute)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	application := &app{DB: db, Connected: make(chan struct{})}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.RetryFor)
 	defer cancel() // close db conn
-	if err := db.PingContext(ctx); err != nil {
+	err := pingUntilUp(ctx, db)
+	if err == nil {
+		close(application.Connected)
+		log.Println("Connected to the DB....")
+		return application
+	}
+	if !opts.Lazy {
 		log.Fatalf("Failed to ping DB %v", err)
 	}
 
-	application := &app{DB: db}
-
-	log.Println("Connected to the DB....")
+	// Requests fail with 503s and /readyz reports the database until it appears
+	log.Printf("Database unavailable, starting degraded until it can be reached: %v", err)
+	go func() {
+		pingUntilUp(context.Background(), db)
+		close(application.Connected)
+		log.Println("Connected to the DB....")
+	}()
 	return application
 }
+
+// pingUntilUp pings db until it answers or ctx ends, doubling the wait between attempts
+// up to maxConnectBackoff. It returns the last ping's error when ctx ends first; the
+// first ping is always made, even when ctx is already done.
+func pingUntilUp(ctx context.Context, db *sql.DB) error {
+	wait := connectBackoff
+	for {
+		pingCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		err := db.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		log.Printf("Database not reachable yet, retrying in %s: %v", wait, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+		wait = min(2*wait, maxConnectBackoff)
+	}
+}
+
+// Config holds the runtime settings read from environment variables
+type Config struct {
+	Port string
+	// ListenAddrs are the addresses the API is served on, host:port or unix:/path for a
+	// UNIX socket; empty serves on Port alone
+	ListenAddrs []string
+	// UnixSocketMode is the permission of the UNIX sockets listened on, so a reverse
+	// proxy in the socket's group can connect
+	UnixSocketMode os.FileMode
+	// AdminListen, when set, serves /healthz, /readyz and /metrics without
+	// authentication on their own address, for probes and scrapers on a private network
+	AdminListen string
+	// ShutdownDrainDelay is how long readiness fails before listeners close on SIGTERM,
+	// giving load balancers time to stop routing new requests here
+	ShutdownDrainDelay time.Duration
+	// ShutdownTimeout bounds how long in-flight requests may take to finish
+	ShutdownTimeout time.Duration
+	// MetricsPushURL is a Prometheus Pushgateway URL for the final flush on shutdown
+	MetricsPushURL string
+	// MetricsSink is statsd or dogstatsd to push metrics to the agent at StatsDAddr as
+	// well, every name starting with StatsDPrefix; StatsDTags, such as env:prod, are sent
+	// with every DogStatsD metric
+	MetricsSink  string
+	StatsDAddr   string
+	StatsDPrefix string
+	StatsDTags   []string
+	// MetricsTenantBuckets labels request and repository metrics by tenant and calendar,
+	// hashing their IDs into this many buckets; the IDs of MetricsTenantAllowlist are
+	// labeled as themselves. Both unset leaves the labels out.
+	MetricsTenantBuckets   int
+	MetricsTenantAllowlist []string
+	// ChaosRules inject latency, errors and dropped connections into requests and event
+	// repository calls, in debug environments only (see ParseChaosRules); ChaosSeed
+	// makes the faults drawn reproducible
+	ChaosRules string
+	ChaosSeed  int64
+	// ReplayLog is the file every mutating request is appended to, for the replay
+	// command to reproduce a database; empty disables it
+	ReplayLog string
+	// TraceRepository logs every event repository call with its duration and request ID
+	TraceRepository bool
+	// LogSampleRate logs one in this many successful requests; failed ones always are
+	LogSampleRate int
+	// LogSlowRequest always logs requests taking longer; zero disables the exception
+	LogSlowRequest time.Duration
+	// Environment names the deployment (APP_ENV), such as production or development
+	Environment string
+	// LogPayloads logs event descriptions, notification texts and other user content
+	// instead of their length. It is honored only in debug environments; credentials
+	// and email addresses are redacted regardless.
+	LogPayloads bool
+
+	// DatabaseURL is the PostgreSQL DSN (DATABASE_URL), possibly from a secrets manager
+	DatabaseURL string
+	// ShadowReads enables shadow reads: event reads are repeated through the pgx
+	// repository in the background and differences with lib/pq are logged
+	ShadowReads bool
+	// ShadowDatabaseURL is the database the pgx repository reads; DatabaseURL when unset
+	ShadowDatabaseURL string
+	// ShadowReadPercent is the share of event reads that are shadowed
+	ShadowReadPercent int
+	// ReplicaDatabaseURL sends event reads to this streaming replica of DatabaseURL;
+	// reads with a consistency token wait for it to catch up with the token's write
+	ReplicaDatabaseURL string
+	// ReplicaMaxWait is how long such a read waits before falling back to the primary
+	ReplicaMaxWait time.Duration
+	// DBConnectTimeout is how long startup retries connecting to the database
+	DBConnectTimeout time.Duration
+	// DBLazyConnect starts the server degraded when the database is still unreachable
+	// after DBConnectTimeout, instead of exiting, and connects once it appears
+	DBLazyConnect bool
+	// SchemaCheck verifies on startup that the migrations' tables, columns and indexes
+	// exist, refusing to start with a report of those missing
+	SchemaCheck bool
+	// CoalesceReads shares listings, heatmaps and stats among identical concurrent
+	// requests, so a burst of them runs one query
+	CoalesceReads bool
+	// AggregateCacheTTL keeps heatmaps and stats in memory for that long; 0 disables
+	// the cache. AggregateStaleWhileRevalidate is how long past the TTL they are still
+	// served while being read again in the background.
+	AggregateCacheTTL             time.Duration
+	AggregateStaleWhileRevalidate time.Duration
+	// CacheControl overrides the Cache-Control of successful GET responses by route, as
+	// "<path template> <header value>" entries separated by semicolons
+	CacheControl string
+	// SecretsProvider is env (default), vault or aws; see secrets.go for their settings
+	SecretsProvider string
+	// SecretsRefreshInterval is how often secrets are re-fetched to pick up rotation
+	SecretsRefreshInterval time.Duration
+
+	// SMTPHost enables email notifications; without it notifications are only logged
+	SMTPHost string
+	SMTPPort string
+	// SMTPUsername and SMTPPassword authenticate outgoing mail
+	SMTPUsername string
+	SMTPPassword string
+	// SMTPFrom is the sender address of notification emails
+	SMTPFrom string
+	// VAPIDPublicKey and VAPIDPrivateKey enable Web Push notifications; generate them
+	// with the vapid-keys command
+	VAPIDPublicKey  string
+	VAPIDPrivateKey string
+	// VAPIDSubject is the mailto: or https: contact push services can reach
+	VAPIDSubject string
+	// FCMCredentialsFile is the Firebase service account JSON key enabling pushes to
+	// Android devices
+	FCMCredentialsFile string
+	// APNsKeyFile is the .p8 signing key enabling pushes to iOS devices, with its key
+	// ID, the Apple team ID and the app's bundle ID as topic
+	APNsKeyFile string
+	APNsKeyID   string
+	APNsTeamID  string
+	APNsTopic   string
+	// APNsSandbox sends to development builds of the app
+	APNsSandbox bool
+
+	// APIKey enables authentication; it authenticates as an admin and can mint
+	// personal tokens for users. When empty the API is open.
+	APIKey string
+	// HMACClients are machine clients authenticating with signed requests (HMAC_CLIENTS)
+	HMACClients map[string]HMACClient
+	// HMACMaxSkew bounds clock drift for signed requests and how long nonces are remembered
+	HMACMaxSkew time.Duration
+	// AuthLockout locks out client IPs and HMAC clients failing to authenticate too often
+	AuthLockout AuthLockoutPolicy
+	// TwoFactorIssuer names the service in authenticator apps
+	TwoFactorIssuer string
+
+	// TLSCertFile and TLSKeyFile enable HTTPS
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile enables mutual TLS with client certificates issued by this CA bundle
+	TLSClientCAFile string
+	// TLSClientAuth is optional (default) or require
+	TLSClientAuth string
+	// MTLSIdentities maps certificate identities to scopes (MTLS_IDENTITIES)
+	MTLSIdentities map[string][]string
+
+	// HolidayCountry is the ISO 3166 country whose public holidays are checked on create
+	HolidayCountry string
+	// HolidayPolicy is one of ignore, warn or busy
+	HolidayPolicy string
+	// HolidaySource is embedded or nager (https://date.nager.at)
+	HolidaySource string
+
+	// WeatherProvider enables ?include=weather when set to openmeteo
+	WeatherProvider string
+	// WeatherCacheTTL is how long a forecast is reused before asking the provider again
+	WeatherCacheTTL time.Duration
+
+	// IDStrategy is uuidv4, uuidv7 or ulid
+	IDStrategy string
+	// ShortIDs adds a base58 short_id to responses for use in URLs
+	ShortIDs bool
+	// FastJSON encodes events without reflection; the output is the same as that of
+	// encoding/json, which is used when it is off
+	FastJSON bool
+
+	// EncryptionKeys enables AES-GCM encryption of description and location at rest:
+	// "version:base64key,..." with the primary (write) key first
+	EncryptionKeys string
+
+	// SchedulerEnabled runs due schedules and queued operations on this instance; API
+	// access to schedules and operations is unaffected
+	SchedulerEnabled bool
+	// BackupStorage is where export_events writes backups: local, s3 or gcs
+	BackupStorage string
+	// BackupBucket is the S3 or GCS bucket for backups
+	BackupBucket string
+	// ExportDir is the directory used by local backup storage
+	ExportDir string
+	// CoverSizes are the widths of the thumbnails generated for event covers, which
+	// are kept in the backup storage
+	CoverSizes []int
+	// CoverMaxBytes bounds the size of an uploaded cover
+	CoverMaxBytes int
+	// PublicURL is the scheme and host clients reach the API at, used for links handed
+	// out to other apps; when empty it is taken from each request
+	PublicURL string
+	// EventPages serves published events as public HTML pages at /e/{id}
+	EventPages bool
+	// FeedSigningKey signs the tokens of calendar ICS feed URLs; feeds are disabled
+	// without it
+	FeedSigningKey string
+	// CDNProvider is cloudflare or fastly to purge the event pages and feeds a CDN
+	// caches under PublicURL when events change; CDNZoneID is the Cloudflare zone
+	CDNProvider string
+	CDNAPIToken string
+	CDNZoneID   string
+	// OpenSearchURL is an OpenSearch or Elasticsearch cluster whose index
+	// OpenSearchIndex serves /events/search instead of PostgreSQL, kept in sync with the
+	// events; OpenSearchSigV4 signs its requests for Amazon OpenSearch Service
+	OpenSearchURL   string
+	OpenSearchIndex string
+	OpenSearchSigV4 bool
+	// AnalyticsSink is log, statsd or http to send anonymous usage analytics there;
+	// AnalyticsURL is the StatsD host:port or the HTTP endpoint, AnalyticsWriteKey the
+	// HTTP endpoint's key and AnalyticsSalt keys the anonymous user IDs
+	AnalyticsSink     string
+	AnalyticsURL      string
+	AnalyticsWriteKey string
+	AnalyticsSalt     string
+	// TicketHold is how long tickets of paid events stay reserved before being paid
+	TicketHold time.Duration
+	// StripeSecretKey enables paying for tickets through Stripe Checkout, whose webhook
+	// deliveries are signed with StripeWebhookSecret
+	StripeSecretKey     string
+	StripeWebhookSecret string
+	// WebhookAllowedNetworks lists the private networks (CIDR) webhooks and webhook
+	// reminders may be called on; only public addresses are called otherwise
+	WebhookAllowedNetworks []string
+	// PaymentReturnURL is where payers go after checkout, with {event} and
+	// {reservation} replaced; the reservation's API URL when empty
+	PaymentReturnURL string
+	// InvitationURL is the page organization invitation emails link to, with {token}
+	// replaced; without it the email explains how to accept through the API
+	InvitationURL string
+
+	// SanitizeStrict rejects suspicious title/description input instead of only logging it
+	SanitizeStrict bool
+	// EventUpdateLimit is how many times one event may be modified per minute; 0 disables it
+	EventUpdateLimit int
+	// Plugins are the compiled-in plugins whose hooks run around event writes, in order
+	Plugins []string
+	// ApprovalRequired holds events written by callers without the events:review scope
+	// as pending until a reviewer approves them
+	ApprovalRequired bool
+	// PastEventPolicy is allow, warn or reject for creating events that already ended
+	PastEventPolicy string
+	// MaxEventDuration bounds how long events last; BookingHorizon how far ahead they may
+	// be created or moved and MinLeadTime how soon. 0 disables each.
+	MaxEventDuration time.Duration
+	BookingHorizon   time.Duration
+	MinLeadTime      time.Duration
+	// DeletePolicies set what happens to the dependents of deleted events, calendars and
+	// users, as kind.relation=policy; see ParseCascadePolicies
+	DeletePolicies []string
+	// WriteConstraints are the checks of the constraint pipeline run before every event
+	// write, in order; see NewConstraintPipeline
+	WriteConstraints []string
+	// MinEventDuration is the shortest event the duration constraint allows
+	MinEventDuration time.Duration
+	// WorkingHours ("09:00-18:00"), WorkingDays ("mon-fri") and WorkingHoursTimezone
+	// define the working_hours constraint
+	WorkingHours         string
+	WorkingDays          string
+	WorkingHoursTimezone string
+	// WarnDurationOver (0 disables it) and WarnAllCapsTitles enable more of the warnings
+	// returned with events that are written but look like mistakes
+	WarnDurationOver  time.Duration
+	WarnAllCapsTitles bool
+}
+
+// LoadConfig reads the application settings from the environment
+func LoadConfig() Config {
+	return Config{
+		Port:                   getEnv("PORT", "8080"),
+		ListenAddrs:            getEnvList("LISTEN"),
+		UnixSocketMode:         getEnvFileMode("UNIX_SOCKET_MODE", 0o660),
+		AdminListen:            os.Getenv("ADMIN_LISTEN"),
+		ShutdownDrainDelay:     getEnvDuration("SHUTDOWN_DRAIN_DELAY", 0),
+		ShutdownTimeout:        getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		MetricsPushURL:         os.Getenv("METRICS_PUSH_URL"),
+		MetricsSink:            os.Getenv("METRICS_SINK"),
+		StatsDAddr:             getEnv("STATSD_ADDR", "127.0.0.1:8125"),
+		StatsDPrefix:           getEnv("STATSD_PREFIX", "calendar."),
+		StatsDTags:             getEnvList("STATSD_TAGS"),
+		MetricsTenantBuckets:   getEnvInt("METRICS_TENANT_BUCKETS", 0),
+		MetricsTenantAllowlist: getEnvList("METRICS_TENANT_ALLOWLIST"),
+		ChaosRules:             os.Getenv("CHAOS_RULES"),
+		ChaosSeed:              int64(getEnvInt("CHAOS_SEED", 0)),
+		TraceRepository:        getEnvBool("TRACE_REPOSITORY", false),
+		ReplayLog:              os.Getenv("REPLAY_LOG"),
+		LogSampleRate:          getEnvInt("LOG_SAMPLE_RATE", 1),
+		LogSlowRequest:         getEnvDuration("LOG_SLOW_REQUEST", time.Second),
+		Environment:            getEnv("APP_ENV", "production"),
+		LogPayloads:            getEnvBool("LOG_PAYLOADS", false),
+
+		DatabaseURL:                   os.Getenv("DATABASE_URL"),
+		ShadowReads:                   getEnvBool("SHADOW_READS", false),
+		ShadowDatabaseURL:             os.Getenv("SHADOW_DATABASE_URL"),
+		ShadowReadPercent:             getEnvInt("SHADOW_READ_PERCENT", 100),
+		ReplicaDatabaseURL:            os.Getenv("REPLICA_DATABASE_URL"),
+		ReplicaMaxWait:                getEnvDuration("REPLICA_MAX_WAIT", 200*time.Millisecond),
+		CoalesceReads:                 getEnvBool("COALESCE_READS", true),
+		AggregateCacheTTL:             getEnvDuration("AGGREGATE_CACHE_TTL", 0),
+		AggregateStaleWhileRevalidate: getEnvDuration("AGGREGATE_STALE_WHILE_REVALIDATE", 5*time.Minute),
+		CacheControl:                  os.Getenv("CACHE_CONTROL"),
+		SchemaCheck:                   getEnvBool("SCHEMA_CHECK", true),
+		DBConnectTimeout:              getEnvDuration("DB_CONNECT_TIMEOUT", 30*time.Second),
+		DBLazyConnect:                 getEnvBool("DB_LAZY_CONNECT", false),
+		SecretsProvider:               getEnv("SECRETS_PROVIDER", "env"),
+		SecretsRefreshInterval:        getEnvDuration("SECRETS_REFRESH_INTERVAL", 5*time.Minute),
+
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:     getEnv("SMTP_FROM", "events@localhost"),
+
+		VAPIDPublicKey:  os.Getenv("VAPID_PUBLIC_KEY"),
+		VAPIDPrivateKey: os.Getenv("VAPID_PRIVATE_KEY"),
+		VAPIDSubject:    getEnv("VAPID_SUBJECT", "mailto:"+getEnv("SMTP_FROM", "events@localhost")),
+
+		FCMCredentialsFile: os.Getenv("FCM_CREDENTIALS_FILE"),
+		APNsKeyFile:        os.Getenv("APNS_KEY_FILE"),
+		APNsKeyID:          os.Getenv("APNS_KEY_ID"),
+		APNsTeamID:         os.Getenv("APNS_TEAM_ID"),
+		APNsTopic:          os.Getenv("APNS_TOPIC"),
+		APNsSandbox:        getEnvBool("APNS_SANDBOX", false),
+
+		APIKey:      os.Getenv("API_KEY"),
+		HMACClients: parseHMACClients(os.Getenv("HMAC_CLIENTS")),
+		HMACMaxSkew: getEnvDuration("HMAC_MAX_SKEW", 5*time.Minute),
+		AuthLockout: AuthLockoutPolicy{
+			Threshold:   getEnvInt("AUTH_LOCKOUT_THRESHOLD", 10),
+			Window:      getEnvDuration("AUTH_LOCKOUT_WINDOW", 15*time.Minute),
+			Duration:    getEnvDuration("AUTH_LOCKOUT_DURATION", time.Minute),
+			MaxDuration: getEnvDuration("AUTH_LOCKOUT_MAX_DURATION", time.Hour),
+		},
+		TwoFactorIssuer: getEnv("TWO_FACTOR_ISSUER", "Events API"),
+
+		TLSCertFile:     os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:      os.Getenv("TLS_KEY_FILE"),
+		TLSClientCAFile: os.Getenv("TLS_CLIENT_CA_FILE"),
+		TLSClientAuth:   getEnv("TLS_CLIENT_AUTH", "optional"),
+		MTLSIdentities:  parseMTLSIdentities(os.Getenv("MTLS_IDENTITIES")),
+		HolidayCountry:  strings.ToUpper(os.Getenv("HOLIDAY_COUNTRY")),
+		HolidayPolicy:   getEnv("HOLIDAY_POLICY", HolidayPolicyIgnore),
+		HolidaySource:   getEnv("HOLIDAY_SOURCE", "embedded"),
+
+		WeatherProvider: os.Getenv("WEATHER_PROVIDER"),
+		WeatherCacheTTL: getEnvDuration("WEATHER_CACHE_TTL", time.Hour),
+
+		IDStrategy: getEnv("ID_STRATEGY", IDStrategyUUIDv4),
+		ShortIDs:   getEnvBool("SHORT_IDS", false),
+		FastJSON:   getEnvBool("FAST_JSON", true),
+
+		EncryptionKeys: os.Getenv("ENCRYPTION_KEYS"),
+		SanitizeStrict: getEnvBool("SANITIZE_STRICT", false),
+
+		EventUpdateLimit: getEnvInt("EVENT_UPDATE_LIMIT", 30),
+		Plugins:          getEnvList("PLUGINS"),
+		ApprovalRequired: getEnvBool("APPROVAL_REQUIRED", false),
+
+		PastEventPolicy:      getEnv("PAST_EVENT_POLICY", PastEventPolicyWarn),
+		MaxEventDuration:     getEnvDuration("MAX_EVENT_DURATION", 0),
+		BookingHorizon:       getEnvDuration("BOOKING_HORIZON", 0),
+		MinLeadTime:          getEnvDuration("MIN_LEAD_TIME", 0),
+		WriteConstraints:     getEnvList("WRITE_CONSTRAINTS"),
+		DeletePolicies:       getEnvList("DELETE_POLICIES"),
+		MinEventDuration:     getEnvDuration("MIN_EVENT_DURATION", 0),
+		WorkingHours:         getEnv("WORKING_HOURS", "09:00-18:00"),
+		WorkingDays:          getEnv("WORKING_DAYS", "mon-fri"),
+		WorkingHoursTimezone: getEnv("WORKING_HOURS_TZ", "UTC"),
+		WarnDurationOver:     getEnvDuration("WARN_DURATION_OVER", 24*time.Hour),
+		WarnAllCapsTitles:    getEnvBool("WARN_ALL_CAPS_TITLES", true),
+
+		SchedulerEnabled:       getEnvBool("SCHEDULER_ENABLED", true),
+		BackupStorage:          getEnv("BACKUP_STORAGE", "local"),
+		BackupBucket:           os.Getenv("BACKUP_BUCKET"),
+		ExportDir:              getEnv("EXPORT_DIR", "exports"),
+		CoverSizes:             getEnvInts("COVER_SIZES", []int{160, 480, 1024}),
+		CoverMaxBytes:          getEnvInt("COVER_MAX_BYTES", 10<<20),
+		PublicURL:              strings.TrimRight(os.Getenv("PUBLIC_URL"), "/"),
+		EventPages:             getEnvBool("EVENT_PAGES", false),
+		FeedSigningKey:         os.Getenv("FEED_SIGNING_KEY"),
+		CDNProvider:            os.Getenv("CDN_PROVIDER"),
+		CDNAPIToken:            os.Getenv("CDN_API_TOKEN"),
+		CDNZoneID:              os.Getenv("CDN_ZONE_ID"),
+		OpenSearchURL:          os.Getenv("OPENSEARCH_URL"),
+		OpenSearchIndex:        getEnv("OPENSEARCH_INDEX", "events"),
+		OpenSearchSigV4:        getEnvBool("OPENSEARCH_AWS_SIGV4", false),
+		AnalyticsSink:          os.Getenv("ANALYTICS_SINK"),
+		AnalyticsURL:           os.Getenv("ANALYTICS_URL"),
+		AnalyticsWriteKey:      os.Getenv("ANALYTICS_WRITE_KEY"),
+		AnalyticsSalt:          os.Getenv("ANALYTICS_SALT"),
+		TicketHold:             getEnvDuration("TICKET_HOLD", 15*time.Minute),
+		StripeSecretKey:        os.Getenv("STRIPE_SECRET_KEY"),
+		StripeWebhookSecret:    os.Getenv("STRIPE_WEBHOOK_SECRET"),
+		WebhookAllowedNetworks: getEnvList("WEBHOOK_ALLOWED_NETWORKS"),
+		PaymentReturnURL:       os.Getenv("PAYMENT_RETURN_URL"),
+		InvitationURL:          os.Getenv("INVITATION_URL"),
+	}
+}
+
+// AuthEnabled reports whether requests must carry credentials
+func (c Config) AuthEnabled() bool {
+	return c.APIKey != "" || len(c.HMACClients) > 0 || c.TLSClientCAFile != ""
+}
+
+// DebugEnvironment reports whether the deployment is one where full payloads may be logged
+func (c Config) DebugEnvironment() bool {
+	switch strings.ToLower(c.Environment) {
+	case "development", "dev", "debug", "local", "test":
+		return true
+	}
+	return false
+}
+
+// FullPayloadLogging reports whether user content is logged in full
+func (c Config) FullPayloadLogging() bool {
+	return c.LogPayloads && c.DebugEnvironment()
+}
+
+// getEnv returns the environment variable or def when it is unset
+func getEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// getEnvBool parses a boolean such as "true" or "1", falling back to def
+func getEnvBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using %t", key, v, def)
+		return def
+	}
+	return b
+}
+
+// getEnvInt parses a non-negative integer, falling back to def
+func getEnvInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Printf("Warning: invalid %s %q, using %d", key, v, def)
+		return def
+	}
+	return n
+}
+
+// getEnvFileMode parses an octal permission such as 0660, falling back to def
+func getEnvFileMode(key string, def os.FileMode) os.FileMode {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	mode, err := strconv.ParseUint(v, 8, 32)
+	if err != nil || mode > 0o777 {
+		log.Printf("Warning: invalid %s %q, using %#o", key, v, def)
+		return def
+	}
+	return os.FileMode(mode)
+}
+
+// getEnvList splits a comma-separated list, dropping empty items
+func getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvInts parses a comma-separated list of positive integers, falling back to def
+func getEnvInts(key string, def []int) []int {
+	items := getEnvList(key)
+	if len(items) == 0 {
+		return def
+	}
+	ints := make([]int, 0, len(items))
+	for _, item := range items {
+		n, err := strconv.Atoi(item)
+		if err != nil || n <= 0 {
+			log.Printf("Warning: invalid %s %q, using %v", key, os.Getenv(key), def)
+			return def
+		}
+		ints = append(ints, n)
+	}
+	return ints
+}
+
+// getEnvDuration parses a duration such as "30s" or "1h", falling back to def
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using %s", key, v, def)
+		return def
+	}
+	return d
+}
@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyConnector fails the first failures connections, like a database still starting
+type flakyConnector struct {
+	failures int32
+	attempts atomic.Int32
+}
+
+func (c *flakyConnector) Connect(context.Context) (driver.Conn, error) {
+	if c.attempts.Add(1) <= c.failures {
+		return nil, errors.New("connection refused")
+	}
+	return stubConn{}, nil
+}
+
+func (c *flakyConnector) Driver() driver.Driver { return nil }
+
+type stubConn struct{}
+
+func (stubConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (stubConn) Close() error                        { return nil }
+func (stubConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func TestPingUntilUp(t *testing.T) {
+	connector := &flakyConnector{failures: 1}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	assert.NoError(t, pingUntilUp(context.Background(), db))
+	assert.Equal(t, int32(2), connector.attempts.Load(), "retried after the backoff")
+
+	// An expired deadline still gets one attempt, and its error
+	down := &flakyConnector{failures: 100}
+	db = sql.OpenDB(down)
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	time.Sleep(2 * time.Millisecond)
+	assert.EqualError(t, pingUntilUp(ctx, db), "connection refused")
+	assert.Equal(t, int32(1), down.attempts.Load())
+}
+
+func TestWithApplicationName(t *testing.T) {
+	t.Setenv("PGAPPNAME", "")
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"postgres://localhost/db?sslmode=disable", "postgres://localhost/db?application_name=taller_challenge&sslmode=disable"},
+		{"postgres://localhost/db?application_name=worker", "postgres://localhost/db?application_name=worker"},
+		{"host=localhost dbname=db", "host=localhost dbname=db application_name=taller_challenge"},
+		{"host=localhost application_name=worker", "host=localhost application_name=worker"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, withApplicationName(tt.dsn), tt.dsn)
+	}
+
+	t.Setenv("PGAPPNAME", "worker")
+	assert.Equal(t, "host=localhost", withApplicationName("host=localhost"))
+}
@@ -0,0 +1,274 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HeaderConsistencyToken carries the token handed out on writes, which clients send
+// back on reads to see their own writes
+const HeaderConsistencyToken = "X-Consistency-Token"
+
+// replicaPollInterval is how often a read waiting for the replica checks its progress
+const replicaPollInterval = 20 * time.Millisecond
+
+// Consistency tracks the read-your-writes state of one request: the token the client
+// sent and whether the request wrote
+type Consistency struct {
+	// Token is the token sent by the client, empty when it sent none
+	Token string
+
+	mu      sync.Mutex
+	current func(ctx context.Context) (string, error)
+}
+
+type consistencyKey struct{}
+
+// WithConsistency returns a context tracking the writes of a request that sent token
+func WithConsistency(ctx context.Context, token string) (context.Context, *Consistency) {
+	c := &Consistency{Token: token}
+	return context.WithValue(ctx, consistencyKey{}, c), c
+}
+
+// ConsistencyFromContext returns the consistency state of the request, or nil
+func ConsistencyFromContext(ctx context.Context) *Consistency {
+	c, _ := ctx.Value(consistencyKey{}).(*Consistency)
+	return c
+}
+
+// wrote records a write, whose token current will tell
+func (c *Consistency) wrote(current func(ctx context.Context) (string, error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = current
+}
+
+func (c *Consistency) hasWritten() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current != nil
+}
+
+// WriteToken returns the token covering the writes of the request, or "" when it did
+// not write. It is asked for once the response is written, after any transaction of
+// the request committed.
+func (c *Consistency) WriteToken(ctx context.Context) string {
+	c.mu.Lock()
+	current := c.current
+	c.mu.Unlock()
+	if current == nil {
+		return ""
+	}
+	token, err := current(ctx)
+	if err != nil {
+		log.Printf("Error getting consistency token: %v", err)
+		return ""
+	}
+	return token
+}
+
+// ParseLSN parses a Postgres WAL location such as 16/B374D848
+func ParseLSN(s string) (uint64, error) {
+	hi, lo, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, fmt.Errorf("invalid LSN %q", s)
+	}
+	h, err := strconv.ParseUint(hi, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LSN %q", s)
+	}
+	l, err := strconv.ParseUint(lo, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LSN %q", s)
+	}
+	return h<<32 | l, nil
+}
+
+// ReplicatedEventRepository sends writes to the primary and reads to a streaming
+// replica. The token of a write is the primary's WAL location after it; a read with a
+// token is served by the replica once it has replayed that far, waiting up to maxWait,
+// and by the primary otherwise, so clients always see their own writes.
+type ReplicatedEventRepository struct {
+	EventRepositoryInterface
+	replica EventRepositoryInterface
+	maxWait time.Duration
+	// primaryLSN and replicaLSN return the WAL location written by the primary and
+	// replayed by the replica
+	primaryLSN func(ctx context.Context) (string, error)
+	replicaLSN func(ctx context.Context) (string, error)
+}
+
+// NewReplicatedEventRepository reads from replica, whose database is replicaDB, and
+// writes to primary, whose database is primaryDB
+func NewReplicatedEventRepository(primary, replica EventRepositoryInterface, primaryDB, replicaDB *sql.DB, maxWait time.Duration) *ReplicatedEventRepository {
+	return &ReplicatedEventRepository{
+		EventRepositoryInterface: primary,
+		replica:                  replica,
+		maxWait:                  maxWait,
+		primaryLSN:               walLocation(primaryDB, `SELECT pg_current_wal_lsn()::text`),
+		replicaLSN:               walLocation(replicaDB, `SELECT COALESCE(pg_last_wal_replay_lsn(), '0/0')::text`),
+	}
+}
+
+func walLocation(db *sql.DB, query string) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		var lsn string
+		if err := traced(ctx, db).QueryRowContext(ctx, query).Scan(&lsn); err != nil {
+			return "", fmt.Errorf("failed to get WAL location: %w", err)
+		}
+		return lsn, nil
+	}
+}
+
+// Ping checks the primary's database when it supports it
+func (r *ReplicatedEventRepository) Ping(ctx context.Context) error {
+	return pingRepository(ctx, r.EventRepositoryInterface)
+}
+
+// reader returns the repository to read from. Reads inside a transaction or after a
+// write of the same request stay on the primary.
+func (r *ReplicatedEventRepository) reader(ctx context.Context) EventRepositoryInterface {
+	if _, inTx := ctx.Value(txKey{}).(*sql.Tx); inTx {
+		return r.EventRepositoryInterface
+	}
+	c := ConsistencyFromContext(ctx)
+	if c != nil && c.hasWritten() {
+		return r.EventRepositoryInterface
+	}
+	if c == nil || c.Token == "" {
+		return r.replica
+	}
+	want, err := ParseLSN(c.Token)
+	if err != nil {
+		return r.EventRepositoryInterface
+	}
+
+	deadline := time.Now().Add(r.maxWait)
+	for {
+		lsn, err := r.replicaLSN(ctx)
+		if err != nil {
+			log.Printf("Error checking replica progress: %v", err)
+			return r.EventRepositoryInterface
+		}
+		if replayed, err := ParseLSN(lsn); err == nil && replayed >= want {
+			return r.replica
+		}
+		if !time.Now().Add(replicaPollInterval).Before(deadline) {
+			return r.EventRepositoryInterface
+		}
+		select {
+		case <-ctx.Done():
+			return r.EventRepositoryInterface
+		case <-time.After(replicaPollInterval):
+		}
+	}
+}
+
+// written records a write for the request's consistency token
+func (r *ReplicatedEventRepository) written(ctx context.Context) {
+	if c := ConsistencyFromContext(ctx); c != nil {
+		c.wrote(r.primaryLSN)
+	}
+}
+
+func (r *ReplicatedEventRepository) CreateEvent(ctx context.Context, event EventDB) (*EventDB, error) {
+	r.written(ctx)
+	return r.EventRepositoryInterface.CreateEvent(ctx, event)
+}
+
+func (r *ReplicatedEventRepository) UpdateEvent(ctx context.Context, event EventDB) (*EventDB, error) {
+	r.written(ctx)
+	return r.EventRepositoryInterface.UpdateEvent(ctx, event)
+}
+
+func (r *ReplicatedEventRepository) DeleteEvent(ctx context.Context, id uuid.UUID) error {
+	r.written(ctx)
+	return r.EventRepositoryInterface.DeleteEvent(ctx, id)
+}
+
+func (r *ReplicatedEventRepository) ImportEvents(ctx context.Context, events []EventDB) (int, error) {
+	r.written(ctx)
+	return r.EventRepositoryInterface.ImportEvents(ctx, events)
+}
+
+func (r *ReplicatedEventRepository) ApplySyncChange(ctx context.Context, c SyncChange) (*SyncOutcome, error) {
+	r.written(ctx)
+	return r.EventRepositoryInterface.ApplySyncChange(ctx, c)
+}
+
+func (r *ReplicatedEventRepository) ReviewEvent(ctx context.Context, review EventReview) (*EventDB, error) {
+	r.written(ctx)
+	return r.EventRepositoryInterface.ReviewEvent(ctx, review)
+}
+
+func (r *ReplicatedEventRepository) GetEvents(ctx context.Context) ([]EventDB, error) {
+	return r.reader(ctx).GetEvents(ctx)
+}
+
+func (r *ReplicatedEventRepository) GetEventByID(ctx context.Context, id uuid.UUID) (*EventDB, error) {
+	return r.reader(ctx).GetEventByID(ctx, id)
+}
+
+func (r *ReplicatedEventRepository) GetEventsBetween(ctx context.Context, from, to time.Time) ([]EventDB, error) {
+	return r.reader(ctx).GetEventsBetween(ctx, from, to)
+}
+
+func (r *ReplicatedEventRepository) GetEventsByCalendar(ctx context.Context, calendarID uuid.UUID) ([]EventDB, error) {
+	return r.reader(ctx).GetEventsByCalendar(ctx, calendarID)
+}
+
+func (r *ReplicatedEventRepository) GetEventsByExternalID(ctx context.Context, source, externalID string) ([]EventDB, error) {
+	return r.reader(ctx).GetEventsByExternalID(ctx, source, externalID)
+}
+
+func (r *ReplicatedEventRepository) GetEventByClientKey(ctx context.Context, calendarID *uuid.UUID, clientKey string) (*EventDB, error) {
+	return r.reader(ctx).GetEventByClientKey(ctx, calendarID, clientKey)
+}
+
+func (r *ReplicatedEventRepository) EventsVersion(ctx context.Context, source, externalID string) (*EventsVersion, error) {
+	return VersionEvents(ctx, r.reader(ctx), source, externalID)
+}
+
+func (r *ReplicatedEventRepository) FilterEvents(ctx context.Context, f *EventFilter) ([]EventDB, error) {
+	return FilterEvents(ctx, r.reader(ctx), f)
+}
+
+func (r *ReplicatedEventRepository) GetEventSummaries(ctx context.Context, f *EventFilter) ([]EventSummary, error) {
+	return SummarizeEvents(ctx, r.reader(ctx), f)
+}
+
+func (r *ReplicatedEventRepository) SearchEvents(ctx context.Context, q SearchQuery) ([]EventSummary, error) {
+	return SearchEvents(ctx, r.reader(ctx), q)
+}
+
+func (r *ReplicatedEventRepository) PullChanges(ctx context.Context, after SyncCursor, limit int) (*SyncPage, error) {
+	return r.reader(ctx).PullChanges(ctx, after, limit)
+}
+
+func (r *ReplicatedEventRepository) ListPendingEvents(ctx context.Context, limit int) ([]EventDB, error) {
+	return r.reader(ctx).ListPendingEvents(ctx, limit)
+}
+
+func (r *ReplicatedEventRepository) ListEventReviews(ctx context.Context, eventID uuid.UUID) ([]EventReview, error) {
+	return r.reader(ctx).ListEventReviews(ctx, eventID)
+}
+
+func (r *ReplicatedEventRepository) Occupancy(ctx context.Context, q HeatmapQuery) ([]HeatmapBucket, error) {
+	return r.reader(ctx).Occupancy(ctx, q)
+}
+
+func (r *ReplicatedEventRepository) EventStats(ctx context.Context, q EventStatsQuery) ([]EventStatsRow, error) {
+	return r.reader(ctx).EventStats(ctx, q)
+}
+
+func (r *ReplicatedEventRepository) SuggestTitles(ctx context.Context, q SuggestQuery) ([]TitleSuggestion, error) {
+	return r.reader(ctx).SuggestTitles(ctx, q)
+}
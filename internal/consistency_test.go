@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLSN(t *testing.T) {
+	lsn, err := ParseLSN("16/B374D848")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0x16)<<32|0xB374D848, lsn)
+
+	a, _ := ParseLSN("0/FFFFFFFF")
+	b, _ := ParseLSN("1/0")
+	assert.Less(t, a, b)
+
+	for _, bad := range []string{"", "16", "16/", "x/1", "1/100000000"} {
+		_, err := ParseLSN(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestReplicatedEventRepository(t *testing.T) {
+	event := EventDB{ID: uuid.New(), Title: "Standup"}
+	primary := &writeRecorder{created: []EventDB{event}}
+	replica := &writeRecorder{}
+	replayed := "0/10"
+	repo := &ReplicatedEventRepository{
+		EventRepositoryInterface: primary,
+		replica:                  replica,
+		maxWait:                  0,
+		primaryLSN:               func(ctx context.Context) (string, error) { return "0/20", nil },
+		replicaLSN:               func(ctx context.Context) (string, error) { return replayed, nil },
+	}
+
+	// Without a token reads go to the replica, which has not seen the event
+	ctx, c := WithConsistency(context.Background(), "")
+	_, err := repo.GetEventByID(ctx, event.ID)
+	assert.ErrorIs(t, err, ErrEventNotFound)
+	assert.Empty(t, c.WriteToken(ctx))
+
+	// A write hands out the primary's location, and later reads of the request stay on it
+	_, err = repo.UpdateEvent(ctx, event)
+	require.NoError(t, err)
+	assert.Equal(t, "0/20", c.WriteToken(ctx))
+	got, err := repo.GetEventByID(ctx, event.ID)
+	require.NoError(t, err)
+	assert.Equal(t, event.ID, got.ID)
+
+	// A token ahead of the replica is served by the primary
+	ctx, _ = WithConsistency(context.Background(), "0/20")
+	_, err = repo.GetEventByID(ctx, event.ID)
+	assert.NoError(t, err)
+
+	// and by the replica once it caught up
+	replayed = "0/20"
+	_, err = repo.GetEventByID(ctx, event.ID)
+	assert.ErrorIs(t, err, ErrEventNotFound)
+
+	// Reads wait for the replica up to maxWait
+	replayed = "0/10"
+	repo.maxWait = time.Second
+	calls := 0
+	repo.replicaLSN = func(ctx context.Context) (string, error) {
+		calls++
+		if calls < 3 {
+			return "0/10", nil
+		}
+		return "0/30", nil
+	}
+	_, err = repo.GetEventByID(ctx, event.ID)
+	assert.ErrorIs(t, err, ErrEventNotFound)
+	assert.Equal(t, 3, calls)
+
+	// Invalid tokens are served by the primary
+	ctx, _ = WithConsistency(context.Background(), "garbage")
+	_, err = repo.GetEventByID(ctx, event.ID)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,258 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Names of the built-in write constraints
+const (
+	ConstraintDuration     = "duration"
+	ConstraintWorkingHours = "working_hours"
+	ConstraintConflicts    = "conflicts"
+	ConstraintPolicy       = "policy"
+)
+
+// maxReportedConflicts bounds how many overlapping events one violation lists
+const maxReportedConflicts = 5
+
+// Violation is a rule an event about to be written breaks
+type Violation struct {
+	Constraint string `json:"constraint"`
+	Message    string `json:"message"`
+}
+
+// ConstraintFunc returns the messages of the rules event breaks. An error is a failure
+// of the check itself, not a violation.
+type ConstraintFunc func(ctx context.Context, event EventDB) ([]string, error)
+
+// Constraint is one check of a ConstraintPipeline
+type Constraint struct {
+	Name  string
+	Check ConstraintFunc
+	// Stop skips the constraints after this one when it is violated, for violations
+	// that make the later checks meaningless or not worth their cost
+	Stop bool
+}
+
+// ConstraintError rejects a write with every violation the pipeline found. It is a
+// validation error.
+type ConstraintError struct {
+	Violations []Violation
+}
+
+func (e *ConstraintError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Constraint + ": " + v.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is makes errors.Is(err, ErrValidation) match
+func (e *ConstraintError) Is(target error) bool { return target == ErrValidation }
+
+// ConstraintPipeline runs its constraints in order before event creates and updates,
+// and rejects the write with all the violations found rather than the first
+type ConstraintPipeline struct {
+	constraints []Constraint
+}
+
+// NewConstraintPipeline runs constraints in the order given
+func NewConstraintPipeline(constraints ...Constraint) *ConstraintPipeline {
+	return &ConstraintPipeline{constraints: constraints}
+}
+
+// NewConstraintPipelineFromConfig builds the pipeline of cfg.WriteConstraints, a list
+// of duration, working_hours, conflicts and policy where a "!" suffix marks the
+// constraints that stop the pipeline when violated. events is read for conflicts and
+// policies evaluated for policy. It returns nil when no constraint is configured.
+func NewConstraintPipelineFromConfig(cfg Config, events EventRepositoryInterface, policies *PolicyEngine) (*ConstraintPipeline, error) {
+	if len(cfg.WriteConstraints) == 0 {
+		return nil, nil
+	}
+	p := &ConstraintPipeline{}
+	for _, name := range cfg.WriteConstraints {
+		c := Constraint{Name: strings.TrimSuffix(name, "!"), Stop: strings.HasSuffix(name, "!")}
+		if p.Has(c.Name) {
+			return nil, fmt.Errorf("constraint %s is listed twice", c.Name)
+		}
+		switch c.Name {
+		case ConstraintDuration:
+			c.Check = DurationConstraint(cfg.MinEventDuration, cfg.MaxEventDuration)
+		case ConstraintWorkingHours:
+			hours, err := ParseWorkingHours(cfg.WorkingHours, cfg.WorkingDays, cfg.WorkingHoursTimezone)
+			if err != nil {
+				return nil, err
+			}
+			c.Check = hours.Constraint()
+		case ConstraintConflicts:
+			c.Check = ConflictConstraint(events)
+		case ConstraintPolicy:
+			if policies == nil {
+				return nil, fmt.Errorf("constraint %s needs the policy engine", c.Name)
+			}
+			c.Check = policies.Violations
+		default:
+			return nil, fmt.Errorf("unknown constraint %q (available: %s, %s, %s, %s)", c.Name, ConstraintDuration, ConstraintWorkingHours, ConstraintConflicts, ConstraintPolicy)
+		}
+		p.constraints = append(p.constraints, c)
+	}
+	return p, nil
+}
+
+// Has reports whether the pipeline runs the constraint name. A nil pipeline runs none.
+func (p *ConstraintPipeline) Has(name string) bool {
+	if p == nil {
+		return false
+	}
+	for _, c := range p.constraints {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Register runs the pipeline before every create and update
+func (p *ConstraintPipeline) Register(hooks *EventHooks) {
+	hooks.BeforeCreate(p.Check)
+	hooks.BeforeUpdate(p.Check)
+}
+
+// Check returns a *ConstraintError with the violations of event, or nil when it
+// breaks none
+func (p *ConstraintPipeline) Check(ctx context.Context, event *EventDB) error {
+	var violations []Violation
+	for _, c := range p.constraints {
+		msgs, err := c.Check(ctx, *event)
+		if err != nil {
+			return HookInternalError(fmt.Errorf("constraint %s: %w", c.Name, err))
+		}
+		for _, msg := range msgs {
+			violations = append(violations, Violation{Constraint: c.Name, Message: msg})
+		}
+		if c.Stop && len(msgs) > 0 {
+			break
+		}
+	}
+	if len(violations) > 0 {
+		return &ConstraintError{Violations: violations}
+	}
+	return nil
+}
+
+// DurationConstraint requires events to last between min and max; 0 disables each
+func DurationConstraint(min, max time.Duration) ConstraintFunc {
+	return func(ctx context.Context, event EventDB) ([]string, error) {
+		d := event.EndTime.Sub(event.StartTime)
+		if min > 0 && d < min {
+			return []string{fmt.Sprintf("events must last at least %s", FormatDuration(min))}, nil
+		}
+		if max > 0 && d > max {
+			return []string{fmt.Sprintf("events may last at most %s", FormatDuration(max))}, nil
+		}
+		return nil, nil
+	}
+}
+
+// WorkingHours are the daily opening hours events must fall within
+type WorkingHours struct {
+	// Open and Close are offsets from midnight
+	Open, Close time.Duration
+	// days is a bitmask of weekdays, bit 0 being Sunday
+	days     uint64
+	location *time.Location
+	spec     string
+}
+
+// ParseWorkingHours parses hours such as "09:00-18:00" on days such as "mon-fri" (the
+// day-of-week syntax of cron expressions) in the IANA zone tz
+func ParseWorkingHours(hours, days, tz string) (*WorkingHours, error) {
+	openStr, closeStr, ok := strings.Cut(hours, "-")
+	if !ok {
+		return nil, fmt.Errorf("working hours %q must look like 09:00-18:00", hours)
+	}
+	open, err := parseTimeOfDay(openStr)
+	if err != nil {
+		return nil, fmt.Errorf("working hours %q: %w", hours, err)
+	}
+	close, err := parseTimeOfDay(closeStr)
+	if err != nil {
+		return nil, fmt.Errorf("working hours %q: %w", hours, err)
+	}
+	if open >= close {
+		return nil, fmt.Errorf("working hours %q must open before they close", hours)
+	}
+	mask, err := parseCronField(days, 0, 7, cronDayNames)
+	if err != nil {
+		return nil, fmt.Errorf("working days %q: %w", days, err)
+	}
+	// 7 is an alias for Sunday, as in cron
+	if mask&(1<<7) != 0 {
+		mask |= 1
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", tz)
+	}
+	return &WorkingHours{Open: open, Close: close, days: mask, location: loc, spec: hours + " " + days + " " + tz}, nil
+}
+
+// parseTimeOfDay parses a time of day such as 09:30 or 24:00
+func parseTimeOfDay(s string) (time.Duration, error) {
+	var h, m int
+	if n, err := fmt.Sscanf(strings.TrimSpace(s), "%d:%d", &h, &m); err != nil || n != 2 || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// Contains reports whether the event from start to end falls within one working day
+func (w *WorkingHours) Contains(start, end time.Time) bool {
+	start = start.In(w.location)
+	if w.days&(1<<uint(start.Weekday())) == 0 {
+		return false
+	}
+	// Set on the wall clock of the day rather than added as durations, so opening hours
+	// stay put across DST changes
+	open := time.Date(start.Year(), start.Month(), start.Day(), 0, int(w.Open/time.Minute), 0, 0, w.location)
+	close := time.Date(start.Year(), start.Month(), start.Day(), 0, int(w.Close/time.Minute), 0, 0, w.location)
+	return !start.Before(open) && !end.After(close)
+}
+
+// Constraint requires events to fall within the working hours
+func (w *WorkingHours) Constraint() ConstraintFunc {
+	return func(ctx context.Context, event EventDB) ([]string, error) {
+		if w.Contains(event.StartTime, event.EndTime) {
+			return nil, nil
+		}
+		return []string{fmt.Sprintf("events must fall within working hours (%s)", w.spec)}, nil
+	}
+}
+
+// ConflictConstraint rejects events overlapping another event of the same calendar.
+// Rejected events do not count, nor does the previous revision of an updated event.
+func ConflictConstraint(events EventRepositoryInterface) ConstraintFunc {
+	return func(ctx context.Context, event EventDB) ([]string, error) {
+		overlapping, err := events.GetEventsBetween(ctx, event.StartTime, event.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		var msgs []string
+		for _, other := range overlapping {
+			if other.ID == event.ID || other.Status == EventStatusRejected || !sameCalendar(other.CalendarID, event.CalendarID) {
+				continue
+			}
+			if len(msgs) == maxReportedConflicts {
+				msgs = append(msgs, "and more overlapping events")
+				break
+			}
+			msgs = append(msgs, fmt.Sprintf("overlaps event %s from %s to %s", other.ID,
+				other.StartTime.UTC().Format(time.RFC3339), other.EndTime.UTC().Format(time.RFC3339)))
+		}
+		return msgs, nil
+	}
+}
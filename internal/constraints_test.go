@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scheduledEvents serves GetEventsBetween from a fixed list; other methods are not implemented
+type scheduledEvents struct {
+	EventRepositoryInterface
+	events []EventDB
+}
+
+func (s *scheduledEvents) GetEventsBetween(ctx context.Context, from, to time.Time) ([]EventDB, error) {
+	var out []EventDB
+	for _, e := range s.events {
+		if e.StartTime.Before(to) && e.EndTime.After(from) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func violated(msgs ...string) ConstraintFunc {
+	return func(ctx context.Context, event EventDB) ([]string, error) { return msgs, nil }
+}
+
+func TestConstraintPipelineReportsEveryViolation(t *testing.T) {
+	ran := false
+	pipeline := NewConstraintPipeline(
+		Constraint{Name: "a", Check: violated("first")},
+		Constraint{Name: "b", Check: violated()},
+		Constraint{Name: "c", Check: violated("second", "third")},
+		Constraint{Name: "d", Check: func(ctx context.Context, event EventDB) ([]string, error) { ran = true; return nil, nil }},
+	)
+	err := pipeline.Check(context.Background(), &EventDB{})
+
+	var ce *ConstraintError
+	require.ErrorAs(t, err, &ce)
+	assert.Equal(t, []Violation{{"a", "first"}, {"c", "second"}, {"c", "third"}}, ce.Violations)
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.True(t, ran)
+}
+
+func TestConstraintPipelineStops(t *testing.T) {
+	pipeline := NewConstraintPipeline(
+		Constraint{Name: "a", Check: violated()},
+		Constraint{Name: "b", Check: violated("fatal"), Stop: true},
+		Constraint{Name: "c", Check: func(ctx context.Context, event EventDB) ([]string, error) {
+			t.Error("constraint after a stopping violation ran")
+			return nil, nil
+		}},
+	)
+	var ce *ConstraintError
+	require.ErrorAs(t, pipeline.Check(context.Background(), &EventDB{}), &ce)
+	assert.Equal(t, []Violation{{"b", "fatal"}}, ce.Violations)
+
+	// A stopping constraint that passes lets the rest run
+	assert.NoError(t, NewConstraintPipeline(Constraint{Name: "b", Check: violated(), Stop: true}).Check(context.Background(), &EventDB{}))
+}
+
+func TestConstraintPipelineFailure(t *testing.T) {
+	boom := errors.New("connection refused")
+	pipeline := NewConstraintPipeline(Constraint{Name: "conflicts", Check: func(ctx context.Context, event EventDB) ([]string, error) { return nil, boom }})
+
+	err := hookError(pipeline.Check(context.Background(), &EventDB{}))
+	assert.ErrorIs(t, err, boom)
+	assert.Nil(t, KindOf(err), "a failing check is a server error, not a rejection")
+}
+
+func TestDurationConstraint(t *testing.T) {
+	check := DurationConstraint(15*time.Minute, 8*time.Hour)
+	start := time.Date(2025, 10, 6, 9, 0, 0, 0, time.UTC)
+	for d, want := range map[time.Duration]int{5 * time.Minute: 1, 15 * time.Minute: 0, 8 * time.Hour: 0, 9 * time.Hour: 1} {
+		msgs, err := check(context.Background(), EventDB{StartTime: start, EndTime: start.Add(d)})
+		require.NoError(t, err)
+		assert.Len(t, msgs, want, d)
+	}
+}
+
+func TestWorkingHours(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	hours, err := ParseWorkingHours("09:00-18:00", "mon-fri", "Europe/Madrid")
+	require.NoError(t, err)
+
+	at := func(day, hour, min int) time.Time { return time.Date(2025, 10, day, hour, min, 0, 0, madrid) }
+	assert.True(t, hours.Contains(at(6, 9, 0), at(6, 18, 0)), "monday, the whole day")
+	assert.False(t, hours.Contains(at(6, 8, 30), at(6, 10, 0)), "starts before opening")
+	assert.False(t, hours.Contains(at(6, 17, 0), at(6, 18, 30)), "ends after closing")
+	assert.False(t, hours.Contains(at(6, 17, 0), at(7, 10, 0)), "spans two days")
+	assert.False(t, hours.Contains(at(11, 10, 0), at(11, 11, 0)), "saturday")
+	// In UTC the meeting is 07:00-08:00, within the hours of Madrid's summer time
+	assert.True(t, hours.Contains(time.Date(2025, 10, 6, 7, 0, 0, 0, time.UTC), time.Date(2025, 10, 6, 8, 0, 0, 0, time.UTC)))
+
+	for _, spec := range [][3]string{{"9-18", "mon-fri", "UTC"}, {"18:00-09:00", "mon-fri", "UTC"}, {"09:00-25:00", "mon-fri", "UTC"}, {"09:00-18:00", "mon-funday", "UTC"}, {"09:00-18:00", "mon-fri", "Mars/Olympus"}} {
+		_, err := ParseWorkingHours(spec[0], spec[1], spec[2])
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestConflictConstraint(t *testing.T) {
+	calendar, other := uuid.New(), uuid.New()
+	start := time.Date(2025, 10, 6, 9, 0, 0, 0, time.UTC)
+	booked := EventDB{ID: uuid.New(), CalendarID: &calendar, StartTime: start, EndTime: start.Add(time.Hour)}
+	check := ConflictConstraint(&scheduledEvents{events: []EventDB{
+		booked,
+		{ID: uuid.New(), CalendarID: &other, StartTime: start, EndTime: start.Add(time.Hour)},
+		{ID: uuid.New(), CalendarID: &calendar, StartTime: start, EndTime: start.Add(time.Hour), Status: EventStatusRejected},
+	}})
+
+	msgs, err := check(context.Background(), EventDB{ID: uuid.New(), CalendarID: &calendar, StartTime: start.Add(30 * time.Minute), EndTime: start.Add(2 * time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Contains(t, msgs[0], booked.ID.String())
+
+	// Moving an event does not conflict with itself, nor do back-to-back events
+	msgs, _ = check(context.Background(), EventDB{ID: booked.ID, CalendarID: &calendar, StartTime: start.Add(time.Minute), EndTime: start.Add(time.Hour)})
+	assert.Empty(t, msgs)
+	msgs, _ = check(context.Background(), EventDB{ID: uuid.New(), CalendarID: &calendar, StartTime: start.Add(time.Hour), EndTime: start.Add(2 * time.Hour)})
+	assert.Empty(t, msgs)
+}
+
+func TestConstraintPipelineFromConfig(t *testing.T) {
+	engine, err := NewPolicyEngine(&fakePolicyRepository{rules: []PolicyRule{
+		{ID: uuid.New(), Name: "titled", Expression: `event.title != "TBD"`, Message: "name the event", Enabled: true},
+		{ID: uuid.New(), Name: "short", Expression: `event.duration <= duration("1h")`, Message: "keep it short", Enabled: true},
+	}})
+	require.NoError(t, err)
+	cfg := Config{WriteConstraints: []string{"duration!", "policy"}, MinEventDuration: 15 * time.Minute}
+	pipeline, err := NewConstraintPipelineFromConfig(cfg, &scheduledEvents{}, engine)
+	require.NoError(t, err)
+	assert.True(t, pipeline.Has(ConstraintPolicy))
+	assert.False(t, pipeline.Has(ConstraintConflicts))
+
+	start := time.Date(2025, 10, 6, 9, 0, 0, 0, time.UTC)
+	var ce *ConstraintError
+	require.ErrorAs(t, pipeline.Check(context.Background(), &EventDB{Title: "TBD", StartTime: start, EndTime: start.Add(2 * time.Hour)}), &ce)
+	assert.Equal(t, []Violation{{"policy", "name the event"}, {"policy", "keep it short"}}, ce.Violations, "every broken rule, not only the first")
+	require.ErrorAs(t, pipeline.Check(context.Background(), &EventDB{Title: "TBD", StartTime: start, EndTime: start.Add(time.Minute)}), &ce)
+	assert.Equal(t, []Violation{{"duration", "events must last at least 15m"}}, ce.Violations)
+
+	none, err := NewConstraintPipelineFromConfig(Config{}, nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, none)
+	assert.False(t, none.Has(ConstraintPolicy))
+	for _, names := range [][]string{{"duration", "duration!"}, {"weather"}} {
+		_, err := NewConstraintPipelineFromConfig(Config{WriteConstraints: names}, nil, engine)
+		assert.Error(t, err, names)
+	}
+}
@@ -0,0 +1,309 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxCoverPixels bounds the decoded size of a cover, so a small file that expands to
+// a huge image cannot exhaust memory
+const maxCoverPixels = 40_000_000
+
+// thumbnailQuality is the JPEG quality of cover thumbnails
+const thumbnailQuality = 85
+
+var (
+	// ErrCoverNotFound is returned when an event has no cover image
+	ErrCoverNotFound = newDomainError(ErrNotFound, "cover not found")
+	// ErrInvalidImage is returned for uploads that are not a JPEG, PNG or GIF image
+	ErrInvalidImage = newDomainError(ErrValidation, "cover must be a JPEG, PNG or GIF image")
+	// ErrImageTooLarge is returned for images with too many pixels
+	ErrImageTooLarge = newDomainError(ErrValidation, "cover image is too large")
+	// ErrCoverSize is returned when a thumbnail size is not configured
+	ErrCoverSize = newDomainError(ErrValidation, "size is not an available thumbnail width")
+)
+
+// EventCover describes the cover image of an event. The files live in the storage,
+// the original under its ETag and thumbnails next to it.
+type EventCover struct {
+	EventID     uuid.UUID `json:"event_id"`
+	ContentType string    `json:"content_type"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	Bytes       int       `json:"bytes"`
+	// ETag is the hash of the original, which changes with every upload
+	ETag string `json:"etag"`
+	// Sizes are the thumbnail widths that can be requested with ?size=
+	Sizes     []int     `json:"sizes"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CoverRepository struct {
+	db *sql.DB
+}
+
+// NewCoverRepository creates a new cover repository
+func NewCoverRepository(db *sql.DB) *CoverRepository {
+	return &CoverRepository{db: db}
+}
+
+const coverColumns = `event_id, content_type, width, height, bytes, etag, updated_at`
+
+func scanCover(row rowScanner, c *EventCover) error {
+	return row.Scan(&c.EventID, &c.ContentType, &c.Width, &c.Height, &c.Bytes, &c.ETag, &c.UpdatedAt)
+}
+
+// SaveCover records an event's cover, replacing the previous one
+func (r *CoverRepository) SaveCover(ctx context.Context, c EventCover) (*EventCover, error) {
+	query := `
+		INSERT INTO event_covers (event_id, content_type, width, height, bytes, etag)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (event_id) DO UPDATE
+		SET content_type = EXCLUDED.content_type, width = EXCLUDED.width, height = EXCLUDED.height,
+			bytes = EXCLUDED.bytes, etag = EXCLUDED.etag, updated_at = NOW()
+		RETURNING ` + coverColumns
+
+	var saved EventCover
+	row := traced(ctx, r.db).QueryRowContext(ctx, query, c.EventID, c.ContentType, c.Width, c.Height, c.Bytes, c.ETag)
+	if err := scanCover(row, &saved); err != nil {
+		if isForeignKeyViolation(err, "event_covers_event_id_fkey") {
+			return nil, ErrEventNotFound
+		}
+		return nil, fmt.Errorf("failed to save cover: %w", err)
+	}
+	return &saved, nil
+}
+
+// GetCover retrieves the cover of an event
+func (r *CoverRepository) GetCover(ctx context.Context, eventID uuid.UUID) (*EventCover, error) {
+	var c EventCover
+	query := `SELECT ` + coverColumns + ` FROM event_covers WHERE event_id = $1`
+	if err := scanCover(traced(ctx, r.db).QueryRowContext(ctx, query, eventID), &c); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCoverNotFound
+		}
+		return nil, fmt.Errorf("failed to get cover: %w", err)
+	}
+	return &c, nil
+}
+
+// DeleteCover removes the cover record of an event
+func (r *CoverRepository) DeleteCover(ctx context.Context, eventID uuid.UUID) error {
+	res, err := traced(ctx, r.db).ExecContext(ctx, `DELETE FROM event_covers WHERE event_id = $1`, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to delete cover: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrCoverNotFound
+	}
+	return nil
+}
+
+// CoverStore keeps event covers and their thumbnails in the storage
+type CoverStore struct {
+	storage Storage
+	covers  CoverRepositoryInterface
+	sizes   []int
+}
+
+// NewCoverStore stores covers in storage with thumbnails of the given widths
+func NewCoverStore(storage Storage, covers CoverRepositoryInterface, sizes []int) *CoverStore {
+	sizes = append([]int(nil), sizes...)
+	sort.Ints(sizes)
+	return &CoverStore{storage: storage, covers: covers, sizes: sizes}
+}
+
+func coverKey(eventID uuid.UUID, etag string, size int) string {
+	if size == 0 {
+		return fmt.Sprintf("covers/%s/%s/original", eventID, etag)
+	}
+	return fmt.Sprintf("covers/%s/%s/%d.jpg", eventID, etag, size)
+}
+
+// Get returns an event's cover with the available thumbnail sizes
+func (s *CoverStore) Get(ctx context.Context, eventID uuid.UUID) (*EventCover, error) {
+	cover, err := s.covers.GetCover(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	cover.Sizes = s.sizes
+	return cover, nil
+}
+
+// Upload validates and stores data as the event's cover, with a thumbnail per size.
+// The files of the previous cover are removed.
+func (s *CoverStore) Upload(ctx context.Context, eventID uuid.UUID, data []byte) (*EventCover, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if cfg.Width*cfg.Height > maxCoverPixels {
+		return nil, ErrImageTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	sum := sha256.Sum256(data)
+	etag := hex.EncodeToString(sum[:16])
+	if err := s.storage.Put(ctx, coverKey(eventID, etag, 0), data); err != nil {
+		return nil, fmt.Errorf("failed to store cover: %w", err)
+	}
+	for _, size := range s.sizes {
+		if err := s.putThumbnail(ctx, eventID, etag, img, size); err != nil {
+			return nil, err
+		}
+	}
+
+	previous, err := s.covers.GetCover(ctx, eventID)
+	if err != nil && !errors.Is(err, ErrCoverNotFound) {
+		return nil, err
+	}
+	cover, err := s.covers.SaveCover(ctx, EventCover{
+		EventID:     eventID,
+		ContentType: "image/" + format,
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+		Bytes:       len(data),
+		ETag:        etag,
+	})
+	if err != nil {
+		s.removeFiles(ctx, eventID, etag)
+		return nil, err
+	}
+	if previous != nil && previous.ETag != etag {
+		s.removeFiles(ctx, eventID, previous.ETag)
+	}
+	cover.Sizes = s.sizes
+	return cover, nil
+}
+
+// Open returns the original cover (size 0) or the thumbnail of a configured width,
+// with its content type. Thumbnails missing from the storage, for example after a
+// width was added, are generated and kept.
+func (s *CoverStore) Open(ctx context.Context, cover EventCover, size int) ([]byte, string, error) {
+	if size == 0 {
+		data, err := s.storage.Get(ctx, coverKey(cover.EventID, cover.ETag, 0))
+		if errors.Is(err, ErrObjectNotFound) {
+			return nil, "", ErrCoverNotFound
+		}
+		return data, cover.ContentType, err
+	}
+	if i := sort.SearchInts(s.sizes, size); i == len(s.sizes) || s.sizes[i] != size {
+		return nil, "", ErrCoverSize
+	}
+
+	data, err := s.storage.Get(ctx, coverKey(cover.EventID, cover.ETag, size))
+	if !errors.Is(err, ErrObjectNotFound) {
+		return data, "image/jpeg", err
+	}
+	original, err := s.storage.Get(ctx, coverKey(cover.EventID, cover.ETag, 0))
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil, "", ErrCoverNotFound
+	} else if err != nil {
+		return nil, "", err
+	}
+	img, _, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode stored cover: %w", err)
+	}
+	data, err = encodeThumbnail(img, size)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.storage.Put(ctx, coverKey(cover.EventID, cover.ETag, size), data); err != nil {
+		log.Printf("Error storing %dpx thumbnail of event %s: %v", size, cover.EventID, err)
+	}
+	return data, "image/jpeg", nil
+}
+
+// Delete removes an event's cover and its files
+func (s *CoverStore) Delete(ctx context.Context, eventID uuid.UUID) error {
+	cover, err := s.covers.GetCover(ctx, eventID)
+	if err != nil {
+		return err
+	}
+	if err := s.covers.DeleteCover(ctx, eventID); err != nil {
+		return err
+	}
+	s.removeFiles(ctx, eventID, cover.ETag)
+	return nil
+}
+
+func (s *CoverStore) putThumbnail(ctx context.Context, eventID uuid.UUID, etag string, img image.Image, size int) error {
+	data, err := encodeThumbnail(img, size)
+	if err != nil {
+		return err
+	}
+	if err := s.storage.Put(ctx, coverKey(eventID, etag, size), data); err != nil {
+		return fmt.Errorf("failed to store %dpx thumbnail: %w", size, err)
+	}
+	return nil
+}
+
+// removeFiles deletes the original and thumbnails of a cover version. Leftover files
+// only waste space, so failures are logged.
+func (s *CoverStore) removeFiles(ctx context.Context, eventID uuid.UUID, etag string) {
+	for _, size := range append([]int{0}, s.sizes...) {
+		if err := s.storage.Delete(ctx, coverKey(eventID, etag, size)); err != nil {
+			log.Printf("Error deleting cover file of event %s: %v", eventID, err)
+		}
+	}
+}
+
+func encodeThumbnail(img image.Image, width int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, Thumbnail(img, width), &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Thumbnail scales img down to width, keeping its aspect ratio, by averaging the
+// source pixels under each target pixel. Transparent areas become white, as JPEG has
+// no alpha. Images narrower than width keep their size.
+func Thumbnail(img image.Image, width int) *image.RGBA {
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Over)
+	if width >= b.Dx() || b.Dx() == 0 {
+		return src
+	}
+
+	height := max(1, b.Dy()*width/b.Dx())
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*b.Dy()/height, max((y+1)*b.Dy()/height, y*b.Dy()/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*b.Dx()/width, max((x+1)*b.Dx()/width, x*b.Dx()/width+1)
+			var r, g, bl, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride+x0*4 : sy*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					r, g, bl = r+int(row[i]), g+int(row[i+1]), bl+int(row[i+2])
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(r/n), uint8(g/n), uint8(bl/n), 0xff
+		}
+	}
+	return dst
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThumbnail(t *testing.T) {
+	// Left half red, right half transparent
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 200; x++ {
+			img.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+
+	thumb := Thumbnail(img, 100)
+	assert.Equal(t, image.Rect(0, 0, 100, 50), thumb.Bounds())
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, thumb.At(10, 10))
+	assert.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, thumb.At(90, 10), "transparent areas turn white")
+
+	assert.Equal(t, image.Rect(0, 0, 400, 200), Thumbnail(img, 1024).Bounds(), "small images are not enlarged")
+}
+
+// fakeCoverRepository keeps covers in memory
+type fakeCoverRepository struct {
+	covers map[uuid.UUID]EventCover
+}
+
+func (f *fakeCoverRepository) SaveCover(ctx context.Context, c EventCover) (*EventCover, error) {
+	c.UpdatedAt = time.Now()
+	f.covers[c.EventID] = c
+	return &c, nil
+}
+
+func (f *fakeCoverRepository) GetCover(ctx context.Context, eventID uuid.UUID) (*EventCover, error) {
+	c, ok := f.covers[eventID]
+	if !ok {
+		return nil, ErrCoverNotFound
+	}
+	return &c, nil
+}
+
+func (f *fakeCoverRepository) DeleteCover(ctx context.Context, eventID uuid.UUID) error {
+	if _, ok := f.covers[eventID]; !ok {
+		return ErrCoverNotFound
+	}
+	delete(f.covers, eventID)
+	return nil
+}
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))))
+	return buf.Bytes()
+}
+
+func TestCoverStore(t *testing.T) {
+	dir := t.TempDir()
+	repo := &fakeCoverRepository{covers: map[uuid.UUID]EventCover{}}
+	store := NewCoverStore(&LocalStorage{Dir: dir}, repo, []int{480, 160})
+	ctx := context.Background()
+	eventID := uuid.New()
+
+	_, err := store.Upload(ctx, eventID, []byte("not an image"))
+	assert.ErrorIs(t, err, ErrInvalidImage)
+
+	cover, err := store.Upload(ctx, eventID, encodePNG(t, 800, 600))
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", cover.ContentType)
+	assert.Equal(t, []int{160, 480}, cover.Sizes)
+	assert.Equal(t, 800, cover.Width)
+
+	data, contentType, err := store.Open(ctx, *cover, 160)
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", contentType)
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, [2]int{160, 120}, [2]int{cfg.Width, cfg.Height})
+
+	_, _, err = store.Open(ctx, *cover, 300)
+	assert.ErrorIs(t, err, ErrCoverSize)
+
+	// A width added after the upload is generated on first use
+	wider := NewCoverStore(&LocalStorage{Dir: dir}, repo, []int{160, 480, 640})
+	data, _, err = wider.Open(ctx, *cover, 640)
+	require.NoError(t, err)
+	cfg, _ = jpeg.DecodeConfig(bytes.NewReader(data))
+	assert.Equal(t, 640, cfg.Width)
+	assert.FileExists(t, filepath.Join(dir, "covers", eventID.String(), cover.ETag, "640.jpg"))
+
+	// Replacing the cover removes the previous files
+	replaced, err := store.Upload(ctx, eventID, encodePNG(t, 300, 300))
+	require.NoError(t, err)
+	assert.NotEqual(t, cover.ETag, replaced.ETag)
+	assert.NoFileExists(t, filepath.Join(dir, "covers", eventID.String(), cover.ETag, "original"))
+
+	require.NoError(t, store.Delete(ctx, eventID))
+	entries, _ := os.ReadDir(filepath.Join(dir, "covers", eventID.String(), replaced.ETag))
+	assert.Empty(t, entries)
+	assert.ErrorIs(t, store.Delete(ctx, eventID), ErrCoverNotFound)
+}
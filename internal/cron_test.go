@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronNext(t *testing.T) {
+	madrid, _ := time.LoadLocation("Europe/Madrid")
+	// Tuesday
+	base := time.Date(2025, 9, 2, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"every minute", "* * * * *", base, time.Date(2025, 9, 2, 10, 8, 0, 0, time.UTC)},
+		{"step", "*/15 * * * *", base, time.Date(2025, 9, 2, 10, 15, 0, 0, time.UTC)},
+		{"daily rolls to tomorrow", "30 2 * * *", base, time.Date(2025, 9, 3, 2, 30, 0, 0, time.UTC)},
+		{"weekday names", "0 9 * * mon", base, time.Date(2025, 9, 8, 9, 0, 0, 0, time.UTC)},
+		{"business hours", "0 9-17/4 * * mon-fri", base, time.Date(2025, 9, 2, 13, 0, 0, 0, time.UTC)},
+		{"macro", "@monthly", base, time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{"sunday as 7", "0 0 * * 7", base, time.Date(2025, 9, 7, 0, 0, 0, 0, time.UTC)},
+		{"day of month or weekday", "0 0 15 * fri", base, time.Date(2025, 9, 5, 0, 0, 0, 0, time.UTC)},
+		{"leap day", "0 0 29 feb *", base, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"strictly after", "7 10 * * *", time.Date(2025, 9, 2, 10, 7, 0, 0, time.UTC), time.Date(2025, 9, 3, 10, 7, 0, 0, time.UTC)},
+		{"local timezone", "0 8 * * *", base.In(madrid), time.Date(2025, 9, 3, 8, 0, 0, 0, madrid)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCron(tt.expr)
+			assert.NoError(t, err)
+			assert.True(t, tt.want.Equal(c.Next(tt.from)), "got %s", c.Next(tt.from))
+		})
+	}
+}
+
+func TestCronNeverMatches(t *testing.T) {
+	c, err := ParseCron("0 0 30 2 *")
+	assert.NoError(t, err)
+	assert.True(t, c.Next(time.Now()).IsZero())
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "*/0 * * * *", "5-1 * * * *", "* * * * funday"} {
+		_, err := ParseCron(expr)
+		assert.Error(t, err, expr)
+	}
+}
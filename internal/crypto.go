@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix marks encrypted column values: "enc:<key version>:<base64 nonce+ciphertext>".
+// Values without it are legacy plaintext and are returned unchanged, so encryption can be
+// enabled on an existing database and rows converted later with the reencrypt command.
+const encryptedPrefix = "enc:"
+
+// FieldCipher encrypts individual column values with AES-256-GCM. It holds a keyring so
+// values written under retired keys stay readable while being re-encrypted.
+type FieldCipher struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewFieldCipher parses "version:base64key,..." where each key is 32 bytes. The first
+// entry is the primary key used for new writes; the rest are only used to decrypt.
+func NewFieldCipher(spec string) (*FieldCipher, error) {
+	fc := &FieldCipher{keys: map[string]cipher.AEAD{}}
+	for _, entry := range strings.Split(spec, ",") {
+		version, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || version == "" || strings.Contains(version, ":") {
+			return nil, fmt.Errorf("invalid key entry %q, want version:base64key", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s is not valid base64: %w", version, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %s must be 32 bytes, got %d", version, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := fc.keys[version]; dup {
+			return nil, fmt.Errorf("duplicate key version %s", version)
+		}
+		fc.keys[version] = aead
+		if fc.primary == "" {
+			fc.primary = version
+		}
+	}
+	if fc.primary == "" {
+		return nil, errors.New("no encryption keys configured")
+	}
+	return fc, nil
+}
+
+// Encrypt seals plaintext with the primary key
+func (fc *FieldCipher) Encrypt(plaintext string) (string, error) {
+	aead := fc.keys[fc.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	// The key version is bound as additional data so a value cannot be relabeled
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(fc.primary))
+	return encryptedPrefix + fc.primary + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt; plaintext values pass through unchanged
+func (fc *FieldCipher) Decrypt(value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	version, encoded, ok := strings.Cut(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if !ok {
+		return "", errors.New("malformed encrypted value")
+	}
+	aead, ok := fc.keys[version]
+	if !ok {
+		return "", fmt.Errorf("unknown encryption key version %s", version)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(version))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// sealedBlobMagic starts binary blobs produced by SealBytes
+const sealedBlobMagic = "TCENC1"
+
+// SealBytes encrypts a whole file, such as a backup, with the primary key. The output is
+// the magic, the key version length and version, then the nonce and ciphertext.
+func (fc *FieldCipher) SealBytes(plaintext []byte) ([]byte, error) {
+	aead := fc.keys[fc.primary]
+	out := append([]byte(sealedBlobMagic), byte(len(fc.primary)))
+	out = append(out, fc.primary...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, []byte(fc.primary)), nil
+}
+
+// OpenBytes decrypts a blob produced by SealBytes with any key of the keyring
+func (fc *FieldCipher) OpenBytes(blob []byte) ([]byte, error) {
+	rest, ok := bytes.CutPrefix(blob, []byte(sealedBlobMagic))
+	if !ok || len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return nil, errors.New("not an encrypted blob")
+	}
+	version := string(rest[1 : 1+int(rest[0])])
+	rest = rest[1+int(rest[0]):]
+	aead, ok := fc.keys[version]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key version %s", version)
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("malformed encrypted blob")
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(version))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt blob: %w", err)
+	}
+	return plaintext, nil
+}
+
+// NeedsRotation reports whether value is plaintext or sealed with a non-primary key
+func (fc *FieldCipher) NeedsRotation(value string) bool {
+	return !strings.HasPrefix(value, encryptedPrefix+fc.primary+":")
+}
+
+// encryptOptional encrypts a nullable column value
+func (fc *FieldCipher) encryptOptional(value *string) (*string, error) {
+	if fc == nil || value == nil {
+		return value, nil
+	}
+	sealed, err := fc.Encrypt(*value)
+	if err != nil {
+		return nil, err
+	}
+	return &sealed, nil
+}
+
+// decryptOptional decrypts a nullable column value in place
+func (fc *FieldCipher) decryptOptional(value *string) error {
+	if fc == nil || value == nil {
+		return nil
+	}
+	plaintext, err := fc.Decrypt(*value)
+	if err != nil {
+		return err
+	}
+	*value = plaintext
+	return nil
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCursor is returned for a page cursor not issued by the server
+var ErrInvalidCursor = newDomainError(ErrValidation, "invalid cursor")
+
+// TimeCursor marks the last row of a page of a list ordered by time and ID. The zero
+// cursor starts at the beginning of the list.
+type TimeCursor struct {
+	At time.Time
+	ID uuid.UUID
+}
+
+// String encodes the cursor as an opaque token
+func (c TimeCursor) String() string {
+	if c.ID == uuid.Nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(c.At.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()))
+}
+
+// IsZero reports whether the cursor is at the beginning of the list
+func (c TimeCursor) IsZero() bool {
+	return c.ID == uuid.Nil
+}
+
+// ParseTimeCursor decodes a token returned by TimeCursor.String; "" is the zero cursor
+func ParseTimeCursor(s string) (TimeCursor, error) {
+	if s == "" {
+		return TimeCursor{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return TimeCursor{}, ErrInvalidCursor
+	}
+	at, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return TimeCursor{}, ErrInvalidCursor
+	}
+	var c TimeCursor
+	if c.At, err = time.Parse(time.RFC3339Nano, at); err != nil {
+		return TimeCursor{}, ErrInvalidCursor
+	}
+	if c.ID, err = uuid.Parse(id); err != nil || c.ID == uuid.Nil {
+		return TimeCursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
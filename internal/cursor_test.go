@@ -0,0 +1,28 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeCursor(t *testing.T) {
+	c := TimeCursor{At: time.Date(2025, 9, 13, 10, 30, 0, 123456000, time.UTC), ID: uuid.New()}
+	parsed, err := ParseTimeCursor(c.String())
+	require.NoError(t, err)
+	assert.True(t, c.At.Equal(parsed.At))
+	assert.Equal(t, c.ID, parsed.ID)
+
+	zero, err := ParseTimeCursor("")
+	require.NoError(t, err)
+	assert.Equal(t, TimeCursor{}, zero)
+	assert.Equal(t, "", TimeCursor{}.String())
+
+	for _, bad := range []string{"!!!", "bm8tc2VwYXJhdG9y", "eHx5"} {
+		_, err := ParseTimeCursor(bad)
+		assert.ErrorIs(t, err, ErrValidation, bad)
+	}
+}
@@ -10,49 +10,185 @@ import (
 	"github.com/google/uuid"
 )
 
+// ErrEventNotFound is returned when an event does not exist
+var ErrEventNotFound = newDomainError(ErrNotFound, "event not found")
+
+// ErrExternalIDTaken is returned when creating an event with the external ID of another
+var ErrExternalIDTaken = newDomainError(ErrConflict, "an event with this source and external_id already exists")
+
 // Event: database struct from postgres
 type EventDB struct {
-	ID          uuid.UUID `json:"id" db:"id"`
-	Title       string    `json:"title" db:"title"`
-	Description *string   `json:"description" db:"description"`
-	StartTime   time.Time `json:"start_time" db:"start_time"`
-	EndTime     time.Time `json:"end_time" db:"end_time"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID uuid.UUID `json:"id" db:"id"`
+	// CalendarID is nil for events in the default calendar
+	CalendarID  *uuid.UUID `json:"calendar_id" db:"calendar_id"`
+	Title       string     `json:"title" db:"title"`
+	Description *string    `json:"description" db:"description"`
+	// DescriptionFormat is plain or markdown; the description itself is stored as raw source
+	DescriptionFormat string    `json:"description_format" db:"description_format"`
+	StartTime         time.Time `json:"start_time" db:"start_time"`
+	EndTime           time.Time `json:"end_time" db:"end_time"`
+	Location          *string   `json:"location,omitempty" db:"location"`
+	Latitude          *float64  `json:"latitude,omitempty" db:"latitude"`
+	Longitude         *float64  `json:"longitude,omitempty" db:"longitude"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+	// Version changes on every write; sync clients send it back to detect conflicts
+	Version int64 `json:"version" db:"version"`
+	// Status is pending, approved or rejected; only approved events are listed
+	Status string `json:"status" db:"status"`
+	// SubmittedBy is the user whose event awaits review
+	SubmittedBy string `json:"submitted_by,omitempty" db:"submitted_by"`
+	// PriceCents is the price of a ticket in the minor unit of Currency
+	PriceCents *int64  `json:"price_cents,omitempty" db:"price_cents"`
+	Currency   *string `json:"currency,omitempty" db:"currency"`
+	// TicketQuota is how many tickets can be reserved; nil for events without tickets
+	TicketQuota *int `json:"ticket_quota,omitempty" db:"ticket_quota"`
+	// ClientKey names the event for clients managing their calendar as code; it is set
+	// on create, never changes and is unique within the calendar
+	ClientKey *string `json:"client_key,omitempty" db:"client_key"`
+	// ExternalID is the event's ID in Source, the system it is synced from; imports
+	// update the event with the pair instead of adding another
+	ExternalID *string `json:"external_id,omitempty" db:"external_id"`
+	Source     *string `json:"source,omitempty" db:"source"`
+}
+
+// eventColumns is the column list matching scanEvent
+const eventColumns = `id, calendar_id, title, description, description_format, start_time, end_time, location, latitude, longitude, created_at, updated_at, version, status, submitted_by, price_cents, currency, ticket_quota, client_key, external_id, source`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// TimestampPrecision is the precision of PostgreSQL timestamps
+const TimestampPrecision = time.Microsecond
+
+// NormalizeTime returns t in UTC at the precision the database stores, without a
+// monotonic clock reading, so the timestamp read back is identical to the one written
+func NormalizeTime(t time.Time) time.Time {
+	return t.Round(TimestampPrecision).UTC()
+}
+
+// normalizeEventTimes applies NormalizeTime to the timestamps of event. Events are
+// normalized on their way in and out of the database, whatever zone the client sent
+// or the connection reads timestamps in.
+func normalizeEventTimes(event *EventDB) {
+	event.StartTime = NormalizeTime(event.StartTime)
+	event.EndTime = NormalizeTime(event.EndTime)
+	event.CreatedAt = NormalizeTime(event.CreatedAt)
+	event.UpdatedAt = NormalizeTime(event.UpdatedAt)
+}
+
+// scanEvent reads one row selected with eventColumns
+func scanEvent(row rowScanner, event *EventDB) error {
+	err := row.Scan(
+		&event.ID,
+		&event.CalendarID,
+		&event.Title,
+		&event.Description,
+		&event.DescriptionFormat,
+		&event.StartTime,
+		&event.EndTime,
+		&event.Location,
+		&event.Latitude,
+		&event.Longitude,
+		&event.CreatedAt,
+		&event.UpdatedAt,
+		&event.Version,
+		&event.Status,
+		&event.SubmittedBy,
+		&event.PriceCents,
+		&event.Currency,
+		&event.TicketQuota,
+		&event.ClientKey,
+		&event.ExternalID,
+		&event.Source,
+	)
+	if err != nil {
+		return err
+	}
+	normalizeEventTimes(event)
+	return nil
 }
 
 type EventRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	cipher  *FieldCipher
+	cascade *CascadeEngine
+}
+
+// NewEventRepository creates a new event repository.
+// When cipher is non-nil, description and location are encrypted at rest.
+func NewEventRepository(db *sql.DB, cipher *FieldCipher) *EventRepository {
+	return &EventRepository{db: db, cipher: cipher, cascade: NewCascadeEngine(db, nil)}
+}
+
+// SetCascade handles the dependents of deleted events by the policies of cascade
+// rather than the defaults
+func (r *EventRepository) SetCascade(cascade *CascadeEngine) {
+	r.cascade = cascade
 }
 
-// NewEventRepository creates a new event repository
-func NewEventRepository(db *sql.DB) *EventRepository {
-	return &EventRepository{db: db}
+// decryptEvent replaces encrypted column values with their plaintext
+func (r *EventRepository) decryptEvent(event *EventDB) error {
+	if err := r.cipher.decryptOptional(event.Description); err != nil {
+		return fmt.Errorf("description: %w", err)
+	}
+	if err := r.cipher.decryptOptional(event.Location); err != nil {
+		return fmt.Errorf("location: %w", err)
+	}
+	return nil
 }
 
 // CreateEvent inserts a new event into the database
 func (r *EventRepository) CreateEvent(ctx context.Context, event EventDB) (*EventDB, error) {
-	query := `
-		INSERT INTO events (title, description, start_time, end_time) 
-		VALUES ($1, $2, $3, $4) 
-		RETURNING id, title, description, start_time, end_time, created_at, updated_at`
+	normalizeEventTimes(&event)
+
+	// A nil ID lets the database default generate one
+	var id *uuid.UUID
+	if event.ID != uuid.Nil {
+		id = &event.ID
+	}
 
-	row := r.db.QueryRowContext(ctx, query, event.Title, event.Description, event.StartTime, event.EndTime)
+	format := event.DescriptionFormat
+	if format == "" {
+		format = DescriptionFormatPlain
+	}
+
+	description, err := r.cipher.encryptOptional(event.Description)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt description: %w", err)
+	}
+	location, err := r.cipher.encryptOptional(event.Location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt location: %w", err)
+	}
+
+	row := conn(ctx, r.db).QueryRowContext(ctx, qInsertEvent.SQL, id, event.Title, description, format, event.StartTime, event.EndTime,
+		location, event.Latitude, event.Longitude, event.CalendarID, event.Status, event.SubmittedBy,
+		event.PriceCents, event.Currency, event.TicketQuota, event.ClientKey, event.ExternalID, event.Source)
 
 	var createdEvent EventDB
-	err := row.Scan(
-		&createdEvent.ID,
-		&createdEvent.Title,
-		&createdEvent.Description,
-		&createdEvent.StartTime,
-		&createdEvent.EndTime,
-		&createdEvent.CreatedAt,
-		&createdEvent.UpdatedAt,
-	)
+	err = scanEvent(row, &createdEvent)
 
 	if err != nil {
+		if isForeignKeyViolation(err, "events_calendar_id_fkey") {
+			return nil, ErrUnknownCalendar
+		}
+		if isExclusionViolation(err, "events_exclusive_no_overlap") {
+			return nil, ErrEventOverlap
+		}
+		if isUniqueViolation(err, "events_client_key_unique") {
+			return nil, ErrClientKeyTaken
+		}
+		if isUniqueViolation(err, "events_source_external_id_unique") {
+			return nil, ErrExternalIDTaken
+		}
 		return nil, fmt.Errorf("failed to create event: %w", err)
 	}
+	if err := r.decryptEvent(&createdEvent); err != nil {
+		return nil, fmt.Errorf("failed to decrypt event: %w", err)
+	}
 
 	log.Printf("Event created successfully with ID: %s", createdEvent.ID)
 	return &createdEvent, nil
@@ -60,12 +196,37 @@ func (r *EventRepository) CreateEvent(ctx context.Context, event EventDB) (*Even
 
 // GetEvents retrieves all events from the database
 func (r *EventRepository) GetEvents(ctx context.Context) ([]EventDB, error) {
-	query := `
-		SELECT id, title, description, start_time, end_time, created_at, updated_at 
-		FROM events 
-		ORDER BY start_time ASC`
+	query, args := newSelect(qSelectEvents).OrderBy("start_time ASC").Build()
+	events, err := r.queryEvents(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Retrieved %d events", len(events))
+	return events, nil
+}
 
-	rows, err := r.db.QueryContext(ctx, query)
+// GetEventsBetween retrieves the events overlapping [from, to), ordered by start time
+func (r *EventRepository) GetEventsBetween(ctx context.Context, from, to time.Time) ([]EventDB, error) {
+	query, args := newSelect(qSelectEvents).
+		Where("start_time < ? AND end_time > ?", to, from).
+		OrderBy("start_time ASC").
+		Build()
+	return r.queryEvents(ctx, query, args...)
+}
+
+// GetEventsByCalendar retrieves the events of a calendar, ordered by start time
+func (r *EventRepository) GetEventsByCalendar(ctx context.Context, calendarID uuid.UUID) ([]EventDB, error) {
+	query, args := newSelect(qSelectEvents).
+		Where("calendar_id = ?", calendarID).
+		OrderBy("start_time ASC").
+		Build()
+	return r.queryEvents(ctx, query, args...)
+}
+
+// queryEvents runs a query selecting eventColumns and decrypts each row
+func (r *EventRepository) queryEvents(ctx context.Context, query string, args ...any) ([]EventDB, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query events: %w", err)
 	}
@@ -74,55 +235,336 @@ func (r *EventRepository) GetEvents(ctx context.Context) ([]EventDB, error) {
 	var events []EventDB
 	for rows.Next() {
 		var event EventDB
-		err := rows.Scan(
-			&event.ID,
-			&event.Title,
-			&event.Description,
-			&event.StartTime,
-			&event.EndTime,
-			&event.CreatedAt,
-			&event.UpdatedAt,
-		)
-		if err != nil {
+		if err := scanEvent(rows, &event); err != nil {
 			return nil, fmt.Errorf("failed to scan event: %w", err)
 		}
+		if err := r.decryptEvent(&event); err != nil {
+			return nil, fmt.Errorf("failed to decrypt event %s: %w", event.ID, err)
+		}
 		events = append(events, event)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating events: %w", err)
 	}
-
-	log.Printf("Retrieved %d events", len(events))
 	return events, nil
 }
 
+// GetEventsByExternalID retrieves the events synced with externalID, from source or,
+// when source is empty, from any system
+func (r *EventRepository) GetEventsByExternalID(ctx context.Context, source, externalID string) ([]EventDB, error) {
+	b := newSelect(qSelectEvents).Where("external_id = ?", externalID)
+	if source != "" {
+		b.Where("source = ?", source)
+	}
+	query, args := b.OrderBy("start_time ASC").Build()
+	return r.queryEvents(ctx, query, args...)
+}
+
+// EventsVersion summarizes a listing cheaply: any write to its events changes the
+// count or the latest update
+type EventsVersion struct {
+	Count     int
+	UpdatedAt time.Time
+}
+
+// EventsVersioner is implemented by repositories that can tell the version of a
+// listing without reading it
+type EventsVersioner interface {
+	EventsVersion(ctx context.Context, source, externalID string) (*EventsVersion, error)
+}
+
+// VersionEvents returns the version of a listing of repo, or nil when repo cannot tell
+func VersionEvents(ctx context.Context, repo EventRepositoryInterface, source, externalID string) (*EventsVersion, error) {
+	if v, ok := repo.(EventsVersioner); ok {
+		return v.EventsVersion(ctx, source, externalID)
+	}
+	return nil, nil
+}
+
+// EventsVersion returns the version of the listing GetEvents returns, or of the one
+// GetEventsByExternalID returns when externalID is set
+func (r *EventRepository) EventsVersion(ctx context.Context, source, externalID string) (*EventsVersion, error) {
+	b := newSelect(qEventsVersion)
+	if externalID != "" {
+		b.Where("external_id = ?", externalID)
+		if source != "" {
+			b.Where("source = ?", source)
+		}
+	}
+	query, args := b.Build()
+	var v EventsVersion
+	if err := conn(ctx, r.db).QueryRowContext(ctx, query, args...).Scan(&v.Count, &v.UpdatedAt); err != nil {
+		return nil, fmt.Errorf("failed to get events version: %w", err)
+	}
+	return &v, nil
+}
+
+// GetEventByClientKey retrieves the event of calendarID, or of no calendar when it is
+// nil, with clientKey
+func (r *EventRepository) GetEventByClientKey(ctx context.Context, calendarID *uuid.UUID, clientKey string) (*EventDB, error) {
+	calendar := uuid.Nil
+	if calendarID != nil {
+		calendar = *calendarID
+	}
+	// The condition matches events_client_key_unique so the index is used
+	query, args := newSelect(qSelectEvents).
+		Where("COALESCE(calendar_id, '00000000-0000-0000-0000-000000000000'::uuid) = ?", calendar).
+		Where("client_key = ?", clientKey).
+		Build()
+	events, err := r.queryEvents(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, ErrEventNotFound
+	}
+	return &events[0], nil
+}
+
 // GetEventByID retrieves a specific event by ID
 func (r *EventRepository) GetEventByID(ctx context.Context, id uuid.UUID) (*EventDB, error) {
-	query := `
-		SELECT id, title, description, start_time, end_time, created_at, updated_at 
-		FROM events 
-		WHERE id = $1`
-
-	row := r.db.QueryRowContext(ctx, query, id)
+	row := conn(ctx, r.db).QueryRowContext(ctx, qGetEvent.SQL, id)
 
 	var event EventDB
-	err := row.Scan(
-		&event.ID,
-		&event.Title,
-		&event.Description,
-		&event.StartTime,
-		&event.EndTime,
-		&event.CreatedAt,
-		&event.UpdatedAt,
-	)
+	err := scanEvent(row, &event)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("event not found")
+			return nil, ErrEventNotFound
 		}
 		return nil, fmt.Errorf("failed to get event by ID: %w", err)
 	}
+	if err := r.decryptEvent(&event); err != nil {
+		return nil, fmt.Errorf("failed to decrypt event %s: %w", event.ID, err)
+	}
 
 	return &event, nil
 }
+
+// UpdateEvent replaces the fields of an existing event; created_at is kept and the
+// updated_at trigger sets the modification time
+func (r *EventRepository) UpdateEvent(ctx context.Context, event EventDB) (*EventDB, error) {
+	normalizeEventTimes(&event)
+
+	format := event.DescriptionFormat
+	if format == "" {
+		format = DescriptionFormatPlain
+	}
+
+	description, err := r.cipher.encryptOptional(event.Description)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt description: %w", err)
+	}
+	location, err := r.cipher.encryptOptional(event.Location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt location: %w", err)
+	}
+
+	row := conn(ctx, r.db).QueryRowContext(ctx, qUpdateEvent.SQL, event.ID, event.Title, description, format, event.StartTime, event.EndTime,
+		location, event.Latitude, event.Longitude, event.CalendarID, event.Status, event.SubmittedBy,
+		event.PriceCents, event.Currency, event.TicketQuota, event.ExternalID, event.Source)
+
+	var updated EventDB
+	if err := scanEvent(row, &updated); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrEventNotFound
+		}
+		if isForeignKeyViolation(err, "events_calendar_id_fkey") {
+			return nil, ErrUnknownCalendar
+		}
+		if isExclusionViolation(err, "events_exclusive_no_overlap") {
+			return nil, ErrEventOverlap
+		}
+		// Moving an event moves its resource bookings
+		if isExclusionViolation(err, "resource_bookings_no_overlap") {
+			return nil, ErrResourceBooked
+		}
+		if isUniqueViolation(err, "events_source_external_id_unique") {
+			return nil, ErrExternalIDTaken
+		}
+		return nil, fmt.Errorf("failed to update event: %w", err)
+	}
+	if err := r.decryptEvent(&updated); err != nil {
+		return nil, fmt.Errorf("failed to decrypt event: %w", err)
+	}
+
+	log.Printf("Event updated successfully with ID: %s", updated.ID)
+	return &updated, nil
+}
+
+// DeleteEvent removes an event and handles its dependents by their delete policies. It
+// fails with a DependentsError when a policy forbids the delete.
+func (r *EventRepository) DeleteEvent(ctx context.Context, id uuid.UUID) error {
+	return NewTxManager(r.db).JoinTx(ctx, func(ctx context.Context) error {
+		if _, err := r.cascade.DeleteDependents(ctx, CascadeEvent, []string{id.String()}); err != nil {
+			return err
+		}
+		res, err := conn(ctx, r.db).ExecContext(ctx, qDeleteEvent.SQL, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete event: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrEventNotFound
+		}
+		return nil
+	})
+}
+
+// Ping checks that the database is reachable
+func (r *EventRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// ReencryptEvents rewrites description and location values that are plaintext or sealed
+// with a retired key, in batches, so old keys can be removed from ENCRYPTION_KEYS.
+// It returns the number of rows rewritten. Note that the updated_at trigger marks
+// rewritten rows as updated.
+func (r *EventRepository) ReencryptEvents(ctx context.Context, batchSize int) (int, error) {
+	if r.cipher == nil {
+		return 0, fmt.Errorf("encryption is not configured")
+	}
+
+	total := 0
+	var after uuid.UUID
+	for {
+		tx, err := r.db.BeginTx(ctx, nil)
+		if err != nil {
+			return total, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+
+		rows, err := tx.QueryContext(ctx, qLockEncryptedFields.SQL, after, batchSize)
+		if err != nil {
+			tx.Rollback()
+			return total, fmt.Errorf("failed to query events: %w", err)
+		}
+
+		type pending struct {
+			id                    uuid.UUID
+			description, location *string
+		}
+		var batch []pending
+		scanned := 0
+		for rows.Next() {
+			var p pending
+			if err := rows.Scan(&p.id, &p.description, &p.location); err != nil {
+				rows.Close()
+				tx.Rollback()
+				return total, fmt.Errorf("failed to scan event: %w", err)
+			}
+			scanned++
+			after = p.id
+			if (p.description != nil && r.cipher.NeedsRotation(*p.description)) ||
+				(p.location != nil && r.cipher.NeedsRotation(*p.location)) {
+				batch = append(batch, p)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			tx.Rollback()
+			return total, fmt.Errorf("error iterating events: %w", err)
+		}
+
+		for _, p := range batch {
+			if err := r.cipher.decryptOptional(p.description); err != nil {
+				tx.Rollback()
+				return total, fmt.Errorf("event %s description: %w", p.id, err)
+			}
+			if err := r.cipher.decryptOptional(p.location); err != nil {
+				tx.Rollback()
+				return total, fmt.Errorf("event %s location: %w", p.id, err)
+			}
+			description, err := r.cipher.encryptOptional(p.description)
+			if err != nil {
+				tx.Rollback()
+				return total, err
+			}
+			location, err := r.cipher.encryptOptional(p.location)
+			if err != nil {
+				tx.Rollback()
+				return total, err
+			}
+			if _, err := tx.ExecContext(ctx, qUpdateEncryptedFields.SQL, p.id, description, location); err != nil {
+				tx.Rollback()
+				return total, fmt.Errorf("failed to update event %s: %w", p.id, err)
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return total, fmt.Errorf("failed to commit batch: %w", err)
+		}
+		total += len(batch)
+		log.Printf("Re-encrypted %d events (%d total)", len(batch), total)
+
+		if scanned < batchSize {
+			return total, nil
+		}
+	}
+}
+
+// ImportEvents inserts events with their original IDs and timestamps in one transaction.
+// Events whose ID already exists are skipped, so a backup can be restored repeatedly.
+// Events with an external ID update the event synced from the same source and external
+// ID instead, so syncing another system again does not duplicate its events. It
+// returns the number of events inserted.
+func (r *EventRepository) ImportEvents(ctx context.Context, events []EventDB) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, qImportEvent.SQL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare import: %w", err)
+	}
+	defer stmt.Close()
+	upsert, err := tx.PrepareContext(ctx, qImportExternalEvent.SQL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare import: %w", err)
+	}
+	defer upsert.Close()
+
+	inserted := 0
+	for _, event := range events {
+		normalizeEventTimes(&event)
+		format := event.DescriptionFormat
+		if format == "" {
+			format = DescriptionFormatPlain
+		}
+		description, err := r.cipher.encryptOptional(event.Description)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encrypt description: %w", err)
+		}
+		location, err := r.cipher.encryptOptional(event.Location)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encrypt location: %w", err)
+		}
+
+		if event.ExternalID != nil {
+			var created bool
+			if err := upsert.QueryRowContext(ctx, event.ID, event.Title, description, format, event.StartTime, event.EndTime,
+				location, event.Latitude, event.Longitude, event.CreatedAt, event.UpdatedAt, event.CalendarID, event.Status, event.SubmittedBy,
+				event.ExternalID, event.Source).Scan(&created); err != nil {
+				return 0, fmt.Errorf("failed to import event %s: %w", event.ID, err)
+			}
+			if created {
+				inserted++
+			}
+			continue
+		}
+		res, err := stmt.ExecContext(ctx, event.ID, event.Title, description, format, event.StartTime, event.EndTime,
+			location, event.Latitude, event.Longitude, event.CreatedAt, event.UpdatedAt, event.CalendarID, event.Status, event.SubmittedBy)
+		if err != nil {
+			return 0, fmt.Errorf("failed to import event %s: %w", event.ID, err)
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			inserted++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit import: %w", err)
+	}
+	return inserted, nil
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"testing"
+	"testing/quick"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -213,6 +216,57 @@ func TestGetEventByID(t *testing.T) {
 	}
 }
 
+// randomInstant is a timestamp between 1970 and 2100 with nanoseconds, in a random zone
+// such as clients send or a connection reads timestamps in
+func randomInstant(rnd *rand.Rand) time.Time {
+	zones := []*time.Location{time.UTC, time.FixedZone("", -3*3600), time.FixedZone("", 5*3600+45*60), time.FixedZone("", -9*3600-30*60)}
+	if loc, err := time.LoadLocation("America/Argentina/Buenos_Aires"); err == nil {
+		zones = append(zones, loc)
+	}
+	return time.Unix(rnd.Int63n(4102444800), rnd.Int63n(int64(time.Second))).In(zones[rnd.Intn(len(zones))])
+}
+
+// postgresRoundTrip writes t as lib/pq sends it, stores it at the precision of a
+// timestamptz column and reads it back as lib/pq does from a session in zone
+func postgresRoundTrip(t time.Time, zone *time.Location) (time.Time, error) {
+	sent, err := time.Parse("2006-01-02 15:04:05.999999999Z07:00", string(pq.FormatTimestamp(t)))
+	if err != nil {
+		return time.Time{}, err
+	}
+	stored := sent.Round(time.Microsecond)
+	return pq.ParseTimestamp(zone, stored.In(zone).Format("2006-01-02 15:04:05.999999-07:00"))
+}
+
+func TestNormalizeTimeRoundTripsThroughDatabase(t *testing.T) {
+	property := func(seed int64) bool {
+		rnd := rand.New(rand.NewSource(seed))
+		written := NormalizeTime(randomInstant(rnd))
+		read, err := postgresRoundTrip(written, randomInstant(rnd).Location())
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		// Identical, not only the same instant: same zone and no drift
+		return NormalizeTime(read) == written && written.Location() == time.UTC
+	}
+	assert.NoError(t, quick.Check(property, &quick.Config{MaxCount: 2000}))
+}
+
+func TestNormalizeTimeIsIdempotent(t *testing.T) {
+	property := func(seed int64) bool {
+		rnd := rand.New(rand.NewSource(seed))
+		once := NormalizeTime(randomInstant(rnd))
+		return NormalizeTime(once) == once && once.Nanosecond()%1000 == 0
+	}
+	assert.NoError(t, quick.Check(property, nil))
+}
+
+func TestNormalizeTimeStripsMonotonicReading(t *testing.T) {
+	now := time.Now()
+	assert.Contains(t, now.String(), "m=")
+	assert.NotContains(t, NormalizeTime(now).String(), "m=")
+}
+
 // Helper function to create string pointers
 func stringPtr(s string) *string {
 	return &s
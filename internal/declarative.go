@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// Actions of a declarative sync plan, in the order they are applied. Deletes go first
+// so an exclusive calendar can swap events without overlapping them.
+const (
+	PlanDelete = "delete"
+	PlanUpdate = "update"
+	PlanCreate = "create"
+)
+
+// ErrClientKeyTaken is returned when another event of the calendar has the client key
+var ErrClientKeyTaken = newDomainError(ErrConflict, "another event of the calendar has this client_key")
+
+// PlannedChange is one write of a declarative sync
+type PlannedChange struct {
+	Action    string `json:"action"`
+	ClientKey string `json:"client_key"`
+	// EventID is unset for the creates of dry runs
+	EventID *uuid.UUID `json:"event_id,omitempty"`
+	// Changes are the fields an update changes
+	Changes []FieldChange `json:"changes,omitempty"`
+	// event is what is written: the declared event for creates, the current one with
+	// the declared fields for updates
+	event EventDB
+}
+
+// DeclarativePlan is the diff between the events of a calendar that carry a client key
+// and the full set a client declares. Events without a client key are not managed, so
+// they are left alone.
+type DeclarativePlan struct {
+	Changes   []PlannedChange `json:"changes"`
+	Create    int             `json:"create"`
+	Update    int             `json:"update"`
+	Delete    int             `json:"delete"`
+	Unchanged int             `json:"unchanged"`
+	// Applied is false for dry runs
+	Applied bool `json:"applied"`
+}
+
+// PlanDeclarativeSync compares the current events of a calendar with the declared ones,
+// which must all carry distinct client keys, and IDs unless the plan is a dry run.
+// Declared events that are not current are created, current events that are not
+// declared are deleted, and the others are updated when a declared field differs. A
+// declared Status, set for writers whose edits need review, only applies to the
+// events the plan writes.
+func PlanDeclarativeSync(current, declared []EventDB) (*DeclarativePlan, error) {
+	byKey := map[string]EventDB{}
+	for _, e := range current {
+		if e.ClientKey != nil {
+			byKey[*e.ClientKey] = e
+		}
+	}
+
+	plan := &DeclarativePlan{Changes: []PlannedChange{}}
+	seen := map[string]bool{}
+	for _, want := range declared {
+		if want.ClientKey == nil || *want.ClientKey == "" {
+			return nil, newDomainError(ErrValidation, "every event needs a client_key")
+		}
+		key := *want.ClientKey
+		if seen[key] {
+			return nil, newDomainError(ErrValidation, fmt.Sprintf("client_key %s is declared twice", key))
+		}
+		seen[key] = true
+		if want.DescriptionFormat == "" {
+			want.DescriptionFormat = DescriptionFormatPlain
+		}
+
+		have, ok := byKey[key]
+		if !ok {
+			change := PlannedChange{Action: PlanCreate, ClientKey: key, event: want}
+			if want.ID != uuid.Nil {
+				change.EventID = &want.ID
+			}
+			plan.Changes = append(plan.Changes, change)
+			continue
+		}
+		merged := have
+		merged.Title = want.Title
+		merged.Description = want.Description
+		merged.DescriptionFormat = want.DescriptionFormat
+		merged.StartTime = want.StartTime
+		merged.EndTime = want.EndTime
+		merged.Location = want.Location
+		merged.Latitude = want.Latitude
+		merged.Longitude = want.Longitude
+		merged.PriceCents = want.PriceCents
+		merged.Currency = want.Currency
+		merged.TicketQuota = want.TicketQuota
+		changes := DiffEvents(have, merged)
+		if len(changes) == 0 {
+			plan.Unchanged++
+			continue
+		}
+		merged.Status, merged.SubmittedBy = want.Status, want.SubmittedBy
+		plan.Changes = append(plan.Changes, PlannedChange{Action: PlanUpdate, ClientKey: key, EventID: &have.ID, Changes: changes, event: merged})
+	}
+	for key, have := range byKey {
+		if !seen[key] {
+			plan.Changes = append(plan.Changes, PlannedChange{Action: PlanDelete, ClientKey: key, EventID: &have.ID, event: have})
+		}
+	}
+
+	order := map[string]int{PlanDelete: 0, PlanUpdate: 1, PlanCreate: 2}
+	sort.Slice(plan.Changes, func(i, j int) bool {
+		a, b := plan.Changes[i], plan.Changes[j]
+		if a.Action != b.Action {
+			return order[a.Action] < order[b.Action]
+		}
+		return a.ClientKey < b.ClientKey
+	})
+	for _, c := range plan.Changes {
+		switch c.Action {
+		case PlanCreate:
+			plan.Create++
+		case PlanUpdate:
+			plan.Update++
+		case PlanDelete:
+			plan.Delete++
+		}
+	}
+	return plan, nil
+}
+
+// Apply makes the writes of the plan through events, stopping at the first failure.
+// Callers run it in a transaction so a failed plan leaves the calendar as it was.
+func (p *DeclarativePlan) Apply(ctx context.Context, events EventRepositoryInterface) error {
+	for _, c := range p.Changes {
+		var err error
+		switch c.Action {
+		case PlanCreate:
+			_, err = events.CreateEvent(ctx, c.event)
+		case PlanUpdate:
+			_, err = events.UpdateEvent(ctx, c.event)
+		case PlanDelete:
+			err = events.DeleteEvent(ctx, c.event.ID)
+		}
+		if err != nil {
+			if msg := DomainMessage(err); msg != "" {
+				return newDomainError(KindOf(err), fmt.Sprintf("%s of %s failed: %s", c.Action, c.ClientKey, msg))
+			}
+			return fmt.Errorf("failed to %s %s: %w", c.Action, c.ClientKey, err)
+		}
+	}
+	p.Applied = true
+	return nil
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanDeclarativeSync(t *testing.T) {
+	calendar := uuid.New()
+	start := time.Date(2025, 10, 6, 9, 0, 0, 0, time.UTC)
+	key := func(k string) *string { return &k }
+	event := func(k, title string) EventDB {
+		return EventDB{ID: uuid.New(), CalendarID: &calendar, ClientKey: key(k), Title: title, DescriptionFormat: DescriptionFormatPlain, StartTime: start, EndTime: start.Add(time.Hour)}
+	}
+	standup, retro, manual := event("standup", "Standup"), event("retro", "Retro"), event("", "Lunch")
+	manual.ClientKey = nil
+	current := []EventDB{standup, retro, manual}
+
+	same := standup
+	same.ID, same.DescriptionFormat = uuid.New(), ""
+	renamed := event("retro", "Sprint retro")
+	added := event("planning", "Planning")
+	plan, err := PlanDeclarativeSync(current, []EventDB{same, renamed, added})
+	require.NoError(t, err)
+	assert.Equal(t, 1, plan.Create)
+	assert.Equal(t, 1, plan.Update)
+	assert.Equal(t, 0, plan.Delete)
+	assert.Equal(t, 1, plan.Unchanged)
+	require.Len(t, plan.Changes, 2)
+	assert.Equal(t, PlanUpdate, plan.Changes[0].Action)
+	assert.Equal(t, retro.ID, *plan.Changes[0].EventID)
+	assert.Equal(t, []FieldChange{{Field: "title", Old: "Retro", New: "Sprint retro"}}, plan.Changes[0].Changes)
+	assert.Equal(t, PlanCreate, plan.Changes[1].Action)
+
+	// Undeclared keyed events are deleted first; events without a key are not managed
+	events := &writeRecorder{created: append([]EventDB(nil), current...)}
+	plan, err = PlanDeclarativeSync(current, []EventDB{added})
+	require.NoError(t, err)
+	require.Len(t, plan.Changes, 3)
+	assert.Equal(t, []string{PlanDelete, PlanDelete, PlanCreate}, []string{plan.Changes[0].Action, plan.Changes[1].Action, plan.Changes[2].Action})
+	require.NoError(t, plan.Apply(context.Background(), events))
+	assert.True(t, plan.Applied)
+	assert.ElementsMatch(t, []uuid.UUID{standup.ID, retro.ID}, events.deleted)
+
+	_, err = PlanDeclarativeSync(current, []EventDB{added, added})
+	assert.ErrorIs(t, err, ErrValidation)
+	_, err = PlanDeclarativeSync(current, []EventDB{manual})
+	assert.ErrorIs(t, err, ErrValidation)
+}
@@ -0,0 +1,270 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Permissions a calendar's owner can delegate on its events
+const (
+	DelegateCreate = "create"
+	DelegateEdit   = "edit"
+	DelegateDelete = "delete"
+)
+
+// ErrDelegateNotFound is returned when a user was not delegated a calendar
+var ErrDelegateNotFound = newDomainError(ErrNotFound, "delegate not found")
+
+// ErrCalendarForbidden is returned when the caller may not write an event in its calendar
+var ErrCalendarForbidden = newDomainError(ErrForbidden, "not allowed to change events in this calendar")
+
+// Delegate is a grant letting a user other than its owner write the events of a
+// calendar, with the listed permissions, until ExpiresAt when set
+type Delegate struct {
+	CalendarID  uuid.UUID  `json:"calendar_id"`
+	UserID      string     `json:"user_id"`
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	GrantedBy   string     `json:"granted_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Allows reports whether the grant gives permission at now
+func (d Delegate) Allows(permission string, now time.Time) bool {
+	if d.ExpiresAt != nil && !now.Before(*d.ExpiresAt) {
+		return false
+	}
+	for _, p := range d.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateDelegate returns a message describing why d is invalid at now, or "" if it
+// is valid. Repeated permissions are removed.
+func ValidateDelegate(d *Delegate, now time.Time) string {
+	if d.UserID == "" || len(d.UserID) > 255 {
+		return "user_id is required and must be <= 255 characters"
+	}
+	if len(d.Permissions) == 0 {
+		return "permissions must list create, edit or delete"
+	}
+	seen := map[string]bool{}
+	permissions := make([]string, 0, len(d.Permissions))
+	for _, p := range d.Permissions {
+		if p != DelegateCreate && p != DelegateEdit && p != DelegateDelete {
+			return "permissions must list create, edit or delete"
+		}
+		if !seen[p] {
+			seen[p] = true
+			permissions = append(permissions, p)
+		}
+	}
+	d.Permissions = permissions
+	if d.ExpiresAt != nil && !d.ExpiresAt.After(now) {
+		return "expires_at must be in the future"
+	}
+	return ""
+}
+
+type DelegateRepository struct {
+	db *sql.DB
+}
+
+// NewDelegateRepository creates a new delegate repository
+func NewDelegateRepository(db *sql.DB) *DelegateRepository {
+	return &DelegateRepository{db: db}
+}
+
+const delegateColumns = `calendar_id, user_id, permissions, expires_at, granted_by, created_at, updated_at`
+
+func scanDelegate(row rowScanner, d *Delegate) error {
+	return row.Scan(&d.CalendarID, &d.UserID, pq.Array(&d.Permissions), &d.ExpiresAt, &d.GrantedBy, &d.CreatedAt, &d.UpdatedAt)
+}
+
+// SetDelegate grants a user a calendar, replacing the permissions and expiry of an
+// earlier grant
+func (r *DelegateRepository) SetDelegate(ctx context.Context, d Delegate) (*Delegate, error) {
+	query := `
+		INSERT INTO calendar_delegates (calendar_id, user_id, permissions, expires_at, granted_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (calendar_id, user_id) DO UPDATE
+		SET permissions = EXCLUDED.permissions, expires_at = EXCLUDED.expires_at, granted_by = EXCLUDED.granted_by
+		RETURNING ` + delegateColumns
+
+	var set Delegate
+	row := conn(ctx, r.db).QueryRowContext(ctx, query, d.CalendarID, d.UserID, pq.Array(d.Permissions), d.ExpiresAt, d.GrantedBy)
+	if err := scanDelegate(row, &set); err != nil {
+		if isForeignKeyViolation(err, "calendar_delegates_calendar_id_fkey") {
+			return nil, ErrCalendarNotFound
+		}
+		return nil, fmt.Errorf("failed to set delegate: %w", err)
+	}
+	return &set, nil
+}
+
+// GetDelegate returns the grant of a calendar to userID, expired or not
+func (r *DelegateRepository) GetDelegate(ctx context.Context, calendarID uuid.UUID, userID string) (*Delegate, error) {
+	var d Delegate
+	query := `SELECT ` + delegateColumns + ` FROM calendar_delegates WHERE calendar_id = $1 AND user_id = $2`
+	if err := scanDelegate(conn(ctx, r.db).QueryRowContext(ctx, query, calendarID, userID), &d); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDelegateNotFound
+		}
+		return nil, fmt.Errorf("failed to get delegate: %w", err)
+	}
+	return &d, nil
+}
+
+// ListDelegates returns the grants of a calendar, expired ones included, by user
+func (r *DelegateRepository) ListDelegates(ctx context.Context, calendarID uuid.UUID) ([]Delegate, error) {
+	query := `SELECT ` + delegateColumns + ` FROM calendar_delegates WHERE calendar_id = $1 ORDER BY user_id`
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, calendarID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delegates: %w", err)
+	}
+	defer rows.Close()
+
+	delegates := []Delegate{}
+	for rows.Next() {
+		var d Delegate
+		if err := scanDelegate(rows, &d); err != nil {
+			return nil, fmt.Errorf("failed to scan delegate: %w", err)
+		}
+		delegates = append(delegates, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating delegates: %w", err)
+	}
+	return delegates, nil
+}
+
+// DeleteDelegate revokes the grant of a calendar to userID
+func (r *DelegateRepository) DeleteDelegate(ctx context.Context, calendarID uuid.UUID, userID string) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM calendar_delegates WHERE calendar_id = $1 AND user_id = $2`, calendarID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete delegate: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrDelegateNotFound
+	}
+	return nil
+}
+
+// CalendarAccess decides who may write the events of a calendar: its owner, the admins
+// of the organization owning it, and the users it was delegated to with the permission
+// for the write. The API key, deployments without authentication and calendars
+// without an owner are not restricted, and neither are events without a calendar.
+type CalendarAccess struct {
+	calendars CalendarRepositoryInterface
+	orgs      OrganizationRepositoryInterface
+	delegates DelegateRepositoryInterface
+	events    EventRepositoryInterface
+	now       func() time.Time
+}
+
+// NewCalendarAccess checks writes against the calendars, organizations and grants
+// stored in the repositories; orgs and delegates may be nil. events reads the current
+// calendar of updated and deleted events.
+func NewCalendarAccess(calendars CalendarRepositoryInterface, orgs OrganizationRepositoryInterface, delegates DelegateRepositoryInterface, events EventRepositoryInterface) *CalendarAccess {
+	return &CalendarAccess{calendars: calendars, orgs: orgs, delegates: delegates, events: events, now: time.Now}
+}
+
+// Register checks every create, update and delete of an event. Moving an event to
+// another calendar needs the edit permission on its calendar and create on the other.
+func (a *CalendarAccess) Register(hooks *EventHooks) {
+	hooks.BeforeCreate(func(ctx context.Context, event *EventDB) error {
+		return a.Check(ctx, event.CalendarID, DelegateCreate)
+	})
+	hooks.BeforeUpdate(func(ctx context.Context, event *EventDB) error {
+		current, err := a.current(ctx, event.ID)
+		if err != nil || current == nil {
+			return err
+		}
+		if err := a.Check(ctx, current.CalendarID, DelegateEdit); err != nil {
+			return err
+		}
+		if !sameCalendar(current.CalendarID, event.CalendarID) {
+			return a.Check(ctx, event.CalendarID, DelegateCreate)
+		}
+		return nil
+	})
+	hooks.BeforeDelete(func(ctx context.Context, id uuid.UUID) error {
+		current, err := a.current(ctx, id)
+		if err != nil || current == nil {
+			return err
+		}
+		return a.Check(ctx, current.CalendarID, DelegateDelete)
+	})
+}
+
+// Check returns ErrCalendarForbidden unless the caller may write the events of
+// calendarID with permission. Unknown calendars are left to the write to reject.
+func (a *CalendarAccess) Check(ctx context.Context, calendarID *uuid.UUID, permission string) error {
+	p := PrincipalFromContext(ctx)
+	if p == nil || p.Admin || calendarID == nil {
+		return nil
+	}
+	c, err := a.calendars.GetCalendar(ctx, *calendarID)
+	if errors.Is(err, ErrCalendarNotFound) {
+		return nil
+	}
+	if err != nil {
+		return HookInternalError(err)
+	}
+	if c.OwnerID == "" || c.OwnerID == p.UserID {
+		return nil
+	}
+	if c.OrganizationID != nil && a.orgs != nil {
+		m, err := a.orgs.GetMembership(ctx, *c.OrganizationID, p.UserID)
+		if err == nil && RoleAtLeast(m.Role, OrgRoleAdmin) {
+			return nil
+		}
+		if err != nil && !errors.Is(err, ErrMemberNotFound) {
+			return HookInternalError(err)
+		}
+	}
+	if a.delegates == nil {
+		return ErrCalendarForbidden
+	}
+	d, err := a.delegates.GetDelegate(ctx, c.ID, p.UserID)
+	if errors.Is(err, ErrDelegateNotFound) {
+		return ErrCalendarForbidden
+	}
+	if err != nil {
+		return HookInternalError(err)
+	}
+	if !d.Allows(permission, a.now()) {
+		return ErrCalendarForbidden
+	}
+	return nil
+}
+
+// current returns the stored event, or nil when it does not exist so the write can
+// report it missing
+func (a *CalendarAccess) current(ctx context.Context, id uuid.UUID) (*EventDB, error) {
+	event, err := a.events.GetEventByID(ctx, id)
+	if errors.Is(err, ErrEventNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, HookInternalError(err)
+	}
+	return event, nil
+}
+
+func sameCalendar(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelegateAllows(t *testing.T) {
+	now := time.Date(2025, 9, 25, 12, 0, 0, 0, time.UTC)
+	expires := now.Add(time.Hour)
+	d := Delegate{Permissions: []string{DelegateCreate, DelegateEdit}, ExpiresAt: &expires}
+	assert.True(t, d.Allows(DelegateEdit, now))
+	assert.False(t, d.Allows(DelegateDelete, now))
+	assert.False(t, d.Allows(DelegateEdit, expires))
+	assert.True(t, Delegate{Permissions: []string{DelegateDelete}}.Allows(DelegateDelete, now))
+}
+
+func TestValidateDelegate(t *testing.T) {
+	now := time.Date(2025, 9, 25, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Minute)
+	tests := []struct {
+		name string
+		d    Delegate
+		want string
+	}{
+		{"valid", Delegate{UserID: "bob", Permissions: []string{DelegateCreate}}, ""},
+		{"missing user", Delegate{Permissions: []string{DelegateCreate}}, "user_id is required and must be <= 255 characters"},
+		{"no permissions", Delegate{UserID: "bob"}, "permissions must list create, edit or delete"},
+		{"unknown permission", Delegate{UserID: "bob", Permissions: []string{"share"}}, "permissions must list create, edit or delete"},
+		{"expired", Delegate{UserID: "bob", Permissions: []string{DelegateEdit}, ExpiresAt: &past}, "expires_at must be in the future"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ValidateDelegate(&tt.d, now))
+		})
+	}
+
+	d := Delegate{UserID: "bob", Permissions: []string{DelegateEdit, DelegateCreate, DelegateEdit}}
+	assert.Empty(t, ValidateDelegate(&d, now))
+	assert.Equal(t, []string{DelegateEdit, DelegateCreate}, d.Permissions)
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"reflect"
+	"time"
+)
+
+// FieldChange is one field an update changed, with its JSON name and the values
+// before and after. Old and New are left out for description and location, which may
+// be long and are encrypted at rest; fetch the event for them.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old,omitempty"`
+	New   any    `json:"new,omitempty"`
+}
+
+// DiffEvents returns the fields that differ between two revisions of an event, in
+// the order of the event's JSON. Bookkeeping fields such as version and updated_at are
+// not compared.
+func DiffEvents(before, after EventDB) []FieldChange {
+	changes := []FieldChange{}
+	add := func(field string, old, new any) {
+		if !reflect.DeepEqual(old, new) {
+			changes = append(changes, FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+	addHidden := func(field string, old, new *string) {
+		if !reflect.DeepEqual(old, new) {
+			changes = append(changes, FieldChange{Field: field})
+		}
+	}
+
+	add("calendar_id", optional(before.CalendarID), optional(after.CalendarID))
+	add("title", before.Title, after.Title)
+	addHidden("description", before.Description, after.Description)
+	add("description_format", before.DescriptionFormat, after.DescriptionFormat)
+	add("start_time", before.StartTime.UTC().Format(time.RFC3339), after.StartTime.UTC().Format(time.RFC3339))
+	add("end_time", before.EndTime.UTC().Format(time.RFC3339), after.EndTime.UTC().Format(time.RFC3339))
+	addHidden("location", before.Location, after.Location)
+	add("latitude", optional(before.Latitude), optional(after.Latitude))
+	add("longitude", optional(before.Longitude), optional(after.Longitude))
+	add("status", before.Status, after.Status)
+	add("price_cents", optional(before.PriceCents), optional(after.PriceCents))
+	add("currency", optional(before.Currency), optional(after.Currency))
+	add("ticket_quota", optional(before.TicketQuota), optional(after.TicketQuota))
+	return changes
+}
+
+// optional dereferences p so unset and set values compare and marshal by value
+func optional[T any](p *T) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffEvents(t *testing.T) {
+	start := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+	description, location, quota := "Agenda", "Room 1", 20
+	before := EventDB{ID: uuid.New(), Title: "Standup", Description: &description, Location: &location, StartTime: start, EndTime: start.Add(15 * time.Minute), Version: 1}
+
+	// Rewriting the same revision changes nothing, whatever the time zone and version
+	same := before
+	same.StartTime = start.In(time.FixedZone("CEST", 2*60*60))
+	same.Version = 2
+	assert.Empty(t, DiffEvents(before, same))
+
+	newDescription := "New agenda"
+	after := before
+	after.Title = "Daily standup"
+	after.Description = &newDescription
+	after.Location = nil
+	after.EndTime = start.Add(30 * time.Minute)
+	after.TicketQuota = &quota
+	assert.Equal(t, []FieldChange{
+		{Field: "title", Old: "Standup", New: "Daily standup"},
+		{Field: "description"},
+		{Field: "end_time", Old: "2025-09-15T09:15:00Z", New: "2025-09-15T09:30:00Z"},
+		{Field: "location"},
+		{Field: "ticket_quota", New: 20},
+	}, DiffEvents(before, after))
+}
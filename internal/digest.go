@@ -0,0 +1,292 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// JobWeeklyDigest is the scheduler job that emails subscribers their coming week
+const JobWeeklyDigest = "weekly_digest"
+
+// ErrDigestSubscriptionNotFound is returned when a user has no digest subscription
+var ErrDigestSubscriptionNotFound = newDomainError(ErrNotFound, "digest subscription not found")
+
+// DigestSubscription holds a user's weekly digest settings
+type DigestSubscription struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	// Timezone and Language control how the week is cut and how dates are written
+	Timezone   string     `json:"timezone"`
+	Language   string     `json:"language"`
+	Enabled    bool       `json:"enabled"`
+	LastSentAt *time.Time `json:"last_sent_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type DigestRepository struct {
+	db *sql.DB
+}
+
+// NewDigestRepository creates a new digest subscription repository
+func NewDigestRepository(db *sql.DB) *DigestRepository {
+	return &DigestRepository{db: db}
+}
+
+const digestColumns = `user_id, email, timezone, language, enabled, last_sent_at, created_at, updated_at`
+
+func scanDigestSubscription(row rowScanner, sub *DigestSubscription) error {
+	return row.Scan(&sub.UserID, &sub.Email, &sub.Timezone, &sub.Language, &sub.Enabled, &sub.LastSentAt, &sub.CreatedAt, &sub.UpdatedAt)
+}
+
+// GetDigestSubscription returns a user's subscription
+func (r *DigestRepository) GetDigestSubscription(ctx context.Context, userID string) (*DigestSubscription, error) {
+	query := `SELECT ` + digestColumns + ` FROM digest_subscriptions WHERE user_id = $1`
+
+	var sub DigestSubscription
+	if err := scanDigestSubscription(traced(ctx, r.db).QueryRowContext(ctx, query, userID), &sub); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDigestSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("failed to get digest subscription: %w", err)
+	}
+	return &sub, nil
+}
+
+// SaveDigestSubscription creates or replaces a user's subscription settings
+func (r *DigestRepository) SaveDigestSubscription(ctx context.Context, sub DigestSubscription) (*DigestSubscription, error) {
+	query := `
+		INSERT INTO digest_subscriptions (user_id, email, timezone, language, enabled)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE
+		SET email = EXCLUDED.email, timezone = EXCLUDED.timezone, language = EXCLUDED.language,
+			enabled = EXCLUDED.enabled, updated_at = NOW()
+		RETURNING ` + digestColumns
+
+	var saved DigestSubscription
+	row := traced(ctx, r.db).QueryRowContext(ctx, query, sub.UserID, sub.Email, sub.Timezone, sub.Language, sub.Enabled)
+	if err := scanDigestSubscription(row, &saved); err != nil {
+		return nil, fmt.Errorf("failed to save digest subscription: %w", err)
+	}
+	return &saved, nil
+}
+
+// DeleteDigestSubscription unsubscribes a user
+func (r *DigestRepository) DeleteDigestSubscription(ctx context.Context, userID string) error {
+	res, err := traced(ctx, r.db).ExecContext(ctx, `DELETE FROM digest_subscriptions WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete digest subscription: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrDigestSubscriptionNotFound
+	}
+	return nil
+}
+
+// PendingDigestSubscriptions returns enabled subscriptions not sent to since before
+func (r *DigestRepository) PendingDigestSubscriptions(ctx context.Context, before time.Time) ([]DigestSubscription, error) {
+	query := `
+		SELECT ` + digestColumns + `
+		FROM digest_subscriptions
+		WHERE enabled AND (last_sent_at IS NULL OR last_sent_at < $1)
+		ORDER BY user_id`
+
+	rows, err := traced(ctx, r.db).QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query digest subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []DigestSubscription{}
+	for rows.Next() {
+		var sub DigestSubscription
+		if err := scanDigestSubscription(rows, &sub); err != nil {
+			return nil, fmt.Errorf("failed to scan digest subscription: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating digest subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+// MarkDigestSent records a successful delivery
+func (r *DigestRepository) MarkDigestSent(ctx context.Context, userID string, at time.Time) error {
+	_, err := traced(ctx, r.db).ExecContext(ctx, `UPDATE digest_subscriptions SET last_sent_at = $2 WHERE user_id = $1`, userID, at)
+	if err != nil {
+		return fmt.Errorf("failed to mark digest sent: %w", err)
+	}
+	return nil
+}
+
+// digestResend is the minimum gap between two digests to the same user, so a retried
+// or duplicated job run does not email people twice
+const digestResend = 24 * time.Hour
+
+// WeeklyDigestJob returns a job that emails every pending subscriber the events of the
+// seven days starting today in their timezone. Subscribers with nothing coming up that
+// week are not emailed.
+func WeeklyDigestJob(events EventRepositoryInterface, digests DigestRepositoryInterface, notifier Notifier) JobFunc {
+	return func(ctx context.Context, _ json.RawMessage) (string, error) {
+		return runWeeklyDigest(ctx, events, digests, notifier, time.Now())
+	}
+}
+
+func runWeeklyDigest(ctx context.Context, events EventRepositoryInterface, digests DigestRepositoryInterface, notifier Notifier, now time.Time) (string, error) {
+	subs, err := digests.PendingDigestSubscriptions(ctx, now.Add(-digestResend))
+	if err != nil {
+		return "", err
+	}
+
+	sent, skipped, failed := 0, 0, 0
+	for _, sub := range subs {
+		ok, err := sendDigest(ctx, events, notifier, sub, now)
+		if err != nil {
+			log.Printf("Error sending digest to %s: %v", sub.UserID, err)
+			failed++
+			continue
+		}
+		if !ok {
+			skipped++
+			continue
+		}
+		if err := digests.MarkDigestSent(ctx, sub.UserID, now); err != nil {
+			log.Printf("Error recording digest for %s: %v", sub.UserID, err)
+		}
+		sent++
+	}
+
+	output := fmt.Sprintf("sent %d digests, %d empty, %d failed", sent, skipped, failed)
+	if failed > 0 {
+		return output, fmt.Errorf("%d of %d digests failed", failed, len(subs))
+	}
+	return output, nil
+}
+
+// sendDigest emails sub the published events of its coming week; it reports false
+// without sending when there are none
+func sendDigest(ctx context.Context, events EventRepositoryInterface, notifier Notifier, sub DigestSubscription, now time.Time) (bool, error) {
+	loc, err := time.LoadLocation(sub.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	from := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	to := from.AddDate(0, 0, 7)
+
+	between, err := events.GetEventsBetween(ctx, from, to)
+	if err != nil {
+		return false, err
+	}
+	upcoming := between[:0:0]
+	for _, e := range between {
+		if e.Published() {
+			upcoming = append(upcoming, e)
+		}
+	}
+	if len(upcoming) == 0 {
+		return false, nil
+	}
+	n, err := ComposeDigest(sub, from, upcoming)
+	if err != nil {
+		return false, err
+	}
+	return true, notifier.Notify(ctx, n)
+}
+
+// digestItem is one event line of the digest templates
+type digestItem struct {
+	When     string
+	Title    string
+	Location string
+}
+
+// digestDay is the events of one day, under its heading
+type digestDay struct {
+	Day   string
+	Items []digestItem
+}
+
+type digestData struct {
+	Heading string
+	Intro   string
+	Footer  string
+	Days    []digestDay
+}
+
+var digestText = texttemplate.Must(texttemplate.New("digest").Parse(`{{.Heading}}
+
+{{.Intro}}
+{{range .Days}}
+{{.Day}}
+{{range .Items}}- {{.When}}: {{.Title}}{{if .Location}} ({{.Location}}){{end}}
+{{end}}{{end}}
+--
+{{.Footer}}
+`))
+
+var digestHTML = htmltemplate.Must(htmltemplate.New("digest").Parse(`<!DOCTYPE html>
+<html><body style="font-family: sans-serif">
+<h2>{{.Heading}}</h2>
+<p>{{.Intro}}</p>{{range .Days}}
+<h3>{{.Day}}</h3>
+<ul>{{range .Items}}
+<li><strong>{{.When}}</strong>: {{.Title}}{{if .Location}} <em>({{.Location}})</em>{{end}}</li>{{end}}
+</ul>{{end}}
+<p style="color: #888; font-size: small">{{.Footer}}</p>
+</body></html>
+`))
+
+// ComposeDigest renders the digest email for the week starting at from, with the
+// events grouped by day in the timezone of from
+func ComposeDigest(sub DigestSubscription, from time.Time, events []EventDB) (Notification, error) {
+	lang := sub.Language
+	loc := from.Location()
+	data := digestData{
+		Heading: Translate(lang, "Your events for the week of %s", FormatDay(lang, from)),
+		Intro:   Translate(lang, "Here is what is coming up in the next 7 days:"),
+		Footer:  Translate(lang, "You receive this email because you subscribed to the weekly digest."),
+	}
+	sorted := append([]EventDB(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].StartTime.Before(sorted[j].StartTime) })
+	for _, e := range sorted {
+		// Events that started before the week are listed under its first day
+		start := e.StartTime.In(loc)
+		if start.Before(from) {
+			start = from
+		}
+		if day := FormatDay(lang, start); len(data.Days) == 0 || data.Days[len(data.Days)-1].Day != day {
+			data.Days = append(data.Days, digestDay{Day: day})
+		}
+		item := digestItem{When: timeRange(e, loc), Title: e.Title}
+		if e.Location != nil {
+			item.Location = *e.Location
+		}
+		last := &data.Days[len(data.Days)-1]
+		last.Items = append(last.Items, item)
+	}
+
+	var text, html bytes.Buffer
+	if err := digestText.Execute(&text, data); err != nil {
+		return Notification{}, fmt.Errorf("failed to render digest: %w", err)
+	}
+	if err := digestHTML.Execute(&html, data); err != nil {
+		return Notification{}, fmt.Errorf("failed to render digest: %w", err)
+	}
+	return Notification{
+		To:      sub.Email,
+		Subject: strings.TrimSpace(data.Heading),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
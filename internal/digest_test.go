@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (f *fakeDigestRepository) PendingDigestSubscriptions(ctx context.Context, before time.Time) ([]DigestSubscription, error) {
+	subs := []DigestSubscription{}
+	for _, sub := range f.subs {
+		if sub.Enabled && (sub.LastSentAt == nil || sub.LastSentAt.Before(before)) {
+			subs = append(subs, sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].UserID < subs[j].UserID })
+	return subs, nil
+}
+
+func (f *fakeDigestRepository) MarkDigestSent(ctx context.Context, userID string, at time.Time) error {
+	sub := f.subs[userID]
+	sub.LastSentAt = &at
+	f.subs[userID] = sub
+	return nil
+}
+
+func TestComposeDigest(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	require.NoError(t, err)
+	from := time.Date(2025, 9, 15, 0, 0, 0, 0, madrid)
+	room := "Room 4"
+	events := []EventDB{
+		{Title: "Retro", StartTime: from.Add(34 * time.Hour), EndTime: from.Add(35 * time.Hour)},
+		{Title: "Standup", StartTime: from.Add(9 * time.Hour), EndTime: from.Add(9*time.Hour + 15*time.Minute)},
+		{Title: "Planning <Q4>", Location: &room, StartTime: from.Add(14 * time.Hour), EndTime: from.Add(16 * time.Hour)},
+	}
+
+	n, err := ComposeDigest(DigestSubscription{Email: "ana@example.com", Language: "en"}, from, events)
+	require.NoError(t, err)
+
+	assert.Equal(t, "ana@example.com", n.To)
+	assert.Equal(t, "Your events for the week of Monday, September 15, 2025", n.Subject)
+	assert.Equal(t, `Your events for the week of Monday, September 15, 2025
+
+Here is what is coming up in the next 7 days:
+
+Monday, September 15, 2025
+- 09:00 – 09:15: Standup
+- 14:00 – 16:00: Planning <Q4> (Room 4)
+
+Tuesday, September 16, 2025
+- 10:00 – 11:00: Retro
+
+--
+You receive this email because you subscribed to the weekly digest.
+`, n.Text)
+	assert.Contains(t, n.HTML, "<h3>Monday, September 15, 2025</h3>")
+	assert.Contains(t, n.HTML, "<h3>Tuesday, September 16, 2025</h3>")
+	assert.Contains(t, n.HTML, "Planning &lt;Q4&gt;")
+}
+
+func TestWeeklyDigestJob(t *testing.T) {
+	now := time.Date(2025, 9, 15, 6, 0, 0, 0, time.UTC)
+	recently := now.Add(-time.Hour)
+	at := func(day, hour int) EventDB {
+		start := time.Date(2025, 9, day, hour, 0, 0, 0, time.UTC)
+		return EventDB{StartTime: start, EndTime: start.Add(time.Hour)}
+	}
+	early, standup, late, pending, nextWeek := at(14, 13), at(16, 8), at(21, 20), at(16, 9), at(23, 8)
+	early.Title, standup.Title, late.Title, pending.Title, nextWeek.Title = "Early", "Standup", "Late", "Pending", "Next week"
+	pending.Status = EventStatusPending
+	events := &scheduledEvents{events: []EventDB{early, standup, late, pending, nextWeek}}
+
+	digests := &fakeDigestRepository{subs: map[string]DigestSubscription{
+		// Madrid's week runs from Sep 14 22:00 to Sep 21 22:00 UTC, Auckland's from Sep 14 12:00 to Sep 21 12:00 UTC
+		"ana":  {UserID: "ana", Email: "ana@example.com", Timezone: "Europe/Madrid", Language: "en", Enabled: true},
+		"kiri": {UserID: "kiri", Email: "kiri@example.com", Timezone: "Pacific/Auckland", Language: "en", Enabled: true},
+		"off":  {UserID: "off", Email: "off@example.com", Timezone: "UTC", Enabled: false},
+		"sent": {UserID: "sent", Email: "sent@example.com", Timezone: "UTC", Enabled: true, LastSentAt: &recently},
+	}}
+	notifier := &recordingNotifier{}
+
+	output, err := runWeeklyDigest(context.Background(), events, digests, notifier, now)
+	require.NoError(t, err)
+
+	assert.Equal(t, "sent 2 digests, 0 empty, 0 failed", output)
+	require.Len(t, notifier.sent, 2)
+	assert.Equal(t, "ana@example.com", notifier.sent[0].To)
+	assert.NotContains(t, notifier.sent[0].Text, "Early")
+	assert.Contains(t, notifier.sent[0].Text, "Standup")
+	assert.Contains(t, notifier.sent[0].Text, "Late")
+	assert.Equal(t, "kiri@example.com", notifier.sent[1].To)
+	assert.Contains(t, notifier.sent[1].Text, "Early")
+	assert.Contains(t, notifier.sent[1].Text, "Standup")
+	assert.NotContains(t, notifier.sent[1].Text, "Late")
+	for _, n := range notifier.sent {
+		assert.NotContains(t, n.Text, "Pending", "unpublished events are left out")
+		assert.NotContains(t, n.Text, "Next week")
+	}
+	assert.Equal(t, now, *digests.subs["ana"].LastSentAt)
+	assert.Equal(t, now, *digests.subs["kiri"].LastSentAt)
+	assert.Equal(t, recently, *digests.subs["sent"].LastSentAt)
+}
+
+func TestWeeklyDigestJobSkipsEmptyWeeks(t *testing.T) {
+	now := time.Date(2025, 9, 15, 6, 0, 0, 0, time.UTC)
+	pending := EventDB{Title: "Pending", Status: EventStatusPending, StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)}
+	events := &scheduledEvents{events: []EventDB{pending}}
+	digests := &fakeDigestRepository{subs: map[string]DigestSubscription{
+		"ana": {UserID: "ana", Email: "ana@example.com", Timezone: "Europe/Madrid", Enabled: true},
+	}}
+	notifier := &recordingNotifier{}
+
+	output, err := runWeeklyDigest(context.Background(), events, digests, notifier, now)
+	require.NoError(t, err)
+
+	assert.Equal(t, "sent 0 digests, 1 empty, 0 failed", output)
+	assert.Empty(t, notifier.sent)
+	assert.Nil(t, digests.subs["ana"].LastSentAt)
+}
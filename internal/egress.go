@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateDestination is returned when a connection to a URL users chose would reach
+// a private, loopback or link-local address that is not allowed
+var ErrPrivateDestination = errors.New("destination is not a public address")
+
+// ParseNetworks parses a list of CIDR prefixes, such as WEBHOOK_ALLOWED_NETWORKS. A
+// bare address stands for itself alone.
+func ParseNetworks(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network %q: %w", s, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", s, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// publicAddress reports whether addr is reachable on the public internet, rather than
+// on the server's own host or networks
+func publicAddress(addr netip.Addr) bool {
+	return !addr.IsPrivate() && !addr.IsLoopback() && !addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() && !addr.IsInterfaceLocalMulticast() && !addr.IsUnspecified() && !addr.IsMulticast()
+}
+
+// publicOnly is a net.Dialer Control refusing connections to addresses that are not
+// public, unless they are in allowed. It sees the address after DNS resolution, so a
+// name resolving, or re-resolving, to a private address is refused too.
+func publicOnly(allowed []netip.Prefix) func(network, address string, _ syscall.RawConn) error {
+	return func(network, address string, _ syscall.RawConn) error {
+		addrPort, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return fmt.Errorf("unexpected address %q: %w", address, err)
+		}
+		addr := addrPort.Addr().Unmap()
+		if publicAddress(addr) {
+			return nil
+		}
+		for _, prefix := range allowed {
+			if prefix.Contains(addr) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s", ErrPrivateDestination, addr)
+	}
+}
+
+// PublicHTTPClient returns a client for URLs users chose, such as webhooks, that only
+// connects to public addresses and those in allowed
+func PublicHTTPClient(timeout time.Duration, allowed []netip.Prefix) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: publicOnly(allowed)}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
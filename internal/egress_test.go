@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"127.0.0.1", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.public, publicAddress(netip.MustParseAddr(tt.addr)), tt.addr)
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks([]string{"10.0.0.0/8", "192.168.1.7", "fd00::1/8"})
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.1.7/32"), netip.MustParsePrefix("fd00::/8")}, networks)
+
+	_, err = ParseNetworks([]string{"intranet"})
+	assert.Error(t, err)
+}
+
+func TestPublicHTTPClient(t *testing.T) {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer endpoint.Close()
+	get := func(client *http.Client, url string) error {
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// Loopback is refused, whether named by address or by a name resolving to it
+	client := PublicHTTPClient(time.Second, nil)
+	assert.ErrorIs(t, get(client, endpoint.URL), ErrPrivateDestination)
+	u, err := url.Parse(endpoint.URL)
+	require.NoError(t, err)
+	assert.ErrorIs(t, get(client, "http://localhost:"+u.Port()), ErrPrivateDestination)
+
+	// unless it is allowed
+	allowed := PublicHTTPClient(time.Second, []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")})
+	assert.NoError(t, get(allowed, endpoint.URL))
+}
@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	"github.com/lib/pq"
+)
+
+// Kinds of domain errors. The errors returned by repositories match one of them with
+// errors.Is, so callers can react to the kind without knowing every sentinel.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+	ErrTimeout    = errors.New("timeout")
+	ErrForbidden  = errors.New("forbidden")
+	// ErrUnprocessable is a write the database refused for breaking a data rule, such
+	// as a reference to a missing record, that no repository anticipated
+	ErrUnprocessable = errors.New("unprocessable")
+	// ErrUnavailable is a failure the client should retry: the database is unreachable
+	// or overloaded, or the transaction lost a serialization conflict or deadlock
+	ErrUnavailable = errors.New("unavailable")
+)
+
+// domainError is a sentinel error of one kind
+type domainError struct {
+	kind error
+	msg  string
+}
+
+func (e *domainError) Error() string { return e.msg }
+
+// Is makes errors.Is(err, kind) match the sentinel
+func (e *domainError) Is(target error) bool { return target == e.kind }
+
+// newDomainError creates a sentinel error of kind
+func newDomainError(kind error, msg string) error {
+	return &domainError{kind: kind, msg: msg}
+}
+
+// DomainError returns an error of kind with msg as the message for the client, for
+// hooks that reject a write with a status other than 400
+func DomainError(kind error, msg string) error {
+	return newDomainError(kind, msg)
+}
+
+// KindOf returns the domain kind of err: ErrNotFound, ErrConflict, ErrValidation,
+// ErrTimeout, ErrForbidden, ErrUnprocessable or ErrUnavailable, or nil for unexpected
+// errors. Context deadlines count as timeouts, and database errors that reach the
+// caller unmapped get the kind of their SQLSTATE.
+func KindOf(err error) error {
+	for _, kind := range []error{ErrNotFound, ErrConflict, ErrValidation, ErrTimeout, ErrForbidden, ErrUnprocessable, ErrUnavailable} {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return databaseKind(err)
+}
+
+// databaseKind classifies a Postgres or connection error, returning nil for others
+func databaseKind(err error) error {
+	var pqErr *pq.Error
+	var netErr net.Error
+	switch {
+	case errors.As(err, &pqErr):
+		switch pqErr.Code {
+		case "23505", "23P01": // unique and exclusion violations
+			return ErrConflict
+		case "57014": // statement timeout
+			return ErrTimeout
+		}
+		switch pqErr.Code.Class() {
+		case "22": // data exception, such as a value out of range
+			return ErrValidation
+		case "23": // foreign key, not null and check violations
+			return ErrUnprocessable
+		case "40": // serialization failure and deadlock
+			return ErrUnavailable
+		case "08", "53", "57": // connection, insufficient resources, shutdown
+			return ErrUnavailable
+		}
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone), errors.As(err, &netErr):
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// DomainMessage returns the message of the sentinel err wraps, without the context
+// added while it was returned, or "" when it wraps none
+func DomainMessage(err error) string {
+	var de *domainError
+	if errors.As(err, &de) {
+		return de.msg
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxSummaryLength bounds the description excerpt shown in link previews
+const maxSummaryLength = 200
+
+var (
+	tagPattern = regexp.MustCompile(`<[^>]*>`)
+	// blockEndPattern matches the tags ending a line of rendered text
+	blockEndPattern    = regexp.MustCompile(`(?i)<br>|</(p|h[1-6]|li|blockquote|pre)>`)
+	multipleBlankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// DescriptionText returns an event's description as plain text, with markdown
+// rendered and paragraphs separated by blank lines
+func DescriptionText(e EventDB) string {
+	if e.Description == nil {
+		return ""
+	}
+	rendered := RenderDescriptionHTML(*e.Description, e.DescriptionFormat)
+	rendered = blockEndPattern.ReplaceAllString(rendered, "\n")
+	text := html.UnescapeString(tagPattern.ReplaceAllString(rendered, ""))
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.TrimSpace(multipleBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// Summary returns a plain-text excerpt of an event's description for link previews
+func Summary(e EventDB) string {
+	text := strings.Join(strings.Fields(DescriptionText(e)), " ")
+	if len(text) <= maxSummaryLength {
+		return text
+	}
+	cut := strings.LastIndex(text[:maxSummaryLength], " ")
+	if cut <= 0 {
+		cut = maxSummaryLength
+	}
+	return strings.ToValidUTF8(text[:cut], "") + "…"
+}
+
+// schemaEvent is the schema.org Event describing a page to search engines
+type schemaEvent struct {
+	Context     string       `json:"@context"`
+	Type        string       `json:"@type"`
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	StartDate   string       `json:"startDate"`
+	EndDate     string       `json:"endDate"`
+	URL         string       `json:"url"`
+	EventStatus string       `json:"eventStatus"`
+	Location    *schemaPlace `json:"location,omitempty"`
+}
+
+type schemaPlace struct {
+	Type    string     `json:"@type"`
+	Name    string     `json:"name,omitempty"`
+	Address string     `json:"address,omitempty"`
+	Geo     *schemaGeo `json:"geo,omitempty"`
+}
+
+type schemaGeo struct {
+	Type      string  `json:"@type"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// eventPageData fills eventPageHTML
+type eventPageData struct {
+	Lang        string
+	Title       string
+	Summary     string
+	URL         string
+	When        string
+	Location    string
+	Description htmltemplate.HTML
+	JSONLD      htmltemplate.JS
+}
+
+var eventPageHTML = htmltemplate.Must(htmltemplate.New("event").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="canonical" href="{{.URL}}">
+{{if .Summary}}<meta name="description" content="{{.Summary}}">
+{{end}}<meta property="og:type" content="website">
+<meta property="og:title" content="{{.Title}}">
+{{if .Summary}}<meta property="og:description" content="{{.Summary}}">
+{{end}}<meta property="og:url" content="{{.URL}}">
+<meta name="twitter:card" content="summary">
+<meta name="twitter:title" content="{{.Title}}">
+{{if .Summary}}<meta name="twitter:description" content="{{.Summary}}">
+{{end}}<script type="application/ld+json">{{.JSONLD}}</script>
+<style>body{font-family:sans-serif;max-width:40rem;margin:2rem auto;padding:0 1rem;line-height:1.5}.meta{color:#555}</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta"><time>{{.When}}</time>{{if .Location}}<br>{{.Location}}{{end}}</p>
+{{.Description}}
+</body>
+</html>
+`))
+
+// RenderEventPage writes the public HTML page of an event, found at url, with Open
+// Graph and Twitter tags for link previews and schema.org JSON-LD for search engines
+func RenderEventPage(w io.Writer, e EventDB, url, lang string) error {
+	ld := schemaEvent{
+		Context:     "https://schema.org",
+		Type:        "Event",
+		Name:        e.Title,
+		Description: Summary(e),
+		StartDate:   e.StartTime.UTC().Format(time.RFC3339),
+		EndDate:     e.EndTime.UTC().Format(time.RFC3339),
+		URL:         url,
+		EventStatus: "https://schema.org/EventScheduled",
+	}
+	data := eventPageData{
+		Lang:    lang,
+		Title:   e.Title,
+		Summary: ld.Description,
+		URL:     url,
+		When:    FormatDate(lang, e.StartTime.UTC()) + " – " + FormatDate(lang, e.EndTime.UTC()),
+	}
+	if e.Description != nil {
+		data.Description = htmltemplate.HTML(RenderDescriptionHTML(*e.Description, e.DescriptionFormat))
+	}
+	if e.Location != nil || (e.Latitude != nil && e.Longitude != nil) {
+		place := &schemaPlace{Type: "Place"}
+		if e.Location != nil {
+			place.Name, place.Address = *e.Location, *e.Location
+			data.Location = *e.Location
+		}
+		if e.Latitude != nil && e.Longitude != nil {
+			place.Geo = &schemaGeo{Type: "GeoCoordinates", Latitude: *e.Latitude, Longitude: *e.Longitude}
+		}
+		ld.Location = place
+	}
+
+	// json.Marshal escapes <, > and &, so the document cannot close the script element
+	raw, err := json.Marshal(ld)
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON-LD: %w", err)
+	}
+	data.JSONLD = htmltemplate.JS(raw)
+
+	if err := eventPageHTML.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to render event page: %w", err)
+	}
+	return nil
+}
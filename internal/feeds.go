@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// FeedRefreshInterval is how often subscribed calendar apps are asked to re-fetch a feed
+const FeedRefreshInterval = time.Hour
+
+// FeedSigner issues and checks the tokens of calendar feed URLs. A token is the
+// HMAC of the calendar ID and its feed version, so it needs no storage, and bumping
+// the version revokes every token issued before.
+type FeedSigner struct {
+	key []byte
+}
+
+// NewFeedSigner returns a signer using key, or nil when key is empty
+func NewFeedSigner(key string) *FeedSigner {
+	if key == "" {
+		return nil
+	}
+	return &FeedSigner{key: []byte(key)}
+}
+
+// Token returns the feed token of a calendar
+func (s *FeedSigner) Token(c Calendar) string {
+	mac := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(mac, "calendar-feed\n%s\n%d", c.ID, c.FeedVersion)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether token is the current feed token of c, in constant time
+func (s *FeedSigner) Verify(c Calendar, token string) bool {
+	return hmac.Equal([]byte(s.Token(c)), []byte(token))
+}
+
+// FeedPath is the path of a calendar's ICS feed, including its token
+func (s *FeedSigner) FeedPath(c Calendar) string {
+	return fmt.Sprintf("/calendars/%s/feed.ics?token=%s", c.ID, s.Token(c))
+}
+
+// icsTime formats t as an iCalendar UTC date-time
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// icsEscape escapes a TEXT value (RFC 5545 section 3.3.11)
+var icsEscape = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", "")
+
+// icsWriter writes content lines, folding them at 75 octets without splitting
+// characters (RFC 5545 section 3.1)
+type icsWriter struct {
+	w *bufio.Writer
+}
+
+func (iw icsWriter) line(name, value string) {
+	line := name + ":" + value
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		iw.w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// The leading space of continuation lines counts towards their length
+		limit = 74
+	}
+	iw.w.WriteString(line + "\r\n")
+}
+
+func (iw icsWriter) text(name string, value *string) {
+	if value != nil && *value != "" {
+		iw.line(name, icsEscape.Replace(*value))
+	}
+}
+
+// WriteICS writes the published events of a calendar as an iCalendar document
+func WriteICS(w io.Writer, c Calendar, events []EventDB, now time.Time) error {
+	iw := icsWriter{w: bufio.NewWriter(w)}
+	iw.line("BEGIN", "VCALENDAR")
+	iw.line("VERSION", "2.0")
+	iw.line("PRODID", "-//taller_challenge//Events API//EN")
+	iw.line("CALSCALE", "GREGORIAN")
+	iw.line("METHOD", "PUBLISH")
+	iw.line("X-WR-CALNAME", icsEscape.Replace(c.Name))
+	iw.line("REFRESH-INTERVAL;VALUE=DURATION", fmt.Sprintf("PT%dM", int(FeedRefreshInterval.Minutes())))
+	iw.line("X-PUBLISHED-TTL", fmt.Sprintf("PT%dM", int(FeedRefreshInterval.Minutes())))
+
+	for _, e := range events {
+		if !e.Published() {
+			continue
+		}
+		iw.line("BEGIN", "VEVENT")
+		iw.line("UID", eventUID(e.ID))
+		iw.line("DTSTAMP", icsTime(now))
+		iw.line("DTSTART", icsTime(e.StartTime))
+		iw.line("DTEND", icsTime(e.EndTime))
+		iw.line("CREATED", icsTime(e.CreatedAt))
+		iw.line("LAST-MODIFIED", icsTime(e.UpdatedAt))
+		iw.line("SUMMARY", icsEscape.Replace(e.Title))
+		iw.text("DESCRIPTION", e.Description)
+		iw.text("LOCATION", e.Location)
+		if e.Latitude != nil && e.Longitude != nil {
+			iw.line("GEO", fmt.Sprintf("%f;%f", *e.Latitude, *e.Longitude))
+		}
+		iw.line("END", "VEVENT")
+	}
+
+	iw.line("END", "VCALENDAR")
+	return iw.w.Flush()
+}
+
+// eventUID is the iCalendar UID of an event, stable across updates
+func eventUID(id uuid.UUID) string {
+	return id.String() + "@taller_challenge"
+}
@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteICS(t *testing.T) {
+	start := time.Date(2025, 9, 15, 9, 0, 0, 0, time.FixedZone("CEST", 2*3600))
+	description := "Agenda; notes, and\nmore: " + strings.Repeat("é", 60)
+	events := []EventDB{
+		{ID: uuid.New(), Title: "Standup", Description: &description, StartTime: start, EndTime: start.Add(time.Hour)},
+		{ID: uuid.New(), Title: "Draft", Status: EventStatusPending, StartTime: start, EndTime: start.Add(time.Hour)},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteICS(&buf, Calendar{Name: "Team, Berlin"}, events, start))
+	ics := buf.String()
+
+	assert.True(t, strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(ics, "END:VCALENDAR\r\n"))
+	assert.Contains(t, ics, "X-WR-CALNAME:Team\\, Berlin\r\n")
+	assert.Contains(t, ics, "DTSTART:20250915T070000Z\r\n")
+	assert.Equal(t, 1, strings.Count(ics, "BEGIN:VEVENT"), "unpublished events are left out")
+
+	for _, line := range strings.Split(strings.TrimSuffix(ics, "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75)
+	}
+	unfolded := strings.ReplaceAll(ics, "\r\n ", "")
+	assert.Contains(t, unfolded, `DESCRIPTION:Agenda\; notes\, and\nmore: `+strings.Repeat("é", 60)+"\r\n")
+}
+
+func TestFeedSigner(t *testing.T) {
+	assert.Nil(t, NewFeedSigner(""))
+
+	signer := NewFeedSigner("secret")
+	c := Calendar{ID: uuid.New(), FeedVersion: 1}
+	token := signer.Token(c)
+	assert.True(t, signer.Verify(c, token))
+	assert.False(t, NewFeedSigner("other").Verify(c, token))
+
+	c.FeedVersion++
+	assert.False(t, signer.Verify(c, token), "rotation revokes the old token")
+}
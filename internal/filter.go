@@ -0,0 +1,521 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// Limits of a filter expression, so one request cannot make the parser or the planner
+// do unbounded work
+const (
+	MaxFilterLength     = 1000
+	maxFilterConditions = 32
+	maxFilterDepth      = 10
+)
+
+// filterFieldKind is the type of a filterable field, which decides the operators it
+// takes and how its values are parsed
+type filterFieldKind int
+
+const (
+	filterString filterFieldKind = iota
+	filterTime
+	filterInt
+	filterUUID
+	filterDuration
+)
+
+// filterField is a field filters may name, with the SQL expression it compiles to and
+// its value on an event for filtering in memory. value returns nil for unset fields.
+type filterField struct {
+	kind  filterFieldKind
+	sql   string
+	value func(e EventDB) any
+}
+
+func nullableString(s *string) any {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
+
+// filterFields are the fields filters may name. Description and location are left
+// out: they may be encrypted at rest, so the database cannot compare them.
+var filterFields = map[string]filterField{
+	"title":              {filterString, "title", func(e EventDB) any { return e.Title }},
+	"status":             {filterString, "status", func(e EventDB) any { return e.Status }},
+	"description_format": {filterString, "description_format", func(e EventDB) any { return e.DescriptionFormat }},
+	"source":             {filterString, "source", func(e EventDB) any { return nullableString(e.Source) }},
+	"external_id":        {filterString, "external_id", func(e EventDB) any { return nullableString(e.ExternalID) }},
+	"currency":           {filterString, "currency", func(e EventDB) any { return nullableString(e.Currency) }},
+	"start_time":         {filterTime, "start_time", func(e EventDB) any { return e.StartTime }},
+	"end_time":           {filterTime, "end_time", func(e EventDB) any { return e.EndTime }},
+	"created_at":         {filterTime, "created_at", func(e EventDB) any { return e.CreatedAt }},
+	"updated_at":         {filterTime, "updated_at", func(e EventDB) any { return e.UpdatedAt }},
+	"duration":           {filterDuration, "EXTRACT(EPOCH FROM end_time - start_time)", func(e EventDB) any { return e.EndTime.Sub(e.StartTime).Seconds() }},
+	"price_cents": {filterInt, "price_cents", func(e EventDB) any {
+		if e.PriceCents == nil {
+			return nil
+		}
+		return *e.PriceCents
+	}},
+	"ticket_quota": {filterInt, "ticket_quota", func(e EventDB) any {
+		if e.TicketQuota == nil {
+			return nil
+		}
+		return int64(*e.TicketQuota)
+	}},
+	"calendar_id": {filterUUID, "calendar_id", func(e EventDB) any {
+		if e.CalendarID == nil {
+			return nil
+		}
+		return *e.CalendarID
+	}},
+}
+
+// busyBlockFields are the fields busy blocks show, as RedactEvent keeps them. Matching
+// other fields tells what the events behind busy blocks are about.
+var busyBlockFields = map[string]bool{
+	"start_time": true, "end_time": true, "created_at": true, "updated_at": true, "duration": true,
+	"calendar_id": true, "status": true, "description_format": true,
+}
+
+// filterOperators are the comparison operators, longest first so the lexer prefers >=
+// over >. ":" is a case-insensitive substring match on strings and equality on others.
+var filterOperators = []string{">=", "<=", "!=", "=", ">", "<", ":"}
+
+// EventFilter is a parsed ?filter= expression such as
+//
+//	start_time>2025-01-01 AND (title:"standup" OR status=pending)
+//
+// Comparisons are field, operator and value; they combine with AND, OR, NOT and
+// parentheses. Values with spaces or parentheses are double quoted. Times are RFC 3339
+// or dates at midnight UTC, and durations Go durations such as 90m.
+type EventFilter struct {
+	root filterNode
+	src  string
+	// content is set when the filter names a field busy blocks do not show
+	content bool
+}
+
+// String returns the expression the filter was parsed from
+func (f *EventFilter) String() string { return f.src }
+
+// ComparesContent reports whether the filter compares fields busy blocks hide, such as
+// the title, rather than only their times. Events the caller sees as busy blocks must
+// be left out of what such a filter matches.
+func (f *EventFilter) ComparesContent() bool { return f.content }
+
+// filterNode is a node of the expression tree. where appends the SQL condition with ?
+// placeholders and its arguments; matches evaluates the node on an event.
+type filterNode interface {
+	where(sb *strings.Builder, args []any) []any
+	matches(e EventDB) bool
+}
+
+type filterAnd struct{ left, right filterNode }
+type filterOr struct{ left, right filterNode }
+type filterNot struct{ expr filterNode }
+
+type filterComparison struct {
+	field filterField
+	op    string
+	value any
+}
+
+func (n filterAnd) where(sb *strings.Builder, args []any) []any {
+	sb.WriteString("(")
+	args = n.left.where(sb, args)
+	sb.WriteString(" AND ")
+	args = n.right.where(sb, args)
+	sb.WriteString(")")
+	return args
+}
+
+func (n filterAnd) matches(e EventDB) bool { return n.left.matches(e) && n.right.matches(e) }
+
+func (n filterOr) where(sb *strings.Builder, args []any) []any {
+	sb.WriteString("(")
+	args = n.left.where(sb, args)
+	sb.WriteString(" OR ")
+	args = n.right.where(sb, args)
+	sb.WriteString(")")
+	return args
+}
+
+func (n filterOr) matches(e EventDB) bool { return n.left.matches(e) || n.right.matches(e) }
+
+func (n filterNot) where(sb *strings.Builder, args []any) []any {
+	sb.WriteString("NOT ")
+	return n.expr.where(sb, args)
+}
+
+func (n filterNot) matches(e EventDB) bool { return !n.expr.matches(e) }
+
+// where compiles the comparison so it is never NULL, as in memory: unset fields match
+// != and nothing else
+func (n filterComparison) where(sb *strings.Builder, args []any) []any {
+	switch {
+	case n.op == "!=":
+		sb.WriteString(n.field.sql + " IS DISTINCT FROM ?")
+	case n.op == ":" && n.field.kind == filterString:
+		sb.WriteString(`COALESCE(` + n.field.sql + ` ILIKE ? ESCAPE '\', false)`)
+		return append(args, "%"+likeEscaper.Replace(n.value.(string))+"%")
+	default:
+		op := n.op
+		if op == ":" {
+			op = "="
+		}
+		sb.WriteString("COALESCE(" + n.field.sql + " " + op + " ?, false)")
+	}
+	return append(args, n.value)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (n filterComparison) matches(e EventDB) bool {
+	v := n.field.value(e)
+	if v == nil {
+		return n.op == "!="
+	}
+	if n.op == ":" && n.field.kind == filterString {
+		return strings.Contains(strings.ToLower(v.(string)), strings.ToLower(n.value.(string)))
+	}
+	var cmp int
+	switch v := v.(type) {
+	case string:
+		cmp = strings.Compare(v, n.value.(string))
+	case time.Time:
+		cmp = v.Compare(n.value.(time.Time))
+	case int64:
+		cmp = compareOrdered(v, n.value.(int64))
+	case float64:
+		cmp = compareOrdered(v, n.value.(float64))
+	case uuid.UUID:
+		if v == n.value.(uuid.UUID) {
+			cmp = 0
+		} else {
+			cmp = 1
+		}
+	}
+	switch n.op {
+	case "=", ":":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	default:
+		return cmp <= 0
+	}
+}
+
+func compareOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Where returns the filter as a condition for selectBuilder.Where, with ? placeholders
+// and their arguments. Values are always bound, never spliced into the SQL.
+func (f *EventFilter) Where() (string, []any) {
+	var sb strings.Builder
+	args := f.root.where(&sb, nil)
+	return sb.String(), args
+}
+
+// Matches reports whether the filter selects e, as the condition of Where would
+func (f *EventFilter) Matches(e EventDB) bool {
+	return f.root.matches(e)
+}
+
+// FilterError is a malformed filter expression, with the offset where parsing stopped
+type FilterError struct {
+	Pos int
+	Msg string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("%s at offset %d", e.Msg, e.Pos)
+}
+
+// ParseEventFilter parses a filter expression
+func ParseEventFilter(s string) (*EventFilter, error) {
+	if len(s) > MaxFilterLength {
+		return nil, &FilterError{Pos: MaxFilterLength, Msg: fmt.Sprintf("filter longer than %d characters", MaxFilterLength)}
+	}
+	p := &filterParser{src: s}
+	root, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	return &EventFilter{root: root, src: s, content: p.content}, nil
+}
+
+// filterParser is a recursive descent parser over the expression. Lexing depends on
+// the position in a comparison, so that values such as 2025-01-01T10:00:00Z can hold
+// the characters of operators.
+type filterParser struct {
+	src        string
+	pos        int
+	conditions int
+	// content is set once a field outside busyBlockFields is named
+	content bool
+}
+
+func (p *filterParser) errorf(format string, args ...any) error {
+	return &FilterError{Pos: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *filterParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// keyword consumes the word kw, case-insensitively, when it comes next
+func (p *filterParser) keyword(kw string) bool {
+	p.skipSpace()
+	end := p.pos + len(kw)
+	if end > len(p.src) || !strings.EqualFold(p.src[p.pos:end], kw) {
+		return false
+	}
+	if end < len(p.src) && !unicode.IsSpace(rune(p.src[end])) && p.src[end] != '(' {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+func (p *filterParser) parseOr(depth int) (filterNode, error) {
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = filterOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd(depth int) (filterNode, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = filterAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary(depth int) (filterNode, error) {
+	if depth > maxFilterDepth {
+		return nil, p.errorf("filter nested deeper than %d levels", maxFilterDepth)
+	}
+	if p.keyword("NOT") {
+		expr, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{expr}, nil
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == '(' {
+		p.pos++
+		expr, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] != ')' {
+			return nil, p.errorf("missing )")
+		}
+		p.pos++
+		return expr, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterNode, error) {
+	if p.conditions++; p.conditions > maxFilterConditions {
+		return nil, p.errorf("more than %d conditions", maxFilterConditions)
+	}
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) && (p.src[p.pos] == '_' || unicode.IsLetter(rune(p.src[p.pos]))) {
+		p.pos++
+	}
+	name := strings.ToLower(p.src[start:p.pos])
+	if name == "" {
+		return nil, p.errorf("expected a field")
+	}
+	field, ok := filterFields[name]
+	if !ok {
+		p.pos = start
+		return nil, p.errorf("unknown field %q", name)
+	}
+	if !busyBlockFields[name] {
+		p.content = true
+	}
+
+	p.skipSpace()
+	op := ""
+	for _, candidate := range filterOperators {
+		if strings.HasPrefix(p.src[p.pos:], candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return nil, p.errorf("expected an operator after %s", name)
+	}
+	opAt := p.pos
+	p.pos += len(op)
+	if field.kind != filterString && field.kind != filterUUID && op == ":" {
+		op = "="
+	}
+	if (field.kind == filterString || field.kind == filterUUID) && op != "=" && op != "!=" && op != ":" {
+		p.pos = opAt
+		return nil, p.errorf("%s takes =, != or :", name)
+	}
+
+	p.skipSpace()
+	valueAt := p.pos
+	raw, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	value, err := parseFilterValue(field.kind, raw)
+	if err != nil {
+		p.pos = valueAt
+		return nil, p.errorf("invalid value for %s: %v", name, err)
+	}
+	return filterComparison{field: field, op: op, value: value}, nil
+}
+
+// parseValue reads a double-quoted string, in which \" and \\ are escapes, or a bare
+// value running to the next space or parenthesis
+func (p *filterParser) parseValue() (string, error) {
+	if p.pos < len(p.src) && p.src[p.pos] == '"' {
+		var sb strings.Builder
+		for i := p.pos + 1; i < len(p.src); i++ {
+			switch c := p.src[i]; {
+			case c == '\\' && i+1 < len(p.src):
+				i++
+				sb.WriteByte(p.src[i])
+			case c == '"':
+				p.pos = i + 1
+				return sb.String(), nil
+			default:
+				sb.WriteByte(c)
+			}
+		}
+		return "", p.errorf("unterminated string")
+	}
+	start := p.pos
+	for p.pos < len(p.src) && !unicode.IsSpace(rune(p.src[p.pos])) && p.src[p.pos] != '(' && p.src[p.pos] != ')' {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf("expected a value")
+	}
+	return p.src[start:p.pos], nil
+}
+
+func parseFilterValue(kind filterFieldKind, raw string) (any, error) {
+	switch kind {
+	case filterTime:
+		if t, err := ParseTime(raw); err == nil {
+			return t, nil
+		}
+		t, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			return nil, errors.New("expected an RFC 3339 time or a date")
+		}
+		return t, nil
+	case filterInt:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, errors.New("expected an integer")
+		}
+		return n, nil
+	case filterDuration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, errors.New("expected a duration such as 90m")
+		}
+		return d.Seconds(), nil
+	case filterUUID:
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, errors.New("expected a UUID")
+		}
+		return id, nil
+	}
+	return raw, nil
+}
+
+// EventFilterer is implemented by repositories that can filter listings in the database
+type EventFilterer interface {
+	FilterEvents(ctx context.Context, f *EventFilter) ([]EventDB, error)
+}
+
+// FilterEvents returns the events of repo f matches, ordered by start time. Repositories
+// that cannot filter in the database are listed and filtered in memory.
+func FilterEvents(ctx context.Context, repo EventRepositoryInterface, f *EventFilter) ([]EventDB, error) {
+	if fr, ok := repo.(EventFilterer); ok {
+		return fr.FilterEvents(ctx, f)
+	}
+	events, err := repo.GetEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return f.Filter(events), nil
+}
+
+// Filter returns the events f matches, in their order
+func (f *EventFilter) Filter(events []EventDB) []EventDB {
+	var out []EventDB
+	for _, e := range events {
+		if f.Matches(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// FilterEvents retrieves the events f matches, ordered by start time
+func (r *EventRepository) FilterEvents(ctx context.Context, f *EventFilter) ([]EventDB, error) {
+	cond, args := f.Where()
+	query, args := newSelect(qSelectEvents).Where(cond, args...).OrderBy("start_time ASC").Build()
+	return r.queryEvents(ctx, query, args...)
+}
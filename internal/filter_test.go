@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventFilterWhere(t *testing.T) {
+	f, err := ParseEventFilter(`start_time>2025-01-01 AND (title:"50%_off" OR NOT status=pending)`)
+	require.NoError(t, err)
+
+	cond, args := f.Where()
+	assert.Equal(t, `(COALESCE(start_time > ?, false) AND (COALESCE(title ILIKE ? ESCAPE '\', false) OR NOT COALESCE(status = ?, false)))`, cond)
+	assert.Equal(t, []any{time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), `%50\%\_off%`, "pending"}, args)
+
+	query, bound := newSelect(qSelectEvents).Where(cond, args...).Build()
+	assert.Contains(t, query, "start_time > $1")
+	assert.Contains(t, query, "status = $3")
+	assert.Len(t, bound, 3)
+}
+
+func TestEventFilterValuesAreNeverSpliced(t *testing.T) {
+	f, err := ParseEventFilter(`title="x' OR 1=1 --"`)
+	require.NoError(t, err)
+
+	cond, args := f.Where()
+	assert.Equal(t, "COALESCE(title = ?, false)", cond)
+	assert.Equal(t, []any{"x' OR 1=1 --"}, args)
+}
+
+func TestEventFilterMatches(t *testing.T) {
+	start := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	google, calendar := "google", uuid.New()
+	price := int64(1500)
+	event := EventDB{Title: "Team Standup", Status: EventStatusPending, StartTime: start, EndTime: start.Add(30 * time.Minute),
+		Source: &google, CalendarID: &calendar, PriceCents: &price}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`title:standup`, true},
+		{`title="Team Standup"`, true},
+		{`title=standup`, false},
+		{`start_time>=2025-03-10T09:00:00Z AND end_time<2025-03-11`, true},
+		{`start_time>2025-03-10T09:00:00Z`, false},
+		{`duration<=30m and duration>15m`, true},
+		{`status=pending OR title:party`, true},
+		{`NOT (status=pending)`, false},
+		{`source=google AND calendar_id=` + calendar.String(), true},
+		{`price_cents>=1000 AND price_cents<2000`, true},
+		// Unset fields match != and nothing else
+		{`currency!=EUR`, true},
+		{`currency:EUR`, false},
+		{`NOT ticket_quota>0`, true},
+	}
+	for _, tt := range tests {
+		f, err := ParseEventFilter(tt.expr)
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.want, f.Matches(event), tt.expr)
+	}
+}
+
+func TestParseEventFilterErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		pos  int
+	}{
+		{`tag:"work"`, 0},
+		{`title`, 5},
+		{`title>"a"`, 5},
+		{`start_time>yesterday`, 11},
+		{`(title:a`, 8},
+		{`title:a OR`, 10},
+		{`title:"open`, 6},
+		{`title:a status=pending`, 8},
+		{`calendar_id=42`, 12},
+	}
+	for _, tt := range tests {
+		_, err := ParseEventFilter(tt.expr)
+		var fe *FilterError
+		require.ErrorAs(t, err, &fe, tt.expr)
+		assert.Equal(t, tt.pos, fe.Pos, tt.expr)
+	}
+
+	_, err := ParseEventFilter(strings.Repeat("(", 20) + "title:a" + strings.Repeat(")", 20))
+	assert.ErrorContains(t, err, "nested deeper")
+	_, err = ParseEventFilter(strings.TrimSuffix(strings.Repeat("title:a OR ", 40), " OR "))
+	assert.ErrorContains(t, err, "conditions")
+}
+
+func TestEventFilterComparesContent(t *testing.T) {
+	tests := []struct {
+		expr    string
+		content bool
+	}{
+		{"start_time>2025-01-01 AND duration<=1h", false},
+		{"NOT (calendar_id=0b0c4f6e-2e2a-4a8e-9d6e-1f1a2b3c4d5e OR status=pending)", false},
+		{`title:"salary"`, true},
+		{"start_time>2025-01-01 AND NOT external_id=abc", true},
+		{"end_time<2025-02-01 OR price_cents>0", true},
+	}
+	for _, tt := range tests {
+		f, err := ParseEventFilter(tt.expr)
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.content, f.ComparesContent(), tt.expr)
+	}
+}
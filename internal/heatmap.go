@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Heatmap bucket sizes
+const (
+	HeatmapBucketHour = "hour"
+	HeatmapBucketDay  = "day"
+	HeatmapBucketWeek = "week"
+)
+
+// MaxHeatmapBuckets bounds how many buckets one heatmap may have
+const MaxHeatmapBuckets = 2000
+
+// heatmapIntervals are the Postgres intervals of the bucket sizes
+var heatmapIntervals = map[string]string{
+	HeatmapBucketHour: "1 hour",
+	HeatmapBucketDay:  "1 day",
+	HeatmapBucketWeek: "7 days",
+}
+
+// HeatmapBucket is the number of events overlapping one period
+type HeatmapBucket struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Count int       `json:"count"`
+}
+
+// HeatmapQuery selects the buckets of a heatmap. From and To must be aligned with
+// AlignHeatmapRange.
+type HeatmapQuery struct {
+	From, To time.Time
+	Bucket   string
+	// Location is where days and weeks start; buckets follow its daylight saving time
+	Location *time.Location
+	// CalendarID limits the counts to one calendar
+	CalendarID *uuid.UUID
+}
+
+// ValidHeatmapBucket reports whether bucket is a supported bucket size
+func ValidHeatmapBucket(bucket string) bool {
+	_, ok := heatmapIntervals[bucket]
+	return ok
+}
+
+// heatmapFloor returns the start of the bucket containing t in loc
+func heatmapFloor(t time.Time, bucket string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch bucket {
+	case HeatmapBucketHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	case HeatmapBucketWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// heatmapNext returns the start of the bucket after the one starting at t
+func heatmapNext(t time.Time, bucket string) time.Time {
+	switch bucket {
+	case HeatmapBucketHour:
+		return t.Add(time.Hour)
+	case HeatmapBucketWeek:
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// AlignHeatmapRange widens [from, to) to whole buckets in loc, which start on the
+// hour, at midnight or on Monday, and counts them. Counting stops past
+// MaxHeatmapBuckets.
+func AlignHeatmapRange(from, to time.Time, bucket string, loc *time.Location) (time.Time, time.Time, int) {
+	start := heatmapFloor(from, bucket, loc)
+	end := heatmapFloor(to, bucket, loc)
+	if end.Before(to) {
+		end = heatmapNext(end, bucket)
+	}
+	n := 0
+	for t := start; t.Before(end) && n <= MaxHeatmapBuckets; t = heatmapNext(t, bucket) {
+		n++
+	}
+	return start, end, n
+}
+
+// Occupancy counts the published events overlapping each bucket of q, including
+// empty buckets
+func (r *EventRepository) Occupancy(ctx context.Context, q HeatmapQuery) ([]HeatmapBucket, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, qEventOccupancy.SQL, q.From, q.To, heatmapIntervals[q.Bucket], q.Location.String(), q.CalendarID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query occupancy: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := []HeatmapBucket{}
+	for rows.Next() {
+		var b HeatmapBucket
+		if err := rows.Scan(&b.Start, &b.End, &b.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan occupancy: %w", err)
+		}
+		b.Start, b.End = b.Start.In(q.Location), b.End.In(q.Location)
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating occupancy: %w", err)
+	}
+	return buckets, nil
+}
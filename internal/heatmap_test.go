@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlignHeatmapRange(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	// Wednesday 10:30 to Thursday 09:15 in Madrid
+	from := time.Date(2025, 9, 17, 10, 30, 0, 0, madrid)
+	to := time.Date(2025, 9, 18, 9, 15, 0, 0, madrid)
+
+	start, end, n := AlignHeatmapRange(from, to, HeatmapBucketHour, madrid)
+	assert.Equal(t, time.Date(2025, 9, 17, 10, 0, 0, 0, madrid), start)
+	assert.Equal(t, time.Date(2025, 9, 18, 10, 0, 0, 0, madrid), end)
+	assert.Equal(t, 24, n)
+
+	start, end, n = AlignHeatmapRange(from, to, HeatmapBucketDay, madrid)
+	assert.Equal(t, time.Date(2025, 9, 17, 0, 0, 0, 0, madrid), start)
+	assert.Equal(t, time.Date(2025, 9, 19, 0, 0, 0, 0, madrid), end)
+	assert.Equal(t, 2, n)
+
+	start, end, n = AlignHeatmapRange(from, to, HeatmapBucketWeek, madrid)
+	assert.Equal(t, time.Monday, start.Weekday())
+	assert.Equal(t, time.Date(2025, 9, 15, 0, 0, 0, 0, madrid), start)
+	assert.Equal(t, time.Date(2025, 9, 22, 0, 0, 0, 0, madrid), end)
+	assert.Equal(t, 1, n)
+
+	// Days follow daylight saving time: 26 October 2025 lasts 25 hours in Madrid
+	start, end, n = AlignHeatmapRange(time.Date(2025, 10, 26, 12, 0, 0, 0, madrid), time.Date(2025, 10, 26, 13, 0, 0, 0, madrid), HeatmapBucketDay, madrid)
+	assert.Equal(t, 25*time.Hour, end.Sub(start))
+	assert.Equal(t, 1, n)
+
+	// Aligned ranges stay as they are
+	_, end, n = AlignHeatmapRange(start, end, HeatmapBucketDay, madrid)
+	assert.Equal(t, time.Date(2025, 10, 27, 0, 0, 0, 0, madrid), end)
+	assert.Equal(t, 1, n)
+
+	_, _, n = AlignHeatmapRange(from, from.AddDate(1, 0, 0), HeatmapBucketHour, madrid)
+	assert.Greater(t, n, MaxHeatmapBuckets)
+}
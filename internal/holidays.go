@@ -0,0 +1,246 @@
+package internal
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Holiday policies applied when an event falls on a public holiday
+const (
+	HolidayPolicyIgnore = "ignore"
+	HolidayPolicyWarn   = "warn"
+	HolidayPolicyBusy   = "busy"
+)
+
+// ErrUnknownCountry is returned when no holiday data exists for a country
+var ErrUnknownCountry = newDomainError(ErrNotFound, "unknown country")
+
+// Holiday is a public holiday on a calendar date
+type Holiday struct {
+	Date    string `json:"date"` // YYYY-MM-DD
+	Name    string `json:"name"`
+	Country string `json:"country"`
+}
+
+// HolidayProvider returns the public holidays of a country in a year
+type HolidayProvider interface {
+	Holidays(ctx context.Context, country string, year int) ([]Holiday, error)
+}
+
+// NewHolidayProvider builds the provider selected by cfg.HolidaySource.
+// External sources are cached and fall back to the embedded dataset on failure.
+func NewHolidayProvider(cfg Config) HolidayProvider {
+	embedded := NewEmbeddedHolidayProvider()
+	if cfg.HolidaySource == "nager" {
+		return newCachedHolidayProvider(&nagerHolidayProvider{
+			client:  &http.Client{Timeout: 5 * time.Second},
+			baseURL: "https://date.nager.at/api/v3",
+		}, embedded)
+	}
+	return embedded
+}
+
+// HolidaysOn returns the holidays falling on any calendar day between start and end (inclusive).
+// Dates are taken in the location of start, so callers should pass the client's original offset.
+func HolidaysOn(ctx context.Context, p HolidayProvider, country string, start, end time.Time) ([]Holiday, error) {
+	end = end.In(start.Location())
+	byYear := map[int][]Holiday{}
+	var matches []Holiday
+	for day := dateOnly(start); !day.After(end); day = day.AddDate(0, 0, 1) {
+		holidays, ok := byYear[day.Year()]
+		if !ok {
+			var err error
+			holidays, err = p.Holidays(ctx, country, day.Year())
+			if err != nil {
+				return nil, err
+			}
+			byYear[day.Year()] = holidays
+		}
+		date := day.Format("2006-01-02")
+		for _, h := range holidays {
+			if h.Date == date {
+				matches = append(matches, h)
+			}
+		}
+	}
+	return matches, nil
+}
+
+//go:embed holidays.json
+var holidaysJSON []byte
+
+// holidayRule describes how to compute one holiday for any year
+type holidayRule struct {
+	Name    string `json:"name"`
+	Date    string `json:"date,omitempty"`   // fixed MM-DD
+	Easter  *int   `json:"easter,omitempty"` // days relative to Easter Sunday
+	Month   int    `json:"month,omitempty"`  // nth weekday of month
+	Weekday string `json:"weekday,omitempty"`
+	Nth     int    `json:"nth,omitempty"` // -1 is the last one
+}
+
+// EmbeddedHolidayProvider computes holidays from the dataset compiled into the binary
+type EmbeddedHolidayProvider struct {
+	rules map[string][]holidayRule
+}
+
+// NewEmbeddedHolidayProvider loads the embedded holiday dataset
+func NewEmbeddedHolidayProvider() *EmbeddedHolidayProvider {
+	var rules map[string][]holidayRule
+	if err := json.Unmarshal(holidaysJSON, &rules); err != nil {
+		panic(fmt.Sprintf("invalid embedded holidays.json: %v", err))
+	}
+	return &EmbeddedHolidayProvider{rules: rules}
+}
+
+// Holidays implements HolidayProvider
+func (p *EmbeddedHolidayProvider) Holidays(ctx context.Context, country string, year int) ([]Holiday, error) {
+	country = strings.ToUpper(country)
+	rules, ok := p.rules[country]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCountry, country)
+	}
+
+	holidays := make([]Holiday, 0, len(rules))
+	for _, rule := range rules {
+		date, err := rule.dateIn(year)
+		if err != nil {
+			return nil, fmt.Errorf("holiday %q: %w", rule.Name, err)
+		}
+		holidays = append(holidays, Holiday{Date: date.Format("2006-01-02"), Name: rule.Name, Country: country})
+	}
+	sort.Slice(holidays, func(i, j int) bool { return holidays[i].Date < holidays[j].Date })
+	return holidays, nil
+}
+
+func (r holidayRule) dateIn(year int) (time.Time, error) {
+	switch {
+	case r.Date != "":
+		d, err := time.Parse("2006-01-02", fmt.Sprintf("%d-%s", year, r.Date))
+		if err != nil {
+			return time.Time{}, err
+		}
+		return d, nil
+	case r.Easter != nil:
+		return easterSunday(year).AddDate(0, 0, *r.Easter), nil
+	case r.Month != 0:
+		wd, ok := weekdays[strings.ToLower(r.Weekday)]
+		if !ok {
+			return time.Time{}, fmt.Errorf("invalid weekday %q", r.Weekday)
+		}
+		return nthWeekday(year, time.Month(r.Month), wd, r.Nth), nil
+	}
+	return time.Time{}, errors.New("rule has no date")
+}
+
+// easterSunday uses the anonymous Gregorian algorithm
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+func nthWeekday(year int, month time.Month, wd time.Weekday, nth int) time.Time {
+	if nth < 0 {
+		last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+		return last.AddDate(0, 0, -((int(last.Weekday()) - int(wd) + 7) % 7))
+	}
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(wd) - int(first.Weekday()) + 7) % 7
+	return first.AddDate(0, 0, offset+7*(nth-1))
+}
+
+// nagerHolidayProvider fetches holidays from the Nager.Date public API
+type nagerHolidayProvider struct {
+	client  *http.Client
+	baseURL string
+}
+
+func (p *nagerHolidayProvider) Holidays(ctx context.Context, country string, year int) ([]Holiday, error) {
+	url := fmt.Sprintf("%s/PublicHolidays/%d/%s", p.baseURL, year, strings.ToUpper(country))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	SetRequestIDHeader(req)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch holidays: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCountry, country)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("holiday API returned %s", resp.Status)
+	}
+
+	var payload []struct {
+		Date      string `json:"date"`
+		LocalName string `json:"localName"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode holidays: %w", err)
+	}
+
+	holidays := make([]Holiday, 0, len(payload))
+	for _, h := range payload {
+		holidays = append(holidays, Holiday{Date: h.Date, Name: h.LocalName, Country: strings.ToUpper(country)})
+	}
+	return holidays, nil
+}
+
+// cachedHolidayProvider memoizes a remote provider per country/year
+type cachedHolidayProvider struct {
+	remote   HolidayProvider
+	fallback HolidayProvider
+
+	mu    sync.Mutex
+	cache map[string][]Holiday
+}
+
+func newCachedHolidayProvider(remote, fallback HolidayProvider) *cachedHolidayProvider {
+	return &cachedHolidayProvider{remote: remote, fallback: fallback, cache: map[string][]Holiday{}}
+}
+
+func (p *cachedHolidayProvider) Holidays(ctx context.Context, country string, year int) ([]Holiday, error) {
+	key := fmt.Sprintf("%s/%d", strings.ToUpper(country), year)
+
+	p.mu.Lock()
+	cached, ok := p.cache[key]
+	p.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	holidays, err := p.remote.Holidays(ctx, country, year)
+	if err != nil {
+		if errors.Is(err, ErrUnknownCountry) {
+			return nil, err
+		}
+		return p.fallback.Holidays(ctx, country, year)
+	}
+
+	p.mu.Lock()
+	p.cache[key] = holidays
+	p.mu.Unlock()
+	return holidays, nil
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbeddedHolidayProvider(t *testing.T) {
+	p := NewEmbeddedHolidayProvider()
+
+	tests := []struct {
+		name    string
+		country string
+		year    int
+		want    Holiday
+		wantErr bool
+	}{
+		{
+			name:    "fixed date",
+			country: "ES",
+			year:    2025,
+			want:    Holiday{Date: "2025-10-12", Name: "Fiesta Nacional de España", Country: "ES"},
+		},
+		{
+			name:    "easter relative",
+			country: "es",
+			year:    2025,
+			want:    Holiday{Date: "2025-04-18", Name: "Viernes Santo", Country: "ES"},
+		},
+		{
+			name:    "nth weekday",
+			country: "US",
+			year:    2025,
+			want:    Holiday{Date: "2025-11-27", Name: "Thanksgiving Day", Country: "US"},
+		},
+		{
+			name:    "last weekday",
+			country: "US",
+			year:    2025,
+			want:    Holiday{Date: "2025-05-26", Name: "Memorial Day", Country: "US"},
+		},
+		{
+			name:    "unknown country",
+			country: "ZZ",
+			year:    2025,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			holidays, err := p.Holidays(context.Background(), tt.country, tt.year)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrUnknownCountry)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Contains(t, holidays, tt.want)
+		})
+	}
+}
+
+func TestHolidaysOn(t *testing.T) {
+	p := NewEmbeddedHolidayProvider()
+	madrid := time.FixedZone("CET", 60*60)
+
+	// 23:30 UTC on Dec 24 is already Christmas in Madrid
+	start := time.Date(2025, 12, 25, 0, 30, 0, 0, madrid)
+	matches, err := HolidaysOn(context.Background(), p, "ES", start, start.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, matches, 1)
+	assert.Equal(t, "Navidad", matches[0].Name)
+
+	matches, err = HolidaysOn(context.Background(), p, "ES", start.AddDate(0, 0, 1), start.AddDate(0, 0, 2))
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+}
@@ -0,0 +1,350 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// BeforeWriteHook checks or enriches an event before it is created or updated. It may
+// modify the event; an error rejects the write.
+type BeforeWriteHook func(ctx context.Context, event *EventDB) error
+
+// AfterWriteHook is told about an event that was created or updated
+type AfterWriteHook func(ctx context.Context, event EventDB)
+
+// AfterChangeHook is told about an event that was updated, with its revision before
+// the update, or nil when it could not be read
+type AfterChangeHook func(ctx context.Context, before *EventDB, after EventDB)
+
+// BeforeDeleteHook checks a deletion; an error rejects it
+type BeforeDeleteHook func(ctx context.Context, id uuid.UUID) error
+
+// AfterDeleteHook is told about a deleted event
+type AfterDeleteHook func(ctx context.Context, id uuid.UUID)
+
+// EventHooks are the points where deployments plug business rules into event writes,
+// such as company-specific booking rules. Hooks run in registration order. Errors of
+// before hooks are returned to the client: a domain error keeps its kind, and any
+// other error is a validation error with its message. After hooks run once the write
+// succeeded, inside the caller's transaction when there is one. Hooks are registered
+// at startup, before the repository serves requests.
+type EventHooks struct {
+	beforeCreate []BeforeWriteHook
+	afterCreate  []AfterWriteHook
+	beforeUpdate []BeforeWriteHook
+	afterUpdate  []AfterWriteHook
+	afterChange  []AfterChangeHook
+	beforeDelete []BeforeDeleteHook
+	afterDelete  []AfterDeleteHook
+}
+
+// NewEventHooks returns an empty set of hooks
+func NewEventHooks() *EventHooks {
+	return &EventHooks{}
+}
+
+// BeforeCreate registers fn to run before an event is created
+func (h *EventHooks) BeforeCreate(fn BeforeWriteHook) {
+	h.beforeCreate = append(h.beforeCreate, fn)
+}
+
+// AfterCreate registers fn to run after an event was created
+func (h *EventHooks) AfterCreate(fn AfterWriteHook) {
+	h.afterCreate = append(h.afterCreate, fn)
+}
+
+// BeforeUpdate registers fn to run before an event is updated
+func (h *EventHooks) BeforeUpdate(fn BeforeWriteHook) {
+	h.beforeUpdate = append(h.beforeUpdate, fn)
+}
+
+// AfterUpdate registers fn to run after an event was updated
+func (h *EventHooks) AfterUpdate(fn AfterWriteHook) {
+	h.afterUpdate = append(h.afterUpdate, fn)
+}
+
+// AfterChange registers fn to run after an event was updated, with the revision it
+// replaced, for hooks that report what changed. Updates then read the event first.
+func (h *EventHooks) AfterChange(fn AfterChangeHook) {
+	h.afterChange = append(h.afterChange, fn)
+}
+
+// BeforeDelete registers fn to run before an event is deleted
+func (h *EventHooks) BeforeDelete(fn BeforeDeleteHook) {
+	h.beforeDelete = append(h.beforeDelete, fn)
+}
+
+// AfterDelete registers fn to run after an event was deleted
+func (h *EventHooks) AfterDelete(fn AfterDeleteHook) {
+	h.afterDelete = append(h.afterDelete, fn)
+}
+
+func runBeforeHooks(ctx context.Context, hooks []BeforeWriteHook, event *EventDB) error {
+	for _, fn := range hooks {
+		if err := fn(ctx, event); err != nil {
+			return hookError(err)
+		}
+	}
+	return nil
+}
+
+func runAfterHooks(ctx context.Context, hooks []AfterWriteHook, event EventDB) {
+	for _, fn := range hooks {
+		fn(ctx, event)
+	}
+}
+
+// hookError keeps the kind of a domain error returned by a hook and turns any other
+// error into a validation error, except failures marked with HookInternalError
+func hookError(err error) error {
+	var internalErr *hookInternalError
+	if errors.As(err, &internalErr) {
+		return internalErr.err
+	}
+	if KindOf(err) != nil {
+		return err
+	}
+	return newDomainError(ErrValidation, err.Error())
+}
+
+// hookInternalError marks a failure of the hook itself rather than a rejection
+type hookInternalError struct {
+	err error
+}
+
+func (e *hookInternalError) Error() string { return e.err.Error() }
+func (e *hookInternalError) Unwrap() error { return e.err }
+
+// HookInternalError marks err, such as a database failure, as a failure of the hook
+// itself, so the write fails with a server error rather than being rejected as invalid
+func HookInternalError(err error) error {
+	return &hookInternalError{err: err}
+}
+
+// Plugin is a set of hooks compiled into the binary. Plugins register themselves from
+// an init function with RegisterPlugin and are enabled by name with PLUGINS.
+type Plugin interface {
+	Name() string
+	Register(hooks *EventHooks) error
+}
+
+var (
+	pluginsMu sync.Mutex
+	plugins   = map[string]Plugin{}
+)
+
+// RegisterPlugin makes p available to LoadPlugins. It panics when the name is taken.
+func RegisterPlugin(p Plugin) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	if _, ok := plugins[p.Name()]; ok {
+		panic("duplicate plugin " + p.Name())
+	}
+	plugins[p.Name()] = p
+}
+
+// Plugins returns the names of the compiled-in plugins
+func Plugins() []string {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadPlugins registers the hooks of the named plugins in order
+func LoadPlugins(hooks *EventHooks, names []string) error {
+	for _, name := range names {
+		pluginsMu.Lock()
+		p, ok := plugins[name]
+		pluginsMu.Unlock()
+		if !ok {
+			return fmt.Errorf("unknown plugin %q (available: %v)", name, Plugins())
+		}
+		if err := p.Register(hooks); err != nil {
+			return fmt.Errorf("failed to load plugin %s: %w", name, err)
+		}
+		log.Printf("Loaded plugin %s", name)
+	}
+	return nil
+}
+
+// HookedEventRepository runs the registered hooks around the writes of an event
+// repository: creates, updates, deletes, imports and pushed sync changes
+type HookedEventRepository struct {
+	EventRepositoryInterface
+	hooks *EventHooks
+}
+
+// NewHookedEventRepository runs hooks around the writes of inner
+func NewHookedEventRepository(inner EventRepositoryInterface, hooks *EventHooks) *HookedEventRepository {
+	return &HookedEventRepository{EventRepositoryInterface: inner, hooks: hooks}
+}
+
+// CreateEvent implements EventRepositoryInterface
+func (r *HookedEventRepository) CreateEvent(ctx context.Context, event EventDB) (*EventDB, error) {
+	if err := runBeforeHooks(ctx, r.hooks.beforeCreate, &event); err != nil {
+		return nil, err
+	}
+	created, err := r.EventRepositoryInterface.CreateEvent(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	runAfterHooks(ctx, r.hooks.afterCreate, *created)
+	return created, nil
+}
+
+// UpdateEvent implements EventRepositoryInterface
+func (r *HookedEventRepository) UpdateEvent(ctx context.Context, event EventDB) (*EventDB, error) {
+	if err := runBeforeHooks(ctx, r.hooks.beforeUpdate, &event); err != nil {
+		return nil, err
+	}
+	before := r.previous(ctx, event.ID)
+	updated, err := r.EventRepositoryInterface.UpdateEvent(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	runAfterHooks(ctx, r.hooks.afterUpdate, *updated)
+	r.runAfterChange(ctx, before, *updated)
+	return updated, nil
+}
+
+// DeleteEvent implements EventRepositoryInterface
+func (r *HookedEventRepository) DeleteEvent(ctx context.Context, id uuid.UUID) error {
+	if err := r.beforeDelete(ctx, id); err != nil {
+		return err
+	}
+	if err := r.EventRepositoryInterface.DeleteEvent(ctx, id); err != nil {
+		return err
+	}
+	r.afterDelete(ctx, id)
+	return nil
+}
+
+// ImportEvents implements EventRepositoryInterface. Imported events go through the
+// create hooks; one rejected event rejects the import.
+func (r *HookedEventRepository) ImportEvents(ctx context.Context, events []EventDB) (int, error) {
+	checked := make([]EventDB, len(events))
+	copy(checked, events)
+	for i := range checked {
+		if err := runBeforeHooks(ctx, r.hooks.beforeCreate, &checked[i]); err != nil {
+			return 0, fmt.Errorf("event %d: %w", i+1, err)
+		}
+	}
+	n, err := r.EventRepositoryInterface.ImportEvents(ctx, checked)
+	if err != nil {
+		return n, err
+	}
+	for _, e := range checked {
+		runAfterHooks(ctx, r.hooks.afterCreate, e)
+	}
+	return n, nil
+}
+
+// ApplySyncChange implements EventRepositoryInterface. A change with BaseVersion 0 is
+// a create, any other an update or delete.
+func (r *HookedEventRepository) ApplySyncChange(ctx context.Context, c SyncChange) (*SyncOutcome, error) {
+	var err error
+	switch {
+	case c.Deleted:
+		err = r.beforeDelete(ctx, c.Event.ID)
+	case c.BaseVersion == 0:
+		err = runBeforeHooks(ctx, r.hooks.beforeCreate, &c.Event)
+	default:
+		err = runBeforeHooks(ctx, r.hooks.beforeUpdate, &c.Event)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var before *EventDB
+	if !c.Deleted && c.BaseVersion != 0 {
+		before = r.previous(ctx, c.Event.ID)
+	}
+
+	out, err := r.EventRepositoryInterface.ApplySyncChange(ctx, c)
+	if err != nil || out.Status != SyncApplied {
+		return out, err
+	}
+	switch {
+	case c.Deleted:
+		r.afterDelete(ctx, c.Event.ID)
+	case c.BaseVersion == 0:
+		runAfterHooks(ctx, r.hooks.afterCreate, c.Event)
+	default:
+		runAfterHooks(ctx, r.hooks.afterUpdate, c.Event)
+		r.runAfterChange(ctx, before, c.Event)
+	}
+	return out, nil
+}
+
+// EventsVersion versions the listings of the wrapped repository when it supports it
+func (r *HookedEventRepository) EventsVersion(ctx context.Context, source, externalID string) (*EventsVersion, error) {
+	return VersionEvents(ctx, r.EventRepositoryInterface, source, externalID)
+}
+
+// FilterEvents filters in the wrapped repository when it supports it
+func (r *HookedEventRepository) FilterEvents(ctx context.Context, f *EventFilter) ([]EventDB, error) {
+	return FilterEvents(ctx, r.EventRepositoryInterface, f)
+}
+
+// GetEventSummaries summarizes in the wrapped repository when it supports it
+func (r *HookedEventRepository) GetEventSummaries(ctx context.Context, f *EventFilter) ([]EventSummary, error) {
+	return SummarizeEvents(ctx, r.EventRepositoryInterface, f)
+}
+
+// SearchEvents searches the wrapped repository when it supports it
+func (r *HookedEventRepository) SearchEvents(ctx context.Context, q SearchQuery) ([]EventSummary, error) {
+	return SearchEvents(ctx, r.EventRepositoryInterface, q)
+}
+
+// Ping checks the wrapped repository's database when it supports it
+func (r *HookedEventRepository) Ping(ctx context.Context) error {
+	return pingRepository(ctx, r.EventRepositoryInterface)
+}
+
+// previous reads the revision an update is about to replace when change hooks are
+// registered. It returns nil when there are none or the event cannot be read; the
+// update itself reports a missing event.
+func (r *HookedEventRepository) previous(ctx context.Context, id uuid.UUID) *EventDB {
+	if len(r.hooks.afterChange) == 0 {
+		return nil
+	}
+	event, err := r.EventRepositoryInterface.GetEventByID(ctx, id)
+	if err != nil {
+		if !errors.Is(err, ErrEventNotFound) {
+			log.Printf("Error reading event %s before update: %v", id, err)
+		}
+		return nil
+	}
+	return event
+}
+
+func (r *HookedEventRepository) runAfterChange(ctx context.Context, before *EventDB, after EventDB) {
+	for _, fn := range r.hooks.afterChange {
+		fn(ctx, before, after)
+	}
+}
+
+func (r *HookedEventRepository) beforeDelete(ctx context.Context, id uuid.UUID) error {
+	for _, fn := range r.hooks.beforeDelete {
+		if err := fn(ctx, id); err != nil {
+			return hookError(err)
+		}
+	}
+	return nil
+}
+
+func (r *HookedEventRepository) afterDelete(ctx context.Context, id uuid.UUID) {
+	for _, fn := range r.hooks.afterDelete {
+		fn(ctx, id)
+	}
+}
@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeRecorder records the events written to it; other methods are not implemented
+type writeRecorder struct {
+	EventRepositoryInterface
+	created []EventDB
+	deleted []uuid.UUID
+}
+
+func (w *writeRecorder) CreateEvent(ctx context.Context, event EventDB) (*EventDB, error) {
+	w.created = append(w.created, event)
+	return &event, nil
+}
+
+func (w *writeRecorder) UpdateEvent(ctx context.Context, event EventDB) (*EventDB, error) {
+	for i := range w.created {
+		if w.created[i].ID == event.ID {
+			w.created[i] = event
+		}
+	}
+	return &event, nil
+}
+
+func (w *writeRecorder) GetEventByID(ctx context.Context, id uuid.UUID) (*EventDB, error) {
+	for _, e := range w.created {
+		if e.ID == id {
+			return &e, nil
+		}
+	}
+	return nil, ErrEventNotFound
+}
+
+func (w *writeRecorder) DeleteEvent(ctx context.Context, id uuid.UUID) error {
+	w.deleted = append(w.deleted, id)
+	return nil
+}
+
+func (w *writeRecorder) ImportEvents(ctx context.Context, events []EventDB) (int, error) {
+	w.created = append(w.created, events...)
+	return len(events), nil
+}
+
+// bookingRules is a plugin that tags titles and refuses some writes
+type bookingRules struct{}
+
+func (bookingRules) Name() string { return "booking_rules" }
+
+func (bookingRules) Register(h *EventHooks) error {
+	h.BeforeCreate(func(ctx context.Context, e *EventDB) error {
+		switch e.Title {
+		case "":
+			return errors.New("title is required by company policy")
+		case "Boardroom":
+			return DomainError(ErrConflict, "the boardroom is booked")
+		}
+		e.Title = "[ACME] " + e.Title
+		return nil
+	})
+	return nil
+}
+
+func TestHookedEventRepository(t *testing.T) {
+	RegisterPlugin(bookingRules{})
+	assert.Panics(t, func() { RegisterPlugin(bookingRules{}) })
+	assert.Error(t, LoadPlugins(NewEventHooks(), []string{"missing"}))
+
+	hooks := NewEventHooks()
+	require.NoError(t, LoadPlugins(hooks, []string{"booking_rules"}))
+	var after []string
+	hooks.AfterCreate(func(ctx context.Context, e EventDB) { after = append(after, e.Title) })
+	protected := uuid.New()
+	hooks.BeforeDelete(func(ctx context.Context, id uuid.UUID) error {
+		if id == protected {
+			return errors.New("event is protected")
+		}
+		return nil
+	})
+
+	inner := &writeRecorder{}
+	repo := NewHookedEventRepository(inner, hooks)
+	ctx := context.Background()
+
+	created, err := repo.CreateEvent(ctx, EventDB{Title: "Standup"})
+	require.NoError(t, err)
+	assert.Equal(t, "[ACME] Standup", created.Title)
+
+	_, err = repo.CreateEvent(ctx, EventDB{})
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.Equal(t, "title is required by company policy", DomainMessage(err))
+
+	_, err = repo.CreateEvent(ctx, EventDB{Title: "Boardroom"})
+	assert.ErrorIs(t, err, ErrConflict)
+
+	_, err = repo.ImportEvents(ctx, []EventDB{{Title: "Retro"}, {}})
+	assert.ErrorIs(t, err, ErrValidation)
+	n, err := repo.ImportEvents(ctx, []EventDB{{Title: "Retro"}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	assert.ErrorIs(t, repo.DeleteEvent(ctx, protected), ErrValidation)
+	assert.NoError(t, repo.DeleteEvent(ctx, uuid.Nil))
+
+	assert.Equal(t, []string{"[ACME] Standup", "[ACME] Retro"}, after)
+	require.Len(t, inner.created, 2)
+	assert.Equal(t, []uuid.UUID{uuid.Nil}, inner.deleted)
+}
@@ -0,0 +1,1007 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLanguage is used when Accept-Language matches no catalog
+const DefaultLanguage = "en"
+
+// catalogs translate English message formats into other languages.
+// English is the key itself, so a missing entry falls back to the original text.
+var catalogs = map[string]map[string]string{
+	"es": {
+		"invalid JSON: %v":                                                    "JSON inválido: %v",
+		"title is required":                                                   "el título es obligatorio",
+		"title must be <= 100 characters":                                     "el título debe tener como máximo 100 caracteres",
+		"description must be <= 10000 characters":                             "la descripción debe tener como máximo 10000 caracteres",
+		"start_time and end_time are required (RFC3339)":                      "start_time y end_time son obligatorios (RFC3339)",
+		"start_time must be before end_time":                                  "start_time debe ser anterior a end_time",
+		"Cannot delete the %s while it has %s":                                "No se puede eliminar %s mientras tenga %s",
+		"Fault injected for resilience testing":                               "Fallo inyectado para pruebas de resiliencia",
+		"fuzzy must be true or false":                                         "fuzzy debe ser true o false",
+		"similarity must be a number above 0 and at most 1":                   "similarity debe ser un número mayor que 0 y como máximo 1",
+		"q is required":                                                       "q es obligatorio",
+		"q must be at most %d characters":                                     "q debe tener como máximo %d caracteres",
+		"view must be full or summary":                                        "view debe ser full o summary",
+		"invalid filter: %s":                                                  "filtro no válido: %s",
+		"and more overlapping events":                                         "y más eventos superpuestos",
+		"end_time and duration_minutes are mutually exclusive":                "end_time y duration_minutes son mutuamente excluyentes",
+		"duration_minutes must be positive and at most a year":                "duration_minutes debe ser positivo y de como máximo un año",
+		"latitude and longitude must be provided together":                    "latitude y longitude deben indicarse juntas",
+		"latitude must be within [-90, 90] and longitude within [-180, 180]":  "latitude debe estar en [-90, 90] y longitude en [-180, 180]",
+		"Request timeout":                                                     "Tiempo de espera agotado",
+		"Failed to create event":                                              "No se pudo crear el evento",
+		"Failed to get events":                                                "No se pudieron obtener los eventos",
+		"Failed to get event":                                                 "No se pudo obtener el evento",
+		"Failed to update event":                                              "No se pudo actualizar el evento",
+		"event updated too often, retry in %d seconds":                        "evento actualizado con demasiada frecuencia, reintente en %d segundos",
+		"Failed to delete event":                                              "No se pudo eliminar el evento",
+		"invalid cursor":                                                      "cursor inválido",
+		"limit must be between 1 and %d":                                      "limit debe estar entre 1 y %d",
+		"Failed to get changes":                                               "No se pudieron obtener los cambios",
+		"changes must contain between 1 and %d entries":                       "changes debe contener entre 1 y %d entradas",
+		"Failed to apply changes":                                             "No se pudieron aplicar los cambios",
+		"id is required":                                                      "el id es obligatorio",
+		"base_version must not be negative":                                   "base_version no puede ser negativo",
+		"event is required unless deleted is true":                            "event es obligatorio salvo que deleted sea true",
+		"Invalid UUID format":                                                 "Formato de UUID inválido",
+		"comment is required when rejecting an event":                         "el comentario es obligatorio al rechazar un evento",
+		"comment must be <= 2000 characters":                                  "el comentario debe tener como máximo 2000 caracteres",
+		"event is not pending review":                                         "el evento no está pendiente de revisión",
+		"Failed to review event":                                              "No se pudo revisar el evento",
+		"Failed to get event reviews":                                         "No se pudieron obtener las revisiones del evento",
+		"Event not found":                                                     "Evento no encontrado",
+		"calendar not found":                                                  "el calendario no existe",
+		"Calendar not found":                                                  "Calendario no encontrado",
+		"event falls on a public holiday: %s (%s)":                            "el evento coincide con un festivo: %s (%s)",
+		"country is required":                                                 "el país es obligatorio",
+		"year must be between 1900 and 2200":                                  "el año debe estar entre 1900 y 2200",
+		"No holiday data for country":                                         "No hay festivos para el país",
+		"Failed to get holidays":                                              "No se pudieron obtener los festivos",
+		"unknown timezone %q":                                                 "zona horaria desconocida %q",
+		"text is required":                                                    "el texto es obligatorio",
+		"could not find a title in text":                                      "no se encontró un título en el texto",
+		"could not find a start time in text":                                 "no se encontró una hora de inicio en el texto",
+		"text has both an end time and a duration":                            "el texto tiene hora de fin y duración a la vez",
+		"description_format must be plain or markdown":                        "description_format debe ser plain o markdown",
+		"input rejected by security policy":                                   "entrada rechazada por la política de seguridad",
+		"authentication required":                                             "autenticación requerida",
+		"invalid or expired token":                                            "token inválido o caducado",
+		"token lacks required scope %s":                                       "el token no tiene el permiso %s",
+		"Your events for the week of %s":                                      "Tus eventos de la semana del %s",
+		"Here is what is coming up in the next 7 days:":                       "Esto es lo que tienes en los próximos 7 días:",
+		"You receive this email because you subscribed to the weekly digest.": "Recibes este correo porque te suscribiste al resumen semanal.",
+		"%s mentioned you on %s":                                              "%s te mencionó en %s",
+		"Someone":                                                             "Alguien",
+		"body is required and must be <= 5000 characters":                     "el texto es obligatorio y debe tener como máximo 5000 caracteres",
+		"Comment not found":                                                   "Comentario no encontrado",
+		"only the author can delete a comment":                                "solo el autor puede eliminar un comentario",
+		"Failed to create comment":                                            "No se pudo crear el comentario",
+		"Failed to get comments":                                              "No se pudieron obtener los comentarios",
+		"Failed to delete comment":                                            "No se pudo eliminar el comentario",
+		"Failed to get activity":                                              "No se pudo obtener la actividad",
+		"Reminder not found":                                                  "Recordatorio no encontrado",
+		"Failed to create reminder":                                           "No se pudo crear el recordatorio",
+		"Failed to get reminders":                                             "No se pudieron obtener los recordatorios",
+		"Failed to get reminder":                                              "No se pudo obtener el recordatorio",
+		"Failed to update reminder":                                           "No se pudo actualizar el recordatorio",
+		"Failed to delete reminder":                                           "No se pudo eliminar el recordatorio",
+		"at most %d reminders per event":                                      "como máximo %d recordatorios por evento",
+		"target must be an email address":                                     "target debe ser una dirección de correo",
+		"target must be an http or https URL":                                 "target debe ser una URL http o https",
+		"reminder time is in the past":                                        "la hora del recordatorio ya pasó",
+		"Reminder: %s":                                                        "Recordatorio: %s",
+		"%s starts on %s.":                                                    "%s comienza el %s.",
+		"Location: %s":                                                        "Lugar: %s",
+		"Push subscription not found":                                         "Suscripción push no encontrada",
+		"Failed to save push subscription":                                    "No se pudo guardar la suscripción push",
+		"Failed to get push subscriptions":                                    "No se pudieron obtener las suscripciones push",
+		"Failed to delete push subscription":                                  "No se pudo eliminar la suscripción push",
+		"endpoint must be an https URL":                                       "endpoint debe ser una URL https",
+		"keys.p256dh must be a base64url P-256 public key":                    "keys.p256dh debe ser una clave pública P-256 en base64url",
+		"keys.auth must be a 16-byte base64url secret":                        "keys.auth debe ser un secreto de 16 bytes en base64url",
+		"%s was changed":                                                      "%s ha cambiado",
+		"Device not found":                                                    "Dispositivo no encontrado",
+		"Failed to register device":                                           "No se pudo registrar el dispositivo",
+		"Failed to get devices":                                               "No se pudieron obtener los dispositivos",
+		"Failed to unregister device":                                         "No se pudo eliminar el dispositivo",
+		"platform %q is not configured":                                       "la plataforma %q no está configurada",
+		"token is required and must be <= %d characters":                      "token es obligatorio y debe tener como máximo %d caracteres",
+		"Cover not found":                                                     "Portada no encontrada",
+		"Failed to upload cover":                                              "Error al subir la portada",
+		"Failed to get cover":                                                 "Error al obtener la portada",
+		"Failed to delete cover":                                              "Error al eliminar la portada",
+		"cover must be at most %d bytes":                                      "la portada debe ocupar como máximo %d bytes",
+		"invalid upload: %v":                                                  "subida no válida: %v",
+		"size must be a positive integer":                                     "size debe ser un entero positivo",
+		"cover must be a JPEG, PNG or GIF image":                              "la portada debe ser una imagen JPEG, PNG o GIF",
+		"cover image is too large":                                            "la imagen de portada es demasiado grande",
+		"size is not an available thumbnail width":                            "size no es un ancho de miniatura disponible",
+		"Failed to rotate feed token":                                         "Error al rotar el token del feed",
+		"only the calendar's owner can manage its feed":                       "solo el propietario del calendario puede gestionar su feed",
+		"No upcoming events.":                                                 "No hay eventos próximos.",
+		"Previous month":                                                      "Mes anterior",
+		"Next month":                                                          "Mes siguiente",
+		"view must be month or agenda":                                        "view debe ser month o agenda",
+		"month must be formatted as YYYY-MM":                                  "month debe tener el formato AAAA-MM",
+		"Agenda":                                                              "Agenda",
+		"Page %d of %d":                                                       "Página %d de %d",
+		"until %s":                                                            "hasta %s",
+		"No events scheduled.":                                                "No hay eventos programados.",
+		"Failed to export events":                                             "Error al exportar los eventos",
+		"from must be an RFC 3339 time or a YYYY-MM-DD date":                  "from debe ser una hora RFC 3339 o una fecha AAAA-MM-DD",
+		"to must be an RFC 3339 time or a YYYY-MM-DD date":                    "to debe ser una hora RFC 3339 o una fecha AAAA-MM-DD",
+		"to must be after from and at most %d days later":                     "to debe ser posterior a from y como máximo %d días después",
+		"bucket must be hour, day or week":                                    "bucket debe ser hour, day o week",
+		"from and to are required":                                            "from y to son obligatorios",
+		"to must be after from":                                               "to debe ser posterior a from",
+		"a heatmap can have at most %d buckets":                               "un mapa de calor puede tener como máximo %d intervalos",
+		"Resource not found":                                                  "Recurso no encontrado",
+		"resource not found":                                                  "el recurso no existe",
+		"resource is already booked at that time":                             "el recurso ya está reservado a esa hora",
+		"resource has bookings":                                               "el recurso tiene reservas",
+		"resource %s is already booked by event %s from %s to %s":             "el recurso %s ya está reservado por el evento %s de %s a %s",
+		"kind must be room or equipment":                                      "kind debe ser room o equipment",
+		"capacity must be positive":                                           "capacity debe ser positiva",
+		"min_capacity must be a non-negative integer":                         "min_capacity debe ser un entero no negativo",
+		"Failed to create resource":                                           "No se pudo crear el recurso",
+		"Failed to get resources":                                             "No se pudieron obtener los recursos",
+		"Failed to get resource":                                              "No se pudo obtener el recurso",
+		"Failed to update resource":                                           "No se pudo actualizar el recurso",
+		"Failed to delete resource":                                           "No se pudo eliminar el recurso",
+		"Failed to get bookings":                                              "No se pudieron obtener las reservas",
+		"Failed to book resources":                                            "No se pudieron reservar los recursos",
+		"event overlaps another event in an exclusive calendar":               "el evento se solapa con otro evento en un calendario exclusivo",
+		"calendar has overlapping events":                                     "el calendario tiene eventos que se solapan",
+		"only the calendar's owner can change it":                             "solo el propietario del calendario puede cambiarlo",
+		"price_cents and currency must be provided together":                  "price_cents y currency deben indicarse juntos",
+		"price_cents must not be negative":                                    "price_cents no puede ser negativo",
+		"currency must be an ISO 4217 code such as EUR":                       "currency debe ser un código ISO 4217 como EUR",
+		"ticket_quota must be positive":                                       "ticket_quota debe ser positivo",
+		"quantity must be between 1 and %d":                                   "quantity debe estar entre 1 y %d",
+		"tickets are only sold for published events":                          "solo se venden entradas de eventos publicados",
+		"Reservation not found":                                               "Reserva no encontrada",
+		"reservation not found":                                               "la reserva no existe",
+		"event does not have tickets":                                         "el evento no tiene entradas",
+		"not enough tickets left":                                             "no quedan suficientes entradas",
+		"reservation has expired or was cancelled":                            "la reserva ha caducado o se canceló",
+		"Failed to get tickets":                                               "No se pudieron obtener las entradas",
+		"Failed to reserve tickets":                                           "No se pudieron reservar las entradas",
+		"Failed to get reservation":                                           "No se pudo obtener la reserva",
+		"Failed to cancel reservation":                                        "No se pudo cancelar la reserva",
+		"Failed to confirm reservation":                                       "No se pudo confirmar la reserva",
+		"only confirmed reservations paid through the payment provider can be refunded": "solo se pueden reembolsar las reservas confirmadas pagadas a través del proveedor de pagos",
+		"paid reservations are refunded, not cancelled":                                 "las reservas pagadas se reembolsan, no se cancelan",
+		"Failed to refund reservation":                                                  "No se pudo reembolsar la reserva",
+		"Failed to start checkout":                                                      "No se pudo iniciar el pago",
+		"invalid webhook signature":                                                     "firma de webhook no válida",
+		"Failed to handle payment":                                                      "No se pudo procesar el pago",
+		"organization not found":                                                        "organización no encontrada",
+		"member not found":                                                              "miembro no encontrado",
+		"invitation not found":                                                          "invitación no encontrada",
+		"invitation has expired":                                                        "la invitación ha caducado",
+		"an organization must keep at least one owner":                                  "una organización debe conservar al menos un propietario",
+		"organization still owns calendars":                                             "la organización aún tiene calendarios",
+		"Organization not found":                                                        "Organización no encontrada",
+		"Member not found":                                                              "Miembro no encontrado",
+		"Invitation not found":                                                          "Invitación no encontrada",
+		"email must be a valid email address":                                           "email debe ser una dirección de correo válida",
+		"role must be owner, admin or member":                                           "role debe ser owner, admin o member",
+		"only owners can change who owns the organization":                              "solo los propietarios pueden cambiar quién es propietario de la organización",
+		"this requires the %s role in the organization":                                 "esto requiere el rol %s en la organización",
+		"token is required":                                                             "token es obligatorio",
+		"accepting an invitation requires a user":                                       "aceptar una invitación requiere un usuario",
+		"Invitation to join %s":                                                         "Invitación para unirte a %s",
+		"%s invited you to join %s as %s.":                                              "%s te ha invitado a unirte a %s como %s.",
+		"Accept the invitation at %s":                                                   "Acepta la invitación en %s",
+		"Accept the invitation by sending this token to POST /invitations/accept: %s": "Acepta la invitación enviando este token a POST /invitations/accept: %s",
+		"The invitation expires on %s.":                                               "La invitación caduca el %s.",
+		"Failed to create organization":                                               "No se pudo crear la organización",
+		"Failed to get organizations":                                                 "No se pudieron obtener las organizaciones",
+		"Failed to get organization":                                                  "No se pudo obtener la organización",
+		"Failed to update organization":                                               "No se pudo actualizar la organización",
+		"Failed to delete organization":                                               "No se pudo eliminar la organización",
+		"Failed to get members":                                                       "No se pudieron obtener los miembros",
+		"Failed to set member":                                                        "No se pudo guardar el miembro",
+		"Failed to remove member":                                                     "No se pudo quitar el miembro",
+		"Failed to create invitation":                                                 "No se pudo crear la invitación",
+		"Failed to get invitations":                                                   "No se pudieron obtener las invitaciones",
+		"Failed to delete invitation":                                                 "No se pudo eliminar la invitación",
+		"Failed to accept invitation":                                                 "No se pudo aceptar la invitación",
+		"not allowed to change events in this calendar":                               "no tiene permiso para cambiar eventos en este calendario",
+		"only the calendar's owner can manage its delegates":                          "solo el propietario del calendario puede gestionar sus delegados",
+		"the calendar's owner cannot be a delegate":                                   "el propietario del calendario no puede ser un delegado",
+		"permissions must list create, edit or delete":                                "permissions debe incluir create, edit o delete",
+		"user_id is required and must be <= 255 characters":                           "user_id es obligatorio y debe tener <= 255 caracteres",
+		"expires_at must be in the future":                                            "expires_at debe estar en el futuro",
+		"Delegate not found":                                                          "Delegado no encontrado",
+		"Failed to set delegate":                                                      "Error al asignar el delegado",
+		"Failed to delete delegate":                                                   "Error al eliminar el delegado",
+		"Busy":                                                                        "Ocupado",
+		"visibility must be full or busy":                                             "visibility debe ser full o busy",
+		"Webhook not found":                                                           "Webhook no encontrado",
+		"Delivery not found":                                                          "Entrega no encontrada",
+		"url must be an http or https URL":                                            "url debe ser una URL http o https",
+		"only admins can add webhooks for every calendar":                             "solo los administradores pueden añadir webhooks para todos los calendarios",
+		"only the calendar's owner can add webhooks to it":                            "solo el propietario del calendario puede añadirle webhooks",
+		"status must be pending, succeeded or failed":                                 "status debe ser pending, succeeded o failed",
+		"Failed to create webhook":                                                    "No se pudo crear el webhook",
+		"Failed to get webhooks":                                                      "No se pudieron obtener los webhooks",
+		"Failed to get webhook":                                                       "No se pudo obtener el webhook",
+		"Failed to delete webhook":                                                    "No se pudo eliminar el webhook",
+		"Failed to get deliveries":                                                    "No se pudieron obtener las entregas",
+		"Failed to get delivery":                                                      "No se pudo obtener la entrega",
+		"Failed to replay delivery":                                                   "No se pudo reenviar la entrega",
+		"grace_minutes must be between 0 and %d":                                      "grace_minutes debe estar entre 0 y %d",
+		"Failed to rotate webhook secret":                                             "No se pudo rotar el secreto del webhook",
+		"name must be 1-63 lowercase letters, digits, - or _":                         "el nombre debe tener de 1 a 63 letras minúsculas, dígitos, - o _",
+		"mapping must have title and start_time":                                      "mapping debe incluir title y start_time",
+		"Ingest source not found":                                                     "Fuente de ingesta no encontrada",
+		"an ingest source with this name already exists":                              "ya existe una fuente de ingesta con este nombre",
+		"Failed to create ingest source":                                              "No se pudo crear la fuente de ingesta",
+		"Failed to get ingest sources":                                                "No se pudieron obtener las fuentes de ingesta",
+		"Failed to get ingest source":                                                 "No se pudo obtener la fuente de ingesta",
+		"Failed to update ingest source":                                              "No se pudo actualizar la fuente de ingesta",
+		"Failed to delete ingest source":                                              "No se pudo eliminar la fuente de ingesta",
+		"Failed to ingest payload":                                                    "No se pudo ingerir la carga",
+		"payload must be at most %d bytes":                                            "la carga debe tener como máximo %d bytes",
+		"failed to read payload: %v":                                                  "no se pudo leer la carga: %v",
+		"invalid ingest signature":                                                    "firma de ingesta no válida",
+		"unknown event type %s":                                                       "tipo de evento desconocido %s",
+		"at most %d events can be declared":                                           "se pueden declarar como máximo %d eventos",
+		"client_key is required and must be <= %d characters":                         "client_key es obligatorio y debe tener <= %d caracteres",
+		"declared events belong to the calendar of the URL":                           "los eventos declarados pertenecen al calendario de la URL",
+		"only the calendar's owner can sync its events":                               "solo el propietario del calendario puede sincronizar sus eventos",
+		"Failed to sync events":                                                       "No se pudieron sincronizar los eventos",
+		"another event of the calendar has this client_key":                           "otro evento del calendario tiene este client_key",
+		"every event needs a client_key":                                              "cada evento necesita un client_key",
+		"external_id and source must be provided together":                            "external_id y source deben indicarse juntos",
+		"external_id must be 1-255 characters and source 1-100":                       "external_id debe tener entre 1 y 255 caracteres y source entre 1 y 100",
+		"an event with this source and external_id already exists":                    "ya existe un evento con este source y external_id",
+		"event ended in the past":                                                     "el evento terminó en el pasado",
+		"event lasts longer than %s":                                                  "el evento dura más de %s",
+		"title is all capitals":                                                       "el título está todo en mayúsculas",
+		"events that already ended cannot be created":                                 "no se pueden crear eventos que ya terminaron",
+		"the admin key has no personal data":                                          "la clave de administración no tiene datos personales",
+		"Failed to queue export":                                                      "No se pudo poner en cola la exportación",
+		"Failed to get export":                                                        "No se pudo obtener la exportación",
+		"Export not found":                                                            "Exportación no encontrada",
+		"confirm must be your user ID":                                                "confirm debe ser su ID de usuario",
+		"Failed to delete account":                                                    "No se pudo eliminar la cuenta",
+		"the admin key has no preferences":                                            "la clave de administración no tiene preferencias",
+		"Failed to get profile":                                                       "No se pudo obtener el perfil",
+		"Failed to get preferences":                                                   "No se pudieron obtener las preferencias",
+		"Failed to update preferences":                                                "No se pudieron actualizar las preferencias",
+		"at most %d reminder_offsets":                                                 "como máximo %d reminder_offsets",
+		"reminder_offsets must be between 0 and %d":                                   "reminder_offsets debe estar entre 0 y %d",
+		"reminder_offsets must not repeat":                                            "reminder_offsets no debe repetirse",
+		"week_start must be monday, sunday or saturday":                               "week_start debe ser monday, sunday o saturday",
+		"Failed to get sessions":                                                      "No se pudieron obtener las sesiones",
+		"Failed to revoke session":                                                    "No se pudo revocar la sesión",
+		"Failed to revoke sessions":                                                   "No se pudieron revocar las sesiones",
+		"the admin key cannot use two-factor authentication":                          "la clave de administración no puede usar la autenticación en dos pasos",
+		"organization %s requires two-factor authentication":                          "la organización %s exige la autenticación en dos pasos",
+		"organization %s requires two-factor authentication, enable it at /me/2fa first": "la organización %s exige la autenticación en dos pasos, actívela primero en /me/2fa",
+		"two-factor authentication is not enabled on this server":                        "la autenticación en dos pasos no está habilitada en este servidor",
+		"enable two-factor authentication before requiring it":                           "active la autenticación en dos pasos antes de exigirla",
+		"Two-factor authentication is not enabled":                                       "La autenticación en dos pasos no está activada",
+		"two-factor authentication is already enabled":                                   "la autenticación en dos pasos ya está activada",
+		"a two-factor code is required in the X-Two-Factor-Code header":                  "se requiere un código de dos pasos en la cabecera X-Two-Factor-Code",
+		"invalid two-factor code":                                                        "código de dos pasos no válido",
+		"too many failed authentication attempts, retry in %d seconds":                   "demasiados intentos de autenticación fallidos, reintente en %d segundos",
+		"Down for maintenance, retry later":                                              "En mantenimiento, reintente más tarde",
+		"Read-only during maintenance, changes are not accepted for now":                 "Solo lectura durante el mantenimiento, por ahora no se aceptan cambios",
+		"Failed to set maintenance mode":                                                 "No se pudo cambiar el modo de mantenimiento",
+		"Invalid value":                                                                  "Valor no válido",
+		"Conflicts with an existing record":                                              "Entra en conflicto con un registro existente",
+		"Refers to a missing record or breaks a data rule":                               "Hace referencia a un registro inexistente o incumple una regla de datos",
+		"Service temporarily unavailable, retry shortly":                                 "Servicio no disponible temporalmente, reintente en breve",
+		"invalid %s %q: expected %s":                                                     "%s inválido %q: se esperaba %s",
+		"a UUID":                                                                         "un UUID",
+		"a UUID or short ID":                                                             "un UUID o ID corto",
+		"an RFC 3339 time":                                                               "una hora RFC 3339",
+	},
+	"fr": {
+		"invalid JSON: %v":                                                    "JSON invalide : %v",
+		"title is required":                                                   "le titre est obligatoire",
+		"title must be <= 100 characters":                                     "le titre doit comporter au plus 100 caractères",
+		"description must be <= 10000 characters":                             "la description doit comporter au plus 10000 caractères",
+		"start_time and end_time are required (RFC3339)":                      "start_time et end_time sont obligatoires (RFC3339)",
+		"start_time must be before end_time":                                  "start_time doit précéder end_time",
+		"Cannot delete the %s while it has %s":                                "Impossible de supprimer %s tant qu'il a %s",
+		"Fault injected for resilience testing":                               "Panne injectée pour les tests de résilience",
+		"fuzzy must be true or false":                                         "fuzzy doit être true ou false",
+		"similarity must be a number above 0 and at most 1":                   "similarity doit être un nombre supérieur à 0 et au plus égal à 1",
+		"q is required":                                                       "q est obligatoire",
+		"q must be at most %d characters":                                     "q doit comporter au plus %d caractères",
+		"view must be full or summary":                                        "view doit valoir full ou summary",
+		"invalid filter: %s":                                                  "filtre non valide : %s",
+		"and more overlapping events":                                         "et d'autres événements qui se chevauchent",
+		"end_time and duration_minutes are mutually exclusive":                "end_time et duration_minutes s'excluent mutuellement",
+		"duration_minutes must be positive and at most a year":                "duration_minutes doit être positif et d'au plus un an",
+		"latitude and longitude must be provided together":                    "latitude et longitude doivent être fournies ensemble",
+		"latitude must be within [-90, 90] and longitude within [-180, 180]":  "latitude doit être dans [-90, 90] et longitude dans [-180, 180]",
+		"Request timeout":                                                     "Délai de requête dépassé",
+		"Failed to create event":                                              "Impossible de créer l'événement",
+		"Failed to get events":                                                "Impossible de récupérer les événements",
+		"Failed to get event":                                                 "Impossible de récupérer l'événement",
+		"Failed to update event":                                              "Impossible de mettre à jour l'événement",
+		"event updated too often, retry in %d seconds":                        "événement modifié trop souvent, réessayez dans %d secondes",
+		"Failed to delete event":                                              "Impossible de supprimer l'événement",
+		"invalid cursor":                                                      "curseur invalide",
+		"limit must be between 1 and %d":                                      "limit doit être compris entre 1 et %d",
+		"Failed to get changes":                                               "Impossible de récupérer les modifications",
+		"changes must contain between 1 and %d entries":                       "changes doit contenir entre 1 et %d entrées",
+		"Failed to apply changes":                                             "Impossible d'appliquer les modifications",
+		"id is required":                                                      "l'id est obligatoire",
+		"base_version must not be negative":                                   "base_version ne doit pas être négatif",
+		"event is required unless deleted is true":                            "event est obligatoire sauf si deleted vaut true",
+		"Invalid UUID format":                                                 "Format d'UUID invalide",
+		"comment is required when rejecting an event":                         "un commentaire est requis pour refuser un événement",
+		"comment must be <= 2000 characters":                                  "le commentaire doit contenir au plus 2000 caractères",
+		"event is not pending review":                                         "l'événement n'est pas en attente de validation",
+		"Failed to review event":                                              "Impossible de valider l'événement",
+		"Failed to get event reviews":                                         "Impossible de récupérer les validations de l'événement",
+		"Event not found":                                                     "Événement introuvable",
+		"calendar not found":                                                  "le calendrier n'existe pas",
+		"Calendar not found":                                                  "Calendrier introuvable",
+		"event falls on a public holiday: %s (%s)":                            "l'événement tombe un jour férié : %s (%s)",
+		"country is required":                                                 "le pays est obligatoire",
+		"year must be between 1900 and 2200":                                  "l'année doit être comprise entre 1900 et 2200",
+		"No holiday data for country":                                         "Aucun jour férié pour ce pays",
+		"Failed to get holidays":                                              "Impossible de récupérer les jours fériés",
+		"unknown timezone %q":                                                 "fuseau horaire inconnu %q",
+		"text is required":                                                    "le texte est obligatoire",
+		"could not find a title in text":                                      "aucun titre trouvé dans le texte",
+		"could not find a start time in text":                                 "aucune heure de début trouvée dans le texte",
+		"text has both an end time and a duration":                            "le texte contient à la fois une heure de fin et une durée",
+		"description_format must be plain or markdown":                        "description_format doit être plain ou markdown",
+		"input rejected by security policy":                                   "entrée rejetée par la politique de sécurité",
+		"authentication required":                                             "authentification requise",
+		"invalid or expired token":                                            "jeton invalide ou expiré",
+		"token lacks required scope %s":                                       "le jeton n'a pas la permission %s",
+		"Your events for the week of %s":                                      "Vos événements de la semaine du %s",
+		"Here is what is coming up in the next 7 days:":                       "Voici ce qui vous attend dans les 7 prochains jours :",
+		"You receive this email because you subscribed to the weekly digest.": "Vous recevez cet e-mail car vous êtes abonné au résumé hebdomadaire.",
+		"%s mentioned you on %s":                                              "%s vous a mentionné dans %s",
+		"Someone":                                                             "Quelqu'un",
+		"body is required and must be <= 5000 characters":                     "le texte est obligatoire et doit contenir au plus 5000 caractères",
+		"Comment not found":                                                   "Commentaire introuvable",
+		"only the author can delete a comment":                                "seul l'auteur peut supprimer un commentaire",
+		"Failed to create comment":                                            "Impossible de créer le commentaire",
+		"Failed to get comments":                                              "Impossible de récupérer les commentaires",
+		"Failed to delete comment":                                            "Impossible de supprimer le commentaire",
+		"Failed to get activity":                                              "Impossible de récupérer l'activité",
+		"Reminder not found":                                                  "Rappel introuvable",
+		"Failed to create reminder":                                           "Impossible de créer le rappel",
+		"Failed to get reminders":                                             "Impossible de récupérer les rappels",
+		"Failed to get reminder":                                              "Impossible de récupérer le rappel",
+		"Failed to update reminder":                                           "Impossible de mettre à jour le rappel",
+		"Failed to delete reminder":                                           "Impossible de supprimer le rappel",
+		"at most %d reminders per event":                                      "au plus %d rappels par événement",
+		"target must be an email address":                                     "target doit être une adresse e-mail",
+		"target must be an http or https URL":                                 "target doit être une URL http ou https",
+		"reminder time is in the past":                                        "l'heure du rappel est passée",
+		"Reminder: %s":                                                        "Rappel : %s",
+		"%s starts on %s.":                                                    "%s commence le %s.",
+		"Location: %s":                                                        "Lieu : %s",
+		"Push subscription not found":                                         "Abonnement push introuvable",
+		"Failed to save push subscription":                                    "Impossible d'enregistrer l'abonnement push",
+		"Failed to get push subscriptions":                                    "Impossible de récupérer les abonnements push",
+		"Failed to delete push subscription":                                  "Impossible de supprimer l'abonnement push",
+		"endpoint must be an https URL":                                       "endpoint doit être une URL https",
+		"keys.p256dh must be a base64url P-256 public key":                    "keys.p256dh doit être une clé publique P-256 en base64url",
+		"keys.auth must be a 16-byte base64url secret":                        "keys.auth doit être un secret de 16 octets en base64url",
+		"%s was changed":                                                      "%s a été modifié",
+		"Device not found":                                                    "Appareil introuvable",
+		"Failed to register device":                                           "Impossible d'enregistrer l'appareil",
+		"Failed to get devices":                                               "Impossible de récupérer les appareils",
+		"Failed to unregister device":                                         "Impossible de supprimer l'appareil",
+		"platform %q is not configured":                                       "la plateforme %q n'est pas configurée",
+		"token is required and must be <= %d characters":                      "token est obligatoire et doit comporter au plus %d caractères",
+		"Cover not found":                                                     "Couverture introuvable",
+		"Failed to upload cover":                                              "Échec de l'envoi de la couverture",
+		"Failed to get cover":                                                 "Échec de la récupération de la couverture",
+		"Failed to delete cover":                                              "Échec de la suppression de la couverture",
+		"cover must be at most %d bytes":                                      "la couverture doit faire au plus %d octets",
+		"invalid upload: %v":                                                  "envoi invalide : %v",
+		"size must be a positive integer":                                     "size doit être un entier positif",
+		"cover must be a JPEG, PNG or GIF image":                              "la couverture doit être une image JPEG, PNG ou GIF",
+		"cover image is too large":                                            "l'image de couverture est trop grande",
+		"size is not an available thumbnail width":                            "size n'est pas une largeur de miniature disponible",
+		"Failed to rotate feed token":                                         "Échec du renouvellement du jeton du flux",
+		"only the calendar's owner can manage its feed":                       "seul le propriétaire du calendrier peut gérer son flux",
+		"No upcoming events.":                                                 "Aucun événement à venir.",
+		"Previous month":                                                      "Mois précédent",
+		"Next month":                                                          "Mois suivant",
+		"view must be month or agenda":                                        "view doit être month ou agenda",
+		"month must be formatted as YYYY-MM":                                  "month doit être au format AAAA-MM",
+		"Agenda":                                                              "Programme",
+		"Page %d of %d":                                                       "Page %d sur %d",
+		"until %s":                                                            "jusqu'au %s",
+		"No events scheduled.":                                                "Aucun événement prévu.",
+		"Failed to export events":                                             "Échec de l'export des événements",
+		"from must be an RFC 3339 time or a YYYY-MM-DD date":                  "from doit être une heure RFC 3339 ou une date AAAA-MM-JJ",
+		"to must be an RFC 3339 time or a YYYY-MM-DD date":                    "to doit être une heure RFC 3339 ou une date AAAA-MM-JJ",
+		"to must be after from and at most %d days later":                     "to doit être après from et au plus %d jours plus tard",
+		"bucket must be hour, day or week":                                    "bucket doit être hour, day ou week",
+		"from and to are required":                                            "from et to sont obligatoires",
+		"to must be after from":                                               "to doit être postérieur à from",
+		"a heatmap can have at most %d buckets":                               "une carte de chaleur peut avoir au plus %d intervalles",
+		"Resource not found":                                                  "Ressource introuvable",
+		"resource not found":                                                  "la ressource n'existe pas",
+		"resource is already booked at that time":                             "la ressource est déjà réservée à cette heure",
+		"resource has bookings":                                               "la ressource a des réservations",
+		"resource %s is already booked by event %s from %s to %s":             "la ressource %s est déjà réservée par l'événement %s de %s à %s",
+		"kind must be room or equipment":                                      "kind doit être room ou equipment",
+		"capacity must be positive":                                           "capacity doit être positive",
+		"min_capacity must be a non-negative integer":                         "min_capacity doit être un entier positif ou nul",
+		"Failed to create resource":                                           "Impossible de créer la ressource",
+		"Failed to get resources":                                             "Impossible d'obtenir les ressources",
+		"Failed to get resource":                                              "Impossible d'obtenir la ressource",
+		"Failed to update resource":                                           "Impossible de mettre à jour la ressource",
+		"Failed to delete resource":                                           "Impossible de supprimer la ressource",
+		"Failed to get bookings":                                              "Impossible d'obtenir les réservations",
+		"Failed to book resources":                                            "Impossible de réserver les ressources",
+		"event overlaps another event in an exclusive calendar":               "l'événement chevauche un autre événement dans un calendrier exclusif",
+		"calendar has overlapping events":                                     "le calendrier contient des événements qui se chevauchent",
+		"only the calendar's owner can change it":                             "seul le propriétaire du calendrier peut le modifier",
+		"price_cents and currency must be provided together":                  "price_cents et currency doivent être fournis ensemble",
+		"price_cents must not be negative":                                    "price_cents ne peut pas être négatif",
+		"currency must be an ISO 4217 code such as EUR":                       "currency doit être un code ISO 4217 comme EUR",
+		"ticket_quota must be positive":                                       "ticket_quota doit être positif",
+		"quantity must be between 1 and %d":                                   "quantity doit être compris entre 1 et %d",
+		"tickets are only sold for published events":                          "les billets ne sont vendus que pour les événements publiés",
+		"Reservation not found":                                               "Réservation introuvable",
+		"reservation not found":                                               "la réservation n'existe pas",
+		"event does not have tickets":                                         "l'événement n'a pas de billets",
+		"not enough tickets left":                                             "il ne reste pas assez de billets",
+		"reservation has expired or was cancelled":                            "la réservation a expiré ou a été annulée",
+		"Failed to get tickets":                                               "Impossible d'obtenir les billets",
+		"Failed to reserve tickets":                                           "Impossible de réserver les billets",
+		"Failed to get reservation":                                           "Impossible d'obtenir la réservation",
+		"Failed to cancel reservation":                                        "Impossible d'annuler la réservation",
+		"Failed to confirm reservation":                                       "Impossible de confirmer la réservation",
+		"only confirmed reservations paid through the payment provider can be refunded": "seules les réservations confirmées payées via le prestataire de paiement peuvent être remboursées",
+		"paid reservations are refunded, not cancelled":                                 "les réservations payées sont remboursées, pas annulées",
+		"Failed to refund reservation":                                                  "Impossible de rembourser la réservation",
+		"Failed to start checkout":                                                      "Impossible de démarrer le paiement",
+		"invalid webhook signature":                                                     "signature de webhook invalide",
+		"Failed to handle payment":                                                      "Impossible de traiter le paiement",
+		"organization not found":                                                        "organisation introuvable",
+		"member not found":                                                              "membre introuvable",
+		"invitation not found":                                                          "invitation introuvable",
+		"invitation has expired":                                                        "l'invitation a expiré",
+		"an organization must keep at least one owner":                                  "une organisation doit garder au moins un propriétaire",
+		"organization still owns calendars":                                             "l'organisation possède encore des calendriers",
+		"Organization not found":                                                        "Organisation introuvable",
+		"Member not found":                                                              "Membre introuvable",
+		"Invitation not found":                                                          "Invitation introuvable",
+		"email must be a valid email address":                                           "email doit être une adresse e-mail valide",
+		"role must be owner, admin or member":                                           "role doit être owner, admin ou member",
+		"only owners can change who owns the organization":                              "seuls les propriétaires peuvent changer qui possède l'organisation",
+		"this requires the %s role in the organization":                                 "cela nécessite le rôle %s dans l'organisation",
+		"token is required":                                                             "token est obligatoire",
+		"accepting an invitation requires a user":                                       "accepter une invitation nécessite un utilisateur",
+		"Invitation to join %s":                                                         "Invitation à rejoindre %s",
+		"%s invited you to join %s as %s.":                                              "%s vous invite à rejoindre %s en tant que %s.",
+		"Accept the invitation at %s":                                                   "Acceptez l'invitation sur %s",
+		"Accept the invitation by sending this token to POST /invitations/accept: %s": "Acceptez l'invitation en envoyant ce jeton à POST /invitations/accept : %s",
+		"The invitation expires on %s.":                                               "L'invitation expire le %s.",
+		"Failed to create organization":                                               "Impossible de créer l'organisation",
+		"Failed to get organizations":                                                 "Impossible d'obtenir les organisations",
+		"Failed to get organization":                                                  "Impossible d'obtenir l'organisation",
+		"Failed to update organization":                                               "Impossible de mettre à jour l'organisation",
+		"Failed to delete organization":                                               "Impossible de supprimer l'organisation",
+		"Failed to get members":                                                       "Impossible d'obtenir les membres",
+		"Failed to set member":                                                        "Impossible d'enregistrer le membre",
+		"Failed to remove member":                                                     "Impossible de retirer le membre",
+		"Failed to create invitation":                                                 "Impossible de créer l'invitation",
+		"Failed to get invitations":                                                   "Impossible d'obtenir les invitations",
+		"Failed to delete invitation":                                                 "Impossible de supprimer l'invitation",
+		"Failed to accept invitation":                                                 "Impossible d'accepter l'invitation",
+		"not allowed to change events in this calendar":                               "vous n'êtes pas autorisé à modifier les événements de ce calendrier",
+		"only the calendar's owner can manage its delegates":                          "seul le propriétaire du calendrier peut gérer ses délégués",
+		"the calendar's owner cannot be a delegate":                                   "le propriétaire du calendrier ne peut pas être un délégué",
+		"permissions must list create, edit or delete":                                "permissions doit contenir create, edit ou delete",
+		"user_id is required and must be <= 255 characters":                           "user_id est obligatoire et doit faire <= 255 caractères",
+		"expires_at must be in the future":                                            "expires_at doit être dans le futur",
+		"Delegate not found":                                                          "Délégué introuvable",
+		"Failed to set delegate":                                                      "Échec de l'attribution du délégué",
+		"Failed to delete delegate":                                                   "Échec de la suppression du délégué",
+		"Busy":                                                                        "Occupé",
+		"visibility must be full or busy":                                             "visibility doit être full ou busy",
+		"Webhook not found":                                                           "Webhook introuvable",
+		"Delivery not found":                                                          "Livraison introuvable",
+		"url must be an http or https URL":                                            "url doit être une URL http ou https",
+		"only admins can add webhooks for every calendar":                             "seuls les administrateurs peuvent ajouter des webhooks pour tous les calendriers",
+		"only the calendar's owner can add webhooks to it":                            "seul le propriétaire du calendrier peut y ajouter des webhooks",
+		"status must be pending, succeeded or failed":                                 "status doit être pending, succeeded ou failed",
+		"Failed to create webhook":                                                    "Impossible de créer le webhook",
+		"Failed to get webhooks":                                                      "Impossible d'obtenir les webhooks",
+		"Failed to get webhook":                                                       "Impossible d'obtenir le webhook",
+		"Failed to delete webhook":                                                    "Impossible de supprimer le webhook",
+		"Failed to get deliveries":                                                    "Impossible d'obtenir les livraisons",
+		"Failed to get delivery":                                                      "Impossible d'obtenir la livraison",
+		"Failed to replay delivery":                                                   "Impossible de rejouer la livraison",
+		"grace_minutes must be between 0 and %d":                                      "grace_minutes doit être compris entre 0 et %d",
+		"Failed to rotate webhook secret":                                             "Impossible de renouveler le secret du webhook",
+		"name must be 1-63 lowercase letters, digits, - or _":                         "le nom doit comporter de 1 à 63 lettres minuscules, chiffres, - ou _",
+		"mapping must have title and start_time":                                      "mapping doit contenir title et start_time",
+		"Ingest source not found":                                                     "Source d'ingestion introuvable",
+		"an ingest source with this name already exists":                              "une source d'ingestion portant ce nom existe déjà",
+		"Failed to create ingest source":                                              "Impossible de créer la source d'ingestion",
+		"Failed to get ingest sources":                                                "Impossible de récupérer les sources d'ingestion",
+		"Failed to get ingest source":                                                 "Impossible de récupérer la source d'ingestion",
+		"Failed to update ingest source":                                              "Impossible de mettre à jour la source d'ingestion",
+		"Failed to delete ingest source":                                              "Impossible de supprimer la source d'ingestion",
+		"Failed to ingest payload":                                                    "Impossible d'ingérer la charge utile",
+		"payload must be at most %d bytes":                                            "la charge utile doit faire au plus %d octets",
+		"failed to read payload: %v":                                                  "impossible de lire la charge utile : %v",
+		"invalid ingest signature":                                                    "signature d'ingestion invalide",
+		"unknown event type %s":                                                       "type d'événement inconnu %s",
+		"at most %d events can be declared":                                           "au plus %d événements peuvent être déclarés",
+		"client_key is required and must be <= %d characters":                         "client_key est obligatoire et doit faire <= %d caractères",
+		"declared events belong to the calendar of the URL":                           "les événements déclarés appartiennent au calendrier de l'URL",
+		"only the calendar's owner can sync its events":                               "seul le propriétaire du calendrier peut synchroniser ses événements",
+		"Failed to sync events":                                                       "Impossible de synchroniser les événements",
+		"another event of the calendar has this client_key":                           "un autre événement du calendrier a ce client_key",
+		"every event needs a client_key":                                              "chaque événement doit avoir un client_key",
+		"external_id and source must be provided together":                            "external_id et source doivent être fournis ensemble",
+		"external_id must be 1-255 characters and source 1-100":                       "external_id doit contenir de 1 à 255 caractères et source de 1 à 100",
+		"an event with this source and external_id already exists":                    "un événement avec ces source et external_id existe déjà",
+		"event ended in the past":                                                     "l'événement s'est terminé dans le passé",
+		"event lasts longer than %s":                                                  "l'événement dure plus de %s",
+		"title is all capitals":                                                       "le titre est entièrement en majuscules",
+		"events that already ended cannot be created":                                 "impossible de créer des événements déjà terminés",
+		"the admin key has no personal data":                                          "la clé d'administration n'a pas de données personnelles",
+		"Failed to queue export":                                                      "Impossible de mettre l'export en file d'attente",
+		"Failed to get export":                                                        "Impossible d'obtenir l'export",
+		"Export not found":                                                            "Export introuvable",
+		"confirm must be your user ID":                                                "confirm doit être votre identifiant d'utilisateur",
+		"Failed to delete account":                                                    "Impossible de supprimer le compte",
+		"the admin key has no preferences":                                            "la clé d'administration n'a pas de préférences",
+		"Failed to get profile":                                                       "Impossible d'obtenir le profil",
+		"Failed to get preferences":                                                   "Impossible d'obtenir les préférences",
+		"Failed to update preferences":                                                "Impossible de mettre à jour les préférences",
+		"at most %d reminder_offsets":                                                 "au plus %d reminder_offsets",
+		"reminder_offsets must be between 0 and %d":                                   "reminder_offsets doit être compris entre 0 et %d",
+		"reminder_offsets must not repeat":                                            "reminder_offsets ne doit pas se répéter",
+		"week_start must be monday, sunday or saturday":                               "week_start doit être monday, sunday ou saturday",
+		"Failed to get sessions":                                                      "Impossible de récupérer les sessions",
+		"Failed to revoke session":                                                    "Impossible de révoquer la session",
+		"Failed to revoke sessions":                                                   "Impossible de révoquer les sessions",
+		"the admin key cannot use two-factor authentication":                          "la clé d'administration ne peut pas utiliser l'authentification à deux facteurs",
+		"organization %s requires two-factor authentication":                          "l'organisation %s exige l'authentification à deux facteurs",
+		"organization %s requires two-factor authentication, enable it at /me/2fa first": "l'organisation %s exige l'authentification à deux facteurs, activez-la d'abord sur /me/2fa",
+		"two-factor authentication is not enabled on this server":                        "l'authentification à deux facteurs n'est pas activée sur ce serveur",
+		"enable two-factor authentication before requiring it":                           "activez l'authentification à deux facteurs avant de l'exiger",
+		"Two-factor authentication is not enabled":                                       "L'authentification à deux facteurs n'est pas activée",
+		"two-factor authentication is already enabled":                                   "l'authentification à deux facteurs est déjà activée",
+		"a two-factor code is required in the X-Two-Factor-Code header":                  "un code à deux facteurs est requis dans l'en-tête X-Two-Factor-Code",
+		"invalid two-factor code":                                                        "code à deux facteurs invalide",
+		"too many failed authentication attempts, retry in %d seconds":                   "trop de tentatives d'authentification échouées, réessayez dans %d secondes",
+		"Down for maintenance, retry later":                                              "En maintenance, réessayez plus tard",
+		"Read-only during maintenance, changes are not accepted for now":                 "Lecture seule pendant la maintenance, les modifications ne sont pas acceptées pour le moment",
+		"Failed to set maintenance mode":                                                 "Impossible de changer le mode maintenance",
+		"Invalid value":                                                                  "Valeur non valide",
+		"Conflicts with an existing record":                                              "Entre en conflit avec un enregistrement existant",
+		"Refers to a missing record or breaks a data rule":                               "Fait référence à un enregistrement inexistant ou enfreint une règle de données",
+		"Service temporarily unavailable, retry shortly":                                 "Service temporairement indisponible, réessayez sous peu",
+		"invalid %s %q: expected %s":                                                     "%s invalide %q : attendu %s",
+		"a UUID":                                                                         "un UUID",
+		"a UUID or short ID":                                                             "un UUID ou un ID court",
+		"an RFC 3339 time":                                                               "une heure RFC 3339",
+	},
+	"de": {
+		"invalid JSON: %v":                                                    "ungültiges JSON: %v",
+		"title is required":                                                   "Titel ist erforderlich",
+		"title must be <= 100 characters":                                     "Titel darf höchstens 100 Zeichen lang sein",
+		"description must be <= 10000 characters":                             "Beschreibung darf höchstens 10000 Zeichen lang sein",
+		"start_time and end_time are required (RFC3339)":                      "start_time und end_time sind erforderlich (RFC3339)",
+		"start_time must be before end_time":                                  "start_time muss vor end_time liegen",
+		"Cannot delete the %s while it has %s":                                "%s kann nicht gelöscht werden, solange es %s hat",
+		"Fault injected for resilience testing":                               "Fehler für Resilienztests eingeschleust",
+		"fuzzy must be true or false":                                         "fuzzy muss true oder false sein",
+		"similarity must be a number above 0 and at most 1":                   "similarity muss eine Zahl größer als 0 und höchstens 1 sein",
+		"q is required":                                                       "q ist erforderlich",
+		"q must be at most %d characters":                                     "q darf höchstens %d Zeichen lang sein",
+		"view must be full or summary":                                        "view muss full oder summary sein",
+		"invalid filter: %s":                                                  "ungültiger Filter: %s",
+		"and more overlapping events":                                         "und weitere überschneidende Termine",
+		"end_time and duration_minutes are mutually exclusive":                "end_time und duration_minutes schließen sich gegenseitig aus",
+		"duration_minutes must be positive and at most a year":                "duration_minutes muss positiv sein und darf höchstens ein Jahr betragen",
+		"latitude and longitude must be provided together":                    "latitude und longitude müssen zusammen angegeben werden",
+		"latitude must be within [-90, 90] and longitude within [-180, 180]":  "latitude muss in [-90, 90] und longitude in [-180, 180] liegen",
+		"Request timeout":                                                     "Zeitüberschreitung der Anfrage",
+		"Failed to create event":                                              "Termin konnte nicht erstellt werden",
+		"Failed to get events":                                                "Termine konnten nicht geladen werden",
+		"Failed to get event":                                                 "Termin konnte nicht geladen werden",
+		"Failed to update event":                                              "Termin konnte nicht aktualisiert werden",
+		"event updated too often, retry in %d seconds":                        "Termin zu oft geändert, erneut versuchen in %d Sekunden",
+		"Failed to delete event":                                              "Termin konnte nicht gelöscht werden",
+		"invalid cursor":                                                      "ungültiger Cursor",
+		"limit must be between 1 and %d":                                      "limit muss zwischen 1 und %d liegen",
+		"Failed to get changes":                                               "Änderungen konnten nicht geladen werden",
+		"changes must contain between 1 and %d entries":                       "changes muss zwischen 1 und %d Einträge enthalten",
+		"Failed to apply changes":                                             "Änderungen konnten nicht übernommen werden",
+		"id is required":                                                      "id ist erforderlich",
+		"base_version must not be negative":                                   "base_version darf nicht negativ sein",
+		"event is required unless deleted is true":                            "event ist erforderlich, sofern deleted nicht true ist",
+		"Invalid UUID format":                                                 "Ungültiges UUID-Format",
+		"comment is required when rejecting an event":                         "beim Ablehnen eines Termins ist ein Kommentar erforderlich",
+		"comment must be <= 2000 characters":                                  "der Kommentar darf höchstens 2000 Zeichen lang sein",
+		"event is not pending review":                                         "der Termin wartet nicht auf Prüfung",
+		"Failed to review event":                                              "Termin konnte nicht geprüft werden",
+		"Failed to get event reviews":                                         "Prüfungen des Termins konnten nicht abgerufen werden",
+		"Event not found":                                                     "Termin nicht gefunden",
+		"calendar not found":                                                  "der Kalender existiert nicht",
+		"Calendar not found":                                                  "Kalender nicht gefunden",
+		"event falls on a public holiday: %s (%s)":                            "Termin fällt auf einen Feiertag: %s (%s)",
+		"country is required":                                                 "Land ist erforderlich",
+		"year must be between 1900 and 2200":                                  "Jahr muss zwischen 1900 und 2200 liegen",
+		"No holiday data for country":                                         "Keine Feiertage für dieses Land",
+		"Failed to get holidays":                                              "Feiertage konnten nicht geladen werden",
+		"unknown timezone %q":                                                 "unbekannte Zeitzone %q",
+		"text is required":                                                    "Text ist erforderlich",
+		"could not find a title in text":                                      "kein Titel im Text gefunden",
+		"could not find a start time in text":                                 "keine Startzeit im Text gefunden",
+		"text has both an end time and a duration":                            "Text enthält sowohl Endzeit als auch Dauer",
+		"description_format must be plain or markdown":                        "description_format muss plain oder markdown sein",
+		"input rejected by security policy":                                   "Eingabe durch Sicherheitsrichtlinie abgelehnt",
+		"authentication required":                                             "Authentifizierung erforderlich",
+		"invalid or expired token":                                            "ungültiges oder abgelaufenes Token",
+		"token lacks required scope %s":                                       "dem Token fehlt die Berechtigung %s",
+		"Your events for the week of %s":                                      "Ihre Termine in der Woche vom %s",
+		"Here is what is coming up in the next 7 days:":                       "Das steht in den nächsten 7 Tagen an:",
+		"You receive this email because you subscribed to the weekly digest.": "Sie erhalten diese E-Mail, weil Sie den Wochenüberblick abonniert haben.",
+		"%s mentioned you on %s":                                              "%s hat Sie in %s erwähnt",
+		"Someone":                                                             "Jemand",
+		"body is required and must be <= 5000 characters":                     "der Text ist erforderlich und darf höchstens 5000 Zeichen lang sein",
+		"Comment not found":                                                   "Kommentar nicht gefunden",
+		"only the author can delete a comment":                                "nur der Autor kann einen Kommentar löschen",
+		"Failed to create comment":                                            "Kommentar konnte nicht erstellt werden",
+		"Failed to get comments":                                              "Kommentare konnten nicht abgerufen werden",
+		"Failed to delete comment":                                            "Kommentar konnte nicht gelöscht werden",
+		"Failed to get activity":                                              "Aktivität konnte nicht abgerufen werden",
+		"Reminder not found":                                                  "Erinnerung nicht gefunden",
+		"Failed to create reminder":                                           "Erinnerung konnte nicht erstellt werden",
+		"Failed to get reminders":                                             "Erinnerungen konnten nicht abgerufen werden",
+		"Failed to get reminder":                                              "Erinnerung konnte nicht abgerufen werden",
+		"Failed to update reminder":                                           "Erinnerung konnte nicht aktualisiert werden",
+		"Failed to delete reminder":                                           "Erinnerung konnte nicht gelöscht werden",
+		"at most %d reminders per event":                                      "höchstens %d Erinnerungen pro Termin",
+		"target must be an email address":                                     "target muss eine E-Mail-Adresse sein",
+		"target must be an http or https URL":                                 "target muss eine http- oder https-URL sein",
+		"reminder time is in the past":                                        "der Erinnerungszeitpunkt liegt in der Vergangenheit",
+		"Reminder: %s":                                                        "Erinnerung: %s",
+		"%s starts on %s.":                                                    "%s beginnt am %s.",
+		"Location: %s":                                                        "Ort: %s",
+		"Push subscription not found":                                         "Push-Abonnement nicht gefunden",
+		"Failed to save push subscription":                                    "Push-Abonnement konnte nicht gespeichert werden",
+		"Failed to get push subscriptions":                                    "Push-Abonnements konnten nicht abgerufen werden",
+		"Failed to delete push subscription":                                  "Push-Abonnement konnte nicht gelöscht werden",
+		"endpoint must be an https URL":                                       "endpoint muss eine https-URL sein",
+		"keys.p256dh must be a base64url P-256 public key":                    "keys.p256dh muss ein P-256-Public-Key in base64url sein",
+		"keys.auth must be a 16-byte base64url secret":                        "keys.auth muss ein 16-Byte-Geheimnis in base64url sein",
+		"%s was changed":                                                      "%s wurde geändert",
+		"Device not found":                                                    "Gerät nicht gefunden",
+		"Failed to register device":                                           "Gerät konnte nicht registriert werden",
+		"Failed to get devices":                                               "Geräte konnten nicht abgerufen werden",
+		"Failed to unregister device":                                         "Gerät konnte nicht entfernt werden",
+		"platform %q is not configured":                                       "Plattform %q ist nicht konfiguriert",
+		"token is required and must be <= %d characters":                      "token ist erforderlich und darf höchstens %d Zeichen lang sein",
+		"Cover not found":                                                     "Titelbild nicht gefunden",
+		"Failed to upload cover":                                              "Titelbild konnte nicht hochgeladen werden",
+		"Failed to get cover":                                                 "Titelbild konnte nicht abgerufen werden",
+		"Failed to delete cover":                                              "Titelbild konnte nicht gelöscht werden",
+		"cover must be at most %d bytes":                                      "das Titelbild darf höchstens %d Bytes groß sein",
+		"invalid upload: %v":                                                  "ungültiger Upload: %v",
+		"size must be a positive integer":                                     "size muss eine positive ganze Zahl sein",
+		"cover must be a JPEG, PNG or GIF image":                              "das Titelbild muss ein JPEG-, PNG- oder GIF-Bild sein",
+		"cover image is too large":                                            "das Titelbild ist zu groß",
+		"size is not an available thumbnail width":                            "size ist keine verfügbare Vorschaubildbreite",
+		"Failed to rotate feed token":                                         "Feed-Token konnte nicht erneuert werden",
+		"only the calendar's owner can manage its feed":                       "nur der Eigentümer des Kalenders kann seinen Feed verwalten",
+		"No upcoming events.":                                                 "Keine anstehenden Termine.",
+		"Previous month":                                                      "Vorheriger Monat",
+		"Next month":                                                          "Nächster Monat",
+		"view must be month or agenda":                                        "view muss month oder agenda sein",
+		"month must be formatted as YYYY-MM":                                  "month muss im Format JJJJ-MM angegeben werden",
+		"Agenda":                                                              "Programm",
+		"Page %d of %d":                                                       "Seite %d von %d",
+		"until %s":                                                            "bis %s",
+		"No events scheduled.":                                                "Keine Termine geplant.",
+		"Failed to export events":                                             "Termine konnten nicht exportiert werden",
+		"from must be an RFC 3339 time or a YYYY-MM-DD date":                  "from muss eine RFC-3339-Zeit oder ein Datum JJJJ-MM-TT sein",
+		"to must be an RFC 3339 time or a YYYY-MM-DD date":                    "to muss eine RFC-3339-Zeit oder ein Datum JJJJ-MM-TT sein",
+		"to must be after from and at most %d days later":                     "to muss nach from und höchstens %d Tage später liegen",
+		"bucket must be hour, day or week":                                    "bucket muss hour, day oder week sein",
+		"from and to are required":                                            "from und to sind erforderlich",
+		"to must be after from":                                               "to muss nach from liegen",
+		"a heatmap can have at most %d buckets":                               "eine Heatmap kann höchstens %d Intervalle haben",
+		"Resource not found":                                                  "Ressource nicht gefunden",
+		"resource not found":                                                  "die Ressource existiert nicht",
+		"resource is already booked at that time":                             "die Ressource ist zu dieser Zeit bereits gebucht",
+		"resource has bookings":                                               "die Ressource hat Buchungen",
+		"resource %s is already booked by event %s from %s to %s":             "die Ressource %s ist bereits vom Termin %s von %s bis %s gebucht",
+		"kind must be room or equipment":                                      "kind muss room oder equipment sein",
+		"capacity must be positive":                                           "capacity muss positiv sein",
+		"min_capacity must be a non-negative integer":                         "min_capacity muss eine nicht negative ganze Zahl sein",
+		"Failed to create resource":                                           "Ressource konnte nicht erstellt werden",
+		"Failed to get resources":                                             "Ressourcen konnten nicht abgerufen werden",
+		"Failed to get resource":                                              "Ressource konnte nicht abgerufen werden",
+		"Failed to update resource":                                           "Ressource konnte nicht aktualisiert werden",
+		"Failed to delete resource":                                           "Ressource konnte nicht gelöscht werden",
+		"Failed to get bookings":                                              "Buchungen konnten nicht abgerufen werden",
+		"Failed to book resources":                                            "Ressourcen konnten nicht gebucht werden",
+		"event overlaps another event in an exclusive calendar":               "der Termin überschneidet sich mit einem anderen Termin in einem exklusiven Kalender",
+		"calendar has overlapping events":                                     "der Kalender enthält sich überschneidende Termine",
+		"only the calendar's owner can change it":                             "nur der Eigentümer des Kalenders kann ihn ändern",
+		"price_cents and currency must be provided together":                  "price_cents und currency müssen zusammen angegeben werden",
+		"price_cents must not be negative":                                    "price_cents darf nicht negativ sein",
+		"currency must be an ISO 4217 code such as EUR":                       "currency muss ein ISO-4217-Code wie EUR sein",
+		"ticket_quota must be positive":                                       "ticket_quota muss positiv sein",
+		"quantity must be between 1 and %d":                                   "quantity muss zwischen 1 und %d liegen",
+		"tickets are only sold for published events":                          "Tickets gibt es nur für veröffentlichte Termine",
+		"Reservation not found":                                               "Reservierung nicht gefunden",
+		"reservation not found":                                               "die Reservierung existiert nicht",
+		"event does not have tickets":                                         "der Termin hat keine Tickets",
+		"not enough tickets left":                                             "nicht genug Tickets übrig",
+		"reservation has expired or was cancelled":                            "die Reservierung ist abgelaufen oder wurde storniert",
+		"Failed to get tickets":                                               "Tickets konnten nicht abgerufen werden",
+		"Failed to reserve tickets":                                           "Tickets konnten nicht reserviert werden",
+		"Failed to get reservation":                                           "Reservierung konnte nicht abgerufen werden",
+		"Failed to cancel reservation":                                        "Reservierung konnte nicht storniert werden",
+		"Failed to confirm reservation":                                       "Reservierung konnte nicht bestätigt werden",
+		"only confirmed reservations paid through the payment provider can be refunded": "nur bestätigte, über den Zahlungsanbieter bezahlte Reservierungen können erstattet werden",
+		"paid reservations are refunded, not cancelled":                                 "bezahlte Reservierungen werden erstattet, nicht storniert",
+		"Failed to refund reservation":                                                  "Reservierung konnte nicht erstattet werden",
+		"Failed to start checkout":                                                      "Bezahlvorgang konnte nicht gestartet werden",
+		"invalid webhook signature":                                                     "ungültige Webhook-Signatur",
+		"Failed to handle payment":                                                      "Zahlung konnte nicht verarbeitet werden",
+		"organization not found":                                                        "Organisation nicht gefunden",
+		"member not found":                                                              "Mitglied nicht gefunden",
+		"invitation not found":                                                          "Einladung nicht gefunden",
+		"invitation has expired":                                                        "die Einladung ist abgelaufen",
+		"an organization must keep at least one owner":                                  "eine Organisation muss mindestens einen Eigentümer behalten",
+		"organization still owns calendars":                                             "die Organisation besitzt noch Kalender",
+		"Organization not found":                                                        "Organisation nicht gefunden",
+		"Member not found":                                                              "Mitglied nicht gefunden",
+		"Invitation not found":                                                          "Einladung nicht gefunden",
+		"email must be a valid email address":                                           "email muss eine gültige E-Mail-Adresse sein",
+		"role must be owner, admin or member":                                           "role muss owner, admin oder member sein",
+		"only owners can change who owns the organization":                              "nur Eigentümer können ändern, wem die Organisation gehört",
+		"this requires the %s role in the organization":                                 "dafür ist die Rolle %s in der Organisation nötig",
+		"token is required":                                                             "token ist erforderlich",
+		"accepting an invitation requires a user":                                       "zum Annehmen einer Einladung ist ein Benutzer nötig",
+		"Invitation to join %s":                                                         "Einladung zu %s",
+		"%s invited you to join %s as %s.":                                              "%s hat dich eingeladen, %s als %s beizutreten.",
+		"Accept the invitation at %s":                                                   "Nimm die Einladung unter %s an",
+		"Accept the invitation by sending this token to POST /invitations/accept: %s": "Nimm die Einladung an, indem du dieses Token an POST /invitations/accept sendest: %s",
+		"The invitation expires on %s.":                                               "Die Einladung läuft am %s ab.",
+		"Failed to create organization":                                               "Organisation konnte nicht erstellt werden",
+		"Failed to get organizations":                                                 "Organisationen konnten nicht abgerufen werden",
+		"Failed to get organization":                                                  "Organisation konnte nicht abgerufen werden",
+		"Failed to update organization":                                               "Organisation konnte nicht aktualisiert werden",
+		"Failed to delete organization":                                               "Organisation konnte nicht gelöscht werden",
+		"Failed to get members":                                                       "Mitglieder konnten nicht abgerufen werden",
+		"Failed to set member":                                                        "Mitglied konnte nicht gespeichert werden",
+		"Failed to remove member":                                                     "Mitglied konnte nicht entfernt werden",
+		"Failed to create invitation":                                                 "Einladung konnte nicht erstellt werden",
+		"Failed to get invitations":                                                   "Einladungen konnten nicht abgerufen werden",
+		"Failed to delete invitation":                                                 "Einladung konnte nicht gelöscht werden",
+		"Failed to accept invitation":                                                 "Einladung konnte nicht angenommen werden",
+		"not allowed to change events in this calendar":                               "keine Berechtigung, Termine in diesem Kalender zu ändern",
+		"only the calendar's owner can manage its delegates":                          "nur der Eigentümer des Kalenders kann seine Vertreter verwalten",
+		"the calendar's owner cannot be a delegate":                                   "der Eigentümer des Kalenders kann kein Vertreter sein",
+		"permissions must list create, edit or delete":                                "permissions muss create, edit oder delete enthalten",
+		"user_id is required and must be <= 255 characters":                           "user_id ist erforderlich und darf höchstens 255 Zeichen lang sein",
+		"expires_at must be in the future":                                            "expires_at muss in der Zukunft liegen",
+		"Delegate not found":                                                          "Vertreter nicht gefunden",
+		"Failed to set delegate":                                                      "Vertreter konnte nicht festgelegt werden",
+		"Failed to delete delegate":                                                   "Vertreter konnte nicht gelöscht werden",
+		"Busy":                                                                        "Beschäftigt",
+		"visibility must be full or busy":                                             "visibility muss full oder busy sein",
+		"Webhook not found":                                                           "Webhook nicht gefunden",
+		"Delivery not found":                                                          "Zustellung nicht gefunden",
+		"url must be an http or https URL":                                            "url muss eine http- oder https-URL sein",
+		"only admins can add webhooks for every calendar":                             "nur Administratoren können Webhooks für alle Kalender hinzufügen",
+		"only the calendar's owner can add webhooks to it":                            "nur der Eigentümer des Kalenders kann ihm Webhooks hinzufügen",
+		"status must be pending, succeeded or failed":                                 "status muss pending, succeeded oder failed sein",
+		"Failed to create webhook":                                                    "Webhook konnte nicht erstellt werden",
+		"Failed to get webhooks":                                                      "Webhooks konnten nicht abgerufen werden",
+		"Failed to get webhook":                                                       "Webhook konnte nicht abgerufen werden",
+		"Failed to delete webhook":                                                    "Webhook konnte nicht gelöscht werden",
+		"Failed to get deliveries":                                                    "Zustellungen konnten nicht abgerufen werden",
+		"Failed to get delivery":                                                      "Zustellung konnte nicht abgerufen werden",
+		"Failed to replay delivery":                                                   "Zustellung konnte nicht erneut gesendet werden",
+		"grace_minutes must be between 0 and %d":                                      "grace_minutes muss zwischen 0 und %d liegen",
+		"Failed to rotate webhook secret":                                             "Webhook-Geheimnis konnte nicht erneuert werden",
+		"name must be 1-63 lowercase letters, digits, - or _":                         "der Name muss aus 1-63 Kleinbuchstaben, Ziffern, - oder _ bestehen",
+		"mapping must have title and start_time":                                      "mapping muss title und start_time enthalten",
+		"Ingest source not found":                                                     "Ingest-Quelle nicht gefunden",
+		"an ingest source with this name already exists":                              "eine Ingest-Quelle mit diesem Namen existiert bereits",
+		"Failed to create ingest source":                                              "Ingest-Quelle konnte nicht erstellt werden",
+		"Failed to get ingest sources":                                                "Ingest-Quellen konnten nicht abgerufen werden",
+		"Failed to get ingest source":                                                 "Ingest-Quelle konnte nicht abgerufen werden",
+		"Failed to update ingest source":                                              "Ingest-Quelle konnte nicht aktualisiert werden",
+		"Failed to delete ingest source":                                              "Ingest-Quelle konnte nicht gelöscht werden",
+		"Failed to ingest payload":                                                    "Nutzlast konnte nicht übernommen werden",
+		"payload must be at most %d bytes":                                            "die Nutzlast darf höchstens %d Bytes groß sein",
+		"failed to read payload: %v":                                                  "Nutzlast konnte nicht gelesen werden: %v",
+		"invalid ingest signature":                                                    "ungültige Ingest-Signatur",
+		"unknown event type %s":                                                       "unbekannter Ereignistyp %s",
+		"at most %d events can be declared":                                           "es können höchstens %d Ereignisse deklariert werden",
+		"client_key is required and must be <= %d characters":                         "client_key ist erforderlich und darf höchstens %d Zeichen lang sein",
+		"declared events belong to the calendar of the URL":                           "deklarierte Ereignisse gehören zum Kalender der URL",
+		"only the calendar's owner can sync its events":                               "nur der Eigentümer des Kalenders kann seine Ereignisse synchronisieren",
+		"Failed to sync events":                                                       "Ereignisse konnten nicht synchronisiert werden",
+		"another event of the calendar has this client_key":                           "ein anderes Ereignis des Kalenders hat diesen client_key",
+		"every event needs a client_key":                                              "jedes Ereignis braucht einen client_key",
+		"external_id and source must be provided together":                            "external_id und source müssen zusammen angegeben werden",
+		"external_id must be 1-255 characters and source 1-100":                       "external_id muss 1-255 Zeichen und source 1-100 Zeichen lang sein",
+		"an event with this source and external_id already exists":                    "ein Ereignis mit dieser source und external_id existiert bereits",
+		"event ended in the past":                                                     "das Ereignis endete in der Vergangenheit",
+		"event lasts longer than %s":                                                  "das Ereignis dauert länger als %s",
+		"title is all capitals":                                                       "der Titel ist komplett in Großbuchstaben",
+		"events that already ended cannot be created":                                 "bereits beendete Ereignisse können nicht erstellt werden",
+		"the admin key has no personal data":                                          "der Admin-Schlüssel hat keine personenbezogenen Daten",
+		"Failed to queue export":                                                      "Export konnte nicht eingereiht werden",
+		"Failed to get export":                                                        "Export konnte nicht abgerufen werden",
+		"Export not found":                                                            "Export nicht gefunden",
+		"confirm must be your user ID":                                                "confirm muss Ihre Benutzer-ID sein",
+		"Failed to delete account":                                                    "Konto konnte nicht gelöscht werden",
+		"the admin key has no preferences":                                            "der Admin-Schlüssel hat keine Einstellungen",
+		"Failed to get profile":                                                       "Profil konnte nicht abgerufen werden",
+		"Failed to get preferences":                                                   "Einstellungen konnten nicht abgerufen werden",
+		"Failed to update preferences":                                                "Einstellungen konnten nicht aktualisiert werden",
+		"at most %d reminder_offsets":                                                 "höchstens %d reminder_offsets",
+		"reminder_offsets must be between 0 and %d":                                   "reminder_offsets muss zwischen 0 und %d liegen",
+		"reminder_offsets must not repeat":                                            "reminder_offsets darf sich nicht wiederholen",
+		"week_start must be monday, sunday or saturday":                               "week_start muss monday, sunday oder saturday sein",
+		"Failed to get sessions":                                                      "Sitzungen konnten nicht abgerufen werden",
+		"Failed to revoke session":                                                    "Sitzung konnte nicht widerrufen werden",
+		"Failed to revoke sessions":                                                   "Sitzungen konnten nicht widerrufen werden",
+		"the admin key cannot use two-factor authentication":                          "der Admin-Schlüssel kann keine Zwei-Faktor-Authentifizierung verwenden",
+		"organization %s requires two-factor authentication":                          "die Organisation %s verlangt Zwei-Faktor-Authentifizierung",
+		"organization %s requires two-factor authentication, enable it at /me/2fa first": "die Organisation %s verlangt Zwei-Faktor-Authentifizierung, zuerst unter /me/2fa aktivieren",
+		"two-factor authentication is not enabled on this server":                        "Zwei-Faktor-Authentifizierung ist auf diesem Server nicht aktiviert",
+		"enable two-factor authentication before requiring it":                           "Zwei-Faktor-Authentifizierung vor dem Verlangen aktivieren",
+		"Two-factor authentication is not enabled":                                       "Zwei-Faktor-Authentifizierung ist nicht aktiviert",
+		"two-factor authentication is already enabled":                                   "Zwei-Faktor-Authentifizierung ist bereits aktiviert",
+		"a two-factor code is required in the X-Two-Factor-Code header":                  "ein Zwei-Faktor-Code ist im Header X-Two-Factor-Code erforderlich",
+		"invalid two-factor code":                                                        "ungültiger Zwei-Faktor-Code",
+		"too many failed authentication attempts, retry in %d seconds":                   "zu viele fehlgeschlagene Anmeldeversuche, erneut versuchen in %d Sekunden",
+		"Down for maintenance, retry later":                                              "Wegen Wartung nicht verfügbar, bitte später erneut versuchen",
+		"Read-only during maintenance, changes are not accepted for now":                 "Während der Wartung nur lesbar, Änderungen werden derzeit nicht angenommen",
+		"Failed to set maintenance mode":                                                 "Wartungsmodus konnte nicht geändert werden",
+		"Invalid value":                                                                  "Ungültiger Wert",
+		"Conflicts with an existing record":                                              "Steht im Konflikt mit einem vorhandenen Eintrag",
+		"Refers to a missing record or breaks a data rule":                               "Verweist auf einen fehlenden Eintrag oder verletzt eine Datenregel",
+		"Service temporarily unavailable, retry shortly":                                 "Dienst vorübergehend nicht verfügbar, bitte gleich erneut versuchen",
+		"invalid %s %q: expected %s":                                                     "ungültiger Wert für %s %q: erwartet %s",
+		"a UUID":                                                                         "eine UUID",
+		"a UUID or short ID":                                                             "eine UUID oder Kurz-ID",
+		"an RFC 3339 time":                                                               "eine RFC-3339-Zeit",
+	},
+}
+
+// dateNames holds the words and layout used to render dates in one language
+type dateNames struct {
+	weekdays [7]string
+	months   [12]string
+	// layout uses {weekday} {day} {month} {year} {time} placeholders
+	layout string
+}
+
+var dateFormats = map[string]dateNames{
+	"en": {
+		weekdays: [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		months:   [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		layout:   "{weekday}, {month} {day}, {year} {time}",
+	},
+	"es": {
+		weekdays: [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		months:   [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		layout:   "{weekday}, {day} de {month} de {year} {time}",
+	},
+	"fr": {
+		weekdays: [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		months:   [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		layout:   "{weekday} {day} {month} {year} {time}",
+	},
+	"de": {
+		weekdays: [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		months:   [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		layout:   "{weekday}, {day}. {month} {year} {time}",
+	},
+}
+
+// NegotiateLanguage picks the best supported language from an Accept-Language header
+func NegotiateLanguage(header string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(f), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		// Only the primary subtag matters for our catalogs: es-MX uses es
+		lang, _, _ := strings.Cut(tag, "-")
+		candidates = append(candidates, candidate{lang: lang, q: q})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if c.q <= 0 {
+			continue
+		}
+		if _, ok := dateFormats[c.lang]; ok {
+			return c.lang
+		}
+	}
+	return DefaultLanguage
+}
+
+// Translate formats a message in lang, falling back to the English format
+func Translate(lang, format string, args ...any) string {
+	if translated, ok := catalogs[lang][format]; ok {
+		format = translated
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// FormatDate renders t as a human-readable date and time in lang
+func FormatDate(lang string, t time.Time) string {
+	names, ok := dateFormats[lang]
+	if !ok {
+		lang, names = DefaultLanguage, dateFormats[DefaultLanguage]
+	}
+	clock := t.Format("15:04 MST")
+	if lang == DefaultLanguage {
+		clock = t.Format("3:04 PM MST")
+	}
+	return strings.NewReplacer(
+		"{weekday}", names.weekdays[t.Weekday()],
+		"{day}", strconv.Itoa(t.Day()),
+		"{month}", names.months[t.Month()-1],
+		"{year}", strconv.Itoa(t.Year()),
+		"{time}", clock,
+	).Replace(names.layout)
+}
+
+// FormatDay renders the date part of t in lang, without the time of day
+func FormatDay(lang string, t time.Time) string {
+	names, ok := dateFormats[lang]
+	if !ok {
+		names = dateFormats[DefaultLanguage]
+	}
+	return strings.TrimSpace(strings.NewReplacer(
+		"{weekday}", names.weekdays[t.Weekday()],
+		"{day}", strconv.Itoa(t.Day()),
+		"{month}", names.months[t.Month()-1],
+		"{year}", strconv.Itoa(t.Year()),
+		"{time}", "",
+	).Replace(names.layout))
+}
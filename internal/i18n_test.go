@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: "en"},
+		{header: "es-ES,es;q=0.9,en;q=0.8", want: "es"},
+		{header: "ja, fr;q=0.5", want: "fr"},
+		{header: "de;q=0.2, fr;q=0.7", want: "fr"},
+		{header: "es;q=0", want: "en"},
+		{header: "pt-BR", want: "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			assert.Equal(t, tt.want, NegotiateLanguage(tt.header))
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	assert.Equal(t, "el título es obligatorio", Translate("es", "title is required"))
+	assert.Equal(t, "JSON inválido: boom", Translate("es", "invalid JSON: %v", "boom"))
+	assert.Equal(t, "no translation yet", Translate("es", "no translation yet"))
+	assert.Equal(t, "title is required", Translate("en", "title is required"))
+}
+
+func TestFormatDate(t *testing.T) {
+	date := time.Date(2025, 8, 22, 10, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "Friday, August 22, 2025 10:00 AM UTC", FormatDate("en", date))
+	assert.Equal(t, "viernes, 22 de agosto de 2025 10:00 UTC", FormatDate("es", date))
+	assert.Equal(t, "Freitag, 22. August 2025 10:00 UTC", FormatDate("de", date))
+	assert.Equal(t, FormatDate("en", date), FormatDate("xx", date))
+}
@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ID strategies selectable with ID_STRATEGY
+const (
+	IDStrategyUUIDv4 = "uuidv4"
+	IDStrategyUUIDv7 = "uuidv7"
+	IDStrategyULID   = "ulid"
+)
+
+// IDGenerator creates primary keys for new events
+type IDGenerator interface {
+	NewID() (uuid.UUID, error)
+}
+
+// NewIDGenerator returns the generator for strategy, defaulting to random UUIDv4
+func NewIDGenerator(strategy string) IDGenerator {
+	switch strategy {
+	case IDStrategyUUIDv7:
+		return uuidV7Generator{}
+	case IDStrategyULID:
+		return &ulidGenerator{now: time.Now}
+	default:
+		return uuidV4Generator{}
+	}
+}
+
+type uuidV4Generator struct{}
+
+func (uuidV4Generator) NewID() (uuid.UUID, error) { return uuid.NewRandom() }
+
+// uuidV7Generator produces time-ordered UUIDs (RFC 9562), which keep B-tree inserts local
+type uuidV7Generator struct{}
+
+func (uuidV7Generator) NewID() (uuid.UUID, error) { return uuid.NewV7() }
+
+// ulidGenerator produces ULIDs stored in the UUID column: 48 bits of millisecond
+// timestamp followed by 80 random bits, incremented within the same millisecond
+// so IDs stay strictly monotonic.
+type ulidGenerator struct {
+	now func() time.Time
+
+	mu     sync.Mutex
+	lastMs uint64
+	last   [10]byte
+}
+
+func (g *ulidGenerator) NewID() (uuid.UUID, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(g.now().UnixMilli())
+	var entropy [10]byte
+	if ms == g.lastMs {
+		entropy = g.last
+		if !incrementBytes(entropy[:]) {
+			return uuid.Nil, errors.New("ulid entropy overflow within one millisecond")
+		}
+	} else if _, err := rand.Read(entropy[:]); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to read entropy: %w", err)
+	}
+	g.lastMs, g.last = ms, entropy
+
+	var id uuid.UUID
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], ms)
+	copy(id[:6], ts[2:])
+	copy(id[6:], entropy[:])
+	return id, nil
+}
+
+// incrementBytes adds one to a big-endian number, reporting false on overflow
+func incrementBytes(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// base58Alphabet omits 0, O, I and l, which are easy to confuse in URLs read aloud
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// shortIDLength is the base58 length of a 128-bit value
+const shortIDLength = 22
+
+// ShortID encodes id as a fixed-length base58 string suitable for URLs
+func ShortID(id uuid.UUID) string {
+	hi, lo := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+	var out [shortIDLength]byte
+	for i := shortIDLength - 1; i >= 0; i-- {
+		// Divide the 128-bit value by 58, high half first
+		var rem uint64
+		hi, rem = hi/58, hi%58
+		lo, rem = bits.Div64(rem, lo, 58)
+		out[i] = base58Alphabet[rem]
+	}
+	return string(out[:])
+}
+
+var (
+	errShortIDLength  = errors.New("invalid short ID length")
+	errShortIDChar    = errors.New("invalid short ID character")
+	errShortIDRange   = errors.New("short ID out of range")
+	errInvalidEventID = errors.New("invalid event ID")
+)
+
+// base58Values maps bytes to their base58 digit, and the others to 255
+var base58Values = func() (v [256]byte) {
+	for i := range v {
+		v[i] = 255
+	}
+	for i := 0; i < len(base58Alphabet); i++ {
+		v[base58Alphabet[i]] = byte(i)
+	}
+	return v
+}()
+
+// ParseShortID decodes a string produced by ShortID without allocating
+func ParseShortID(s string) (uuid.UUID, error) {
+	if len(s) != shortIDLength {
+		return uuid.Nil, errShortIDLength
+	}
+	var hi, lo uint64
+	for i := 0; i < len(s); i++ {
+		d := base58Values[s[i]]
+		if d == 255 {
+			return uuid.Nil, errShortIDChar
+		}
+		// (hi, lo) = (hi, lo)*58 + d, rejecting values past 128 bits
+		carry, l := bits.Mul64(lo, 58)
+		l, c := bits.Add64(l, uint64(d), 0)
+		over, h := bits.Mul64(hi, 58)
+		h, c2 := bits.Add64(h, carry+c, 0)
+		if over != 0 || c2 != 0 {
+			return uuid.Nil, errShortIDRange
+		}
+		hi, lo = h, l
+	}
+
+	var id uuid.UUID
+	binary.BigEndian.PutUint64(id[:8], hi)
+	binary.BigEndian.PutUint64(id[8:], lo)
+	return id, nil
+}
+
+// ParseEventID accepts either a UUID or a short ID. Canonical UUIDs and short IDs,
+// which GET /events/{id} sees on every request, are parsed without allocating.
+func ParseEventID(s string) (uuid.UUID, error) {
+	if id, ok := parseCanonicalUUID(s); ok {
+		return id, nil
+	}
+	if len(s) == shortIDLength {
+		return ParseShortID(s)
+	}
+	if id, err := uuid.Parse(s); err == nil {
+		return id, nil
+	}
+	return uuid.Nil, errInvalidEventID
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShortIDRoundTrip(t *testing.T) {
+	ids := []uuid.UUID{uuid.Nil, uuid.Max, uuid.New(), uuid.New()}
+
+	for _, id := range ids {
+		short := ShortID(id)
+		assert.Len(t, short, 22)
+
+		parsed, err := ParseEventID(short)
+		assert.NoError(t, err)
+		assert.Equal(t, id, parsed)
+	}
+
+	_, err := ParseShortID("0OIl0OIl0OIl0OIl0OIl0O")
+	assert.Error(t, err)
+	_, err = ParseEventID("not-an-id")
+	assert.Error(t, err)
+}
+
+func TestIDGeneratorsAreTimeOrdered(t *testing.T) {
+	for _, strategy := range []string{IDStrategyUUIDv7, IDStrategyULID} {
+		t.Run(strategy, func(t *testing.T) {
+			gen := NewIDGenerator(strategy)
+
+			prev, err := gen.NewID()
+			assert.NoError(t, err)
+			for i := 0; i < 100; i++ {
+				next, err := gen.NewID()
+				assert.NoError(t, err)
+				assert.Equal(t, 1, bytes.Compare(next[:], prev[:]), "IDs must increase")
+				prev = next
+			}
+		})
+	}
+}
+
+func TestULIDMonotonicWithinMillisecond(t *testing.T) {
+	fixed := time.Date(2025, 8, 27, 10, 0, 0, 0, time.UTC)
+	gen := &ulidGenerator{now: func() time.Time { return fixed }}
+
+	a, err := gen.NewID()
+	assert.NoError(t, err)
+	b, err := gen.NewID()
+	assert.NoError(t, err)
+
+	assert.Equal(t, a[:6], b[:6], "same timestamp prefix")
+	assert.Equal(t, 1, bytes.Compare(b[:], a[:]))
+}
@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OperationImportEvents is the operation kind of POST /events/import
+const OperationImportEvents = "import_events"
+
+// importBatchSize is how many rows are inserted per transaction; progress is saved
+// after each batch
+const importBatchSize = 500
+
+// importRow is a validated event with its position in the input
+type importRow struct {
+	row   int
+	event EventDB
+}
+
+// ImportEventsOperation returns the function processing import operations. The input
+// is a file in any backup format. Invalid rows are reported and skipped; events whose
+// ID already exists are left untouched, so a failed import can simply be retried.
+func ImportEventsOperation(repo EventRepositoryInterface, cipher *FieldCipher) OperationFunc {
+	return func(ctx context.Context, input []byte, tracker *OperationTracker) (string, error) {
+		events, err := DecodeBackup(input, cipher)
+		if err != nil {
+			return "", err
+		}
+		tracker.SetTotal(len(events))
+
+		now := time.Now().UTC()
+		inserted := 0
+		batch := make([]importRow, 0, importBatchSize)
+		flush := func() error {
+			n, err := importBatch(ctx, repo, batch, tracker)
+			inserted += n
+			batch = batch[:0]
+			if err != nil {
+				return err
+			}
+			return tracker.Save(ctx)
+		}
+
+		for i, e := range events {
+			prepareImportedEvent(&e, now)
+			if msg := ValidateEvent(e); msg != "" {
+				tracker.Failed(i+1, errors.New(msg))
+				continue
+			}
+			batch = append(batch, importRow{row: i + 1, event: e})
+			if len(batch) == importBatchSize {
+				if err := flush(); err != nil {
+					return "", err
+				}
+			}
+		}
+		if err := flush(); err != nil {
+			return "", err
+		}
+
+		p := tracker.Progress()
+		return fmt.Sprintf("imported %d events, %d already existed, %d failed", inserted, p.Succeeded-inserted, p.Failed), nil
+	}
+}
+
+// prepareImportedEvent fills what the file may omit and cleans free-text fields the
+// way the API does
+func prepareImportedEvent(e *EventDB, now time.Time) {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = now
+	}
+	if e.UpdatedAt.IsZero() {
+		e.UpdatedAt = e.CreatedAt
+	}
+	e.Title = SanitizeText(e.Title, false)
+	if e.Description != nil {
+		description := SanitizeText(*e.Description, true)
+		e.Description = &description
+	}
+	if e.Location != nil {
+		location := SanitizeText(*e.Location, false)
+		e.Location = &location
+	}
+}
+
+// importBatch inserts rows in one transaction. When that fails, rows are retried one
+// by one so only the offending rows are reported. It returns the number inserted.
+func importBatch(ctx context.Context, repo EventRepositoryInterface, batch []importRow, tracker *OperationTracker) (int, error) {
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	events := make([]EventDB, len(batch))
+	for i, r := range batch {
+		events[i] = r.event
+	}
+	n, err := repo.ImportEvents(ctx, events)
+	if err == nil {
+		tracker.Succeeded(len(batch))
+		return n, nil
+	}
+	if ctx.Err() != nil {
+		return 0, err
+	}
+
+	inserted := 0
+	for _, r := range batch {
+		n, err := repo.ImportEvents(ctx, []EventDB{r.event})
+		if err != nil {
+			if ctx.Err() != nil {
+				return inserted, err
+			}
+			tracker.Failed(r.row, importRowError(r.event, err))
+			continue
+		}
+		inserted += n
+		tracker.Succeeded(1)
+	}
+	return inserted, nil
+}
+
+// importRowError turns a database error into a message safe to show the client
+func importRowError(e EventDB, err error) error {
+	if isForeignKeyViolation(err, "events_calendar_id_fkey") {
+		return ErrUnknownCalendar
+	}
+	if isExclusionViolation(err, "events_exclusive_no_overlap") {
+		return ErrEventOverlap
+	}
+	log.Printf("Error importing event %s: %v", e.ID, err)
+	return errors.New("failed to import event")
+}
@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// importedEvents stores imported events in memory, refusing the events named in
+// reject with their error, like the database refusing a whole transaction
+type importedEvents struct {
+	EventRepositoryInterface
+	byID   map[uuid.UUID]EventDB
+	reject map[string]error
+	calls  int
+}
+
+func (f *importedEvents) ImportEvents(ctx context.Context, events []EventDB) (int, error) {
+	f.calls++
+	for _, e := range events {
+		if err, ok := f.reject[e.Title]; ok {
+			return 0, err
+		}
+	}
+	inserted := 0
+	for _, e := range events {
+		if _, ok := f.byID[e.ID]; !ok {
+			f.byID[e.ID] = e
+			inserted++
+		}
+	}
+	return inserted, nil
+}
+
+// importFile encodes events as a JSON backup
+func importFile(t *testing.T, events ...EventDB) []byte {
+	t.Helper()
+	data, err := json.Marshal(events)
+	require.NoError(t, err)
+	return data
+}
+
+func TestImportEventsOperation(t *testing.T) {
+	start := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+	existing := EventDB{ID: uuid.New(), Title: "Kickoff", StartTime: start, EndTime: start.Add(time.Hour)}
+	repo := &importedEvents{
+		byID: map[uuid.UUID]EventDB{existing.ID: existing},
+		reject: map[string]error{
+			"Clash":  &pq.Error{Code: "23P01", Constraint: "events_exclusive_no_overlap"},
+			"Broken": errors.New("connection reset by peer"),
+		},
+	}
+	input := importFile(t,
+		EventDB{Title: "Standup", StartTime: start, EndTime: start.Add(15 * time.Minute)},
+		EventDB{Title: "", StartTime: start, EndTime: start.Add(time.Hour)},
+		existing,
+		EventDB{Title: "Clash", StartTime: start, EndTime: start.Add(time.Hour)},
+		EventDB{Title: "Backwards", StartTime: start, EndTime: start.Add(-time.Hour)},
+		EventDB{Title: "Broken", StartTime: start, EndTime: start.Add(time.Hour)},
+		EventDB{Title: "Retro\u202e  notes\x00", StartTime: start, EndTime: start.Add(time.Hour)},
+	)
+	progress := &savedProgress{}
+	tracker := &OperationTracker{repo: progress, lease: time.Minute}
+
+	result, err := ImportEventsOperation(repo, nil)(context.Background(), input, tracker)
+	require.NoError(t, err)
+
+	assert.Equal(t, "imported 2 events, 1 already existed, 4 failed", result)
+	p := tracker.Progress()
+	assert.Equal(t, 7, p.Total)
+	assert.Equal(t, 7, p.Processed)
+	assert.Equal(t, 3, p.Succeeded)
+	assert.Equal(t, 4, p.Failed)
+	assert.Equal(t, []OperationError{
+		{Row: 2, Message: "title is required"},
+		{Row: 5, Message: "start_time must be before end_time"},
+		{Row: 4, Message: ErrEventOverlap.Error()},
+		{Row: 6, Message: "failed to import event"},
+	}, p.Errors)
+
+	// The batch was refused as a whole, then retried row by row
+	assert.Equal(t, 1+5, repo.calls)
+	assert.Len(t, repo.byID, 3)
+	for id, e := range repo.byID {
+		if id == existing.ID {
+			continue
+		}
+		assert.NotEqual(t, uuid.Nil, id)
+		assert.False(t, e.CreatedAt.IsZero())
+		assert.Contains(t, []string{"Standup", "Retro notes"}, e.Title)
+	}
+	require.Len(t, progress.saves, 1)
+	assert.Equal(t, p, progress.saves[0])
+}
+
+func TestImportEventsOperationRejectsUnreadableFiles(t *testing.T) {
+	repo := &importedEvents{byID: map[uuid.UUID]EventDB{}}
+	tracker := &OperationTracker{repo: &savedProgress{}}
+
+	_, err := ImportEventsOperation(repo, nil)(context.Background(), []byte(`[{"title": `), tracker)
+	assert.ErrorContains(t, err, "failed to parse JSON backup")
+	assert.Zero(t, repo.calls)
+}
+
+// queuedOperations hands out one queued operation and records what happens to it
+type queuedOperations struct {
+	OperationRepositoryInterface
+	queued   *Operation
+	input    []byte
+	statuses []string
+	saves    []Operation
+	result   string
+	err      *string
+}
+
+func (f *queuedOperations) ClaimOperation(ctx context.Context, lockedUntil time.Time) (*Operation, []byte, error) {
+	if f.queued == nil {
+		return nil, nil, nil
+	}
+	op := *f.queued
+	op.Status = OperationRunning
+	f.queued = nil
+	f.statuses = append(f.statuses, op.Status)
+	return &op, f.input, nil
+}
+
+func (f *queuedOperations) SaveOperationProgress(ctx context.Context, op Operation, lockedUntil time.Time) error {
+	f.saves = append(f.saves, op)
+	return nil
+}
+
+func (f *queuedOperations) FinishOperation(ctx context.Context, id uuid.UUID, status, result string, opErr *string) error {
+	f.statuses = append(f.statuses, status)
+	f.result, f.err = result, opErr
+	return nil
+}
+
+func TestImportEventsOperationStatus(t *testing.T) {
+	start := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		input      []byte
+		wantStatus string
+		wantResult string
+		wantErr    bool
+	}{
+		{
+			name:       "rows imported",
+			input:      importFile(t, EventDB{Title: "Standup", StartTime: start, EndTime: start.Add(time.Hour)}, EventDB{Title: ""}),
+			wantStatus: OperationSucceeded,
+			wantResult: "imported 1 events, 0 already existed, 1 failed",
+		},
+		{name: "unreadable file", input: []byte(`[{`), wantStatus: OperationFailed, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := &queuedOperations{queued: &Operation{ID: uuid.New(), Kind: OperationImportEvents, Status: OperationPending}, input: tt.input}
+			s := NewScheduler(nil, ops)
+			s.RegisterOperation(OperationImportEvents, ImportEventsOperation(&importedEvents{byID: map[uuid.UUID]EventDB{}}, nil))
+
+			s.claimOperations(context.Background())
+			s.wg.Wait()
+
+			assert.Equal(t, []string{OperationRunning, tt.wantStatus}, ops.statuses)
+			assert.Equal(t, tt.wantResult, ops.result)
+			assert.Equal(t, tt.wantErr, ops.err != nil)
+			require.NotEmpty(t, ops.saves, "progress is saved before the outcome")
+		})
+	}
+}
@@ -0,0 +1,405 @@
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/uuid"
+)
+
+// Event fields ingest sources map payloads to. Title and start_time are required;
+// end_time defaults to an hour after the start.
+const (
+	IngestTitle       = "title"
+	IngestDescription = "description"
+	IngestLocation    = "location"
+	IngestStartTime   = "start_time"
+	IngestEndTime     = "end_time"
+)
+
+// IngestFields lists every mappable field, in display order
+var IngestFields = []string{IngestTitle, IngestDescription, IngestLocation, IngestStartTime, IngestEndTime}
+
+// Headers authenticating ingested payloads: a signature of the body, as GitHub sends,
+// or the source's secret itself for tools that can only send fixed headers
+const (
+	HeaderIngestSignature = "X-Ingest-Signature"
+	HeaderHubSignature    = "X-Hub-Signature-256"
+	HeaderIngestToken     = "X-Ingest-Token"
+)
+
+// ingestDefaultDuration is the length of ingested events without an end_time mapping
+const ingestDefaultDuration = time.Hour
+
+// ingestSourceName is the pattern of source names, which appear in the ingest URL
+var ingestSourceName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// ErrIngestSourceNotFound is returned when an ingest source does not exist
+var ErrIngestSourceNotFound = newDomainError(ErrNotFound, "ingest source not found")
+
+// ErrIngestSourceExists is returned when another source has the name
+var ErrIngestSourceExists = newDomainError(ErrConflict, "an ingest source with this name already exists")
+
+// IngestSource is an external system whose payloads, POSTed to /ingest/{name}, are
+// turned into events of CalendarID. Mapping has a CEL expression per event field over
+// payload (the JSON body), headers and now, e.g.
+//
+//	{"title": "'Release ' + payload.milestone.title", "start_time": "payload.milestone.due_on"}
+//
+// Payloads for which Filter, when set, is false are skipped.
+type IngestSource struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	// Secret authenticates payloads; it is only shown when the source is created
+	Secret     string            `json:"-"`
+	CalendarID *uuid.UUID        `json:"calendar_id"`
+	Filter     string            `json:"filter"`
+	Mapping    map[string]string `json:"mapping"`
+	Enabled    bool              `json:"enabled"`
+	CreatedBy  string            `json:"created_by"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// Authenticates reports whether a payload came from the source: signature is the hex
+// HMAC-SHA256 of body with the secret, prefixed with "sha256=", or token is the secret
+func (s IngestSource) Authenticates(body []byte, signature, token string) bool {
+	if token != "" {
+		return hmac.Equal([]byte(token), []byte(s.Secret))
+	}
+	sig, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(s.Secret))
+	mac.Write(body)
+	return hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(strings.ToLower(sig)))
+}
+
+type IngestRepository struct {
+	db *sql.DB
+}
+
+// NewIngestRepository creates a new ingest source repository
+func NewIngestRepository(db *sql.DB) *IngestRepository {
+	return &IngestRepository{db: db}
+}
+
+const ingestSourceColumns = `id, name, secret, calendar_id, filter, mapping, enabled, created_by, created_at, updated_at`
+
+func scanIngestSource(row rowScanner, s *IngestSource) error {
+	var mapping []byte
+	if err := row.Scan(&s.ID, &s.Name, &s.Secret, &s.CalendarID, &s.Filter, &mapping, &s.Enabled, &s.CreatedBy, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return err
+	}
+	return json.Unmarshal(mapping, &s.Mapping)
+}
+
+// CreateIngestSource stores a new source
+func (r *IngestRepository) CreateIngestSource(ctx context.Context, s IngestSource) (*IngestSource, error) {
+	mapping, err := json.Marshal(s.Mapping)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode mapping: %w", err)
+	}
+	query := `
+		INSERT INTO ingest_sources (id, name, secret, calendar_id, filter, mapping, enabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING ` + ingestSourceColumns
+
+	var created IngestSource
+	row := conn(ctx, r.db).QueryRowContext(ctx, query, s.ID, s.Name, s.Secret, s.CalendarID, s.Filter, mapping, s.Enabled, s.CreatedBy)
+	if err := scanIngestSource(row, &created); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrIngestSourceExists
+		}
+		if isForeignKeyViolation(err, "ingest_sources_calendar_id_fkey") {
+			return nil, ErrUnknownCalendar
+		}
+		return nil, fmt.Errorf("failed to create ingest source: %w", err)
+	}
+	return &created, nil
+}
+
+// ListIngestSources returns every source ordered by name
+func (r *IngestRepository) ListIngestSources(ctx context.Context) ([]IngestSource, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `SELECT `+ingestSourceColumns+` FROM ingest_sources ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ingest sources: %w", err)
+	}
+	defer rows.Close()
+
+	sources := []IngestSource{}
+	for rows.Next() {
+		var s IngestSource
+		if err := scanIngestSource(rows, &s); err != nil {
+			return nil, fmt.Errorf("failed to scan ingest source: %w", err)
+		}
+		sources = append(sources, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating ingest sources: %w", err)
+	}
+	return sources, nil
+}
+
+// GetIngestSource retrieves a source by ID
+func (r *IngestRepository) GetIngestSource(ctx context.Context, id uuid.UUID) (*IngestSource, error) {
+	return r.getIngestSource(ctx, `id = $1`, id)
+}
+
+// GetIngestSourceByName retrieves a source by the name in its URL
+func (r *IngestRepository) GetIngestSourceByName(ctx context.Context, name string) (*IngestSource, error) {
+	return r.getIngestSource(ctx, `name = $1`, name)
+}
+
+func (r *IngestRepository) getIngestSource(ctx context.Context, where string, arg any) (*IngestSource, error) {
+	var s IngestSource
+	if err := scanIngestSource(conn(ctx, r.db).QueryRowContext(ctx, `SELECT `+ingestSourceColumns+` FROM ingest_sources WHERE `+where, arg), &s); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrIngestSourceNotFound
+		}
+		return nil, fmt.Errorf("failed to get ingest source: %w", err)
+	}
+	return &s, nil
+}
+
+// UpdateIngestSource saves the editable fields of a source; the name and secret stay
+func (r *IngestRepository) UpdateIngestSource(ctx context.Context, s IngestSource) (*IngestSource, error) {
+	mapping, err := json.Marshal(s.Mapping)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode mapping: %w", err)
+	}
+	query := `
+		UPDATE ingest_sources
+		SET calendar_id = $2, filter = $3, mapping = $4, enabled = $5
+		WHERE id = $1
+		RETURNING ` + ingestSourceColumns
+
+	var updated IngestSource
+	if err := scanIngestSource(conn(ctx, r.db).QueryRowContext(ctx, query, s.ID, s.CalendarID, s.Filter, mapping, s.Enabled), &updated); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrIngestSourceNotFound
+		}
+		if isForeignKeyViolation(err, "ingest_sources_calendar_id_fkey") {
+			return nil, ErrUnknownCalendar
+		}
+		return nil, fmt.Errorf("failed to update ingest source: %w", err)
+	}
+	return &updated, nil
+}
+
+// DeleteIngestSource removes a source; the events it created stay
+func (r *IngestRepository) DeleteIngestSource(ctx context.Context, id uuid.UUID) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM ingest_sources WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete ingest source: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrIngestSourceNotFound
+	}
+	return nil
+}
+
+// Ingester turns the payloads of ingest sources into events. Expressions see payload
+// (the decoded JSON body), headers (the first value of each request header, by
+// canonical name) and now.
+type Ingester struct {
+	events EventRepositoryInterface
+	ids    IDGenerator
+	env    *cel.Env
+	now    func() time.Time
+}
+
+// NewIngester creates the events in events, with IDs from ids
+func NewIngester(events EventRepositoryInterface, ids IDGenerator) (*Ingester, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("payload", cel.DynType),
+		cel.Variable("headers", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("now", cel.TimestampType),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ingest environment: %w", err)
+	}
+	return &Ingester{events: events, ids: ids, env: env, now: time.Now}, nil
+}
+
+// ValidateSource returns a message describing why s is invalid, or "" if it is valid
+func (in *Ingester) ValidateSource(s IngestSource) string {
+	if !ingestSourceName.MatchString(s.Name) {
+		return "name must be 1-63 lowercase letters, digits, - or _"
+	}
+	if s.Mapping[IngestTitle] == "" || s.Mapping[IngestStartTime] == "" {
+		return "mapping must have title and start_time"
+	}
+	fields := make([]string, 0, len(s.Mapping))
+	for field := range s.Mapping {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		if !isIngestField(field) {
+			return fmt.Sprintf("mapping fields must be among %v", IngestFields)
+		}
+		if _, err := in.compile(s.Mapping[field], false); err != nil {
+			return fmt.Sprintf("invalid mapping for %s: %v", field, err)
+		}
+	}
+	if s.Filter != "" {
+		if _, err := in.compile(s.Filter, true); err != nil {
+			return fmt.Sprintf("invalid filter: %v", err)
+		}
+	}
+	return ""
+}
+
+// Transform maps a payload of s to an event, without storing it. It returns nil when
+// the filter skips the payload, and a validation error when an expression fails or
+// the event is invalid.
+func (in *Ingester) Transform(s IngestSource, payload any, headers http.Header) (*EventDB, error) {
+	vars := map[string]any{"payload": payload, "headers": firstValues(headers), "now": in.now()}
+	if s.Filter != "" {
+		out, err := in.eval(s.Filter, true, vars)
+		if err != nil {
+			return nil, newDomainError(ErrValidation, fmt.Sprintf("filter failed: %v", err))
+		}
+		if keep, _ := out.(bool); !keep {
+			return nil, nil
+		}
+	}
+
+	values := map[string]any{}
+	for field, expression := range s.Mapping {
+		out, err := in.eval(expression, false, vars)
+		if err != nil {
+			return nil, newDomainError(ErrValidation, fmt.Sprintf("mapping for %s failed: %v", field, err))
+		}
+		values[field] = out
+	}
+
+	event := EventDB{CalendarID: s.CalendarID}
+	var err error
+	if event.Title, err = ingestString(values, IngestTitle); err != nil {
+		return nil, err
+	}
+	for field, dest := range map[string]**string{IngestDescription: &event.Description, IngestLocation: &event.Location} {
+		if _, ok := values[field]; !ok {
+			continue
+		}
+		s, err := ingestString(values, field)
+		if err != nil {
+			return nil, err
+		}
+		if s != "" {
+			*dest = &s
+		}
+	}
+	if event.StartTime, err = ingestTime(values, IngestStartTime); err != nil {
+		return nil, err
+	}
+	event.EndTime = event.StartTime.Add(ingestDefaultDuration)
+	if _, ok := values[IngestEndTime]; ok {
+		if event.EndTime, err = ingestTime(values, IngestEndTime); err != nil {
+			return nil, err
+		}
+	}
+	if msg := ValidateEvent(event); msg != "" {
+		return nil, newDomainError(ErrValidation, msg)
+	}
+	return &event, nil
+}
+
+// Ingest creates the event a payload of s maps to, acting as the source. It returns
+// nil when the filter skips the payload.
+func (in *Ingester) Ingest(ctx context.Context, s IngestSource, body []byte, headers http.Header) (*EventDB, error) {
+	var payload any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, newDomainError(ErrValidation, fmt.Sprintf("payload is not JSON: %v", err))
+	}
+	event, err := in.Transform(s, payload, headers)
+	if err != nil || event == nil {
+		return nil, err
+	}
+	if event.ID, err = in.ids.NewID(); err != nil {
+		return nil, fmt.Errorf("failed to generate event ID: %w", err)
+	}
+	ctx = WithPrincipal(ctx, &Principal{UserID: "ingest:" + s.Name, Admin: true})
+	return in.events.CreateEvent(ctx, *event)
+}
+
+// compile parses and type-checks an expression; filters must be boolean
+func (in *Ingester) compile(expression string, boolean bool) (cel.Program, error) {
+	ast, iss := in.env.Compile(expression)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+	if boolean && ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("expression must be boolean, not %s", ast.OutputType())
+	}
+	return in.env.Program(ast, cel.CostLimit(policyCostLimit))
+}
+
+func (in *Ingester) eval(expression string, boolean bool, vars map[string]any) (any, error) {
+	program, err := in.compile(expression, boolean)
+	if err != nil {
+		return nil, err
+	}
+	out, _, err := program.Eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	return out.Value(), nil
+}
+
+// ingestString returns a mapped value that must be a string
+func ingestString(values map[string]any, field string) (string, error) {
+	s, ok := values[field].(string)
+	if !ok {
+		return "", newDomainError(ErrValidation, fmt.Sprintf("mapping for %s must return a string", field))
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// ingestTime returns a mapped value that must be a timestamp or an RFC3339 string
+func ingestTime(values map[string]any, field string) (time.Time, error) {
+	switch v := values[field].(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, newDomainError(ErrValidation, fmt.Sprintf("mapping for %s must return a timestamp or an RFC3339 string", field))
+}
+
+func isIngestField(field string) bool {
+	for _, f := range IngestFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// firstValues returns the first value of each header
+func firstValues(headers http.Header) map[string]string {
+	out := make(map[string]string, len(headers))
+	for name, values := range headers {
+		if len(values) > 0 {
+			out[name] = values[0]
+		}
+	}
+	return out
+}
@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// milestoneSource maps GitHub milestone webhooks to release events
+func milestoneSource() IngestSource {
+	return IngestSource{
+		Name:   "github",
+		Secret: "whsec_test",
+		Filter: `headers["X-Github-Event"] == "milestone" && payload.action in ["created", "edited"]`,
+		Mapping: map[string]string{
+			IngestTitle:       `"Release " + payload.milestone.title`,
+			IngestDescription: `payload.milestone.description`,
+			IngestStartTime:   `payload.milestone.due_on`,
+		},
+	}
+}
+
+func TestIngesterTransform(t *testing.T) {
+	in, err := NewIngester(nil, nil)
+	require.NoError(t, err)
+	source := milestoneSource()
+	require.Empty(t, in.ValidateSource(source))
+
+	headers := http.Header{}
+	headers.Set("X-GitHub-Event", "milestone")
+	payload := map[string]any{
+		"action":    "created",
+		"milestone": map[string]any{"title": "v2.0", "description": "", "due_on": "2025-10-15T07:00:00Z"},
+	}
+	event, err := in.Transform(source, payload, headers)
+	require.NoError(t, err)
+	require.NotNil(t, event)
+	assert.Equal(t, "Release v2.0", event.Title)
+	assert.Nil(t, event.Description)
+	assert.Equal(t, time.Date(2025, 10, 15, 7, 0, 0, 0, time.UTC), event.StartTime)
+	assert.Equal(t, event.StartTime.Add(time.Hour), event.EndTime)
+
+	// Payloads the filter rejects are skipped
+	payload["action"] = "deleted"
+	event, err = in.Transform(source, payload, headers)
+	require.NoError(t, err)
+	assert.Nil(t, event)
+
+	// Mappings that fail or produce invalid events are validation errors
+	payload["action"] = "edited"
+	payload["milestone"] = map[string]any{"title": "v2.1", "description": "", "due_on": "next week"}
+	_, err = in.Transform(source, payload, headers)
+	assert.True(t, errors.Is(err, ErrValidation), err)
+	payload["milestone"] = map[string]any{"title": "v2.1"}
+	_, err = in.Transform(source, payload, headers)
+	assert.True(t, errors.Is(err, ErrValidation), err)
+}
+
+func TestValidateIngestSource(t *testing.T) {
+	in, err := NewIngester(nil, nil)
+	require.NoError(t, err)
+
+	for name, mutate := range map[string]func(*IngestSource){
+		"name":            func(s *IngestSource) { s.Name = "Not A Slug" },
+		"missing title":   func(s *IngestSource) { delete(s.Mapping, IngestTitle) },
+		"unknown field":   func(s *IngestSource) { s.Mapping["attendees"] = "payload.people" },
+		"invalid mapping": func(s *IngestSource) { s.Mapping[IngestTitle] = "payload.(" },
+		"non-bool filter": func(s *IngestSource) { s.Filter = `"yes"` },
+	} {
+		s := milestoneSource()
+		mutate(&s)
+		assert.NotEmpty(t, in.ValidateSource(s), name)
+	}
+}
+
+func TestIngestSourceAuthenticates(t *testing.T) {
+	source := milestoneSource()
+	body := []byte(`{"action": "created"}`)
+	mac := hmac.New(sha256.New, []byte(source.Secret))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	assert.True(t, source.Authenticates(body, signature, ""))
+	assert.False(t, source.Authenticates([]byte(`{}`), signature, ""))
+	assert.False(t, source.Authenticates(body, hex.EncodeToString(mac.Sum(nil)), ""))
+	assert.True(t, source.Authenticates(body, "", source.Secret))
+	assert.False(t, source.Authenticates(body, signature, "wrong"))
+	assert.False(t, source.Authenticates(body, "", ""))
+}
+
+func TestIngest(t *testing.T) {
+	events := &writeRecorder{}
+	var principal *Principal
+	hooks := NewEventHooks()
+	hooks.BeforeCreate(func(ctx context.Context, e *EventDB) error {
+		principal = PrincipalFromContext(ctx)
+		return nil
+	})
+	in, err := NewIngester(NewHookedEventRepository(events, hooks), NewIDGenerator(""))
+	require.NoError(t, err)
+	calendar := uuid.New()
+	source := milestoneSource()
+	source.CalendarID = &calendar
+
+	headers := http.Header{}
+	headers.Set("X-GitHub-Event", "milestone")
+	created, err := in.Ingest(context.Background(), source, []byte(`{"action": "created", "milestone": {"title": "v2.0", "description": "Ship it", "due_on": "2025-10-15T07:00:00Z"}}`), headers)
+	require.NoError(t, err)
+	require.Len(t, events.created, 1)
+	assert.NotEqual(t, uuid.Nil, created.ID)
+	assert.Equal(t, &calendar, created.CalendarID)
+	assert.Equal(t, "Ship it", *created.Description)
+	require.NotNil(t, principal)
+	assert.Equal(t, "ingest:github", principal.UserID)
+
+	_, err = in.Ingest(context.Background(), source, []byte(`not json`), headers)
+	assert.True(t, errors.Is(err, ErrValidation), err)
+}
@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"log"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Result classes of a repository call, used as a metric label
+const (
+	ResultOK          = "ok"
+	ResultNotFound    = "not_found"
+	ResultInvalid     = "invalid"
+	ResultTimeout     = "timeout"
+	ResultCanceled    = "canceled"
+	ResultConstraint  = "constraint"
+	ResultConflict    = "conflict"
+	ResultUnavailable = "unavailable"
+	ResultError       = "error"
+)
+
+// ClassifyError maps an error returned by a repository to a result class
+func ClassifyError(err error) string {
+	var pqErr *pq.Error
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ResultOK
+	case errors.Is(err, ErrNotFound):
+		return ResultNotFound
+	case errors.Is(err, ErrValidation):
+		return ResultInvalid
+	case errors.Is(err, ErrConflict):
+		return ResultConflict
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return ResultTimeout
+	case errors.Is(err, context.Canceled):
+		return ResultCanceled
+	case errors.As(err, &pqErr):
+		switch pqErr.Code.Class() {
+		case "23":
+			return ResultConstraint
+		case "40":
+			return ResultConflict
+		case "08", "53", "57":
+			return ResultUnavailable
+		}
+		return ResultError
+	case errors.Is(err, driver.ErrBadConn), errors.As(err, &netErr):
+		return ResultUnavailable
+	}
+	return ResultError
+}
+
+// InstrumentedEventRepository wraps an event repository with call metrics and, when
+// tracing is enabled, a log line per call tagged with the request ID
+type InstrumentedEventRepository struct {
+	inner   EventRepositoryInterface
+	metrics *Metrics
+	trace   bool
+}
+
+// NewInstrumentedEventRepository instruments inner, recording into metrics
+func NewInstrumentedEventRepository(inner EventRepositoryInterface, metrics *Metrics, trace bool) *InstrumentedEventRepository {
+	return &InstrumentedEventRepository{inner: inner, metrics: metrics, trace: trace}
+}
+
+// observe records one call; defer it with the call's start time and named error
+func (r *InstrumentedEventRepository) observe(ctx context.Context, method string, start time.Time, err *error) {
+	d := time.Since(start)
+	result := ClassifyError(*err)
+	r.metrics.ObserveRepositoryCall(ctx, method, result, d)
+	if r.trace {
+		log.Printf("Trace: span=EventRepository.%s request_id=%s duration=%s result=%s", method, RequestIDFromContext(ctx), d, result)
+	}
+}
+
+func (r *InstrumentedEventRepository) CreateEvent(ctx context.Context, event EventDB) (_ *EventDB, err error) {
+	defer r.observe(ctx, "CreateEvent", time.Now(), &err)
+	return r.inner.CreateEvent(ctx, event)
+}
+
+func (r *InstrumentedEventRepository) GetEvents(ctx context.Context) (_ []EventDB, err error) {
+	defer r.observe(ctx, "GetEvents", time.Now(), &err)
+	return r.inner.GetEvents(ctx)
+}
+
+func (r *InstrumentedEventRepository) GetEventByID(ctx context.Context, id uuid.UUID) (_ *EventDB, err error) {
+	defer r.observe(ctx, "GetEventByID", time.Now(), &err)
+	return r.inner.GetEventByID(ctx, id)
+}
+
+func (r *InstrumentedEventRepository) GetEventsBetween(ctx context.Context, from, to time.Time) (_ []EventDB, err error) {
+	defer r.observe(ctx, "GetEventsBetween", time.Now(), &err)
+	return r.inner.GetEventsBetween(ctx, from, to)
+}
+
+func (r *InstrumentedEventRepository) GetEventsByCalendar(ctx context.Context, calendarID uuid.UUID) (_ []EventDB, err error) {
+	defer r.observe(ctx, "GetEventsByCalendar", time.Now(), &err)
+	return r.inner.GetEventsByCalendar(ctx, calendarID)
+}
+
+func (r *InstrumentedEventRepository) GetEventsByExternalID(ctx context.Context, source, externalID string) (_ []EventDB, err error) {
+	defer r.observe(ctx, "GetEventsByExternalID", time.Now(), &err)
+	return r.inner.GetEventsByExternalID(ctx, source, externalID)
+}
+
+func (r *InstrumentedEventRepository) GetEventByClientKey(ctx context.Context, calendarID *uuid.UUID, clientKey string) (_ *EventDB, err error) {
+	defer r.observe(ctx, "GetEventByClientKey", time.Now(), &err)
+	return r.inner.GetEventByClientKey(ctx, calendarID, clientKey)
+}
+
+func (r *InstrumentedEventRepository) UpdateEvent(ctx context.Context, event EventDB) (_ *EventDB, err error) {
+	defer r.observe(ctx, "UpdateEvent", time.Now(), &err)
+	return r.inner.UpdateEvent(ctx, event)
+}
+
+func (r *InstrumentedEventRepository) DeleteEvent(ctx context.Context, id uuid.UUID) (err error) {
+	defer r.observe(ctx, "DeleteEvent", time.Now(), &err)
+	return r.inner.DeleteEvent(ctx, id)
+}
+
+func (r *InstrumentedEventRepository) ImportEvents(ctx context.Context, events []EventDB) (_ int, err error) {
+	defer r.observe(ctx, "ImportEvents", time.Now(), &err)
+	return r.inner.ImportEvents(ctx, events)
+}
+
+func (r *InstrumentedEventRepository) PullChanges(ctx context.Context, after SyncCursor, limit int) (_ *SyncPage, err error) {
+	defer r.observe(ctx, "PullChanges", time.Now(), &err)
+	return r.inner.PullChanges(ctx, after, limit)
+}
+
+func (r *InstrumentedEventRepository) ApplySyncChange(ctx context.Context, c SyncChange) (_ *SyncOutcome, err error) {
+	defer r.observe(ctx, "ApplySyncChange", time.Now(), &err)
+	return r.inner.ApplySyncChange(ctx, c)
+}
+
+func (r *InstrumentedEventRepository) ListPendingEvents(ctx context.Context, limit int) (_ []EventDB, err error) {
+	defer r.observe(ctx, "ListPendingEvents", time.Now(), &err)
+	return r.inner.ListPendingEvents(ctx, limit)
+}
+
+func (r *InstrumentedEventRepository) ReviewEvent(ctx context.Context, review EventReview) (_ *EventDB, err error) {
+	defer r.observe(ctx, "ReviewEvent", time.Now(), &err)
+	return r.inner.ReviewEvent(ctx, review)
+}
+
+func (r *InstrumentedEventRepository) ListEventReviews(ctx context.Context, eventID uuid.UUID) (_ []EventReview, err error) {
+	defer r.observe(ctx, "ListEventReviews", time.Now(), &err)
+	return r.inner.ListEventReviews(ctx, eventID)
+}
+
+func (r *InstrumentedEventRepository) EventStats(ctx context.Context, q EventStatsQuery) (_ []EventStatsRow, err error) {
+	defer r.observe(ctx, "EventStats", time.Now(), &err)
+	return r.inner.EventStats(ctx, q)
+}
+
+func (r *InstrumentedEventRepository) SuggestTitles(ctx context.Context, q SuggestQuery) (_ []TitleSuggestion, err error) {
+	defer r.observe(ctx, "SuggestTitles", time.Now(), &err)
+	return r.inner.SuggestTitles(ctx, q)
+}
+
+func (r *InstrumentedEventRepository) Occupancy(ctx context.Context, q HeatmapQuery) (_ []HeatmapBucket, err error) {
+	defer r.observe(ctx, "Occupancy", time.Now(), &err)
+	return r.inner.Occupancy(ctx, q)
+}
+
+func (r *InstrumentedEventRepository) EventsVersion(ctx context.Context, source, externalID string) (_ *EventsVersion, err error) {
+	defer r.observe(ctx, "EventsVersion", time.Now(), &err)
+	return VersionEvents(ctx, r.inner, source, externalID)
+}
+
+func (r *InstrumentedEventRepository) FilterEvents(ctx context.Context, f *EventFilter) (_ []EventDB, err error) {
+	defer r.observe(ctx, "FilterEvents", time.Now(), &err)
+	return FilterEvents(ctx, r.inner, f)
+}
+
+func (r *InstrumentedEventRepository) GetEventSummaries(ctx context.Context, f *EventFilter) (_ []EventSummary, err error) {
+	defer r.observe(ctx, "GetEventSummaries", time.Now(), &err)
+	return SummarizeEvents(ctx, r.inner, f)
+}
+
+func (r *InstrumentedEventRepository) SearchEvents(ctx context.Context, q SearchQuery) (_ []EventSummary, err error) {
+	defer r.observe(ctx, "SearchEvents", time.Now(), &err)
+	return SearchEvents(ctx, r.inner, q)
+}
+
+// Ping checks the wrapped repository's database when it supports it
+func (r *InstrumentedEventRepository) Ping(ctx context.Context) error {
+	return pingRepository(ctx, r.inner)
+}
+
+// pingRepository pings repo when it can check its database, and succeeds otherwise
+func pingRepository(ctx context.Context, repo EventRepositoryInterface) error {
+	if p, ok := repo.(interface{ Ping(context.Context) error }); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
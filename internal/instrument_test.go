@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedEvents answers lookups after a delay, failing with the errors in fail
+type scriptedEvents struct {
+	EventRepositoryInterface
+	delay time.Duration
+	fail  map[uuid.UUID]error
+}
+
+func (s *scriptedEvents) GetEventByID(ctx context.Context, id uuid.UUID) (*EventDB, error) {
+	time.Sleep(s.delay)
+	if err, ok := s.fail[id]; ok {
+		return nil, err
+	}
+	return &EventDB{ID: id, Title: "Standup"}, nil
+}
+
+func (s *scriptedEvents) DeleteEvent(ctx context.Context, id uuid.UUID) error {
+	return s.fail[id]
+}
+
+func TestInstrumentedEventRepository(t *testing.T) {
+	missing, locked := uuid.New(), uuid.New()
+	deadlock := fmt.Errorf("failed to delete event: %w", &pq.Error{Code: "40P01"})
+	inner := &scriptedEvents{delay: 10 * time.Millisecond, fail: map[uuid.UUID]error{missing: ErrEventNotFound, locked: deadlock}}
+	metrics := NewMetrics()
+	repo := NewInstrumentedEventRepository(inner, metrics, true)
+	ctx := context.Background()
+
+	e, err := repo.GetEventByID(ctx, uuid.New())
+	require.NoError(t, err)
+	assert.Equal(t, "Standup", e.Title)
+	_, err = repo.GetEventByID(ctx, uuid.New())
+	require.NoError(t, err)
+
+	// Errors come back as the inner repository returned them
+	_, err = repo.GetEventByID(ctx, missing)
+	assert.Same(t, ErrEventNotFound, err)
+	err = repo.DeleteEvent(ctx, locked)
+	assert.Same(t, deadlock, err)
+	require.NoError(t, repo.DeleteEvent(ctx, uuid.New()))
+
+	stats := func(method, result string) requestStats {
+		metrics.mu.Lock()
+		defer metrics.mu.Unlock()
+		s, ok := metrics.repositories[repositoryKey{Method: method, Result: result}]
+		require.True(t, ok, "%s %s recorded", method, result)
+		return *s
+	}
+	found := stats("GetEventByID", ResultOK)
+	assert.EqualValues(t, 2, found.Count)
+	assert.GreaterOrEqual(t, found.Duration, 20*time.Millisecond)
+	notFound := stats("GetEventByID", ResultNotFound)
+	assert.EqualValues(t, 1, notFound.Count)
+	assert.GreaterOrEqual(t, notFound.Duration, 10*time.Millisecond)
+	assert.EqualValues(t, 1, stats("DeleteEvent", ResultConflict).Count)
+	assert.EqualValues(t, 1, stats("DeleteEvent", ResultOK).Count)
+
+	var buf bytes.Buffer
+	require.NoError(t, metrics.WritePrometheus(&buf))
+	assert.Contains(t, buf.String(), `repository_calls_total{method="GetEventByID",result="not_found"} 1`)
+}
@@ -2,6 +2,8 @@ package internal
 
 import (
 	"context"
+	"encoding/json"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -12,4 +14,275 @@ type EventRepositoryInterface interface {
 	CreateEvent(ctx context.Context, event EventDB) (*EventDB, error)
 	GetEvents(ctx context.Context) ([]EventDB, error)
 	GetEventByID(ctx context.Context, id uuid.UUID) (*EventDB, error)
+	GetEventsBetween(ctx context.Context, from, to time.Time) ([]EventDB, error)
+	GetEventsByCalendar(ctx context.Context, calendarID uuid.UUID) ([]EventDB, error)
+	GetEventsByExternalID(ctx context.Context, source, externalID string) ([]EventDB, error)
+	GetEventByClientKey(ctx context.Context, calendarID *uuid.UUID, clientKey string) (*EventDB, error)
+	UpdateEvent(ctx context.Context, event EventDB) (*EventDB, error)
+	DeleteEvent(ctx context.Context, id uuid.UUID) error
+	ImportEvents(ctx context.Context, events []EventDB) (int, error)
+	PullChanges(ctx context.Context, after SyncCursor, limit int) (*SyncPage, error)
+	ApplySyncChange(ctx context.Context, c SyncChange) (*SyncOutcome, error)
+	ListPendingEvents(ctx context.Context, limit int) ([]EventDB, error)
+	ReviewEvent(ctx context.Context, review EventReview) (*EventDB, error)
+	ListEventReviews(ctx context.Context, eventID uuid.UUID) ([]EventReview, error)
+	Occupancy(ctx context.Context, q HeatmapQuery) ([]HeatmapBucket, error)
+	EventStats(ctx context.Context, q EventStatsQuery) ([]EventStatsRow, error)
+	SuggestTitles(ctx context.Context, q SuggestQuery) ([]TitleSuggestion, error)
+}
+
+// TokenRepositoryInterface defines the contract for API token storage
+type TokenRepositoryInterface interface {
+	CreateToken(ctx context.Context, token APIToken, hash []byte) (*APIToken, error)
+	GetTokenByHash(ctx context.Context, hash []byte) (*APIToken, error)
+	ListTokens(ctx context.Context, userID string) ([]APIToken, error)
+	DeleteToken(ctx context.Context, userID string, id uuid.UUID) error
+	RevokeToken(ctx context.Context, userID string, id uuid.UUID) error
+	RevokeTokens(ctx context.Context, userID string, except *uuid.UUID) (int, error)
+	TouchToken(ctx context.Context, id uuid.UUID, ip string) error
+}
+
+// TwoFactorRepositoryInterface defines the contract for TOTP enrollments and their
+// recovery codes
+type TwoFactorRepositoryInterface interface {
+	GetTwoFactor(ctx context.Context, userID string) (*TwoFactor, error)
+	StartTwoFactor(ctx context.Context, userID, secret string) error
+	ConfirmTwoFactor(ctx context.Context, userID string, step int64, recoveryHashes [][]byte) error
+	UseTOTPStep(ctx context.Context, userID string, step int64) error
+	UseRecoveryCode(ctx context.Context, userID string, hash []byte) error
+	ReplaceRecoveryCodes(ctx context.Context, userID string, hashes [][]byte) error
+	DeleteTwoFactor(ctx context.Context, userID string) error
+}
+
+// PreferencesRepositoryInterface defines the contract for user preferences
+type PreferencesRepositoryInterface interface {
+	GetPreferences(ctx context.Context, userID string) (*Preferences, error)
+	SavePreferences(ctx context.Context, p Preferences) (*Preferences, error)
+}
+
+// PrivacyRepositoryInterface defines the contract for exporting and erasing the data
+// of a user
+type PrivacyRepositoryInterface interface {
+	ExportUserData(ctx context.Context, userID string) (*UserData, error)
+	EraseUserData(ctx context.Context, userID string) (*UserErasure, error)
+}
+
+// ScheduleRepositoryInterface defines the contract for cron schedules and their runs
+type ScheduleRepositoryInterface interface {
+	CreateSchedule(ctx context.Context, s Schedule) (*Schedule, error)
+	ListSchedules(ctx context.Context) ([]Schedule, error)
+	GetSchedule(ctx context.Context, id uuid.UUID) (*Schedule, error)
+	UpdateSchedule(ctx context.Context, s Schedule) (*Schedule, error)
+	DeleteSchedule(ctx context.Context, id uuid.UUID) error
+	DueSchedules(ctx context.Context, now time.Time) ([]Schedule, error)
+	ClaimSchedule(ctx context.Context, id uuid.UUID, expected time.Time, next *time.Time) (bool, error)
+	CreateRun(ctx context.Context, run ScheduleRun) (*ScheduleRun, error)
+	FinishRun(ctx context.Context, id uuid.UUID, status, output string, runErr *string) error
+	ListRuns(ctx context.Context, scheduleID uuid.UUID, limit int) ([]ScheduleRun, error)
+}
+
+// DigestRepositoryInterface defines the contract for weekly digest subscriptions
+type DigestRepositoryInterface interface {
+	GetDigestSubscription(ctx context.Context, userID string) (*DigestSubscription, error)
+	SaveDigestSubscription(ctx context.Context, sub DigestSubscription) (*DigestSubscription, error)
+	DeleteDigestSubscription(ctx context.Context, userID string) error
+	PendingDigestSubscriptions(ctx context.Context, before time.Time) ([]DigestSubscription, error)
+	MarkDigestSent(ctx context.Context, userID string, at time.Time) error
+}
+
+// CalendarRepositoryInterface defines the contract for calendar storage
+type CalendarRepositoryInterface interface {
+	CreateCalendar(ctx context.Context, c Calendar) (*Calendar, error)
+	ListCalendars(ctx context.Context) ([]Calendar, error)
+	ListOrganizationCalendars(ctx context.Context, orgID uuid.UUID) ([]Calendar, error)
+	GetCalendar(ctx context.Context, id uuid.UUID) (*Calendar, error)
+	UpdateCalendar(ctx context.Context, c Calendar) (*Calendar, error)
+	DeleteCalendar(ctx context.Context, id uuid.UUID) error
+	RotateFeedToken(ctx context.Context, id uuid.UUID) (*Calendar, error)
+}
+
+// OrganizationRepositoryInterface defines the contract for organizations, their
+// members and invitations
+type OrganizationRepositoryInterface interface {
+	CreateOrganization(ctx context.Context, o Organization) (*Organization, error)
+	ListOrganizations(ctx context.Context, userID string) ([]Organization, error)
+	GetOrganization(ctx context.Context, id uuid.UUID) (*Organization, error)
+	UpdateOrganization(ctx context.Context, o Organization) (*Organization, error)
+	DeleteOrganization(ctx context.Context, id uuid.UUID) error
+	GetMembership(ctx context.Context, orgID uuid.UUID, userID string) (*Membership, error)
+	ListMembers(ctx context.Context, orgID uuid.UUID) ([]Membership, error)
+	SetMember(ctx context.Context, orgID uuid.UUID, userID, role string) (*Membership, error)
+	RemoveMember(ctx context.Context, orgID uuid.UUID, userID string) error
+	CreateInvitation(ctx context.Context, inv Invitation, tokenHash []byte) (*Invitation, error)
+	ListInvitations(ctx context.Context, orgID uuid.UUID) ([]Invitation, error)
+	DeleteInvitation(ctx context.Context, orgID, id uuid.UUID) error
+	AcceptInvitation(ctx context.Context, tokenHash []byte, userID string, now time.Time) (*Membership, error)
+}
+
+// DelegateRepositoryInterface defines the contract for calendar delegation grants
+type DelegateRepositoryInterface interface {
+	SetDelegate(ctx context.Context, d Delegate) (*Delegate, error)
+	GetDelegate(ctx context.Context, calendarID uuid.UUID, userID string) (*Delegate, error)
+	ListDelegates(ctx context.Context, calendarID uuid.UUID) ([]Delegate, error)
+	DeleteDelegate(ctx context.Context, calendarID uuid.UUID, userID string) error
+}
+
+// SnapshotRepositoryInterface defines the contract for point-in-time event snapshots
+type SnapshotRepositoryInterface interface {
+	CreateSnapshot(ctx context.Context, s Snapshot) (*Snapshot, error)
+	ListSnapshots(ctx context.Context) ([]Snapshot, error)
+	GetSnapshot(ctx context.Context, id uuid.UUID) (*Snapshot, error)
+	DeleteSnapshot(ctx context.Context, id uuid.UUID) error
+	RestoreSnapshot(ctx context.Context, id uuid.UUID, calendar Calendar) (*Calendar, int, error)
+}
+
+// Transactor runs a function inside a database transaction carried by its context
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// OperationRepositoryInterface defines the contract for long-running operation storage
+type OperationRepositoryInterface interface {
+	CreateOperation(ctx context.Context, op Operation, input []byte) (*Operation, error)
+	GetOperation(ctx context.Context, id uuid.UUID) (*Operation, error)
+	ClaimOperation(ctx context.Context, lockedUntil time.Time) (*Operation, []byte, error)
+	SaveOperationProgress(ctx context.Context, op Operation, lockedUntil time.Time) error
+	FinishOperation(ctx context.Context, id uuid.UUID, status, result string, opErr *string) error
+}
+
+// ArchiveRepositoryInterface defines the contract for moving events to the archive
+type ArchiveRepositoryInterface interface {
+	ArchivableMonths(ctx context.Context, cutoff time.Time) ([]time.Time, error)
+	ArchiveMonth(ctx context.Context, month, cutoff time.Time) (int, error)
+	ListArchivePartitions(ctx context.Context) ([]ArchivePartition, error)
+	DetachArchivePartition(ctx context.Context, name string) error
+}
+
+// MaintenanceRepositoryInterface defines the contract for database maintenance
+type MaintenanceRepositoryInterface interface {
+	ListIndexes(ctx context.Context, tables []string) ([]string, error)
+	Reindex(ctx context.Context, index string) error
+	ListMaterializedViews(ctx context.Context) ([]MaterializedView, error)
+	RefreshMaterializedView(ctx context.Context, v MaterializedView) error
+}
+
+// MaintenanceModeRepositoryInterface defines the contract for storing the maintenance switch
+type MaintenanceModeRepositoryInterface interface {
+	GetMaintenanceMode(ctx context.Context) (*MaintenanceMode, error)
+	SetMaintenanceMode(ctx context.Context, m MaintenanceMode) (*MaintenanceMode, error)
+}
+
+// AuthFailureRepositoryInterface defines the contract for counting failed authentications
+type AuthFailureRepositoryInterface interface {
+	AddAuthFailure(ctx context.Context, key string, window time.Duration) (int, int, error)
+	LockAuthKey(ctx context.Context, key string, until time.Time) error
+	ListAuthLocks(ctx context.Context) (map[string]time.Time, error)
+}
+
+// PolicyRepositoryInterface defines the contract for policy rule storage
+type PolicyRepositoryInterface interface {
+	CreatePolicyRule(ctx context.Context, p PolicyRule) (*PolicyRule, error)
+	ListPolicyRules(ctx context.Context) ([]PolicyRule, error)
+	GetPolicyRule(ctx context.Context, id uuid.UUID) (*PolicyRule, error)
+	UpdatePolicyRule(ctx context.Context, p PolicyRule) (*PolicyRule, error)
+	DeletePolicyRule(ctx context.Context, id uuid.UUID) error
+}
+
+// CommentRepositoryInterface defines the contract for event discussion threads
+type CommentRepositoryInterface interface {
+	CreateComment(ctx context.Context, c EventComment) (*EventComment, error)
+	ListComments(ctx context.Context, eventID uuid.UUID, after TimeCursor, limit int) (*CommentPage, error)
+	GetComment(ctx context.Context, eventID, id uuid.UUID) (*EventComment, error)
+	DeleteComment(ctx context.Context, eventID, id uuid.UUID) error
+}
+
+// ActivityRepositoryInterface defines the contract for the activity feed
+type ActivityRepositoryInterface interface {
+	RecordActivity(ctx context.Context, a Activity) error
+	RecordDeletion(ctx context.Context, id, eventID uuid.UUID, actor string) error
+	ListActivity(ctx context.Context, f ActivityFilter) (*ActivityPage, error)
+}
+
+// ReminderRepositoryInterface defines the contract for event reminders
+type ReminderRepositoryInterface interface {
+	CreateReminder(ctx context.Context, r Reminder) (*Reminder, error)
+	ListReminders(ctx context.Context, eventID uuid.UUID) ([]Reminder, error)
+	GetReminder(ctx context.Context, eventID, id uuid.UUID) (*Reminder, error)
+	UpdateReminder(ctx context.Context, r Reminder) (*Reminder, error)
+	DeleteReminder(ctx context.Context, eventID, id uuid.UUID) error
+	ClaimDueReminders(ctx context.Context, now time.Time, limit int) ([]Reminder, error)
+	ReleaseReminder(ctx context.Context, id uuid.UUID, failure string) error
+}
+
+// PushSubscriptionRepositoryInterface defines the contract for browser push subscriptions
+type PushSubscriptionRepositoryInterface interface {
+	SavePushSubscription(ctx context.Context, s PushSubscription) (*PushSubscription, error)
+	ListPushSubscriptions(ctx context.Context, userID string) ([]PushSubscription, error)
+	DeletePushSubscription(ctx context.Context, userID string, id uuid.UUID) error
+	DeletePushEndpoint(ctx context.Context, endpoint string) error
+}
+
+// DeviceTokenRepositoryInterface defines the contract for mobile push device tokens
+type DeviceTokenRepositoryInterface interface {
+	SaveDeviceToken(ctx context.Context, d DeviceToken) (*DeviceToken, error)
+	ListDeviceTokens(ctx context.Context, userID string) ([]DeviceToken, error)
+	DeleteDeviceToken(ctx context.Context, userID string, id uuid.UUID) error
+	InvalidateDeviceToken(ctx context.Context, platform, token string) error
+}
+
+// CoverRepositoryInterface defines the contract for event cover records
+type CoverRepositoryInterface interface {
+	SaveCover(ctx context.Context, c EventCover) (*EventCover, error)
+	GetCover(ctx context.Context, eventID uuid.UUID) (*EventCover, error)
+	DeleteCover(ctx context.Context, eventID uuid.UUID) error
+}
+
+// ResourceRepositoryInterface defines the contract for bookable resources
+type ResourceRepositoryInterface interface {
+	CreateResource(ctx context.Context, res Resource) (*Resource, error)
+	ListResources(ctx context.Context, filter ResourceFilter) ([]Resource, error)
+	GetResource(ctx context.Context, id uuid.UUID) (*Resource, error)
+	UpdateResource(ctx context.Context, res Resource) (*Resource, error)
+	DeleteResource(ctx context.Context, id uuid.UUID) error
+	ListEventResources(ctx context.Context, eventID uuid.UUID) ([]Resource, error)
+	SetEventResources(ctx context.Context, eventID uuid.UUID, resourceIDs []uuid.UUID) ([]Resource, error)
+	Conflicts(ctx context.Context, resourceIDs []uuid.UUID, slot TimeSlot, eventID uuid.UUID) ([]Booking, error)
+	ListBookings(ctx context.Context, resourceID uuid.UUID, slot TimeSlot) ([]Booking, error)
+}
+
+// TicketRepositoryInterface defines the contract for event ticket reservations
+type TicketRepositoryInterface interface {
+	Reserve(ctx context.Context, res TicketReservation, holdUntil time.Time) (*TicketReservation, error)
+	GetAvailability(ctx context.Context, eventID uuid.UUID) (*TicketAvailability, error)
+	ListReservations(ctx context.Context, eventID uuid.UUID) ([]TicketReservation, error)
+	GetReservation(ctx context.Context, eventID, id uuid.UUID) (*TicketReservation, error)
+	SetCheckout(ctx context.Context, eventID, id uuid.UUID, provider string, checkout Checkout) (*TicketReservation, error)
+	ConfirmReservation(ctx context.Context, eventID, id uuid.UUID, paymentID string) (*TicketReservation, error)
+	CancelReservation(ctx context.Context, eventID, id uuid.UUID) error
+	RefundReservation(ctx context.Context, eventID, id uuid.UUID) error
+	ExpireReservations(ctx context.Context, now time.Time) (int, error)
+}
+
+// WebhookRepositoryInterface defines the contract for webhooks and their delivery log
+type WebhookRepositoryInterface interface {
+	CreateWebhook(ctx context.Context, w Webhook) (*Webhook, error)
+	GetWebhook(ctx context.Context, id uuid.UUID) (*Webhook, error)
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	RotateWebhookSecret(ctx context.Context, id uuid.UUID, secret string, previousExpiresAt time.Time) (*Webhook, error)
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+	EnqueueDeliveries(ctx context.Context, eventType string, eventID uuid.UUID, calendarID *uuid.UUID, payload json.RawMessage, dueAt time.Time) ([]WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit int) ([]WebhookDelivery, error)
+	GetDelivery(ctx context.Context, webhookID, id uuid.UUID) (*WebhookDelivery, error)
+	ClaimDueDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, a DeliveryAttempt, status string, nextAttemptAt *time.Time) error
+}
+
+// IngestRepositoryInterface defines the contract for the sources of inbound payloads
+type IngestRepositoryInterface interface {
+	CreateIngestSource(ctx context.Context, s IngestSource) (*IngestSource, error)
+	ListIngestSources(ctx context.Context) ([]IngestSource, error)
+	GetIngestSource(ctx context.Context, id uuid.UUID) (*IngestSource, error)
+	GetIngestSourceByName(ctx context.Context, name string) (*IngestSource, error)
+	UpdateIngestSource(ctx context.Context, s IngestSource) (*IngestSource, error)
+	DeleteIngestSource(ctx context.Context, id uuid.UUID) error
 }
@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// authLockReloadInterval is how often the locks are re-read, picking up those set by
+// other instances
+const authLockReloadInterval = 5 * time.Second
+
+// maxAuthFailureDelay caps the delay added to failed authentication responses
+const maxAuthFailureDelay = 2 * time.Second
+
+// AuthLockoutPolicy configures brute-force lockouts
+type AuthLockoutPolicy struct {
+	// Threshold is how many failures within Window lock a key; 0 disables lockouts
+	Threshold int
+	Window    time.Duration
+	// Duration is the first lockout, doubled at each following one up to MaxDuration
+	Duration    time.Duration
+	MaxDuration time.Duration
+}
+
+// lockDuration is how long the lockout following lockouts earlier ones lasts
+func (p AuthLockoutPolicy) lockDuration(lockouts int) time.Duration {
+	d := p.Duration
+	for i := 0; i < lockouts && d < p.MaxDuration; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxDuration)
+}
+
+// AuthFailureRepository stores failed authentication attempts
+type AuthFailureRepository struct {
+	db *sql.DB
+}
+
+// NewAuthFailureRepository creates a repository of failed authentication attempts
+func NewAuthFailureRepository(db *sql.DB) *AuthFailureRepository {
+	return &AuthFailureRepository{db: db}
+}
+
+// AddAuthFailure counts a failure of key in its window, starting a new window when the
+// current one is older than window. It returns the failures in the window and the
+// lockouts key had so far.
+func (r *AuthFailureRepository) AddAuthFailure(ctx context.Context, key string, window time.Duration) (int, int, error) {
+	var failures, lockouts int
+	err := traced(ctx, r.db).QueryRowContext(ctx, `
+		INSERT INTO auth_failures (key, failures, window_start) VALUES ($1, 1, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN auth_failures.window_start < NOW() - $2 * INTERVAL '1 second' THEN 1 ELSE auth_failures.failures + 1 END,
+			window_start = CASE WHEN auth_failures.window_start < NOW() - $2 * INTERVAL '1 second' THEN NOW() ELSE auth_failures.window_start END,
+			lockouts = CASE WHEN auth_failures.locked_until < NOW() - INTERVAL '1 day' THEN 0 ELSE auth_failures.lockouts END
+		RETURNING failures, lockouts`, key, window.Seconds()).Scan(&failures, &lockouts)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to record authentication failure: %w", err)
+	}
+	return failures, lockouts, nil
+}
+
+// LockAuthKey locks key until until, counting the lockout and starting a new window
+func (r *AuthFailureRepository) LockAuthKey(ctx context.Context, key string, until time.Time) error {
+	_, err := traced(ctx, r.db).ExecContext(ctx, `
+		UPDATE auth_failures SET locked_until = $2, lockouts = lockouts + 1, failures = 0, window_start = NOW()
+		WHERE key = $1`, key, until)
+	if err != nil {
+		return fmt.Errorf("failed to lock %s: %w", key, err)
+	}
+	return nil
+}
+
+// ListAuthLocks returns the keys locked now, with the end of their lock
+func (r *AuthFailureRepository) ListAuthLocks(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := traced(ctx, r.db).QueryContext(ctx, `SELECT key, locked_until FROM auth_failures WHERE locked_until > NOW()`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list authentication locks: %w", err)
+	}
+	defer rows.Close()
+	locks := map[string]time.Time{}
+	for rows.Next() {
+		var key string
+		var until time.Time
+		if err := rows.Scan(&key, &until); err != nil {
+			return nil, fmt.Errorf("failed to scan authentication lock: %w", err)
+		}
+		locks[key] = until
+	}
+	return locks, rows.Err()
+}
+
+// AuthLockout locks out client IPs and accounts that fail to authenticate too often,
+// so credentials cannot be guessed by brute force. Failures are counted in the
+// database, shared by every instance; the locks are checked from memory and re-read
+// every authLockReloadInterval, so checking costs no query.
+type AuthLockout struct {
+	repo   AuthFailureRepositoryInterface
+	policy AuthLockoutPolicy
+	now    func() time.Time
+
+	mu       sync.Mutex
+	locks    map[string]time.Time
+	loadedAt time.Time
+}
+
+// NewAuthLockout applies policy with the failures in repo; it returns nil, which locks
+// nothing, when the policy has no threshold
+func NewAuthLockout(repo AuthFailureRepositoryInterface, policy AuthLockoutPolicy) *AuthLockout {
+	if policy.Threshold <= 0 {
+		return nil
+	}
+	return &AuthLockout{repo: repo, policy: policy, now: time.Now, locks: map[string]time.Time{}}
+}
+
+// Locked returns how long the first of keys that is locked stays locked, or false when
+// none is. When the locks cannot be re-read the ones known are kept.
+func (l *AuthLockout) Locked(ctx context.Context, keys ...string) (time.Duration, bool) {
+	if l == nil {
+		return 0, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.loadedAt) >= authLockReloadInterval {
+		l.loadedAt = now
+		if locks, err := l.repo.ListAuthLocks(ctx); err != nil {
+			log.Printf("Error reading authentication locks: %v", err)
+		} else {
+			l.locks = locks
+		}
+	}
+	for _, key := range keys {
+		if until, ok := l.locks[key]; ok && until.After(now) {
+			return until.Sub(now), true
+		}
+	}
+	return 0, false
+}
+
+// Failed records a failed authentication by keys, locking those reaching the
+// threshold. It returns how long to delay the response, growing exponentially with
+// the failures so guesses slow down well before the lockout.
+func (l *AuthLockout) Failed(ctx context.Context, keys ...string) time.Duration {
+	if l == nil {
+		return 0
+	}
+	var delay time.Duration
+	for _, key := range keys {
+		failures, lockouts, err := l.repo.AddAuthFailure(ctx, key, l.policy.Window)
+		if err != nil {
+			log.Printf("Error recording authentication failure of %s: %v", key, err)
+			continue
+		}
+		delay = max(delay, min((50*time.Millisecond)<<min(failures, 10), maxAuthFailureDelay))
+		if failures < l.policy.Threshold {
+			continue
+		}
+		d := l.policy.lockDuration(lockouts)
+		until := l.now().Add(d)
+		if err := l.repo.LockAuthKey(ctx, key, until); err != nil {
+			log.Printf("Error locking %s: %v", key, err)
+			continue
+		}
+		l.mu.Lock()
+		l.locks[key] = until
+		l.mu.Unlock()
+		log.Printf("Security: locked out %s for %s after %d failed authentications (lockout %d)", key, d, failures, lockouts+1)
+	}
+	return delay
+}
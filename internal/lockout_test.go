@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryAuthFailures keeps failed authentications in memory, with its own clock
+type memoryAuthFailures struct {
+	now      func() time.Time
+	failures map[string]int
+	lockouts map[string]int
+	locks    map[string]time.Time
+	lists    int
+}
+
+func newMemoryAuthFailures(now func() time.Time) *memoryAuthFailures {
+	return &memoryAuthFailures{now: now, failures: map[string]int{}, lockouts: map[string]int{}, locks: map[string]time.Time{}}
+}
+
+func (m *memoryAuthFailures) AddAuthFailure(ctx context.Context, key string, window time.Duration) (int, int, error) {
+	m.failures[key]++
+	return m.failures[key], m.lockouts[key], nil
+}
+
+func (m *memoryAuthFailures) LockAuthKey(ctx context.Context, key string, until time.Time) error {
+	m.locks[key] = until
+	m.lockouts[key]++
+	m.failures[key] = 0
+	return nil
+}
+
+func (m *memoryAuthFailures) ListAuthLocks(ctx context.Context) (map[string]time.Time, error) {
+	m.lists++
+	locks := map[string]time.Time{}
+	for k, until := range m.locks {
+		if until.After(m.now()) {
+			locks[k] = until
+		}
+	}
+	return locks, nil
+}
+
+func TestAuthLockout(t *testing.T) {
+	now := time.Date(2025, 10, 6, 9, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	repo := newMemoryAuthFailures(clock)
+	assert.Nil(t, NewAuthLockout(repo, AuthLockoutPolicy{}), "disabled without a threshold")
+	lockout := NewAuthLockout(repo, AuthLockoutPolicy{Threshold: 3, Window: time.Minute, Duration: time.Minute, MaxDuration: 3 * time.Minute})
+	lockout.now = clock
+	ctx := context.Background()
+
+	// Responses slow down exponentially, then the third failure locks the key
+	assert.Equal(t, 100*time.Millisecond, lockout.Failed(ctx, "ip:10.0.0.1"))
+	assert.Equal(t, 200*time.Millisecond, lockout.Failed(ctx, "ip:10.0.0.1"))
+	_, locked := lockout.Locked(ctx, "ip:10.0.0.1")
+	assert.False(t, locked)
+	lockout.Failed(ctx, "ip:10.0.0.1")
+	wait, locked := lockout.Locked(ctx, "ip:10.0.0.2", "ip:10.0.0.1")
+	assert.True(t, locked)
+	assert.Equal(t, time.Minute, wait)
+	lists := repo.lists
+	lockout.Locked(ctx, "ip:10.0.0.1")
+	assert.Equal(t, lists, repo.lists, "locks are checked from memory")
+
+	// The next lockout lasts twice as long, up to the maximum
+	now = now.Add(time.Minute)
+	_, locked = lockout.Locked(ctx, "ip:10.0.0.1")
+	assert.False(t, locked)
+	for i := 0; i < 3; i++ {
+		lockout.Failed(ctx, "ip:10.0.0.1")
+	}
+	wait, _ = lockout.Locked(ctx, "ip:10.0.0.1")
+	assert.Equal(t, 2*time.Minute, wait)
+	assert.Equal(t, 3*time.Minute, lockout.policy.lockDuration(5))
+
+	// Locks set by another instance are picked up on reload
+	repo.locks["account:hmac:billing"] = now.Add(time.Minute)
+	now = now.Add(authLockReloadInterval)
+	_, locked = lockout.Locked(ctx, "account:hmac:billing")
+	assert.True(t, locked)
+}
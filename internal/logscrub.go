@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Markers around the user content written with LogPayload. They are control
+// characters, which LogPayload strips from the content so it cannot close its marker.
+const (
+	payloadStart = "\x1e"
+	payloadEnd   = "\x1f"
+)
+
+var (
+	// logSecretPattern matches the credentials the API hands out, by their prefix
+	logSecretPattern = regexp.MustCompile(`\b(` + tokenPrefix + `|` + webhookSecretPrefix + `|` + invitationPrefix + `|sk_live_|sk_test_)[A-Za-z0-9_\-]{8,}`)
+	// logBearerPattern matches credentials in Authorization headers
+	logBearerPattern = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=\-]{8,}`)
+	// logParamPattern matches credentials in query strings and form bodies
+	logParamPattern = regexp.MustCompile(`(?i)([?&](?:token|secret|password|api_key|key|signature|code)=)[^&\s"']+`)
+	// logFieldPattern matches credentials in JSON
+	logFieldPattern = regexp.MustCompile(`(?i)("(?:token|secret|password|api_key|signature|recovery_codes?)"\s*:\s*)"[^"]*"`)
+	logEmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	logPayload      = regexp.MustCompile(payloadStart + `([^` + payloadEnd + `]*)` + payloadEnd)
+)
+
+// LogPayload marks user content, such as event descriptions and notification texts,
+// in a log line. LogScrubber replaces it with its length unless payloads are logged
+// in full.
+func LogPayload(s string) string {
+	s = strings.NewReplacer(payloadStart, "", payloadEnd, "").Replace(s)
+	return payloadStart + s + payloadEnd
+}
+
+// ScrubLog redacts credentials and email addresses from a log line, and the contents
+// marked with LogPayload unless full is set, in which case they are only unmarked
+func ScrubLog(line string, full bool) string {
+	line = logPayload.ReplaceAllStringFunc(line, func(m string) string {
+		content := m[len(payloadStart) : len(m)-len(payloadEnd)]
+		if full {
+			return content
+		}
+		return fmt.Sprintf("[%d bytes redacted]", len(content))
+	})
+	line = logSecretPattern.ReplaceAllString(line, "${1}[redacted]")
+	line = logBearerPattern.ReplaceAllString(line, "$1 [redacted]")
+	line = logParamPattern.ReplaceAllString(line, "${1}[redacted]")
+	line = logFieldPattern.ReplaceAllString(line, `$1"[redacted]"`)
+	return logEmailPattern.ReplaceAllString(line, "[email]")
+}
+
+// LogScrubber is the log output scrubbing every entry with ScrubLog before it reaches
+// the underlying writer. The log package writes each entry in one call, so entries are
+// never split across calls.
+type LogScrubber struct {
+	out  io.Writer
+	full bool
+	mu   sync.Mutex
+}
+
+// NewLogScrubber writes scrubbed entries to out. full logs the contents marked with
+// LogPayload; credentials and email addresses are redacted either way.
+func NewLogScrubber(out io.Writer, full bool) *LogScrubber {
+	return &LogScrubber{out: out, full: full}
+}
+
+func (s *LogScrubber) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := io.WriteString(s.out, ScrubLog(string(p), s.full)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/lib/pq"
+)
+
+// Operation kinds of the maintenance endpoints
+const (
+	OperationReindex      = "reindex"
+	OperationRefreshStats = "refresh_stats"
+)
+
+// tableNamePattern accepts unquoted Postgres table names
+var tableNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// MaintenanceInput is the input of a maintenance operation. Tables limits a reindex to
+// the indexes of those tables; all tables are reindexed when it is empty.
+type MaintenanceInput struct {
+	Tables []string `json:"tables,omitempty"`
+}
+
+// Validate returns a client-facing message when the input is invalid
+func (in MaintenanceInput) Validate() string {
+	for _, t := range in.Tables {
+		if !tableNamePattern.MatchString(t) {
+			return fmt.Sprintf("invalid table name %q", t)
+		}
+	}
+	return ""
+}
+
+// MaterializedView is a materialized view of the database
+type MaterializedView struct {
+	Name string
+	// Concurrent is set when the view has a unique index, so it can be refreshed
+	// without blocking its readers
+	Concurrent bool
+}
+
+type MaintenanceRepository struct {
+	db *sql.DB
+}
+
+// NewMaintenanceRepository creates a repository running database maintenance
+func NewMaintenanceRepository(db *sql.DB) *MaintenanceRepository {
+	return &MaintenanceRepository{db: db}
+}
+
+// ListIndexes returns the indexes of tables in the public schema, or of all of them
+// when tables is empty
+func (r *MaintenanceRepository) ListIndexes(ctx context.Context, tables []string) ([]string, error) {
+	rows, err := traced(ctx, r.db).QueryContext(ctx, `
+		SELECT indexname FROM pg_indexes
+		WHERE schemaname = 'public' AND (cardinality($1::text[]) = 0 OR tablename = ANY($1))
+		ORDER BY tablename, indexname`, pq.Array(tables))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan index: %w", err)
+		}
+		indexes = append(indexes, name)
+	}
+	return indexes, rows.Err()
+}
+
+// Reindex rebuilds an index without locking out writes to its table
+func (r *MaintenanceRepository) Reindex(ctx context.Context, index string) error {
+	if _, err := traced(ctx, r.db).ExecContext(ctx, `REINDEX INDEX CONCURRENTLY `+pq.QuoteIdentifier(index)); err != nil {
+		return fmt.Errorf("failed to reindex %s: %w", index, err)
+	}
+	return nil
+}
+
+// ListMaterializedViews returns the materialized views of the public schema
+func (r *MaintenanceRepository) ListMaterializedViews(ctx context.Context) ([]MaterializedView, error) {
+	rows, err := traced(ctx, r.db).QueryContext(ctx, `
+		SELECT v.matviewname, EXISTS (
+			SELECT 1 FROM pg_index i
+			WHERE i.indrelid = format('%I.%I', v.schemaname, v.matviewname)::regclass AND i.indisunique
+		)
+		FROM pg_matviews v
+		WHERE v.schemaname = 'public'
+		ORDER BY v.matviewname`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list materialized views: %w", err)
+	}
+	defer rows.Close()
+
+	var views []MaterializedView
+	for rows.Next() {
+		var v MaterializedView
+		if err := rows.Scan(&v.Name, &v.Concurrent); err != nil {
+			return nil, fmt.Errorf("failed to scan materialized view: %w", err)
+		}
+		views = append(views, v)
+	}
+	return views, rows.Err()
+}
+
+// RefreshMaterializedView recomputes a materialized view
+func (r *MaintenanceRepository) RefreshMaterializedView(ctx context.Context, v MaterializedView) error {
+	query := `REFRESH MATERIALIZED VIEW `
+	if v.Concurrent {
+		query += `CONCURRENTLY `
+	}
+	if _, err := traced(ctx, r.db).ExecContext(ctx, query+pq.QuoteIdentifier(v.Name)); err != nil {
+		return fmt.Errorf("failed to refresh %s: %w", v.Name, err)
+	}
+	return nil
+}
+
+// decodeMaintenanceInput reads the input of a maintenance operation, which may be empty
+func decodeMaintenanceInput(input []byte) (MaintenanceInput, error) {
+	var in MaintenanceInput
+	if len(input) > 0 {
+		if err := json.Unmarshal(input, &in); err != nil {
+			return in, fmt.Errorf("invalid maintenance input: %w", err)
+		}
+	}
+	if msg := in.Validate(); msg != "" {
+		return in, errors.New(msg)
+	}
+	return in, nil
+}
+
+// ReindexOperation returns the function processing reindex operations. Indexes are
+// rebuilt one at a time, saving progress after each; one that fails is reported and
+// the others are still rebuilt.
+func ReindexOperation(repo MaintenanceRepositoryInterface) OperationFunc {
+	return func(ctx context.Context, input []byte, tracker *OperationTracker) (string, error) {
+		in, err := decodeMaintenanceInput(input)
+		if err != nil {
+			return "", err
+		}
+		indexes, err := repo.ListIndexes(ctx, in.Tables)
+		if err != nil {
+			return "", err
+		}
+		if len(indexes) == 0 {
+			return "", errors.New("no indexes to rebuild")
+		}
+		tracker.SetTotal(len(indexes))
+		if err := tracker.Save(ctx); err != nil {
+			return "", err
+		}
+
+		for i, index := range indexes {
+			if err := repo.Reindex(ctx, index); err != nil {
+				if ctx.Err() != nil {
+					return "", err
+				}
+				tracker.Failed(i+1, err)
+			} else {
+				tracker.Succeeded(1)
+			}
+			if err := tracker.Save(ctx); err != nil {
+				return "", err
+			}
+		}
+		p := tracker.Progress()
+		return fmt.Sprintf("rebuilt %d indexes, %d failed", p.Succeeded, p.Failed), nil
+	}
+}
+
+// RefreshStatsOperation returns the function processing refresh_stats operations,
+// which recompute every materialized view one at a time
+func RefreshStatsOperation(repo MaintenanceRepositoryInterface) OperationFunc {
+	return func(ctx context.Context, input []byte, tracker *OperationTracker) (string, error) {
+		views, err := repo.ListMaterializedViews(ctx)
+		if err != nil {
+			return "", err
+		}
+		tracker.SetTotal(len(views))
+
+		for i, v := range views {
+			if err := repo.RefreshMaterializedView(ctx, v); err != nil {
+				if ctx.Err() != nil {
+					return "", err
+				}
+				tracker.Failed(i+1, err)
+			} else {
+				tracker.Succeeded(1)
+			}
+			if err := tracker.Save(ctx); err != nil {
+				return "", err
+			}
+		}
+		p := tracker.Progress()
+		return fmt.Sprintf("refreshed %d materialized views, %d failed", p.Succeeded, p.Failed), nil
+	}
+}
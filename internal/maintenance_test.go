@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// savedProgress records the progress saved by operations
+type savedProgress struct {
+	OperationRepositoryInterface
+	saves []Operation
+}
+
+func (f *savedProgress) SaveOperationProgress(ctx context.Context, op Operation, lockedUntil time.Time) error {
+	f.saves = append(f.saves, op)
+	return nil
+}
+
+// fakeMaintenance pretends to rebuild indexes and refresh views
+type fakeMaintenance struct {
+	indexes   map[string][]string
+	broken    string
+	rebuilt   []string
+	views     []MaterializedView
+	refreshed []string
+}
+
+func (f *fakeMaintenance) ListIndexes(ctx context.Context, tables []string) ([]string, error) {
+	var out []string
+	for table, indexes := range f.indexes {
+		if len(tables) == 0 || contains(tables, table) {
+			out = append(out, indexes...)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeMaintenance) Reindex(ctx context.Context, index string) error {
+	if index == f.broken {
+		return errors.New("could not create unique index")
+	}
+	f.rebuilt = append(f.rebuilt, index)
+	return nil
+}
+
+func (f *fakeMaintenance) ListMaterializedViews(ctx context.Context) ([]MaterializedView, error) {
+	return f.views, nil
+}
+
+func (f *fakeMaintenance) RefreshMaterializedView(ctx context.Context, v MaterializedView) error {
+	f.refreshed = append(f.refreshed, v.Name)
+	return nil
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReindexOperation(t *testing.T) {
+	repo := &fakeMaintenance{indexes: map[string][]string{
+		"events":   {"events_pkey", "events_client_key_unique"},
+		"webhooks": {"webhooks_pkey"},
+	}, broken: "events_client_key_unique"}
+	progress := &savedProgress{}
+	tracker := &OperationTracker{repo: progress, lease: time.Minute}
+
+	result, err := ReindexOperation(repo)(context.Background(), []byte(`{"tables": ["events"]}`), tracker)
+	require.NoError(t, err)
+	assert.Equal(t, "rebuilt 1 indexes, 1 failed", result)
+	assert.Equal(t, []string{"events_pkey"}, repo.rebuilt)
+	p := tracker.Progress()
+	assert.Equal(t, 2, p.Total)
+	require.Len(t, p.Errors, 1)
+	assert.Contains(t, p.Errors[0].Message, "unique index")
+	// Progress is saved before the first index and after each one
+	assert.Len(t, progress.saves, 3)
+
+	_, err = ReindexOperation(repo)(context.Background(), []byte(`{"tables": ["events; DROP TABLE events"]}`), &OperationTracker{repo: progress})
+	assert.Error(t, err)
+	_, err = ReindexOperation(repo)(context.Background(), []byte(`{"tables": ["missing"]}`), &OperationTracker{repo: progress})
+	assert.EqualError(t, err, "no indexes to rebuild")
+}
+
+func TestRefreshStatsOperation(t *testing.T) {
+	repo := &fakeMaintenance{views: []MaterializedView{{Name: "event_stats_daily", Concurrent: true}}}
+	tracker := &OperationTracker{repo: &savedProgress{}}
+
+	result, err := RefreshStatsOperation(repo)(context.Background(), nil, tracker)
+	require.NoError(t, err)
+	assert.Equal(t, "refreshed 1 materialized views, 0 failed", result)
+	assert.Equal(t, []string{"event_stats_daily"}, repo.refreshed)
+}
+
+func TestRefreshEventStatsJob(t *testing.T) {
+	repo := &fakeMaintenance{}
+	result, err := RefreshEventStatsJob(repo)(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "refreshed event_stats_daily", result)
+	assert.Equal(t, []string{"event_stats_daily"}, repo.refreshed)
+}
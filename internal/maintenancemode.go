@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Maintenance modes
+const (
+	MaintenanceOff = "off"
+	// MaintenanceReadOnly rejects writes while reads keep working
+	MaintenanceReadOnly = "read_only"
+	// MaintenanceFull answers every request with the maintenance message
+	MaintenanceFull = "full"
+)
+
+// maintenanceReloadInterval is how often the mode is re-read, picking up switches made
+// through other instances
+const maintenanceReloadInterval = 5 * time.Second
+
+// MaintenanceMode is the maintenance switch, shared by every instance through the
+// database
+type MaintenanceMode struct {
+	Mode string `json:"mode"`
+	// Message is shown to clients instead of the default one when set
+	Message string `json:"message,omitempty"`
+	// RetryAfter is the Retry-After, in seconds, of the responses refused
+	RetryAfter int       `json:"retry_after"`
+	UpdatedBy  string    `json:"updated_by,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Validate returns a client-facing message when the mode is invalid
+func (m MaintenanceMode) Validate() string {
+	switch m.Mode {
+	case MaintenanceOff, MaintenanceReadOnly, MaintenanceFull:
+	default:
+		return fmt.Sprintf("mode must be %s, %s or %s", MaintenanceOff, MaintenanceReadOnly, MaintenanceFull)
+	}
+	if m.RetryAfter <= 0 {
+		return "retry_after must be a positive number of seconds"
+	}
+	if len(m.Message) > 1000 {
+		return "message must be at most 1000 characters"
+	}
+	return ""
+}
+
+// GetMaintenanceMode returns the stored mode, off when it was never switched
+func (r *MaintenanceRepository) GetMaintenanceMode(ctx context.Context) (*MaintenanceMode, error) {
+	var m MaintenanceMode
+	err := traced(ctx, r.db).QueryRowContext(ctx, `
+		SELECT mode, message, retry_after_seconds, updated_by, updated_at FROM maintenance_mode`).
+		Scan(&m.Mode, &m.Message, &m.RetryAfter, &m.UpdatedBy, &m.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &MaintenanceMode{Mode: MaintenanceOff, RetryAfter: 300}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get maintenance mode: %w", err)
+	}
+	return &m, nil
+}
+
+// SetMaintenanceMode stores m, returning it with its update time
+func (r *MaintenanceRepository) SetMaintenanceMode(ctx context.Context, m MaintenanceMode) (*MaintenanceMode, error) {
+	err := traced(ctx, r.db).QueryRowContext(ctx, `
+		INSERT INTO maintenance_mode (id, mode, message, retry_after_seconds, updated_by, updated_at)
+		VALUES (true, $1, $2, $3, $4, NOW())
+		ON CONFLICT (id) DO UPDATE
+		SET mode = EXCLUDED.mode, message = EXCLUDED.message, retry_after_seconds = EXCLUDED.retry_after_seconds,
+			updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+		RETURNING updated_at`, m.Mode, m.Message, m.RetryAfter, m.UpdatedBy).Scan(&m.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set maintenance mode: %w", err)
+	}
+	return &m, nil
+}
+
+// MaintenanceSwitch serves the maintenance mode to every request from memory,
+// re-reading it every maintenanceReloadInterval
+type MaintenanceSwitch struct {
+	repo MaintenanceModeRepositoryInterface
+	now  func() time.Time
+
+	mu       sync.Mutex
+	mode     MaintenanceMode
+	loadedAt time.Time
+}
+
+// NewMaintenanceSwitch creates a switch reading the mode from repo
+func NewMaintenanceSwitch(repo MaintenanceModeRepositoryInterface) *MaintenanceSwitch {
+	return &MaintenanceSwitch{repo: repo, now: time.Now, mode: MaintenanceMode{Mode: MaintenanceOff}}
+}
+
+// Current returns the mode, re-reading it when it is older than
+// maintenanceReloadInterval. When the database cannot be read the last mode known is
+// kept, so an outage neither turns maintenance on nor lifts it.
+func (s *MaintenanceSwitch) Current(ctx context.Context) MaintenanceMode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.loadedAt.IsZero() && s.now().Sub(s.loadedAt) < maintenanceReloadInterval {
+		return s.mode
+	}
+	s.loadedAt = s.now()
+	m, err := s.repo.GetMaintenanceMode(ctx)
+	if err != nil {
+		log.Printf("Error reading maintenance mode, keeping %s: %v", s.mode.Mode, err)
+		return s.mode
+	}
+	s.mode = *m
+	return s.mode
+}
+
+// Set stores m and applies it to this instance at once; other instances follow
+// within maintenanceReloadInterval
+func (s *MaintenanceSwitch) Set(ctx context.Context, m MaintenanceMode) (*MaintenanceMode, error) {
+	stored, err := s.repo.SetMaintenanceMode(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.mode, s.loadedAt = *stored, s.now()
+	s.mu.Unlock()
+	log.Printf("Maintenance mode set to %s by %s", stored.Mode, stored.UpdatedBy)
+	return stored, nil
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyMaintenanceRepository returns mode, or err when set
+type flakyMaintenanceRepository struct {
+	mode  MaintenanceMode
+	err   error
+	reads int
+}
+
+func (f *flakyMaintenanceRepository) GetMaintenanceMode(ctx context.Context) (*MaintenanceMode, error) {
+	f.reads++
+	if f.err != nil {
+		return nil, f.err
+	}
+	m := f.mode
+	return &m, nil
+}
+
+func (f *flakyMaintenanceRepository) SetMaintenanceMode(ctx context.Context, m MaintenanceMode) (*MaintenanceMode, error) {
+	f.mode = m
+	return &m, nil
+}
+
+func TestMaintenanceSwitch(t *testing.T) {
+	now := time.Date(2025, 10, 5, 9, 0, 0, 0, time.UTC)
+	repo := &flakyMaintenanceRepository{mode: MaintenanceMode{Mode: MaintenanceReadOnly, RetryAfter: 60}}
+	sw := NewMaintenanceSwitch(repo)
+	sw.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	assert.Equal(t, MaintenanceReadOnly, sw.Current(ctx).Mode)
+	assert.Equal(t, MaintenanceReadOnly, sw.Current(ctx).Mode)
+	assert.Equal(t, 1, repo.reads, "served from memory until the reload interval")
+
+	// Another instance lifts it; this one follows after the interval
+	repo.mode.Mode = MaintenanceOff
+	now = now.Add(maintenanceReloadInterval)
+	assert.Equal(t, MaintenanceOff, sw.Current(ctx).Mode)
+
+	// A database outage keeps the last mode known
+	sw.Set(ctx, MaintenanceMode{Mode: MaintenanceFull, RetryAfter: 60})
+	repo.err = errors.New("connection refused")
+	now = now.Add(maintenanceReloadInterval)
+	assert.Equal(t, MaintenanceFull, sw.Current(ctx).Mode)
+
+	assert.NotEmpty(t, MaintenanceMode{Mode: "partial", RetryAfter: 60}.Validate())
+	assert.NotEmpty(t, MaintenanceMode{Mode: MaintenanceFull}.Validate())
+	assert.Empty(t, MaintenanceMode{Mode: MaintenanceFull, RetryAfter: 60}.Validate())
+}
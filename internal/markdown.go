@@ -0,0 +1,190 @@
+package internal
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Description formats stored alongside the raw description source
+const (
+	DescriptionFormatPlain    = "plain"
+	DescriptionFormatMarkdown = "markdown"
+)
+
+var (
+	headingPattern     = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	unorderedPattern   = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	orderedPattern     = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	rulePattern        = regexp.MustCompile(`^\s*(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	linkPattern        = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	strongPattern      = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`)
+	emphasisPattern    = regexp.MustCompile(`\*(\S(?:.*?\S)?)\*`)
+	underscorePattern  = regexp.MustCompile(`(^|[^\w])_(\S(?:.*?\S)?)_([^\w]|$)`)
+	placeholderPattern = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// maxQuoteDepth is how deep blockquotes nest; deeper '>' markers are kept as text, so
+// each level of a description is rendered a bounded number of times
+const maxQuoteDepth = 8
+
+// allowedLinkSchemes are the only URL schemes rendered as links; anything else
+// (javascript:, data:, vbscript:...) is shown as plain text
+var allowedLinkSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// RenderDescriptionHTML renders a stored description to safe HTML according to its format
+func RenderDescriptionHTML(description, format string) string {
+	if format == DescriptionFormatMarkdown {
+		return RenderMarkdown(description)
+	}
+	var b strings.Builder
+	for _, para := range strings.Split(strings.ReplaceAll(description, "\r\n", "\n"), "\n\n") {
+		if strings.TrimSpace(para) == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(para), "\n", "<br>"))
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
+
+// RenderMarkdown converts a CommonMark subset (headings, paragraphs, lists, quotes,
+// fenced code, rules, emphasis, code spans and links) to HTML. Raw HTML in the source
+// is always escaped, so the output is safe to embed without a separate sanitizer.
+func RenderMarkdown(src string) string {
+	return renderMarkdown(src, 0)
+}
+
+// renderMarkdown renders src nested in depth blockquotes
+func renderMarkdown(src string, depth int) string {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	var b strings.Builder
+	var para []string
+	listTag := ""
+
+	flushPara := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>" + renderInline(strings.Join(para, "\n")) + "</p>\n")
+			para = nil
+		}
+	}
+	closeList := func() {
+		if listTag != "" {
+			b.WriteString("</" + listTag + ">\n")
+			listTag = ""
+		}
+	}
+	openList := func(tag string) {
+		if listTag != tag {
+			closeList()
+			b.WriteString("<" + tag + ">\n")
+			listTag = tag
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			flushPara()
+			closeList()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			b.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+		case trimmed == "":
+			flushPara()
+			closeList()
+		case rulePattern.MatchString(line):
+			flushPara()
+			closeList()
+			b.WriteString("<hr>\n")
+		case headingPattern.MatchString(trimmed):
+			flushPara()
+			closeList()
+			m := headingPattern.FindStringSubmatch(trimmed)
+			level := len(m[1])
+			b.WriteString(fmt.Sprintf("<h%d>%s</h%d>\n", level, renderInline(m[2]), level))
+		case strings.HasPrefix(trimmed, ">") && depth < maxQuoteDepth:
+			flushPara()
+			closeList()
+			var quote []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				quote = append(quote, strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(lines[i]), ">"), " "))
+			}
+			i--
+			b.WriteString("<blockquote>\n" + renderMarkdown(strings.Join(quote, "\n"), depth+1) + "</blockquote>\n")
+		case unorderedPattern.MatchString(line):
+			flushPara()
+			openList("ul")
+			b.WriteString("<li>" + renderInline(unorderedPattern.FindStringSubmatch(line)[1]) + "</li>\n")
+		case orderedPattern.MatchString(line):
+			flushPara()
+			openList("ol")
+			b.WriteString("<li>" + renderInline(orderedPattern.FindStringSubmatch(line)[1]) + "</li>\n")
+		default:
+			closeList()
+			para = append(para, trimmed)
+		}
+	}
+	flushPara()
+	closeList()
+	return b.String()
+}
+
+// renderInline formats code spans, links and emphasis within one block
+func renderInline(text string) string {
+	var b strings.Builder
+	// Odd segments between backticks are code spans and get no further formatting
+	segments := strings.Split(text, "`")
+	for i, seg := range segments {
+		if i%2 == 1 && i < len(segments)-1 {
+			b.WriteString("<code>" + html.EscapeString(seg) + "</code>")
+			continue
+		}
+		if i%2 == 1 {
+			b.WriteString("`")
+		}
+		b.WriteString(renderSpans(seg))
+	}
+	return strings.ReplaceAll(b.String(), "\n", "<br>\n")
+}
+
+// renderSpans escapes text and applies links and emphasis
+func renderSpans(text string) string {
+	// Links are swapped for NUL-delimited placeholders first so emphasis never
+	// touches URLs; NULs in the input are dropped so they cannot forge one
+	text = strings.ReplaceAll(text, "\x00", "")
+	var links []string
+	text = linkPattern.ReplaceAllStringFunc(text, func(m string) string {
+		parts := linkPattern.FindStringSubmatch(m)
+		label, href := parts[1], parts[2]
+		u, err := url.Parse(href)
+		if err != nil || !allowedLinkSchemes[strings.ToLower(u.Scheme)] {
+			return m
+		}
+		links = append(links, fmt.Sprintf(`<a href="%s" rel="nofollow noopener">%s</a>`,
+			html.EscapeString(href), applyEmphasis(html.EscapeString(label))))
+		return fmt.Sprintf("\x00%d\x00", len(links)-1)
+	})
+
+	text = applyEmphasis(html.EscapeString(text))
+
+	return placeholderPattern.ReplaceAllStringFunc(text, func(m string) string {
+		var idx int
+		fmt.Sscanf(placeholderPattern.FindStringSubmatch(m)[1], "%d", &idx)
+		return links[idx]
+	})
+}
+
+func applyEmphasis(escaped string) string {
+	escaped = strongPattern.ReplaceAllString(escaped, "<strong>$1</strong>")
+	escaped = emphasisPattern.ReplaceAllString(escaped, "<em>$1</em>")
+	return underscorePattern.ReplaceAllString(escaped, "$1<em>$2</em>$3")
+}
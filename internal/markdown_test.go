@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "heading and paragraph",
+			src:  "# Agenda\n\nWelcome **everyone** to *Go*",
+			want: "<h1>Agenda</h1>\n<p>Welcome <strong>everyone</strong> to <em>Go</em></p>\n",
+		},
+		{
+			name: "lists",
+			src:  "- one\n- two\n\n1. first\n2. second",
+			want: "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n<ol>\n<li>first</li>\n<li>second</li>\n</ol>\n",
+		},
+		{
+			name: "code is not formatted",
+			src:  "run `go test **./...**`",
+			want: "<p>run <code>go test **./...**</code></p>\n",
+		},
+		{
+			name: "fenced code is escaped",
+			src:  "```\n<b>x</b>\n```",
+			want: "<pre><code>&lt;b&gt;x&lt;/b&gt;</code></pre>\n",
+		},
+		{
+			name: "link",
+			src:  "see [the docs](https://go.dev/doc?a=1&b=snake_case_name)",
+			want: "<p>see <a href=\"https://go.dev/doc?a=1&amp;b=snake_case_name\" rel=\"nofollow noopener\">the docs</a></p>\n",
+		},
+		{
+			name: "raw html is escaped",
+			src:  "<script>alert(1)</script>",
+			want: "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n",
+		},
+		{
+			name: "javascript links are not rendered",
+			src:  "[click](javascript:alert(1))",
+			want: "<p>[click](javascript:alert(1))</p>\n",
+		},
+		{
+			name: "attribute injection through href",
+			src:  `[x](https://a.com/"onmouseover="alert(1))`,
+			want: "<p><a href=\"https://a.com/&#34;onmouseover=&#34;alert(1\" rel=\"nofollow noopener\">x</a>)</p>\n",
+		},
+		{
+			name: "blockquote",
+			src:  "> quoted _text_",
+			want: "<blockquote>\n<p>quoted <em>text</em></p>\n</blockquote>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, RenderMarkdown(tt.src))
+		})
+	}
+}
+
+func TestRenderMarkdownDeepQuotes(t *testing.T) {
+	got := RenderMarkdown(strings.Repeat(">", maxQuoteDepth+2) + " deep")
+	assert.Equal(t, maxQuoteDepth, strings.Count(got, "<blockquote>"))
+	assert.Contains(t, got, "<p>&gt;&gt; deep</p>")
+
+	// Nesting no longer costs a pass over the text per '>'
+	src := strings.Repeat(">", 100000)
+	start := time.Now()
+	RenderMarkdown(src)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestRenderDescriptionHTMLPlain(t *testing.T) {
+	got := RenderDescriptionHTML("line <1>\nline 2\n\n**not bold**", DescriptionFormatPlain)
+	assert.Equal(t, "<p>line &lt;1&gt;<br>line 2</p>\n<p>**not bold**</p>\n", got)
+}
@@ -0,0 +1,518 @@
+package internal
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// errEventExists is returned when creating an event with the ID of another
+var errEventExists = newDomainError(ErrConflict, "an event with this ID already exists")
+
+// MemoryEventRepository keeps events in memory, for serve --mock and tests that need a
+// working repository without PostgreSQL. It follows the PostgreSQL repository on
+// ordering, versions, tombstones and review statuses; fuzzy suggestions match like
+// plain ones, and nothing survives a restart.
+type MemoryEventRepository struct {
+	mu      sync.Mutex
+	events  map[uuid.UUID]EventDB
+	deleted map[uuid.UUID]EventTombstone
+	reviews []EventReview
+	// version is the last version given to a write, like the events_version sequence
+	version int64
+	now     func() time.Time
+}
+
+// NewMemoryEventRepository returns a repository holding events, which keep their IDs,
+// timestamps and statuses
+func NewMemoryEventRepository(events []EventDB) *MemoryEventRepository {
+	r := &MemoryEventRepository{
+		events:  make(map[uuid.UUID]EventDB, len(events)),
+		deleted: map[uuid.UUID]EventTombstone{},
+		now:     time.Now,
+	}
+	for _, e := range events {
+		r.insert(e)
+	}
+	return r
+}
+
+// insert stores e with the next version, defaulting what the table defaults
+func (r *MemoryEventRepository) insert(e EventDB) EventDB {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	if e.DescriptionFormat == "" {
+		e.DescriptionFormat = DescriptionFormatPlain
+	}
+	if e.Status == "" {
+		e.Status = EventStatusApproved
+	}
+	now := r.now()
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = now
+	}
+	if e.UpdatedAt.IsZero() {
+		e.UpdatedAt = e.CreatedAt
+	}
+	normalizeEventTimes(&e)
+	r.version++
+	e.Version = r.version
+	r.events[e.ID] = e
+	return e
+}
+
+// update replaces the fields of the stored event like qUpdateEvent
+func (r *MemoryEventRepository) update(old, e EventDB) EventDB {
+	if e.DescriptionFormat == "" {
+		e.DescriptionFormat = DescriptionFormatPlain
+	}
+	if e.Status == "" {
+		e.Status, e.SubmittedBy = old.Status, old.SubmittedBy
+	}
+	e.CreatedAt, e.UpdatedAt, e.ClientKey = old.CreatedAt, r.now(), old.ClientKey
+	normalizeEventTimes(&e)
+	r.version++
+	e.Version = r.version
+	r.events[e.ID] = e
+	return e
+}
+
+// remove deletes an event and leaves its tombstone
+func (r *MemoryEventRepository) remove(id uuid.UUID) {
+	delete(r.events, id)
+	r.version++
+	r.deleted[id] = EventTombstone{ID: id, Version: r.version, DeletedAt: NormalizeTime(r.now())}
+}
+
+// taken reports which unique constraint e breaks against the other stored events
+func (r *MemoryEventRepository) taken(e EventDB) error {
+	for _, other := range r.events {
+		if other.ID == e.ID {
+			continue
+		}
+		if e.ClientKey != nil && other.ClientKey != nil && *e.ClientKey == *other.ClientKey && sameCalendar(e.CalendarID, other.CalendarID) {
+			return ErrClientKeyTaken
+		}
+		if e.ExternalID != nil && other.ExternalID != nil && *e.ExternalID == *other.ExternalID && equalOptional(e.Source, other.Source) {
+			return ErrExternalIDTaken
+		}
+	}
+	return nil
+}
+
+// equalOptional compares two optional strings, nil equal to nil only
+func equalOptional(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// list returns the events keep matches, ordered by start time then ID
+func (r *MemoryEventRepository) list(keep func(EventDB) bool) []EventDB {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := []EventDB{}
+	for _, e := range r.events {
+		if keep(e) {
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].StartTime.Equal(events[j].StartTime) {
+			return events[i].StartTime.Before(events[j].StartTime)
+		}
+		return events[i].ID.String() < events[j].ID.String()
+	})
+	return events
+}
+
+// CreateEvent stores a new event, generating its ID unless it has one
+func (r *MemoryEventRepository) CreateEvent(ctx context.Context, event EventDB) (*EventDB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.events[event.ID]; ok && event.ID != uuid.Nil {
+		return nil, errEventExists
+	}
+	if err := r.taken(event); err != nil {
+		return nil, err
+	}
+	event.CreatedAt, event.UpdatedAt = time.Time{}, time.Time{}
+	created := r.insert(event)
+	return &created, nil
+}
+
+// GetEvents returns every event, ordered by start time
+func (r *MemoryEventRepository) GetEvents(ctx context.Context) ([]EventDB, error) {
+	return r.list(func(EventDB) bool { return true }), nil
+}
+
+// GetEventByID returns the event with id
+func (r *MemoryEventRepository) GetEventByID(ctx context.Context, id uuid.UUID) (*EventDB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.events[id]
+	if !ok {
+		return nil, ErrEventNotFound
+	}
+	return &e, nil
+}
+
+// GetEventsBetween returns the events overlapping [from, to), ordered by start time
+func (r *MemoryEventRepository) GetEventsBetween(ctx context.Context, from, to time.Time) ([]EventDB, error) {
+	return r.list(func(e EventDB) bool { return e.StartTime.Before(to) && e.EndTime.After(from) }), nil
+}
+
+// GetEventsByCalendar returns the events of a calendar, ordered by start time
+func (r *MemoryEventRepository) GetEventsByCalendar(ctx context.Context, calendarID uuid.UUID) ([]EventDB, error) {
+	return r.list(func(e EventDB) bool { return e.CalendarID != nil && *e.CalendarID == calendarID }), nil
+}
+
+// GetEventsByExternalID returns the events synced with externalID, from source or,
+// when source is empty, from any system
+func (r *MemoryEventRepository) GetEventsByExternalID(ctx context.Context, source, externalID string) ([]EventDB, error) {
+	return r.list(func(e EventDB) bool {
+		return e.ExternalID != nil && *e.ExternalID == externalID && (source == "" || (e.Source != nil && *e.Source == source))
+	}), nil
+}
+
+// GetEventByClientKey returns the event of calendarID, or of no calendar when it is
+// nil, with clientKey
+func (r *MemoryEventRepository) GetEventByClientKey(ctx context.Context, calendarID *uuid.UUID, clientKey string) (*EventDB, error) {
+	events := r.list(func(e EventDB) bool {
+		return e.ClientKey != nil && *e.ClientKey == clientKey && sameCalendar(e.CalendarID, calendarID)
+	})
+	if len(events) == 0 {
+		return nil, ErrEventNotFound
+	}
+	return &events[0], nil
+}
+
+// UpdateEvent replaces the fields of an existing event; created_at and client_key are
+// kept, and the status unless a new one is set
+func (r *MemoryEventRepository) UpdateEvent(ctx context.Context, event EventDB) (*EventDB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old, ok := r.events[event.ID]
+	if !ok {
+		return nil, ErrEventNotFound
+	}
+	if err := r.taken(event); err != nil {
+		return nil, err
+	}
+	updated := r.update(old, event)
+	return &updated, nil
+}
+
+// DeleteEvent removes an event
+func (r *MemoryEventRepository) DeleteEvent(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.events[id]; !ok {
+		return ErrEventNotFound
+	}
+	r.remove(id)
+	return nil
+}
+
+// ImportEvents stores events with their IDs and timestamps, skipping IDs that exist
+// and updating the event with the same source and external ID, and returns the number
+// of events inserted
+func (r *MemoryEventRepository) ImportEvents(ctx context.Context, events []EventDB) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	inserted := 0
+	for _, e := range events {
+		if old, ok := r.byExternalID(e); ok {
+			// Only the synced fields change, like qImportExternalEvent
+			merged := old
+			merged.Title, merged.Description, merged.DescriptionFormat = e.Title, e.Description, e.DescriptionFormat
+			merged.StartTime, merged.EndTime, merged.Location = e.StartTime, e.EndTime, e.Location
+			merged.Latitude, merged.Longitude, merged.CalendarID = e.Latitude, e.Longitude, e.CalendarID
+			merged.Status = ""
+			r.update(old, merged)
+			continue
+		}
+		if _, ok := r.events[e.ID]; ok {
+			continue
+		}
+		r.insert(e)
+		inserted++
+	}
+	return inserted, nil
+}
+
+// byExternalID returns the stored event with the source and external ID of e
+func (r *MemoryEventRepository) byExternalID(e EventDB) (EventDB, bool) {
+	if e.ExternalID == nil {
+		return EventDB{}, false
+	}
+	for _, old := range r.events {
+		if old.ExternalID != nil && *old.ExternalID == *e.ExternalID && equalOptional(old.Source, e.Source) {
+			return old, true
+		}
+	}
+	return EventDB{}, false
+}
+
+// PullChanges returns up to limit changes made after cursor, oldest first
+func (r *MemoryEventRepository) PullChanges(ctx context.Context, after SyncCursor, limit int) (*SyncPage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type change struct {
+		cursor  SyncCursor
+		event   *EventDB
+		deleted *EventTombstone
+	}
+	var changes []change
+	later := func(c SyncCursor) bool {
+		return c.Version > after.Version || (c.Version == after.Version && c.ID.String() > after.ID.String())
+	}
+	for _, e := range r.events {
+		if c := (SyncCursor{Version: e.Version, ID: e.ID}); later(c) {
+			changes = append(changes, change{cursor: c, event: &e})
+		}
+	}
+	for _, d := range r.deleted {
+		if c := (SyncCursor{Version: d.Version, ID: d.ID}); later(c) {
+			changes = append(changes, change{cursor: c, deleted: &d})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].cursor.Version != changes[j].cursor.Version {
+			return changes[i].cursor.Version < changes[j].cursor.Version
+		}
+		return changes[i].cursor.ID.String() < changes[j].cursor.ID.String()
+	})
+
+	page := &SyncPage{Events: []EventDB{}, Deleted: []EventTombstone{}, Next: after}
+	for i, c := range changes {
+		if i == limit {
+			page.HasMore = true
+			break
+		}
+		page.Next = c.cursor
+		if c.deleted != nil {
+			page.Deleted = append(page.Deleted, *c.deleted)
+		} else {
+			page.Events = append(page.Events, *c.event)
+		}
+	}
+	return page, nil
+}
+
+// ApplySyncChange writes a change pushed by a sync client unless the event changed
+// since BaseVersion, reporting conflicts like the PostgreSQL repository
+func (r *MemoryEventRepository) ApplySyncChange(ctx context.Context, c SyncChange) (*SyncOutcome, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := c.Event
+	normalizeEventTimes(&e)
+	server, exists := r.events[e.ID]
+
+	switch {
+	case c.Deleted && exists && server.Version == c.BaseVersion:
+		r.remove(e.ID)
+		return &SyncOutcome{Status: SyncApplied}, nil
+	case !c.Deleted && c.BaseVersion == 0 && !exists:
+		// A tombstone means the client is reviving an event deleted elsewhere
+		if _, ok := r.deleted[e.ID]; !ok {
+			e.ClientKey, e.ExternalID, e.Source, e.PriceCents, e.Currency, e.TicketQuota = nil, nil, nil, nil, nil, nil
+			e.CreatedAt, e.UpdatedAt = time.Time{}, time.Time{}
+			created := r.insert(e)
+			return &SyncOutcome{Status: SyncApplied, Version: created.Version}, nil
+		}
+	case !c.Deleted && c.BaseVersion != 0 && exists && server.Version == c.BaseVersion:
+		// Sync clients only edit these fields; the others are kept
+		merged := server
+		merged.Title, merged.Description, merged.DescriptionFormat = e.Title, e.Description, e.DescriptionFormat
+		merged.StartTime, merged.EndTime, merged.Location = e.StartTime, e.EndTime, e.Location
+		merged.Latitude, merged.Longitude, merged.CalendarID = e.Latitude, e.Longitude, e.CalendarID
+		merged.Status, merged.SubmittedBy = e.Status, e.SubmittedBy
+		updated := r.update(server, merged)
+		return &SyncOutcome{Status: SyncApplied, Version: updated.Version}, nil
+	}
+
+	// Nothing was written: find out whether the server already has what was pushed
+	if !exists {
+		if c.Deleted {
+			return &SyncOutcome{Status: SyncApplied}, nil
+		}
+		return &SyncOutcome{Status: SyncConflict, ServerDeleted: true}, nil
+	}
+	if !c.Deleted && sameEventContent(server, e) {
+		return &SyncOutcome{Status: SyncApplied, Version: server.Version}, nil
+	}
+	return &SyncOutcome{Status: SyncConflict, Version: server.Version, Server: &server}, nil
+}
+
+// ListPendingEvents returns up to limit events awaiting review, oldest submission first
+func (r *MemoryEventRepository) ListPendingEvents(ctx context.Context, limit int) ([]EventDB, error) {
+	pending := r.list(func(e EventDB) bool { return e.Status == EventStatusPending })
+	sort.SliceStable(pending, func(i, j int) bool {
+		if !pending[i].CreatedAt.Equal(pending[j].CreatedAt) {
+			return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+		}
+		return pending[i].ID.String() < pending[j].ID.String()
+	})
+	if len(pending) > limit {
+		pending = pending[:limit]
+	}
+	return pending, nil
+}
+
+// ReviewEvent records a decision on a pending event and sets its status. It fails with
+// ErrEventNotPending when the event was already reviewed.
+func (r *MemoryEventRepository) ReviewEvent(ctx context.Context, review EventReview) (*EventDB, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.events[review.EventID]
+	if !ok {
+		return nil, ErrEventNotFound
+	}
+	if e.Status != EventStatusPending {
+		return nil, ErrEventNotPending
+	}
+	reviewed := e
+	reviewed.Status = review.Decision
+	reviewed = r.update(e, reviewed)
+	if review.ID == uuid.Nil {
+		review.ID = uuid.New()
+	}
+	review.CreatedAt = NormalizeTime(r.now())
+	r.reviews = append(r.reviews, review)
+	return &reviewed, nil
+}
+
+// ListEventReviews returns the decisions on an event, oldest first
+func (r *MemoryEventRepository) ListEventReviews(ctx context.Context, eventID uuid.UUID) ([]EventReview, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reviews := []EventReview{}
+	for _, rv := range r.reviews {
+		if rv.EventID == eventID {
+			reviews = append(reviews, rv)
+		}
+	}
+	return reviews, nil
+}
+
+// Occupancy counts the published events overlapping each bucket of q, including
+// empty buckets
+func (r *MemoryEventRepository) Occupancy(ctx context.Context, q HeatmapQuery) ([]HeatmapBucket, error) {
+	events := r.list(func(e EventDB) bool {
+		return e.Status == EventStatusApproved && (q.CalendarID == nil || sameCalendar(e.CalendarID, q.CalendarID))
+	})
+	buckets := []HeatmapBucket{}
+	for start := q.From.In(q.Location); start.Before(q.To); start = heatmapNext(start, q.Bucket) {
+		b := HeatmapBucket{Start: start, End: heatmapNext(start, q.Bucket)}
+		for _, e := range events {
+			if e.StartTime.Before(b.End) && e.EndTime.After(b.Start) {
+				b.Count++
+			}
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, nil
+}
+
+// EventStats sums the published events starting on each UTC day of q by q.Group.
+// Unlike the PostgreSQL repository the counts are live.
+func (r *MemoryEventRepository) EventStats(ctx context.Context, q EventStatsQuery) ([]EventStatsRow, error) {
+	from := q.From.UTC().Format(time.DateOnly)
+	to := q.To.UTC().Format(time.DateOnly)
+
+	// Minutes are rounded down per day and calendar, as event_stats_daily does
+	type daily struct {
+		day      string
+		calendar string
+	}
+	days := map[daily]*EventStatsRow{}
+	seconds := map[daily]int64{}
+	for _, e := range r.list(func(e EventDB) bool { return e.Status == EventStatusApproved }) {
+		day := e.StartTime.UTC().Format(time.DateOnly)
+		if day < from || day >= to || (q.CalendarID != nil && !sameCalendar(e.CalendarID, q.CalendarID)) {
+			continue
+		}
+		k := daily{day: day}
+		if e.CalendarID != nil {
+			k.calendar = e.CalendarID.String()
+		}
+		if days[k] == nil {
+			days[k] = &EventStatsRow{}
+		}
+		days[k].Events++
+		seconds[k] += int64(e.EndTime.Sub(e.StartTime) / time.Second)
+	}
+
+	groups := map[string]*EventStatsRow{}
+	for k, d := range days {
+		key := k.day
+		switch q.Group {
+		case StatsGroupWeek:
+			t, _ := time.Parse(time.DateOnly, k.day)
+			key = heatmapFloor(t, HeatmapBucketWeek, time.UTC).Format(time.DateOnly)
+		case StatsGroupCalendar:
+			key = k.calendar
+		}
+		if groups[key] == nil {
+			groups[key] = &EventStatsRow{Key: key}
+		}
+		groups[key].Events += d.Events
+		groups[key].Minutes += seconds[k] / 60
+	}
+	stats := []EventStatsRow{}
+	for _, s := range groups {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Key < stats[j].Key })
+	return stats, nil
+}
+
+// SuggestTitles returns the distinct titles of published events starting with q.Text,
+// then those containing it, shorter titles first. Inputs shorter than three characters
+// only match title prefixes, like those the trigram index cannot serve.
+func (r *MemoryEventRepository) SuggestTitles(ctx context.Context, q SuggestQuery) ([]TitleSuggestion, error) {
+	text := strings.ToLower(q.Text)
+	anywhere := utf8.RuneCountInString(text) >= minTrigramLength
+	var titles []string
+	for _, e := range r.list(func(e EventDB) bool {
+		return e.Status == EventStatusApproved && (q.CalendarID == nil || sameCalendar(e.CalendarID, q.CalendarID))
+	}) {
+		title := strings.ToLower(e.Title)
+		if (strings.HasPrefix(title, text) || (anywhere && strings.Contains(title, text))) && !slices.Contains(titles, e.Title) {
+			titles = append(titles, e.Title)
+		}
+	}
+	sort.Slice(titles, func(i, j int) bool {
+		pi, pj := strings.HasPrefix(strings.ToLower(titles[i]), text), strings.HasPrefix(strings.ToLower(titles[j]), text)
+		if pi != pj {
+			return pi
+		}
+		if len(titles[i]) != len(titles[j]) {
+			return len(titles[i]) < len(titles[j])
+		}
+		return titles[i] < titles[j]
+	})
+	if len(titles) > q.Limit {
+		titles = titles[:q.Limit]
+	}
+	suggestions := []TitleSuggestion{}
+	for _, title := range titles {
+		suggestions = append(suggestions, TitleSuggestion{Title: title, Highlights: MatchOffsets(title, q.Text)})
+	}
+	return suggestions, nil
+}
+
+// Ping always succeeds; there is no database to reach
+func (r *MemoryEventRepository) Ping(ctx context.Context) error {
+	return nil
+}
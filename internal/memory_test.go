@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryEventRepositorySync(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2030, 3, 4, 9, 0, 0, 0, time.UTC)
+	standup := EventDB{ID: uuid.New(), Title: "Standup", StartTime: start, EndTime: start.Add(15 * time.Minute)}
+	retro := EventDB{ID: uuid.New(), Title: "Retro", StartTime: start.Add(time.Hour), EndTime: start.Add(2 * time.Hour)}
+	repo := NewMemoryEventRepository([]EventDB{standup, retro})
+
+	page, err := repo.PullChanges(ctx, SyncCursor{}, 1)
+	require.NoError(t, err)
+	require.Len(t, page.Events, 1)
+	assert.Equal(t, standup.ID, page.Events[0].ID)
+	assert.True(t, page.HasMore)
+
+	// A change based on an old version conflicts and returns the server copy
+	edited := standup
+	edited.Title = "Daily standup"
+	out, err := repo.ApplySyncChange(ctx, SyncChange{BaseVersion: 1, Event: edited})
+	require.NoError(t, err)
+	assert.Equal(t, SyncApplied, out.Status)
+	out, err = repo.ApplySyncChange(ctx, SyncChange{BaseVersion: 1, Event: standup})
+	require.NoError(t, err)
+	assert.Equal(t, SyncConflict, out.Status)
+	require.NotNil(t, out.Server)
+	assert.Equal(t, "Daily standup", out.Server.Title)
+
+	require.NoError(t, repo.DeleteEvent(ctx, retro.ID))
+	rest, err := repo.PullChanges(ctx, page.Next, 10)
+	require.NoError(t, err)
+	assert.False(t, rest.HasMore)
+	require.Len(t, rest.Events, 1)
+	assert.Equal(t, int64(3), rest.Events[0].Version)
+	require.Len(t, rest.Deleted, 1)
+	assert.Equal(t, retro.ID, rest.Deleted[0].ID)
+
+	// Deleted events cannot be revived by a create
+	out, err = repo.ApplySyncChange(ctx, SyncChange{Event: retro})
+	require.NoError(t, err)
+	assert.True(t, out.ServerDeleted)
+}
+
+func TestMemoryEventRepositoryReview(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2030, 3, 4, 9, 0, 0, 0, time.UTC)
+	meetup := EventDB{ID: uuid.New(), Title: "Meetup", StartTime: start, EndTime: start.Add(time.Hour), Status: EventStatusPending, SubmittedBy: "user-1"}
+	repo := NewMemoryEventRepository([]EventDB{meetup})
+
+	pending, err := repo.ListPendingEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+
+	// Updates without a status keep the review status
+	meetup.Title, meetup.Status = "Community meetup", ""
+	updated, err := repo.UpdateEvent(ctx, meetup)
+	require.NoError(t, err)
+	assert.Equal(t, EventStatusPending, updated.Status)
+
+	reviewed, err := repo.ReviewEvent(ctx, EventReview{EventID: meetup.ID, Decision: EventStatusApproved, Reviewer: "admin"})
+	require.NoError(t, err)
+	assert.Equal(t, EventStatusApproved, reviewed.Status)
+	_, err = repo.ReviewEvent(ctx, EventReview{EventID: meetup.ID, Decision: EventStatusRejected})
+	assert.ErrorIs(t, err, ErrEventNotPending)
+
+	reviews, err := repo.ListEventReviews(ctx, meetup.ID)
+	require.NoError(t, err)
+	require.Len(t, reviews, 1)
+	assert.Equal(t, "admin", reviews[0].Reviewer)
+}
+
+func TestMemoryEventRepositoryAggregates(t *testing.T) {
+	ctx := context.Background()
+	monday := time.Date(2030, 3, 4, 0, 0, 0, 0, time.UTC)
+	calendar := uuid.New()
+	repo := NewMemoryEventRepository([]EventDB{
+		{Title: "Team standup", StartTime: monday.Add(9 * time.Hour), EndTime: monday.Add(9*time.Hour + 15*time.Minute)},
+		{Title: "Team standup", StartTime: monday.Add(33 * time.Hour), EndTime: monday.Add(33*time.Hour + 15*time.Minute)},
+		{Title: "Standup notes", StartTime: monday.Add(34 * time.Hour), EndTime: monday.Add(35 * time.Hour), CalendarID: &calendar},
+		{Title: "Team secret", StartTime: monday.Add(10 * time.Hour), EndTime: monday.Add(11 * time.Hour), Status: EventStatusPending},
+	})
+
+	stats, err := repo.EventStats(ctx, EventStatsQuery{From: monday, To: monday.AddDate(0, 0, 7), Group: StatsGroupDay})
+	require.NoError(t, err)
+	assert.Equal(t, []EventStatsRow{{Key: "2030-03-04", Events: 1, Minutes: 15}, {Key: "2030-03-05", Events: 2, Minutes: 75}}, stats)
+	stats, err = repo.EventStats(ctx, EventStatsQuery{From: monday, To: monday.AddDate(0, 0, 7), Group: StatsGroupCalendar})
+	require.NoError(t, err)
+	assert.Equal(t, []EventStatsRow{{Key: "", Events: 2, Minutes: 30}, {Key: calendar.String(), Events: 1, Minutes: 60}}, stats)
+
+	buckets, err := repo.Occupancy(ctx, HeatmapQuery{From: monday, To: monday.AddDate(0, 0, 2), Bucket: HeatmapBucketDay, Location: time.UTC})
+	require.NoError(t, err)
+	require.Len(t, buckets, 2)
+	assert.Equal(t, 1, buckets[0].Count)
+	assert.Equal(t, 2, buckets[1].Count)
+
+	// Prefix matches come first; pending events are never suggested
+	suggestions, err := repo.SuggestTitles(ctx, SuggestQuery{Text: "stand", Limit: 5})
+	require.NoError(t, err)
+	var titles []string
+	for _, s := range suggestions {
+		titles = append(titles, s.Title)
+	}
+	assert.Equal(t, []string{"Standup notes", "Team standup"}, titles)
+}
+
+func TestMockEvents(t *testing.T) {
+	anchor := time.Date(2030, 3, 6, 15, 0, 0, 0, time.UTC)
+	events := MockEvents(1, anchor)
+	assert.Equal(t, events, MockEvents(1, anchor.Add(24*time.Hour)), "same seed and week")
+	assert.NotEqual(t, events[0].ID, MockEvents(2, anchor)[0].ID)
+
+	statuses := map[string]int{}
+	for _, e := range events {
+		statuses[e.Status]++
+		assert.True(t, e.EndTime.After(e.StartTime), e.Title)
+	}
+	assert.Equal(t, 1, statuses[EventStatusPending])
+	assert.Equal(t, 1, statuses[EventStatusRejected])
+	assert.Equal(t, time.Date(2030, 2, 11, 9, 30, 0, 0, time.UTC), events[0].StartTime)
+}
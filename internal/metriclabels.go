@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// Label values of requests without a tenant or calendar
+const (
+	MetricTenantAnonymous = "anonymous"
+	MetricCalendarNone    = "none"
+)
+
+// MetricLabels attribute a request, and the repository calls made to serve it, to a
+// tenant and a calendar. Both are empty unless tenant labels are enabled.
+type MetricLabels struct {
+	Tenant   string
+	Calendar string
+}
+
+// MetricLabeler bounds the cardinality of tenant and calendar labels: the IDs of the
+// allowlist, typically the largest customers, are labeled as themselves, and the others
+// are hashed into a fixed number of buckets, bucket_00 to bucket_<n-1>, or all labeled
+// "other" without buckets
+type MetricLabeler struct {
+	buckets uint32
+	allow   map[string]bool
+}
+
+// NewMetricLabeler labels IDs with buckets buckets and an allowlist of user and calendar
+// IDs, or returns nil, disabling the labels, when both are empty
+func NewMetricLabeler(buckets int, allowlist []string) *MetricLabeler {
+	if buckets <= 0 && len(allowlist) == 0 {
+		return nil
+	}
+	l := &MetricLabeler{allow: map[string]bool{}}
+	if buckets > 0 {
+		l.buckets = uint32(buckets)
+	}
+	for _, id := range allowlist {
+		l.allow[id] = true
+	}
+	return l
+}
+
+// Label returns the label value of id, or none when id is empty
+func (l *MetricLabeler) Label(id, none string) string {
+	switch {
+	case id == "":
+		return none
+	case l.allow[id]:
+		return id
+	case l.buckets == 0:
+		return "other"
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return fmt.Sprintf("bucket_%02d", h.Sum32()%l.buckets)
+}
+
+type metricScopeKey struct{}
+
+// MetricScope holds the tenant and calendar a request is attributed to. The metrics
+// middleware creates it before the caller is authenticated, so the middlewares and
+// handlers that learn them later fill it in.
+type MetricScope struct {
+	mu       sync.Mutex
+	tenant   string
+	calendar string
+}
+
+// WithMetricScope returns a context carrying a new, empty scope
+func WithMetricScope(ctx context.Context) (context.Context, *MetricScope) {
+	s := &MetricScope{}
+	return context.WithValue(ctx, metricScopeKey{}, s), s
+}
+
+// MetricScopeFromContext returns the scope of ctx, or nil; its methods do nothing on nil
+func MetricScopeFromContext(ctx context.Context) *MetricScope {
+	s, _ := ctx.Value(metricScopeKey{}).(*MetricScope)
+	return s
+}
+
+// SetTenant attributes the request to the user id
+func (s *MetricScope) SetTenant(id string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tenant = id
+}
+
+// SetCalendar attributes the request to the calendar id
+func (s *MetricScope) SetCalendar(id string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calendar = id
+}
+
+func (s *MetricScope) ids() (tenant, calendar string) {
+	if s == nil {
+		return "", ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tenant, s.calendar
+}
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricLabeler(t *testing.T) {
+	assert.Nil(t, NewMetricLabeler(0, nil), "labels are disabled by default")
+
+	l := NewMetricLabeler(16, []string{"acme"})
+	assert.Equal(t, "acme", l.Label("acme", MetricTenantAnonymous))
+	assert.Equal(t, MetricTenantAnonymous, l.Label("", MetricTenantAnonymous))
+	bucket := l.Label("user-1", MetricTenantAnonymous)
+	assert.Regexp(t, `^bucket_(0\d|1[0-5])$`, bucket)
+	assert.Equal(t, bucket, l.Label("user-1", MetricTenantAnonymous), "IDs always hash to the same bucket")
+
+	assert.Equal(t, "other", NewMetricLabeler(0, []string{"acme"}).Label("user-1", MetricTenantAnonymous))
+}
+
+func TestMetricsTenantLabels(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.SetLabeler(NewMetricLabeler(4, []string{"acme"}))
+
+	ctx, scope := WithMetricScope(WithRequestID(context.Background(), "req-1"))
+	scope.SetTenant("acme")
+	metrics.ObserveRequest(ctx, "GET", "/events", 200, 20*time.Millisecond)
+	metrics.ObserveRepositoryCall(ctx, "GetEvents", ResultOK, 5*time.Millisecond)
+	metrics.ObserveRequest(context.Background(), "GET", "/events", 200, 10*time.Millisecond)
+
+	var buf bytes.Buffer
+	require.NoError(t, metrics.WritePrometheus(&buf))
+	out := buf.String()
+	assert.Contains(t, out, `http_requests_total{method="GET",route="/events",status="200",tenant="acme",calendar="none"} 1`+"\n")
+	assert.Contains(t, out, `http_requests_total{method="GET",route="/events",status="200",tenant="anonymous",calendar="none"} 1`+"\n")
+	assert.Contains(t, out, `repository_calls_total{method="GetEvents",result="ok",tenant="acme",calendar="none"} 1`+"\n")
+	assert.NotContains(t, out, "trace_id", "the Prometheus format has no exemplars")
+}
+
+func TestMetricsExemplars(t *testing.T) {
+	metrics := NewMetrics()
+	observe := func(traceID string, d time.Duration) {
+		ctx := WithRequestID(context.Background(), "req-"+traceID)
+		if traceID != "" {
+			ctx = WithTraceID(ctx, traceID)
+		}
+		metrics.ObserveRequest(ctx, "GET", "/events", 200, d)
+	}
+	observe("slow", 900*time.Millisecond)
+	observe("fast", 10*time.Millisecond)
+
+	var buf bytes.Buffer
+	require.NoError(t, metrics.WriteOpenMetrics(&buf))
+	out := buf.String()
+	assert.Contains(t, out, "# TYPE http_requests counter\n")
+	assert.Regexp(t, `http_requests_total\{method="GET",route="/events",status="200"\} 2 # \{trace_id="slow"\} 0\.9 \d+\.\d{3}\n`, out, "the slowest request is the exemplar")
+	assert.Contains(t, out, "# TYPE http_request_duration_seconds_sum unknown\n")
+	assert.True(t, bytes.HasSuffix(buf.Bytes(), []byte("# EOF\n")))
+
+	// Once old, any request replaces the exemplar
+	metrics.mu.Lock()
+	metrics.requests[requestKey{Method: "GET", Route: "/events", Status: 200}].Exemplar.Time = time.Now().Add(-2 * exemplarTTL)
+	metrics.mu.Unlock()
+	observe("", 5*time.Millisecond)
+	buf.Reset()
+	require.NoError(t, metrics.WriteOpenMetrics(&buf))
+	assert.Contains(t, buf.String(), `# {trace_id="req-"} 0.005`, "without a trace, the request ID links to the logs")
+}
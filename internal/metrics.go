@@ -0,0 +1,312 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// exemplarTTL is how long the exemplar of a series is kept unless a slower request
+// replaces it, so exemplars point at the latency spikes of the last minute
+const exemplarTTL = time.Minute
+
+// maxExemplarID bounds the IDs of exemplars, whose labels OpenMetrics limits to 128
+// characters
+const maxExemplarID = 120
+
+// requestKey groups requests by method, route template, status and, when enabled,
+// tenant and calendar
+type requestKey struct {
+	Method string
+	Route  string
+	Status int
+	MetricLabels
+}
+
+// exemplar links a series to one of the requests it counts
+type exemplar struct {
+	TraceID string
+	Value   time.Duration
+	Time    time.Time
+}
+
+type requestStats struct {
+	Count    int64
+	Duration time.Duration
+	Exemplar exemplar
+}
+
+// add counts a request or call of ctx. It becomes the exemplar when it is the slowest
+// since the exemplar was recorded, or the exemplar is older than exemplarTTL.
+func (s *requestStats) add(ctx context.Context, d time.Duration, now time.Time) {
+	s.Count++
+	s.Duration += d
+	id := TraceIDFromContext(ctx)
+	if id == "" {
+		id = RequestIDFromContext(ctx)
+	}
+	if id == "" || len(id) > maxExemplarID {
+		return
+	}
+	if s.Exemplar.TraceID == "" || d >= s.Exemplar.Value || now.Sub(s.Exemplar.Time) > exemplarTTL {
+		s.Exemplar = exemplar{TraceID: id, Value: d, Time: now}
+	}
+}
+
+// repositoryKey groups repository calls by method, result class and, when enabled,
+// tenant and calendar
+type repositoryKey struct {
+	Method string
+	Result string
+	MetricLabels
+}
+
+// MetricsSink receives every observation Metrics records, to push it to another
+// monitoring system
+type MetricsSink interface {
+	ObserveRequest(method, route string, status int, labels MetricLabels, d time.Duration)
+	ObserveRepositoryCall(method, result string, labels MetricLabels, d time.Duration)
+}
+
+// Metrics collects HTTP request counters in memory and renders them in the
+// Prometheus text exposition format
+type Metrics struct {
+	started  time.Time
+	inFlight atomic.Int64
+
+	mu           sync.Mutex
+	requests     map[requestKey]*requestStats
+	repositories map[repositoryKey]*requestStats
+	sinks        []MetricsSink
+	labeler      *MetricLabeler
+}
+
+// NewMetrics creates an empty metrics registry
+func NewMetrics() *Metrics {
+	return &Metrics{started: time.Now(), requests: map[requestKey]*requestStats{}, repositories: map[repositoryKey]*requestStats{}}
+}
+
+// RequestStarted marks a request as in flight; call the returned func when it ends
+func (m *Metrics) RequestStarted() func() {
+	m.inFlight.Add(1)
+	return func() { m.inFlight.Add(-1) }
+}
+
+// InFlight returns how many requests are being served
+func (m *Metrics) InFlight() int64 {
+	return m.inFlight.Load()
+}
+
+// AddSink sends the observations recorded from now on to sink as well. Sinks are called
+// with the registry locked, so they must not block.
+func (m *Metrics) AddSink(sink MetricsSink) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sinks = append(m.sinks, sink)
+}
+
+// SetLabeler labels requests and repository calls with the tenant and calendar of their
+// MetricScope, as labeled by l; nil removes the labels
+func (m *Metrics) SetLabeler(l *MetricLabeler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.labeler = l
+}
+
+// labels returns the labels of the request of ctx; call with m.mu held
+func (m *Metrics) labels(ctx context.Context) MetricLabels {
+	if m.labeler == nil {
+		return MetricLabels{}
+	}
+	tenant, calendar := MetricScopeFromContext(ctx).ids()
+	return MetricLabels{Tenant: m.labeler.Label(tenant, MetricTenantAnonymous), Calendar: m.labeler.Label(calendar, MetricCalendarNone)}
+}
+
+// ObserveRequest records a finished request of ctx
+func (m *Metrics) ObserveRequest(ctx context.Context, method, route string, status int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	labels := m.labels(ctx)
+	key := requestKey{Method: method, Route: route, Status: status, MetricLabels: labels}
+	stats, ok := m.requests[key]
+	if !ok {
+		stats = &requestStats{}
+		m.requests[key] = stats
+	}
+	stats.add(ctx, d, time.Now())
+	for _, sink := range m.sinks {
+		sink.ObserveRequest(method, route, status, labels, d)
+	}
+}
+
+// ObserveRepositoryCall records a finished repository call of ctx; result is a class
+// from ClassifyError, or "coalesced" for calls that shared another's read
+func (m *Metrics) ObserveRepositoryCall(ctx context.Context, method, result string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	labels := m.labels(ctx)
+	key := repositoryKey{Method: method, Result: result, MetricLabels: labels}
+	stats, ok := m.repositories[key]
+	if !ok {
+		stats = &requestStats{}
+		m.repositories[key] = stats
+	}
+	stats.add(ctx, d, time.Now())
+	for _, sink := range m.sinks {
+		sink.ObserveRepositoryCall(method, result, labels, d)
+	}
+}
+
+// String renders the labels for a series, empty without tenant labels
+func (l MetricLabels) String() string {
+	if l.Tenant == "" && l.Calendar == "" {
+		return ""
+	}
+	return fmt.Sprintf(",tenant=%q,calendar=%q", l.Tenant, l.Calendar)
+}
+
+// WritePrometheus writes every metric to w in the Prometheus text format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	return m.write(w, false)
+}
+
+// WriteOpenMetrics writes every metric to w in the OpenMetrics text format, which
+// Prometheus asks for to scrape exemplars: each request and repository call counter
+// carries the trace ID, or request ID, of its slowest recent request
+func (m *Metrics) WriteOpenMetrics(w io.Writer) error {
+	return m.write(w, true)
+}
+
+func (m *Metrics) write(w io.Writer, openMetrics bool) error {
+	m.mu.Lock()
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	snapshot := make(map[requestKey]requestStats, len(keys))
+	for _, k := range keys {
+		snapshot[k] = *m.requests[k]
+	}
+	repoKeys := make([]repositoryKey, 0, len(m.repositories))
+	repoSnapshot := make(map[repositoryKey]requestStats, len(m.repositories))
+	for k, stats := range m.repositories {
+		repoKeys = append(repoKeys, k)
+		repoSnapshot[k] = *stats
+	}
+	m.mu.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Route != keys[j].Route {
+			return keys[i].Route < keys[j].Route
+		}
+		if keys[i].Method != keys[j].Method {
+			return keys[i].Method < keys[j].Method
+		}
+		if keys[i].Status != keys[j].Status {
+			return keys[i].Status < keys[j].Status
+		}
+		return keys[i].MetricLabels.String() < keys[j].MetricLabels.String()
+	})
+
+	// OpenMetrics names counter families without their _total suffix, and has no
+	// counter type for sums, which stay untyped there under the same name
+	counter := func(buf *bytes.Buffer, name, help string) {
+		if openMetrics {
+			fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s counter\n", strings.TrimSuffix(name, "_total"), help, strings.TrimSuffix(name, "_total"))
+			return
+		}
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	}
+	sum := func(buf *bytes.Buffer, name, help string) {
+		kind := "counter"
+		if openMetrics {
+			kind = "unknown"
+		}
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	example := func(buf *bytes.Buffer, e exemplar) {
+		if openMetrics && e.TraceID != "" {
+			fmt.Fprintf(buf, " # {trace_id=%q} %g %.3f", e.TraceID, e.Value.Seconds(), float64(e.Time.UnixMilli())/1000)
+		}
+		buf.WriteByte('\n')
+	}
+
+	var buf bytes.Buffer
+	counter(&buf, "http_requests_total", "Completed HTTP requests.")
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "http_requests_total{method=%q,route=%q,status=\"%d\"%s} %d", k.Method, k.Route, k.Status, k.MetricLabels, snapshot[k].Count)
+		example(&buf, snapshot[k].Exemplar)
+	}
+	sum(&buf, "http_request_duration_seconds_sum", "Total time spent serving HTTP requests.")
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "http_request_duration_seconds_sum{method=%q,route=%q,status=\"%d\"%s} %g\n", k.Method, k.Route, k.Status, k.MetricLabels, snapshot[k].Duration.Seconds())
+	}
+	sort.Slice(repoKeys, func(i, j int) bool {
+		if repoKeys[i].Method != repoKeys[j].Method {
+			return repoKeys[i].Method < repoKeys[j].Method
+		}
+		if repoKeys[i].Result != repoKeys[j].Result {
+			return repoKeys[i].Result < repoKeys[j].Result
+		}
+		return repoKeys[i].MetricLabels.String() < repoKeys[j].MetricLabels.String()
+	})
+	counter(&buf, "repository_calls_total", "Completed repository calls.")
+	for _, k := range repoKeys {
+		fmt.Fprintf(&buf, "repository_calls_total{method=%q,result=%q%s} %d", k.Method, k.Result, k.MetricLabels, repoSnapshot[k].Count)
+		example(&buf, repoSnapshot[k].Exemplar)
+	}
+	sum(&buf, "repository_call_duration_seconds_sum", "Total time spent in repository calls.")
+	for _, k := range repoKeys {
+		fmt.Fprintf(&buf, "repository_call_duration_seconds_sum{method=%q,result=%q%s} %g\n", k.Method, k.Result, k.MetricLabels, repoSnapshot[k].Duration.Seconds())
+	}
+	buf.WriteString("# HELP http_requests_in_flight Requests currently being served.\n# TYPE http_requests_in_flight gauge\n")
+	fmt.Fprintf(&buf, "http_requests_in_flight %d\n", m.InFlight())
+	buf.WriteString("# HELP process_uptime_seconds Time since the server started.\n# TYPE process_uptime_seconds gauge\n")
+	fmt.Fprintf(&buf, "process_uptime_seconds %g\n", time.Since(m.started).Seconds())
+	if openMetrics {
+		buf.WriteString("# EOF\n")
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Flush publishes the final values before the process exits. With pushURL set (a
+// Prometheus Pushgateway job URL) they are pushed there, otherwise they are logged,
+// so requests served after the last scrape are not lost.
+func (m *Metrics) Flush(ctx context.Context, pushURL string) error {
+	var buf bytes.Buffer
+	if err := m.WritePrometheus(&buf); err != nil {
+		return err
+	}
+
+	if pushURL == "" {
+		log.Printf("Final metrics:\n%s", buf.String())
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, pushURL, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("metrics push returned %s", resp.Status)
+	}
+	return nil
+}
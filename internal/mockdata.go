@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MockWeeks is how many weeks of events MockEvents generates, before and after the
+// anchor week together
+const MockWeeks = 6
+
+// mockTitles are the titles of the generated meetings, with their usual length
+var mockTitles = []struct {
+	title   string
+	minutes int
+}{
+	{"Team standup", 15},
+	{"Sprint planning", 90},
+	{"Design review", 60},
+	{"1:1 with manager", 30},
+	{"Customer demo", 45},
+	{"Architecture sync", 60},
+	{"Lunch & learn", 60},
+	{"Retrospective", 60},
+	{"Hiring interview", 45},
+	{"Release go/no-go", 30},
+}
+
+var mockLocations = []struct {
+	name     string
+	lat, lon float64
+}{
+	{"Room Ada", 40.4168, -3.7038},
+	{"Room Grace", 40.4169, -3.7041},
+	{"Auditorium", 40.4172, -3.7035},
+	{"https://meet.example.com/team", 0, 0},
+}
+
+// MockEvents generates a deterministic set of events around the week of anchor, in UTC:
+// daily standups, meetings on weekday working hours, a multi-day offsite, a ticketed
+// workshop, and events pending review or rejected. The same seed and anchor week give
+// the same events, IDs included, so screenshots and frontend tests are stable.
+func MockEvents(seed int64, anchor time.Time) []EventDB {
+	rng := rand.New(rand.NewSource(seed))
+	newID := func() uuid.UUID {
+		id, _ := uuid.NewRandomFromReader(rng)
+		return id
+	}
+	monday := heatmapFloor(anchor, HeatmapBucketWeek, time.UTC)
+	first := monday.AddDate(0, 0, -7*(MockWeeks/2))
+	// Everything exists since a week before the first event
+	created := first.AddDate(0, 0, -7)
+	event := func(title string, start time.Time, minutes int) EventDB {
+		return EventDB{
+			ID: newID(), Title: title, DescriptionFormat: DescriptionFormatPlain,
+			StartTime: start, EndTime: start.Add(time.Duration(minutes) * time.Minute),
+			CreatedAt: created, UpdatedAt: created, Status: EventStatusApproved,
+		}
+	}
+
+	var events []EventDB
+	for day := first; day.Before(first.AddDate(0, 0, 7*MockWeeks)); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		events = append(events, event(mockTitles[0].title, day.Add(9*time.Hour+30*time.Minute), mockTitles[0].minutes))
+
+		// Up to three more meetings a day, on the hour or half hour from 10:00 to 17:30
+		for n := rng.Intn(4); n > 0; n-- {
+			m := mockTitles[1+rng.Intn(len(mockTitles)-1)]
+			e := event(m.title, day.Add(10*time.Hour+time.Duration(rng.Intn(16))*30*time.Minute), m.minutes)
+			if rng.Intn(3) == 0 {
+				description := "Agenda:\n\n- Updates\n- **Decisions**\n- Next steps"
+				e.Description, e.DescriptionFormat = &description, DescriptionFormatMarkdown
+			}
+			if l := mockLocations[rng.Intn(len(mockLocations))]; l.lat != 0 {
+				e.Location, e.Latitude, e.Longitude = &l.name, &l.lat, &l.lon
+			} else {
+				e.Location = &l.name
+			}
+			events = append(events, e)
+		}
+	}
+
+	offsite := event("Team offsite", monday.AddDate(0, 0, 9), 2*24*60)
+	location := "Sierra de Guadarrama"
+	offsite.Location = &location
+	events = append(events, offsite)
+
+	workshop := event("Go performance workshop", monday.AddDate(0, 0, 3).Add(15*time.Hour), 180)
+	price, currency, quota := int64(2500), "EUR", 30
+	workshop.PriceCents, workshop.Currency, workshop.TicketQuota = &price, &currency, &quota
+	events = append(events, workshop)
+
+	pending := event("Community meetup", monday.AddDate(0, 0, 11).Add(18*time.Hour), 120)
+	pending.Status, pending.SubmittedBy = EventStatusPending, "user-1"
+	events = append(events, pending)
+
+	rejected := event("Crypto giveaway", monday.AddDate(0, 0, 4).Add(20*time.Hour), 60)
+	rejected.Status, rejected.SubmittedBy = EventStatusRejected, "user-2"
+	events = append(events, rejected)
+
+	return events
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// parseMTLSIdentities parses "name=scope+scope;name=scope" into scopes keyed by the
+// certificate identity (subject CN, DNS SAN or URI SAN such as a SPIFFE ID)
+func parseMTLSIdentities(spec string) map[string][]string {
+	identities := map[string][]string{}
+	for _, entry := range strings.Split(spec, ";") {
+		name, scopes, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name == "" || scopes == "" {
+			continue
+		}
+		identities[name] = strings.Split(scopes, "+")
+	}
+	return identities
+}
+
+// CertificateIdentity matches a verified client certificate against the configured
+// identities, checking URI SANs, then DNS SANs, then the subject common name
+func CertificateIdentity(cert *x509.Certificate, identities map[string][]string) (string, []string, bool) {
+	var candidates []string
+	for _, u := range cert.URIs {
+		candidates = append(candidates, u.String())
+	}
+	candidates = append(candidates, cert.DNSNames...)
+	if cert.Subject.CommonName != "" {
+		candidates = append(candidates, cert.Subject.CommonName)
+	}
+
+	for _, name := range candidates {
+		if scopes, ok := identities[name]; ok {
+			return name, scopes, true
+		}
+	}
+	return "", nil, false
+}
+
+// ServerTLSConfig builds the TLS configuration for the HTTP server, or returns nil
+// when TLS is not configured. With a client CA bundle, client certificates are
+// requested and verified against it.
+func ServerTLSConfig(cfg Config) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" {
+		if cfg.TLSClientCAFile != "" {
+			return nil, errors.New("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.TLSClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(cfg.TLSClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("client CA bundle contains no certificates")
+	}
+	tlsConfig.ClientCAs = pool
+
+	switch cfg.TLSClientAuth {
+	case "require":
+		// Every caller must present a valid certificate
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		// Certificates are optional so bearer-token clients can share the listener,
+		// but any certificate presented must chain to the CA
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMTLSIdentities(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		want map[string][]string
+	}{
+		{name: "empty", spec: "", want: map[string][]string{}},
+		{name: "single", spec: "billing=events:read", want: map[string][]string{"billing": {"events:read"}}},
+		{
+			name: "several scopes and identities",
+			spec: "billing=events:read+events:write; spiffe://prod/sync=events:read",
+			want: map[string][]string{"billing": {"events:read", "events:write"}, "spiffe://prod/sync": {"events:read"}},
+		},
+		{name: "missing equals", spec: "billing;sync=events:read", want: map[string][]string{"sync": {"events:read"}}},
+		{name: "missing name", spec: "=events:read", want: map[string][]string{}},
+		{name: "missing scopes", spec: "billing=", want: map[string][]string{}},
+		{name: "empty entries", spec: ";;billing=events:read;", want: map[string][]string{"billing": {"events:read"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseMTLSIdentities(tt.spec))
+		})
+	}
+}
+
+func TestCertificateIdentity(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://prod/billing")
+	identities := map[string][]string{
+		"spiffe://prod/billing": {"events:write"},
+		"sync.internal":         {"events:read"},
+		"reporting":             {"events:read"},
+	}
+
+	tests := []struct {
+		name       string
+		cert       *x509.Certificate
+		wantName   string
+		wantScopes []string
+		wantOK     bool
+	}{
+		{name: "common name", cert: &x509.Certificate{Subject: pkix.Name{CommonName: "reporting"}}, wantName: "reporting", wantScopes: []string{"events:read"}, wantOK: true},
+		{name: "DNS SAN", cert: &x509.Certificate{DNSNames: []string{"other", "sync.internal"}}, wantName: "sync.internal", wantScopes: []string{"events:read"}, wantOK: true},
+		{name: "URI SAN", cert: &x509.Certificate{URIs: []*url.URL{spiffe}}, wantName: "spiffe://prod/billing", wantScopes: []string{"events:write"}, wantOK: true},
+		{
+			name:       "URI SAN wins over DNS SAN and common name",
+			cert:       &x509.Certificate{URIs: []*url.URL{spiffe}, DNSNames: []string{"sync.internal"}, Subject: pkix.Name{CommonName: "reporting"}},
+			wantName:   "spiffe://prod/billing",
+			wantScopes: []string{"events:write"},
+			wantOK:     true,
+		},
+		{name: "unmapped", cert: &x509.Certificate{Subject: pkix.Name{CommonName: "intruder"}, DNSNames: []string{"intruder.example"}}},
+		{name: "no identity", cert: &x509.Certificate{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, scopes, ok := CertificateIdentity(tt.cert, identities)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantName, name)
+			assert.Equal(t, tt.wantScopes, scopes)
+		})
+	}
+}
+
+// writeTestCA writes a self-signed CA certificate as PEM and returns its path
+func writeTestCA(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	return path
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	ca := writeTestCA(t)
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(empty, []byte("not a certificate"), 0o600))
+
+	tests := []struct {
+		name           string
+		cfg            Config
+		wantNil        bool
+		wantErr        string
+		wantClientAuth tls.ClientAuthType
+	}{
+		{name: "TLS not configured", cfg: Config{}, wantNil: true},
+		{name: "client CA without server certificate", cfg: Config{TLSClientCAFile: ca}, wantErr: "TLS_CLIENT_CA_FILE requires"},
+		{name: "server certificate only", cfg: Config{TLSCertFile: "cert.pem"}, wantClientAuth: tls.NoClientCert},
+		{name: "unreadable CA bundle", cfg: Config{TLSCertFile: "cert.pem", TLSClientCAFile: filepath.Join(t.TempDir(), "missing.pem")}, wantErr: "failed to read client CA bundle"},
+		{name: "CA bundle without certificates", cfg: Config{TLSCertFile: "cert.pem", TLSClientCAFile: empty}, wantErr: "contains no certificates"},
+		{name: "optional client certificates", cfg: Config{TLSCertFile: "cert.pem", TLSClientCAFile: ca, TLSClientAuth: "optional"}, wantClientAuth: tls.VerifyClientCertIfGiven},
+		{name: "required client certificates", cfg: Config{TLSCertFile: "cert.pem", TLSClientCAFile: ca, TLSClientAuth: "require"}, wantClientAuth: tls.RequireAndVerifyClientCert},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := ServerTLSConfig(tt.cfg)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if tt.wantNil {
+				assert.Nil(t, tlsConfig)
+				return
+			}
+			require.NotNil(t, tlsConfig)
+			assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+			assert.Equal(t, tt.wantClientAuth, tlsConfig.ClientAuth)
+			assert.Equal(t, tt.cfg.TLSClientCAFile != "", tlsConfig.ClientCAs != nil)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Notification is a message for one recipient. HTML is optional.
+type Notification struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Notifier delivers notifications to users
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// NewNotifier returns an SMTP notifier when SMTP_HOST is set, otherwise one that
+// only logs messages, which is enough for local development
+func NewNotifier(cfg Config) Notifier {
+	if cfg.SMTPHost == "" {
+		return LogNotifier{}
+	}
+	return &SMTPNotifier{
+		Addr:     net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+	}
+}
+
+// LogNotifier writes notifications to the log instead of sending them
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, n Notification) error {
+	log.Printf("Notification to %s: %s\n%s", n.To, LogPayload(n.Subject), LogPayload(n.Text))
+	return nil
+}
+
+// SMTPNotifier sends notifications as email. net/smtp upgrades to STARTTLS when the
+// server offers it and refuses to send credentials over an unencrypted connection.
+type SMTPNotifier struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTPNotifier) Notify(ctx context.Context, n Notification) error {
+	if _, err := mail.ParseAddress(n.To); err != nil {
+		return fmt.Errorf("invalid recipient %q: %w", n.To, err)
+	}
+	msg, err := buildEmail(s.From, n)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, _ := net.SplitHostPort(s.Addr)
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	// net/smtp has no context support, so honor cancellation by abandoning the send
+	done := make(chan error, 1)
+	go func() { done <- smtp.SendMail(s.Addr, auth, s.From, []string{n.To}, msg) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to send email: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// buildEmail renders a MIME message, multipart/alternative when there is an HTML part
+func buildEmail(from string, n Notification) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+
+	header("From", from)
+	header("To", n.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", n.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	if n.HTML == "" {
+		header("Content-Type", `text/plain; charset="utf-8"`)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		buf.WriteString(quotedPrintable(n.Text))
+		return buf.Bytes(), nil
+	}
+
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate MIME boundary: %w", err)
+	}
+	boundary := "b" + hex.EncodeToString(b)
+	header("Content-Type", `multipart/alternative; boundary="`+boundary+`"`)
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", n.Text},
+		{"text/html", n.HTML},
+	} {
+		fmt.Fprintf(&buf, "--%s\r\nContent-Type: %s; charset=\"utf-8\"\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n%s\r\n",
+			boundary, part.contentType, quotedPrintable(part.body))
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+	return buf.Bytes(), nil
+}
+
+// quotedPrintable encodes a body so non-ASCII text and long lines survive any relay
+func quotedPrintable(s string) string {
+	var buf bytes.Buffer
+	w := quotedprintable.NewWriter(&buf)
+	w.Write([]byte(strings.ReplaceAll(s, "\r\n", "\n")))
+	w.Close()
+	return buf.String()
+}
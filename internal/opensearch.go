@@ -0,0 +1,381 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// openSearchMapping maps the fields of the indexed events; the title weighs most
+const openSearchMapping = `{"mappings":{"properties":{
+	"title":{"type":"text"},
+	"description":{"type":"text"},
+	"location":{"type":"text"},
+	"calendar_id":{"type":"keyword"},
+	"start_time":{"type":"date"},
+	"version":{"type":"long"}}}}`
+
+// openSearchDoc is the indexed part of a published event
+type openSearchDoc struct {
+	Title       string     `json:"title"`
+	Description *string    `json:"description,omitempty"`
+	Location    *string    `json:"location,omitempty"`
+	CalendarID  *uuid.UUID `json:"calendar_id,omitempty"`
+	StartTime   time.Time  `json:"start_time"`
+	Version     int64      `json:"version"`
+}
+
+// OpenSearchIndex is an OpenSearch or Elasticsearch index of the text of the published
+// events. Documents carry the events' versions as external versions, so a stale write
+// never replaces a newer one, whatever order changes arrive in.
+type OpenSearchIndex struct {
+	client   *http.Client
+	baseURL  string
+	index    string
+	user     string
+	password string
+	// creds sign requests for Amazon OpenSearch Service when set
+	creds *awsCredentials
+}
+
+// NewOpenSearchIndex connects to the index cfg.OpenSearchIndex at cfg.OpenSearchURL, or
+// returns nil when no URL is configured. Credentials in the URL are sent as basic
+// authentication.
+func NewOpenSearchIndex(cfg Config) (*OpenSearchIndex, error) {
+	if cfg.OpenSearchURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(cfg.OpenSearchURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("OPENSEARCH_URL must be an http or https URL")
+	}
+	idx := &OpenSearchIndex{client: &http.Client{Timeout: 10 * time.Second}, index: cfg.OpenSearchIndex}
+	if u.User != nil {
+		idx.user = u.User.Username()
+		idx.password, _ = u.User.Password()
+		u.User = nil
+	}
+	idx.baseURL = strings.TrimSuffix(u.String(), "/")
+	if cfg.OpenSearchSigV4 {
+		creds, err := awsCredentialsFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("signing OpenSearch requests: %w", err)
+		}
+		idx.creds = &creds
+	}
+	return idx, nil
+}
+
+// do sends a request to the cluster, returning the body of 2xx answers and an error
+// naming the status otherwise
+func (idx *OpenSearchIndex) do(ctx context.Context, method, path string, body []byte, contentType string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, idx.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if idx.user != "" {
+		req.SetBasicAuth(idx.user, idx.password)
+	}
+	SetRequestIDHeader(req)
+	if idx.creds != nil {
+		signAWSRequest(req, body, *idx.creds, "es", time.Now())
+	}
+	resp, err := idx.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg := respBody[:min(len(respBody), 512)]
+		return respBody, resp.StatusCode, fmt.Errorf("%s %s answered %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return respBody, resp.StatusCode, nil
+}
+
+// EnsureIndex creates the index with its mapping unless it exists
+func (idx *OpenSearchIndex) EnsureIndex(ctx context.Context) error {
+	path := "/" + url.PathEscape(idx.index)
+	_, status, err := idx.do(ctx, http.MethodHead, path, nil, "")
+	if err == nil {
+		return nil
+	}
+	if status != http.StatusNotFound {
+		return err
+	}
+	body, _, err := idx.do(ctx, http.MethodPut, path, []byte(openSearchMapping), "application/json")
+	if err != nil && !bytes.Contains(body, []byte("resource_already_exists_exception")) {
+		return err
+	}
+	return nil
+}
+
+// LatestVersion returns the highest event version in the index, 0 when it is empty.
+// Every change up to it has been indexed, apart from deletions, which indexing again
+// repeats harmlessly.
+func (idx *OpenSearchIndex) LatestVersion(ctx context.Context) (int64, error) {
+	body, _, err := idx.do(ctx, http.MethodPost, "/"+url.PathEscape(idx.index)+"/_search",
+		[]byte(`{"size":0,"aggs":{"latest":{"max":{"field":"version"}}}}`), "application/json")
+	if err != nil {
+		return 0, err
+	}
+	var resp struct {
+		Aggregations struct {
+			Latest struct {
+				Value *float64 `json:"value"`
+			} `json:"latest"`
+		} `json:"aggregations"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, fmt.Errorf("failed to decode latest version: %w", err)
+	}
+	if resp.Aggregations.Latest.Value == nil {
+		return 0, nil
+	}
+	return int64(*resp.Aggregations.Latest.Value), nil
+}
+
+// Bulk indexes the published events of a page of changes and removes the others, along
+// with the deleted events
+func (idx *OpenSearchIndex) Bulk(ctx context.Context, events []EventDB, deleted []EventTombstone) error {
+	if len(events) == 0 && len(deleted) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	action := func(op string, id uuid.UUID, version int64) {
+		enc.Encode(map[string]any{op: map[string]any{"_index": idx.index, "_id": id, "version": version, "version_type": "external"}})
+	}
+	for _, e := range events {
+		if !e.Published() {
+			action("delete", e.ID, e.Version)
+			continue
+		}
+		action("index", e.ID, e.Version)
+		if err := enc.Encode(openSearchDoc{Title: e.Title, Description: e.Description, Location: e.Location,
+			CalendarID: e.CalendarID, StartTime: e.StartTime, Version: e.Version}); err != nil {
+			return err
+		}
+	}
+	for _, t := range deleted {
+		action("delete", t.ID, t.Version)
+	}
+
+	body, _, err := idx.do(ctx, http.MethodPost, "/_bulk", buf.Bytes(), "application/x-ndjson")
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Errors bool                                  `json:"errors"`
+		Items  []map[string]openSearchBulkItemResult `json:"items"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("failed to decode bulk response: %w", err)
+	}
+	if !resp.Errors {
+		return nil
+	}
+	for _, item := range resp.Items {
+		for op, res := range item {
+			// A conflict means a newer version is indexed, and a missing document was
+			// never indexed or is already deleted
+			if res.Status/100 == 2 || res.Status == http.StatusConflict || (op == "delete" && res.Status == http.StatusNotFound) {
+				continue
+			}
+			return fmt.Errorf("failed to %s event %s: %s", op, res.ID, res.Error)
+		}
+	}
+	return nil
+}
+
+// openSearchBulkItemResult is the outcome of one action of a bulk request
+type openSearchBulkItemResult struct {
+	ID     string          `json:"_id"`
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// Search returns the IDs of the indexed events best matching q, tolerating typos
+func (idx *OpenSearchIndex) Search(ctx context.Context, q SearchQuery) ([]uuid.UUID, error) {
+	filter := []any{}
+	if q.CalendarID != nil {
+		filter = append(filter, map[string]any{"term": map[string]any{"calendar_id": q.CalendarID.String()}})
+	}
+	query, err := json.Marshal(map[string]any{
+		"size":    q.Limit,
+		"_source": false,
+		"query": map[string]any{"bool": map[string]any{
+			"must": map[string]any{"multi_match": map[string]any{
+				"query":     q.Text,
+				"fields":    []string{"title^3", "description", "location"},
+				"fuzziness": "AUTO",
+				"operator":  "and",
+			}},
+			"filter": filter,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	body, _, err := idx.do(ctx, http.MethodPost, "/"+url.PathEscape(idx.index)+"/_search", query, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Hits struct {
+			Hits []struct {
+				ID string `json:"_id"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode search response: %w", err)
+	}
+	ids := make([]uuid.UUID, 0, len(resp.Hits.Hits))
+	for _, hit := range resp.Hits.Hits {
+		id, err := uuid.Parse(hit.ID)
+		if err != nil {
+			return nil, fmt.Errorf("index holds a document with ID %q: %w", hit.ID, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// SearchStore is the database searches fall back to and read the matched events from
+type SearchStore interface {
+	EventSearcher
+	EventSummariesByID(ctx context.Context, ids []uuid.UUID) ([]EventSummary, error)
+}
+
+// OpenSearchSearcher searches the OpenSearch index, then reads the matched events from
+// the database, which drops those unpublished or moved to a busy calendar since they
+// were indexed. When the cluster fails, it searches the database instead.
+type OpenSearchSearcher struct {
+	index *OpenSearchIndex
+	store SearchStore
+}
+
+// NewOpenSearchSearcher searches index, reading events from and falling back to store
+func NewOpenSearchSearcher(index *OpenSearchIndex, store SearchStore) *OpenSearchSearcher {
+	return &OpenSearchSearcher{index: index, store: store}
+}
+
+func (s *OpenSearchSearcher) SearchEvents(ctx context.Context, q SearchQuery) ([]EventSummary, error) {
+	ids, err := s.index.Search(ctx, q)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Printf("Error searching OpenSearch, searching the database instead: %v", err)
+		return s.store.SearchEvents(ctx, q)
+	}
+	return s.store.EventSummariesByID(ctx, ids)
+}
+
+// SearchIndexer keeps an OpenSearch index in sync with the events by tailing the sync
+// change feed, the stream /sync/changes serves. It resumes after the latest version
+// indexed, so a restart neither loses changes nor indexes everything again, and several
+// instances may run it at once.
+type SearchIndexer struct {
+	index  *OpenSearchIndex
+	events EventRepositoryInterface
+	// Interval is how often the feed is polled when no write wakes the indexer
+	Interval time.Duration
+	// BatchSize is how many changes are pulled and indexed at once
+	BatchSize int
+	wake      chan struct{}
+}
+
+// NewSearchIndexer indexes the changes of events into index
+func NewSearchIndexer(index *OpenSearchIndex, events EventRepositoryInterface) *SearchIndexer {
+	return &SearchIndexer{index: index, events: events, Interval: 30 * time.Second, BatchSize: 500, wake: make(chan struct{}, 1)}
+}
+
+// Register wakes the indexer once every write commits, so searches see it within
+// moments rather than at the next poll
+func (ix *SearchIndexer) Register(hooks *EventHooks) {
+	hooks.AfterCreate(func(ctx context.Context, _ EventDB) { AfterCommit(ctx, ix.Wake) })
+	hooks.AfterUpdate(func(ctx context.Context, _ EventDB) { AfterCommit(ctx, ix.Wake) })
+	hooks.AfterDelete(func(ctx context.Context, _ uuid.UUID) { AfterCommit(ctx, ix.Wake) })
+}
+
+// Wake makes the indexer pull changes now
+func (ix *SearchIndexer) Wake() {
+	select {
+	case ix.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run indexes changes until ctx is done
+func (ix *SearchIndexer) Run(ctx context.Context) {
+	ticker := time.NewTicker(ix.Interval)
+	defer ticker.Stop()
+
+	var cursor *SyncCursor
+	for {
+		more := false
+		var err error
+		if cursor == nil {
+			cursor, err = ix.resume(ctx)
+		}
+		if err == nil {
+			more, err = ix.Sync(ctx, cursor)
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error indexing events into OpenSearch: %v", err)
+		}
+		if more && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-ix.wake:
+		}
+	}
+}
+
+// resume creates the index if needed and returns a cursor before the changes of its
+// latest version, which are pulled again in case some of them were left out
+func (ix *SearchIndexer) resume(ctx context.Context) (*SyncCursor, error) {
+	if err := ix.index.EnsureIndex(ctx); err != nil {
+		return nil, err
+	}
+	version, err := ix.index.LatestVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &SyncCursor{Version: version}, nil
+}
+
+// Sync indexes one page of the changes after cursor and advances it, reporting whether
+// more changes are waiting
+func (ix *SearchIndexer) Sync(ctx context.Context, cursor *SyncCursor) (bool, error) {
+	page, err := ix.events.PullChanges(ctx, *cursor, ix.BatchSize)
+	if err != nil {
+		return false, fmt.Errorf("pulling changes: %w", err)
+	}
+	if err := ix.index.Bulk(ctx, page.Events, page.Deleted); err != nil {
+		return false, err
+	}
+	*cursor = page.Next
+	return page.HasMore, nil
+}
@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCluster is an OpenSearch cluster holding one index, recording bulk actions
+type fakeCluster struct {
+	mu      sync.Mutex
+	created bool
+	actions []map[string]map[string]any
+	// bulkItems answers bulk requests when set
+	bulkItems string
+	hits      []uuid.UUID
+	fail      bool
+	auth      string
+}
+
+func (c *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if user, pass, ok := r.BasicAuth(); ok {
+		c.auth = user + ":" + pass
+	}
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case c.fail:
+		http.Error(w, `{"error":"cluster_block_exception"}`, http.StatusServiceUnavailable)
+	case r.Method == http.MethodHead && r.URL.Path == "/events":
+		if !c.created {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case r.Method == http.MethodPut && r.URL.Path == "/events":
+		c.created = true
+	case r.URL.Path == "/_bulk":
+		lines := bufio.NewScanner(bytes.NewReader(body))
+		for lines.Scan() {
+			var line map[string]map[string]any
+			json.Unmarshal(lines.Bytes(), &line)
+			if _, ok := line["index"]; ok && lines.Scan() {
+				var doc map[string]any
+				json.Unmarshal(lines.Bytes(), &doc)
+				line["doc"] = doc
+			}
+			c.actions = append(c.actions, line)
+		}
+		if c.bulkItems != "" {
+			w.Write([]byte(c.bulkItems))
+			return
+		}
+		w.Write([]byte(`{"errors":false,"items":[]}`))
+	case r.URL.Path == "/events/_search" && bytes.Contains(body, []byte(`"aggs"`)):
+		w.Write([]byte(`{"aggregations":{"latest":{"value":null}}}`))
+	case r.URL.Path == "/events/_search":
+		var hits []map[string]string
+		for _, id := range c.hits {
+			hits = append(hits, map[string]string{"_id": id.String()})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"hits": map[string]any{"hits": hits}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newFakeIndex(t *testing.T, cluster *fakeCluster) *OpenSearchIndex {
+	srv := httptest.NewServer(cluster)
+	t.Cleanup(srv.Close)
+	idx, err := NewOpenSearchIndex(Config{OpenSearchURL: "http://admin:secret@" + srv.Listener.Addr().String(), OpenSearchIndex: "events"})
+	require.NoError(t, err)
+	return idx
+}
+
+// changeFeed serves one page of changes
+type changeFeed struct {
+	EventRepositoryInterface
+	page  SyncPage
+	after SyncCursor
+}
+
+func (f *changeFeed) PullChanges(ctx context.Context, after SyncCursor, limit int) (*SyncPage, error) {
+	f.after = after
+	page := f.page
+	return &page, nil
+}
+
+func TestSearchIndexerSync(t *testing.T) {
+	cluster := &fakeCluster{}
+	idx := newFakeIndex(t, cluster)
+	published := EventDB{ID: uuid.New(), Title: "Standup", Version: 10}
+	pending := EventDB{ID: uuid.New(), Title: "Offsite", Status: EventStatusPending, Version: 11}
+	deleted := EventTombstone{ID: uuid.New(), Version: 12}
+	feed := &changeFeed{page: SyncPage{Events: []EventDB{published, pending}, Deleted: []EventTombstone{deleted}, Next: SyncCursor{Version: 12, ID: deleted.ID}}}
+	ix := NewSearchIndexer(idx, feed)
+
+	cursor, err := ix.resume(context.Background())
+	require.NoError(t, err)
+	assert.True(t, cluster.created, "the index is created")
+	more, err := ix.Sync(context.Background(), cursor)
+	require.NoError(t, err)
+	assert.False(t, more)
+	assert.Equal(t, SyncCursor{}, feed.after)
+	assert.Equal(t, SyncCursor{Version: 12, ID: deleted.ID}, *cursor)
+
+	require.Len(t, cluster.actions, 3)
+	assert.Equal(t, published.ID.String(), cluster.actions[0]["index"]["_id"])
+	assert.Equal(t, "external", cluster.actions[0]["index"]["version_type"])
+	assert.Equal(t, float64(10), cluster.actions[0]["index"]["version"])
+	assert.Equal(t, "Standup", cluster.actions[0]["doc"]["title"])
+	assert.Equal(t, pending.ID.String(), cluster.actions[1]["delete"]["_id"], "unpublished events leave the index")
+	assert.Equal(t, deleted.ID.String(), cluster.actions[2]["delete"]["_id"])
+	assert.Equal(t, "admin:secret", cluster.auth)
+}
+
+func TestOpenSearchBulkErrors(t *testing.T) {
+	cluster := &fakeCluster{bulkItems: `{"errors":true,"items":[{"index":{"_id":"a","status":409}},{"delete":{"_id":"b","status":404}}]}`}
+	idx := newFakeIndex(t, cluster)
+	events := []EventDB{{ID: uuid.New(), Title: "Standup", Version: 1}}
+	assert.NoError(t, idx.Bulk(context.Background(), events, nil), "stale versions and missing documents are not errors")
+
+	cluster.bulkItems = `{"errors":true,"items":[{"index":{"_id":"a","status":400,"error":{"type":"mapper_parsing_exception"}}}]}`
+	err := idx.Bulk(context.Background(), events, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mapper_parsing_exception")
+}
+
+// searchStore answers searches and reads of searched events from a fixed set
+type searchStore struct {
+	events   []EventSummary
+	searched bool
+}
+
+func (s *searchStore) SearchEvents(ctx context.Context, q SearchQuery) ([]EventSummary, error) {
+	s.searched = true
+	return s.events, nil
+}
+
+func (s *searchStore) EventSummariesByID(ctx context.Context, ids []uuid.UUID) ([]EventSummary, error) {
+	var out []EventSummary
+	for _, id := range ids {
+		for _, e := range s.events {
+			if e.ID == id {
+				out = append(out, e)
+			}
+		}
+	}
+	return out, nil
+}
+
+func TestOpenSearchSearcher(t *testing.T) {
+	first, second := EventSummary{ID: uuid.New(), Title: "Standup"}, EventSummary{ID: uuid.New(), Title: "Standup review"}
+	cluster := &fakeCluster{hits: []uuid.UUID{second.ID, uuid.New(), first.ID}}
+	store := &searchStore{events: []EventSummary{first, second}}
+	s := NewOpenSearchSearcher(newFakeIndex(t, cluster), store)
+
+	results, err := s.SearchEvents(context.Background(), SearchQuery{Text: "standp", Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []EventSummary{second, first}, results, "ranked by the index, without events the database no longer lists")
+	assert.False(t, store.searched)
+
+	cluster.fail = true
+	results, err = s.SearchEvents(context.Background(), SearchQuery{Text: "standp", Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.True(t, store.searched, "searches fall back to the database when the cluster fails")
+}
+
+func TestNewOpenSearchIndex(t *testing.T) {
+	idx, err := NewOpenSearchIndex(Config{})
+	require.NoError(t, err)
+	assert.Nil(t, idx)
+	_, err = NewOpenSearchIndex(Config{OpenSearchURL: "opensearch:9200"})
+	assert.Error(t, err)
+}
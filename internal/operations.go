@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of a long-running operation
+const (
+	OperationPending   = "pending"
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+)
+
+// ErrOperationNotFound is returned when an operation does not exist
+var ErrOperationNotFound = newDomainError(ErrNotFound, "operation not found")
+
+// maxOperationErrors bounds the row errors kept per operation; the failed counter
+// still counts every one
+const maxOperationErrors = 1000
+
+// OperationError describes one input row that could not be processed
+type OperationError struct {
+	// Row is the 1-based position of the record in the input, not counting a CSV header
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+// Operation is work accepted by the API and processed in the background
+type Operation struct {
+	ID        uuid.UUID        `json:"id"`
+	Kind      string           `json:"kind"`
+	Status    string           `json:"status"`
+	Total     int              `json:"total"`
+	Processed int              `json:"processed"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Errors    []OperationError `json:"errors"`
+	// Result summarizes a finished operation; Error is set when it failed as a whole
+	Result     string     `json:"result"`
+	Error      *string    `json:"error"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// Done reports whether the operation has finished, successfully or not
+func (op *Operation) Done() bool {
+	return op.Status == OperationSucceeded || op.Status == OperationFailed
+}
+
+type OperationRepository struct {
+	db *sql.DB
+}
+
+// NewOperationRepository creates a new operation repository
+func NewOperationRepository(db *sql.DB) *OperationRepository {
+	return &OperationRepository{db: db}
+}
+
+const operationColumns = `id, kind, status, total, processed, succeeded, failed, errors, result, error, created_by, created_at, started_at, finished_at`
+
+func scanOperation(row rowScanner, op *Operation, extra ...any) error {
+	var errs []byte
+	dest := append([]any{&op.ID, &op.Kind, &op.Status, &op.Total, &op.Processed, &op.Succeeded, &op.Failed, &errs,
+		&op.Result, &op.Error, &op.CreatedBy, &op.CreatedAt, &op.StartedAt, &op.FinishedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	op.Errors = []OperationError{}
+	return json.Unmarshal(errs, &op.Errors)
+}
+
+// CreateOperation queues an operation; input is handed to the processing function
+func (r *OperationRepository) CreateOperation(ctx context.Context, op Operation, input []byte) (*Operation, error) {
+	query := `
+		INSERT INTO operations (id, kind, status, created_by, input)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + operationColumns
+
+	var created Operation
+	if err := scanOperation(traced(ctx, r.db).QueryRowContext(ctx, query, op.ID, op.Kind, OperationPending, op.CreatedBy, input), &created); err != nil {
+		return nil, fmt.Errorf("failed to create operation: %w", err)
+	}
+	return &created, nil
+}
+
+// GetOperation retrieves an operation by ID
+func (r *OperationRepository) GetOperation(ctx context.Context, id uuid.UUID) (*Operation, error) {
+	var op Operation
+	if err := scanOperation(traced(ctx, r.db).QueryRowContext(ctx, `SELECT `+operationColumns+` FROM operations WHERE id = $1`, id), &op); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOperationNotFound
+		}
+		return nil, fmt.Errorf("failed to get operation: %w", err)
+	}
+	return &op, nil
+}
+
+// ClaimOperation takes the oldest pending operation, or a running one whose lease
+// expired because the instance processing it died, and leases it until lockedUntil.
+// It returns nil when there is nothing to do. Progress restarts from zero.
+func (r *OperationRepository) ClaimOperation(ctx context.Context, lockedUntil time.Time) (*Operation, []byte, error) {
+	query := `
+		UPDATE operations
+		SET status = 'running', started_at = COALESCE(started_at, NOW()), locked_until = $1,
+			total = 0, processed = 0, succeeded = 0, failed = 0, errors = '[]'
+		WHERE id = (
+			SELECT id FROM operations
+			WHERE status = 'pending' OR (status = 'running' AND locked_until < NOW())
+			ORDER BY created_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING ` + operationColumns + `, input`
+
+	var op Operation
+	var input []byte
+	if err := scanOperation(traced(ctx, r.db).QueryRowContext(ctx, query, lockedUntil), &op, &input); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to claim operation: %w", err)
+	}
+	return &op, input, nil
+}
+
+// SaveOperationProgress stores the counters and row errors of a running operation
+// and extends its lease
+func (r *OperationRepository) SaveOperationProgress(ctx context.Context, op Operation, lockedUntil time.Time) error {
+	errs, err := json.Marshal(op.Errors)
+	if err != nil {
+		return err
+	}
+	_, err = traced(ctx, r.db).ExecContext(ctx, `
+		UPDATE operations
+		SET total = $2, processed = $3, succeeded = $4, failed = $5, errors = $6, locked_until = $7
+		WHERE id = $1 AND status = 'running'`,
+		op.ID, op.Total, op.Processed, op.Succeeded, op.Failed, errs, lockedUntil)
+	if err != nil {
+		return fmt.Errorf("failed to save operation progress: %w", err)
+	}
+	return nil
+}
+
+// FinishOperation records the outcome of an operation and drops its input
+func (r *OperationRepository) FinishOperation(ctx context.Context, id uuid.UUID, status, result string, opErr *string) error {
+	_, err := traced(ctx, r.db).ExecContext(ctx, `
+		UPDATE operations
+		SET status = $2, result = $3, error = $4, finished_at = NOW(), locked_until = NULL, input = NULL
+		WHERE id = $1`, id, status, result, opErr)
+	if err != nil {
+		return fmt.Errorf("failed to finish operation: %w", err)
+	}
+	return nil
+}
+
+// OperationFunc processes the input of one operation, reporting progress through
+// tracker. The returned string is stored as the operation result.
+type OperationFunc func(ctx context.Context, input []byte, tracker *OperationTracker) (string, error)
+
+// OperationTracker accumulates the progress of a running operation and saves it
+type OperationTracker struct {
+	repo  OperationRepositoryInterface
+	lease time.Duration
+	op    Operation
+}
+
+// SetTotal records how many rows the operation will process
+func (t *OperationTracker) SetTotal(n int) {
+	t.op.Total = n
+}
+
+// Succeeded records n rows processed successfully
+func (t *OperationTracker) Succeeded(n int) {
+	t.op.Processed += n
+	t.op.Succeeded += n
+}
+
+// Failed records a row that could not be processed
+func (t *OperationTracker) Failed(row int, err error) {
+	t.op.Processed++
+	t.op.Failed++
+	if len(t.op.Errors) < maxOperationErrors {
+		t.op.Errors = append(t.op.Errors, OperationError{Row: row, Message: err.Error()})
+	}
+}
+
+// Progress returns the counters recorded so far
+func (t *OperationTracker) Progress() Operation {
+	return t.op
+}
+
+// Save persists the progress so far and renews the lease on the operation
+func (t *OperationTracker) Save(ctx context.Context) error {
+	return t.repo.SaveOperationProgress(ctx, t.op, time.Now().Add(t.lease))
+}
@@ -0,0 +1,436 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"net/mail"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Roles in an organization, from most to least privileged. Owners manage the
+// organization and every member; admins manage its calendars, invitations and
+// members below owner; members see what it owns.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// orgRoleRanks orders the roles so a role can be compared against a minimum
+var orgRoleRanks = map[string]int{OrgRoleMember: 1, OrgRoleAdmin: 2, OrgRoleOwner: 3}
+
+// InvitationTTL is how long an organization invitation can be accepted
+const InvitationTTL = 7 * 24 * time.Hour
+
+// ErrOrganizationNotFound is returned when an organization does not exist
+var ErrOrganizationNotFound = newDomainError(ErrNotFound, "organization not found")
+
+// ErrUnknownOrganization is returned when a calendar refers to an organization that
+// does not exist
+var ErrUnknownOrganization = newDomainError(ErrValidation, "organization not found")
+
+// ErrMemberNotFound is returned when a user is not a member of an organization
+var ErrMemberNotFound = newDomainError(ErrNotFound, "member not found")
+
+// ErrInvitationNotFound is returned when an invitation does not exist or was
+// already accepted
+var ErrInvitationNotFound = newDomainError(ErrNotFound, "invitation not found")
+
+// ErrInvitationExpired is returned when accepting an invitation past its expiry
+var ErrInvitationExpired = newDomainError(ErrConflict, "invitation has expired")
+
+// ErrLastOwner is returned when removing or demoting the only owner of an organization
+var ErrLastOwner = newDomainError(ErrConflict, "an organization must keep at least one owner")
+
+// ErrOrganizationHasCalendars is returned when deleting an organization that still
+// owns calendars
+var ErrOrganizationHasCalendars = newDomainError(ErrConflict, "organization still owns calendars")
+
+// Organization is a team that owns shared calendars
+type Organization struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy string    `json:"created_by"`
+	// RequireTwoFactor keeps members without two-factor authentication out
+	RequireTwoFactor bool      `json:"require_two_factor"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	// Role is the caller's role, set in listings of the caller's organizations
+	Role string `json:"role,omitempty"`
+}
+
+// Membership is a user's role in an organization
+type Membership struct {
+	OrganizationID uuid.UUID `json:"organization_id"`
+	UserID         string    `json:"user_id"`
+	Role           string    `json:"role"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Invitation asks someone, by email, to join an organization with a role. The token
+// sent to them is never stored, only its hash.
+type Invitation struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	Email          string    `json:"email"`
+	Role           string    `json:"role"`
+	InvitedBy      string    `json:"invited_by"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ValidOrgRole reports whether role is an organization role
+func ValidOrgRole(role string) bool {
+	return orgRoleRanks[role] > 0
+}
+
+// RoleAtLeast reports whether role grants at least what min does
+func RoleAtLeast(role, min string) bool {
+	return orgRoleRanks[role] >= orgRoleRanks[min]
+}
+
+// ValidateInvitation returns a message describing why inv is invalid, or "" if it is valid
+func ValidateInvitation(inv Invitation) string {
+	if addr, err := mail.ParseAddress(inv.Email); err != nil || addr.Address != inv.Email {
+		return "email must be a valid email address"
+	}
+	if !ValidOrgRole(inv.Role) {
+		return "role must be owner, admin or member"
+	}
+	return ""
+}
+
+type OrganizationRepository struct {
+	db *sql.DB
+}
+
+// NewOrganizationRepository creates a new organization repository
+func NewOrganizationRepository(db *sql.DB) *OrganizationRepository {
+	return &OrganizationRepository{db: db}
+}
+
+const organizationColumns = `id, name, created_by, require_two_factor, created_at, updated_at`
+
+func scanOrganization(row rowScanner, o *Organization) error {
+	return row.Scan(&o.ID, &o.Name, &o.CreatedBy, &o.RequireTwoFactor, &o.CreatedAt, &o.UpdatedAt)
+}
+
+const membershipColumns = `organization_id, user_id, role, created_at`
+
+func scanMembership(row rowScanner, m *Membership) error {
+	return row.Scan(&m.OrganizationID, &m.UserID, &m.Role, &m.CreatedAt)
+}
+
+const invitationColumns = `id, organization_id, email, role, invited_by, expires_at, created_at`
+
+func scanInvitation(row rowScanner, inv *Invitation) error {
+	return row.Scan(&inv.ID, &inv.OrganizationID, &inv.Email, &inv.Role, &inv.InvitedBy, &inv.ExpiresAt, &inv.CreatedAt)
+}
+
+// CreateOrganization stores a new organization with its creator as the owner
+func (r *OrganizationRepository) CreateOrganization(ctx context.Context, o Organization) (*Organization, error) {
+	var created Organization
+	err := NewTxManager(r.db).JoinTx(ctx, func(ctx context.Context) error {
+		query := `INSERT INTO organizations (id, name, created_by, require_two_factor) VALUES ($1, $2, $3, $4) RETURNING ` + organizationColumns
+		if err := scanOrganization(conn(ctx, r.db).QueryRowContext(ctx, query, o.ID, o.Name, o.CreatedBy, o.RequireTwoFactor), &created); err != nil {
+			return fmt.Errorf("failed to create organization: %w", err)
+		}
+		if _, err := conn(ctx, r.db).ExecContext(ctx,
+			`INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, 'owner')`, o.ID, o.CreatedBy); err != nil {
+			return fmt.Errorf("failed to add owner: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	created.Role = OrgRoleOwner
+	return &created, nil
+}
+
+// ListOrganizations returns the organizations userID belongs to with their role, or
+// every organization when userID is empty, ordered by name
+func (r *OrganizationRepository) ListOrganizations(ctx context.Context, userID string) ([]Organization, error) {
+	query := `
+		SELECT o.id, o.name, o.created_by, o.require_two_factor, o.created_at, o.updated_at, COALESCE(m.role, '')
+		FROM organizations o LEFT JOIN organization_members m ON m.organization_id = o.id AND m.user_id = $1
+		WHERE $1 = '' OR m.user_id IS NOT NULL
+		ORDER BY o.name, o.id`
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query organizations: %w", err)
+	}
+	defer rows.Close()
+
+	orgs := []Organization{}
+	for rows.Next() {
+		var o Organization
+		if err := rows.Scan(&o.ID, &o.Name, &o.CreatedBy, &o.RequireTwoFactor, &o.CreatedAt, &o.UpdatedAt, &o.Role); err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		orgs = append(orgs, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organizations: %w", err)
+	}
+	return orgs, nil
+}
+
+// GetOrganization retrieves an organization by ID
+func (r *OrganizationRepository) GetOrganization(ctx context.Context, id uuid.UUID) (*Organization, error) {
+	var o Organization
+	err := scanOrganization(conn(ctx, r.db).QueryRowContext(ctx, `SELECT `+organizationColumns+` FROM organizations WHERE id = $1`, id), &o)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return &o, nil
+}
+
+// UpdateOrganization renames an organization and sets whether it requires two-factor
+// authentication
+func (r *OrganizationRepository) UpdateOrganization(ctx context.Context, o Organization) (*Organization, error) {
+	var updated Organization
+	query := `UPDATE organizations SET name = $2, require_two_factor = $3 WHERE id = $1 RETURNING ` + organizationColumns
+	if err := scanOrganization(conn(ctx, r.db).QueryRowContext(ctx, query, o.ID, o.Name, o.RequireTwoFactor), &updated); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("failed to update organization: %w", err)
+	}
+	return &updated, nil
+}
+
+// DeleteOrganization removes an organization with its members and invitations. It
+// fails with ErrOrganizationHasCalendars while it owns calendars.
+func (r *OrganizationRepository) DeleteOrganization(ctx context.Context, id uuid.UUID) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM organizations WHERE id = $1`, id)
+	if err != nil {
+		if isForeignKeyViolation(err, "calendars_organization_id_fkey") {
+			return ErrOrganizationHasCalendars
+		}
+		return fmt.Errorf("failed to delete organization: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrOrganizationNotFound
+	}
+	return nil
+}
+
+// GetMembership returns the role of userID in an organization, failing with
+// ErrMemberNotFound when they are not a member
+func (r *OrganizationRepository) GetMembership(ctx context.Context, orgID uuid.UUID, userID string) (*Membership, error) {
+	var m Membership
+	query := `SELECT ` + membershipColumns + ` FROM organization_members WHERE organization_id = $1 AND user_id = $2`
+	if err := scanMembership(conn(ctx, r.db).QueryRowContext(ctx, query, orgID, userID), &m); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrMemberNotFound
+		}
+		return nil, fmt.Errorf("failed to get membership: %w", err)
+	}
+	return &m, nil
+}
+
+// ListMembers returns the members of an organization, owners first
+func (r *OrganizationRepository) ListMembers(ctx context.Context, orgID uuid.UUID) ([]Membership, error) {
+	query := `
+		SELECT ` + membershipColumns + ` FROM organization_members WHERE organization_id = $1
+		ORDER BY CASE role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 ELSE 2 END, user_id`
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []Membership{}
+	for rows.Next() {
+		var m Membership
+		if err := scanMembership(rows, &m); err != nil {
+			return nil, fmt.Errorf("failed to scan member: %w", err)
+		}
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating members: %w", err)
+	}
+	return members, nil
+}
+
+// SetMember adds userID to an organization or changes their role. It fails with
+// ErrLastOwner when that would leave the organization without an owner.
+func (r *OrganizationRepository) SetMember(ctx context.Context, orgID uuid.UUID, userID, role string) (*Membership, error) {
+	var m Membership
+	err := r.changeMembers(ctx, orgID, func(ctx context.Context) error {
+		query := `
+			INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, $3)
+			ON CONFLICT (organization_id, user_id) DO UPDATE SET role = EXCLUDED.role
+			RETURNING ` + membershipColumns
+		if err := scanMembership(conn(ctx, r.db).QueryRowContext(ctx, query, orgID, userID, role), &m); err != nil {
+			return fmt.Errorf("failed to set member: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// RemoveMember removes userID from an organization. It fails with ErrLastOwner when
+// they are its only owner.
+func (r *OrganizationRepository) RemoveMember(ctx context.Context, orgID uuid.UUID, userID string) error {
+	return r.changeMembers(ctx, orgID, func(ctx context.Context) error {
+		res, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2`, orgID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to remove member: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrMemberNotFound
+		}
+		return nil
+	})
+}
+
+// changeMembers runs fn with the organization locked, so concurrent changes cannot
+// each remove a different last owner, and rolls back when no owner is left
+func (r *OrganizationRepository) changeMembers(ctx context.Context, orgID uuid.UUID, fn func(ctx context.Context) error) error {
+	return NewTxManager(r.db).JoinTx(ctx, func(ctx context.Context) error {
+		var id uuid.UUID
+		if err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT id FROM organizations WHERE id = $1 FOR UPDATE`, orgID).Scan(&id); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrOrganizationNotFound
+			}
+			return fmt.Errorf("failed to lock organization: %w", err)
+		}
+		if err := fn(ctx); err != nil {
+			return err
+		}
+		var owners int
+		query := `SELECT COUNT(*) FROM organization_members WHERE organization_id = $1 AND role = 'owner'`
+		if err := conn(ctx, r.db).QueryRowContext(ctx, query, orgID).Scan(&owners); err != nil {
+			return fmt.Errorf("failed to count owners: %w", err)
+		}
+		if owners == 0 {
+			return ErrLastOwner
+		}
+		return nil
+	})
+}
+
+// CreateInvitation stores an invitation under the hash of the token sent to the invitee
+func (r *OrganizationRepository) CreateInvitation(ctx context.Context, inv Invitation, tokenHash []byte) (*Invitation, error) {
+	query := `
+		INSERT INTO organization_invitations (id, organization_id, email, role, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + invitationColumns
+
+	var created Invitation
+	err := scanInvitation(conn(ctx, r.db).QueryRowContext(ctx, query, inv.ID, inv.OrganizationID, inv.Email, inv.Role, tokenHash, inv.InvitedBy, inv.ExpiresAt), &created)
+	if err != nil {
+		if isForeignKeyViolation(err, "organization_invitations_organization_id_fkey") {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+	return &created, nil
+}
+
+// ListInvitations returns the invitations of an organization not accepted yet,
+// newest first
+func (r *OrganizationRepository) ListInvitations(ctx context.Context, orgID uuid.UUID) ([]Invitation, error) {
+	query := `
+		SELECT ` + invitationColumns + ` FROM organization_invitations
+		WHERE organization_id = $1 AND accepted_at IS NULL
+		ORDER BY created_at DESC, id`
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := []Invitation{}
+	for rows.Next() {
+		var inv Invitation
+		if err := scanInvitation(rows, &inv); err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating invitations: %w", err)
+	}
+	return invitations, nil
+}
+
+// DeleteInvitation revokes an invitation not accepted yet
+func (r *OrganizationRepository) DeleteInvitation(ctx context.Context, orgID, id uuid.UUID) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx,
+		`DELETE FROM organization_invitations WHERE organization_id = $1 AND id = $2 AND accepted_at IS NULL`, orgID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete invitation: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
+
+// AcceptInvitation makes userID a member with the role of the invitation whose token
+// hashes to tokenHash. A member keeps a higher role they already have. Each
+// invitation is accepted once.
+func (r *OrganizationRepository) AcceptInvitation(ctx context.Context, tokenHash []byte, userID string, now time.Time) (*Membership, error) {
+	var m Membership
+	err := NewTxManager(r.db).JoinTx(ctx, func(ctx context.Context) error {
+		var inv Invitation
+		query := `SELECT ` + invitationColumns + ` FROM organization_invitations WHERE token_hash = $1 AND accepted_at IS NULL FOR UPDATE`
+		if err := scanInvitation(conn(ctx, r.db).QueryRowContext(ctx, query, tokenHash), &inv); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrInvitationNotFound
+			}
+			return fmt.Errorf("failed to get invitation: %w", err)
+		}
+		if !now.Before(inv.ExpiresAt) {
+			return ErrInvitationExpired
+		}
+
+		insert := `
+			INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, $3)
+			ON CONFLICT (organization_id, user_id) DO UPDATE SET role = CASE
+				WHEN organization_members.role = 'owner' OR (organization_members.role = 'admin' AND EXCLUDED.role = 'member')
+				THEN organization_members.role ELSE EXCLUDED.role END
+			RETURNING ` + membershipColumns
+		if err := scanMembership(conn(ctx, r.db).QueryRowContext(ctx, insert, inv.OrganizationID, userID, inv.Role), &m); err != nil {
+			return fmt.Errorf("failed to add member: %w", err)
+		}
+		if _, err := conn(ctx, r.db).ExecContext(ctx,
+			`UPDATE organization_invitations SET accepted_by = $2, accepted_at = $3 WHERE id = $1`, inv.ID, userID, now); err != nil {
+			return fmt.Errorf("failed to accept invitation: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// invitationPrefix tells invitation tokens apart from API tokens
+const invitationPrefix = "inv_"
+
+// GenerateInvitationToken returns a new random invitation token and the hash to
+// store for it
+func GenerateInvitationToken() (string, []byte, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, fmt.Errorf("failed to generate invitation token: %w", err)
+	}
+	token := invitationPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleAtLeast(t *testing.T) {
+	assert.True(t, RoleAtLeast(OrgRoleOwner, OrgRoleAdmin))
+	assert.True(t, RoleAtLeast(OrgRoleAdmin, OrgRoleAdmin))
+	assert.False(t, RoleAtLeast(OrgRoleMember, OrgRoleAdmin))
+	assert.False(t, RoleAtLeast("", OrgRoleMember))
+	assert.False(t, ValidOrgRole("guest"))
+}
+
+func TestValidateInvitation(t *testing.T) {
+	assert.Empty(t, ValidateInvitation(Invitation{Email: "bob@example.com", Role: OrgRoleMember}))
+	assert.Equal(t, "email must be a valid email address", ValidateInvitation(Invitation{Email: "Bob <bob@example.com>", Role: OrgRoleMember}))
+	assert.Equal(t, "email must be a valid email address", ValidateInvitation(Invitation{Email: "bob", Role: OrgRoleMember}))
+	assert.Equal(t, "role must be owner, admin or member", ValidateInvitation(Invitation{Email: "bob@example.com", Role: "guest"}))
+}
+
+func TestGenerateInvitationToken(t *testing.T) {
+	token, hash, err := GenerateInvitationToken()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, "inv_"))
+	assert.Equal(t, HashToken(token), hash)
+
+	other, _, err := GenerateInvitationToken()
+	require.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
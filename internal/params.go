@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ParamError is a malformed path or query parameter. It names the parameter so
+// clients can tell which of several was wrong.
+type ParamError struct {
+	Param string
+	Value string
+	// Want describes the accepted format, such as "a UUID"
+	Want string
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("invalid %s %q: expected %s", e.Param, e.Value, e.Want)
+}
+
+// ParseUUIDParam parses the UUID in parameter param. The canonical form is parsed
+// without allocating; the other forms uuid.Parse accepts still are.
+func ParseUUIDParam(param, v string) (uuid.UUID, error) {
+	if id, ok := parseCanonicalUUID(v); ok {
+		return id, nil
+	}
+	if id, err := uuid.Parse(v); err == nil {
+		return id, nil
+	}
+	return uuid.Nil, &ParamError{Param: param, Value: v, Want: "a UUID"}
+}
+
+// ParseEventIDParam parses the event ID in parameter param, a UUID or a short ID
+func ParseEventIDParam(param, v string) (uuid.UUID, error) {
+	id, err := ParseEventID(v)
+	if err != nil {
+		return uuid.Nil, &ParamError{Param: param, Value: v, Want: "a UUID or short ID"}
+	}
+	return id, nil
+}
+
+// ParseTimeParam parses the RFC 3339 time in parameter param, returned in UTC
+func ParseTimeParam(param, v string) (time.Time, error) {
+	t, err := ParseTime(v)
+	if err != nil {
+		return time.Time{}, &ParamError{Param: param, Value: v, Want: "an RFC 3339 time"}
+	}
+	return t, nil
+}
+
+// ParseTime parses an RFC 3339 time into UTC. time.Parse has a fast path for the
+// layout that does not allocate, offsets included.
+func ParseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
+
+// hexValue decodes a hex digit, returning 255 for other bytes
+func hexValue(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10
+	}
+	return 255
+}
+
+// uuidByteOffsets are the offsets of the 16 hex pairs of a canonical UUID
+var uuidByteOffsets = [16]int{0, 2, 4, 6, 9, 11, 14, 16, 19, 21, 24, 26, 28, 30, 32, 34}
+
+// parseCanonicalUUID parses xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx. Dashes are only read
+// at their fixed offsets, so one anywhere else fails as a hex digit.
+func parseCanonicalUUID(s string) (uuid.UUID, bool) {
+	var id uuid.UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return id, false
+	}
+	for j, i := range uuidByteOffsets {
+		hi, lo := hexValue(s[i]), hexValue(s[i+1])
+		if hi > 15 || lo > 15 {
+			return uuid.Nil, false
+		}
+		id[j] = hi<<4 | lo
+	}
+	return id, true
+}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTime(t *testing.T) {
+	got, err := ParseTime("2025-10-06T09:30:00.5-03:00")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 10, 6, 12, 30, 0, 5e8, time.UTC), got)
+	_, err = ParseTime("2025-02-29T00:00:00Z")
+	assert.Error(t, err)
+}
+
+func TestParseParams(t *testing.T) {
+	id := uuid.New()
+	got, err := ParseUUIDParam("calendar_id", id.String())
+	require.NoError(t, err)
+	assert.Equal(t, id, got)
+	got, err = ParseUUIDParam("calendar_id", "urn:uuid:"+id.String())
+	require.NoError(t, err)
+	assert.Equal(t, id, got)
+
+	_, err = ParseUUIDParam("calendar_id", "nope")
+	var pe *ParamError
+	require.True(t, errors.As(err, &pe))
+	assert.Equal(t, "calendar_id", pe.Param)
+	assert.Equal(t, `invalid calendar_id "nope": expected a UUID`, err.Error())
+
+	for _, malformed := range []string{
+		"zzzzzzzz-zzzz-zzzz-zzzz-zzzzzzzzzzzz",
+		"01234567-89ab-cdef-0123--456789abcde",
+		"01234567-89ab-cdef-0123-456789abcde-",
+		"-1234567-89ab-cdef-0123-456789abcdef",
+		"01234567-89ab-cdef-0123-4567-9abcdef",
+		"01234567-89ab-cdef-0123-456789abcdeg",
+		"01234567-89ab-cdef-0123-456789abcde",
+	} {
+		_, err = ParseUUIDParam("calendar_id", malformed)
+		assert.Error(t, err, malformed)
+		_, err = ParseEventID(malformed)
+		assert.Error(t, err, malformed)
+	}
+
+	got, err = ParseEventIDParam("id", ShortID(id))
+	require.NoError(t, err)
+	assert.Equal(t, id, got)
+	_, err = ParseEventIDParam("id", "not-an-id")
+	assert.EqualError(t, err, `invalid id "not-an-id": expected a UUID or short ID`)
+
+	_, err = ParseTimeParam("from", "yesterday")
+	assert.EqualError(t, err, `invalid from "yesterday": expected an RFC 3339 time`)
+}
+
+func TestParsingDoesNotAllocate(t *testing.T) {
+	id := uuid.New()
+	canonical, short := id.String(), ShortID(id)
+	assert.Zero(t, testing.AllocsPerRun(100, func() { ParseEventID(canonical) }))
+	assert.Zero(t, testing.AllocsPerRun(100, func() { ParseEventID(short) }))
+	assert.Zero(t, testing.AllocsPerRun(100, func() { ParseEventID("not-an-event-id") }))
+	assert.Zero(t, testing.AllocsPerRun(100, func() { ParseTime("2025-10-06T09:30:00.123-03:00") }))
+}
+
+func BenchmarkParseEventID(b *testing.B) {
+	id := uuid.New()
+	for _, bc := range []struct{ name, in string }{{"uuid", id.String()}, {"short", ShortID(id)}, {"invalid", "not-an-event-id"}} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ParseEventID(bc.in)
+			}
+		})
+	}
+}
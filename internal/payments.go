@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrWebhookSignature is returned for webhook deliveries that are not signed with the
+// expected secret, or whose signature is too old: by payment providers, and by
+// VerifyWebhookSignature for the deliveries of this API
+var ErrWebhookSignature = errors.New("invalid webhook signature")
+
+// Payment outcomes reported by a provider's webhook
+const (
+	PaymentCompleted = "completed"
+	PaymentExpired   = "expired"
+)
+
+// PaymentProvider takes the payments of held ticket reservations through a hosted
+// checkout page and refunds them
+type PaymentProvider interface {
+	// Name identifies the provider on the reservations it handles
+	Name() string
+	// CreateCheckout starts the payment of a reservation; the payer completes it at
+	// the returned URL
+	CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error)
+	// ParseWebhook verifies a webhook delivery and returns the payment outcome it
+	// reports, or nil for deliveries about anything else
+	ParseWebhook(payload []byte, header http.Header, now time.Time) (*PaymentEvent, error)
+	// Refund returns the whole of a payment to the payer. Refunding the same
+	// reservation again is harmless.
+	Refund(ctx context.Context, reservationID uuid.UUID, paymentID string) error
+}
+
+// CheckoutRequest is a held reservation to be paid
+type CheckoutRequest struct {
+	Reservation TicketReservation
+	EventTitle  string
+	// ReturnURL is where the payer is sent back to, paid or not
+	ReturnURL string
+	// ExpiresAt is when the reservation's hold runs out
+	ExpiresAt time.Time
+}
+
+// Checkout is a provider's hosted payment page for a reservation
+type Checkout struct {
+	SessionID string
+	URL       string
+}
+
+// PaymentEvent is the outcome of a checkout reported by a provider
+type PaymentEvent struct {
+	Type          string
+	EventID       uuid.UUID
+	ReservationID uuid.UUID
+	SessionID     string
+	// PaymentID is the provider's payment of a completed checkout
+	PaymentID string
+}
+
+// NewPaymentProvider returns the provider configured in cfg, or nil when ticket
+// payments are not set up
+func NewPaymentProvider(cfg Config) PaymentProvider {
+	if cfg.StripeSecretKey == "" {
+		return nil
+	}
+	return NewStripeProvider(cfg.StripeSecretKey, cfg.StripeWebhookSecret)
+}
@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// A4 page size and margins in PDF points
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+	pdfMargin     = 50.0
+)
+
+// PDF fonts: the standard Helvetica faces every reader has, so nothing is embedded
+const (
+	pdfRegular = "F1"
+	pdfBold    = "F2"
+)
+
+// helveticaWidths are the advance widths of Helvetica for the printable ASCII
+// characters, in thousandths of the font size
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// winAnsi maps the characters outside Latin-1 that WinAnsiEncoding can show
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '•': 0x95, '–': 0x96, '—': 0x97,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '™': 0x99,
+}
+
+// pdfEncode converts s to WinAnsiEncoding, replacing characters it cannot show with '?'
+func pdfEncode(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch b, ok := winAnsi[r]; {
+		case ok:
+			out = append(out, b)
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			out = append(out, byte(r))
+		case r == '\t':
+			out = append(out, ' ')
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+// pdfTextWidth approximates the width of s in points. Bold text runs about 5% wider;
+// characters outside ASCII are counted as a digit.
+func pdfTextWidth(s, font string, size float64) float64 {
+	total := 0
+	for _, b := range pdfEncode(s) {
+		if b >= 0x20 && b < 0x7f {
+			total += helveticaWidths[b-0x20]
+		} else {
+			total += 556
+		}
+	}
+	width := float64(total) * size / 1000
+	if font == pdfBold {
+		width *= 1.05
+	}
+	return width
+}
+
+// pdfWrap breaks text into lines no wider than width, keeping its line breaks
+func pdfWrap(text, font string, size, width float64) []string {
+	var lines []string
+	for _, para := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			candidate := strings.TrimPrefix(line+" "+word, " ")
+			if line != "" && pdfTextWidth(candidate, font, size) > width {
+				lines = append(lines, line)
+				candidate = word
+			}
+			// Break words longer than a line
+			for pdfTextWidth(candidate, font, size) > width && len([]rune(candidate)) > 1 {
+				runes := []rune(candidate)
+				n := len(runes) - 1
+				for n > 1 && pdfTextWidth(string(runes[:n]), font, size) > width {
+					n--
+				}
+				lines = append(lines, string(runes[:n]))
+				candidate = string(runes[n:])
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// pdfDocument lays out text on A4 pages from the top down and writes them as a PDF
+type pdfDocument struct {
+	title string
+	pages []*bytes.Buffer
+	// y is the baseline of the next line on the current page
+	y float64
+}
+
+func newPDFDocument(title string) *pdfDocument {
+	d := &pdfDocument{title: title}
+	d.newPage()
+	return d
+}
+
+func (d *pdfDocument) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pdfPageHeight - pdfMargin
+}
+
+func (d *pdfDocument) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+// ensure starts a new page unless height points are left above the bottom margin
+func (d *pdfDocument) ensure(height float64) {
+	if d.y-height < pdfMargin {
+		d.newPage()
+	}
+}
+
+// textAt draws one line of text with its baseline at y
+func (d *pdfDocument) textAt(x, y float64, font string, size float64, text string) {
+	fmt.Fprintf(d.page(), "BT /%s %.1f Tf %.2f %.2f Td ", font, size, x, y)
+	d.page().Write(pdfString(pdfEncode(text)))
+	d.page().WriteString(" Tj ET\n")
+}
+
+// paragraph draws text wrapped to width starting at x, moving down a line for each
+func (d *pdfDocument) paragraph(x, width float64, font string, size float64, text string) {
+	for _, line := range pdfWrap(text, font, size, width) {
+		d.ensure(size * 1.3)
+		d.y -= size * 1.3
+		d.textAt(x, d.y, font, size, line)
+	}
+}
+
+// rule draws a horizontal line across the text area at the current position
+func (d *pdfDocument) rule() {
+	fmt.Fprintf(d.page(), "0.8 G 0.5 w %.2f %.2f m %.2f %.2f l S 0 G\n", pdfMargin, d.y, pdfPageWidth-pdfMargin, d.y)
+}
+
+// space moves down by height points
+func (d *pdfDocument) space(height float64) {
+	d.y -= height
+}
+
+// pdfString quotes b as a PDF literal string
+func pdfString(b []byte) []byte {
+	var out bytes.Buffer
+	out.WriteByte('(')
+	for _, c := range b {
+		if c == '(' || c == ')' || c == '\\' {
+			out.WriteByte('\\')
+		}
+		out.WriteByte(c)
+	}
+	out.WriteByte(')')
+	return out.Bytes()
+}
+
+// write writes the document as a PDF file. footer, when set, returns the text
+// centered at the bottom of each page given its number and the page count.
+func (d *pdfDocument) write(w io.Writer, footer func(page, pages int) string, created time.Time) error {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// Objects 1-5 are the catalog, page tree, both fonts and the document info; each
+	// page then takes two, itself and its content stream
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Title %s /Producer (taller_challenge) /CreationDate (D:%s) >>",
+		pdfString(pdfEncode(d.title)), created.UTC().Format("20060102150405Z")))
+
+	for i, content := range d.pages {
+		if footer != nil {
+			text := footer(i+1, len(d.pages))
+			x := (pdfPageWidth - pdfTextWidth(text, pdfRegular, 8)) / 2
+			fmt.Fprintf(content, "0.4 g BT /%s 8 Tf %.2f %.2f Td ", pdfRegular, x, pdfMargin/2)
+			content.Write(pdfString(pdfEncode(text)))
+			content.WriteString(" Tj ET 0 g\n")
+		}
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, pdfRegular, pdfBold, 7+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
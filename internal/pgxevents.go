@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNotPorted is returned by the methods of PgxEventRepository that still only exist
+// on the lib/pq EventRepository
+var ErrNotPorted = errors.New("not implemented by the pgx event repository yet")
+
+// PgxEventRepository reads events through pgx instead of lib/pq and database/sql. It
+// runs the same registered queries as EventRepository, so comparing the two under
+// ShadowEventRepository exercises the driver alone: its type decoding, connection
+// handling and error reporting. Only reads are ported; writes and the sync, approval
+// and aggregate methods return ErrNotPorted.
+type PgxEventRepository struct {
+	pool   *pgxpool.Pool
+	cipher *FieldCipher
+}
+
+// NewPgxEventRepository opens a pool to dsn; connections are made on first use. When
+// cipher is non-nil, description and location are decrypted as EventRepository does.
+func NewPgxEventRepository(ctx context.Context, dsn string, cipher *FieldCipher) (*PgxEventRepository, error) {
+	cfg, err := pgxpool.ParseConfig(withApplicationName(dsn))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pgx DSN: %w", err)
+	}
+	cfg.MaxConnLifetime = 5 * time.Minute
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pgx pool: %w", err)
+	}
+	return &PgxEventRepository{pool: pool, cipher: cipher}, nil
+}
+
+// Close closes the pool's connections
+func (r *PgxEventRepository) Close() {
+	r.pool.Close()
+}
+
+// Ping checks the database
+func (r *PgxEventRepository) Ping(ctx context.Context) error {
+	return r.pool.Ping(ctx)
+}
+
+// GetEvents retrieves all events
+func (r *PgxEventRepository) GetEvents(ctx context.Context) ([]EventDB, error) {
+	query, args := newSelect(qSelectEvents).OrderBy("start_time ASC").Build()
+	return r.queryEvents(ctx, query, args...)
+}
+
+// GetEventsBetween retrieves the events overlapping [from, to), ordered by start time
+func (r *PgxEventRepository) GetEventsBetween(ctx context.Context, from, to time.Time) ([]EventDB, error) {
+	query, args := newSelect(qSelectEvents).
+		Where("start_time < ? AND end_time > ?", to, from).
+		OrderBy("start_time ASC").
+		Build()
+	return r.queryEvents(ctx, query, args...)
+}
+
+// GetEventsByCalendar retrieves the events of a calendar, ordered by start time
+func (r *PgxEventRepository) GetEventsByCalendar(ctx context.Context, calendarID uuid.UUID) ([]EventDB, error) {
+	query, args := newSelect(qSelectEvents).
+		Where("calendar_id = ?", calendarID).
+		OrderBy("start_time ASC").
+		Build()
+	return r.queryEvents(ctx, query, args...)
+}
+
+// GetEventsByExternalID retrieves the events synced with externalID, from source or,
+// when source is empty, from any system
+func (r *PgxEventRepository) GetEventsByExternalID(ctx context.Context, source, externalID string) ([]EventDB, error) {
+	b := newSelect(qSelectEvents).Where("external_id = ?", externalID)
+	if source != "" {
+		b.Where("source = ?", source)
+	}
+	query, args := b.OrderBy("start_time ASC").Build()
+	return r.queryEvents(ctx, query, args...)
+}
+
+// GetEventByClientKey retrieves the event of calendarID, or of no calendar when it is
+// nil, with clientKey
+func (r *PgxEventRepository) GetEventByClientKey(ctx context.Context, calendarID *uuid.UUID, clientKey string) (*EventDB, error) {
+	calendar := uuid.Nil
+	if calendarID != nil {
+		calendar = *calendarID
+	}
+	query, args := newSelect(qSelectEvents).
+		Where("COALESCE(calendar_id, '00000000-0000-0000-0000-000000000000'::uuid) = ?", calendar).
+		Where("client_key = ?", clientKey).
+		Build()
+	events, err := r.queryEvents(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, ErrEventNotFound
+	}
+	return &events[0], nil
+}
+
+// GetEventByID retrieves a specific event by ID
+func (r *PgxEventRepository) GetEventByID(ctx context.Context, id uuid.UUID) (*EventDB, error) {
+	var event EventDB
+	err := scanEvent(r.pool.QueryRow(ctx, qGetEvent.SQL, id), &event)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrEventNotFound
+		}
+		return nil, fmt.Errorf("failed to get event by ID: %w", err)
+	}
+	if err := r.decryptEvent(&event); err != nil {
+		return nil, fmt.Errorf("failed to decrypt event %s: %w", event.ID, err)
+	}
+	return &event, nil
+}
+
+// FilterEvents retrieves the events f matches, ordered by start time
+func (r *PgxEventRepository) FilterEvents(ctx context.Context, f *EventFilter) ([]EventDB, error) {
+	cond, args := f.Where()
+	query, args := newSelect(qSelectEvents).Where(cond, args...).OrderBy("start_time ASC").Build()
+	return r.queryEvents(ctx, query, args...)
+}
+
+// queryEvents runs a query selecting eventColumns and decrypts each row
+func (r *PgxEventRepository) queryEvents(ctx context.Context, query string, args ...any) ([]EventDB, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []EventDB
+	for rows.Next() {
+		var event EventDB
+		if err := scanEvent(rows, &event); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		if err := r.decryptEvent(&event); err != nil {
+			return nil, fmt.Errorf("failed to decrypt event %s: %w", event.ID, err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating events: %w", err)
+	}
+	return events, nil
+}
+
+// decryptEvent replaces encrypted column values with their plaintext
+func (r *PgxEventRepository) decryptEvent(event *EventDB) error {
+	if err := r.cipher.decryptOptional(event.Description); err != nil {
+		return fmt.Errorf("description: %w", err)
+	}
+	if err := r.cipher.decryptOptional(event.Location); err != nil {
+		return fmt.Errorf("location: %w", err)
+	}
+	return nil
+}
+
+// CreateEvent is not ported yet
+func (r *PgxEventRepository) CreateEvent(ctx context.Context, event EventDB) (*EventDB, error) {
+	return nil, ErrNotPorted
+}
+
+// UpdateEvent is not ported yet
+func (r *PgxEventRepository) UpdateEvent(ctx context.Context, event EventDB) (*EventDB, error) {
+	return nil, ErrNotPorted
+}
+
+// DeleteEvent is not ported yet
+func (r *PgxEventRepository) DeleteEvent(ctx context.Context, id uuid.UUID) error {
+	return ErrNotPorted
+}
+
+// ImportEvents is not ported yet
+func (r *PgxEventRepository) ImportEvents(ctx context.Context, events []EventDB) (int, error) {
+	return 0, ErrNotPorted
+}
+
+// PullChanges is not ported yet
+func (r *PgxEventRepository) PullChanges(ctx context.Context, after SyncCursor, limit int) (*SyncPage, error) {
+	return nil, ErrNotPorted
+}
+
+// ApplySyncChange is not ported yet
+func (r *PgxEventRepository) ApplySyncChange(ctx context.Context, c SyncChange) (*SyncOutcome, error) {
+	return nil, ErrNotPorted
+}
+
+// ListPendingEvents is not ported yet
+func (r *PgxEventRepository) ListPendingEvents(ctx context.Context, limit int) ([]EventDB, error) {
+	return nil, ErrNotPorted
+}
+
+// ReviewEvent is not ported yet
+func (r *PgxEventRepository) ReviewEvent(ctx context.Context, review EventReview) (*EventDB, error) {
+	return nil, ErrNotPorted
+}
+
+// ListEventReviews is not ported yet
+func (r *PgxEventRepository) ListEventReviews(ctx context.Context, eventID uuid.UUID) ([]EventReview, error) {
+	return nil, ErrNotPorted
+}
+
+// Occupancy is not ported yet
+func (r *PgxEventRepository) Occupancy(ctx context.Context, q HeatmapQuery) ([]HeatmapBucket, error) {
+	return nil, ErrNotPorted
+}
+
+// EventStats is not ported yet
+func (r *PgxEventRepository) EventStats(ctx context.Context, q EventStatsQuery) ([]EventStatsRow, error) {
+	return nil, ErrNotPorted
+}
+
+// SuggestTitles is not ported yet
+func (r *PgxEventRepository) SuggestTitles(ctx context.Context, q SuggestQuery) ([]TitleSuggestion, error) {
+	return nil, ErrNotPorted
+}
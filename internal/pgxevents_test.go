@@ -0,0 +1,24 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPgxEventRepository(t *testing.T) {
+	_, err := NewPgxEventRepository(context.Background(), "postgres://localhost:notaport/db", nil)
+	assert.Error(t, err)
+
+	// The pool connects on first use, so a database that is down does not stop startup
+	repo, err := NewPgxEventRepository(context.Background(), "postgres://localhost:1/db?connect_timeout=1", nil)
+	require.NoError(t, err)
+	defer repo.Close()
+	var _ EventRepositoryInterface = repo
+
+	// Writes stay on lib/pq until they are ported
+	assert.ErrorIs(t, repo.DeleteEvent(context.Background(), uuid.New()), ErrNotPorted)
+}
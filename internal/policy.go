@@ -0,0 +1,312 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/uuid"
+)
+
+// policyCostLimit bounds the work one rule may do per evaluation, so a rule cannot
+// stall writes with an expensive comprehension
+const policyCostLimit = 10000
+
+// policyReloadInterval is how often rules are re-read, picking up changes made
+// through other instances
+const policyReloadInterval = 30 * time.Second
+
+// ErrPolicyRuleNotFound is returned when a policy rule does not exist
+var ErrPolicyRuleNotFound = newDomainError(ErrNotFound, "policy rule not found")
+
+// PolicyRule is an admin-defined validation rule: a CEL expression over the event
+// being written that must evaluate to true, e.g.
+//
+//	event.duration <= duration("8h") || event.description != ""
+type PolicyRule struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	Expression string    `json:"expression"`
+	// Message is returned to the client when the rule rejects an event
+	Message string `json:"message"`
+	// CalendarID limits the rule to the events of one calendar
+	CalendarID *uuid.UUID `json:"calendar_id"`
+	Enabled    bool       `json:"enabled"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Applies reports whether the rule checks event
+func (p *PolicyRule) Applies(event EventDB) bool {
+	if !p.Enabled {
+		return false
+	}
+	return p.CalendarID == nil || (event.CalendarID != nil && *event.CalendarID == *p.CalendarID)
+}
+
+type PolicyRepository struct {
+	db *sql.DB
+}
+
+// NewPolicyRepository creates a new policy rule repository
+func NewPolicyRepository(db *sql.DB) *PolicyRepository {
+	return &PolicyRepository{db: db}
+}
+
+const policyRuleColumns = `id, name, expression, message, calendar_id, enabled, created_by, created_at, updated_at`
+
+func scanPolicyRule(row rowScanner, p *PolicyRule) error {
+	return row.Scan(&p.ID, &p.Name, &p.Expression, &p.Message, &p.CalendarID, &p.Enabled, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt)
+}
+
+// CreatePolicyRule stores a new rule
+func (r *PolicyRepository) CreatePolicyRule(ctx context.Context, p PolicyRule) (*PolicyRule, error) {
+	query := `
+		INSERT INTO policy_rules (id, name, expression, message, calendar_id, enabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + policyRuleColumns
+
+	var created PolicyRule
+	if err := scanPolicyRule(traced(ctx, r.db).QueryRowContext(ctx, query, p.ID, p.Name, p.Expression, p.Message, p.CalendarID, p.Enabled, p.CreatedBy), &created); err != nil {
+		if isForeignKeyViolation(err, "policy_rules_calendar_id_fkey") {
+			return nil, ErrUnknownCalendar
+		}
+		return nil, fmt.Errorf("failed to create policy rule: %w", err)
+	}
+	return &created, nil
+}
+
+// ListPolicyRules returns every rule ordered by name
+func (r *PolicyRepository) ListPolicyRules(ctx context.Context) ([]PolicyRule, error) {
+	rows, err := traced(ctx, r.db).QueryContext(ctx, `SELECT `+policyRuleColumns+` FROM policy_rules ORDER BY name, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query policy rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []PolicyRule{}
+	for rows.Next() {
+		var p PolicyRule
+		if err := scanPolicyRule(rows, &p); err != nil {
+			return nil, fmt.Errorf("failed to scan policy rule: %w", err)
+		}
+		rules = append(rules, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating policy rules: %w", err)
+	}
+	return rules, nil
+}
+
+// GetPolicyRule retrieves a rule by ID
+func (r *PolicyRepository) GetPolicyRule(ctx context.Context, id uuid.UUID) (*PolicyRule, error) {
+	var p PolicyRule
+	if err := scanPolicyRule(traced(ctx, r.db).QueryRowContext(ctx, `SELECT `+policyRuleColumns+` FROM policy_rules WHERE id = $1`, id), &p); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPolicyRuleNotFound
+		}
+		return nil, fmt.Errorf("failed to get policy rule: %w", err)
+	}
+	return &p, nil
+}
+
+// UpdatePolicyRule saves the editable fields of a rule
+func (r *PolicyRepository) UpdatePolicyRule(ctx context.Context, p PolicyRule) (*PolicyRule, error) {
+	query := `
+		UPDATE policy_rules
+		SET name = $2, expression = $3, message = $4, calendar_id = $5, enabled = $6, updated_at = NOW()
+		WHERE id = $1
+		RETURNING ` + policyRuleColumns
+
+	var updated PolicyRule
+	if err := scanPolicyRule(traced(ctx, r.db).QueryRowContext(ctx, query, p.ID, p.Name, p.Expression, p.Message, p.CalendarID, p.Enabled), &updated); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPolicyRuleNotFound
+		}
+		if isForeignKeyViolation(err, "policy_rules_calendar_id_fkey") {
+			return nil, ErrUnknownCalendar
+		}
+		return nil, fmt.Errorf("failed to update policy rule: %w", err)
+	}
+	return &updated, nil
+}
+
+// DeletePolicyRule removes a rule
+func (r *PolicyRepository) DeletePolicyRule(ctx context.Context, id uuid.UUID) error {
+	res, err := traced(ctx, r.db).ExecContext(ctx, `DELETE FROM policy_rules WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete policy rule: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrPolicyRuleNotFound
+	}
+	return nil
+}
+
+// compiledRule is a rule with its CEL program
+type compiledRule struct {
+	rule    PolicyRule
+	program cel.Program
+}
+
+// PolicyEngine evaluates the stored policy rules on event creates and updates. Rules
+// see the event as a map with id, calendar_id, title, description,
+// description_format, location (strings, "" when unset), start and end (timestamps)
+// and duration, plus now.
+type PolicyEngine struct {
+	repo PolicyRepositoryInterface
+	env  *cel.Env
+	now  func() time.Time
+
+	mu       sync.Mutex
+	rules    []compiledRule
+	loadedAt time.Time
+}
+
+// NewPolicyEngine evaluates the rules stored in repo
+func NewPolicyEngine(repo PolicyRepositoryInterface) (*PolicyEngine, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("event", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("now", cel.TimestampType),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create policy environment: %w", err)
+	}
+	return &PolicyEngine{repo: repo, env: env, now: time.Now}, nil
+}
+
+// Register checks the rules before every create and update
+func (e *PolicyEngine) Register(hooks *EventHooks) {
+	hooks.BeforeCreate(e.Check)
+	hooks.BeforeUpdate(e.Check)
+}
+
+// Compile parses and type-checks a rule expression, which must be boolean
+func (e *PolicyEngine) Compile(expression string) (cel.Program, error) {
+	ast, iss := e.env.Compile(expression)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("expression must be boolean, not %s", ast.OutputType())
+	}
+	return e.env.Program(ast, cel.CostLimit(policyCostLimit))
+}
+
+// Evaluate runs a compiled rule against event
+func (e *PolicyEngine) Evaluate(program cel.Program, event EventDB) (bool, error) {
+	out, _, err := program.Eval(map[string]any{"event": policyInput(event), "now": e.now()})
+	if err != nil {
+		return false, err
+	}
+	ok, isBool := out.Value().(bool)
+	if !isBool {
+		return false, fmt.Errorf("expression returned %s, not a boolean", out.Type())
+	}
+	return ok, nil
+}
+
+// Check rejects event with the message of the first enabled rule it breaks. A rule
+// that fails to evaluate rejects the event too.
+func (e *PolicyEngine) Check(ctx context.Context, event *EventDB) error {
+	broken, err := e.Violations(ctx, *event)
+	if err != nil {
+		return HookInternalError(err)
+	}
+	if len(broken) > 0 {
+		return newDomainError(ErrValidation, broken[0])
+	}
+	return nil
+}
+
+// Violations returns the messages of every enabled rule event breaks, in rule order.
+// A rule that fails to evaluate counts as broken.
+func (e *PolicyEngine) Violations(ctx context.Context, event EventDB) ([]string, error) {
+	rules, err := e.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var broken []string
+	for _, r := range rules {
+		if !r.rule.Applies(event) {
+			continue
+		}
+		ok, err := e.Evaluate(r.program, event)
+		if err != nil {
+			log.Printf("Policy: rule %s (%s) failed on event %s: %v", r.rule.Name, r.rule.ID, event.ID, err)
+			broken = append(broken, fmt.Sprintf("policy %q could not be evaluated: %v", r.rule.Name, err))
+			continue
+		}
+		if !ok {
+			broken = append(broken, r.rule.Message)
+		}
+	}
+	return broken, nil
+}
+
+// Invalidate makes the next check re-read the rules; call it after changing them
+func (e *PolicyEngine) Invalidate() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.loadedAt = time.Time{}
+}
+
+// load returns the compiled rules, re-reading them when they are older than
+// policyReloadInterval. Stored rules that no longer compile are skipped with a log line.
+func (e *PolicyEngine) load(ctx context.Context) ([]compiledRule, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.loadedAt.IsZero() && e.now().Sub(e.loadedAt) < policyReloadInterval {
+		return e.rules, nil
+	}
+
+	stored, err := e.repo.ListPolicyRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policy rules: %w", err)
+	}
+	rules := make([]compiledRule, 0, len(stored))
+	for _, rule := range stored {
+		if !rule.Enabled {
+			continue
+		}
+		program, err := e.Compile(rule.Expression)
+		if err != nil {
+			log.Printf("Warning: skipping policy rule %s (%s): %v", rule.Name, rule.ID, err)
+			continue
+		}
+		rules = append(rules, compiledRule{rule: rule, program: program})
+	}
+	e.rules, e.loadedAt = rules, e.now()
+	return rules, nil
+}
+
+// policyInput is the event as rules see it. Optional fields are "" rather than null so
+// rules can compare them without checking for presence.
+func policyInput(event EventDB) map[string]any {
+	in := map[string]any{
+		"id":                 event.ID.String(),
+		"calendar_id":        "",
+		"title":              event.Title,
+		"description":        "",
+		"description_format": event.DescriptionFormat,
+		"location":           "",
+		"start":              event.StartTime,
+		"end":                event.EndTime,
+		"duration":           event.EndTime.Sub(event.StartTime),
+	}
+	if event.CalendarID != nil {
+		in["calendar_id"] = event.CalendarID.String()
+	}
+	if event.Description != nil {
+		in["description"] = *event.Description
+	}
+	if event.Location != nil {
+		in["location"] = *event.Location
+	}
+	return in
+}
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePolicyRepository lists a fixed set of rules and counts the reads
+type fakePolicyRepository struct {
+	PolicyRepositoryInterface
+	rules []PolicyRule
+	reads int
+}
+
+func (f *fakePolicyRepository) ListPolicyRules(ctx context.Context) ([]PolicyRule, error) {
+	f.reads++
+	return f.rules, nil
+}
+
+func TestPolicyEngineCompile(t *testing.T) {
+	engine, err := NewPolicyEngine(&fakePolicyRepository{})
+	require.NoError(t, err)
+
+	for _, expr := range []string{
+		`event.duration <= duration("8h") || event.description != ""`,
+		`!(event.start.getDayOfWeek("Europe/Madrid") in [0, 6])`,
+		`event.start > now`,
+	} {
+		_, err := engine.Compile(expr)
+		assert.NoError(t, err, expr)
+	}
+	for _, expr := range []string{
+		`event.title +`,
+		`unknown == 1`,
+		`1 + 1`,
+	} {
+		_, err := engine.Compile(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestPolicyEngineCheck(t *testing.T) {
+	teamCalendar := uuid.New()
+	repo := &fakePolicyRepository{rules: []PolicyRule{
+		{ID: uuid.New(), Name: "long events", Expression: `event.duration <= duration("8h") || event.description != ""`, Message: "events longer than 8h require a description", Enabled: true},
+		{ID: uuid.New(), Name: "weekdays", Expression: `!(event.start.getDayOfWeek() in [0, 6])`, Message: "no events on weekends in the team calendar", CalendarID: &teamCalendar, Enabled: true},
+		{ID: uuid.New(), Name: "disabled", Expression: `false`, Message: "never"},
+		{ID: uuid.New(), Name: "broken", Expression: `event.title +`, Message: "skipped", Enabled: true},
+	}}
+	engine, err := NewPolicyEngine(repo)
+	require.NoError(t, err)
+
+	monday := time.Date(2025, 9, 8, 9, 0, 0, 0, time.UTC)
+	saturday := time.Date(2025, 9, 13, 9, 0, 0, 0, time.UTC)
+	desc := "Offsite agenda"
+
+	tests := []struct {
+		name    string
+		event   EventDB
+		wantMsg string
+	}{
+		{"short event", EventDB{StartTime: monday, EndTime: monday.Add(time.Hour)}, ""},
+		{"long event without description", EventDB{StartTime: monday, EndTime: monday.Add(9 * time.Hour)}, "events longer than 8h require a description"},
+		{"long event with description", EventDB{StartTime: monday, EndTime: monday.Add(9 * time.Hour), Description: &desc}, ""},
+		{"weekend in another calendar", EventDB{StartTime: saturday, EndTime: saturday.Add(time.Hour)}, ""},
+		{"weekend in the team calendar", EventDB{CalendarID: &teamCalendar, StartTime: saturday, EndTime: saturday.Add(time.Hour)}, "no events on weekends in the team calendar"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := engine.Check(context.Background(), &tt.event)
+			if tt.wantMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrValidation)
+			assert.Equal(t, tt.wantMsg, DomainMessage(err))
+		})
+	}
+
+	// Rules are cached until invalidated or policyReloadInterval passes
+	assert.Equal(t, 1, repo.reads)
+	engine.Invalidate()
+	require.NoError(t, engine.Check(context.Background(), &EventDB{StartTime: monday, EndTime: monday}))
+	assert.Equal(t, 2, repo.reads)
+}
@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Week start days of the preferences
+const (
+	WeekStartMonday   = "monday"
+	WeekStartSunday   = "sunday"
+	WeekStartSaturday = "saturday"
+)
+
+// MaxDefaultReminders is how many reminders the preferences may add to new events
+const MaxDefaultReminders = 5
+
+// Preferences are a user's defaults for the events they create. Users who never saved
+// theirs get DefaultPreferences.
+type Preferences struct {
+	UserID string `json:"-"`
+	// Timezone is the IANA zone quick-add reads dates in when the request has none
+	Timezone string `json:"timezone"`
+	// ReminderOffsets are the minutes before the start of the push reminders added to
+	// the events the user creates
+	ReminderOffsets []int `json:"reminder_offsets"`
+	// WeekStart is the first day of the week in the user's calendar views
+	WeekStart string `json:"week_start"`
+	// UpdatedAt is nil until the preferences are first saved
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// DefaultPreferences returns the preferences of a user who never saved theirs
+func DefaultPreferences(userID string) Preferences {
+	return Preferences{UserID: userID, Timezone: "UTC", ReminderOffsets: []int{}, WeekStart: WeekStartMonday}
+}
+
+// Validate returns a client-facing message when the preferences are invalid
+func (p Preferences) Validate() string {
+	if _, err := time.LoadLocation(p.Timezone); err != nil || p.Timezone == "" || p.Timezone == "Local" {
+		return fmt.Sprintf("unknown timezone %q", p.Timezone)
+	}
+	if len(p.ReminderOffsets) > MaxDefaultReminders {
+		return fmt.Sprintf("at most %d reminder_offsets", MaxDefaultReminders)
+	}
+	seen := map[int]bool{}
+	for _, offset := range p.ReminderOffsets {
+		if offset < 0 || offset > MaxReminderOffset {
+			return fmt.Sprintf("reminder_offsets must be between 0 and %d", MaxReminderOffset)
+		}
+		if seen[offset] {
+			return "reminder_offsets must not repeat"
+		}
+		seen[offset] = true
+	}
+	switch p.WeekStart {
+	case WeekStartMonday, WeekStartSunday, WeekStartSaturday:
+	default:
+		return "week_start must be monday, sunday or saturday"
+	}
+	return ""
+}
+
+// Location returns the preferred time zone, or UTC when it can no longer be loaded
+func (p Preferences) Location() *time.Location {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+type PreferencesRepository struct {
+	db *sql.DB
+}
+
+// NewPreferencesRepository creates a repository of user preferences
+func NewPreferencesRepository(db *sql.DB) *PreferencesRepository {
+	return &PreferencesRepository{db: db}
+}
+
+const preferencesColumns = `user_id, timezone, reminder_offsets, week_start, updated_at`
+
+func scanPreferences(row rowScanner, p *Preferences) error {
+	var offsets pq.Int64Array
+	var updatedAt time.Time
+	if err := row.Scan(&p.UserID, &p.Timezone, &offsets, &p.WeekStart, &updatedAt); err != nil {
+		return err
+	}
+	p.ReminderOffsets = make([]int, len(offsets))
+	for i, offset := range offsets {
+		p.ReminderOffsets[i] = int(offset)
+	}
+	p.UpdatedAt = &updatedAt
+	return nil
+}
+
+// GetPreferences returns the preferences of userID, or the defaults when none were saved
+func (r *PreferencesRepository) GetPreferences(ctx context.Context, userID string) (*Preferences, error) {
+	query := `SELECT ` + preferencesColumns + ` FROM user_preferences WHERE user_id = $1`
+	var p Preferences
+	err := scanPreferences(conn(ctx, r.db).QueryRowContext(ctx, query, userID), &p)
+	if errors.Is(err, sql.ErrNoRows) {
+		defaults := DefaultPreferences(userID)
+		return &defaults, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	return &p, nil
+}
+
+// SavePreferences creates or replaces the preferences of p.UserID
+func (r *PreferencesRepository) SavePreferences(ctx context.Context, p Preferences) (*Preferences, error) {
+	offsets := make(pq.Int64Array, len(p.ReminderOffsets))
+	for i, offset := range p.ReminderOffsets {
+		offsets[i] = int64(offset)
+	}
+	query := `
+		INSERT INTO user_preferences (user_id, timezone, reminder_offsets, week_start)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET
+			timezone = EXCLUDED.timezone, reminder_offsets = EXCLUDED.reminder_offsets,
+			week_start = EXCLUDED.week_start, updated_at = NOW()
+		RETURNING ` + preferencesColumns
+	var saved Preferences
+	if err := scanPreferences(conn(ctx, r.db).QueryRowContext(ctx, query, p.UserID, p.Timezone, offsets, p.WeekStart), &saved); err != nil {
+		return nil, fmt.Errorf("failed to save preferences: %w", err)
+	}
+	return &saved, nil
+}
+
+// DefaultReminders adds the reminders of their preferences to the events users create
+type DefaultReminders struct {
+	preferences PreferencesRepositoryInterface
+	reminders   ReminderRepositoryInterface
+}
+
+// NewDefaultReminders returns nil when push notifications are not configured, as the
+// default reminders are sent to the user's devices
+func NewDefaultReminders(preferences PreferencesRepositoryInterface, reminders ReminderRepositoryInterface, push *PushNotifier) *DefaultReminders {
+	if preferences == nil || reminders == nil || push == nil {
+		return nil
+	}
+	return &DefaultReminders{preferences: preferences, reminders: reminders}
+}
+
+// Register adds the reminders after every create by a user. Creates by the admin key
+// or without a caller, such as imports, get none.
+func (d *DefaultReminders) Register(hooks *EventHooks) {
+	hooks.AfterCreate(func(ctx context.Context, event EventDB) {
+		p := PrincipalFromContext(ctx)
+		if p == nil || p.Admin {
+			return
+		}
+		d.add(ctx, p.UserID, event, time.Now())
+	})
+}
+
+// add creates the default reminders of userID on event. The event already exists, so
+// failures are only logged.
+func (d *DefaultReminders) add(ctx context.Context, userID string, event EventDB, now time.Time) {
+	prefs, err := d.preferences.GetPreferences(ctx, userID)
+	if err != nil {
+		log.Printf("Error getting preferences of %s: %v", userID, err)
+		return
+	}
+	for _, offset := range prefs.ReminderOffsets {
+		r := Reminder{ID: uuid.New(), EventID: event.ID, OwnerID: userID, OffsetMinutes: offset, Channel: ReminderChannelPush}
+		if !r.RemindAt(event.StartTime).After(now) {
+			continue
+		}
+		if _, err := d.reminders.CreateReminder(ctx, r); err != nil {
+			log.Printf("Error adding default reminder to event %s: %v", event.ID, err)
+		}
+	}
+}
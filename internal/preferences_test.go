@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreferencesValidate(t *testing.T) {
+	p := DefaultPreferences("ana")
+	assert.Empty(t, p.Validate())
+
+	for _, tc := range []struct {
+		change func(p *Preferences)
+		want   string
+	}{
+		{func(p *Preferences) { p.Timezone = "Mars/Olympus" }, `unknown timezone "Mars/Olympus"`},
+		{func(p *Preferences) { p.Timezone = "Local" }, `unknown timezone "Local"`},
+		{func(p *Preferences) { p.ReminderOffsets = []int{1, 2, 3, 4, 5, 6} }, "at most 5 reminder_offsets"},
+		{func(p *Preferences) { p.ReminderOffsets = []int{-1} }, "reminder_offsets must be between 0 and 40320"},
+		{func(p *Preferences) { p.ReminderOffsets = []int{10, 10} }, "reminder_offsets must not repeat"},
+		{func(p *Preferences) { p.WeekStart = "friday" }, "week_start must be monday, sunday or saturday"},
+	} {
+		p := DefaultPreferences("ana")
+		tc.change(&p)
+		assert.Equal(t, tc.want, p.Validate())
+	}
+
+	p.Timezone = "Europe/Madrid"
+	assert.Equal(t, "Europe/Madrid", p.Location().String())
+}
+
+// savedPreferences serves GetPreferences from a map
+type savedPreferences map[string]Preferences
+
+func (s savedPreferences) GetPreferences(ctx context.Context, userID string) (*Preferences, error) {
+	p, ok := s[userID]
+	if !ok {
+		p = DefaultPreferences(userID)
+	}
+	return &p, nil
+}
+
+func (s savedPreferences) SavePreferences(ctx context.Context, p Preferences) (*Preferences, error) {
+	s[p.UserID] = p
+	return &p, nil
+}
+
+// createdReminders keeps the reminders created
+type createdReminders struct {
+	ReminderRepositoryInterface
+	created []Reminder
+}
+
+func (c *createdReminders) CreateReminder(ctx context.Context, r Reminder) (*Reminder, error) {
+	c.created = append(c.created, r)
+	return &r, nil
+}
+
+func TestDefaultReminders(t *testing.T) {
+	prefs := savedPreferences{"ana": {UserID: "ana", Timezone: "UTC", ReminderOffsets: []int{15, 24 * 60}, WeekStart: WeekStartMonday}}
+	reminders := &createdReminders{}
+	assert.Nil(t, NewDefaultReminders(prefs, reminders, nil))
+	defaults := NewDefaultReminders(prefs, reminders, &PushNotifier{})
+	hooks := NewEventHooks()
+	defaults.Register(hooks)
+	created := func(ctx context.Context, start time.Time) EventDB {
+		event := EventDB{ID: uuid.New(), StartTime: start}
+		for _, fn := range hooks.afterCreate {
+			fn(ctx, event)
+		}
+		return event
+	}
+
+	// Only the offsets still ahead are added, on the push channel
+	event := created(WithPrincipal(context.Background(), &Principal{UserID: "ana"}), time.Now().Add(time.Hour))
+	if assert.Len(t, reminders.created, 1) {
+		r := reminders.created[0]
+		assert.Equal(t, event.ID, r.EventID)
+		assert.Equal(t, "ana", r.OwnerID)
+		assert.Equal(t, 15, r.OffsetMinutes)
+		assert.Equal(t, ReminderChannelPush, r.Channel)
+	}
+
+	// Users without preferences, the admin key and imports get none
+	created(WithPrincipal(context.Background(), &Principal{UserID: "bob"}), time.Now().Add(48*time.Hour))
+	created(WithPrincipal(context.Background(), &Principal{UserID: "ana", Admin: true}), time.Now().Add(48*time.Hour))
+	created(context.Background(), time.Now().Add(48*time.Hour))
+	assert.Len(t, reminders.created, 1)
+}
@@ -0,0 +1,349 @@
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// OperationExportUserData is the operation writing the archive of a user's data
+const OperationExportUserData = "export_user_data"
+
+// UserExportRetention is how long the archive of a user's data can be downloaded
+const UserExportRetention = 7 * 24 * time.Hour
+
+// ErasedCalendarName replaces the name of the personal calendars of erased users
+const ErasedCalendarName = "Deleted calendar"
+
+// ErrExportNotFound is returned when an export does not exist, is not finished or
+// has expired
+var ErrExportNotFound = newDomainError(ErrNotFound, "export not found")
+
+// UserExportKey is the storage key of the archive written by an export operation
+func UserExportKey(operationID uuid.UUID) string {
+	return "user-exports/" + operationID.String() + ".zip"
+}
+
+// UserData is everything stored about a user. Events are those of the calendars they
+// own; reservations are the tickets they hold as an attendee.
+type UserData struct {
+	UserID       string              `json:"user_id"`
+	ExportedAt   time.Time           `json:"exported_at"`
+	Preferences  *Preferences        `json:"preferences"`
+	Digest       *DigestSubscription `json:"digest_subscription"`
+	Tokens       []APIToken          `json:"tokens"`
+	Memberships  []Membership        `json:"organization_memberships"`
+	Calendars    []Calendar          `json:"calendars"`
+	Events       []EventDB           `json:"events"`
+	Comments     []EventComment      `json:"comments"`
+	Reservations []TicketReservation `json:"ticket_reservations"`
+	Reminders    []Reminder          `json:"reminders"`
+}
+
+// EncodeUserDataArchive writes d as a zip of JSON files, one per kind of data
+func EncodeUserDataArchive(d UserData) ([]byte, error) {
+	profile := struct {
+		UserID      string              `json:"user_id"`
+		ExportedAt  time.Time           `json:"exported_at"`
+		Preferences *Preferences        `json:"preferences"`
+		Digest      *DigestSubscription `json:"digest_subscription"`
+		Tokens      []APIToken          `json:"tokens"`
+		Memberships []Membership        `json:"organization_memberships"`
+	}{d.UserID, d.ExportedAt, d.Preferences, d.Digest, d.Tokens, d.Memberships}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range []struct {
+		name string
+		data any
+	}{
+		{"profile.json", profile},
+		{"calendars.json", d.Calendars},
+		{"events.json", d.Events},
+		{"comments.json", d.Comments},
+		{"ticket_reservations.json", d.Reservations},
+		{"reminders.json", d.Reminders},
+	} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: d.ExportedAt})
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", file.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// userExportInput is the input of an export operation
+type userExportInput struct {
+	UserID string `json:"user_id"`
+}
+
+// NewUserExportInput returns the input of an operation exporting the data of userID
+func NewUserExportInput(userID string) ([]byte, error) {
+	return json.Marshal(userExportInput{UserID: userID})
+}
+
+// ExportUserDataOperation returns an operation writing the archive of a user's data to
+// storage under UserExportKey. Its result is the path the archive is downloaded from.
+func ExportUserDataOperation(repo PrivacyRepositoryInterface, storage Storage) OperationFunc {
+	return func(ctx context.Context, input []byte, tracker *OperationTracker) (string, error) {
+		var in userExportInput
+		if err := json.Unmarshal(input, &in); err != nil || in.UserID == "" {
+			return "", errors.New("invalid export input")
+		}
+		data, err := repo.ExportUserData(ctx, in.UserID)
+		if err != nil {
+			return "", err
+		}
+		archive, err := EncodeUserDataArchive(*data)
+		if err != nil {
+			return "", err
+		}
+		id := tracker.Progress().ID
+		if err := storage.Put(ctx, UserExportKey(id), archive); err != nil {
+			return "", fmt.Errorf("failed to store export: %w", err)
+		}
+		tracker.SetTotal(1)
+		tracker.Succeeded(1)
+		return "/me/export/" + id.String(), nil
+	}
+}
+
+// UserErasure reports what erasing a user changed. Rows counts the rows deleted or
+// anonymized by kind of data; Exports and Events are left for the caller to clean up
+// in storage, as the archives of the user's exports and the covers of their events.
+type UserErasure struct {
+	Rows    map[string]int `json:"rows"`
+	Exports []uuid.UUID    `json:"-"`
+	Events  []uuid.UUID    `json:"-"`
+}
+
+// personalCalendars selects the calendars userID ($1) owns outside any organization
+const personalCalendars = `SELECT id FROM calendars WHERE owner_id = $1 AND organization_id IS NULL`
+
+// userErasures are the statements erasing a user, given as $1, in order, after the
+// cascade engine handled their calendars, webhooks and reminders. Data only the user
+// has is deleted. Shared records are kept with the user's ID removed, so counts,
+// ticket quotas and stats stay right; the events of their personal calendars keep
+// their times but lose what described them, like the events of busy calendars.
+var userErasures = []struct {
+	kind, query string
+}{
+	{"events", `UPDATE events SET title = '` + BusyTitle + `', description = NULL, location = NULL, latitude = NULL, longitude = NULL WHERE calendar_id IN (` + personalCalendars + `)`},
+	{"archived_events", `UPDATE events_archive SET title = '` + BusyTitle + `', description = NULL, location = NULL, latitude = NULL, longitude = NULL WHERE calendar_id IN (` + personalCalendars + `)`},
+	{"snapshot_events", `UPDATE snapshot_events SET title = '` + BusyTitle + `', description = NULL, location = NULL, latitude = NULL, longitude = NULL WHERE calendar_id IN (` + personalCalendars + `)`},
+	{"calendars", `UPDATE calendars SET owner_id = '', name = CASE WHEN organization_id IS NULL THEN '` + ErasedCalendarName + `' ELSE name END WHERE owner_id = $1`},
+	{"submitted_events", `UPDATE events SET submitted_by = '' WHERE submitted_by = $1`},
+	{"comments", `UPDATE event_comments SET author = '', body = '', mentions = '{}' WHERE author = $1`},
+	{"mentions", `UPDATE event_comments SET mentions = array_remove(mentions, $1) WHERE $1 = ANY(mentions)`},
+	{"reviews", `UPDATE event_reviews SET reviewer = '' WHERE reviewer = $1`},
+	{"activity", `UPDATE event_activity SET actor = '' WHERE actor = $1`},
+	{"ticket_reservations", `UPDATE ticket_reservations SET holder_id = '' WHERE holder_id = $1`},
+	{"tokens", `DELETE FROM api_tokens WHERE user_id = $1`},
+	{"two_factor", `DELETE FROM user_two_factor WHERE user_id = $1`},
+	{"auth_failures", `DELETE FROM auth_failures WHERE key = 'account:2fa:' || $1::text`},
+	{"preferences", `DELETE FROM user_preferences WHERE user_id = $1`},
+	{"digest_subscriptions", `DELETE FROM digest_subscriptions WHERE user_id = $1`},
+	{"push_subscriptions", `DELETE FROM push_subscriptions WHERE user_id = $1`},
+	{"device_tokens", `DELETE FROM device_tokens WHERE user_id = $1`},
+	{"delegations", `DELETE FROM calendar_delegates WHERE user_id = $1`},
+	{"granted_delegations", `UPDATE calendar_delegates SET granted_by = '' WHERE granted_by = $1`},
+	{"memberships", `DELETE FROM organization_members WHERE user_id = $1`},
+	{"invitations", `UPDATE organization_invitations SET invited_by = CASE WHEN invited_by = $1 THEN '' ELSE invited_by END, accepted_by = CASE WHEN accepted_by = $1 THEN '' ELSE accepted_by END WHERE invited_by = $1 OR accepted_by = $1`},
+	{"organizations", `UPDATE organizations SET created_by = '' WHERE created_by = $1`},
+	{"schedules", `UPDATE schedules SET created_by = '' WHERE created_by = $1`},
+	{"snapshots", `UPDATE snapshots SET created_by = '' WHERE created_by = $1`},
+	{"operations", `UPDATE operations SET created_by = '', input = NULL WHERE created_by = $1`},
+	{"policy_rules", `UPDATE policy_rules SET created_by = '' WHERE created_by = $1`},
+	{"ingest_sources", `UPDATE ingest_sources SET created_by = '' WHERE created_by = $1`},
+	{"maintenance_mode", `UPDATE maintenance_mode SET updated_by = '' WHERE updated_by = $1`},
+}
+
+type PrivacyRepository struct {
+	db      *sql.DB
+	cipher  *FieldCipher
+	cascade *CascadeEngine
+}
+
+// NewPrivacyRepository creates a repository exporting and erasing the data of users.
+// cipher decrypts event fields encrypted at rest and may be nil.
+func NewPrivacyRepository(db *sql.DB, cipher *FieldCipher) *PrivacyRepository {
+	return &PrivacyRepository{db: db, cipher: cipher, cascade: NewCascadeEngine(db, nil)}
+}
+
+// SetCascade handles the calendars, webhooks and reminders of erased users by the
+// policies of cascade rather than the defaults
+func (r *PrivacyRepository) SetCascade(cascade *CascadeEngine) {
+	r.cascade = cascade
+}
+
+// queryAll runs a query selecting the columns scan reads and collects the rows
+func queryAll[T any](ctx context.Context, db *sql.DB, scan func(rowScanner, *T) error, query string, args ...any) ([]T, error) {
+	rows, err := conn(ctx, db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []T{}
+	for rows.Next() {
+		var v T
+		if err := scan(rows, &v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// ExportUserData reads everything stored about userID in one snapshot of the database
+func (r *PrivacyRepository) ExportUserData(ctx context.Context, userID string) (*UserData, error) {
+	d := &UserData{UserID: userID, ExportedAt: time.Now().UTC()}
+	err := NewTxManager(r.db).InTx(ctx, func(ctx context.Context) error {
+		if _, err := conn(ctx, r.db).ExecContext(ctx, `SET TRANSACTION ISOLATION LEVEL REPEATABLE READ, READ ONLY`); err != nil {
+			return fmt.Errorf("failed to start export: %w", err)
+		}
+
+		var prefs Preferences
+		err := scanPreferences(conn(ctx, r.db).QueryRowContext(ctx, `SELECT `+preferencesColumns+` FROM user_preferences WHERE user_id = $1`, userID), &prefs)
+		if errors.Is(err, sql.ErrNoRows) {
+			prefs = DefaultPreferences(userID)
+		} else if err != nil {
+			return fmt.Errorf("failed to export preferences: %w", err)
+		}
+		d.Preferences = &prefs
+
+		var digest DigestSubscription
+		err = scanDigestSubscription(conn(ctx, r.db).QueryRowContext(ctx, `SELECT `+digestColumns+` FROM digest_subscriptions WHERE user_id = $1`, userID), &digest)
+		if err == nil {
+			d.Digest = &digest
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to export digest subscription: %w", err)
+		}
+
+		if d.Tokens, err = queryAll(ctx, r.db, scanToken, `SELECT `+tokenColumns+` FROM api_tokens WHERE user_id = $1 ORDER BY created_at`, userID); err != nil {
+			return fmt.Errorf("failed to export tokens: %w", err)
+		}
+		if d.Memberships, err = queryAll(ctx, r.db, scanMembership, `SELECT `+membershipColumns+` FROM organization_members WHERE user_id = $1 ORDER BY created_at`, userID); err != nil {
+			return fmt.Errorf("failed to export memberships: %w", err)
+		}
+		if d.Calendars, err = queryAll(ctx, r.db, scanCalendar, `SELECT `+calendarColumns+` FROM calendars WHERE owner_id = $1 ORDER BY name`, userID); err != nil {
+			return fmt.Errorf("failed to export calendars: %w", err)
+		}
+		query := `SELECT ` + eventColumns + ` FROM events WHERE calendar_id IN (SELECT id FROM calendars WHERE owner_id = $1) ORDER BY start_time, id`
+		if d.Events, err = queryAll(ctx, r.db, scanEvent, query, userID); err != nil {
+			return fmt.Errorf("failed to export events: %w", err)
+		}
+		for i := range d.Events {
+			if err := r.cipher.decryptOptional(d.Events[i].Description); err != nil {
+				return fmt.Errorf("failed to decrypt event %s: %w", d.Events[i].ID, err)
+			}
+			if err := r.cipher.decryptOptional(d.Events[i].Location); err != nil {
+				return fmt.Errorf("failed to decrypt event %s: %w", d.Events[i].ID, err)
+			}
+		}
+		if d.Comments, err = queryAll(ctx, r.db, scanComment, `SELECT `+commentColumns+` FROM event_comments WHERE author = $1 ORDER BY created_at`, userID); err != nil {
+			return fmt.Errorf("failed to export comments: %w", err)
+		}
+		if d.Reservations, err = queryAll(ctx, r.db, scanReservation, `SELECT `+reservationColumns+` FROM ticket_reservations WHERE holder_id = $1 ORDER BY created_at`, userID); err != nil {
+			return fmt.Errorf("failed to export ticket reservations: %w", err)
+		}
+		if d.Reminders, err = queryAll(ctx, r.db, scanReminder, `SELECT `+reminderColumns+` FROM event_reminders WHERE owner_id = $1 ORDER BY created_at`, userID); err != nil {
+			return fmt.Errorf("failed to export reminders: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// EraseUserData deletes or anonymizes everything stored about userID in one
+// transaction. It fails with ErrLastOwner, erasing nothing, when the user is the only
+// owner of an organization, and with a DependentsError when a delete policy forbids
+// erasing their calendars, webhooks or reminders.
+func (r *PrivacyRepository) EraseUserData(ctx context.Context, userID string) (*UserErasure, error) {
+	erasure := &UserErasure{Rows: map[string]int{}}
+	err := NewTxManager(r.db).JoinTx(ctx, func(ctx context.Context) error {
+		// Lock the organizations the user owns, so concurrent changes cannot each remove
+		// a different last owner
+		var owned []string
+		rows, err := conn(ctx, r.db).QueryContext(ctx, `
+			SELECT id FROM organizations
+			WHERE id IN (SELECT organization_id FROM organization_members WHERE user_id = $1 AND role = 'owner')
+			ORDER BY id FOR UPDATE`, userID)
+		if err != nil {
+			return fmt.Errorf("failed to lock organizations: %w", err)
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan organization: %w", err)
+			}
+			owned = append(owned, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to lock organizations: %w", err)
+		}
+
+		scanID := func(row rowScanner, id *uuid.UUID) error { return row.Scan(id) }
+		if erasure.Exports, err = queryAll(ctx, r.db, scanID, `SELECT id FROM operations WHERE created_by = $1 AND kind = $2 AND status = $3`, userID, OperationExportUserData, OperationSucceeded); err != nil {
+			return fmt.Errorf("failed to list exports: %w", err)
+		}
+		if erasure.Events, err = queryAll(ctx, r.db, scanID, `SELECT id FROM events WHERE calendar_id IN (`+personalCalendars+`)`, userID); err != nil {
+			return fmt.Errorf("failed to list events: %w", err)
+		}
+
+		counts, err := r.cascade.DeleteDependents(ctx, CascadeUser, []string{userID})
+		if err != nil {
+			return err
+		}
+		for kind, n := range counts {
+			erasure.Rows[kind] += n
+		}
+		for _, e := range userErasures {
+			res, err := conn(ctx, r.db).ExecContext(ctx, e.query, userID)
+			if err != nil {
+				return fmt.Errorf("failed to erase %s: %w", e.kind, err)
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				erasure.Rows[e.kind] += int(n)
+			}
+		}
+
+		var ownerless bool
+		query := `SELECT EXISTS (
+			SELECT 1 FROM organizations o
+			WHERE o.id = ANY($1::uuid[]) AND NOT EXISTS (SELECT 1 FROM organization_members m WHERE m.organization_id = o.id AND m.role = 'owner')
+		)`
+		if err := conn(ctx, r.db).QueryRowContext(ctx, query, pq.Array(owned)).Scan(&ownerless); err != nil {
+			return fmt.Errorf("failed to count owners: %w", err)
+		}
+		if ownerless {
+			return ErrLastOwner
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return erasure, nil
+}
@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exportedUser serves ExportUserData with fixed data
+type exportedUser struct {
+	PrivacyRepositoryInterface
+	data UserData
+}
+
+func (e *exportedUser) ExportUserData(ctx context.Context, userID string) (*UserData, error) {
+	d := e.data
+	d.UserID = userID
+	return &d, nil
+}
+
+func TestExportUserDataOperation(t *testing.T) {
+	prefs := DefaultPreferences("ana")
+	description := "Bring the slides"
+	repo := &exportedUser{data: UserData{
+		ExportedAt:   time.Date(2025, 10, 7, 12, 0, 0, 0, time.UTC),
+		Preferences:  &prefs,
+		Calendars:    []Calendar{{ID: uuid.New(), Name: "Personal", OwnerID: "ana"}},
+		Events:       []EventDB{{ID: uuid.New(), Title: "Review", Description: &description}},
+		Comments:     []EventComment{{ID: uuid.New(), Author: "ana", Body: "See you there"}},
+		Reservations: []TicketReservation{},
+		Reminders:    []Reminder{},
+	}}
+	storage := &LocalStorage{Dir: t.TempDir()}
+	op := Operation{ID: uuid.New()}
+	tracker := &OperationTracker{repo: &savedProgress{}, op: op}
+
+	input, err := NewUserExportInput("ana")
+	require.NoError(t, err)
+	result, err := ExportUserDataOperation(repo, storage)(context.Background(), input, tracker)
+	require.NoError(t, err)
+	assert.Equal(t, "/me/export/"+op.ID.String(), result)
+	assert.Equal(t, 1, tracker.Progress().Succeeded)
+
+	archive, err := storage.Get(context.Background(), UserExportKey(op.ID))
+	require.NoError(t, err)
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		files[f.Name], err = io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+	}
+	assert.Len(t, files, 6)
+	var profile map[string]any
+	require.NoError(t, json.Unmarshal(files["profile.json"], &profile))
+	assert.Equal(t, "ana", profile["user_id"])
+	assert.Contains(t, string(files["events.json"]), "Bring the slides")
+	assert.Contains(t, string(files["comments.json"]), "See you there")
+	assert.JSONEq(t, `[]`, string(files["reminders.json"]))
+
+	_, err = ExportUserDataOperation(repo, storage)(context.Background(), []byte(`{}`), tracker)
+	assert.EqualError(t, err, "invalid export input")
+}
+
+func TestUserErasures(t *testing.T) {
+	// Every statement takes the user as its only parameter
+	kinds := map[string]bool{}
+	for _, e := range userErasures {
+		assert.False(t, kinds[e.kind], e.kind)
+		kinds[e.kind] = true
+		assert.Contains(t, e.query, "$1", e.kind)
+		assert.NotContains(t, e.query, "$2", e.kind)
+	}
+	// Events are anonymized while their personal calendars can still be told apart
+	assert.Less(t, indexOfErasure("events"), indexOfErasure("calendars"))
+	assert.Less(t, indexOfErasure("snapshot_events"), indexOfErasure("calendars"))
+}
+
+func indexOfErasure(kind string) int {
+	for i, e := range userErasures {
+		if e.kind == kind {
+			return i
+		}
+	}
+	return -1
+}
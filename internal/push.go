@@ -0,0 +1,435 @@
+package internal
+
+import (
+	"context"
+	"crypto/ecdh"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// pushTTL is how long push services keep a notification for an offline browser;
+// later than that a reminder is no longer useful
+const pushTTL = time.Hour
+
+// ErrPushSubscriptionNotFound is returned when a push subscription does not exist
+var ErrPushSubscriptionNotFound = newDomainError(ErrNotFound, "push subscription not found")
+
+// ErrNoPushSubscriptions is returned when a user has no browser or device to notify
+var ErrNoPushSubscriptions = errors.New("no push subscriptions or devices")
+
+// PushSubscription is a browser registered for a user's push notifications, as
+// returned by PushManager.subscribe in the browser
+type PushSubscription struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    string    `json:"user_id"`
+	Endpoint  string    `json:"endpoint"`
+	Keys      PushKeys  `json:"keys"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PushKeys are the browser's message encryption keys, base64url
+type PushKeys struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+// Validate returns a client-facing message when the keys cannot encrypt messages, or ""
+func (k PushKeys) Validate() string {
+	pub, err := base64.RawURLEncoding.DecodeString(k.P256dh)
+	if err == nil {
+		_, err = ecdh.P256().NewPublicKey(pub)
+	}
+	if err != nil {
+		return "keys.p256dh must be a base64url P-256 public key"
+	}
+	if auth, err := base64.RawURLEncoding.DecodeString(k.Auth); err != nil || len(auth) != 16 {
+		return "keys.auth must be a 16-byte base64url secret"
+	}
+	return ""
+}
+
+func (k PushKeys) decode() (public, auth []byte, err error) {
+	if public, err = base64.RawURLEncoding.DecodeString(k.P256dh); err != nil {
+		return nil, nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	if auth, err = base64.RawURLEncoding.DecodeString(k.Auth); err != nil {
+		return nil, nil, fmt.Errorf("invalid auth secret: %w", err)
+	}
+	return public, auth, nil
+}
+
+// PushMessage is the JSON payload the service worker turns into a notification
+type PushMessage struct {
+	Title   string     `json:"title"`
+	Body    string     `json:"body"`
+	EventID *uuid.UUID `json:"event_id,omitempty"`
+	// Changes are the fields of the event an update changed
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+type PushSubscriptionRepository struct {
+	db *sql.DB
+}
+
+// NewPushSubscriptionRepository creates a new push subscription repository
+func NewPushSubscriptionRepository(db *sql.DB) *PushSubscriptionRepository {
+	return &PushSubscriptionRepository{db: db}
+}
+
+const pushSubscriptionColumns = `id, user_id, endpoint, p256dh, auth, user_agent, created_at, updated_at`
+
+func scanPushSubscription(row rowScanner, s *PushSubscription) error {
+	return row.Scan(&s.ID, &s.UserID, &s.Endpoint, &s.Keys.P256dh, &s.Keys.Auth, &s.UserAgent, &s.CreatedAt, &s.UpdatedAt)
+}
+
+// SavePushSubscription registers a browser. A browser registering its endpoint again,
+// for example after its keys changed or another user signed in, replaces the old
+// registration.
+func (r *PushSubscriptionRepository) SavePushSubscription(ctx context.Context, s PushSubscription) (*PushSubscription, error) {
+	query := `
+		INSERT INTO push_subscriptions (id, user_id, endpoint, p256dh, auth, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (endpoint) DO UPDATE
+		SET user_id = EXCLUDED.user_id, p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth,
+			user_agent = EXCLUDED.user_agent, updated_at = NOW()
+		RETURNING ` + pushSubscriptionColumns
+
+	var saved PushSubscription
+	row := traced(ctx, r.db).QueryRowContext(ctx, query, s.ID, s.UserID, s.Endpoint, s.Keys.P256dh, s.Keys.Auth, s.UserAgent)
+	if err := scanPushSubscription(row, &saved); err != nil {
+		return nil, fmt.Errorf("failed to save push subscription: %w", err)
+	}
+	return &saved, nil
+}
+
+// ListPushSubscriptions returns a user's browsers, oldest first
+func (r *PushSubscriptionRepository) ListPushSubscriptions(ctx context.Context, userID string) ([]PushSubscription, error) {
+	query := `SELECT ` + pushSubscriptionColumns + ` FROM push_subscriptions WHERE user_id = $1 ORDER BY created_at, id`
+	rows, err := traced(ctx, r.db).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query push subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []PushSubscription{}
+	for rows.Next() {
+		var s PushSubscription
+		if err := scanPushSubscription(rows, &s); err != nil {
+			return nil, fmt.Errorf("failed to scan push subscription: %w", err)
+		}
+		subs = append(subs, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating push subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+// DeletePushSubscription unregisters one of a user's browsers
+func (r *PushSubscriptionRepository) DeletePushSubscription(ctx context.Context, userID string, id uuid.UUID) error {
+	res, err := traced(ctx, r.db).ExecContext(ctx, `DELETE FROM push_subscriptions WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete push subscription: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrPushSubscriptionNotFound
+	}
+	return nil
+}
+
+// DeletePushEndpoint removes a subscription the push service reported as gone
+func (r *PushSubscriptionRepository) DeletePushEndpoint(ctx context.Context, endpoint string) error {
+	if _, err := traced(ctx, r.db).ExecContext(ctx, `DELETE FROM push_subscriptions WHERE endpoint = $1`, endpoint); err != nil {
+		return fmt.Errorf("failed to delete push subscription: %w", err)
+	}
+	return nil
+}
+
+// ErrDeviceTokenNotFound is returned when a device token does not exist
+var ErrDeviceTokenNotFound = newDomainError(ErrNotFound, "device token not found")
+
+// DeviceToken is a mobile device registered for a user's push notifications
+type DeviceToken struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    string    `json:"user_id"`
+	Platform  string    `json:"platform"`
+	Token     string    `json:"token"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type DeviceTokenRepository struct {
+	db *sql.DB
+}
+
+// NewDeviceTokenRepository creates a new device token repository
+func NewDeviceTokenRepository(db *sql.DB) *DeviceTokenRepository {
+	return &DeviceTokenRepository{db: db}
+}
+
+const deviceTokenColumns = `id, user_id, platform, token, created_at, updated_at`
+
+func scanDeviceToken(row rowScanner, d *DeviceToken) error {
+	return row.Scan(&d.ID, &d.UserID, &d.Platform, &d.Token, &d.CreatedAt, &d.UpdatedAt)
+}
+
+// SaveDeviceToken registers a device. A token registered again, for example after
+// another user signed in to the app, moves to the new user.
+func (r *DeviceTokenRepository) SaveDeviceToken(ctx context.Context, d DeviceToken) (*DeviceToken, error) {
+	query := `
+		INSERT INTO device_tokens (id, user_id, platform, token)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (platform, token) DO UPDATE
+		SET user_id = EXCLUDED.user_id, updated_at = NOW()
+		RETURNING ` + deviceTokenColumns
+
+	var saved DeviceToken
+	row := traced(ctx, r.db).QueryRowContext(ctx, query, d.ID, d.UserID, d.Platform, d.Token)
+	if err := scanDeviceToken(row, &saved); err != nil {
+		return nil, fmt.Errorf("failed to save device token: %w", err)
+	}
+	return &saved, nil
+}
+
+// ListDeviceTokens returns a user's devices, oldest first
+func (r *DeviceTokenRepository) ListDeviceTokens(ctx context.Context, userID string) ([]DeviceToken, error) {
+	query := `SELECT ` + deviceTokenColumns + ` FROM device_tokens WHERE user_id = $1 ORDER BY created_at, id`
+	rows, err := traced(ctx, r.db).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query device tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []DeviceToken{}
+	for rows.Next() {
+		var d DeviceToken
+		if err := scanDeviceToken(rows, &d); err != nil {
+			return nil, fmt.Errorf("failed to scan device token: %w", err)
+		}
+		tokens = append(tokens, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating device tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// DeleteDeviceToken unregisters one of a user's devices
+func (r *DeviceTokenRepository) DeleteDeviceToken(ctx context.Context, userID string, id uuid.UUID) error {
+	res, err := traced(ctx, r.db).ExecContext(ctx, `DELETE FROM device_tokens WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete device token: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrDeviceTokenNotFound
+	}
+	return nil
+}
+
+// InvalidateDeviceToken removes a token its provider reported as no longer valid
+func (r *DeviceTokenRepository) InvalidateDeviceToken(ctx context.Context, platform, token string) error {
+	if _, err := traced(ctx, r.db).ExecContext(ctx, `DELETE FROM device_tokens WHERE platform = $1 AND token = $2`, platform, token); err != nil {
+		return fmt.Errorf("failed to delete device token: %w", err)
+	}
+	return nil
+}
+
+// PushNotifier sends push notifications to every browser and mobile device of a user
+type PushNotifier struct {
+	subscriptions PushSubscriptionRepositoryInterface
+	webPush       *WebPush
+	devices       DeviceTokenRepositoryInterface
+	providers     PushProviders
+}
+
+// NewPushNotifier sends to browsers through webPush and to mobile devices through
+// providers. Either side may be left nil; it returns nil when neither is configured,
+// so callers can leave the push channel out.
+func NewPushNotifier(subscriptions PushSubscriptionRepositoryInterface, webPush *WebPush, devices DeviceTokenRepositoryInterface, providers PushProviders) *PushNotifier {
+	p := &PushNotifier{}
+	if subscriptions != nil && webPush != nil {
+		p.subscriptions, p.webPush = subscriptions, webPush
+	}
+	if devices != nil && len(providers) > 0 {
+		p.devices, p.providers = devices, providers
+	}
+	if p.webPush == nil && p.providers == nil {
+		return nil
+	}
+	return p
+}
+
+// NotifyUser sends msg to the user's browsers and devices. Subscriptions and tokens
+// reported as gone are deleted. It fails when nothing received the message.
+func (p *PushNotifier) NotifyUser(ctx context.Context, userID string, msg PushMessage) error {
+	delivered := 0
+	var errs []error
+	if p.webPush != nil {
+		n, err := p.notifyBrowsers(ctx, userID, msg)
+		delivered += n
+		errs = append(errs, err...)
+	}
+	if p.providers != nil {
+		n, err := p.notifyDevices(ctx, userID, msg)
+		delivered += n
+		errs = append(errs, err...)
+	}
+
+	if delivered > 0 {
+		for _, err := range errs {
+			log.Printf("Error sending push notification to %s: %v", userID, err)
+		}
+		return nil
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return ErrNoPushSubscriptions
+}
+
+func (p *PushNotifier) notifyBrowsers(ctx context.Context, userID string, msg PushMessage) (int, []error) {
+	subs, err := p.subscriptions.ListPushSubscriptions(ctx, userID)
+	if err != nil {
+		return 0, []error{err}
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return 0, []error{err}
+	}
+
+	delivered := 0
+	var errs []error
+	for _, sub := range subs {
+		err := p.webPush.Send(ctx, sub, payload, pushTTL)
+		switch {
+		case errors.Is(err, ErrPushSubscriptionGone):
+			if err := p.subscriptions.DeletePushEndpoint(ctx, sub.Endpoint); err != nil {
+				log.Printf("Error deleting gone push subscription %s: %v", sub.ID, err)
+			}
+		case err != nil:
+			errs = append(errs, fmt.Errorf("subscription %s: %w", sub.ID, err))
+		default:
+			delivered++
+		}
+	}
+	return delivered, errs
+}
+
+func (p *PushNotifier) notifyDevices(ctx context.Context, userID string, msg PushMessage) (int, []error) {
+	tokens, err := p.devices.ListDeviceTokens(ctx, userID)
+	if err != nil {
+		return 0, []error{err}
+	}
+
+	delivered := 0
+	var errs []error
+	for _, d := range tokens {
+		provider, ok := p.providers[d.Platform]
+		if !ok {
+			continue
+		}
+		err := provider.Push(ctx, d.Token, msg)
+		switch {
+		case errors.Is(err, ErrDeviceTokenInvalid):
+			if err := p.devices.InvalidateDeviceToken(ctx, d.Platform, d.Token); err != nil {
+				log.Printf("Error deleting invalid device token %s: %v", d.ID, err)
+			}
+		case err != nil:
+			errs = append(errs, fmt.Errorf("device %s: %w", d.ID, err))
+		default:
+			delivered++
+		}
+	}
+	return delivered, errs
+}
+
+// PushReminderSender sends reminders as push notifications to the owner's browsers and
+// devices
+type PushReminderSender struct {
+	Push *PushNotifier
+}
+
+func (s *PushReminderSender) SendReminder(ctx context.Context, r Reminder, event EventDB) error {
+	msg := ComposeReminder(r, event)
+	return s.Push.NotifyUser(ctx, r.OwnerID, PushMessage{Title: msg.Subject, Body: msg.Text, EventID: &event.ID})
+}
+
+// changeNotifyTimeout bounds the change notifications of one update, which are sent
+// after the write
+const changeNotifyTimeout = 30 * time.Second
+
+// EventChangeNotifier pushes updates of an event to the users who set a reminder on
+// it, so they learn when it moves. The user who made the change is not notified.
+type EventChangeNotifier struct {
+	reminders ReminderRepositoryInterface
+	push      *PushNotifier
+}
+
+// NewEventChangeNotifier returns nil when push notifications are not configured
+func NewEventChangeNotifier(reminders ReminderRepositoryInterface, push *PushNotifier) *EventChangeNotifier {
+	if reminders == nil || push == nil {
+		return nil
+	}
+	return &EventChangeNotifier{reminders: reminders, push: push}
+}
+
+// Register notifies after every update that changed the event. Notifications are sent
+// in the background so the write is not held up by the push services.
+func (n *EventChangeNotifier) Register(hooks *EventHooks) {
+	hooks.AfterChange(func(ctx context.Context, before *EventDB, after EventDB) {
+		if !after.Published() {
+			return
+		}
+		var changes []FieldChange
+		if before != nil {
+			if changes = DiffEvents(*before, after); len(changes) == 0 {
+				return
+			}
+		}
+		notifyCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), changeNotifyTimeout)
+		go func() {
+			defer cancel()
+			n.NotifyChange(notifyCtx, after, changes)
+		}()
+	})
+}
+
+// NotifyChange pushes the event's new time and the fields that changed, when known, to
+// each user with a reminder on it, in the language of their first reminder. Failures
+// are only logged.
+func (n *EventChangeNotifier) NotifyChange(ctx context.Context, event EventDB, changes []FieldChange) {
+	reminders, err := n.reminders.ListReminders(ctx, event.ID)
+	if err != nil {
+		log.Printf("Error listing reminders of changed event %s: %v", event.ID, err)
+		return
+	}
+
+	author := actor(ctx)
+	notified := map[string]bool{}
+	for _, r := range reminders {
+		if notified[r.OwnerID] || (author != "" && r.OwnerID == author) {
+			continue
+		}
+		notified[r.OwnerID] = true
+
+		lang := r.Language
+		msg := PushMessage{
+			Title:   Translate(lang, "%s was changed", event.Title),
+			Body:    Translate(lang, "%s starts on %s.", event.Title, FormatDate(lang, event.StartTime)),
+			EventID: &event.ID,
+			Changes: changes,
+		}
+		if err := n.push.NotifyUser(ctx, r.OwnerID, msg); err != nil && !errors.Is(err, ErrNoPushSubscriptions) {
+			log.Printf("Error notifying %s of the change to event %s: %v", r.OwnerID, event.ID, err)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mobile push platforms
+const (
+	PlatformFCM  = "fcm"
+	PlatformAPNs = "apns"
+)
+
+// ErrDeviceTokenInvalid is returned by a provider when a device token will never work
+// again, because the app was uninstalled or the token belongs to another app. The
+// token should be deleted.
+var ErrDeviceTokenInvalid = errors.New("device token is no longer valid")
+
+// PushProvider delivers notifications to the mobile devices of one platform
+type PushProvider interface {
+	Push(ctx context.Context, token string, msg PushMessage) error
+}
+
+// PushProviders maps each platform to its provider
+type PushProviders map[string]PushProvider
+
+// NewPushProviders returns the providers configured in cfg; none when FCM and APNs
+// are not set up
+func NewPushProviders(cfg Config) (PushProviders, error) {
+	providers := PushProviders{}
+	if cfg.FCMCredentialsFile != "" {
+		fcm, err := NewFCMProvider(cfg.FCMCredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("FCM: %w", err)
+		}
+		providers[PlatformFCM] = fcm
+	}
+	if cfg.APNsKeyFile != "" {
+		apns, err := NewAPNsProvider(cfg.APNsKeyFile, cfg.APNsKeyID, cfg.APNsTeamID, cfg.APNsTopic, cfg.APNsSandbox)
+		if err != nil {
+			return nil, fmt.Errorf("APNs: %w", err)
+		}
+		providers[PlatformAPNs] = apns
+	}
+	return providers, nil
+}
+
+// signJWT returns the compact JWT of claims signed with key, RS256 for RSA keys and
+// ES256 for P-256 keys
+func signJWT(header, claims map[string]any, key crypto.Signer) (string, error) {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, signErr := ecdsa.Sign(rand.Reader, k, digest[:])
+		sig, err = make([]byte, 64), signErr
+		if err == nil {
+			r.FillBytes(sig[:32])
+			s.FillBytes(sig[32:])
+		}
+	default:
+		err = fmt.Errorf("unsupported key type %T", key)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// parsePKCS8PEM reads the PKCS#8 private key of a PEM file, as issued by Google for
+// service accounts and by Apple for APNs
+func parsePKCS8PEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM private key found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return signer, nil
+}
+
+// FCMProvider sends to Android and web apps through the Firebase Cloud Messaging HTTP
+// v1 API, authenticating with a service account
+type FCMProvider struct {
+	projectID   string
+	clientEmail string
+	keyID       string
+	tokenURI    string
+	key         crypto.Signer
+	// BaseURL is the FCM API, replaced in tests
+	BaseURL string
+	Client  *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expires     time.Time
+}
+
+// fcmServiceAccount is the part of a service account JSON key FCM needs
+type fcmServiceAccount struct {
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// NewFCMProvider reads a service account JSON key downloaded from the Firebase console
+func NewFCMProvider(credentialsFile string) (*FCMProvider, error) {
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	var sa fcmServiceAccount
+	if err := json.Unmarshal(data, &sa); err != nil {
+		return nil, fmt.Errorf("invalid service account file: %w", err)
+	}
+	if sa.ProjectID == "" || sa.ClientEmail == "" || sa.TokenURI == "" {
+		return nil, errors.New("service account file lacks project_id, client_email or token_uri")
+	}
+	key, err := parsePKCS8PEM([]byte(sa.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+	return &FCMProvider{
+		projectID:   sa.ProjectID,
+		clientEmail: sa.ClientEmail,
+		keyID:       sa.PrivateKeyID,
+		tokenURI:    sa.TokenURI,
+		key:         key,
+		BaseURL:     "https://fcm.googleapis.com",
+		Client:      &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// fcmError is the error body of the FCM API
+type fcmError struct {
+	Error struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func (f *FCMProvider) Push(ctx context.Context, token string, msg PushMessage) error {
+	accessToken, err := f.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	message := map[string]any{
+		"token":        token,
+		"notification": map[string]string{"title": msg.Title, "body": msg.Body},
+	}
+	// FCM data values are strings, so the changes travel as JSON text
+	data := map[string]string{}
+	if msg.EventID != nil {
+		data["event_id"] = msg.EventID.String()
+	}
+	if len(msg.Changes) > 0 {
+		changes, err := json.Marshal(msg.Changes)
+		if err != nil {
+			return err
+		}
+		data["changes"] = string(changes)
+	}
+	if len(data) > 0 {
+		message["data"] = data
+	}
+	body, err := json.Marshal(map[string]any{"message": message})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.BaseURL+"/v1/projects/"+f.projectID+"/messages:send", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("FCM unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	var fe fcmError
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&fe)
+	for _, d := range fe.Error.Details {
+		// UNREGISTERED: the app was uninstalled; SENDER_ID_MISMATCH: another project's token
+		if d.ErrorCode == "UNREGISTERED" || d.ErrorCode == "SENDER_ID_MISMATCH" {
+			return ErrDeviceTokenInvalid
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrDeviceTokenInvalid
+	}
+	return fmt.Errorf("FCM returned %s: %s", resp.Status, fe.Error.Message)
+}
+
+// token returns an OAuth access token for the service account, exchanging a signed
+// assertion for a new one shortly before the current one expires
+func (f *FCMProvider) token(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	if f.accessToken != "" && now.Before(f.expires) {
+		return f.accessToken, nil
+	}
+
+	assertion, err := signJWT(
+		map[string]any{"alg": "RS256", "typ": "JWT", "kid": f.keyID},
+		map[string]any{
+			"iss":   f.clientEmail,
+			"scope": "https://www.googleapis.com/auth/firebase.messaging",
+			"aud":   f.tokenURI,
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Hour).Unix(),
+		},
+		f.key,
+	)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("FCM token exchange failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("FCM token exchange returned %s", resp.Status)
+	}
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || out.AccessToken == "" {
+		return "", errors.New("FCM token exchange returned no access token")
+	}
+
+	f.accessToken = out.AccessToken
+	f.expires = now.Add(time.Duration(out.ExpiresIn)*time.Second - time.Minute)
+	return f.accessToken, nil
+}
+
+// apnsTokenRefresh is how long an APNs provider token is reused. Apple rejects tokens
+// older than an hour and refreshing more often than every 20 minutes.
+const apnsTokenRefresh = 50 * time.Minute
+
+// APNsProvider sends to iOS apps through the Apple Push Notification service with
+// token-based authentication
+type APNsProvider struct {
+	key    *ecdsa.PrivateKey
+	keyID  string
+	teamID string
+	topic  string
+	// Host is the APNs server: production, sandbox, or a test server
+	Host   string
+	Client *http.Client
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+// NewAPNsProvider reads the .p8 signing key created in the Apple developer account.
+// topic is the app's bundle ID; sandbox targets development builds of the app.
+func NewAPNsProvider(keyFile, keyID, teamID, topic string, sandbox bool) (*APNsProvider, error) {
+	if keyID == "" || teamID == "" || topic == "" {
+		return nil, errors.New("APNS_KEY_ID, APNS_TEAM_ID and APNS_TOPIC are required")
+	}
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := parsePKCS8PEM(data)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := signer.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("APNs signing key must be an EC key")
+	}
+	host := "https://api.push.apple.com"
+	if sandbox {
+		host = "https://api.sandbox.push.apple.com"
+	}
+	return &APNsProvider{
+		key:    key,
+		keyID:  keyID,
+		teamID: teamID,
+		topic:  topic,
+		Host:   host,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (a *APNsProvider) Push(ctx context.Context, token string, msg PushMessage) error {
+	bearer, err := a.providerToken(time.Now())
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]any{
+		"aps": map[string]any{
+			"alert": map[string]string{"title": msg.Title, "body": msg.Body},
+			"sound": "default",
+		},
+	}
+	if msg.EventID != nil {
+		payload["event_id"] = msg.EventID.String()
+	}
+	if len(msg.Changes) > 0 {
+		payload["changes"] = msg.Changes
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.Host+"/3/device/"+url.PathEscape(token), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+bearer)
+	req.Header.Set("apns-topic", a.topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+	req.Header.Set("apns-expiration", fmt.Sprint(time.Now().Add(pushTTL).Unix()))
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("APNs unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	var ae struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&ae)
+	switch {
+	case resp.StatusCode == http.StatusGone,
+		ae.Reason == "BadDeviceToken", ae.Reason == "Unregistered", ae.Reason == "DeviceTokenNotForTopic":
+		return ErrDeviceTokenInvalid
+	}
+	return fmt.Errorf("APNs returned %s: %s", resp.Status, ae.Reason)
+}
+
+// providerToken returns the ES256 JWT authenticating this server to APNs
+func (a *APNsProvider) providerToken(now time.Time) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && now.Sub(a.issuedAt) < apnsTokenRefresh {
+		return a.token, nil
+	}
+	token, err := signJWT(
+		map[string]any{"alg": "ES256", "kid": a.keyID},
+		map[string]any{"iss": a.teamID, "iat": now.Unix()},
+		a.key,
+	)
+	if err != nil {
+		return "", err
+	}
+	a.token, a.issuedAt = token, now
+	return token, nil
+}
@@ -0,0 +1,252 @@
+package internal
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePKCS8 writes key as a PKCS#8 PEM file and returns its contents and path
+func writePKCS8(t *testing.T, key any) (string, string) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	data := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	return data, path
+}
+
+func TestFCMProvider(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pemKey, _ := writePKCS8(t, key)
+
+	exchanges := 0
+	var sent map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			exchanges++
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.PostForm.Get("grant_type"))
+			assert.Len(t, strings.Split(r.PostForm.Get("assertion"), "."), 3)
+			w.Write([]byte(`{"access_token": "ya29.token", "expires_in": 3600}`))
+		case "/v1/projects/calendar-app/messages:send":
+			assert.Equal(t, "Bearer ya29.token", r.Header.Get("Authorization"))
+			var body map[string]map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["message"]["token"] == "uninstalled" {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error": {"status": "NOT_FOUND", "details": [{"errorCode": "UNREGISTERED"}]}}`))
+				return
+			}
+			if body["message"]["token"] == "throttled" {
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(`{"error": {"status": "RESOURCE_EXHAUSTED", "message": "quota exceeded"}}`))
+				return
+			}
+			sent = body["message"]
+			w.Write([]byte(`{"name": "projects/calendar-app/messages/1"}`))
+		}
+	}))
+	defer server.Close()
+
+	creds, _ := json.Marshal(fcmServiceAccount{
+		ProjectID: "calendar-app", PrivateKeyID: "k1", PrivateKey: pemKey,
+		ClientEmail: "push@calendar-app.iam.gserviceaccount.com", TokenURI: server.URL + "/token",
+	})
+	path := filepath.Join(t.TempDir(), "fcm.json")
+	require.NoError(t, os.WriteFile(path, creds, 0o600))
+
+	fcm, err := NewFCMProvider(path)
+	require.NoError(t, err)
+	fcm.BaseURL = server.URL
+
+	eventID := uuid.New()
+	require.NoError(t, fcm.Push(context.Background(), "device-1", PushMessage{Title: "Reminder: Standup", Body: "soon", EventID: &eventID}))
+	assert.Equal(t, "device-1", sent["token"])
+	assert.Equal(t, map[string]any{"title": "Reminder: Standup", "body": "soon"}, sent["notification"])
+	assert.Equal(t, map[string]any{"event_id": eventID.String()}, sent["data"])
+	changes := []FieldChange{{Field: "title", Old: "Standup", New: "Retro"}}
+	require.NoError(t, fcm.Push(context.Background(), "device-1", PushMessage{Title: "Retro was changed", EventID: &eventID, Changes: changes}))
+	assert.Equal(t, `[{"field":"title","old":"Standup","new":"Retro"}]`, sent["data"].(map[string]any)["changes"])
+
+	assert.ErrorIs(t, fcm.Push(context.Background(), "uninstalled", PushMessage{}), ErrDeviceTokenInvalid)
+	err = fcm.Push(context.Background(), "throttled", PushMessage{})
+	assert.EqualError(t, err, "FCM returned 429 Too Many Requests: quota exceeded")
+	assert.Equal(t, 1, exchanges, "the access token is reused until it expires")
+}
+
+func TestAPNsProvider(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, path := writePKCS8(t, key)
+
+	var topic, payload string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "bearer "))
+		switch r.URL.Path {
+		case "/3/device/gone":
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"reason": "Unregistered"}`))
+		case "/3/device/wrong-app":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"reason": "BadDeviceToken"}`))
+		case "/3/device/busy":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"reason": "ServiceUnavailable"}`))
+		default:
+			topic = r.Header.Get("apns-topic")
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			b, _ := json.Marshal(body["aps"])
+			payload = string(b)
+		}
+	}))
+	defer server.Close()
+
+	_, err = NewAPNsProvider(path, "", "TEAM123", "com.example.calendar", false)
+	assert.Error(t, err)
+	apns, err := NewAPNsProvider(path, "KEY123", "TEAM123", "com.example.calendar", true)
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.sandbox.push.apple.com", apns.Host)
+	apns.Host = server.URL
+
+	require.NoError(t, apns.Push(context.Background(), "device-1", PushMessage{Title: "Reminder: Standup", Body: "soon"}))
+	assert.Equal(t, "com.example.calendar", topic)
+	assert.JSONEq(t, `{"alert": {"title": "Reminder: Standup", "body": "soon"}, "sound": "default"}`, payload)
+
+	assert.ErrorIs(t, apns.Push(context.Background(), "gone", PushMessage{}), ErrDeviceTokenInvalid)
+	assert.ErrorIs(t, apns.Push(context.Background(), "wrong-app", PushMessage{}), ErrDeviceTokenInvalid)
+	assert.EqualError(t, apns.Push(context.Background(), "busy", PushMessage{}), "APNs returned 503 Service Unavailable: ServiceUnavailable")
+
+	first, _ := apns.providerToken(time.Now())
+	again, _ := apns.providerToken(time.Now().Add(10 * time.Minute))
+	later, _ := apns.providerToken(time.Now().Add(time.Hour))
+	assert.Equal(t, first, again)
+	assert.NotEqual(t, first, later)
+}
+
+// fakeDeviceTokens keeps device tokens in memory
+type fakeDeviceTokens struct {
+	DeviceTokenRepositoryInterface
+	tokens []DeviceToken
+}
+
+func (f *fakeDeviceTokens) ListDeviceTokens(ctx context.Context, userID string) ([]DeviceToken, error) {
+	tokens := []DeviceToken{}
+	for _, d := range f.tokens {
+		if d.UserID == userID {
+			tokens = append(tokens, d)
+		}
+	}
+	return tokens, nil
+}
+
+func (f *fakeDeviceTokens) InvalidateDeviceToken(ctx context.Context, platform, token string) error {
+	kept := f.tokens[:0]
+	for _, d := range f.tokens {
+		if d.Platform != platform || d.Token != token {
+			kept = append(kept, d)
+		}
+	}
+	f.tokens = kept
+	return nil
+}
+
+// scriptedProvider answers each token with a fixed result and records what it sent
+type scriptedProvider struct {
+	results map[string]error
+	sent    map[string]PushMessage
+}
+
+func (p *scriptedProvider) Push(ctx context.Context, token string, msg PushMessage) error {
+	if err := p.results[token]; err != nil {
+		return err
+	}
+	p.sent[token] = msg
+	return nil
+}
+
+func TestPushNotifierDevices(t *testing.T) {
+	devices := &fakeDeviceTokens{tokens: []DeviceToken{
+		{ID: uuid.New(), UserID: "alice", Platform: PlatformFCM, Token: "pixel"},
+		{ID: uuid.New(), UserID: "alice", Platform: PlatformAPNs, Token: "old-iphone"},
+		{ID: uuid.New(), UserID: "alice", Platform: PlatformAPNs, Token: "iphone"},
+		{ID: uuid.New(), UserID: "bob", Platform: PlatformFCM, Token: "broken"},
+	}}
+	fcm := &scriptedProvider{results: map[string]error{"broken": errors.New("FCM returned 500")}, sent: map[string]PushMessage{}}
+	apns := &scriptedProvider{results: map[string]error{"old-iphone": ErrDeviceTokenInvalid}, sent: map[string]PushMessage{}}
+	push := NewPushNotifier(nil, nil, devices, PushProviders{PlatformFCM: fcm, PlatformAPNs: apns})
+	require.NotNil(t, push)
+
+	msg := PushMessage{Title: "Standup was changed"}
+	require.NoError(t, push.NotifyUser(context.Background(), "alice", msg))
+	assert.Equal(t, map[string]PushMessage{"pixel": msg}, fcm.sent)
+	assert.Equal(t, map[string]PushMessage{"iphone": msg}, apns.sent)
+	assert.Len(t, devices.tokens, 3, "the rejected token is deleted")
+
+	// A failure is not an invalid token, so bob keeps his device
+	assert.EqualError(t, push.NotifyUser(context.Background(), "bob", msg), "device "+devices.tokens[2].ID.String()+": FCM returned 500")
+	assert.Len(t, devices.tokens, 3)
+
+	assert.Nil(t, NewPushNotifier(nil, nil, devices, PushProviders{}))
+}
+
+// remindersOn lists fixed reminders for any event
+type remindersOn struct {
+	ReminderRepositoryInterface
+	reminders []Reminder
+}
+
+func (r *remindersOn) ListReminders(ctx context.Context, eventID uuid.UUID) ([]Reminder, error) {
+	return r.reminders, nil
+}
+
+func TestEventChangeNotifier(t *testing.T) {
+	devices := &fakeDeviceTokens{tokens: []DeviceToken{
+		{UserID: "alice", Platform: PlatformFCM, Token: "alice-phone"},
+		{UserID: "bob", Platform: PlatformFCM, Token: "bob-phone"},
+		{UserID: "carol", Platform: PlatformFCM, Token: "carol-phone"},
+	}}
+	fcm := &scriptedProvider{sent: map[string]PushMessage{}}
+	push := NewPushNotifier(nil, nil, devices, PushProviders{PlatformFCM: fcm})
+	reminders := &remindersOn{reminders: []Reminder{
+		{OwnerID: "alice", Language: "es"},
+		{OwnerID: "alice", Language: "en"},
+		{OwnerID: "bob", Language: "en"},
+		{OwnerID: "carol", Language: "en"},
+	}}
+	changes := NewEventChangeNotifier(reminders, push)
+
+	event := EventDB{ID: uuid.New(), Title: "Standup", StartTime: time.Date(2025, 9, 15, 10, 0, 0, 0, time.UTC)}
+	ctx := WithPrincipal(context.Background(), &Principal{UserID: "carol"})
+	moved := []FieldChange{{Field: "start_time", Old: "2025-09-15T09:00:00Z", New: "2025-09-15T10:00:00Z"}}
+	changes.NotifyChange(ctx, event, moved)
+
+	require.Len(t, fcm.sent, 2, "carol made the change")
+	assert.Equal(t, "Standup ha cambiado", fcm.sent["alice-phone"].Title)
+	assert.Equal(t, "Standup was changed", fcm.sent["bob-phone"].Title)
+	assert.Equal(t, "Standup starts on "+FormatDate("en", event.StartTime)+".", fcm.sent["bob-phone"].Body)
+	assert.Equal(t, event.ID, *fcm.sent["bob-phone"].EventID)
+	assert.Equal(t, moved, fcm.sent["bob-phone"].Changes)
+
+	assert.Nil(t, NewEventChangeNotifier(reminders, nil))
+}
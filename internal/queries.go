@@ -0,0 +1,329 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Query is a named, parameterized SQL statement. Statements are registered once at
+// package initialization, so every query the repositories run can be listed and
+// checked by tests instead of being assembled inline.
+type Query struct {
+	Name string
+	SQL  string
+}
+
+// queryRegistry holds every registered query by name
+var queryRegistry = map[string]Query{}
+
+// registerQuery adds a query to the registry; names must be unique
+func registerQuery(name, sql string) Query {
+	if _, ok := queryRegistry[name]; ok {
+		panic("duplicate query " + name)
+	}
+	q := Query{Name: name, SQL: sql}
+	queryRegistry[name] = q
+	return q
+}
+
+// Event queries. List queries start from qSelectEvents and add their filters with
+// selectBuilder.
+var (
+	qSelectEvents = registerQuery("events.select", `SELECT `+eventColumns+` FROM events`)
+
+	// qSelectEventSummaries lists the columns of EventSummary, for ?view=summary
+	qSelectEventSummaries = registerQuery("events.select_summaries", `SELECT `+eventSummaryColumns+` FROM events`)
+
+	// qEventsVersion summarizes a listing for its ETag; filters are added like those of
+	// qSelectEvents
+	qEventsVersion = registerQuery("events.version", `SELECT COUNT(*), COALESCE(MAX(updated_at), 'epoch') FROM events`)
+
+	qInsertEvent = registerQuery("events.insert", `
+		INSERT INTO events (id, title, description, description_format, start_time, end_time, location, latitude, longitude, calendar_id, status, submitted_by,
+			price_cents, currency, ticket_quota, client_key, external_id, source)
+		VALUES (COALESCE($1, uuid_generate_v4()), $2, $3, $4, $5, $6, $7, $8, $9, $10, COALESCE(NULLIF($11, ''), 'approved'), $12, $13, $14, $15, $16, $17, $18)
+		RETURNING `+eventColumns)
+
+	qGetEvent = registerQuery("events.get", `SELECT `+eventColumns+` FROM events WHERE id = $1`)
+
+	qUpdateEvent = registerQuery("events.update", `
+		UPDATE events
+		SET title = $2, description = $3, description_format = $4, start_time = $5, end_time = $6,
+			location = $7, latitude = $8, longitude = $9, calendar_id = $10,
+			status = COALESCE(NULLIF($11, ''), status), submitted_by = CASE WHEN $11 = '' THEN submitted_by ELSE $12 END,
+			price_cents = $13, currency = $14, ticket_quota = $15, external_id = $16, source = $17
+		WHERE id = $1
+		RETURNING `+eventColumns)
+
+	qDeleteEvent = registerQuery("events.delete", `DELETE FROM events WHERE id = $1`)
+
+	qImportEvent = registerQuery("events.import", `
+		INSERT INTO events (id, title, description, description_format, start_time, end_time, location, latitude, longitude, created_at, updated_at, calendar_id, status, submitted_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, COALESCE(NULLIF($13, ''), 'approved'), $14)
+		ON CONFLICT (id) DO NOTHING`)
+
+	// Events synced from another system replace the fields of the event with the same
+	// external ID; the row's ID, timestamps and review status are kept
+	qImportExternalEvent = registerQuery("events.import_external", `
+		INSERT INTO events (id, title, description, description_format, start_time, end_time, location, latitude, longitude, created_at, updated_at, calendar_id, status, submitted_by,
+			external_id, source)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, COALESCE(NULLIF($13, ''), 'approved'), $14, $15, $16)
+		ON CONFLICT (source, external_id) DO UPDATE
+		SET title = EXCLUDED.title, description = EXCLUDED.description, description_format = EXCLUDED.description_format,
+			start_time = EXCLUDED.start_time, end_time = EXCLUDED.end_time, location = EXCLUDED.location,
+			latitude = EXCLUDED.latitude, longitude = EXCLUDED.longitude, calendar_id = EXCLUDED.calendar_id
+		RETURNING (xmax = 0)`)
+
+	qLockEncryptedFields = registerQuery("events.lock_encrypted_fields", `
+		SELECT id, description, location
+		FROM events
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE`)
+
+	qUpdateEncryptedFields = registerQuery("events.update_encrypted_fields", `UPDATE events SET description = $2, location = $3 WHERE id = $1`)
+)
+
+// Sync queries
+var (
+	qSyncSnapshotXmin = registerQuery("sync.snapshot_xmin", `SELECT txid_snapshot_xmin(txid_current_snapshot())`)
+
+	qSyncChanges = registerQuery("sync.changes", `
+		SELECT version, id, false AS deleted, NULL::timestamptz AS deleted_at FROM events
+		WHERE (version, id) > ($1, $2) AND version < $3
+		UNION ALL
+		SELECT version, id, true, deleted_at FROM event_tombstones
+		WHERE (version, id) > ($1, $2) AND version < $3
+		ORDER BY version, id
+		LIMIT $4`)
+
+	qSyncDelete = registerQuery("sync.delete", `DELETE FROM events WHERE id = $1 AND version = $2`)
+
+	// qSyncLockVersion locks a pushed deletion's event until it is deleted, so its
+	// dependents are only handled for a delete that applies
+	qSyncLockVersion = registerQuery("sync.lock_version", `SELECT version FROM events WHERE id = $1 FOR UPDATE`)
+
+	// A tombstone means the client is reviving an event deleted elsewhere
+	qSyncInsert = registerQuery("sync.insert", `
+		INSERT INTO events (id, title, description, description_format, start_time, end_time, location, latitude, longitude, calendar_id, status, submitted_by)
+		SELECT $1::uuid, $2, $3, $4, $5::timestamptz, $6::timestamptz, $7, $8::double precision, $9::double precision, $10::uuid,
+			COALESCE(NULLIF($11, ''), 'approved'), $12
+		WHERE NOT EXISTS (SELECT 1 FROM event_tombstones WHERE id = $1::uuid)
+		ON CONFLICT (id) DO NOTHING`)
+
+	qSyncUpdate = registerQuery("sync.update", `
+		UPDATE events
+		SET title = $2, description = $3, description_format = $4, start_time = $5, end_time = $6,
+			location = $7, latitude = $8, longitude = $9, calendar_id = $10,
+			status = COALESCE(NULLIF($11, ''), status), submitted_by = CASE WHEN $11 = '' THEN submitted_by ELSE $12 END
+		WHERE id = $1 AND version = $13`)
+)
+
+// Approval queries
+var (
+	qReviewEvent = registerQuery("approval.review", `
+		UPDATE events SET status = $2
+		WHERE id = $1 AND status = 'pending'
+		RETURNING `+eventColumns)
+
+	qInsertEventReview = registerQuery("approval.insert_review", `
+		INSERT INTO event_reviews (id, event_id, decision, comment, reviewer)
+		VALUES ($1, $2, $3, $4, $5)`)
+
+	qListEventReviews = registerQuery("approval.list_reviews", `
+		SELECT `+eventReviewColumns+` FROM event_reviews
+		WHERE event_id = $1
+		ORDER BY created_at, id`)
+)
+
+// Heatmap queries
+var (
+	// Buckets are generated in local time, so days and weeks follow daylight saving
+	// time. Events count in every bucket they overlap.
+	qEventOccupancy = registerQuery("events.occupancy", `
+		WITH buckets AS (
+			SELECT local AT TIME ZONE $4 AS bucket_start, (local + $3::interval) AT TIME ZONE $4 AS bucket_end
+			FROM generate_series($1::timestamptz AT TIME ZONE $4, $2::timestamptz AT TIME ZONE $4 - $3::interval, $3::interval) AS local
+		)
+		SELECT b.bucket_start, b.bucket_end, COUNT(e.id)
+		FROM buckets b
+		LEFT JOIN events e
+			ON e.start_time < b.bucket_end AND e.end_time > b.bucket_start
+			AND e.status = 'approved'
+			AND ($5::uuid IS NULL OR e.calendar_id = $5)
+		GROUP BY b.bucket_start, b.bucket_end
+		ORDER BY b.bucket_start`)
+)
+
+// qSuggestTitles lists the distinct titles of published events outside busy calendars
+// matching $1, a LIKE pattern on the lowercased title served by the trigram index,
+// those starting with $2 first
+var qSuggestTitles = registerQuery("events.suggest_titles", `
+	SELECT e.title
+	FROM events e
+	LEFT JOIN calendars c ON c.id = e.calendar_id
+	WHERE e.status = 'approved'
+		AND lower(e.title) LIKE $1 ESCAPE '\'
+		AND ($3::uuid IS NULL OR e.calendar_id = $3)
+		AND COALESCE(c.visibility, 'full') <> 'busy'
+	GROUP BY e.title
+	ORDER BY lower(e.title) LIKE $2 ESCAPE '\' DESC, length(e.title), e.title
+	LIMIT $4`)
+
+// qSuggestSimilarTitles is qSuggestTitles also matching the titles whose trigram
+// similarity to $1 reaches pg_trgm.similarity_threshold, ranked after those matching
+// $2, most similar first
+var qSuggestSimilarTitles = registerQuery("events.suggest_similar_titles", `
+	SELECT e.title, max(similarity(lower(e.title), $1)) AS score
+	FROM events e
+	LEFT JOIN calendars c ON c.id = e.calendar_id
+	WHERE e.status = 'approved'
+		AND (lower(e.title) LIKE $2 ESCAPE '\' OR lower(e.title) % $1)
+		AND ($4::uuid IS NULL OR e.calendar_id = $4)
+		AND COALESCE(c.visibility, 'full') <> 'busy'
+	GROUP BY e.title
+	ORDER BY lower(e.title) LIKE $3 ESCAPE '\' DESC, lower(e.title) LIKE $2 ESCAPE '\' DESC,
+		score DESC, length(e.title), e.title
+	LIMIT $5`)
+
+// searchEventColumns is eventSummaryColumns of the events joined to their calendars
+const searchEventColumns = `e.id, e.calendar_id, e.title, e.start_time, e.end_time, e.status, e.submitted_by, e.version, e.updated_at`
+
+// qSearchEvents is the full text search of the titles of the published events outside
+// busy calendars, served by idx_events_title_fts
+var qSearchEvents = registerQuery("events.search", `
+	SELECT `+searchEventColumns+`
+	FROM events e
+	LEFT JOIN calendars c ON c.id = e.calendar_id
+	WHERE e.status = 'approved'
+		AND to_tsvector('simple', e.title) @@ websearch_to_tsquery('simple', $1)
+		AND ($2::uuid IS NULL OR e.calendar_id = $2)
+		AND COALESCE(c.visibility, 'full') <> 'busy'
+	ORDER BY ts_rank(to_tsvector('simple', e.title), websearch_to_tsquery('simple', $1)) DESC, e.start_time
+	LIMIT $3`)
+
+// qSearchableEventsByID selects the events of $1 searches may return
+var qSearchableEventsByID = registerQuery("events.select_searchable", `
+	SELECT `+searchEventColumns+`
+	FROM events e
+	LEFT JOIN calendars c ON c.id = e.calendar_id
+	WHERE e.id = ANY($1)
+		AND e.status = 'approved'
+		AND COALESCE(c.visibility, 'full') <> 'busy'`)
+
+// Stats queries, reading the precomputed daily counts
+var (
+	qEventStats = registerQuery("events.stats", `
+		SELECT CASE $3
+				WHEN 'week' THEN date_trunc('week', day)::date::text
+				WHEN 'calendar' THEN calendar_id::text
+				ELSE day::text
+			END AS key,
+			SUM(events), SUM(minutes)
+		FROM event_stats_daily
+		WHERE day >= $1::date AND day < $2::date AND ($4::uuid IS NULL OR calendar_id = $4)
+		GROUP BY 1
+		ORDER BY 1`)
+)
+
+// Activity queries
+var (
+	qSelectActivity = registerQuery("activity.select", `
+		SELECT id, event_id, calendar_id, action, title, actor, published, occurred_at, changes
+		FROM event_activity`)
+
+	qInsertActivity = registerQuery("activity.insert", `
+		INSERT INTO event_activity (id, event_id, calendar_id, action, title, actor, published, changes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`)
+
+	// A deletion keeps the calendar and last known title of the event
+	qInsertDeletionActivity = registerQuery("activity.insert_deletion", `
+		INSERT INTO event_activity (id, event_id, calendar_id, action, title, actor)
+		SELECT $1, $2, t.calendar_id, 'deleted',
+			COALESCE((SELECT a.title FROM event_activity a WHERE a.event_id = $2 ORDER BY a.occurred_at DESC, a.id DESC LIMIT 1), ''),
+			$3
+		FROM event_tombstones t
+		WHERE t.id = $2`)
+)
+
+// selectBuilder adds filters, sorting and a limit to a registered SELECT. Conditions
+// use ? for their arguments, which are numbered $1, $2, ... in order, so values are
+// never formatted into the SQL. Sort expressions are not escaped and must be constants,
+// never client input.
+type selectBuilder struct {
+	base    Query
+	where   []string
+	args    []any
+	orderBy []string
+	limit   int
+}
+
+// newSelect starts a builder from a registered SELECT without WHERE or ORDER BY
+func newSelect(base Query) *selectBuilder {
+	return &selectBuilder{base: base}
+}
+
+// Where adds a condition, ANDed with the others; conditions using OR are parenthesized
+// by Build
+func (b *selectBuilder) Where(cond string, args ...any) *selectBuilder {
+	var sb strings.Builder
+	n := 0
+	for _, c := range cond {
+		if c != '?' {
+			sb.WriteRune(c)
+			continue
+		}
+		if n == len(args) {
+			panic(fmt.Sprintf("query %s: condition %q has more placeholders than arguments", b.base.Name, cond))
+		}
+		b.args = append(b.args, args[n])
+		sb.WriteString("$" + strconv.Itoa(len(b.args)))
+		n++
+	}
+	if n != len(args) {
+		panic(fmt.Sprintf("query %s: condition %q has fewer placeholders than arguments", b.base.Name, cond))
+	}
+	b.where = append(b.where, sb.String())
+	return b
+}
+
+// OrderBy adds a sort expression
+func (b *selectBuilder) OrderBy(expr string) *selectBuilder {
+	b.orderBy = append(b.orderBy, expr)
+	return b
+}
+
+// Limit caps the number of rows; 0 means no limit
+func (b *selectBuilder) Limit(n int) *selectBuilder {
+	b.limit = n
+	return b
+}
+
+// Build returns the SQL and its arguments
+func (b *selectBuilder) Build() (string, []any) {
+	var sb strings.Builder
+	sb.WriteString(b.base.SQL)
+	if len(b.where) > 0 {
+		conds := b.where
+		if len(conds) > 1 {
+			conds = make([]string, len(b.where))
+			for i, c := range b.where {
+				conds[i] = "(" + c + ")"
+			}
+		}
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(conds, " AND "))
+	}
+	if len(b.orderBy) > 0 {
+		sb.WriteString(" ORDER BY ")
+		sb.WriteString(strings.Join(b.orderBy, ", "))
+	}
+	args := append([]any(nil), b.args...)
+	if b.limit > 0 {
+		args = append(args, b.limit)
+		sb.WriteString(" LIMIT $" + strconv.Itoa(len(args)))
+	}
+	return sb.String(), args
+}
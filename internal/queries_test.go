@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisteredQueryPlaceholders(t *testing.T) {
+	placeholder := regexp.MustCompile(`\$(\d+)`)
+	for name, q := range queryRegistry {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, name, q.Name)
+			used := map[int]bool{}
+			max := 0
+			for _, m := range placeholder.FindAllStringSubmatch(q.SQL, -1) {
+				n, _ := strconv.Atoi(m[1])
+				used[n] = true
+				if n > max {
+					max = n
+				}
+			}
+			for n := 1; n <= max; n++ {
+				assert.True(t, used[n], "$%d is never used", n)
+			}
+		})
+	}
+}
+
+func TestSelectBuilder(t *testing.T) {
+	from := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	base := Query{Name: "test", SQL: "SELECT id FROM events"}
+
+	tests := []struct {
+		name     string
+		build    func() (string, []any)
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:     "no filters",
+			build:    newSelect(base).OrderBy("start_time ASC").Build,
+			wantSQL:  "SELECT id FROM events ORDER BY start_time ASC",
+			wantArgs: nil,
+		},
+		{
+			name:     "one condition",
+			build:    newSelect(base).Where("start_time < ? AND end_time > ?", to, from).Build,
+			wantSQL:  "SELECT id FROM events WHERE start_time < $1 AND end_time > $2",
+			wantArgs: []any{to, from},
+		},
+		{
+			name: "conditions, sort and limit are numbered in order",
+			build: newSelect(base).
+				Where("title = ? OR location = ?", "a", "b").
+				Where("start_time >= ?", from).
+				OrderBy("start_time ASC").OrderBy("id").
+				Limit(10).Build,
+			wantSQL:  "SELECT id FROM events WHERE (title = $1 OR location = $2) AND (start_time >= $3) ORDER BY start_time ASC, id LIMIT $4",
+			wantArgs: []any{"a", "b", from, 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args := tt.build()
+			assert.Equal(t, tt.wantSQL, sql)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+func TestSelectBuilderArgumentMismatch(t *testing.T) {
+	base := Query{Name: "test", SQL: "SELECT id FROM events"}
+	assert.Panics(t, func() { newSelect(base).Where("id = ? OR id = ?", 1) })
+	assert.Panics(t, func() { newSelect(base).Where("id = ?", 1, 2) })
+}
@@ -0,0 +1,306 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultQuickAddDuration is used when the sentence has a start but no end or duration
+const defaultQuickAddDuration = time.Hour
+
+// QuickAddDraft is the structured event parsed from a quick-add sentence
+type QuickAddDraft struct {
+	Title     string    `json:"title"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+var (
+	clockPattern    = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+	isoDatePattern  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	durationPattern = regexp.MustCompile(`^(\d+)\s*(m|min|mins|minute|minutes|h|hr|hrs|hour|hours)$`)
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+var months = map[string]time.Month{
+	"jan": time.January, "january": time.January,
+	"feb": time.February, "february": time.February,
+	"mar": time.March, "march": time.March,
+	"apr": time.April, "april": time.April,
+	"may": time.May,
+	"jun": time.June, "june": time.June,
+	"jul": time.July, "july": time.July,
+	"aug": time.August, "august": time.August,
+	"sep": time.September, "sept": time.September, "september": time.September,
+	"oct": time.October, "october": time.October,
+	"nov": time.November, "november": time.November,
+	"dec": time.December, "december": time.December,
+}
+
+// fillerWords are dropped from the title when they precede a date or time
+var fillerWords = map[string]bool{"at": true, "on": true, "from": true, "for": true}
+
+// clock is a time of day in minutes since midnight
+type clock struct {
+	minutes int
+	hasAMPM bool
+}
+
+// ParseQuickAdd parses sentences like "Lunch with Sara Friday 12:30-13:30" into a draft event.
+// Relative words (today, tomorrow, weekday names) are resolved against now, whose location
+// is used as the reference timezone.
+func ParseQuickAdd(text string, now time.Time) (*QuickAddDraft, error) {
+	tokens := strings.Fields(text)
+	if len(tokens) == 0 {
+		return nil, errors.New("text is required")
+	}
+
+	var (
+		titleWords []string
+		day        *time.Time
+		start, end *clock
+		duration   time.Duration
+		nextWeek   bool
+	)
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		lower := strings.ToLower(strings.Trim(tok, ",."))
+
+		switch {
+		case lower == "today" || lower == "tomorrow":
+			d := dateOnly(now)
+			if lower == "tomorrow" {
+				d = d.AddDate(0, 0, 1)
+			}
+			day = &d
+			continue
+		case lower == "next" && i+1 < len(tokens):
+			if _, ok := weekdays[strings.ToLower(strings.Trim(tokens[i+1], ",."))]; ok {
+				nextWeek = true
+				continue
+			}
+		case isWeekday(lower):
+			d := nextWeekday(now, weekdays[lower])
+			if nextWeek {
+				d = d.AddDate(0, 0, 7)
+			}
+			day = &d
+			continue
+		case isoDatePattern.MatchString(lower):
+			d, err := time.ParseInLocation("2006-01-02", lower, now.Location())
+			if err != nil {
+				return nil, fmt.Errorf("invalid date %q", tok)
+			}
+			day = &d
+			continue
+		case lower == "noon":
+			start = &clock{minutes: 12 * 60, hasAMPM: true}
+			continue
+		case lower == "for" && i+1 < len(tokens):
+			if d, consumed, ok := parseQuickDuration(tokens[i+1:]); ok {
+				duration = d
+				i += consumed
+				continue
+			}
+		}
+
+		if m, ok := months[lower]; ok && i+1 < len(tokens) {
+			if n, err := strconv.Atoi(strings.Trim(tokens[i+1], ",.")); err == nil && n >= 1 && n <= 31 {
+				d := monthDay(now, m, n)
+				day = &d
+				i++
+				continue
+			}
+		}
+
+		if s, e, ok := parseClockRange(lower); ok {
+			start, end = s, e
+			continue
+		}
+		if c, ok := parseClock(lower); ok && start == nil && looksLikeTime(lower) {
+			start = c
+			if i+2 < len(tokens) && isRangeSeparator(tokens[i+1]) {
+				if e, ok := parseClock(strings.ToLower(tokens[i+2])); ok {
+					end = e
+					i += 2
+				}
+			}
+			continue
+		}
+
+		titleWords = append(titleWords, tok)
+	}
+
+	for len(titleWords) > 0 && fillerWords[strings.ToLower(titleWords[len(titleWords)-1])] {
+		titleWords = titleWords[:len(titleWords)-1]
+	}
+	title := strings.TrimSpace(strings.Join(titleWords, " "))
+	if title == "" {
+		return nil, errors.New("could not find a title in text")
+	}
+	if start == nil {
+		return nil, errors.New("could not find a start time in text")
+	}
+	if end != nil && duration != 0 {
+		return nil, errors.New("text has both an end time and a duration")
+	}
+
+	// "3-5pm" style ranges borrow the meridiem of the end time
+	if end != nil && end.hasAMPM && !start.hasAMPM && start.minutes < 12*60 && end.minutes >= 12*60 && start.minutes+12*60 <= end.minutes {
+		start.minutes += 12 * 60
+	}
+
+	base := dateOnly(now)
+	if day != nil {
+		base = *day
+	}
+	startTime := base.Add(time.Duration(start.minutes) * time.Minute)
+	if day == nil && startTime.Before(now) {
+		startTime = startTime.AddDate(0, 0, 1)
+		base = base.AddDate(0, 0, 1)
+	}
+
+	var endTime time.Time
+	switch {
+	case end != nil:
+		endTime = base.Add(time.Duration(end.minutes) * time.Minute)
+		if !endTime.After(startTime) {
+			endTime = endTime.AddDate(0, 0, 1)
+		}
+	case duration != 0:
+		endTime = startTime.Add(duration)
+	default:
+		endTime = startTime.Add(defaultQuickAddDuration)
+	}
+
+	return &QuickAddDraft{Title: title, StartTime: startTime, EndTime: endTime}, nil
+}
+
+func isWeekday(s string) bool {
+	_, ok := weekdays[s]
+	return ok
+}
+
+func isRangeSeparator(s string) bool {
+	switch strings.ToLower(s) {
+	case "-", "to", "until", "till":
+		return true
+	}
+	return false
+}
+
+// looksLikeTime avoids treating plain numbers in titles ("Top 10 review") as times
+func looksLikeTime(s string) bool {
+	return strings.Contains(s, ":") || strings.HasSuffix(s, "am") || strings.HasSuffix(s, "pm")
+}
+
+func parseClock(s string) (*clock, bool) {
+	m := clockPattern.FindStringSubmatch(s)
+	if m == nil {
+		return nil, false
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	if minute > 59 {
+		return nil, false
+	}
+	switch m[3] {
+	case "am":
+		if hour < 1 || hour > 12 {
+			return nil, false
+		}
+		if hour == 12 {
+			hour = 0
+		}
+	case "pm":
+		if hour < 1 || hour > 12 {
+			return nil, false
+		}
+		if hour != 12 {
+			hour += 12
+		}
+	default:
+		if hour > 23 {
+			return nil, false
+		}
+	}
+	return &clock{minutes: hour*60 + minute, hasAMPM: m[3] != ""}, true
+}
+
+// parseClockRange parses a single token such as "12:30-13:30" or "3-5pm"
+func parseClockRange(s string) (*clock, *clock, bool) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return nil, nil, false
+	}
+	start, ok := parseClock(parts[0])
+	if !ok {
+		return nil, nil, false
+	}
+	end, ok := parseClock(parts[1])
+	if !ok {
+		return nil, nil, false
+	}
+	return start, end, true
+}
+
+// parseQuickDuration parses "30 minutes", "2h" or "1 hour" and reports how many tokens it used
+func parseQuickDuration(tokens []string) (time.Duration, int, bool) {
+	candidates := []string{strings.ToLower(tokens[0])}
+	if len(tokens) > 1 {
+		candidates = append(candidates, strings.ToLower(tokens[0]+" "+tokens[1]))
+	}
+	for i := len(candidates) - 1; i >= 0; i-- {
+		m := durationPattern.FindStringSubmatch(strings.Trim(candidates[i], ",."))
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[1])
+		unit := time.Minute
+		if strings.HasPrefix(m[2], "h") {
+			unit = time.Hour
+		}
+		if n <= 0 {
+			return 0, 0, false
+		}
+		return time.Duration(n) * unit, i + 1, true
+	}
+	return 0, 0, false
+}
+
+func dateOnly(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// nextWeekday returns the next date falling on wd, counting today
+func nextWeekday(now time.Time, wd time.Weekday) time.Time {
+	days := (int(wd) - int(now.Weekday()) + 7) % 7
+	return dateOnly(now).AddDate(0, 0, days)
+}
+
+// monthDay resolves "Aug 22" to this year, or next year if the date already passed
+func monthDay(now time.Time, m time.Month, d int) time.Time {
+	t := time.Date(now.Year(), m, d, 0, 0, 0, 0, now.Location())
+	if t.Before(dateOnly(now)) {
+		t = t.AddDate(1, 0, 0)
+	}
+	return t
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuickAdd(t *testing.T) {
+	madrid := time.FixedZone("CEST", 2*60*60)
+	// Wednesday
+	now := time.Date(2025, 8, 20, 9, 0, 0, 0, madrid)
+
+	tests := []struct {
+		name      string
+		text      string
+		wantTitle string
+		wantStart time.Time
+		wantEnd   time.Time
+		wantErr   bool
+	}{
+		{
+			name:      "weekday with range",
+			text:      "Lunch with Sara Friday 12:30-13:30",
+			wantTitle: "Lunch with Sara",
+			wantStart: time.Date(2025, 8, 22, 12, 30, 0, 0, madrid),
+			wantEnd:   time.Date(2025, 8, 22, 13, 30, 0, 0, madrid),
+		},
+		{
+			name:      "tomorrow with duration",
+			text:      "Standup tomorrow at 9am for 15 minutes",
+			wantTitle: "Standup",
+			wantStart: time.Date(2025, 8, 21, 9, 0, 0, 0, madrid),
+			wantEnd:   time.Date(2025, 8, 21, 9, 15, 0, 0, madrid),
+		},
+		{
+			name:      "pm range shares meridiem",
+			text:      "Workshop on Aug 25 3-5pm",
+			wantTitle: "Workshop",
+			wantStart: time.Date(2025, 8, 25, 15, 0, 0, 0, madrid),
+			wantEnd:   time.Date(2025, 8, 25, 17, 0, 0, 0, madrid),
+		},
+		{
+			name:      "next weekday with default duration",
+			text:      "Review next monday 10:00",
+			wantTitle: "Review",
+			wantStart: time.Date(2025, 9, 1, 10, 0, 0, 0, madrid),
+			wantEnd:   time.Date(2025, 9, 1, 11, 0, 0, 0, madrid),
+		},
+		{
+			name:      "time already passed today rolls to tomorrow",
+			text:      "Coffee 8:00",
+			wantTitle: "Coffee",
+			wantStart: time.Date(2025, 8, 21, 8, 0, 0, 0, madrid),
+			wantEnd:   time.Date(2025, 8, 21, 9, 0, 0, 0, madrid),
+		},
+		{
+			name:    "missing time",
+			text:    "Lunch with Sara Friday",
+			wantErr: true,
+		},
+		{
+			name:    "missing title",
+			text:    "Friday 12:30",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			draft, err := ParseQuickAdd(tt.text, now)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, draft)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantTitle, draft.Title)
+			assert.True(t, tt.wantStart.Equal(draft.StartTime), "start %v", draft.StartTime)
+			assert.True(t, tt.wantEnd.Equal(draft.EndTime), "end %v", draft.EndTime)
+		})
+	}
+}
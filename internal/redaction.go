@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Calendar visibilities. Viewers of a busy calendar other than its owner, the members
+// of the organization owning it and its delegates see its events as busy blocks.
+const (
+	CalendarVisibilityFull = "full"
+	CalendarVisibilityBusy = "busy"
+)
+
+// BusyTitle is the title of a redacted event
+const BusyTitle = "Busy"
+
+// ValidCalendarVisibility reports whether v is a calendar visibility
+func ValidCalendarVisibility(v string) bool {
+	return v == CalendarVisibilityFull || v == CalendarVisibilityBusy
+}
+
+// RedactEvent returns the busy block shown in place of event: its times and calendar,
+// without what it is about
+func RedactEvent(event EventDB) EventDB {
+	return EventDB{
+		ID:                event.ID,
+		CalendarID:        event.CalendarID,
+		Title:             BusyTitle,
+		DescriptionFormat: event.DescriptionFormat,
+		StartTime:         event.StartTime,
+		EndTime:           event.EndTime,
+		CreatedAt:         event.CreatedAt,
+		UpdatedAt:         event.UpdatedAt,
+		Version:           event.Version,
+		Status:            event.Status,
+	}
+}
+
+// EventRedactor is the serialization policy hiding the events of busy calendars from
+// the viewers who may not see them. The API key and deployments without
+// authentication see every event.
+type EventRedactor struct {
+	calendars CalendarRepositoryInterface
+	orgs      OrganizationRepositoryInterface
+	delegates DelegateRepositoryInterface
+	now       func() time.Time
+}
+
+// NewEventRedactor reads calendar visibility from calendars; orgs and delegates may be
+// nil when those features are not used
+func NewEventRedactor(calendars CalendarRepositoryInterface, orgs OrganizationRepositoryInterface, delegates DelegateRepositoryInterface) *EventRedactor {
+	return &EventRedactor{calendars: calendars, orgs: orgs, delegates: delegates, now: time.Now}
+}
+
+// Redact returns which of events the caller in ctx sees as busy blocks, by index.
+// Every event of a busy calendar is redacted when the caller's access to it could not
+// be checked, along with the error.
+func (p *EventRedactor) Redact(ctx context.Context, events []EventDB) ([]bool, error) {
+	redacted := make([]bool, len(events))
+	viewer := PrincipalFromContext(ctx)
+	if viewer == nil || viewer.Admin {
+		return redacted, nil
+	}
+
+	hidden := map[uuid.UUID]bool{}
+	var firstErr error
+	for i, e := range events {
+		if e.CalendarID == nil {
+			continue
+		}
+		hide, seen := hidden[*e.CalendarID]
+		if !seen {
+			var err error
+			hide, err = p.hides(ctx, *e.CalendarID, viewer.UserID)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			hidden[*e.CalendarID] = hide
+		}
+		redacted[i] = hide
+	}
+	return redacted, firstErr
+}
+
+// hides reports whether userID sees the events of a calendar as busy blocks. On error
+// it hides them.
+func (p *EventRedactor) hides(ctx context.Context, calendarID uuid.UUID, userID string) (bool, error) {
+	c, err := p.calendars.GetCalendar(ctx, calendarID)
+	if errors.Is(err, ErrCalendarNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+	if c.Visibility != CalendarVisibilityBusy || c.OwnerID == "" || c.OwnerID == userID {
+		return false, nil
+	}
+	if c.OrganizationID != nil && p.orgs != nil {
+		_, err := p.orgs.GetMembership(ctx, *c.OrganizationID, userID)
+		if err == nil {
+			return false, nil
+		}
+		if !errors.Is(err, ErrMemberNotFound) {
+			return true, err
+		}
+	}
+	if p.delegates != nil {
+		d, err := p.delegates.GetDelegate(ctx, c.ID, userID)
+		if err == nil {
+			return d.ExpiresAt != nil && !p.now().Before(*d.ExpiresAt), nil
+		}
+		if !errors.Is(err, ErrDelegateNotFound) {
+			return true, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,22 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactEvent(t *testing.T) {
+	calendarID := uuid.New()
+	description, location, price := "Salary review", "Room 4", int64(1500)
+	lat, lng := 52.52, 13.40
+	start := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+	event := EventDB{ID: uuid.New(), CalendarID: &calendarID, Title: "1:1", Description: &description, Location: &location,
+		Latitude: &lat, Longitude: &lng, StartTime: start, EndTime: start.Add(time.Hour), Version: 3, SubmittedBy: "alice", PriceCents: &price}
+
+	assert.Equal(t, EventDB{ID: event.ID, CalendarID: &calendarID, Title: BusyTitle, StartTime: event.StartTime, EndTime: event.EndTime, Version: 3}, RedactEvent(event))
+	assert.True(t, ValidCalendarVisibility(CalendarVisibilityBusy))
+	assert.False(t, ValidCalendarVisibility("private"))
+}
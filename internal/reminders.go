@@ -0,0 +1,396 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobSendReminders is the scheduler job that sends the reminders that are due
+const JobSendReminders = "send_reminders"
+
+// Reminder channels
+const (
+	ReminderChannelEmail   = "email"
+	ReminderChannelWebhook = "webhook"
+	ReminderChannelPush    = "push"
+)
+
+// MaxReminderOffset is the earliest a reminder may fire, in minutes before the event
+const MaxReminderOffset = 4 * 7 * 24 * 60
+
+// maxReminderAttempts is how many times a failing reminder is tried
+const maxReminderAttempts = 3
+
+// reminderBatch is how many due reminders one run of the job sends; a failed reminder
+// is retried on the next run
+const reminderBatch = 500
+
+// ErrReminderNotFound is returned when a reminder does not exist on the event
+var ErrReminderNotFound = newDomainError(ErrNotFound, "reminder not found")
+
+// Reminder tells its owner about an event some minutes before it starts. The time
+// follows the event when its start moves.
+type Reminder struct {
+	ID            uuid.UUID `json:"id"`
+	EventID       uuid.UUID `json:"event_id"`
+	OwnerID       string    `json:"owner_id"`
+	OffsetMinutes int       `json:"offset_minutes"`
+	Channel       string    `json:"channel"`
+	// Target is the email address or webhook URL; push reminders go to the owner's devices
+	Target string `json:"target,omitempty"`
+	// Language is the one the reminder was created in, used for email texts
+	Language  string     `json:"-"`
+	Attempts  int        `json:"attempts"`
+	SentAt    *time.Time `json:"sent_at"`
+	LastError *string    `json:"last_error"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// RemindAt is when the reminder fires for an event starting at start
+func (r Reminder) RemindAt(start time.Time) time.Time {
+	return start.Add(-time.Duration(r.OffsetMinutes) * time.Minute)
+}
+
+// ValidateReminder returns a client-facing message when the reminder is invalid for
+// event, or "". channels are the channels this deployment can send on.
+func ValidateReminder(r Reminder, event EventDB, channels []string, now time.Time) string {
+	known := false
+	for _, c := range channels {
+		known = known || c == r.Channel
+	}
+	if !known {
+		return fmt.Sprintf("channel must be one of %v", channels)
+	}
+	if r.OffsetMinutes < 0 || r.OffsetMinutes > MaxReminderOffset {
+		return fmt.Sprintf("offset_minutes must be between 0 and %d", MaxReminderOffset)
+	}
+	switch r.Channel {
+	case ReminderChannelEmail:
+		if _, err := mail.ParseAddress(r.Target); err != nil {
+			return "target must be an email address"
+		}
+	case ReminderChannelWebhook:
+		u, err := url.Parse(r.Target)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return "target must be an http or https URL"
+		}
+	default:
+		if r.Target != "" {
+			return fmt.Sprintf("target is not used by the %s channel", r.Channel)
+		}
+	}
+	if !r.RemindAt(event.StartTime).After(now) {
+		return "reminder time is in the past"
+	}
+	return ""
+}
+
+// ReminderSender delivers reminders on one channel
+type ReminderSender interface {
+	SendReminder(ctx context.Context, r Reminder, event EventDB) error
+}
+
+// ReminderSenders maps each channel to its sender
+type ReminderSenders map[string]ReminderSender
+
+// Channels lists the channels with a sender, sorted
+func (s ReminderSenders) Channels() []string {
+	channels := make([]string, 0, len(s))
+	for c := range s {
+		channels = append(channels, c)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// NewReminderSenders returns the email and webhook senders; a PushReminderSender is
+// added for the push channel when push notifications are configured. Webhook reminders
+// are only sent to public addresses.
+func NewReminderSenders(notifier Notifier) ReminderSenders {
+	return ReminderSenders{
+		ReminderChannelEmail:   &EmailReminderSender{Notifier: notifier},
+		ReminderChannelWebhook: &WebhookReminderSender{Client: PublicHTTPClient(webhookTimeout, nil)},
+	}
+}
+
+// NewReminderSendersFromConfig also sends webhook reminders to cfg.WebhookAllowedNetworks
+func NewReminderSendersFromConfig(notifier Notifier, cfg Config) (ReminderSenders, error) {
+	allowed, err := ParseNetworks(cfg.WebhookAllowedNetworks)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_ALLOWED_NETWORKS: %w", err)
+	}
+	senders := NewReminderSenders(notifier)
+	senders[ReminderChannelWebhook] = &WebhookReminderSender{Client: PublicHTTPClient(webhookTimeout, allowed)}
+	return senders, nil
+}
+
+// EmailReminderSender sends reminders through the notifier
+type EmailReminderSender struct {
+	Notifier Notifier
+}
+
+func (s *EmailReminderSender) SendReminder(ctx context.Context, r Reminder, event EventDB) error {
+	return s.Notifier.Notify(ctx, ComposeReminder(r, event))
+}
+
+// ComposeReminder renders the reminder text in the reminder's language
+func ComposeReminder(r Reminder, event EventDB) Notification {
+	lang := r.Language
+	text := Translate(lang, "%s starts on %s.", event.Title, FormatDate(lang, event.StartTime))
+	if event.Location != nil && *event.Location != "" {
+		text += "\n" + Translate(lang, "Location: %s", *event.Location)
+	}
+	return Notification{
+		To:      r.Target,
+		Subject: Translate(lang, "Reminder: %s", event.Title),
+		Text:    text,
+	}
+}
+
+// WebhookReminderSender POSTs reminders as JSON to the reminder's URL
+type WebhookReminderSender struct {
+	Client *http.Client
+}
+
+// reminderPayload is the body of a webhook reminder
+type reminderPayload struct {
+	ReminderID    uuid.UUID     `json:"reminder_id"`
+	OffsetMinutes int           `json:"offset_minutes"`
+	Event         reminderEvent `json:"event"`
+}
+
+type reminderEvent struct {
+	ID        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Location  *string   `json:"location"`
+}
+
+func (s *WebhookReminderSender) SendReminder(ctx context.Context, r Reminder, event EventDB) error {
+	body, err := json.Marshal(reminderPayload{
+		ReminderID:    r.ID,
+		OffsetMinutes: r.OffsetMinutes,
+		Event:         reminderEvent{ID: event.ID, Title: event.Title, StartTime: event.StartTime, EndTime: event.EndTime, Location: event.Location},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.Target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	SetRequestIDHeader(req)
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+type ReminderRepository struct {
+	db *sql.DB
+}
+
+// NewReminderRepository creates a new reminder repository
+func NewReminderRepository(db *sql.DB) *ReminderRepository {
+	return &ReminderRepository{db: db}
+}
+
+const reminderColumns = `id, event_id, owner_id, offset_minutes, channel, target, language, attempts, sent_at, last_error, created_at, updated_at`
+
+func scanReminder(row rowScanner, r *Reminder) error {
+	return row.Scan(&r.ID, &r.EventID, &r.OwnerID, &r.OffsetMinutes, &r.Channel, &r.Target, &r.Language, &r.Attempts, &r.SentAt, &r.LastError, &r.CreatedAt, &r.UpdatedAt)
+}
+
+// CreateReminder stores a new reminder
+func (r *ReminderRepository) CreateReminder(ctx context.Context, rem Reminder) (*Reminder, error) {
+	query := `
+		INSERT INTO event_reminders (id, event_id, owner_id, offset_minutes, channel, target, language)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + reminderColumns
+
+	var created Reminder
+	if err := scanReminder(traced(ctx, r.db).QueryRowContext(ctx, query, rem.ID, rem.EventID, rem.OwnerID, rem.OffsetMinutes, rem.Channel, rem.Target, rem.Language), &created); err != nil {
+		if isForeignKeyViolation(err, "event_reminders_event_id_fkey") {
+			return nil, ErrEventNotFound
+		}
+		return nil, fmt.Errorf("failed to create reminder: %w", err)
+	}
+	return &created, nil
+}
+
+// ListReminders returns the reminders of an event, earliest first
+func (r *ReminderRepository) ListReminders(ctx context.Context, eventID uuid.UUID) ([]Reminder, error) {
+	query := `SELECT ` + reminderColumns + ` FROM event_reminders WHERE event_id = $1 ORDER BY offset_minutes DESC, id`
+	rows, err := traced(ctx, r.db).QueryContext(ctx, query, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reminders: %w", err)
+	}
+	defer rows.Close()
+
+	reminders := []Reminder{}
+	for rows.Next() {
+		var rem Reminder
+		if err := scanReminder(rows, &rem); err != nil {
+			return nil, fmt.Errorf("failed to scan reminder: %w", err)
+		}
+		reminders = append(reminders, rem)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reminders: %w", err)
+	}
+	return reminders, nil
+}
+
+// GetReminder retrieves a reminder of an event
+func (r *ReminderRepository) GetReminder(ctx context.Context, eventID, id uuid.UUID) (*Reminder, error) {
+	var rem Reminder
+	query := `SELECT ` + reminderColumns + ` FROM event_reminders WHERE event_id = $1 AND id = $2`
+	if err := scanReminder(traced(ctx, r.db).QueryRowContext(ctx, query, eventID, id), &rem); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReminderNotFound
+		}
+		return nil, fmt.Errorf("failed to get reminder: %w", err)
+	}
+	return &rem, nil
+}
+
+// UpdateReminder saves the offset, channel and target of a reminder and schedules it
+// to be sent again
+func (r *ReminderRepository) UpdateReminder(ctx context.Context, rem Reminder) (*Reminder, error) {
+	query := `
+		UPDATE event_reminders
+		SET offset_minutes = $3, channel = $4, target = $5,
+			attempts = 0, sent_at = NULL, last_error = NULL, updated_at = NOW()
+		WHERE event_id = $1 AND id = $2
+		RETURNING ` + reminderColumns
+
+	var updated Reminder
+	if err := scanReminder(traced(ctx, r.db).QueryRowContext(ctx, query, rem.EventID, rem.ID, rem.OffsetMinutes, rem.Channel, rem.Target), &updated); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReminderNotFound
+		}
+		return nil, fmt.Errorf("failed to update reminder: %w", err)
+	}
+	return &updated, nil
+}
+
+// DeleteReminder removes a reminder of an event
+func (r *ReminderRepository) DeleteReminder(ctx context.Context, eventID, id uuid.UUID) error {
+	res, err := traced(ctx, r.db).ExecContext(ctx, `DELETE FROM event_reminders WHERE event_id = $1 AND id = $2`, eventID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete reminder: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrReminderNotFound
+	}
+	return nil
+}
+
+// ClaimDueReminders marks up to limit reminders due at now as sent and returns them.
+// Reminders of events that already ended are skipped. Claimed rows are locked with
+// SKIP LOCKED, so instances never claim the same reminder.
+func (r *ReminderRepository) ClaimDueReminders(ctx context.Context, now time.Time, limit int) ([]Reminder, error) {
+	query := `
+		UPDATE event_reminders
+		SET sent_at = $1, attempts = attempts + 1, updated_at = NOW()
+		WHERE id IN (
+			SELECT r.id FROM event_reminders r
+			JOIN events e ON e.id = r.event_id
+			WHERE r.sent_at IS NULL AND r.attempts < $2
+				AND e.start_time - make_interval(mins => r.offset_minutes) <= $1
+				AND e.end_time > $1
+			ORDER BY e.start_time, r.id
+			LIMIT $3
+			FOR UPDATE OF r SKIP LOCKED
+		)
+		RETURNING ` + reminderColumns
+
+	rows, err := traced(ctx, r.db).QueryContext(ctx, query, now, maxReminderAttempts, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim reminders: %w", err)
+	}
+	defer rows.Close()
+
+	reminders := []Reminder{}
+	for rows.Next() {
+		var rem Reminder
+		if err := scanReminder(rows, &rem); err != nil {
+			return nil, fmt.Errorf("failed to scan reminder: %w", err)
+		}
+		reminders = append(reminders, rem)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reminders: %w", err)
+	}
+	return reminders, nil
+}
+
+// ReleaseReminder records a failed delivery and makes the reminder due again, until it
+// runs out of attempts
+func (r *ReminderRepository) ReleaseReminder(ctx context.Context, id uuid.UUID, failure string) error {
+	query := `UPDATE event_reminders SET sent_at = NULL, last_error = $2, updated_at = NOW() WHERE id = $1`
+	if _, err := traced(ctx, r.db).ExecContext(ctx, query, id, failure); err != nil {
+		return fmt.Errorf("failed to release reminder: %w", err)
+	}
+	return nil
+}
+
+// SendRemindersJob sends every due reminder on its channel
+func SendRemindersJob(events EventRepositoryInterface, reminders ReminderRepositoryInterface, senders ReminderSenders) JobFunc {
+	return func(ctx context.Context, _ json.RawMessage) (string, error) {
+		due, err := reminders.ClaimDueReminders(ctx, time.Now(), reminderBatch)
+		if err != nil {
+			return "", err
+		}
+
+		sent, failed := 0, 0
+		for _, rem := range due {
+			if err := sendReminder(ctx, events, senders, rem); err != nil {
+				log.Printf("Error sending reminder %s of event %s: %v", rem.ID, rem.EventID, err)
+				if err := reminders.ReleaseReminder(ctx, rem.ID, err.Error()); err != nil {
+					log.Printf("Error releasing reminder %s: %v", rem.ID, err)
+				}
+				failed++
+				continue
+			}
+			sent++
+		}
+
+		output := fmt.Sprintf("sent %d reminders, %d failed", sent, failed)
+		if failed > 0 {
+			return output, fmt.Errorf("%d of %d reminders failed", failed, len(due))
+		}
+		return output, nil
+	}
+}
+
+func sendReminder(ctx context.Context, events EventRepositoryInterface, senders ReminderSenders, rem Reminder) error {
+	sender, ok := senders[rem.Channel]
+	if !ok {
+		return fmt.Errorf("channel %s is not configured", rem.Channel)
+	}
+	event, err := events.GetEventByID(ctx, rem.EventID)
+	if err != nil {
+		return err
+	}
+	return sender.SendReminder(ctx, rem, *event)
+}
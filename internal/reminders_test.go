@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateReminder(t *testing.T) {
+	now := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+	event := EventDB{StartTime: now.Add(2 * time.Hour), EndTime: now.Add(3 * time.Hour)}
+	channels := []string{ReminderChannelEmail, ReminderChannelWebhook}
+
+	tests := []struct {
+		name     string
+		reminder Reminder
+		want     string
+	}{
+		{"email", Reminder{Channel: "email", Target: "alice@example.com", OffsetMinutes: 30}, ""},
+		{"webhook", Reminder{Channel: "webhook", Target: "https://hooks.example.com/r", OffsetMinutes: 0}, ""},
+		{"unknown channel", Reminder{Channel: "sms", Target: "+34600000000", OffsetMinutes: 30}, "channel must be one of [email webhook]"},
+		{"push not configured", Reminder{Channel: "push", OffsetMinutes: 30}, "channel must be one of [email webhook]"},
+		{"negative offset", Reminder{Channel: "email", Target: "alice@example.com", OffsetMinutes: -5}, "offset_minutes must be between 0 and 40320"},
+		{"offset too large", Reminder{Channel: "email", Target: "alice@example.com", OffsetMinutes: MaxReminderOffset + 1}, "offset_minutes must be between 0 and 40320"},
+		{"bad email", Reminder{Channel: "email", Target: "alice", OffsetMinutes: 30}, "target must be an email address"},
+		{"bad url", Reminder{Channel: "webhook", Target: "ftp://example.com", OffsetMinutes: 30}, "target must be an http or https URL"},
+		{"in the past", Reminder{Channel: "email", Target: "alice@example.com", OffsetMinutes: 120}, "reminder time is in the past"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ValidateReminder(tt.reminder, event, channels, now))
+		})
+	}
+
+	push := Reminder{Channel: "push", Target: "device", OffsetMinutes: 30}
+	assert.Equal(t, "target is not used by the push channel", ValidateReminder(push, event, []string{"push"}, now))
+}
+
+func TestComposeReminder(t *testing.T) {
+	loc := "Sala 2"
+	event := EventDB{Title: "Standup", StartTime: time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC), Location: &loc}
+	msg := ComposeReminder(Reminder{Target: "ana@example.com", Language: "es"}, event)
+
+	assert.Equal(t, "ana@example.com", msg.To)
+	assert.Equal(t, "Recordatorio: Standup", msg.Subject)
+	assert.Contains(t, msg.Text, "Standup comienza el ")
+	assert.Contains(t, msg.Text, "\nLugar: Sala 2")
+}
+
+// fakeReminderRepository hands out its due reminders once and keeps the releases
+type fakeReminderRepository struct {
+	ReminderRepositoryInterface
+	due      []Reminder
+	released map[uuid.UUID]string
+}
+
+func (f *fakeReminderRepository) ClaimDueReminders(ctx context.Context, now time.Time, limit int) ([]Reminder, error) {
+	due := f.due
+	f.due = nil
+	return due, nil
+}
+
+func (f *fakeReminderRepository) ReleaseReminder(ctx context.Context, id uuid.UUID, failure string) error {
+	f.released[id] = failure
+	return nil
+}
+
+// eventsByID serves GetEventByID from a map
+type eventsByID struct {
+	EventRepositoryInterface
+	events map[uuid.UUID]EventDB
+}
+
+func (e *eventsByID) GetEventByID(ctx context.Context, id uuid.UUID) (*EventDB, error) {
+	event, ok := e.events[id]
+	if !ok {
+		return nil, ErrEventNotFound
+	}
+	return &event, nil
+}
+
+type failingSender struct{}
+
+func (failingSender) SendReminder(ctx context.Context, r Reminder, event EventDB) error {
+	return errors.New("hook returned 500")
+}
+
+func TestSendRemindersJob(t *testing.T) {
+	event := EventDB{ID: uuid.New(), Title: "Standup", StartTime: time.Now().Add(10 * time.Minute)}
+	events := &eventsByID{events: map[uuid.UUID]EventDB{event.ID: event}}
+	emailed := Reminder{ID: uuid.New(), EventID: event.ID, Channel: ReminderChannelEmail, Target: "alice@example.com"}
+	hooked := Reminder{ID: uuid.New(), EventID: event.ID, Channel: ReminderChannelWebhook, Target: "https://hooks.example.com"}
+	orphan := Reminder{ID: uuid.New(), EventID: uuid.New(), Channel: ReminderChannelEmail, Target: "bob@example.com"}
+	repo := &fakeReminderRepository{due: []Reminder{emailed, hooked, orphan}, released: map[uuid.UUID]string{}}
+
+	notifier := &recordingNotifier{}
+	senders := ReminderSenders{
+		ReminderChannelEmail:   &EmailReminderSender{Notifier: notifier},
+		ReminderChannelWebhook: failingSender{},
+	}
+
+	output, err := SendRemindersJob(events, repo, senders)(context.Background(), nil)
+	require.Error(t, err)
+	assert.Equal(t, "sent 1 reminders, 2 failed", output)
+
+	require.Len(t, notifier.sent, 1)
+	assert.Equal(t, "alice@example.com", notifier.sent[0].To)
+	assert.Equal(t, map[uuid.UUID]string{
+		hooked.ID: "hook returned 500",
+		orphan.ID: ErrEventNotFound.Error(),
+	}, repo.released)
+}
+
+func TestWebhookReminderSenderRefusesPrivateTargets(t *testing.T) {
+	var calls int
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer receiver.Close()
+	event := EventDB{ID: uuid.New(), Title: "Standup", StartTime: time.Now().Add(time.Hour), EndTime: time.Now().Add(2 * time.Hour)}
+	reminder := Reminder{ID: uuid.New(), EventID: event.ID, Channel: ReminderChannelWebhook, Target: receiver.URL}
+
+	err := NewReminderSenders(LogNotifier{})[ReminderChannelWebhook].SendReminder(context.Background(), reminder, event)
+	assert.ErrorIs(t, err, ErrPrivateDestination)
+	assert.Zero(t, calls)
+
+	senders, err := NewReminderSendersFromConfig(LogNotifier{}, Config{WebhookAllowedNetworks: []string{"127.0.0.0/8", "::1"}})
+	require.NoError(t, err)
+	require.NoError(t, senders[ReminderChannelWebhook].SendReminder(context.Background(), reminder, event))
+	assert.Equal(t, 1, calls)
+
+	_, err = NewReminderSendersFromConfig(LogNotifier{}, Config{WebhookAllowedNetworks: []string{"intranet"}})
+	assert.Error(t, err)
+}
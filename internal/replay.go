@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxReplayBody bounds the request bodies a replay log keeps; larger bodies are omitted
+const MaxReplayBody = 1 << 20
+
+// ReplayHeaders are the request headers a replay log keeps. Conditional headers are
+// left out: the ETags they carry do not match the versions of a replayed database.
+var ReplayHeaders = []string{"Content-Type", "Accept-Language"}
+
+// ReplayEntry is one mutating request of a replay log, with who made it and what it
+// returned
+type ReplayEntry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	// UserID, Admin and Scopes are the principal the request was authenticated as
+	UserID string   `json:"user_id,omitempty"`
+	Admin  bool     `json:"admin,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+	Method string   `json:"method"`
+	// Path includes the query string
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	// BodyOmitted says why the body was not kept; such entries cannot be replayed
+	BodyOmitted string `json:"body_omitted,omitempty"`
+	Status      int    `json:"status"`
+	// ResultID is the id of the JSON object the request returned, such as a created
+	// event, so replays can map it to the ID the replayed request gets
+	ResultID string `json:"result_id,omitempty"`
+}
+
+// Principal returns the principal to replay the entry as, or nil for an anonymous request
+func (e ReplayEntry) Principal() *Principal {
+	if e.UserID == "" && !e.Admin {
+		return nil
+	}
+	return &Principal{UserID: e.UserID, Scopes: e.Scopes, Admin: e.Admin}
+}
+
+// ReplayLog appends ReplayEntry records to a file, one JSON object per line. It holds
+// the bodies of requests, user content included, so the file is only readable by its
+// owner.
+type ReplayLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenReplayLog opens the replay log at path for appending, creating it if needed
+func OpenReplayLog(path string) (*ReplayLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replay log: %w", err)
+	}
+	return &ReplayLog{file: f}, nil
+}
+
+// Record appends an entry. Failures are logged rather than failing the request.
+func (l *ReplayLog) Record(e ReplayEntry) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("Replay log: failed to encode %s %s: %v", e.Method, e.Path, err)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		log.Printf("Replay log: failed to record %s %s: %v", e.Method, e.Path, err)
+	}
+}
+
+// Close closes the file
+func (l *ReplayLog) Close() error {
+	return l.file.Close()
+}
+
+// ReadReplayLog decodes the entries of a replay log
+func ReadReplayLog(r io.Reader) ([]ReplayEntry, error) {
+	var entries []ReplayEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 2*MaxReplayBody)
+	for n := 1; scanner.Scan(); n++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var e ReplayEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ReplayOutcome is what replaying an entry returned
+type ReplayOutcome struct {
+	Entry  ReplayEntry
+	Status int
+	// Skipped says why the entry was not replayed
+	Skipped string
+	// Body is the response body when the status differs from the recorded one
+	Body string
+}
+
+// Mismatch reports whether the replayed request returned another status than the
+// recorded one
+func (o ReplayOutcome) Mismatch() bool {
+	return o.Skipped == "" && o.Status != o.Entry.Status
+}
+
+// uuidPattern matches the IDs a replay maps
+var uuidPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+
+// Replayer re-applies the entries of a replay log to a handler, in order, as the
+// principals that made them. The IDs created while replaying differ from the recorded
+// ones, so the recorded result IDs are replaced with the new ones in the paths and
+// bodies of the following entries.
+type Replayer struct {
+	handler http.Handler
+	ids     map[string]string
+}
+
+// NewReplayer replays into handler, which must serve requests authenticated by their
+// context principal, like the API router
+func NewReplayer(handler http.Handler) *Replayer {
+	return &Replayer{handler: handler, ids: map[string]string{}}
+}
+
+// Replay applies one entry
+func (r *Replayer) Replay(ctx context.Context, e ReplayEntry) ReplayOutcome {
+	if e.BodyOmitted != "" {
+		return ReplayOutcome{Entry: e, Skipped: "body omitted: " + e.BodyOmitted}
+	}
+	mapIDs := func(s string) string {
+		return uuidPattern.ReplaceAllStringFunc(s, func(id string) string {
+			if mapped, ok := r.ids[strings.ToLower(id)]; ok {
+				return mapped
+			}
+			return id
+		})
+	}
+	req := httptest.NewRequest(e.Method, mapIDs(e.Path), strings.NewReader(mapIDs(e.Body)))
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	if e.RequestID != "" {
+		req.Header.Set(HeaderRequestID, "replay-"+e.RequestID)
+	}
+	if p := e.Principal(); p != nil {
+		ctx = WithPrincipal(ctx, p)
+	}
+	rec := httptest.NewRecorder()
+	r.handler.ServeHTTP(rec, req.WithContext(ctx))
+
+	out := ReplayOutcome{Entry: e, Status: rec.Code}
+	if e.ResultID != "" {
+		if id := ReplayResultID(rec.Header().Get("Content-Type"), rec.Body.Bytes()); id != "" && id != e.ResultID {
+			r.ids[strings.ToLower(e.ResultID)] = id
+		}
+	}
+	if out.Mismatch() {
+		out.Body = strings.TrimSpace(rec.Body.String())
+	}
+	return out
+}
+
+// ReplayResultID returns the top-level id of a JSON object response, or ""
+func ReplayResultID(contentType string, body []byte) string {
+	if !strings.HasPrefix(contentType, "application/json") {
+		return ""
+	}
+	var result struct {
+		ID any `json:"id"`
+	}
+	if json.Unmarshal(body, &result) != nil {
+		return ""
+	}
+	if id, ok := result.ID.(string); ok {
+		return id
+	}
+	return ""
+}
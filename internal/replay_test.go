@@ -0,0 +1,30 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadReplayLog(t *testing.T) {
+	entries, err := ReadReplayLog(strings.NewReader(`{"method":"POST","path":"/events","status":201,"result_id":"abc"}
+
+{"method":"DELETE","path":"/events/abc","user_id":"user-1","status":204}
+`))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Nil(t, entries[0].Principal(), "anonymous")
+	assert.Equal(t, &Principal{UserID: "user-1"}, entries[1].Principal())
+
+	_, err = ReadReplayLog(strings.NewReader("{\"method\":\"POST\"}\nnot json\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestReplayResultID(t *testing.T) {
+	assert.Equal(t, "abc", ReplayResultID("application/json", []byte(`{"id":"abc","title":"x"}`)))
+	assert.Equal(t, "", ReplayResultID("application/json", []byte(`[{"id":"abc"}]`)), "lists have no result")
+	assert.Equal(t, "", ReplayResultID("application/json", []byte(`{"id":12}`)))
+	assert.Equal(t, "", ReplayResultID("text/plain", []byte(`{"id":"abc"}`)))
+}
@@ -0,0 +1,315 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Resource kinds
+const (
+	ResourceKindRoom      = "room"
+	ResourceKindEquipment = "equipment"
+)
+
+// ErrResourceNotFound is returned when a resource does not exist
+var ErrResourceNotFound = newDomainError(ErrNotFound, "resource not found")
+
+// ErrUnknownResource is returned when a booking names a resource that does not exist
+var ErrUnknownResource = newDomainError(ErrValidation, "resource not found")
+
+// ErrResourceBooked is returned when a resource is booked for another event at the time
+var ErrResourceBooked = newDomainError(ErrConflict, "resource is already booked at that time")
+
+// ErrResourceInUse is returned when deleting a resource that still has bookings
+var ErrResourceInUse = newDomainError(ErrConflict, "resource has bookings")
+
+// Resource is a room or piece of equipment events book. A resource is booked by at
+// most one event at a time.
+type Resource struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Kind string    `json:"kind"`
+	// Capacity is how many people a room seats
+	Capacity  *int      `json:"capacity"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Booking is a resource held by an event for the event's time
+type Booking struct {
+	ResourceID uuid.UUID `json:"resource_id"`
+	EventID    uuid.UUID `json:"event_id"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+}
+
+// TimeSlot is a period of time, including Start and excluding End
+type TimeSlot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// ResourceFilter selects resources by kind, capacity and availability
+type ResourceFilter struct {
+	Kind        string
+	MinCapacity int
+	// FreeDuring, when set, keeps the resources without bookings during the slot
+	FreeDuring *TimeSlot
+}
+
+// ValidateResource returns a message describing what is wrong with a resource, or ""
+func ValidateResource(res Resource) string {
+	switch {
+	case res.Name == "" || len(res.Name) > 100:
+		return "name is required and must be <= 100 characters"
+	case res.Kind != ResourceKindRoom && res.Kind != ResourceKindEquipment:
+		return "kind must be room or equipment"
+	case res.Capacity != nil && *res.Capacity <= 0:
+		return "capacity must be positive"
+	}
+	return ""
+}
+
+// FreeSlots returns the parts of [from, to) not covered by bookings
+func FreeSlots(from, to time.Time, bookings []Booking) []TimeSlot {
+	sorted := append([]Booking(nil), bookings...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	free := []TimeSlot{}
+	cursor := from
+	for _, b := range sorted {
+		if b.Start.After(cursor) {
+			free = append(free, TimeSlot{Start: cursor, End: minTime(b.Start, to)})
+		}
+		if b.End.After(cursor) {
+			cursor = b.End
+		}
+		if !cursor.Before(to) {
+			return free
+		}
+	}
+	if cursor.Before(to) {
+		free = append(free, TimeSlot{Start: cursor, End: to})
+	}
+	return free
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+type ResourceRepository struct {
+	db *sql.DB
+}
+
+// NewResourceRepository creates a new resource repository
+func NewResourceRepository(db *sql.DB) *ResourceRepository {
+	return &ResourceRepository{db: db}
+}
+
+const resourceColumns = `id, name, kind, capacity, created_at, updated_at`
+
+func scanResource(row rowScanner, res *Resource) error {
+	return row.Scan(&res.ID, &res.Name, &res.Kind, &res.Capacity, &res.CreatedAt, &res.UpdatedAt)
+}
+
+// isExclusionViolation reports whether err is a Postgres exclusion constraint violation on constraint
+func isExclusionViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23P01" && pqErr.Constraint == constraint
+}
+
+// CreateResource stores a new resource
+func (r *ResourceRepository) CreateResource(ctx context.Context, res Resource) (*Resource, error) {
+	query := `
+		INSERT INTO resources (id, name, kind, capacity)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + resourceColumns
+
+	var created Resource
+	if err := scanResource(conn(ctx, r.db).QueryRowContext(ctx, query, res.ID, res.Name, res.Kind, res.Capacity), &created); err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+	return &created, nil
+}
+
+// ListResources returns the resources matching filter ordered by name
+func (r *ResourceRepository) ListResources(ctx context.Context, filter ResourceFilter) ([]Resource, error) {
+	var freeFrom, freeTo *time.Time
+	if filter.FreeDuring != nil {
+		freeFrom, freeTo = &filter.FreeDuring.Start, &filter.FreeDuring.End
+	}
+	query := `
+		SELECT ` + resourceColumns + ` FROM resources r
+		WHERE ($1 = '' OR kind = $1)
+			AND ($2 = 0 OR capacity >= $2)
+			AND ($3::timestamptz IS NULL OR NOT EXISTS (
+				SELECT 1 FROM resource_bookings b
+				WHERE b.resource_id = r.id AND b.period && tstzrange($3, $4)))
+		ORDER BY name, id`
+	return r.queryResources(ctx, query, filter.Kind, filter.MinCapacity, freeFrom, freeTo)
+}
+
+func (r *ResourceRepository) queryResources(ctx context.Context, query string, args ...any) ([]Resource, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query resources: %w", err)
+	}
+	defer rows.Close()
+
+	resources := []Resource{}
+	for rows.Next() {
+		var res Resource
+		if err := scanResource(rows, &res); err != nil {
+			return nil, fmt.Errorf("failed to scan resource: %w", err)
+		}
+		resources = append(resources, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating resources: %w", err)
+	}
+	return resources, nil
+}
+
+// GetResource retrieves a resource by ID
+func (r *ResourceRepository) GetResource(ctx context.Context, id uuid.UUID) (*Resource, error) {
+	var res Resource
+	if err := scanResource(conn(ctx, r.db).QueryRowContext(ctx, `SELECT `+resourceColumns+` FROM resources WHERE id = $1`, id), &res); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrResourceNotFound
+		}
+		return nil, fmt.Errorf("failed to get resource: %w", err)
+	}
+	return &res, nil
+}
+
+// UpdateResource changes the name, kind and capacity of a resource
+func (r *ResourceRepository) UpdateResource(ctx context.Context, res Resource) (*Resource, error) {
+	query := `UPDATE resources SET name = $2, kind = $3, capacity = $4 WHERE id = $1 RETURNING ` + resourceColumns
+
+	var updated Resource
+	if err := scanResource(conn(ctx, r.db).QueryRowContext(ctx, query, res.ID, res.Name, res.Kind, res.Capacity), &updated); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrResourceNotFound
+		}
+		return nil, fmt.Errorf("failed to update resource: %w", err)
+	}
+	return &updated, nil
+}
+
+// DeleteResource removes a resource without bookings
+func (r *ResourceRepository) DeleteResource(ctx context.Context, id uuid.UUID) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM resources WHERE id = $1`, id)
+	if err != nil {
+		if isForeignKeyViolation(err, "resource_bookings_resource_id_fkey") {
+			return ErrResourceInUse
+		}
+		return fmt.Errorf("failed to delete resource: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrResourceNotFound
+	}
+	return nil
+}
+
+// ListEventResources returns the resources booked for an event
+func (r *ResourceRepository) ListEventResources(ctx context.Context, eventID uuid.UUID) ([]Resource, error) {
+	query := `
+		SELECT ` + resourceColumns + ` FROM resources
+		WHERE id IN (SELECT resource_id FROM resource_bookings WHERE event_id = $1)
+		ORDER BY name, id`
+	return r.queryResources(ctx, query, eventID)
+}
+
+// SetEventResources replaces the resources booked for an event with resourceIDs, for
+// the event's time. It fails with ErrResourceBooked when one is taken.
+func (r *ResourceRepository) SetEventResources(ctx context.Context, eventID uuid.UUID, resourceIDs []uuid.UUID) ([]Resource, error) {
+	err := NewTxManager(r.db).JoinTx(ctx, func(ctx context.Context) error {
+		if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM resource_bookings WHERE event_id = $1`, eventID); err != nil {
+			return fmt.Errorf("failed to clear bookings: %w", err)
+		}
+		if len(resourceIDs) == 0 {
+			return nil
+		}
+		query := `
+			INSERT INTO resource_bookings (resource_id, event_id, period)
+			SELECT res.id, e.id, tstzrange(e.start_time, e.end_time)
+			FROM events e, resources res
+			WHERE e.id = $1 AND res.id = ANY($2)`
+		result, err := conn(ctx, r.db).ExecContext(ctx, query, eventID, pq.Array(resourceIDs))
+		if err != nil {
+			if isExclusionViolation(err, "resource_bookings_no_overlap") {
+				return ErrResourceBooked
+			}
+			return fmt.Errorf("failed to book resources: %w", err)
+		}
+		if n, _ := result.RowsAffected(); int(n) != len(resourceIDs) {
+			// Either the event is gone or a resource does not exist
+			var exists bool
+			if err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM events WHERE id = $1)`, eventID).Scan(&exists); err != nil {
+				return fmt.Errorf("failed to get event: %w", err)
+			}
+			if !exists {
+				return ErrEventNotFound
+			}
+			return ErrUnknownResource
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.ListEventResources(ctx, eventID)
+}
+
+// Conflicts returns the bookings of other events than eventID holding any of
+// resourceIDs during slot
+func (r *ResourceRepository) Conflicts(ctx context.Context, resourceIDs []uuid.UUID, slot TimeSlot, eventID uuid.UUID) ([]Booking, error) {
+	query := `
+		SELECT ` + bookingColumns + ` FROM resource_bookings
+		WHERE resource_id = ANY($1) AND period && tstzrange($2, $3) AND event_id <> $4
+		ORDER BY lower(period), resource_id`
+	return r.queryBookings(ctx, query, pq.Array(resourceIDs), slot.Start, slot.End, eventID)
+}
+
+// ListBookings returns the bookings of a resource overlapping slot, earliest first
+func (r *ResourceRepository) ListBookings(ctx context.Context, resourceID uuid.UUID, slot TimeSlot) ([]Booking, error) {
+	query := `
+		SELECT ` + bookingColumns + ` FROM resource_bookings
+		WHERE resource_id = $1 AND period && tstzrange($2, $3)
+		ORDER BY lower(period)`
+	return r.queryBookings(ctx, query, resourceID, slot.Start, slot.End)
+}
+
+const bookingColumns = `resource_id, event_id, lower(period), upper(period)`
+
+func (r *ResourceRepository) queryBookings(ctx context.Context, query string, args ...any) ([]Booking, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bookings: %w", err)
+	}
+	defer rows.Close()
+
+	bookings := []Booking{}
+	for rows.Next() {
+		var b Booking
+		if err := rows.Scan(&b.ResourceID, &b.EventID, &b.Start, &b.End); err != nil {
+			return nil, fmt.Errorf("failed to scan booking: %w", err)
+		}
+		bookings = append(bookings, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating bookings: %w", err)
+	}
+	return bookings, nil
+}
@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFreeSlots(t *testing.T) {
+	day := time.Date(2025, 9, 15, 0, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return day.Add(time.Duration(h) * time.Hour) }
+	booking := func(from, to int) Booking { return Booking{Start: at(from), End: at(to)} }
+
+	assert.Equal(t, []TimeSlot{{Start: at(8), End: at(18)}}, FreeSlots(at(8), at(18), nil))
+
+	// Bookings come unsorted, overlap each other and the ends of the period
+	free := FreeSlots(at(8), at(18), []Booking{booking(14, 16), booking(7, 9), booking(11, 12), booking(11, 13)})
+	assert.Equal(t, []TimeSlot{
+		{Start: at(9), End: at(11)},
+		{Start: at(13), End: at(14)},
+		{Start: at(16), End: at(18)},
+	}, free)
+
+	assert.Empty(t, FreeSlots(at(8), at(18), []Booking{booking(6, 20)}))
+	assert.Equal(t, []TimeSlot{{Start: at(8), End: at(10)}}, FreeSlots(at(8), at(18), []Booking{booking(10, 20)}))
+}
+
+func TestValidateResource(t *testing.T) {
+	zero, ten := 0, 10
+	assert.Empty(t, ValidateResource(Resource{Name: "Room 1", Kind: ResourceKindRoom, Capacity: &ten}))
+	assert.Empty(t, ValidateResource(Resource{Name: "Projector", Kind: ResourceKindEquipment}))
+	assert.NotEmpty(t, ValidateResource(Resource{Kind: ResourceKindRoom}))
+	assert.NotEmpty(t, ValidateResource(Resource{Name: "Van", Kind: "vehicle"}))
+	assert.NotEmpty(t, ValidateResource(Resource{Name: "Closet", Kind: ResourceKindRoom, Capacity: &zero}))
+}
@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookSampleCount is how many sample payloads integration platforms are given
+const WebhookSampleCount = 3
+
+// WebhookSamples returns payloads of eventType as deliveries carry them, made from the
+// most recently updated of events, so integration platforms like Zapier and Make can
+// offer their fields before the first write. Each sample's id is its event's, keeping
+// it stable across calls. An example payload stands in when there are no events.
+func WebhookSamples(eventType string, events []EventDB, now time.Time) []json.RawMessage {
+	recent := append([]EventDB(nil), events...)
+	sort.SliceStable(recent, func(i, j int) bool { return recent[i].UpdatedAt.After(recent[j].UpdatedAt) })
+	if len(recent) > WebhookSampleCount {
+		recent = recent[:WebhookSampleCount]
+	}
+	if len(recent) == 0 {
+		start := now.UTC().Truncate(time.Hour).Add(24 * time.Hour)
+		recent = []EventDB{{ID: uuid.Nil, Title: "Example event", StartTime: start, EndTime: start.Add(time.Hour), Status: EventStatusApproved, Version: 1, UpdatedAt: now}}
+	}
+
+	samples := make([]json.RawMessage, 0, len(recent))
+	for _, event := range recent {
+		payload := webhookPayload{ID: event.ID, Type: eventType, OccurredAt: event.UpdatedAt.UTC(), Event: payloadEvent(event)}
+		switch eventType {
+		case WebhookEventUpdated:
+			// Updates carry what changed; samples show a move by an hour
+			start := event.StartTime.UTC()
+			payload.Changes = []FieldChange{{Field: "start_time", Old: start.Add(-time.Hour).Format(time.RFC3339), New: start.Format(time.RFC3339)}}
+		case WebhookEventDeleted:
+			payload.Event = webhookEvent{ID: event.ID, CalendarID: event.CalendarID}
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, body)
+	}
+	return samples
+}
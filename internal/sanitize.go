@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// suspiciousPatterns flag input that looks like a script or SQL injection attempt.
+// Queries are parameterized and HTML output is escaped, so these are not needed for
+// safety; they exist so probing attempts are visible and can be rejected in strict mode.
+var suspiciousPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"script tag", regexp.MustCompile(`(?i)<\s*/?\s*script`)},
+	{"event handler attribute", regexp.MustCompile(`(?i)<[^>]*\son[a-z]+\s*=`)},
+	{"script url", regexp.MustCompile(`(?i)\b(javascript|vbscript)\s*:|\bdata\s*:\s*[a-z]+/[a-z0-9.+-]+\s*[;,]`)},
+	{"iframe or object tag", regexp.MustCompile(`(?i)<\s*(iframe|object|embed)\b`)},
+	{"sql tautology", regexp.MustCompile(`(?i)'\s*(or|and)\s+('?\w+'?)\s*=\s*('?\w+'?)`)},
+	{"sql statement chaining", regexp.MustCompile(`(?i);\s*((drop|truncate|alter)\s+(table|database|schema)|delete\s+from|insert\s+into|update\s+\w+\s+set)\b`)},
+	{"sql union select", regexp.MustCompile(`(?i)\bunion\b(\s+all)?\s+select\b`)},
+	{"sql comment", regexp.MustCompile(`'\s*(--|#|/\*)`)},
+}
+
+// SanitizeText strips control characters and bidi overrides, normalizes whitespace and
+// composes the text to NFC. Newlines and tabs are kept when multiline is true
+// (descriptions). Other invisible formatting characters are kept: joiners hold emoji
+// sequences and Persian and Indic words together.
+func SanitizeText(s string, multiline bool) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch {
+		case r == '\n' || r == '\t':
+			if multiline {
+				b.WriteRune(r)
+			} else {
+				b.WriteRune(' ')
+			}
+		case r == '\r':
+			// Normalize CRLF to LF
+		case r == unicode.ReplacementChar, unicode.IsControl(r):
+			// Drop NULs, escape sequences, C1 controls and invalid UTF-8
+		case isBidiOverride(r):
+			// Reordered text can disguise what is displayed (Trojan Source)
+		case unicode.IsSpace(r):
+			// Exotic spaces such as NBSP or ideographic space become plain spaces
+			b.WriteRune(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	out := norm.NFC.String(b.String())
+	if !multiline {
+		out = strings.Join(strings.Fields(out), " ")
+	}
+	return strings.TrimSpace(out)
+}
+
+// isBidiOverride reports whether r is a bidi embedding, override or isolate. The
+// left-to-right and right-to-left marks are kept, as right-to-left text needs them.
+func isBidiOverride(r rune) bool {
+	return (r >= 0x202A && r <= 0x202E) || (r >= 0x2066 && r <= 0x2069)
+}
+
+// SuspiciousInput returns the name of the first suspicious pattern found in s, or ""
+// The patterns are also matched against the NFKC form of s, so fullwidth variants such
+// as "＜script＞" are caught without rewriting what is stored.
+func SuspiciousInput(s string) string {
+	folded := norm.NFKC.String(s)
+	for _, p := range suspiciousPatterns {
+		if p.pattern.MatchString(s) || p.pattern.MatchString(folded) {
+			return p.name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeText(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		multiline bool
+		want      string
+	}{
+		{name: "control characters", in: "Go\x00 Meetup\x1b[31m", want: "Go Meetup[31m"},
+		{name: "bidi override", in: "invoice‮gpj.exe", want: "invoicegpj.exe"},
+		{name: "bidi isolate", in: "ad\u2066min\u2069", want: "admin"},
+		{name: "emoji sequences keep their joiners", in: "Family \U0001f468\u200d\U0001f469\u200d\U0001f467", want: "Family \U0001f468\u200d\U0001f469\u200d\U0001f467"},
+		{name: "zero width non-joiner", in: "می\u200cخواهم", want: "می\u200cخواهم"},
+		{name: "right-to-left mark", in: "שלום\u200f!", want: "שלום\u200f!"},
+		{name: "exotic spaces collapse", in: "  Team 　sync \t now ", want: "Team sync now"},
+		{name: "fullwidth text is kept", in: "ＡＢＣ ＜meeting＞", want: "ＡＢＣ ＜meeting＞"},
+		{name: "composed to NFC", in: "Cafe\u0301", want: "Caf\u00e9"},
+		{name: "multiline keeps newlines", in: "line 1\r\nline 2\n\tindented", multiline: true, want: "line 1\nline 2\n\tindented"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SanitizeText(tt.in, tt.multiline))
+		})
+	}
+}
+
+func TestSuspiciousInput(t *testing.T) {
+	suspicious := []string{
+		"<script>alert(1)</script>",
+		`<img src=x onerror="alert(1)">`,
+		"[x](javascript:alert(1))",
+		"data:text/html;base64,PHNjcmlwdD4=",
+		"' OR 1=1",
+		"x'; DROP TABLE events; --",
+		"1 UNION SELECT password FROM users",
+		"admin'--",
+		"＜script＞alert(1)＜/script＞",
+	}
+	for _, s := range suspicious {
+		assert.NotEmpty(t, SuspiciousInput(s), s)
+	}
+
+	benign := []string{
+		"Go Conference",
+		"Sara's birthday; update the roadmap afterwards",
+		"Agenda data: 10 talks, 3 workshops",
+		"Q&A <-> networking",
+		"Union meeting to select a new chair",
+	}
+	for _, s := range benign {
+		assert.Empty(t, SuspiciousInput(s), s)
+	}
+}
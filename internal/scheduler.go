@@ -0,0 +1,268 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobFunc runs one activation of a scheduled job. params are the schedule's JSON
+// parameters; the returned string is stored as the run output.
+type JobFunc func(ctx context.Context, params json.RawMessage) (string, error)
+
+// Scheduler polls for due schedules and runs the registered job for each. It also
+// processes queued long-running operations. Both are claimed in the database, so any
+// number of instances can run a scheduler.
+type Scheduler struct {
+	repo ScheduleRepositoryInterface
+	ops  OperationRepositoryInterface
+	// Interval is how often due schedules are polled; cron has minute resolution
+	Interval time.Duration
+	// JobTimeout bounds a single run or operation
+	JobTimeout time.Duration
+	// OperationLease is how long an operation stays claimed without a progress save
+	// before another instance may take it over
+	OperationLease time.Duration
+
+	mu         sync.RWMutex
+	jobs       map[string]JobFunc
+	operations map[string]OperationFunc
+	wg         sync.WaitGroup
+	// opSlots limits the operations this instance processes at once
+	opSlots chan struct{}
+	wake    chan struct{}
+}
+
+// NewScheduler creates a scheduler with no registered jobs
+func NewScheduler(repo ScheduleRepositoryInterface, ops OperationRepositoryInterface) *Scheduler {
+	return &Scheduler{
+		repo:           repo,
+		ops:            ops,
+		Interval:       30 * time.Second,
+		JobTimeout:     time.Hour,
+		OperationLease: 5 * time.Minute,
+		jobs:           map[string]JobFunc{},
+		operations:     map[string]OperationFunc{},
+		opSlots:        make(chan struct{}, 2),
+		wake:           make(chan struct{}, 1),
+	}
+}
+
+// RegisterOperation sets the function that processes operations of kind
+func (s *Scheduler) RegisterOperation(kind string, fn OperationFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.operations[kind] = fn
+}
+
+// Wake makes the scheduler poll now rather than at the next interval, so a newly
+// queued operation starts at once
+func (s *Scheduler) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Register makes a job available to schedules under name
+func (s *Scheduler) Register(name string, fn JobFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[name] = fn
+}
+
+// HasJob reports whether a job is registered under name
+func (s *Scheduler) HasJob(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.jobs[name]
+	return ok
+}
+
+// Jobs lists the registered job names
+func (s *Scheduler) Jobs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.jobs))
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run polls until ctx is done, then waits for running jobs to finish
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.tick(ctx, time.Now())
+		s.claimOperations(ctx)
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// tick starts every schedule due at now that this instance manages to claim
+func (s *Scheduler) tick(ctx context.Context, now time.Time) {
+	due, err := s.repo.DueSchedules(ctx, now)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Error loading due schedules: %v", err)
+		}
+		return
+	}
+
+	for _, sched := range due {
+		// Missed activations (e.g. while every instance was down) collapse into one run
+		next, err := sched.NextActivation(now)
+		if err != nil {
+			log.Printf("Error computing next run of schedule %s: %v", sched.ID, err)
+			continue
+		}
+		claimed, err := s.repo.ClaimSchedule(ctx, sched.ID, *sched.NextRunAt, next)
+		if err != nil {
+			log.Printf("Error claiming schedule %s: %v", sched.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		s.wg.Add(1)
+		go func(sched Schedule) {
+			defer s.wg.Done()
+			s.execute(sched)
+		}(sched)
+	}
+}
+
+// execute runs a claimed schedule and records the outcome. It uses its own context
+// so a shutdown lets the job finish rather than abandoning it half-done.
+func (s *Scheduler) execute(sched Schedule) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.JobTimeout)
+	defer cancel()
+
+	run, err := s.repo.CreateRun(ctx, ScheduleRun{
+		ID:         uuid.New(),
+		ScheduleID: sched.ID,
+		Status:     RunStatusRunning,
+		StartedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("Error recording run of schedule %s: %v", sched.ID, err)
+		return
+	}
+
+	output, err := s.runJob(ctx, sched)
+	status := RunStatusSucceeded
+	var runErr *string
+	if err != nil {
+		status = RunStatusFailed
+		msg := err.Error()
+		runErr = &msg
+		log.Printf("Schedule %s (%s) failed: %v", sched.Name, sched.Job, err)
+	}
+
+	if err := s.repo.FinishRun(context.Background(), run.ID, status, output, runErr); err != nil {
+		log.Printf("Error recording outcome of schedule %s: %v", sched.ID, err)
+	}
+}
+
+// runJob looks up and calls the job, turning a panic into a failed run
+func (s *Scheduler) runJob(ctx context.Context, sched Schedule) (output string, err error) {
+	s.mu.RLock()
+	fn, ok := s.jobs[sched.Job]
+	s.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("job %q is not registered", sched.Job)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return fn(ctx, sched.Params)
+}
+
+// claimOperations starts queued operations while this instance has free slots
+func (s *Scheduler) claimOperations(ctx context.Context) {
+	for {
+		select {
+		case s.opSlots <- struct{}{}:
+		default:
+			return
+		}
+
+		op, input, err := s.ops.ClaimOperation(ctx, time.Now().Add(s.OperationLease))
+		if err != nil || op == nil {
+			<-s.opSlots
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Error claiming operation: %v", err)
+			}
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() { <-s.opSlots }()
+			s.process(*op, input)
+		}()
+	}
+}
+
+// process runs a claimed operation and records the outcome. Like execute, it uses
+// its own context so a shutdown does not abandon the operation half-done.
+func (s *Scheduler) process(op Operation, input []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.JobTimeout)
+	defer cancel()
+
+	tracker := &OperationTracker{repo: s.ops, lease: s.OperationLease, op: op}
+	result, err := s.runOperation(ctx, op, input, tracker)
+	if saveErr := tracker.Save(ctx); saveErr != nil {
+		log.Printf("Error saving progress of operation %s: %v", op.ID, saveErr)
+	}
+
+	status := OperationSucceeded
+	var opErr *string
+	if err != nil {
+		status = OperationFailed
+		msg := err.Error()
+		opErr = &msg
+		log.Printf("Operation %s (%s) failed: %v", op.ID, op.Kind, err)
+	}
+	if err := s.ops.FinishOperation(context.Background(), op.ID, status, result, opErr); err != nil {
+		log.Printf("Error recording outcome of operation %s: %v", op.ID, err)
+	}
+}
+
+// runOperation looks up and calls the operation function, turning a panic into a failure
+func (s *Scheduler) runOperation(ctx context.Context, op Operation, input []byte, tracker *OperationTracker) (result string, err error) {
+	s.mu.RLock()
+	fn, ok := s.operations[op.Kind]
+	s.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("operation kind %q is not registered", op.Kind)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("operation panicked: %v", p)
+		}
+	}()
+	return fn(ctx, input, tracker)
+}
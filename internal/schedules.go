@@ -0,0 +1,277 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of a schedule run
+const (
+	RunStatusRunning   = "running"
+	RunStatusSucceeded = "succeeded"
+	RunStatusFailed    = "failed"
+)
+
+// ErrScheduleNotFound is returned when a schedule does not exist
+var ErrScheduleNotFound = newDomainError(ErrNotFound, "schedule not found")
+
+// Schedule runs a registered job on a cron expression
+type Schedule struct {
+	ID       uuid.UUID       `json:"id"`
+	Name     string          `json:"name"`
+	Cron     string          `json:"cron"`
+	Timezone string          `json:"timezone"`
+	Job      string          `json:"job"`
+	Params   json.RawMessage `json:"params"`
+	Enabled  bool            `json:"enabled"`
+	// NextRunAt is nil while the schedule is disabled
+	NextRunAt *time.Time `json:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at"`
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ScheduleRun is one execution of a schedule
+type ScheduleRun struct {
+	ID         uuid.UUID  `json:"id"`
+	ScheduleID uuid.UUID  `json:"schedule_id"`
+	Status     string     `json:"status"`
+	Output     string     `json:"output"`
+	Error      *string    `json:"error"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// NextActivation computes when s should next run after t, honoring its timezone.
+// It returns nil for disabled schedules.
+func (s *Schedule) NextActivation(t time.Time) (*time.Time, error) {
+	if !s.Enabled {
+		return nil, nil
+	}
+	cron, err := ParseCron(s.Cron)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", s.Timezone)
+	}
+	next := cron.Next(t.In(loc))
+	if next.IsZero() {
+		return nil, errors.New("cron expression never matches")
+	}
+	next = next.UTC()
+	return &next, nil
+}
+
+type ScheduleRepository struct {
+	db *sql.DB
+}
+
+// NewScheduleRepository creates a new schedule repository
+func NewScheduleRepository(db *sql.DB) *ScheduleRepository {
+	return &ScheduleRepository{db: db}
+}
+
+const scheduleColumns = `id, name, cron, timezone, job, params, enabled, next_run_at, last_run_at, created_by, created_at, updated_at`
+
+func scanSchedule(row rowScanner, s *Schedule) error {
+	var params []byte
+	err := row.Scan(
+		&s.ID,
+		&s.Name,
+		&s.Cron,
+		&s.Timezone,
+		&s.Job,
+		&params,
+		&s.Enabled,
+		&s.NextRunAt,
+		&s.LastRunAt,
+		&s.CreatedBy,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+	s.Params = params
+	return err
+}
+
+const scheduleRunColumns = `id, schedule_id, status, output, error, started_at, finished_at`
+
+func scanScheduleRun(row rowScanner, run *ScheduleRun) error {
+	return row.Scan(&run.ID, &run.ScheduleID, &run.Status, &run.Output, &run.Error, &run.StartedAt, &run.FinishedAt)
+}
+
+// CreateSchedule stores a new schedule
+func (r *ScheduleRepository) CreateSchedule(ctx context.Context, s Schedule) (*Schedule, error) {
+	query := `
+		INSERT INTO schedules (id, name, cron, timezone, job, params, enabled, next_run_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING ` + scheduleColumns
+
+	row := traced(ctx, r.db).QueryRowContext(ctx, query, s.ID, s.Name, s.Cron, s.Timezone, s.Job, []byte(s.Params), s.Enabled, s.NextRunAt, s.CreatedBy)
+
+	var created Schedule
+	if err := scanSchedule(row, &created); err != nil {
+		return nil, fmt.Errorf("failed to create schedule: %w", err)
+	}
+	return &created, nil
+}
+
+// ListSchedules returns every schedule ordered by name
+func (r *ScheduleRepository) ListSchedules(ctx context.Context) ([]Schedule, error) {
+	return r.querySchedules(ctx, `SELECT `+scheduleColumns+` FROM schedules ORDER BY name, id`)
+}
+
+// GetSchedule retrieves a schedule by ID
+func (r *ScheduleRepository) GetSchedule(ctx context.Context, id uuid.UUID) (*Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM schedules WHERE id = $1`
+
+	var s Schedule
+	if err := scanSchedule(traced(ctx, r.db).QueryRowContext(ctx, query, id), &s); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrScheduleNotFound
+		}
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+	return &s, nil
+}
+
+// UpdateSchedule saves the editable fields of a schedule
+func (r *ScheduleRepository) UpdateSchedule(ctx context.Context, s Schedule) (*Schedule, error) {
+	query := `
+		UPDATE schedules
+		SET name = $2, cron = $3, timezone = $4, params = $5, enabled = $6, next_run_at = $7, updated_at = NOW()
+		WHERE id = $1
+		RETURNING ` + scheduleColumns
+
+	row := traced(ctx, r.db).QueryRowContext(ctx, query, s.ID, s.Name, s.Cron, s.Timezone, []byte(s.Params), s.Enabled, s.NextRunAt)
+
+	var updated Schedule
+	if err := scanSchedule(row, &updated); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrScheduleNotFound
+		}
+		return nil, fmt.Errorf("failed to update schedule: %w", err)
+	}
+	return &updated, nil
+}
+
+// DeleteSchedule removes a schedule and its run history
+func (r *ScheduleRepository) DeleteSchedule(ctx context.Context, id uuid.UUID) error {
+	res, err := traced(ctx, r.db).ExecContext(ctx, `DELETE FROM schedules WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+// DueSchedules returns the enabled schedules whose next run is at or before now
+func (r *ScheduleRepository) DueSchedules(ctx context.Context, now time.Time) ([]Schedule, error) {
+	return r.querySchedules(ctx, `
+		SELECT `+scheduleColumns+`
+		FROM schedules
+		WHERE enabled AND next_run_at <= $1
+		ORDER BY next_run_at`, now)
+}
+
+// ClaimSchedule moves a due schedule to its next activation. It only succeeds when
+// next_run_at still equals expected, so when several instances poll at once exactly
+// one of them runs the job.
+func (r *ScheduleRepository) ClaimSchedule(ctx context.Context, id uuid.UUID, expected time.Time, next *time.Time) (bool, error) {
+	res, err := traced(ctx, r.db).ExecContext(ctx, `
+		UPDATE schedules
+		SET next_run_at = $3, last_run_at = NOW()
+		WHERE id = $1 AND enabled AND next_run_at = $2`, id, expected, next)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim schedule: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// CreateRun records the start of a schedule run
+func (r *ScheduleRepository) CreateRun(ctx context.Context, run ScheduleRun) (*ScheduleRun, error) {
+	query := `
+		INSERT INTO schedule_runs (id, schedule_id, status, started_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + scheduleRunColumns
+
+	var created ScheduleRun
+	row := traced(ctx, r.db).QueryRowContext(ctx, query, run.ID, run.ScheduleID, run.Status, run.StartedAt)
+	if err := scanScheduleRun(row, &created); err != nil {
+		return nil, fmt.Errorf("failed to create schedule run: %w", err)
+	}
+	return &created, nil
+}
+
+// FinishRun records the outcome of a schedule run
+func (r *ScheduleRepository) FinishRun(ctx context.Context, id uuid.UUID, status, output string, runErr *string) error {
+	_, err := traced(ctx, r.db).ExecContext(ctx, `
+		UPDATE schedule_runs
+		SET status = $2, output = $3, error = $4, finished_at = NOW()
+		WHERE id = $1`, id, status, output, runErr)
+	if err != nil {
+		return fmt.Errorf("failed to finish schedule run: %w", err)
+	}
+	return nil
+}
+
+// ListRuns returns the most recent runs of a schedule, newest first
+func (r *ScheduleRepository) ListRuns(ctx context.Context, scheduleID uuid.UUID, limit int) ([]ScheduleRun, error) {
+	query := `
+		SELECT ` + scheduleRunColumns + `
+		FROM schedule_runs
+		WHERE schedule_id = $1
+		ORDER BY started_at DESC
+		LIMIT $2`
+
+	rows, err := traced(ctx, r.db).QueryContext(ctx, query, scheduleID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedule runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []ScheduleRun{}
+	for rows.Next() {
+		var run ScheduleRun
+		if err := scanScheduleRun(rows, &run); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating schedule runs: %w", err)
+	}
+	return runs, nil
+}
+
+func (r *ScheduleRepository) querySchedules(ctx context.Context, query string, args ...any) ([]Schedule, error) {
+	rows, err := traced(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []Schedule{}
+	for rows.Next() {
+		var s Schedule
+		if err := scanSchedule(rows, &s); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		schedules = append(schedules, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating schedules: %w", err)
+	}
+	return schedules, nil
+}
@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// schemaMigration is what a migration creates that the application relies on: the
+// columns it adds by table and its indexes. A table or view created without listing
+// columns, such as a materialized view, is only checked to exist.
+type schemaMigration struct {
+	File    string
+	Columns map[string][]string
+	Indexes []string
+}
+
+// expectedSchema mirrors migrations/, in order. A migration adding a table, column or
+// index must be added here too; TestExpectedSchemaMatchesMigrations checks they agree.
+var expectedSchema = []schemaMigration{
+	{"001_create_events_table.sql", map[string][]string{"events": {"id", "title", "description", "start_time", "end_time", "created_at", "updated_at"}}, []string{"idx_events_start_time", "idx_events_created_at"}},
+	{"002_add_event_location.sql", map[string][]string{"events": {"location", "latitude", "longitude"}}, nil},
+	// 003_time_ordered_ids.sql changes no table
+	{"004_add_description_format.sql", map[string][]string{"events": {"description_format"}}, nil},
+	{"005_create_api_tokens_table.sql", map[string][]string{"api_tokens": {"id", "user_id", "name", "token_hash", "scopes", "expires_at", "created_at", "last_used_at"}}, []string{"idx_api_tokens_user_id"}},
+	{"006_create_schedules_tables.sql", map[string][]string{"schedules": {"id", "name", "cron", "timezone", "job", "params", "enabled", "next_run_at", "last_run_at", "created_by", "created_at", "updated_at"}, "schedule_runs": {"id", "schedule_id", "status", "output", "error", "started_at", "finished_at"}}, []string{"idx_schedules_due", "idx_schedule_runs_schedule"}},
+	{"007_create_digest_subscriptions_table.sql", map[string][]string{"digest_subscriptions": {"user_id", "email", "timezone", "language", "enabled", "last_sent_at", "created_at", "updated_at"}}, nil},
+	{"008_create_calendars_and_snapshots.sql", map[string][]string{"calendars": {"id", "name", "owner_id", "created_at", "updated_at"}, "events": {"calendar_id"}, "snapshots": {"id", "name", "event_count", "created_by", "created_at"}, "snapshot_events": {"snapshot_id", "event_id", "calendar_id", "title", "description", "description_format", "start_time", "end_time", "location", "latitude", "longitude", "created_at", "updated_at"}}, []string{"idx_events_calendar_id"}},
+	{"009_create_operations_table.sql", map[string][]string{"operations": {"id", "kind", "status", "input", "total", "processed", "succeeded", "failed", "errors", "result", "error", "created_by", "locked_until", "created_at", "started_at", "finished_at"}}, []string{"idx_operations_queue"}},
+	{"010_add_event_sync.sql", map[string][]string{"events": {"version"}, "event_tombstones": {"id", "calendar_id", "version", "deleted_at"}}, []string{"idx_events_version", "idx_event_tombstones_version"}},
+	{"011_create_policy_rules.sql", map[string][]string{"policy_rules": {"id", "name", "expression", "message", "calendar_id", "enabled", "created_by", "created_at", "updated_at"}}, nil},
+	{"012_add_event_approval.sql", map[string][]string{"events": {"status", "submitted_by"}, "event_reviews": {"id", "event_id", "decision", "comment", "reviewer", "created_at"}}, []string{"idx_events_pending", "idx_event_reviews_event"}},
+	{"013_create_event_comments.sql", map[string][]string{"event_comments": {"id", "event_id", "author", "body", "mentions", "created_at"}}, []string{"idx_event_comments_thread"}},
+	{"014_create_event_activity.sql", map[string][]string{"event_activity": {"id", "event_id", "calendar_id", "action", "title", "actor", "published", "occurred_at"}}, []string{"idx_event_activity_feed", "idx_event_activity_calendar"}},
+	{"015_create_event_reminders.sql", map[string][]string{"event_reminders": {"id", "event_id", "owner_id", "offset_minutes", "channel", "target", "language", "attempts", "sent_at", "last_error", "created_at", "updated_at"}}, []string{"idx_event_reminders_event", "idx_event_reminders_unsent"}},
+	{"016_create_push_subscriptions.sql", map[string][]string{"push_subscriptions": {"id", "user_id", "endpoint", "p256dh", "auth", "user_agent", "created_at", "updated_at"}}, []string{"idx_push_subscriptions_user"}},
+	{"017_create_device_tokens.sql", map[string][]string{"device_tokens": {"id", "user_id", "platform", "token", "created_at", "updated_at"}}, []string{"idx_device_tokens_user"}},
+	{"018_create_event_covers.sql", map[string][]string{"event_covers": {"event_id", "content_type", "width", "height", "bytes", "etag", "updated_at"}}, nil},
+	{"019_add_calendar_feed_version.sql", map[string][]string{"calendars": {"feed_version"}}, nil},
+	{"020_create_resources.sql", map[string][]string{"resources": {"id", "name", "kind", "capacity", "created_at", "updated_at"}, "resource_bookings": {"resource_id", "event_id", "period"}}, []string{"idx_resource_bookings_event"}},
+	{"021_add_exclusive_calendars.sql", map[string][]string{"calendars": {"exclusive"}, "events": {"in_exclusive_calendar"}}, nil},
+	{"022_add_event_ticketing.sql", map[string][]string{"events": {"price_cents", "currency", "ticket_quota"}, "event_ticket_counts": {"event_id", "reserved"}, "ticket_reservations": {"id", "event_id", "holder_id", "quantity", "status", "amount_cents", "currency", "expires_at", "created_at", "updated_at"}}, []string{"idx_ticket_reservations_event", "idx_ticket_reservations_held"}},
+	{"023_add_reservation_payments.sql", map[string][]string{"ticket_reservations": {"payment_provider", "checkout_session_id", "checkout_url", "payment_id"}}, []string{"idx_ticket_reservations_checkout"}},
+	{"024_create_organizations.sql", map[string][]string{"organizations": {"id", "name", "created_by", "created_at", "updated_at"}, "organization_members": {"organization_id", "user_id", "role", "created_at"}, "organization_invitations": {"id", "organization_id", "email", "role", "token_hash", "invited_by", "expires_at", "accepted_by", "accepted_at", "created_at"}, "calendars": {"organization_id"}}, []string{"idx_organization_members_user", "idx_organization_invitations_org", "idx_calendars_organization"}},
+	{"025_create_calendar_delegates.sql", map[string][]string{"calendar_delegates": {"calendar_id", "user_id", "permissions", "expires_at", "granted_by", "created_at", "updated_at"}}, []string{"idx_calendar_delegates_user"}},
+	{"026_add_calendar_visibility.sql", map[string][]string{"calendars": {"visibility"}}, nil},
+	{"027_add_activity_changes.sql", map[string][]string{"event_activity": {"changes"}}, nil},
+	{"028_create_webhooks.sql", map[string][]string{"webhooks": {"id", "owner_id", "url", "events", "calendar_id", "created_at", "updated_at"}, "webhook_deliveries": {"id", "webhook_id", "event_type", "event_id", "payload", "status", "attempt_count", "next_attempt_at", "created_at", "updated_at"}, "webhook_delivery_attempts": {"id", "delivery_id", "attempt", "response_code", "latency_ms", "error", "replay", "attempted_at"}}, []string{"idx_webhooks_owner", "idx_webhook_deliveries_webhook", "idx_webhook_deliveries_due", "idx_webhook_delivery_attempts_delivery"}},
+	{"029_add_webhook_secrets.sql", map[string][]string{"webhooks": {"secret", "previous_secret", "previous_secret_expires_at"}}, nil},
+	{"030_create_ingest_sources.sql", map[string][]string{"ingest_sources": {"id", "name", "secret", "calendar_id", "filter", "mapping", "enabled", "created_by", "created_at", "updated_at"}}, nil},
+	{"031_add_event_client_keys.sql", map[string][]string{"events": {"client_key"}}, []string{"events_client_key_unique"}},
+	{"032_add_event_external_ids.sql", map[string][]string{"events": {"external_id", "source"}}, nil},
+	{"033_create_event_stats.sql", map[string][]string{"event_stats_daily": {"day", "calendar_id", "events", "minutes"}}, []string{"event_stats_daily_key", "event_stats_daily_calendar"}},
+	{"034_create_events_archive.sql", map[string][]string{"events_archive": nil}, []string{"idx_events_archive_id", "idx_events_archive_calendar_id"}},
+	{"035_create_maintenance_mode.sql", map[string][]string{"maintenance_mode": {"id", "mode", "message", "retry_after_seconds", "updated_by", "updated_at"}}, nil},
+	{"036_create_auth_failures.sql", map[string][]string{"auth_failures": {"key", "failures", "window_start", "lockouts", "locked_until"}}, []string{"idx_auth_failures_locked"}},
+	{"037_create_two_factor.sql", map[string][]string{"user_two_factor": {"user_id", "secret", "last_used_step", "confirmed_at", "created_at"}, "two_factor_recovery_codes": {"user_id", "code_hash", "used_at"}, "organizations": {"require_two_factor"}}, nil},
+	{"038_add_token_sessions.sql", map[string][]string{"api_tokens": {"created_ip", "user_agent", "last_used_ip", "revoked_at"}}, nil},
+	{"039_create_user_preferences.sql", map[string][]string{"user_preferences": {"user_id", "timezone", "reminder_offsets", "week_start", "updated_at"}}, nil},
+	{"040_add_event_title_search.sql", nil, []string{"idx_events_title_trgm", "idx_events_title_prefix"}},
+	{"041_add_event_full_text_search.sql", nil, []string{"idx_events_title_fts"}},
+	{"042_add_snapshot_event_review.sql", map[string][]string{"snapshot_events": {"status", "submitted_by"}}, nil},
+	{"043_add_snapshot_event_ticketing.sql", map[string][]string{"snapshot_events": {"price_cents", "currency", "ticket_quota"}}, nil},
+}
+
+// SchemaObject is a table, column or index missing from the database, with the
+// migration that creates it
+type SchemaObject struct {
+	// Kind is "table", "column" or "index"
+	Kind      string
+	Name      string
+	Migration string
+}
+
+// SchemaError reports every object of the expected schema the database lacks, so one
+// startup shows all that is missing rather than the first query to fail
+type SchemaError struct {
+	Missing []SchemaObject
+}
+
+func (e *SchemaError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "database schema is missing %d objects; run the migrations from %s on:", len(e.Missing), e.Missing[0].Migration)
+	for _, m := range e.Missing {
+		fmt.Fprintf(&b, "\n  %s %s (%s)", m.Kind, m.Name, m.Migration)
+	}
+	return b.String()
+}
+
+const qSchemaObjects = `
+	SELECT c.relname, COALESCE(a.attname, '')
+	FROM pg_class c
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	LEFT JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped AND c.relkind IN ('r', 'p', 'v', 'm')
+	WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p', 'v', 'm', 'i', 'I')`
+
+// CheckSchema verifies that the tables, columns and indexes the migrations create
+// exist in db, returning a *SchemaError listing those that do not
+func CheckSchema(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, qSchemaObjects)
+	if err != nil {
+		return fmt.Errorf("failed to read database schema: %w", err)
+	}
+	defer rows.Close()
+	present := map[string]bool{}
+	for rows.Next() {
+		var relation, column string
+		if err := rows.Scan(&relation, &column); err != nil {
+			return fmt.Errorf("failed to read database schema: %w", err)
+		}
+		present[relation] = true
+		if column != "" {
+			present[relation+"."+column] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read database schema: %w", err)
+	}
+	if missing := missingSchema(present); len(missing) > 0 {
+		return &SchemaError{Missing: missing}
+	}
+	return nil
+}
+
+// missingSchema returns the expected objects absent from present, which holds the
+// names of relations and indexes and the table.column names of columns. The columns
+// of a missing table are not listed on their own.
+func missingSchema(present map[string]bool) []SchemaObject {
+	var missing []SchemaObject
+	reported := map[string]bool{}
+	for _, m := range expectedSchema {
+		tables := make([]string, 0, len(m.Columns))
+		for table := range m.Columns {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+		for _, table := range tables {
+			if !present[table] {
+				if !reported[table] {
+					reported[table] = true
+					missing = append(missing, SchemaObject{Kind: "table", Name: table, Migration: m.File})
+				}
+				continue
+			}
+			for _, column := range m.Columns[table] {
+				if name := table + "." + column; !present[name] {
+					missing = append(missing, SchemaObject{Kind: "column", Name: name, Migration: m.File})
+				}
+			}
+		}
+		for _, index := range m.Indexes {
+			if !present[index] {
+				missing = append(missing, SchemaObject{Kind: "index", Name: index, Migration: m.File})
+			}
+		}
+	}
+	return missing
+}
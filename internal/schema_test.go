@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	sqlComment     = regexp.MustCompile(`--[^\n]*`)
+	sqlCreateTable = regexp.MustCompile(`(?is)CREATE TABLE IF NOT EXISTS (\w+)\s*\((.*?)\n\);`)
+	sqlCreateLike  = regexp.MustCompile(`(?i)CREATE TABLE IF NOT EXISTS (\w+) \(LIKE|CREATE MATERIALIZED VIEW IF NOT EXISTS (\w+)`)
+	sqlAddColumn   = regexp.MustCompile(`(?i)ALTER TABLE (\w+) ADD COLUMN IF NOT EXISTS (\w+)`)
+	sqlCreateIndex = regexp.MustCompile(`(?i)CREATE (?:UNIQUE )?INDEX (?:IF NOT EXISTS )?(\w+)\s+ON`)
+)
+
+// parseMigration lists the tables, columns and indexes a migration creates, as
+// expectedSchema declares them; the columns of views and LIKE tables are not parsed
+func parseMigration(sql string) (tables map[string][]string, indexes []string) {
+	sql = sqlComment.ReplaceAllString(sql, "")
+	tables = map[string][]string{}
+	for _, m := range sqlCreateTable.FindAllStringSubmatch(sql, -1) {
+		tables[m[1]] = nil
+		depth, start := 0, 0
+		body := m[2] + ","
+		for i, c := range body {
+			switch c {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			case ',':
+				if depth > 0 {
+					continue
+				}
+				fields := strings.Fields(body[start:i])
+				start = i + 1
+				if len(fields) == 0 {
+					continue
+				}
+				switch strings.ToUpper(fields[0]) {
+				case "PRIMARY", "UNIQUE", "CONSTRAINT", "CHECK", "FOREIGN", "EXCLUDE":
+					continue
+				}
+				tables[m[1]] = append(tables[m[1]], fields[0])
+			}
+		}
+	}
+	for _, m := range sqlCreateLike.FindAllStringSubmatch(sql, -1) {
+		tables[m[1]+m[2]] = nil
+	}
+	for _, m := range sqlAddColumn.FindAllStringSubmatch(sql, -1) {
+		tables[m[1]] = append(tables[m[1]], m[2])
+	}
+	for _, m := range sqlCreateIndex.FindAllStringSubmatch(sql, -1) {
+		indexes = append(indexes, m[1])
+	}
+	return tables, indexes
+}
+
+func TestExpectedSchemaMatchesMigrations(t *testing.T) {
+	files, err := filepath.Glob("../migrations/*.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	declared := map[string]schemaMigration{}
+	for _, m := range expectedSchema {
+		declared[m.File] = m
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		tables, indexes := parseMigration(string(data))
+		name := filepath.Base(file)
+		m, ok := declared[name]
+		if len(tables) == 0 && len(indexes) == 0 {
+			assert.False(t, ok, "%s creates nothing to check", name)
+			continue
+		}
+		require.True(t, ok, "add %s to expectedSchema", name)
+		assert.ElementsMatch(t, indexes, m.Indexes, name)
+		for table, columns := range tables {
+			require.Contains(t, m.Columns, table, name)
+			if columns != nil {
+				assert.Equal(t, columns, m.Columns[table], "%s: columns of %s", name, table)
+			}
+		}
+		assert.Len(t, m.Columns, len(tables), name)
+		delete(declared, name)
+	}
+	assert.Empty(t, declared, "expectedSchema lists migrations that do not exist")
+}
+
+func TestMissingSchema(t *testing.T) {
+	present := map[string]bool{}
+	for _, m := range expectedSchema {
+		for table, columns := range m.Columns {
+			present[table] = true
+			for _, c := range columns {
+				present[table+"."+c] = true
+			}
+		}
+		for _, index := range m.Indexes {
+			present[index] = true
+		}
+	}
+	assert.Empty(t, missingSchema(present))
+
+	// A database the last migrations never ran on
+	delete(present, "events.source")
+	delete(present, "events_archive")
+	delete(present, "idx_events_archive_id")
+	missing := missingSchema(present)
+	assert.Equal(t, []SchemaObject{
+		{Kind: "column", Name: "events.source", Migration: "032_add_event_external_ids.sql"},
+		{Kind: "table", Name: "events_archive", Migration: "034_create_events_archive.sql"},
+		{Kind: "index", Name: "idx_events_archive_id", Migration: "034_create_events_archive.sql"},
+	}, missing)
+	err := &SchemaError{Missing: missing}
+	assert.Contains(t, err.Error(), "missing 3 objects; run the migrations from 032_add_event_external_ids.sql on")
+	assert.Contains(t, err.Error(), "\n  table events_archive (034_create_events_archive.sql)")
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Bounds of event searches
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+	MaxSearchLength    = 200
+)
+
+// SearchQuery asks for the published events matching words, best matches first
+type SearchQuery struct {
+	Text  string
+	Limit int
+	// CalendarID limits the search to one calendar
+	CalendarID *uuid.UUID
+}
+
+// EventSearcher is implemented by repositories and search backends that can search
+// event text. Like suggestions, searches only return published events outside busy
+// calendars, so they never reveal what busy blocks hide.
+type EventSearcher interface {
+	SearchEvents(ctx context.Context, q SearchQuery) ([]EventSummary, error)
+}
+
+// SearchEvents searches repo when it supports it. Other repositories are listed and
+// matched in memory against every word of q.Text, without regard to calendar visibility.
+func SearchEvents(ctx context.Context, repo EventRepositoryInterface, q SearchQuery) ([]EventSummary, error) {
+	if s, ok := repo.(EventSearcher); ok {
+		return s.SearchEvents(ctx, q)
+	}
+	events, err := repo.GetEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	words := strings.Fields(strings.ToLower(q.Text))
+	results := []EventSummary{}
+	for _, e := range events {
+		if len(results) == q.Limit {
+			break
+		}
+		if !e.Published() || (q.CalendarID != nil && !sameCalendar(e.CalendarID, q.CalendarID)) {
+			continue
+		}
+		title := strings.ToLower(e.Title)
+		matched := len(words) > 0
+		for _, w := range words {
+			matched = matched && strings.Contains(title, w)
+		}
+		if matched {
+			results = append(results, e.Summary())
+		}
+	}
+	return results, nil
+}
+
+// SearchEvents runs a PostgreSQL full text search of the titles, ranked by ts_rank.
+// q.Text is read as a web search: quoted phrases, OR and -excluded words.
+func (r *EventRepository) SearchEvents(ctx context.Context, q SearchQuery) ([]EventSummary, error) {
+	return r.querySummaries(ctx, "search events", qSearchEvents.SQL, q.Text, q.CalendarID, q.Limit)
+}
+
+// EventSummariesByID returns the summaries of the events of ids that searches may
+// return, in the order of ids. Search backends holding only IDs read the events here,
+// so results always reflect the database.
+func (r *EventRepository) EventSummariesByID(ctx context.Context, ids []uuid.UUID) ([]EventSummary, error) {
+	if len(ids) == 0 {
+		return []EventSummary{}, nil
+	}
+	summaries, err := r.querySummaries(ctx, "read searched events", qSearchableEventsByID.SQL, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]EventSummary, len(summaries))
+	for _, s := range summaries {
+		byID[s.ID] = s
+	}
+	ordered := summaries[:0]
+	for _, id := range ids {
+		if s, ok := byID[id]; ok {
+			ordered = append(ordered, s)
+		}
+	}
+	return ordered, nil
+}
+
+// querySummaries runs a query selecting searchEventColumns
+func (r *EventRepository) querySummaries(ctx context.Context, action, query string, args ...any) ([]EventSummary, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s: %w", action, err)
+	}
+	defer rows.Close()
+
+	summaries := []EventSummary{}
+	for rows.Next() {
+		var s EventSummary
+		if err := scanEventSummary(rows, &s); err != nil {
+			return nil, fmt.Errorf("failed to scan event summary: %w", err)
+		}
+		summaries = append(summaries, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating event summaries: %w", err)
+	}
+	return summaries, nil
+}
@@ -0,0 +1,28 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchEventsInMemory(t *testing.T) {
+	calendarID := uuid.New()
+	repo := &listedEvents{events: []EventDB{
+		{ID: uuid.New(), Title: "Weekly Standup"},
+		{ID: uuid.New(), Title: "Standup retro", Status: EventStatusPending},
+		{ID: uuid.New(), Title: "Standup planning", CalendarID: &calendarID},
+		{ID: uuid.New(), Title: "Lunch"},
+	}}
+	results, err := SearchEvents(context.Background(), repo, SearchQuery{Text: "standup", Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, results, 2, "unpublished events are never found")
+
+	results, err = SearchEvents(context.Background(), repo, SearchQuery{Text: "planning standup", Limit: 10, CalendarID: &calendarID})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "Standup planning", results[0].Title)
+}
@@ -0,0 +1,244 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secretKeys are the settings that may come from a secrets manager instead of the
+// environment. Secrets are stored under the same names as the environment variables.
+var secretKeys = []string{"DATABASE_URL", "API_KEY", "HMAC_CLIENTS", "ENCRYPTION_KEYS", "SMTP_USERNAME", "SMTP_PASSWORD", "VAPID_PRIVATE_KEY", "FEED_SIGNING_KEY", "STRIPE_SECRET_KEY", "STRIPE_WEBHOOK_SECRET"}
+
+// SecretsProvider fetches the current value of every secret it holds
+type SecretsProvider interface {
+	FetchSecrets(ctx context.Context) (map[string]string, error)
+}
+
+// NewSecretsProvider builds the provider selected by cfg.SecretsProvider
+func NewSecretsProvider(cfg Config) (SecretsProvider, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	switch cfg.SecretsProvider {
+	case "env":
+		return envSecretsProvider{}, nil
+	case "vault":
+		addr, token, path := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN"), os.Getenv("VAULT_SECRET_PATH")
+		if addr == "" || token == "" || path == "" {
+			return nil, errors.New("VAULT_ADDR, VAULT_TOKEN and VAULT_SECRET_PATH are required")
+		}
+		return &vaultSecretsProvider{
+			client: client,
+			addr:   strings.TrimRight(addr, "/"),
+			token:  token,
+			mount:  getEnv("VAULT_MOUNT", "secret"),
+			path:   strings.Trim(path, "/"),
+		}, nil
+	case "aws":
+		creds, err := awsCredentialsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		secretID := os.Getenv("AWS_SECRET_ID")
+		if secretID == "" {
+			return nil, errors.New("AWS_SECRET_ID is required")
+		}
+		return &awsSecretsProvider{client: client, creds: creds, secretID: secretID}, nil
+	default:
+		return nil, fmt.Errorf("unknown SECRETS_PROVIDER %q", cfg.SecretsProvider)
+	}
+}
+
+// envSecretsProvider reads secrets from environment variables
+type envSecretsProvider struct{}
+
+func (envSecretsProvider) FetchSecrets(ctx context.Context) (map[string]string, error) {
+	secrets := map[string]string{}
+	for _, key := range secretKeys {
+		if v := os.Getenv(key); v != "" {
+			secrets[key] = v
+		}
+	}
+	return secrets, nil
+}
+
+// vaultSecretsProvider reads a KV version 2 secret from HashiCorp Vault
+type vaultSecretsProvider struct {
+	client *http.Client
+	addr   string
+	token  string
+	mount  string
+	path   string
+}
+
+func (p *vaultSecretsProvider) FetchSecrets(ctx context.Context) (map[string]string, error) {
+	url := fmt.Sprintf("%s/v1/%s/data/%s", p.addr, p.mount, p.path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Vault: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned %s", resp.Status)
+	}
+
+	var payload struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode Vault secret: %w", err)
+	}
+	return payload.Data.Data, nil
+}
+
+// awsSecretsProvider reads a JSON key/value secret from AWS Secrets Manager
+type awsSecretsProvider struct {
+	client   *http.Client
+	creds    awsCredentials
+	secretID string
+}
+
+func (p *awsSecretsProvider) FetchSecrets(ctx context.Context) (map[string]string, error) {
+	body, _ := json.Marshal(map[string]string{"SecretId": p.secretID})
+	url := fmt.Sprintf("https://secretsmanager.%s.amazonaws.com/", p.creds.Region)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signAWSRequest(req, body, p.creds, "secretsmanager", time.Now())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Secrets Manager: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("secrets manager returned %s", resp.Status)
+	}
+
+	var payload struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode secret: %w", err)
+	}
+	secrets := map[string]string{}
+	if err := json.Unmarshal([]byte(payload.SecretString), &secrets); err != nil {
+		return nil, fmt.Errorf("secret %s must be a JSON object of strings: %w", p.secretID, err)
+	}
+	return secrets, nil
+}
+
+// Secrets holds the latest fetched secrets and is safe for concurrent use
+type Secrets struct {
+	provider SecretsProvider
+
+	mu     sync.RWMutex
+	values map[string]string
+}
+
+// LoadSecrets fetches the initial secrets from provider
+func LoadSecrets(ctx context.Context, provider SecretsProvider) (*Secrets, error) {
+	values, err := provider.FetchSecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Secrets{provider: provider, values: values}, nil
+}
+
+// Get returns the current value of a secret, or "" when it is not set
+func (s *Secrets) Get(key string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.values[key]
+}
+
+// Apply overrides the secret-backed settings of cfg with the fetched values
+func (s *Secrets) Apply(cfg *Config) {
+	if v := s.Get("DATABASE_URL"); v != "" {
+		cfg.DatabaseURL = v
+	}
+	if v := s.Get("API_KEY"); v != "" {
+		cfg.APIKey = v
+	}
+	if v := s.Get("HMAC_CLIENTS"); v != "" {
+		cfg.HMACClients = parseHMACClients(v)
+	}
+	if v := s.Get("ENCRYPTION_KEYS"); v != "" {
+		cfg.EncryptionKeys = v
+	}
+	if v := s.Get("SMTP_USERNAME"); v != "" {
+		cfg.SMTPUsername = v
+	}
+	if v := s.Get("SMTP_PASSWORD"); v != "" {
+		cfg.SMTPPassword = v
+	}
+	if v := s.Get("VAPID_PRIVATE_KEY"); v != "" {
+		cfg.VAPIDPrivateKey = v
+	}
+	if v := s.Get("FEED_SIGNING_KEY"); v != "" {
+		cfg.FeedSigningKey = v
+	}
+	if v := s.Get("STRIPE_SECRET_KEY"); v != "" {
+		cfg.StripeSecretKey = v
+	}
+	if v := s.Get("STRIPE_WEBHOOK_SECRET"); v != "" {
+		cfg.StripeWebhookSecret = v
+	}
+}
+
+// Watch re-fetches secrets every interval until ctx is done. DATABASE_URL changes take
+// effect on the next new database connection; other settings are read once at startup,
+// so rotating them is logged as requiring a restart.
+func (s *Secrets) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fetchCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		values, err := s.provider.FetchSecrets(fetchCtx)
+		cancel()
+		if err != nil {
+			log.Printf("Warning: failed to refresh secrets, keeping previous values: %v", err)
+			continue
+		}
+
+		s.mu.Lock()
+		for _, key := range secretKeys {
+			if s.values[key] == values[key] {
+				continue
+			}
+			if key == "DATABASE_URL" {
+				log.Printf("Secret %s rotated; new database connections will use it", key)
+			} else {
+				log.Printf("Secret %s rotated; restart the server to apply it", key)
+			}
+		}
+		s.values = values
+		s.mu.Unlock()
+	}
+}
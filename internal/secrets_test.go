@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVaultSecretsProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/secret/data/app/prod", r.URL.Path)
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"DATABASE_URL":"postgres://vault","API_KEY":"k1"},"metadata":{"version":3}}}`))
+	}))
+	defer srv.Close()
+
+	t.Setenv("VAULT_ADDR", srv.URL+"/")
+	t.Setenv("VAULT_TOKEN", "root")
+	t.Setenv("VAULT_SECRET_PATH", "/app/prod")
+	provider, err := NewSecretsProvider(Config{SecretsProvider: "vault"})
+	assert.NoError(t, err)
+
+	secrets, err := LoadSecrets(context.Background(), provider)
+	assert.NoError(t, err)
+
+	cfg := Config{DatabaseURL: "postgres://env", APIKey: "env-key", EncryptionKeys: "v1:abc"}
+	secrets.Apply(&cfg)
+	assert.Equal(t, "postgres://vault", cfg.DatabaseURL)
+	assert.Equal(t, "k1", cfg.APIKey)
+	// Settings missing from the secret keep their environment value
+	assert.Equal(t, "v1:abc", cfg.EncryptionKeys)
+}
+
+func TestVaultSecretsProviderForbidden(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "wrong")
+	t.Setenv("VAULT_SECRET_PATH", "app")
+	provider, err := NewSecretsProvider(Config{SecretsProvider: "vault"})
+	assert.NoError(t, err)
+
+	_, err = LoadSecrets(context.Background(), provider)
+	assert.Error(t, err)
+}
+
+func TestNewSecretsProviderValidation(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "")
+	_, err := NewSecretsProvider(Config{SecretsProvider: "vault"})
+	assert.Error(t, err)
+
+	_, err = NewSecretsProvider(Config{SecretsProvider: "consul"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// shadowTimeout bounds a shadow read; it runs after the caller has its answer
+const shadowTimeout = 5 * time.Second
+
+// maxShadowReads bounds the shadow reads in flight; reads beyond it are skipped
+// rather than queued, so a slow shadow never builds up load
+const maxShadowReads = 16
+
+// ShadowEventRepository serves every call from the primary repository and repeats a
+// share of the reads against a shadow implementation in the background, logging any
+// difference between the two answers. It is used to try out a new repository
+// implementation or database on live traffic without affecting responses.
+type ShadowEventRepository struct {
+	EventRepositoryInterface
+	shadow  EventRepositoryInterface
+	percent int
+	slots   chan struct{}
+}
+
+// NewShadowEventRepository shadows percent (0-100) of the reads of primary to shadow.
+// Writes only go to primary.
+func NewShadowEventRepository(primary, shadow EventRepositoryInterface, percent int) *ShadowEventRepository {
+	return &ShadowEventRepository{
+		EventRepositoryInterface: primary,
+		shadow:                   shadow,
+		percent:                  percent,
+		slots:                    make(chan struct{}, maxShadowReads),
+	}
+}
+
+// GetEvents implements EventRepositoryInterface
+func (r *ShadowEventRepository) GetEvents(ctx context.Context) ([]EventDB, error) {
+	events, err := r.EventRepositoryInterface.GetEvents(ctx)
+	if err == nil {
+		r.compareList(ctx, "GetEvents", events, func(ctx context.Context) ([]EventDB, error) {
+			return r.shadow.GetEvents(ctx)
+		})
+	}
+	return events, err
+}
+
+// GetEventByID implements EventRepositoryInterface
+func (r *ShadowEventRepository) GetEventByID(ctx context.Context, id uuid.UUID) (*EventDB, error) {
+	event, err := r.EventRepositoryInterface.GetEventByID(ctx, id)
+	if err != nil && !errors.Is(err, ErrEventNotFound) {
+		return event, err
+	}
+	var primary []EventDB
+	if event != nil {
+		primary = []EventDB{*event}
+	}
+	r.compareList(ctx, "GetEventByID", primary, func(ctx context.Context) ([]EventDB, error) {
+		e, err := r.shadow.GetEventByID(ctx, id)
+		if errors.Is(err, ErrEventNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []EventDB{*e}, nil
+	})
+	return event, err
+}
+
+// GetEventsBetween implements EventRepositoryInterface
+func (r *ShadowEventRepository) GetEventsBetween(ctx context.Context, from, to time.Time) ([]EventDB, error) {
+	events, err := r.EventRepositoryInterface.GetEventsBetween(ctx, from, to)
+	if err == nil {
+		r.compareList(ctx, "GetEventsBetween", events, func(ctx context.Context) ([]EventDB, error) {
+			return r.shadow.GetEventsBetween(ctx, from, to)
+		})
+	}
+	return events, err
+}
+
+// GetEventsByCalendar implements EventRepositoryInterface
+func (r *ShadowEventRepository) GetEventsByCalendar(ctx context.Context, calendarID uuid.UUID) ([]EventDB, error) {
+	events, err := r.EventRepositoryInterface.GetEventsByCalendar(ctx, calendarID)
+	if err == nil {
+		r.compareList(ctx, "GetEventsByCalendar", events, func(ctx context.Context) ([]EventDB, error) {
+			return r.shadow.GetEventsByCalendar(ctx, calendarID)
+		})
+	}
+	return events, err
+}
+
+// FilterEvents filters in the primary, and compares with the shadow's filtered listing
+func (r *ShadowEventRepository) FilterEvents(ctx context.Context, f *EventFilter) ([]EventDB, error) {
+	events, err := FilterEvents(ctx, r.EventRepositoryInterface, f)
+	if err == nil {
+		r.compareList(ctx, "FilterEvents", events, func(ctx context.Context) ([]EventDB, error) {
+			return FilterEvents(ctx, r.shadow, f)
+		})
+	}
+	return events, err
+}
+
+// GetEventSummaries summarizes in the primary. Summaries are not compared with the
+// shadow: the full listings they are cut from already are.
+func (r *ShadowEventRepository) GetEventSummaries(ctx context.Context, f *EventFilter) ([]EventSummary, error) {
+	return SummarizeEvents(ctx, r.EventRepositoryInterface, f)
+}
+
+// SearchEvents searches the primary; searches are not compared with the shadow
+func (r *ShadowEventRepository) SearchEvents(ctx context.Context, q SearchQuery) ([]EventSummary, error) {
+	return SearchEvents(ctx, r.EventRepositoryInterface, q)
+}
+
+// EventsVersion versions the listings of the wrapped repository when it supports it
+func (r *ShadowEventRepository) EventsVersion(ctx context.Context, source, externalID string) (*EventsVersion, error) {
+	return VersionEvents(ctx, r.EventRepositoryInterface, source, externalID)
+}
+
+// Ping checks the primary's database when it supports it
+func (r *ShadowEventRepository) Ping(ctx context.Context) error {
+	return pingRepository(ctx, r.EventRepositoryInterface)
+}
+
+// compareList runs read against the shadow in the background when the read is
+// sampled and a slot is free, and logs how its answer differs from primary
+func (r *ShadowEventRepository) compareList(ctx context.Context, method string, primary []EventDB, read func(ctx context.Context) ([]EventDB, error)) {
+	if r.percent < 100 && rand.Intn(100) >= r.percent {
+		return
+	}
+	// Reads inside a batch transaction see uncommitted writes the shadow cannot see
+	if _, inTx := ctx.Value(txKey{}).(*sql.Tx); inTx {
+		return
+	}
+	select {
+	case r.slots <- struct{}{}:
+	default:
+		return
+	}
+
+	// The shadow outlives the request but keeps its values, such as the request ID
+	primary = append([]EventDB(nil), primary...)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shadowTimeout)
+	go func() {
+		defer func() { <-r.slots }()
+		defer cancel()
+
+		requestID := RequestIDFromContext(ctx)
+		shadow, err := read(ctx)
+		if err != nil {
+			log.Printf("Shadow: %s failed on the shadow repository (request_id=%s): %v", method, requestID, err)
+			return
+		}
+		if diff := diffEvents(primary, shadow); diff != "" {
+			log.Printf("Shadow: %s diverged (request_id=%s): %s", method, requestID, diff)
+		}
+	}()
+}
+
+// diffEvents describes the first difference between two answers, or returns "" when
+// they hold the same events. Order is ignored.
+func diffEvents(primary, shadow []EventDB) string {
+	if len(primary) != len(shadow) {
+		return fmt.Sprintf("primary returned %d events, shadow %d", len(primary), len(shadow))
+	}
+	byID := make(map[uuid.UUID]EventDB, len(shadow))
+	for _, e := range shadow {
+		byID[e.ID] = e
+	}
+	for _, p := range primary {
+		s, ok := byID[p.ID]
+		switch {
+		case !ok:
+			return fmt.Sprintf("event %s missing from shadow", p.ID)
+		case !sameEventContent(p, s):
+			return fmt.Sprintf("event %s has different content", p.ID)
+		case p.Version != s.Version:
+			return fmt.Sprintf("event %s has version %d, shadow %d", p.ID, p.Version, s.Version)
+		case !p.CreatedAt.Equal(s.CreatedAt) || !p.UpdatedAt.Equal(s.UpdatedAt):
+			return fmt.Sprintf("event %s has different timestamps", p.ID)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ErrSnapshotNotFound is returned when a snapshot does not exist
+var ErrSnapshotNotFound = newDomainError(ErrNotFound, "snapshot not found")
+
+// Snapshot is a point-in-time copy of every event, kept in the database
+type Snapshot struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	EventCount int       `json:"event_count"`
+	CreatedBy  string    `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type SnapshotRepository struct {
+	db  *sql.DB
+	ids IDGenerator
+}
+
+// NewSnapshotRepository creates a new snapshot repository
+func NewSnapshotRepository(db *sql.DB) *SnapshotRepository {
+	return &SnapshotRepository{db: db, ids: NewIDGenerator("")}
+}
+
+// SetIDGenerator keys restored events with ids, the configured ID_STRATEGY, rather
+// than random UUIDv4
+func (r *SnapshotRepository) SetIDGenerator(ids IDGenerator) {
+	r.ids = ids
+}
+
+const snapshotColumns = `id, name, event_count, created_by, created_at`
+
+func scanSnapshot(row rowScanner, s *Snapshot) error {
+	return row.Scan(&s.ID, &s.Name, &s.EventCount, &s.CreatedBy, &s.CreatedAt)
+}
+
+// snapshotRestoredColumns are copied from snapshot_events back into events on restore.
+// A column added to events goes here and in a migration adding it to snapshot_events,
+// unless TestSnapshotCopiesEventColumns lists why it is not copied.
+const snapshotRestoredColumns = `title, description, description_format, start_time, end_time, location, latitude, longitude, created_at, updated_at, status, submitted_by, price_cents, currency, ticket_quota`
+
+// snapshotEventColumns are copied from events into snapshot_events; the id column is
+// event_id in snapshot_events
+const snapshotEventColumns = `calendar_id, ` + snapshotRestoredColumns
+
+// CreateSnapshot copies the events table into a new snapshot. It runs in a repeatable
+// read transaction so the copy is consistent even while events are being written.
+func (r *SnapshotRepository) CreateSnapshot(ctx context.Context, s Snapshot) (*Snapshot, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := traced(ctx, tx).ExecContext(ctx, `INSERT INTO snapshots (id, name, created_by) VALUES ($1, $2, $3)`, s.ID, s.Name, s.CreatedBy); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	res, err := traced(ctx, tx).ExecContext(ctx, `
+		INSERT INTO snapshot_events (snapshot_id, event_id, `+snapshotEventColumns+`)
+		SELECT $1, id, `+snapshotEventColumns+` FROM events`, s.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy events: %w", err)
+	}
+	count, _ := res.RowsAffected()
+
+	var created Snapshot
+	row := traced(ctx, tx).QueryRowContext(ctx, `UPDATE snapshots SET event_count = $2 WHERE id = $1 RETURNING `+snapshotColumns, s.ID, count)
+	if err := scanSnapshot(row, &created); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit snapshot: %w", err)
+	}
+	return &created, nil
+}
+
+// ListSnapshots returns every snapshot, newest first
+func (r *SnapshotRepository) ListSnapshots(ctx context.Context) ([]Snapshot, error) {
+	rows, err := traced(ctx, r.db).QueryContext(ctx, `SELECT `+snapshotColumns+` FROM snapshots ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := []Snapshot{}
+	for rows.Next() {
+		var s Snapshot
+		if err := scanSnapshot(rows, &s); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot: %w", err)
+		}
+		snapshots = append(snapshots, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating snapshots: %w", err)
+	}
+	return snapshots, nil
+}
+
+// GetSnapshot retrieves a snapshot by ID
+func (r *SnapshotRepository) GetSnapshot(ctx context.Context, id uuid.UUID) (*Snapshot, error) {
+	var s Snapshot
+	if err := scanSnapshot(traced(ctx, r.db).QueryRowContext(ctx, `SELECT `+snapshotColumns+` FROM snapshots WHERE id = $1`, id), &s); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSnapshotNotFound
+		}
+		return nil, fmt.Errorf("failed to get snapshot: %w", err)
+	}
+	return &s, nil
+}
+
+// DeleteSnapshot removes a snapshot and its copied events
+func (r *SnapshotRepository) DeleteSnapshot(ctx context.Context, id uuid.UUID) error {
+	res, err := traced(ctx, r.db).ExecContext(ctx, `DELETE FROM snapshots WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSnapshotNotFound
+	}
+	return nil
+}
+
+// RestoreSnapshot creates calendar and copies every event of the snapshot into it with
+// new IDs, leaving live events untouched. It returns the number of events restored.
+func (r *SnapshotRepository) RestoreSnapshot(ctx context.Context, id uuid.UUID, calendar Calendar) (*Calendar, int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := traced(ctx, tx).QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM snapshots WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return nil, 0, fmt.Errorf("failed to get snapshot: %w", err)
+	}
+	if !exists {
+		return nil, 0, ErrSnapshotNotFound
+	}
+
+	var created Calendar
+	row := traced(ctx, tx).QueryRowContext(ctx, `INSERT INTO calendars (id, name, owner_id) VALUES ($1, $2, $3) RETURNING `+calendarColumns,
+		calendar.ID, calendar.Name, calendar.OwnerID)
+	if err := scanCalendar(row, &created); err != nil {
+		return nil, 0, fmt.Errorf("failed to create staging calendar: %w", err)
+	}
+
+	oldIDs, newIDs, err := r.restoredIDs(ctx, tx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Values are copied as stored, so encrypted fields stay encrypted under their key, and
+	// events keep their review status
+	res, err := traced(ctx, tx).ExecContext(ctx, `
+		INSERT INTO events (id, calendar_id, `+snapshotRestoredColumns+`)
+		SELECT m.new_id, $2, `+snapshotRestoredColumns+`
+		FROM snapshot_events
+		JOIN unnest($3::uuid[], $4::uuid[]) AS m (event_id, new_id) USING (event_id)
+		WHERE snapshot_id = $1`, id, created.ID, pq.Array(oldIDs), pq.Array(newIDs))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to restore events: %w", err)
+	}
+	restored, _ := res.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("failed to commit restore: %w", err)
+	}
+	return &created, int(restored), nil
+}
+
+// restoredIDs lists the events of a snapshot with the new ID of each restored copy
+func (r *SnapshotRepository) restoredIDs(ctx context.Context, tx *sql.Tx, id uuid.UUID) (oldIDs, newIDs []string, err error) {
+	rows, err := traced(ctx, tx).QueryContext(ctx, `SELECT event_id FROM snapshot_events WHERE snapshot_id = $1`, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list snapshot events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var eventID uuid.UUID
+		if err := rows.Scan(&eventID); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan snapshot event: %w", err)
+		}
+		newID, err := r.ids.NewID()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to generate event ID: %w", err)
+		}
+		oldIDs = append(oldIDs, eventID.String())
+		newIDs = append(newIDs, newID.String())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating snapshot events: %w", err)
+	}
+	return oldIDs, newIDs, nil
+}
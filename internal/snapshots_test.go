@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openTestDatabase connects to the PostgreSQL database at TEST_DATABASE_URL, migrated
+// up to the latest migration, skipping the test when it is not set
+func openTestDatabase(t *testing.T) *sql.DB {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSnapshotCopiesEventColumns(t *testing.T) {
+	// Columns of events that snapshots do not copy, and why
+	notCopied := map[string]string{
+		"id":                    "restored events get new IDs; snapshot_events keeps it as event_id",
+		"version":               "set by a trigger on every write",
+		"in_exclusive_calendar": "set by a trigger from the calendar",
+		"client_key":            "identifies a client's write of the live event",
+		"external_id":           "unique per source, so it stays with the live event",
+		"source":                "unique with external_id",
+	}
+	columns := map[string]bool{}
+	for _, c := range strings.Split(snapshotEventColumns, ", ") {
+		columns[c] = true
+	}
+	snapshotColumns := map[string]bool{}
+	for _, m := range expectedSchema {
+		for _, c := range m.Columns["snapshot_events"] {
+			snapshotColumns[c] = true
+		}
+	}
+	for _, m := range expectedSchema {
+		for _, c := range m.Columns["events"] {
+			if _, skipped := notCopied[c]; skipped {
+				continue
+			}
+			assert.True(t, columns[c], "events.%s (%s) is not copied by snapshots", c, m.File)
+			assert.True(t, snapshotColumns[c], "events.%s (%s) has no snapshot_events column", c, m.File)
+		}
+	}
+}
+
+func TestRestoreSnapshotKeepsEventFields(t *testing.T) {
+	db := openTestDatabase(t)
+	ctx := context.Background()
+	repo := NewSnapshotRepository(db)
+	repo.SetIDGenerator(NewIDGenerator(IDStrategyUUIDv7))
+
+	event := uuid.New()
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	_, err := db.ExecContext(ctx, `INSERT INTO events (id, title, start_time, end_time, status, submitted_by, price_cents, currency, ticket_quota)
+		VALUES ($1, $2, $3, $4, 'pending', 'alice', 1500, 'EUR', 20)`,
+		event, "Snapshot "+event.String(), start, start.Add(time.Hour))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.ExecContext(ctx, `DELETE FROM events WHERE id = $1`, event) })
+
+	snapshot, err := repo.CreateSnapshot(ctx, Snapshot{ID: uuid.New(), Name: "before restore", CreatedBy: "admin"})
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.DeleteSnapshot(ctx, snapshot.ID) })
+
+	calendar, restored, err := repo.RestoreSnapshot(ctx, snapshot.ID, Calendar{ID: uuid.New(), Name: "Restored", OwnerID: "admin"})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db.ExecContext(ctx, `DELETE FROM events WHERE calendar_id = $1`, calendar.ID)
+		db.ExecContext(ctx, `DELETE FROM calendars WHERE id = $1`, calendar.ID)
+	})
+	assert.Equal(t, snapshot.EventCount, restored)
+
+	var id uuid.UUID
+	var status, submittedBy, currency string
+	var priceCents int64
+	var quota int
+	err = db.QueryRowContext(ctx, `SELECT id, status, submitted_by, price_cents, currency, ticket_quota FROM events WHERE calendar_id = $1 AND title = $2`,
+		calendar.ID, "Snapshot "+event.String()).Scan(&id, &status, &submittedBy, &priceCents, &currency, &quota)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Version(7), id.Version(), "restored IDs follow the ID strategy")
+	assert.Equal(t, "pending", status)
+	assert.Equal(t, "alice", submittedBy)
+	assert.Equal(t, int64(1500), priceCents)
+	assert.Equal(t, "EUR", currency)
+	assert.Equal(t, 20, quota)
+}
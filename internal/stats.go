@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobRefreshEventStats is the scheduler job that recomputes the counts served by
+// GET /events/stats
+const JobRefreshEventStats = "refresh_event_stats"
+
+// eventStatsView is the materialized view holding the daily counts
+const eventStatsView = "event_stats_daily"
+
+// Groupings of event stats
+const (
+	StatsGroupDay      = "day"
+	StatsGroupWeek     = "week"
+	StatsGroupCalendar = "calendar"
+)
+
+// MaxStatsDays bounds the period of one stats query
+const MaxStatsDays = 1830
+
+// EventStatsQuery selects the published events counted by EventStats: those starting
+// on the UTC days from From up to, excluding, To
+type EventStatsQuery struct {
+	From, To time.Time
+	Group    string
+	// CalendarID limits the counts to one calendar
+	CalendarID *uuid.UUID
+}
+
+// EventStatsRow is the number and total length of the events of one group. Key is the
+// day, the Monday of the week, or the calendar ID, empty for events of no calendar.
+type EventStatsRow struct {
+	Key     string `json:"key"`
+	Events  int    `json:"events"`
+	Minutes int64  `json:"minutes"`
+}
+
+// ValidStatsGroup reports whether group is a supported grouping
+func ValidStatsGroup(group string) bool {
+	return group == StatsGroupDay || group == StatsGroupWeek || group == StatsGroupCalendar
+}
+
+// EventStats sums the precomputed daily counts by q.Group. The counts are as of the
+// last refresh of the view, not live.
+func (r *EventRepository) EventStats(ctx context.Context, q EventStatsQuery) ([]EventStatsRow, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, qEventStats.SQL, q.From, q.To, q.Group, q.CalendarID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := []EventStatsRow{}
+	for rows.Next() {
+		var s EventStatsRow
+		if err := rows.Scan(&s.Key, &s.Events, &s.Minutes); err != nil {
+			return nil, fmt.Errorf("failed to scan event stats: %w", err)
+		}
+		if s.Key == uuid.Nil.String() {
+			s.Key = ""
+		}
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating event stats: %w", err)
+	}
+	return stats, nil
+}
+
+// RefreshEventStatsJob returns the job recomputing the daily counts. The refresh does
+// not block readers, so it can run often.
+func RefreshEventStatsJob(repo MaintenanceRepositoryInterface) JobFunc {
+	return func(ctx context.Context, _ json.RawMessage) (string, error) {
+		if err := repo.RefreshMaterializedView(ctx, MaterializedView{Name: eventStatsView, Concurrent: true}); err != nil {
+			return "", err
+		}
+		return "refreshed " + eventStatsView, nil
+	}
+}
@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics sinks pushing to a StatsD agent
+const (
+	MetricsStatsD    = "statsd"
+	MetricsDogStatsD = "dogstatsd"
+)
+
+// statsdPacketSize keeps packets under the usual MTU, so no datagram is fragmented
+const statsdPacketSize = 1432
+
+// StatsD writes metrics to a StatsD or DogStatsD agent over UDP, packing lines into
+// packets. DogStatsD agents receive tags as tags; plain StatsD has none, so their values
+// are appended to the metric name instead.
+type StatsD struct {
+	conn   net.Conn
+	prefix string
+	dog    bool
+	// tags are added to every DogStatsD metric
+	tags []string
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// NewStatsD sends metrics named prefix + name to the agent at addr. tags, such as
+// "env:prod", are sent with every metric of a DogStatsD agent.
+func NewStatsD(addr, prefix string, dogstatsd bool, tags []string) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to StatsD at %s: %w", addr, err)
+	}
+	return &StatsD{conn: conn, prefix: prefix, dog: dogstatsd, tags: tags}, nil
+}
+
+// Count adds n to a counter. tags alternate names and values.
+func (s *StatsD) Count(name string, n int64, tags ...string) {
+	s.write(name, strconv.FormatInt(n, 10), "c", tags)
+}
+
+// Timing records a duration, in milliseconds
+func (s *StatsD) Timing(name string, d time.Duration, tags ...string) {
+	s.write(name, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), "ms", tags)
+}
+
+// Gauge sets a gauge
+func (s *StatsD) Gauge(name string, v float64, tags ...string) {
+	s.write(name, strconv.FormatFloat(v, 'f', -1, 64), "g", tags)
+}
+
+func (s *StatsD) write(name, value, kind string, tags []string) {
+	var line strings.Builder
+	line.WriteString(statsdName(s.prefix + name))
+	if !s.dog {
+		for i := 1; i < len(tags); i += 2 {
+			line.WriteString("." + statsdName(tags[i]))
+		}
+	}
+	line.WriteString(":" + value + "|" + kind)
+	if s.dog && (len(tags) > 1 || len(s.tags) > 0) {
+		all := append([]string(nil), s.tags...)
+		for i := 1; i < len(tags); i += 2 {
+			all = append(all, tags[i-1]+":"+statsdTagValue(tags[i]))
+		}
+		line.WriteString("|#" + strings.Join(all, ","))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buf.Len() > 0 && s.buf.Len()+1+line.Len() > statsdPacketSize {
+		s.flushLocked()
+	}
+	if s.buf.Len() > 0 {
+		s.buf.WriteByte('\n')
+	}
+	s.buf.WriteString(line.String())
+}
+
+// Flush sends the buffered metrics
+func (s *StatsD) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushLocked()
+}
+
+func (s *StatsD) flushLocked() {
+	if s.buf.Len() == 0 {
+		return
+	}
+	// UDP writes fail only locally, e.g. when nothing listens on a local port; metrics
+	// are best effort either way
+	if _, err := s.conn.Write(s.buf.Bytes()); err != nil {
+		log.Printf("Error sending metrics to StatsD: %v", err)
+	}
+	s.buf.Reset()
+}
+
+// statsdName replaces the characters StatsD reserves in names
+func statsdName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '#', ',', '\n', ' ', '/', '{', '}':
+			return '_'
+		}
+		return r
+	}, strings.Trim(s, "/"))
+}
+
+// statsdTagValue replaces the characters DogStatsD reserves in tag values
+func statsdTagValue(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '|', ',', '#', '\n', ' ':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// StatsDMetrics pushes the observations of Metrics to a StatsD agent, alongside the
+// Prometheus endpoint: http.requests and http.request_duration per request,
+// repository.calls and repository.call_duration per repository call, and the
+// http.requests_in_flight gauge at every flush
+type StatsDMetrics struct {
+	client  *StatsD
+	metrics *Metrics
+	// Interval is how often buffered metrics are sent
+	Interval time.Duration
+}
+
+// NewStatsDMetrics builds the sink of cfg.MetricsSink, or returns nil when metrics are
+// only served to Prometheus
+func NewStatsDMetrics(cfg Config) (*StatsDMetrics, error) {
+	switch cfg.MetricsSink {
+	case "":
+		return nil, nil
+	case MetricsStatsD, MetricsDogStatsD:
+		client, err := NewStatsD(cfg.StatsDAddr, cfg.StatsDPrefix, cfg.MetricsSink == MetricsDogStatsD, cfg.StatsDTags)
+		if err != nil {
+			return nil, err
+		}
+		return &StatsDMetrics{client: client, Interval: 10 * time.Second}, nil
+	default:
+		return nil, fmt.Errorf("unknown metrics sink %q (available: %s, %s)", cfg.MetricsSink, MetricsStatsD, MetricsDogStatsD)
+	}
+}
+
+// Register makes metrics push every observation to the agent
+func (s *StatsDMetrics) Register(metrics *Metrics) {
+	s.metrics = metrics
+	metrics.AddSink(s)
+}
+
+func (s *StatsDMetrics) ObserveRequest(method, route string, status int, labels MetricLabels, d time.Duration) {
+	tags := statsdLabels(labels, "method", method, "route", route, "status", strconv.Itoa(status))
+	s.client.Count("http.requests", 1, tags...)
+	s.client.Timing("http.request_duration", d, tags...)
+}
+
+func (s *StatsDMetrics) ObserveRepositoryCall(method, result string, labels MetricLabels, d time.Duration) {
+	tags := statsdLabels(labels, "method", method, "result", result)
+	s.client.Count("repository.calls", 1, tags...)
+	s.client.Timing("repository.call_duration", d, tags...)
+}
+
+// statsdLabels appends the tenant and calendar labels, when enabled, to tags
+func statsdLabels(labels MetricLabels, tags ...string) []string {
+	if labels.Tenant == "" && labels.Calendar == "" {
+		return tags
+	}
+	return append(tags, "tenant", labels.Tenant, "calendar", labels.Calendar)
+}
+
+// Run sends the buffered metrics every Interval until ctx is done, then sends those
+// left
+func (s *StatsDMetrics) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.client.Flush()
+			return
+		case <-ticker.C:
+			if s.metrics != nil {
+				s.client.Gauge("http.requests_in_flight", float64(s.metrics.InFlight()))
+			}
+			s.client.Flush()
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listenStatsD returns an agent address and a function reading the next packet's lines
+func listenStatsD(t *testing.T) (string, func() []string) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn.LocalAddr().String(), func() []string {
+		buf := make([]byte, 2048)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		return strings.Split(string(buf[:n]), "\n")
+	}
+}
+
+func TestStatsDMetrics(t *testing.T) {
+	tests := []struct {
+		sink string
+		want []string
+	}{
+		{MetricsDogStatsD, []string{
+			"calendar.http.requests:1|c|#env:test,method:GET,route:/events/{id},status:200",
+			"calendar.http.request_duration:12.5|ms|#env:test,method:GET,route:/events/{id},status:200",
+			"calendar.repository.calls:1|c|#env:test,method:GetEvent,result:ok",
+			"calendar.repository.call_duration:3|ms|#env:test,method:GetEvent,result:ok",
+		}},
+		{MetricsStatsD, []string{
+			"calendar.http.requests.GET.events__id_.200:1|c",
+			"calendar.http.request_duration.GET.events__id_.200:12.5|ms",
+			"calendar.repository.calls.GetEvent.ok:1|c",
+			"calendar.repository.call_duration.GetEvent.ok:3|ms",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sink, func(t *testing.T) {
+			addr, read := listenStatsD(t)
+			sink, err := NewStatsDMetrics(Config{MetricsSink: tt.sink, StatsDAddr: addr, StatsDPrefix: "calendar.", StatsDTags: []string{"env:test"}})
+			require.NoError(t, err)
+			metrics := NewMetrics()
+			sink.Register(metrics)
+
+			metrics.ObserveRequest(context.Background(), "GET", "/events/{id}", 200, 12500*time.Microsecond)
+			metrics.ObserveRepositoryCall(context.Background(), "GetEvent", "ok", 3*time.Millisecond)
+			sink.client.Flush()
+			assert.Equal(t, tt.want, read())
+		})
+	}
+}
+
+func TestStatsDPackets(t *testing.T) {
+	addr, read := listenStatsD(t)
+	client, err := NewStatsD(addr, "", false, nil)
+	require.NoError(t, err)
+	for i := 0; i < 200; i++ {
+		client.Count("requests", 1)
+	}
+	client.Flush()
+	first, second := read(), read()
+	assert.Len(t, append(first, second...), 200)
+	assert.LessOrEqual(t, len(strings.Join(first, "\n")), statsdPacketSize, "packets stay under the MTU")
+}
+
+func TestNewStatsDMetrics(t *testing.T) {
+	sink, err := NewStatsDMetrics(Config{})
+	require.NoError(t, err)
+	assert.Nil(t, sink, "metrics are only served to Prometheus by default")
+	_, err = NewStatsDMetrics(Config{MetricsSink: "graphite"})
+	assert.Error(t, err)
+}
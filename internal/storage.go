@@ -0,0 +1,209 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrObjectNotFound is returned when a stored object does not exist
+var ErrObjectNotFound = newDomainError(ErrNotFound, "object not found")
+
+// Storage stores backup files and event covers under slash-separated keys
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes key; deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+	// Location describes where key is stored, for logs and job output
+	Location(key string) string
+}
+
+// NewStorage builds the backend selected by BACKUP_STORAGE: local (default), s3 or gcs.
+// GCS is reached through its S3-compatible XML API with HMAC keys.
+func NewStorage(cfg Config) (Storage, error) {
+	switch cfg.BackupStorage {
+	case "local":
+		return &LocalStorage{Dir: cfg.ExportDir}, nil
+	case "s3":
+		creds, err := awsCredentialsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		endpoint := os.Getenv("S3_ENDPOINT")
+		if endpoint == "" {
+			endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", creds.Region)
+		}
+		return newS3Storage(endpoint, cfg.BackupBucket, creds, "s3")
+	case "gcs":
+		creds := awsCredentials{
+			AccessKeyID:     os.Getenv("GCS_HMAC_ACCESS_ID"),
+			SecretAccessKey: os.Getenv("GCS_HMAC_SECRET"),
+			Region:          "auto",
+		}
+		if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+			return nil, errors.New("GCS_HMAC_ACCESS_ID and GCS_HMAC_SECRET are required")
+		}
+		return newS3Storage("https://storage.googleapis.com", cfg.BackupBucket, creds, "gs")
+	default:
+		return nil, fmt.Errorf("unknown BACKUP_STORAGE %q", cfg.BackupStorage)
+	}
+}
+
+// LocalStorage keeps objects as files below Dir
+type LocalStorage struct {
+	Dir string
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(s.Dir, clean), nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	// Write to a temporary name first so readers never see a partial file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func (s *LocalStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return data, err
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete %s: %w", path, err)
+	}
+	return nil
+}
+
+func (s *LocalStorage) Location(key string) string {
+	path, _ := s.path(key)
+	return path
+}
+
+// S3Storage talks to S3 or an S3-compatible service using path-style URLs
+type S3Storage struct {
+	client   *http.Client
+	endpoint *url.URL
+	bucket   string
+	creds    awsCredentials
+	// scheme prefixes locations, s3 or gs
+	scheme string
+}
+
+func newS3Storage(endpoint, bucket string, creds awsCredentials, scheme string) (*S3Storage, error) {
+	if bucket == "" {
+		return nil, errors.New("BACKUP_BUCKET is required")
+	}
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid storage endpoint %q", endpoint)
+	}
+	return &S3Storage{client: &http.Client{Timeout: 5 * time.Minute}, endpoint: u, bucket: bucket, creds: creds, scheme: scheme}, nil
+}
+
+func (s *S3Storage) objectURL(key string) string {
+	u := *s.endpoint
+	u.Path = "/" + s.bucket + "/" + strings.TrimLeft(key, "/")
+	return u.String()
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	signAWSRequest(req, data, s.creds, "s3", time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("upload of %s returned %s: %s", key, resp.Status, body)
+	}
+	return nil
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	signAWSRequest(req, nil, s.creds, "s3", time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrObjectNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download of %s returned %s", key, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+	signAWSRequest(req, nil, s.creds, "s3", time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("delete of %s returned %s", key, resp.Status)
+	}
+	return nil
+}
+
+func (s *S3Storage) Location(key string) string {
+	return s.scheme + "://" + s.bucket + "/" + strings.TrimLeft(key, "/")
+}
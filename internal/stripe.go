@@ -0,0 +1,235 @@
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Stripe Checkout sessions expire between 30 minutes and 24 hours after creation
+const (
+	stripeMinSessionLife = 31 * time.Minute
+	stripeMaxSessionLife = 24 * time.Hour
+)
+
+// stripeSignatureTolerance is how old a signed webhook delivery may be
+const stripeSignatureTolerance = 5 * time.Minute
+
+// StripeProvider takes payments through Stripe Checkout
+type StripeProvider struct {
+	secretKey     string
+	webhookSecret string
+	// BaseURL is the Stripe API, replaced in tests
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewStripeProvider creates a provider calling Stripe with secretKey and verifying
+// webhook deliveries with the endpoint's webhookSecret
+func NewStripeProvider(secretKey, webhookSecret string) *StripeProvider {
+	return &StripeProvider{
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+		BaseURL:       "https://api.stripe.com",
+		Client:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *StripeProvider) Name() string { return "stripe" }
+
+// CreateCheckout creates a Checkout session for the reservation's tickets. The
+// session outlives short holds, since Stripe requires at least 30 minutes; payments
+// arriving after the hold ran out are refunded by the webhook.
+func (s *StripeProvider) CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error) {
+	res := req.Reservation
+	if res.AmountCents == nil || res.Currency == nil {
+		return nil, fmt.Errorf("reservation %s has no price", res.ID)
+	}
+	now := time.Now()
+	expires := req.ExpiresAt
+	if expires.Before(now.Add(stripeMinSessionLife)) {
+		expires = now.Add(stripeMinSessionLife)
+	}
+	if expires.After(now.Add(stripeMaxSessionLife)) {
+		expires = now.Add(stripeMaxSessionLife)
+	}
+
+	form := url.Values{
+		"mode":                                   {"payment"},
+		"success_url":                            {req.ReturnURL},
+		"cancel_url":                             {req.ReturnURL},
+		"client_reference_id":                    {res.ID.String()},
+		"expires_at":                             {strconv.FormatInt(expires.Unix(), 10)},
+		"metadata[event_id]":                     {res.EventID.String()},
+		"metadata[reservation_id]":               {res.ID.String()},
+		"line_items[0][quantity]":                {strconv.Itoa(res.Quantity)},
+		"line_items[0][price_data][currency]":    {strings.ToLower(*res.Currency)},
+		"line_items[0][price_data][unit_amount]": {strconv.FormatInt(*res.AmountCents/int64(res.Quantity), 10)},
+		"line_items[0][price_data][product_data][name]": {req.EventTitle},
+	}
+	var session struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}
+	// Retrying the same reservation returns the session already created
+	if err := s.post(ctx, "/v1/checkout/sessions", form, "checkout-"+res.ID.String(), &session); err != nil {
+		return nil, err
+	}
+	return &Checkout{SessionID: session.ID, URL: session.URL}, nil
+}
+
+// Refund refunds the payment intent of a completed checkout
+func (s *StripeProvider) Refund(ctx context.Context, reservationID uuid.UUID, paymentID string) error {
+	form := url.Values{
+		"payment_intent":           {paymentID},
+		"metadata[reservation_id]": {reservationID.String()},
+	}
+	return s.post(ctx, "/v1/refunds", form, "refund-"+reservationID.String(), nil)
+}
+
+// stripeError is the error body of the Stripe API
+type stripeError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// post sends a form to the Stripe API, decoding the response into out when not nil
+func (s *StripeProvider) post(ctx context.Context, path string, form url.Values, idempotencyKey string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.BaseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+s.secretKey)
+	req.Header.Set("Idempotency-Key", idempotencyKey)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Stripe unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var se stripeError
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&se)
+		return fmt.Errorf("Stripe returned %s: %s", resp.Status, se.Error.Message)
+	}
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid Stripe response: %w", err)
+	}
+	return nil
+}
+
+// stripeEvent is the part of a Stripe webhook event about Checkout sessions
+type stripeEvent struct {
+	Type string `json:"type"`
+	Data struct {
+		Object struct {
+			ID            string            `json:"id"`
+			PaymentStatus string            `json:"payment_status"`
+			PaymentIntent string            `json:"payment_intent"`
+			Metadata      map[string]string `json:"metadata"`
+		} `json:"object"`
+	} `json:"data"`
+}
+
+// ParseWebhook verifies the Stripe-Signature header of a delivery and reports paid
+// and expired Checkout sessions
+func (s *StripeProvider) ParseWebhook(payload []byte, header http.Header, now time.Time) (*PaymentEvent, error) {
+	if !s.verifySignature(payload, header.Get("Stripe-Signature"), now) {
+		return nil, ErrWebhookSignature
+	}
+
+	var event stripeEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid Stripe event: %w", err)
+	}
+	session := event.Data.Object
+	var outcome string
+	switch event.Type {
+	case "checkout.session.completed", "checkout.session.async_payment_succeeded":
+		// Delayed payment methods complete the session before the money arrives
+		if session.PaymentStatus != "paid" {
+			return nil, nil
+		}
+		outcome = PaymentCompleted
+	case "checkout.session.expired", "checkout.session.async_payment_failed":
+		outcome = PaymentExpired
+	default:
+		return nil, nil
+	}
+
+	eventID, err := uuid.Parse(session.Metadata["event_id"])
+	if err != nil {
+		return nil, nil
+	}
+	reservationID, err := uuid.Parse(session.Metadata["reservation_id"])
+	if err != nil {
+		return nil, nil
+	}
+	return &PaymentEvent{
+		Type:          outcome,
+		EventID:       eventID,
+		ReservationID: reservationID,
+		SessionID:     session.ID,
+		PaymentID:     session.PaymentIntent,
+	}, nil
+}
+
+// verifySignature checks a "t=timestamp,v1=signature,..." header: any v1 signature
+// must be the hex HMAC-SHA256 of "timestamp.payload", signed recently
+func (s *StripeProvider) verifySignature(payload []byte, header string, now time.Time) bool {
+	if s.webhookSecret == "" {
+		return false
+	}
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > stripeSignatureTolerance || age < -stripeSignatureTolerance {
+		return false
+	}
+
+	expected := signStripePayload(s.webhookSecret, timestamp, payload)
+	for _, sig := range signatures {
+		if hmac.Equal([]byte(expected), []byte(sig)) {
+			return true
+		}
+	}
+	return false
+}
+
+// signStripePayload returns the v1 signature of a webhook payload sent at timestamp
+func signStripePayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
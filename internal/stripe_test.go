@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStripeCreateCheckout(t *testing.T) {
+	var form map[string]string
+	var idempotencyKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/checkout/sessions", r.URL.Path)
+		assert.Equal(t, "Bearer sk_test", r.Header.Get("Authorization"))
+		idempotencyKey = r.Header.Get("Idempotency-Key")
+		require.NoError(t, r.ParseForm())
+		form = map[string]string{}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		w.Write([]byte(`{"id": "cs_1", "url": "https://checkout.stripe.com/c/cs_1"}`))
+	}))
+	defer server.Close()
+
+	stripe := NewStripeProvider("sk_test", "whsec_test")
+	stripe.BaseURL = server.URL
+	amount, currency := int64(3000), "EUR"
+	res := TicketReservation{ID: uuid.New(), EventID: uuid.New(), Quantity: 2, AmountCents: &amount, Currency: &currency}
+	checkout, err := stripe.CreateCheckout(context.Background(), CheckoutRequest{
+		Reservation: res,
+		EventTitle:  "Concert",
+		ReturnURL:   "https://shop.example.com/done",
+		ExpiresAt:   time.Now().Add(10 * time.Minute),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Checkout{SessionID: "cs_1", URL: "https://checkout.stripe.com/c/cs_1"}, *checkout)
+	assert.Equal(t, "checkout-"+res.ID.String(), idempotencyKey)
+	assert.Equal(t, "eur", form["line_items[0][price_data][currency]"])
+	assert.Equal(t, "1500", form["line_items[0][price_data][unit_amount]"])
+	assert.Equal(t, "2", form["line_items[0][quantity]"])
+	assert.Equal(t, res.ID.String(), form["metadata[reservation_id]"])
+
+	// Stripe sessions last at least 30 minutes, however short the hold
+	expires, err := strconv.ParseInt(form["expires_at"], 10, 64)
+	require.NoError(t, err)
+	assert.Greater(t, time.Unix(expires, 0), time.Now().Add(30*time.Minute))
+}
+
+func TestStripeParseWebhook(t *testing.T) {
+	stripe := NewStripeProvider("sk_test", "whsec_test")
+	now := time.Unix(1757930400, 0)
+	eventID, reservationID := uuid.New(), uuid.New()
+	payload, err := json.Marshal(map[string]any{
+		"type": "checkout.session.completed",
+		"data": map[string]any{"object": map[string]any{
+			"id":             "cs_1",
+			"payment_status": "paid",
+			"payment_intent": "pi_1",
+			"metadata":       map[string]string{"event_id": eventID.String(), "reservation_id": reservationID.String()},
+		}},
+	})
+	require.NoError(t, err)
+	signed := func(at time.Time, secret string) http.Header {
+		ts := strconv.FormatInt(at.Unix(), 10)
+		return http.Header{"Stripe-Signature": {fmt.Sprintf("t=%s,v1=%s", ts, signStripePayload(secret, ts, payload))}}
+	}
+
+	event, err := stripe.ParseWebhook(payload, signed(now, "whsec_test"), now)
+	require.NoError(t, err)
+	assert.Equal(t, &PaymentEvent{Type: PaymentCompleted, EventID: eventID, ReservationID: reservationID, SessionID: "cs_1", PaymentID: "pi_1"}, event)
+
+	_, err = stripe.ParseWebhook(payload, signed(now, "whsec_other"), now)
+	assert.ErrorIs(t, err, ErrWebhookSignature)
+	_, err = stripe.ParseWebhook(payload, signed(now.Add(-10*time.Minute), "whsec_test"), now)
+	assert.ErrorIs(t, err, ErrWebhookSignature)
+	_, err = stripe.ParseWebhook(payload, http.Header{}, now)
+	assert.ErrorIs(t, err, ErrWebhookSignature)
+
+	// Other events are acknowledged without an outcome
+	other := []byte(`{"type": "customer.created", "data": {"object": {}}}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	event, err = stripe.ParseWebhook(other, http.Header{"Stripe-Signature": {"t=" + ts + ",v1=" + signStripePayload("whsec_test", ts, other)}}, now)
+	require.NoError(t, err)
+	assert.Nil(t, event)
+}
@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Bounds of title suggestions
+const (
+	DefaultSuggestLimit = 8
+	MaxSuggestLimit     = 25
+	MaxSuggestLength    = 100
+	// DefaultSimilarity is the trigram similarity fuzzy suggestions need by default,
+	// enough for "standp metting" to find "Standup Meeting"
+	DefaultSimilarity = 0.3
+)
+
+// minTrigramLength is the shortest input the trigram index can match inside titles;
+// shorter inputs only match title prefixes
+const minTrigramLength = 3
+
+// SuggestQuery asks for the titles of published events matching what a user typed
+type SuggestQuery struct {
+	Text  string
+	Limit int
+	// CalendarID limits the suggestions to one calendar
+	CalendarID *uuid.UUID
+	// Similarity, when positive, also suggests titles whose trigram similarity to Text
+	// reaches it, so typos still find them
+	Similarity float64
+}
+
+// TitleSuggestion is a title matching a SuggestQuery, with where it matches
+type TitleSuggestion struct {
+	Title string `json:"title"`
+	// Highlights are the [start, end) offsets of the matches in Title, in characters
+	Highlights [][2]int `json:"highlights"`
+	// Score is the trigram similarity of Title to the query, set by fuzzy queries
+	Score float64 `json:"score,omitempty"`
+}
+
+// SuggestTitles returns the distinct titles of published events starting with
+// q.Text, then those containing it, shorter titles first. Fuzzy queries follow them with
+// the titles similar enough to q.Text, most similar first. Titles of busy calendars,
+// which most callers may only see as busy blocks, are never suggested.
+func (r *EventRepository) SuggestTitles(ctx context.Context, q SuggestQuery) ([]TitleSuggestion, error) {
+	text := strings.ToLower(q.Text)
+	prefix := likeEscaper.Replace(text) + "%"
+	pattern := prefix
+	if utf8.RuneCountInString(text) >= minTrigramLength {
+		pattern = "%" + prefix
+	}
+	if q.Similarity > 0 {
+		return r.suggestSimilarTitles(ctx, q, text, pattern, prefix)
+	}
+	rows, err := conn(ctx, r.db).QueryContext(ctx, qSuggestTitles.SQL, pattern, prefix, q.CalendarID, q.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query title suggestions: %w", err)
+	}
+	defer rows.Close()
+
+	suggestions := []TitleSuggestion{}
+	for rows.Next() {
+		var title string
+		if err := rows.Scan(&title); err != nil {
+			return nil, fmt.Errorf("failed to scan title suggestion: %w", err)
+		}
+		suggestions = append(suggestions, TitleSuggestion{Title: title, Highlights: MatchOffsets(title, q.Text)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating title suggestions: %w", err)
+	}
+	return suggestions, nil
+}
+
+// suggestSimilarTitles runs a fuzzy suggestion query. The trigram index only serves
+// the % operator, which matches at pg_trgm.similarity_threshold, so the threshold is set
+// for a read-only transaction around the query.
+func (r *EventRepository) suggestSimilarTitles(ctx context.Context, q SuggestQuery, text, pattern, prefix string) ([]TitleSuggestion, error) {
+	var suggestions []TitleSuggestion
+	err := readOnlyTx(ctx, r.db, func(tx dbtx) error {
+		threshold := strconv.FormatFloat(q.Similarity, 'f', -1, 64)
+		if _, err := tx.ExecContext(ctx, `SELECT set_config('pg_trgm.similarity_threshold', $1, true)`, threshold); err != nil {
+			return fmt.Errorf("failed to set similarity threshold: %w", err)
+		}
+		rows, err := tx.QueryContext(ctx, qSuggestSimilarTitles.SQL, text, pattern, prefix, q.CalendarID, q.Limit)
+		if err != nil {
+			return fmt.Errorf("failed to query title suggestions: %w", err)
+		}
+		defer rows.Close()
+
+		suggestions = []TitleSuggestion{}
+		for rows.Next() {
+			var s TitleSuggestion
+			if err := rows.Scan(&s.Title, &s.Score); err != nil {
+				return fmt.Errorf("failed to scan title suggestion: %w", err)
+			}
+			s.Highlights = MatchOffsets(s.Title, q.Text)
+			if len(s.Highlights) == 0 {
+				s.Highlights = MatchWords(s.Title, q.Text)
+			}
+			suggestions = append(suggestions, s)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating title suggestions: %w", err)
+		}
+		return nil
+	})
+	return suggestions, err
+}
+
+// readOnlyTx calls fn with the transaction of ctx, or with a new read-only transaction
+// committed when fn succeeds
+func readOnlyTx(ctx context.Context, db *sql.DB, fn func(tx dbtx) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(conn(ctx, db))
+	}
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if err := fn(traced(ctx, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MatchOffsets returns the [start, end) character offsets of the non-overlapping,
+// case-insensitive occurrences of sub in s
+func MatchOffsets(s, sub string) [][2]int {
+	hay, needle := foldRunes(s), foldRunes(sub)
+	offsets := [][2]int{}
+	if len(needle) == 0 {
+		return offsets
+	}
+	for i := 0; i+len(needle) <= len(hay); {
+		if runesEqual(hay[i:i+len(needle)], needle) {
+			offsets = append(offsets, [2]int{i, i + len(needle)})
+			i += len(needle)
+			continue
+		}
+		i++
+	}
+	return offsets
+}
+
+// MatchWords returns the offsets of the occurrences of each word of text in s, in
+// order and keeping the longest of overlapping ones, for titles matching only part of
+// what was typed
+func MatchWords(s, text string) [][2]int {
+	offsets := [][2]int{}
+	for _, word := range strings.Fields(text) {
+		offsets = append(offsets, MatchOffsets(s, word)...)
+	}
+	// Longer matches first among those starting together, so they win the overlap
+	slices.SortFunc(offsets, func(a, b [2]int) int {
+		if a[0] != b[0] {
+			return a[0] - b[0]
+		}
+		return b[1] - a[1]
+	})
+	merged := offsets[:0]
+	for _, o := range offsets {
+		if len(merged) > 0 && o[0] < merged[len(merged)-1][1] {
+			continue
+		}
+		merged = append(merged, o)
+	}
+	return merged
+}
+
+// foldRunes lowercases s rune by rune, so offsets into the result are offsets into s
+func foldRunes(s string) []rune {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
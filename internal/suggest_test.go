@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchOffsets(t *testing.T) {
+	assert.Equal(t, [][2]int{{0, 3}}, MatchOffsets("Partial eclipse", "par"))
+	assert.Equal(t, [][2]int{{0, 3}, {13, 16}}, MatchOffsets("Party in the park", "PAR"))
+	assert.Equal(t, [][2]int{{2, 4}}, MatchOffsets("Café Über", "fé"), "offsets count characters, not bytes")
+	assert.Equal(t, [][2]int{{0, 2}, {2, 4}}, MatchOffsets("aaaa", "aa"), "matches don't overlap")
+	assert.Empty(t, MatchOffsets("Concert", "jazz"))
+	assert.Empty(t, MatchOffsets("Concert", ""))
+}
+
+func TestMatchWords(t *testing.T) {
+	assert.Equal(t, [][2]int{{0, 7}}, MatchWords("Standup Meeting", "standup metting"))
+	assert.Equal(t, [][2]int{{0, 4}, {5, 12}}, MatchWords("Team standup", "standup team"), "words match in title order")
+	assert.Equal(t, [][2]int{{5, 12}}, MatchWords("Team standup", "stand standup"), "overlapping matches keep the longest")
+	assert.Empty(t, MatchWords("Standup Meeting", "standp metting"))
+}
+
+// suggestBenchSource tags the events seeded by BenchmarkSuggestTitles
+const suggestBenchSource = "suggest-bench"
+
+// BenchmarkSuggestTitles measures suggestions against the PostgreSQL database at
+// SUGGEST_BENCH_DATABASE_URL, migrated up to 040, after seeding it with a million
+// published events. The seeded events are deleted afterwards.
+func BenchmarkSuggestTitles(b *testing.B) {
+	dsn := os.Getenv("SUGGEST_BENCH_DATABASE_URL")
+	if dsn == "" {
+		b.Skip("SUGGEST_BENCH_DATABASE_URL not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(b, err)
+	b.Cleanup(func() { db.Close() })
+	ctx := context.Background()
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO events (title, start_time, end_time, source)
+		SELECT (ARRAY['Standup', 'Planning', 'Retrospective', 'Party', 'Parade', 'Concert', 'Workshop',
+				'Review', 'Lunch', 'Yoga', 'Hackathon', 'Interview', 'Demo', 'Offsite', 'Training'])[1 + i % 15]
+			|| ' ' || (ARRAY['Meeting', 'Session', 'Sync', 'Night', 'Club', 'Day', 'Kickoff'])[1 + i / 15 % 7]
+			|| ' ' || (i % 1000),
+			now() + i * interval '1 minute', now() + i * interval '1 minute' + interval '1 hour', $1
+		FROM generate_series(1, 1000000) AS i`, suggestBenchSource)
+	require.NoError(b, err)
+	b.Cleanup(func() { db.ExecContext(ctx, `DELETE FROM events WHERE source = $1`, suggestBenchSource) })
+	_, err = db.ExecContext(ctx, `ANALYZE events`)
+	require.NoError(b, err)
+
+	repo := NewEventRepository(db, nil)
+	for _, bc := range []struct {
+		name string
+		q    SuggestQuery
+	}{
+		{"prefix", SuggestQuery{Text: "pa", Limit: DefaultSuggestLimit}},
+		{"contains", SuggestQuery{Text: "meeting 42", Limit: DefaultSuggestLimit}},
+		{"fuzzy", SuggestQuery{Text: "standp metting", Limit: DefaultSuggestLimit, Similarity: DefaultSimilarity}},
+		{"fuzzy_miss", SuggestQuery{Text: "xylophone recital", Limit: DefaultSuggestLimit, Similarity: DefaultSimilarity}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.SuggestTitles(ctx, bc.q); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventSummary is the part of an event a calendar grid draws. It leaves out the
+// description, location, tickets and sync metadata, which are most of the bytes of a
+// listing and all of its decryption.
+type EventSummary struct {
+	ID         uuid.UUID  `json:"id"`
+	CalendarID *uuid.UUID `json:"calendar_id"`
+	Title      string     `json:"title"`
+	StartTime  time.Time  `json:"start_time"`
+	EndTime    time.Time  `json:"end_time"`
+	Status     string     `json:"status"`
+	// SubmittedBy lets listings show pending events to their submitter
+	SubmittedBy string    `json:"submitted_by,omitempty"`
+	Version     int64     `json:"version"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// eventSummaryColumns is the column list matching scanEventSummary
+const eventSummaryColumns = `id, calendar_id, title, start_time, end_time, status, submitted_by, version, updated_at`
+
+// scanEventSummary reads one row selected with eventSummaryColumns
+func scanEventSummary(row rowScanner, s *EventSummary) error {
+	err := row.Scan(&s.ID, &s.CalendarID, &s.Title, &s.StartTime, &s.EndTime, &s.Status, &s.SubmittedBy, &s.Version, &s.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	s.StartTime = NormalizeTime(s.StartTime)
+	s.EndTime = NormalizeTime(s.EndTime)
+	s.UpdatedAt = NormalizeTime(s.UpdatedAt)
+	return nil
+}
+
+// Summary returns the summary of e
+func (e EventDB) Summary() EventSummary {
+	return EventSummary{
+		ID:          e.ID,
+		CalendarID:  e.CalendarID,
+		Title:       e.Title,
+		StartTime:   e.StartTime,
+		EndTime:     e.EndTime,
+		Status:      e.Status,
+		SubmittedBy: e.SubmittedBy,
+		Version:     e.Version,
+		UpdatedAt:   e.UpdatedAt,
+	}
+}
+
+// Published reports whether the summarized event is listed, like EventDB.Published
+func (s EventSummary) Published() bool {
+	return EventDB{Status: s.Status}.Published()
+}
+
+// SummarizeAll returns the summaries of events
+func SummarizeAll(events []EventDB) []EventSummary {
+	if events == nil {
+		return nil
+	}
+	out := make([]EventSummary, len(events))
+	for i, e := range events {
+		out[i] = e.Summary()
+	}
+	return out
+}
+
+// EventSummarizer is implemented by repositories that can read summaries without
+// reading whole events
+type EventSummarizer interface {
+	GetEventSummaries(ctx context.Context, f *EventFilter) ([]EventSummary, error)
+}
+
+// SummarizeEvents returns the summaries of the events f matches, or of every event
+// when f is nil, ordered by start time. Repositories that cannot read summaries are
+// listed in full and summarized.
+func SummarizeEvents(ctx context.Context, repo EventRepositoryInterface, f *EventFilter) ([]EventSummary, error) {
+	if s, ok := repo.(EventSummarizer); ok {
+		return s.GetEventSummaries(ctx, f)
+	}
+	var events []EventDB
+	var err error
+	if f != nil {
+		events, err = FilterEvents(ctx, repo, f)
+	} else {
+		events, err = repo.GetEvents(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return SummarizeAll(events), nil
+}
+
+// GetEventSummaries selects only the summary columns of the events f matches, or of
+// every event when f is nil, ordered by start time
+func (r *EventRepository) GetEventSummaries(ctx context.Context, f *EventFilter) ([]EventSummary, error) {
+	b := newSelect(qSelectEventSummaries)
+	if f != nil {
+		cond, args := f.Where()
+		b.Where(cond, args...)
+	}
+	query, args := b.OrderBy("start_time ASC").Build()
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event summaries: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []EventSummary
+	for rows.Next() {
+		var s EventSummary
+		if err := scanEventSummary(rows, &s); err != nil {
+			return nil, fmt.Errorf("failed to scan event summary: %w", err)
+		}
+		summaries = append(summaries, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating event summaries: %w", err)
+	}
+	return summaries, nil
+}
@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listedEvents serves GetEvents from a fixed list; other methods are not implemented
+type listedEvents struct {
+	EventRepositoryInterface
+	events []EventDB
+}
+
+func (l *listedEvents) GetEvents(ctx context.Context) ([]EventDB, error) {
+	return l.events, nil
+}
+
+func TestSummarizeEventsFallsBackToFullListing(t *testing.T) {
+	start := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	description := "long agenda"
+	planning := EventDB{ID: uuid.New(), Title: "Planning", Description: &description, StartTime: start, EndTime: start.Add(time.Hour), Version: 3}
+	repo := &listedEvents{events: []EventDB{planning, {ID: uuid.New(), Title: "Retro", StartTime: start, EndTime: start.Add(time.Hour)}}}
+
+	all, err := SummarizeEvents(context.Background(), repo, nil)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	f, err := ParseEventFilter(`title:plan`)
+	require.NoError(t, err)
+	filtered, err := SummarizeEvents(context.Background(), repo, f)
+	require.NoError(t, err)
+	assert.Equal(t, []EventSummary{{ID: planning.ID, Title: "Planning", StartTime: start, EndTime: start.Add(time.Hour), Version: 3}}, filtered)
+}
+
+func TestEventSummariesSkipLargeColumns(t *testing.T) {
+	query, _ := newSelect(qSelectEventSummaries).Build()
+	assert.NotContains(t, query, "description")
+	assert.NotContains(t, query, "location")
+}
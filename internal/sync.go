@@ -0,0 +1,290 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Outcomes of a pushed sync change
+const (
+	SyncApplied  = "applied"
+	SyncConflict = "conflict"
+)
+
+// ErrInvalidSyncCursor is returned for a cursor not issued by PullChanges
+var ErrInvalidSyncCursor = newDomainError(ErrValidation, "invalid sync cursor")
+
+// SyncCursor marks how far a client has pulled: the version and ID of the last
+// change it received. The zero cursor pulls everything.
+type SyncCursor struct {
+	Version int64
+	ID      uuid.UUID
+}
+
+// String encodes the cursor as an opaque token
+func (c SyncCursor) String() string {
+	if c.Version == 0 {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.Version, 10) + ":" + c.ID.String()))
+}
+
+// ParseSyncCursor decodes a token returned by SyncCursor.String; "" is the zero cursor
+func ParseSyncCursor(s string) (SyncCursor, error) {
+	if s == "" {
+		return SyncCursor{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return SyncCursor{}, ErrInvalidSyncCursor
+	}
+	version, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return SyncCursor{}, ErrInvalidSyncCursor
+	}
+	var c SyncCursor
+	if c.Version, err = strconv.ParseInt(version, 10, 64); err != nil || c.Version <= 0 {
+		return SyncCursor{}, ErrInvalidSyncCursor
+	}
+	if c.ID, err = uuid.Parse(id); err != nil {
+		return SyncCursor{}, ErrInvalidSyncCursor
+	}
+	return c, nil
+}
+
+// EventTombstone records a deleted event
+type EventTombstone struct {
+	ID        uuid.UUID `json:"id"`
+	Version   int64     `json:"version"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// SyncPage is one page of changes after a cursor
+type SyncPage struct {
+	Events  []EventDB
+	Deleted []EventTombstone
+	// Next is the cursor to pull the following page from; it equals the requested
+	// cursor when there were no changes
+	Next    SyncCursor
+	HasMore bool
+}
+
+// SyncChange is a write made by an offline client. BaseVersion is the version the
+// client last saw, or 0 for an event it created.
+type SyncChange struct {
+	BaseVersion int64
+	Deleted     bool
+	// Event holds the new fields; its ID is the client-generated event ID
+	Event EventDB
+}
+
+// SyncOutcome reports what happened to a pushed change. On conflict, Server holds
+// the current server copy, or is nil with ServerDeleted set when it was deleted.
+type SyncOutcome struct {
+	Status        string
+	Version       int64
+	Server        *EventDB
+	ServerDeleted bool
+}
+
+// PullChanges returns up to limit changes made after cursor, oldest first. Changes of
+// transactions that are still running, and everything after them, are held back until
+// they commit, so resuming from the returned cursor never misses a change.
+func (r *EventRepository) PullChanges(ctx context.Context, after SyncCursor, limit int) (*SyncPage, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Every transaction older than xmin has finished, and the snapshot taken here is
+	// used by the queries below
+	var xmin int64
+	if err := traced(ctx, tx).QueryRowContext(ctx, qSyncSnapshotXmin.SQL).Scan(&xmin); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	rows, err := traced(ctx, tx).QueryContext(ctx, qSyncChanges.SQL, after.Version, after.ID, xmin, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changes: %w", err)
+	}
+	defer rows.Close()
+
+	page := &SyncPage{Events: []EventDB{}, Deleted: []EventTombstone{}, Next: after}
+	var changed []uuid.UUID
+	for rows.Next() {
+		var version int64
+		var id uuid.UUID
+		var deleted bool
+		var deletedAt sql.NullTime
+		if err := rows.Scan(&version, &id, &deleted, &deletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan change: %w", err)
+		}
+		if len(changed)+len(page.Deleted) == limit {
+			page.HasMore = true
+			break
+		}
+		page.Next = SyncCursor{Version: version, ID: id}
+		if deleted {
+			page.Deleted = append(page.Deleted, EventTombstone{ID: id, Version: version, DeletedAt: deletedAt.Time})
+		} else {
+			changed = append(changed, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating changes: %w", err)
+	}
+	rows.Close()
+
+	if len(changed) > 0 {
+		query, args := newSelect(qSelectEvents).
+			Where("id = ANY(?)", pq.Array(changed)).
+			OrderBy("version, id").
+			Build()
+		page.Events, err = r.queryEvents(context.WithValue(ctx, txKey{}, tx), query, args...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// ApplySyncChange writes a change pushed by a sync client unless the event changed on
+// the server since BaseVersion. Pushing the same change twice is harmless: a conflict
+// whose server copy already matches the change is reported as applied.
+func (r *EventRepository) ApplySyncChange(ctx context.Context, c SyncChange) (*SyncOutcome, error) {
+	e := c.Event
+	normalizeEventTimes(&e)
+	var written int64
+	var err error
+
+	if c.Deleted {
+		written, err = r.syncDelete(ctx, e.ID, c.BaseVersion)
+	} else {
+		args, encErr := r.syncArgs(e)
+		if encErr != nil {
+			return nil, encErr
+		}
+		var res sql.Result
+		if c.BaseVersion == 0 {
+			res, err = conn(ctx, r.db).ExecContext(ctx, qSyncInsert.SQL, args...)
+		} else {
+			res, err = conn(ctx, r.db).ExecContext(ctx, qSyncUpdate.SQL, append(args, c.BaseVersion)...)
+		}
+		if err == nil {
+			written, _ = res.RowsAffected()
+		}
+	}
+	var dependents *DependentsError
+	if errors.As(err, &dependents) {
+		return nil, err
+	}
+	if err != nil {
+		if isForeignKeyViolation(err, "events_calendar_id_fkey") {
+			return nil, ErrUnknownCalendar
+		}
+		if isExclusionViolation(err, "events_exclusive_no_overlap") {
+			return nil, ErrEventOverlap
+		}
+		// Moving an event moves its resource bookings
+		if isExclusionViolation(err, "resource_bookings_no_overlap") {
+			return nil, ErrResourceBooked
+		}
+		return nil, fmt.Errorf("failed to apply change to event %s: %w", e.ID, err)
+	}
+
+	server, err := r.GetEventByID(ctx, e.ID)
+	if err != nil && !errors.Is(err, ErrEventNotFound) {
+		return nil, err
+	}
+	if written == 1 {
+		out := &SyncOutcome{Status: SyncApplied}
+		if server != nil {
+			out.Version = server.Version
+		}
+		return out, nil
+	}
+
+	// Nothing was written: find out whether the server already has what was pushed
+	if server == nil {
+		if c.Deleted {
+			return &SyncOutcome{Status: SyncApplied}, nil
+		}
+		return &SyncOutcome{Status: SyncConflict, ServerDeleted: true}, nil
+	}
+	if !c.Deleted && sameEventContent(*server, e) {
+		return &SyncOutcome{Status: SyncApplied, Version: server.Version}, nil
+	}
+	return &SyncOutcome{Status: SyncConflict, Version: server.Version, Server: server}, nil
+}
+
+// syncDelete deletes an event pushed as deleted at version, with its dependents as
+// DeleteEvent does. It returns 0 without writing anything when the event is gone or
+// at another version.
+func (r *EventRepository) syncDelete(ctx context.Context, id uuid.UUID, version int64) (int64, error) {
+	var written int64
+	err := NewTxManager(r.db).JoinTx(ctx, func(ctx context.Context) error {
+		var current int64
+		err := conn(ctx, r.db).QueryRowContext(ctx, qSyncLockVersion.SQL, id).Scan(&current)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && current != version) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := r.cascade.DeleteDependents(ctx, CascadeEvent, []string{id.String()}); err != nil {
+			return err
+		}
+		res, err := conn(ctx, r.db).ExecContext(ctx, qSyncDelete.SQL, id, version)
+		if err != nil {
+			return err
+		}
+		written, _ = res.RowsAffected()
+		return nil
+	})
+	return written, err
+}
+
+// syncArgs returns the write arguments $1-$10 of a sync change, encrypted as needed
+func (r *EventRepository) syncArgs(e EventDB) ([]any, error) {
+	format := e.DescriptionFormat
+	if format == "" {
+		format = DescriptionFormatPlain
+	}
+	description, err := r.cipher.encryptOptional(e.Description)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt description: %w", err)
+	}
+	location, err := r.cipher.encryptOptional(e.Location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt location: %w", err)
+	}
+	return []any{e.ID, e.Title, description, format, e.StartTime, e.EndTime, location, e.Latitude, e.Longitude, e.CalendarID, e.Status, e.SubmittedBy}, nil
+}
+
+// sameEventContent compares the client-editable fields of two events
+func sameEventContent(a, b EventDB) bool {
+	format := func(f string) string {
+		if f == "" {
+			return DescriptionFormatPlain
+		}
+		return f
+	}
+	return a.Title == b.Title &&
+		reflect.DeepEqual(a.Description, b.Description) &&
+		format(a.DescriptionFormat) == format(b.DescriptionFormat) &&
+		a.StartTime.Equal(b.StartTime) && a.EndTime.Equal(b.EndTime) &&
+		reflect.DeepEqual(a.Location, b.Location) &&
+		reflect.DeepEqual(a.Latitude, b.Latitude) && reflect.DeepEqual(a.Longitude, b.Longitude) &&
+		reflect.DeepEqual(a.CalendarID, b.CalendarID)
+}
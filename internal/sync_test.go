@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncCursor(t *testing.T) {
+	c := SyncCursor{Version: 12345, ID: uuid.New()}
+	parsed, err := ParseSyncCursor(c.String())
+	assert.NoError(t, err)
+	assert.Equal(t, c, parsed)
+
+	zero, err := ParseSyncCursor("")
+	assert.NoError(t, err)
+	assert.Equal(t, SyncCursor{}, zero)
+	assert.Equal(t, "", zero.String())
+
+	for _, s := range []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("12345")),
+		base64.RawURLEncoding.EncodeToString([]byte("abc:" + uuid.NewString())),
+		base64.RawURLEncoding.EncodeToString([]byte("-1:" + uuid.NewString())),
+		base64.RawURLEncoding.EncodeToString([]byte("12345:nope")),
+	} {
+		_, err := ParseSyncCursor(s)
+		assert.ErrorIs(t, err, ErrInvalidSyncCursor, s)
+	}
+}
+
+func TestSameEventContent(t *testing.T) {
+	start := time.Date(2025, 9, 10, 9, 0, 0, 0, time.UTC)
+	desc, other := "Agenda", "Other agenda"
+	base := EventDB{ID: uuid.New(), Title: "Standup", Description: &desc, StartTime: start, EndTime: start.Add(15 * time.Minute), Version: 10}
+
+	tests := []struct {
+		name   string
+		modify func(e *EventDB)
+		want   bool
+	}{
+		{"identical", func(e *EventDB) {}, true},
+		{"server-managed fields are ignored", func(e *EventDB) { e.Version = 11; e.UpdatedAt = start }, true},
+		{"empty format is plain", func(e *EventDB) { e.DescriptionFormat = DescriptionFormatPlain }, true},
+		{"same instant in another zone", func(e *EventDB) { e.StartTime = start.In(time.FixedZone("CEST", 2*3600)) }, true},
+		{"equal description behind another pointer", func(e *EventDB) { d := desc; e.Description = &d }, true},
+		{"title", func(e *EventDB) { e.Title = "Retro" }, false},
+		{"description", func(e *EventDB) { e.Description = &other }, false},
+		{"description removed", func(e *EventDB) { e.Description = nil }, false},
+		{"end time", func(e *EventDB) { e.EndTime = start.Add(time.Hour) }, false},
+		{"calendar", func(e *EventDB) { id := uuid.New(); e.CalendarID = &id }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := base
+			tt.modify(&e)
+			assert.Equal(t, tt.want, sameEventContent(base, e))
+		})
+	}
+}
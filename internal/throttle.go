@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxThrottledEvents bounds the events tracked by an UpdateThrottle; past it, the
+// windows that started first are dropped, expired or not
+const maxThrottledEvents = 100000
+
+// updateWindow counts the updates of one event in the minute starting at start
+type updateWindow struct {
+	id    uuid.UUID
+	start time.Time
+	count int
+}
+
+// UpdateThrottle limits how often a single event may be modified per minute. It
+// protects against clients stuck in an update loop, such as two sync clients
+// overwriting each other. Counts are kept in memory per instance.
+type UpdateThrottle struct {
+	limit int
+	now   func() time.Time
+
+	mu      sync.Mutex
+	windows map[uuid.UUID]*list.Element
+	// order holds the windows by start, oldest first
+	order *list.List
+}
+
+// NewUpdateThrottle allows limit updates per event per minute; it returns nil, which
+// allows everything, when limit is 0
+func NewUpdateThrottle(limit int) *UpdateThrottle {
+	if limit <= 0 {
+		return nil
+	}
+	return &UpdateThrottle{limit: limit, now: time.Now, windows: map[uuid.UUID]*list.Element{}, order: list.New()}
+}
+
+// Allow reports whether event id may be updated. When the event has reached the limit
+// it returns false and how long until it may be updated again. Only updates passed to
+// Record count, so writes rejected for other reasons do not use up the limit.
+func (t *UpdateThrottle) Allow(id uuid.UUID) (bool, time.Duration) {
+	if t == nil {
+		return true, 0
+	}
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.windows[id]
+	if !ok {
+		return true, 0
+	}
+	w := e.Value.(*updateWindow)
+	if now.Sub(w.start) >= time.Minute || w.count < t.limit {
+		return true, 0
+	}
+	return false, w.start.Add(time.Minute).Sub(now)
+}
+
+// Record counts an update of event id
+func (t *UpdateThrottle) Record(id uuid.UUID) {
+	if t == nil {
+		return
+	}
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if e, ok := t.windows[id]; ok {
+		w := e.Value.(*updateWindow)
+		if now.Sub(w.start) < time.Minute {
+			w.count++
+			return
+		}
+		w.start, w.count = now, 1
+		t.order.MoveToBack(e)
+		return
+	}
+	for t.order.Len() > 0 {
+		oldest := t.order.Front()
+		if w := oldest.Value.(*updateWindow); now.Sub(w.start) < time.Minute && t.order.Len() < maxThrottledEvents {
+			break
+		}
+		delete(t.windows, oldest.Value.(*updateWindow).id)
+		t.order.Remove(oldest)
+	}
+	t.windows[id] = t.order.PushBack(&updateWindow{id: id, start: now, count: 1})
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateThrottle(t *testing.T) {
+	now := time.Date(2025, 9, 12, 10, 0, 0, 0, time.UTC)
+	throttle := NewUpdateThrottle(3)
+	throttle.now = func() time.Time { return now }
+	id, other := uuid.New(), uuid.New()
+
+	for i := 0; i < 3; i++ {
+		ok, _ := throttle.Allow(id)
+		assert.True(t, ok, "update %d", i+1)
+		throttle.Record(id)
+	}
+	now = now.Add(20 * time.Second)
+	ok, wait := throttle.Allow(id)
+	assert.False(t, ok)
+	assert.Equal(t, 40*time.Second, wait)
+
+	ok, _ = throttle.Allow(other)
+	assert.True(t, ok, "other events have their own limit")
+	for i := 0; i < 10; i++ {
+		ok, _ = throttle.Allow(other)
+	}
+	assert.True(t, ok, "updates that are not recorded do not count")
+
+	now = now.Add(40 * time.Second)
+	ok, _ = throttle.Allow(id)
+	assert.True(t, ok, "a new minute starts a new window")
+}
+
+func TestUpdateThrottleIsBounded(t *testing.T) {
+	now := time.Date(2025, 9, 12, 10, 0, 0, 0, time.UTC)
+	throttle := NewUpdateThrottle(1)
+	throttle.now = func() time.Time { return now }
+	first := uuid.New()
+	throttle.Record(first)
+	for i := 1; i < maxThrottledEvents+10; i++ {
+		throttle.Record(uuid.New())
+	}
+	assert.Len(t, throttle.windows, maxThrottledEvents)
+	assert.Equal(t, maxThrottledEvents, throttle.order.Len())
+	ok, _ := throttle.Allow(first)
+	assert.True(t, ok, "the oldest windows are dropped first")
+
+	// Expired windows go before the limit is reached
+	now = now.Add(time.Minute)
+	throttle.Record(first)
+	assert.Len(t, throttle.windows, 1)
+}
+
+func TestUpdateThrottleDisabled(t *testing.T) {
+	throttle := NewUpdateThrottle(0)
+	assert.Nil(t, throttle)
+	for i := 0; i < 100; i++ {
+		ok, _ := throttle.Allow(uuid.Nil)
+		assert.True(t, ok)
+		throttle.Record(uuid.Nil)
+	}
+}
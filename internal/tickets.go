@@ -0,0 +1,334 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobExpireTicketReservations is the scheduler job that returns the tickets of unpaid
+// reservations to their events
+const JobExpireTicketReservations = "expire_ticket_reservations"
+
+// Ticket reservation statuses. Reservations of free events are confirmed at once;
+// those of paid events are held until paid, or until they expire.
+const (
+	TicketStatusHeld      = "held"
+	TicketStatusConfirmed = "confirmed"
+	TicketStatusExpired   = "expired"
+	TicketStatusCancelled = "cancelled"
+	TicketStatusRefunded  = "refunded"
+)
+
+// MaxTicketsPerReservation bounds how many tickets one reservation takes
+const MaxTicketsPerReservation = 10
+
+// ErrReservationNotFound is returned when a ticket reservation does not exist on the event
+var ErrReservationNotFound = newDomainError(ErrNotFound, "reservation not found")
+
+// ErrNoTickets is returned when reserving tickets for an event without a ticket quota
+var ErrNoTickets = newDomainError(ErrValidation, "event does not have tickets")
+
+// ErrSoldOut is returned when an event has fewer tickets left than requested
+var ErrSoldOut = newDomainError(ErrConflict, "not enough tickets left")
+
+// ErrReservationClosed is returned when confirming or cancelling a reservation that
+// expired or was cancelled
+var ErrReservationClosed = newDomainError(ErrConflict, "reservation has expired or was cancelled")
+
+// ErrNotRefundable is returned when refunding a reservation that was not paid through
+// the payment provider, or is no longer confirmed
+var ErrNotRefundable = newDomainError(ErrConflict, "only confirmed reservations paid through the payment provider can be refunded")
+
+// TicketReservation is a number of tickets of an event set aside for a holder
+type TicketReservation struct {
+	ID       uuid.UUID `json:"id"`
+	EventID  uuid.UUID `json:"event_id"`
+	HolderID string    `json:"holder_id"`
+	Quantity int       `json:"quantity"`
+	Status   string    `json:"status"`
+	// AmountCents is the price of the tickets when reserved; nil for free events
+	AmountCents *int64  `json:"amount_cents,omitempty"`
+	Currency    *string `json:"currency,omitempty"`
+	// ExpiresAt is when a held reservation returns its tickets unless paid
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// PaymentProvider takes the payment of a held reservation at CheckoutURL; PaymentID
+	// is the provider's payment once paid
+	PaymentProvider   *string   `json:"payment_provider,omitempty"`
+	CheckoutSessionID *string   `json:"-"`
+	CheckoutURL       *string   `json:"checkout_url,omitempty"`
+	PaymentID         *string   `json:"payment_id,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// TicketAvailability is how many of an event's tickets are left
+type TicketAvailability struct {
+	Quota     int `json:"quota"`
+	Reserved  int `json:"reserved"`
+	Available int `json:"available"`
+}
+
+// ValidCurrency reports whether code looks like an ISO 4217 currency code
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+type TicketRepository struct {
+	db *sql.DB
+}
+
+// NewTicketRepository creates a new ticket repository
+func NewTicketRepository(db *sql.DB) *TicketRepository {
+	return &TicketRepository{db: db}
+}
+
+const reservationColumns = `id, event_id, holder_id, quantity, status, amount_cents, currency, expires_at,
+	payment_provider, checkout_session_id, checkout_url, payment_id, created_at, updated_at`
+
+func scanReservation(row rowScanner, res *TicketReservation) error {
+	return row.Scan(&res.ID, &res.EventID, &res.HolderID, &res.Quantity, &res.Status, &res.AmountCents, &res.Currency, &res.ExpiresAt,
+		&res.PaymentProvider, &res.CheckoutSessionID, &res.CheckoutURL, &res.PaymentID, &res.CreatedAt, &res.UpdatedAt)
+}
+
+// Reserve takes res.Quantity tickets from the event's quota and records the
+// reservation, in one statement so concurrent reservations never oversell. Paid
+// events' reservations are held until holdUntil; free ones are confirmed.
+func (r *TicketRepository) Reserve(ctx context.Context, res TicketReservation, holdUntil time.Time) (*TicketReservation, error) {
+	query := `
+		WITH claimed AS (
+			INSERT INTO event_ticket_counts AS t (event_id, reserved)
+			SELECT id, $3 FROM events WHERE id = $2 AND ticket_quota >= $3
+			ON CONFLICT (event_id) DO UPDATE SET reserved = t.reserved + EXCLUDED.reserved
+				WHERE t.reserved + EXCLUDED.reserved <= (SELECT ticket_quota FROM events WHERE id = $2)
+			RETURNING event_id
+		)
+		INSERT INTO ticket_reservations (id, event_id, holder_id, quantity, status, amount_cents, currency, expires_at)
+		SELECT $1, e.id, $4, $3,
+			CASE WHEN e.price_cents > 0 THEN 'held' ELSE 'confirmed' END,
+			e.price_cents * $3, e.currency,
+			CASE WHEN e.price_cents > 0 THEN $5::timestamptz END
+		FROM claimed c JOIN events e ON e.id = c.event_id
+		RETURNING ` + reservationColumns
+
+	var created TicketReservation
+	err := scanReservation(conn(ctx, r.db).QueryRowContext(ctx, query, res.ID, res.EventID, res.Quantity, res.HolderID, holdUntil), &created)
+	if err == sql.ErrNoRows {
+		// Nothing was claimed: tell a missing event or quota from a sold-out one
+		var quota *int
+		if err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT ticket_quota FROM events WHERE id = $1`, res.EventID).Scan(&quota); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, ErrEventNotFound
+			}
+			return nil, fmt.Errorf("failed to get ticket quota: %w", err)
+		}
+		if quota == nil {
+			return nil, ErrNoTickets
+		}
+		return nil, ErrSoldOut
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve tickets: %w", err)
+	}
+	return &created, nil
+}
+
+// GetAvailability returns how many tickets an event has left. It fails with
+// ErrNoTickets when the event has no quota.
+func (r *TicketRepository) GetAvailability(ctx context.Context, eventID uuid.UUID) (*TicketAvailability, error) {
+	query := `
+		SELECT e.ticket_quota, COALESCE(t.reserved, 0)
+		FROM events e LEFT JOIN event_ticket_counts t ON t.event_id = e.id
+		WHERE e.id = $1`
+
+	var quota *int
+	var a TicketAvailability
+	if err := conn(ctx, r.db).QueryRowContext(ctx, query, eventID).Scan(&quota, &a.Reserved); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrEventNotFound
+		}
+		return nil, fmt.Errorf("failed to get ticket availability: %w", err)
+	}
+	if quota == nil {
+		return nil, ErrNoTickets
+	}
+	// A quota lowered below the reserved tickets leaves none
+	a.Quota, a.Available = *quota, max(*quota-a.Reserved, 0)
+	return &a, nil
+}
+
+// ListReservations returns the reservations of an event, oldest first
+func (r *TicketRepository) ListReservations(ctx context.Context, eventID uuid.UUID) ([]TicketReservation, error) {
+	query := `SELECT ` + reservationColumns + ` FROM ticket_reservations WHERE event_id = $1 ORDER BY created_at, id`
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reservations: %w", err)
+	}
+	defer rows.Close()
+
+	reservations := []TicketReservation{}
+	for rows.Next() {
+		var res TicketReservation
+		if err := scanReservation(rows, &res); err != nil {
+			return nil, fmt.Errorf("failed to scan reservation: %w", err)
+		}
+		reservations = append(reservations, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reservations: %w", err)
+	}
+	return reservations, nil
+}
+
+// GetReservation retrieves a reservation of an event
+func (r *TicketRepository) GetReservation(ctx context.Context, eventID, id uuid.UUID) (*TicketReservation, error) {
+	var res TicketReservation
+	query := `SELECT ` + reservationColumns + ` FROM ticket_reservations WHERE event_id = $1 AND id = $2`
+	if err := scanReservation(conn(ctx, r.db).QueryRowContext(ctx, query, eventID, id), &res); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReservationNotFound
+		}
+		return nil, fmt.Errorf("failed to get reservation: %w", err)
+	}
+	return &res, nil
+}
+
+// SetCheckout records the provider's checkout session a held reservation is paid at
+func (r *TicketRepository) SetCheckout(ctx context.Context, eventID, id uuid.UUID, provider string, checkout Checkout) (*TicketReservation, error) {
+	query := `
+		UPDATE ticket_reservations SET payment_provider = $3, checkout_session_id = $4, checkout_url = $5, updated_at = NOW()
+		WHERE event_id = $1 AND id = $2 AND status = 'held'
+		RETURNING ` + reservationColumns
+
+	var res TicketReservation
+	if err := scanReservation(conn(ctx, r.db).QueryRowContext(ctx, query, eventID, id, provider, checkout.SessionID, checkout.URL), &res); err != nil {
+		if err == sql.ErrNoRows {
+			if _, err := r.GetReservation(ctx, eventID, id); err != nil {
+				return nil, err
+			}
+			return nil, ErrReservationClosed
+		}
+		return nil, fmt.Errorf("failed to set checkout: %w", err)
+	}
+	return &res, nil
+}
+
+// ConfirmReservation marks a held reservation paid, by the provider's paymentID when
+// paid through one. It fails with ErrReservationClosed once the reservation expired
+// or was cancelled.
+func (r *TicketRepository) ConfirmReservation(ctx context.Context, eventID, id uuid.UUID, paymentID string) (*TicketReservation, error) {
+	query := `
+		UPDATE ticket_reservations
+		SET status = 'confirmed', expires_at = NULL, checkout_url = NULL,
+			payment_id = COALESCE(NULLIF($3, ''), payment_id), updated_at = NOW()
+		WHERE event_id = $1 AND id = $2 AND (status = 'confirmed' OR (status = 'held' AND expires_at > NOW()))
+		RETURNING ` + reservationColumns
+
+	var res TicketReservation
+	if err := scanReservation(conn(ctx, r.db).QueryRowContext(ctx, query, eventID, id, paymentID), &res); err != nil {
+		if err == sql.ErrNoRows {
+			if _, err := r.GetReservation(ctx, eventID, id); err != nil {
+				return nil, err
+			}
+			return nil, ErrReservationClosed
+		}
+		return nil, fmt.Errorf("failed to confirm reservation: %w", err)
+	}
+	return &res, nil
+}
+
+// CancelReservation cancels a held or confirmed reservation, returning its tickets to
+// the event
+func (r *TicketRepository) CancelReservation(ctx context.Context, eventID, id uuid.UUID) error {
+	query := `
+		WITH cancelled AS (
+			UPDATE ticket_reservations SET status = 'cancelled', expires_at = NULL, updated_at = NOW()
+			WHERE event_id = $1 AND id = $2 AND status IN ('held', 'confirmed')
+			RETURNING event_id, quantity
+		)
+		UPDATE event_ticket_counts t SET reserved = t.reserved - c.quantity
+		FROM cancelled c WHERE t.event_id = c.event_id`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, eventID, id)
+	if err != nil {
+		return fmt.Errorf("failed to cancel reservation: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		if _, err := r.GetReservation(ctx, eventID, id); err != nil {
+			return err
+		}
+		return ErrReservationClosed
+	}
+	return nil
+}
+
+// RefundReservation marks a confirmed, paid reservation refunded, returning its
+// tickets to the event. The refund itself is issued by the payment provider.
+func (r *TicketRepository) RefundReservation(ctx context.Context, eventID, id uuid.UUID) error {
+	query := `
+		WITH refunded AS (
+			UPDATE ticket_reservations SET status = 'refunded', updated_at = NOW()
+			WHERE event_id = $1 AND id = $2 AND status = 'confirmed' AND payment_id IS NOT NULL
+			RETURNING event_id, quantity
+		)
+		UPDATE event_ticket_counts t SET reserved = t.reserved - f.quantity
+		FROM refunded f WHERE t.event_id = f.event_id`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, eventID, id)
+	if err != nil {
+		return fmt.Errorf("failed to refund reservation: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		if _, err := r.GetReservation(ctx, eventID, id); err != nil {
+			return err
+		}
+		return ErrNotRefundable
+	}
+	return nil
+}
+
+// ExpireReservations expires the held reservations whose time ran out by now,
+// returning their tickets to the events, and reports how many expired
+func (r *TicketRepository) ExpireReservations(ctx context.Context, now time.Time) (int, error) {
+	query := `
+		WITH expired AS (
+			UPDATE ticket_reservations SET status = 'expired', updated_at = NOW()
+			WHERE status = 'held' AND expires_at <= $1
+			RETURNING event_id, quantity
+		), released AS (
+			UPDATE event_ticket_counts t SET reserved = t.reserved - e.quantity
+			FROM (SELECT event_id, SUM(quantity) AS quantity FROM expired GROUP BY event_id) e
+			WHERE t.event_id = e.event_id
+		)
+		SELECT COUNT(*) FROM expired`
+
+	var n int
+	if err := conn(ctx, r.db).QueryRowContext(ctx, query, now).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to expire reservations: %w", err)
+	}
+	return n, nil
+}
+
+// ExpireTicketReservationsJob returns the tickets of held reservations that were not
+// paid in time
+func ExpireTicketReservationsJob(tickets TicketRepositoryInterface) JobFunc {
+	return func(ctx context.Context, _ json.RawMessage) (string, error) {
+		n, err := tickets.ExpireReservations(ctx, time.Now())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("expired %d reservations", n), nil
+	}
+}
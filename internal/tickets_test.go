@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidCurrency(t *testing.T) {
+	assert.True(t, ValidCurrency("EUR"))
+	assert.False(t, ValidCurrency("eur"))
+	assert.False(t, ValidCurrency("EURO"))
+	assert.False(t, ValidCurrency("€"))
+}
+
+func TestValidateEventPrice(t *testing.T) {
+	start := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+	event := EventDB{Title: "Concert", StartTime: start, EndTime: start.Add(2 * time.Hour)}
+	price, negative, quota, eur := int64(1500), int64(-1), 0, "EUR"
+
+	assert.Empty(t, ValidateEvent(event))
+	event.PriceCents, event.Currency = &price, &eur
+	assert.Empty(t, ValidateEvent(event))
+	event.Currency = nil
+	assert.Equal(t, "price_cents and currency must be provided together", ValidateEvent(event))
+	event.PriceCents, event.Currency = &negative, &eur
+	assert.Equal(t, "price_cents must not be negative", ValidateEvent(event))
+	event.PriceCents, event.TicketQuota = &price, &quota
+	assert.Equal(t, "ticket_quota must be positive", ValidateEvent(event))
+}
+
+// expiringTickets records when reservations were expired
+type expiringTickets struct {
+	TicketRepositoryInterface
+	now time.Time
+}
+
+func (f *expiringTickets) ExpireReservations(ctx context.Context, now time.Time) (int, error) {
+	f.now = now
+	return 3, nil
+}
+
+func TestExpireTicketReservationsJob(t *testing.T) {
+	tickets := &expiringTickets{}
+	output, err := ExpireTicketReservationsJob(tickets)(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "expired 3 reservations", output)
+	assert.WithinDuration(t, time.Now(), tickets.now, time.Minute)
+}
+
+func TestValidateEventExternalID(t *testing.T) {
+	start := time.Date(2025, 10, 3, 9, 0, 0, 0, time.UTC)
+	event := EventDB{Title: "Standup", StartTime: start, EndTime: start.Add(15 * time.Minute)}
+	ext, source, empty := "abc123", "google", ""
+
+	event.ExternalID = &ext
+	assert.Equal(t, "external_id and source must be provided together", ValidateEvent(event))
+	event.Source = &source
+	assert.Empty(t, ValidateEvent(event))
+	event.Source = &empty
+	assert.Equal(t, "external_id must be 1-255 characters and source 1-100", ValidateEvent(event))
+}
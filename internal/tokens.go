@@ -0,0 +1,252 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Scopes grantable to API tokens
+const (
+	ScopeEventsRead     = "events:read"
+	ScopeEventsWrite    = "events:write"
+	ScopeWebhooksManage = "webhooks:manage"
+	ScopeMetricsRead    = "metrics:read"
+	// ScopeEventsReview lets a token approve and reject submitted events, and publish
+	// its own events without review
+	ScopeEventsReview = "events:review"
+)
+
+// AllScopes lists every scope, in display order
+var AllScopes = []string{ScopeEventsRead, ScopeEventsWrite, ScopeWebhooksManage, ScopeMetricsRead, ScopeEventsReview}
+
+// tokenPrefix makes leaked tokens easy to recognize in logs and secret scanners
+const tokenPrefix = "tc_"
+
+// ErrTokenNotFound is returned when a token does not exist or belongs to someone else
+var ErrTokenNotFound = newDomainError(ErrNotFound, "token not found")
+
+// APIToken is a personal access token. The secret itself is never stored, only its hash.
+// Each token is a session of its user, listed with where it was created and last used.
+type APIToken struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedIP  string     `json:"created_ip,omitempty"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	// RevokedAt is set once the token was revoked; it no longer authenticates
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Expired reports whether the token is past its expiry at now
+func (t *APIToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// Active reports whether the token still authenticates at now
+func (t *APIToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && !t.Expired(now)
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID  string
+	Scopes  []string
+	TokenID *uuid.UUID
+	// Admin is set for the deployment API key, which may act for any user
+	Admin bool
+}
+
+// HasScope reports whether the principal was granted scope
+func (p *Principal) HasScope(scope string) bool {
+	if p.Admin {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the authenticated caller
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the authenticated caller, or nil when auth is disabled
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// ValidScope reports whether scope is known
+func ValidScope(scope string) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateTokenSecret returns a new random token and the hash to store for it
+func GenerateTokenSecret() (string, []byte, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	secret := tokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return secret, HashToken(secret), nil
+}
+
+// HashToken returns the lookup hash of a token secret. Tokens carry 256 bits of
+// entropy, so a fast unsalted hash is sufficient.
+func HashToken(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+type TokenRepository struct {
+	db *sql.DB
+}
+
+// NewTokenRepository creates a new token repository
+func NewTokenRepository(db *sql.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+const tokenColumns = `id, user_id, name, scopes, expires_at, created_at, last_used_at, created_ip, user_agent, last_used_ip, revoked_at`
+
+func scanToken(row rowScanner, token *APIToken) error {
+	return row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		pq.Array(&token.Scopes),
+		&token.ExpiresAt,
+		&token.CreatedAt,
+		&token.LastUsedAt,
+		&token.CreatedIP,
+		&token.UserAgent,
+		&token.LastUsedIP,
+		&token.RevokedAt,
+	)
+}
+
+// CreateToken stores a token under the given secret hash
+func (r *TokenRepository) CreateToken(ctx context.Context, token APIToken, hash []byte) (*APIToken, error) {
+	query := `
+		INSERT INTO api_tokens (id, user_id, name, token_hash, scopes, expires_at, created_ip, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING ` + tokenColumns
+
+	row := traced(ctx, r.db).QueryRowContext(ctx, query, token.ID, token.UserID, token.Name, hash, pq.Array(token.Scopes), token.ExpiresAt, token.CreatedIP, token.UserAgent)
+
+	var created APIToken
+	if err := scanToken(row, &created); err != nil {
+		return nil, fmt.Errorf("failed to create token: %w", err)
+	}
+	return &created, nil
+}
+
+// GetTokenByHash looks up a token by the hash of its secret. Revoked tokens are
+// returned too, for the caller to refuse.
+func (r *TokenRepository) GetTokenByHash(ctx context.Context, hash []byte) (*APIToken, error) {
+	query := `SELECT ` + tokenColumns + ` FROM api_tokens WHERE token_hash = $1`
+
+	var token APIToken
+	if err := scanToken(traced(ctx, r.db).QueryRowContext(ctx, query, hash), &token); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+	return &token, nil
+}
+
+// ListTokens returns the tokens of a user, newest first
+func (r *TokenRepository) ListTokens(ctx context.Context, userID string) ([]APIToken, error) {
+	query := `SELECT ` + tokenColumns + ` FROM api_tokens WHERE user_id = $1 ORDER BY created_at DESC`
+
+	rows, err := traced(ctx, r.db).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []APIToken{}
+	for rows.Next() {
+		var token APIToken
+		if err := scanToken(rows, &token); err != nil {
+			return nil, fmt.Errorf("failed to scan token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// DeleteToken revokes one of a user's tokens
+func (r *TokenRepository) DeleteToken(ctx context.Context, userID string, id uuid.UUID) error {
+	res, err := traced(ctx, r.db).ExecContext(ctx, `DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete token: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// RevokeToken revokes one of a user's tokens, failing with ErrTokenNotFound when it
+// does not exist or was already revoked
+func (r *TokenRepository) RevokeToken(ctx context.Context, userID string, id uuid.UUID) error {
+	res, err := traced(ctx, r.db).ExecContext(ctx,
+		`UPDATE api_tokens SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// RevokeTokens revokes every token of a user but except, when set, and returns how
+// many it revoked
+func (r *TokenRepository) RevokeTokens(ctx context.Context, userID string, except *uuid.UUID) (int, error) {
+	res, err := traced(ctx, r.db).ExecContext(ctx,
+		`UPDATE api_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL AND ($2::uuid IS NULL OR id <> $2)`, userID, except)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke tokens: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// TouchToken records that a token was just used, from ip
+func (r *TokenRepository) TouchToken(ctx context.Context, id uuid.UUID, ip string) error {
+	_, err := traced(ctx, r.db).ExecContext(ctx, `UPDATE api_tokens SET last_used_at = NOW(), last_used_ip = $2 WHERE id = $1`, id, ip)
+	if err != nil {
+		return fmt.Errorf("failed to touch token: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TokenUseInterval is how often the use of a token from the same IP is recorded at most
+const TokenUseInterval = time.Minute
+
+// tokenUseQueue bounds the uses waiting to be recorded; more are dropped
+const tokenUseQueue = 256
+
+type tokenUse struct {
+	id uuid.UUID
+	ip string
+}
+
+// TokenUses records the last use of API tokens in the background, through one worker,
+// so authenticating a request never waits on a write. last_used_at is informational:
+// uses within TokenUseInterval of the recorded one are skipped, and uses arriving while
+// the queue is full are dropped.
+type TokenUses struct {
+	tokens TokenRepositoryInterface
+	queue  chan tokenUse
+	done   chan struct{}
+
+	mu      sync.Mutex
+	pending map[uuid.UUID]bool
+	closed  bool
+}
+
+// NewTokenUses starts the worker recording uses in tokens. Close stops it.
+func NewTokenUses(tokens TokenRepositoryInterface) *TokenUses {
+	u := &TokenUses{
+		tokens:  tokens,
+		queue:   make(chan tokenUse, tokenUseQueue),
+		done:    make(chan struct{}),
+		pending: map[uuid.UUID]bool{},
+	}
+	go u.run()
+	return u
+}
+
+// Record queues the use of token from ip at now, unless it was recorded recently from
+// the same IP or is already queued. A nil TokenUses records nothing.
+func (u *TokenUses) Record(token *APIToken, ip string, now time.Time) {
+	if u == nil {
+		return
+	}
+	if token.LastUsedAt != nil && token.LastUsedIP == ip && now.Sub(*token.LastUsedAt) < TokenUseInterval {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.closed || u.pending[token.ID] {
+		return
+	}
+	select {
+	case u.queue <- tokenUse{id: token.ID, ip: ip}:
+		u.pending[token.ID] = true
+	default:
+	}
+}
+
+func (u *TokenUses) run() {
+	defer close(u.done)
+	for use := range u.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		if err := u.tokens.TouchToken(ctx, use.id, use.ip); err != nil {
+			log.Printf("Error recording token use: %v", err)
+		}
+		cancel()
+		u.mu.Lock()
+		delete(u.pending, use.id)
+		u.mu.Unlock()
+	}
+}
+
+// Close stops queuing uses and waits until the queued ones are recorded or ctx ends
+func (u *TokenUses) Close(ctx context.Context) error {
+	if u == nil {
+		return nil
+	}
+	u.mu.Lock()
+	if !u.closed {
+		u.closed = true
+		close(u.queue)
+	}
+	u.mu.Unlock()
+	select {
+	case <-u.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
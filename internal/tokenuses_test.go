@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// touchCounter counts TouchToken calls; the other methods are unused
+type touchCounter struct {
+	TokenRepositoryInterface
+	mu      sync.Mutex
+	touches []string
+}
+
+func (c *touchCounter) TouchToken(ctx context.Context, id uuid.UUID, ip string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.touches = append(c.touches, ip)
+	return nil
+}
+
+func TestTokenUsesRecord(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-10 * time.Second)
+	stale := now.Add(-2 * TokenUseInterval)
+
+	tests := []struct {
+		name  string
+		token APIToken
+		ip    string
+		want  []string
+	}{
+		{"never used", APIToken{ID: uuid.New()}, "10.0.0.1", []string{"10.0.0.1"}},
+		{"used recently from the same IP", APIToken{ID: uuid.New(), LastUsedAt: &recent, LastUsedIP: "10.0.0.1"}, "10.0.0.1", nil},
+		{"used recently from another IP", APIToken{ID: uuid.New(), LastUsedAt: &recent, LastUsedIP: "10.0.0.2"}, "10.0.0.1", []string{"10.0.0.1"}},
+		{"used a while ago", APIToken{ID: uuid.New(), LastUsedAt: &stale, LastUsedIP: "10.0.0.1"}, "10.0.0.1", []string{"10.0.0.1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &touchCounter{}
+			uses := NewTokenUses(repo)
+			uses.Record(&tt.token, tt.ip, now)
+			require.NoError(t, uses.Close(context.Background()))
+			assert.Equal(t, tt.want, repo.touches)
+		})
+	}
+}
+
+func TestTokenUsesSkipsQueuedTokens(t *testing.T) {
+	repo := &touchCounter{}
+	uses := NewTokenUses(repo)
+	// Hold the worker so the uses stay queued
+	repo.mu.Lock()
+	token := APIToken{ID: uuid.New()}
+	for i := 0; i < 5; i++ {
+		uses.Record(&token, "10.0.0.1", time.Now())
+	}
+	repo.mu.Unlock()
+
+	require.NoError(t, uses.Close(context.Background()))
+	assert.Len(t, repo.touches, 1)
+
+	// Uses after Close are not recorded
+	uses.Record(&token, "10.0.0.1", time.Now())
+	assert.Len(t, repo.touches, 1)
+}
@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// HeaderRequestID carries the request ID in requests and responses
+const HeaderRequestID = "X-Request-ID"
+
+// maxRequestIDLength bounds request IDs accepted from clients
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+type suppliedRequestIDKey struct{}
+
+// WithSuppliedRequestID returns a context carrying a request ID the client supplied.
+// Unlike generated ones, it is not forwarded to other services.
+func WithSuppliedRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(WithRequestID(ctx, id), suppliedRequestIDKey{}, true)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, or ""
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// HeaderTraceparent carries the W3C trace context of callers taking part in
+// distributed traces
+const HeaderTraceparent = "traceparent"
+
+type traceIDKey struct{}
+
+// WithTraceID returns a context carrying the trace ID of the request's caller
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+// TraceIDFromContext returns the trace ID carried by ctx, or ""
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// ParseTraceparent returns the trace ID of a traceparent header, 32 lowercase hex
+// digits, or "" when the header is missing or malformed
+func ParseTraceparent(h string) string {
+	parts := strings.Split(h, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || parts[1] != strings.ToLower(parts[1]) {
+		return ""
+	}
+	id, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	for _, b := range id {
+		if b != 0 {
+			return parts[1]
+		}
+	}
+	// An all-zero trace ID is invalid
+	return ""
+}
+
+// RequestID returns the client-supplied ID when it is safe to log and embed in SQL
+// comments, and a new one otherwise
+func RequestID(supplied string) string {
+	if ValidRequestID(supplied) {
+		return supplied
+	}
+	return uuid.NewString()
+}
+
+// ValidRequestID reports whether id is 1-128 letters, digits or "-_.:"
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// SetRequestIDHeader copies the request ID of req's context to an outgoing request,
+// unless the client supplied it: services called on the client's behalf only see IDs
+// the server generated
+func SetRequestIDHeader(req *http.Request) {
+	if supplied, _ := req.Context().Value(suppliedRequestIDKey{}).(bool); supplied {
+		return
+	}
+	if id := RequestIDFromContext(req.Context()); id != "" {
+		req.Header.Set(HeaderRequestID, id)
+	}
+}
+
+// traced returns q with the request ID of ctx prepended to every query as a comment,
+// so it shows up in pg_stat_activity and the Postgres logs
+func traced(ctx context.Context, q dbtx) dbtx {
+	id := RequestIDFromContext(ctx)
+	if id == "" {
+		return q
+	}
+	return tracedConn{q: q, comment: "/* request_id=" + id + " */ "}
+}
+
+// tracedConn prefixes queries with a comment. The request ID is validated by
+// ValidRequestID, so it cannot close the comment.
+type tracedConn struct {
+	q       dbtx
+	comment string
+}
+
+func (c tracedConn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return c.q.ExecContext(ctx, c.comment+query, args...)
+}
+
+func (c tracedConn) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return c.q.QueryContext(ctx, c.comment+query, args...)
+}
+
+func (c tracedConn) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return c.q.QueryRowContext(ctx, c.comment+query, args...)
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		supplied string
+		keep     bool
+	}{
+		{"3f2b8c1e-9a4d-4f6b-8e2a-1c5d7e9f0a2b", true},
+		{"req_01J8Z:edge.42", true},
+		{"", false},
+		{"*/ DROP TABLE events; /*", false},
+		{"has space", false},
+		{"new\nline", false},
+		{strings.Repeat("a", maxRequestIDLength), true},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.supplied, func(t *testing.T) {
+			id := RequestID(tt.supplied)
+			if tt.keep {
+				assert.Equal(t, tt.supplied, id)
+			} else {
+				assert.NotEqual(t, tt.supplied, id)
+				assert.True(t, ValidRequestID(id), "generated IDs are valid")
+			}
+		})
+	}
+}
+
+func TestSetRequestIDHeader(t *testing.T) {
+	outgoing := func(ctx context.Context) string {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://holidays.example.com", nil)
+		SetRequestIDHeader(req)
+		return req.Header.Get(HeaderRequestID)
+	}
+	assert.Equal(t, "generated", outgoing(WithRequestID(context.Background(), "generated")))
+	assert.Empty(t, outgoing(WithSuppliedRequestID(context.Background(), "from-client")))
+	assert.Empty(t, outgoing(context.Background()))
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"", ""},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", ""},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", ""},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01", ""},
+		{"00-4bf92f3577b34da6-01", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseTraceparent(tt.header))
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters, the defaults of RFC 6238 that every authenticator app supports
+const (
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+	// totpModulus is 10^totpDigits
+	totpModulus = 1_000_000
+	// totpSkew accepts codes this many periods early or late, for clock drift
+	totpSkew = 1
+)
+
+// RecoveryCodeCount is how many recovery codes are issued at once
+const RecoveryCodeCount = 10
+
+// HeaderTwoFactorCode carries a TOTP or recovery code on requests that need one
+const HeaderTwoFactorCode = "X-Two-Factor-Code"
+
+// ErrTwoFactorNotEnrolled is returned when a user has not started enrolling
+var ErrTwoFactorNotEnrolled = newDomainError(ErrNotFound, "two-factor authentication is not enabled")
+
+// ErrTwoFactorEnabled is returned when enrolling a user whose enrollment is confirmed
+var ErrTwoFactorEnabled = newDomainError(ErrConflict, "two-factor authentication is already enabled")
+
+// ErrTwoFactorRequired is returned when a request lacks the code it needs
+var ErrTwoFactorRequired = newDomainError(ErrForbidden, "a two-factor code is required in the X-Two-Factor-Code header")
+
+// ErrInvalidTwoFactorCode is returned for a wrong, expired or already used code
+var ErrInvalidTwoFactorCode = newDomainError(ErrForbidden, "invalid two-factor code")
+
+// TwoFactor is a user's TOTP enrollment. It is pending until a first code confirms
+// the user's authenticator holds the secret.
+type TwoFactor struct {
+	UserID string `json:"-"`
+	Secret string `json:"-"`
+	// LastUsedStep is the time step of the last accepted code
+	LastUsedStep      int64      `json:"-"`
+	ConfirmedAt       *time.Time `json:"confirmed_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`
+}
+
+// Enabled reports whether the enrollment was confirmed
+func (t *TwoFactor) Enabled() bool {
+	return t.ConfirmedAt != nil
+}
+
+// GenerateTOTPSecret returns a new random secret, base32 encoded as authenticator
+// apps expect it
+func GenerateTOTPSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf), nil
+}
+
+// TOTPProvisioningURI returns the otpauth:// URI that authenticator apps read from a
+// QR code, labeling the secret with issuer and account
+func TOTPProvisioningURI(issuer, account, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(int(totpPeriod.Seconds())))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// totpStep is the time step of t
+func totpStep(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod.Seconds())
+}
+
+// hotp computes the code of key at counter as RFC 4226 does
+func hotp(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0xF
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7FFFFFFF
+	return fmt.Sprintf("%0*d", totpDigits, value%totpModulus)
+}
+
+// decodeTOTPSecret decodes a base32 secret, tolerating the lower case and spaces
+// users type it with
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+}
+
+// TOTPCode returns the code of secret at t
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+	return hotp(key, totpStep(t)), nil
+}
+
+// MatchTOTP checks code against secret at now, allowing for clock drift, and returns
+// the time step it matched. Callers must refuse steps already used.
+func MatchTOTP(secret, code string, now time.Time) (int64, bool) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	step := totpStep(now)
+	for d := int64(-totpSkew); d <= totpSkew; d++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, step+d)), []byte(code)) == 1 {
+			return step + d, true
+		}
+	}
+	return 0, false
+}
+
+// isTOTPCode reports whether code has the shape of a TOTP code rather than a
+// recovery code
+func isTOTPCode(code string) bool {
+	if len(code) != totpDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// GenerateRecoveryCodes returns RecoveryCodeCount new codes, formatted as
+// xxxxx-xxxxx, and the hashes to store for them
+func GenerateRecoveryCodes() ([]string, [][]byte, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([][]byte, RecoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(buf))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = HashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode returns the lookup hash of a recovery code, ignoring case, dashes
+// and spaces. A code is only usable with a credential of its user, so its 50 bits
+// do not need a slow hash.
+func HashRecoveryCode(code string) []byte {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return HashToken(code)
+}
+
+// VerifyTwoFactor checks code, a TOTP or recovery code, against the confirmed
+// enrollment of userID and uses it up, so it is accepted only once
+func VerifyTwoFactor(ctx context.Context, repo TwoFactorRepositoryInterface, userID, code string, now time.Time) error {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return ErrTwoFactorRequired
+	}
+	tf, err := repo.GetTwoFactor(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !tf.Enabled() {
+		return ErrTwoFactorNotEnrolled
+	}
+	if isTOTPCode(code) {
+		step, ok := MatchTOTP(tf.Secret, code, now)
+		if !ok || step <= tf.LastUsedStep {
+			return ErrInvalidTwoFactorCode
+		}
+		return repo.UseTOTPStep(ctx, userID, step)
+	}
+	return repo.UseRecoveryCode(ctx, userID, HashRecoveryCode(code))
+}
+
+// TwoFactorEnabled reports whether userID has confirmed two-factor authentication
+func TwoFactorEnabled(ctx context.Context, repo TwoFactorRepositoryInterface, userID string) (bool, error) {
+	tf, err := repo.GetTwoFactor(ctx, userID)
+	if errors.Is(err, ErrTwoFactorNotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return tf.Enabled(), nil
+}
+
+type TwoFactorRepository struct {
+	db     *sql.DB
+	cipher *FieldCipher
+}
+
+// NewTwoFactorRepository creates a repository of TOTP enrollments. When cipher is
+// non-nil, secrets are encrypted at rest.
+func NewTwoFactorRepository(db *sql.DB, cipher *FieldCipher) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db, cipher: cipher}
+}
+
+// GetTwoFactor returns the enrollment of userID with its unused recovery codes counted
+func (r *TwoFactorRepository) GetTwoFactor(ctx context.Context, userID string) (*TwoFactor, error) {
+	query := `
+		SELECT user_id, secret, last_used_step, confirmed_at, created_at,
+			(SELECT COUNT(*) FROM two_factor_recovery_codes c WHERE c.user_id = t.user_id AND c.used_at IS NULL)
+		FROM user_two_factor t WHERE user_id = $1`
+	var tf TwoFactor
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, userID).Scan(&tf.UserID, &tf.Secret, &tf.LastUsedStep, &tf.ConfirmedAt, &tf.CreatedAt, &tf.RecoveryCodesLeft)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTwoFactorNotEnrolled
+		}
+		return nil, fmt.Errorf("failed to get two-factor enrollment: %w", err)
+	}
+	if err := r.cipher.decryptOptional(&tf.Secret); err != nil {
+		return nil, fmt.Errorf("failed to decrypt two-factor secret: %w", err)
+	}
+	return &tf, nil
+}
+
+// StartTwoFactor stores a pending enrollment of userID with secret, replacing a
+// pending one. It fails with ErrTwoFactorEnabled once the enrollment is confirmed.
+func (r *TwoFactorRepository) StartTwoFactor(ctx context.Context, userID, secret string) error {
+	sealed, err := r.cipher.encryptOptional(&secret)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt two-factor secret: %w", err)
+	}
+	res, err := conn(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO user_two_factor (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+		WHERE user_two_factor.confirmed_at IS NULL`, userID, *sealed)
+	if err != nil {
+		return fmt.Errorf("failed to start two-factor enrollment: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTwoFactorEnabled
+	}
+	return nil
+}
+
+// ConfirmTwoFactor enables the pending enrollment of userID, recording the step of
+// the code that confirmed it, and stores its first recovery codes
+func (r *TwoFactorRepository) ConfirmTwoFactor(ctx context.Context, userID string, step int64, recoveryHashes [][]byte) error {
+	return NewTxManager(r.db).JoinTx(ctx, func(ctx context.Context) error {
+		res, err := conn(ctx, r.db).ExecContext(ctx,
+			`UPDATE user_two_factor SET confirmed_at = NOW(), last_used_step = $2 WHERE user_id = $1 AND confirmed_at IS NULL`, userID, step)
+		if err != nil {
+			return fmt.Errorf("failed to confirm two-factor enrollment: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrTwoFactorEnabled
+		}
+		return r.ReplaceRecoveryCodes(ctx, userID, recoveryHashes)
+	})
+}
+
+// UseTOTPStep records that a code of step was accepted, failing with
+// ErrInvalidTwoFactorCode when a code of that step or a later one already was
+func (r *TwoFactorRepository) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx,
+		`UPDATE user_two_factor SET last_used_step = $2 WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2`, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to record two-factor code: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// UseRecoveryCode marks a recovery code of userID used, failing with
+// ErrInvalidTwoFactorCode when it does not exist or was used
+func (r *TwoFactorRepository) UseRecoveryCode(ctx context.Context, userID string, hash []byte) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx,
+		`UPDATE two_factor_recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userID, hash)
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes replaces every recovery code of userID with hashes
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID string, hashes [][]byte) error {
+	return NewTxManager(r.db).JoinTx(ctx, func(ctx context.Context) error {
+		if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM two_factor_recovery_codes WHERE user_id = $1`, userID); err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		for _, hash := range hashes {
+			if _, err := conn(ctx, r.db).ExecContext(ctx,
+				`INSERT INTO two_factor_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+				return fmt.Errorf("failed to store recovery code: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteTwoFactor removes the enrollment of userID with its recovery codes
+func (r *TwoFactorRepository) DeleteTwoFactor(ctx context.Context, userID string) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM user_two_factor WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete two-factor enrollment: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTwoFactorNotEnrolled
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// The SHA-1 vectors of RFC 6238, truncated to six digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	for unix, want := range map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924", 2000000000: "279037"} {
+		got, err := TOTPCode(secret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, unix)
+	}
+
+	now := time.Unix(1234567890, 0)
+	step, ok := MatchTOTP("gezd gnbv gy3t qojq gezd gnbv gy3t qojq", "005924", now)
+	assert.True(t, ok)
+	assert.Equal(t, totpStep(now), step)
+	// The previous code is still accepted, for clock drift, but not older ones
+	previous, _ := TOTPCode(secret, now.Add(-totpPeriod))
+	step, ok = MatchTOTP(secret, previous, now)
+	assert.True(t, ok)
+	assert.Equal(t, totpStep(now)-1, step)
+	older, _ := TOTPCode(secret, now.Add(-2*totpPeriod))
+	_, ok = MatchTOTP(secret, older, now)
+	assert.False(t, ok)
+	_, ok = MatchTOTP(secret, "5924", now)
+	assert.False(t, ok)
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	u, err := url.Parse(TOTPProvisioningURI("Events API", "ana@example.com", secret))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Events API:ana@example.com", u.Path)
+	assert.Equal(t, secret, u.Query().Get("secret"))
+	assert.Equal(t, "Events API", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes()
+	require.NoError(t, err)
+	require.Len(t, codes, RecoveryCodeCount)
+	assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, codes[0])
+	assert.NotEqual(t, codes[0], codes[1])
+	assert.Equal(t, hashes[0], HashRecoveryCode(codes[0]))
+	// Codes are matched however they are typed
+	assert.Equal(t, hashes[0], HashRecoveryCode(" "+codes[0][:5]+" "+codes[0][6:]))
+	assert.Equal(t, hashes[0], HashRecoveryCode(codes[0][:5]+codes[0][6:]))
+	assert.False(t, isTOTPCode(codes[0]))
+	assert.True(t, isTOTPCode("012345"))
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// dbtx is the part of *sql.DB and *sql.Tx the repositories use
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// afterCommitKey carries the callbacks of the transaction in the context
+type afterCommitKey struct{}
+
+// afterCommit is the callbacks to run once a transaction commits
+type afterCommit struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+// AfterCommit runs fn once the transaction of ctx commits, and not at all when it rolls
+// back. Without a transaction fn runs right away. It is for side effects outside the
+// database, such as purging caches, that must not see a write that may still roll back.
+func AfterCommit(ctx context.Context, fn func()) {
+	callbacks, ok := ctx.Value(afterCommitKey{}).(*afterCommit)
+	if !ok {
+		fn()
+		return
+	}
+	callbacks.mu.Lock()
+	callbacks.fns = append(callbacks.fns, fn)
+	callbacks.mu.Unlock()
+}
+
+// conn returns the transaction carried by ctx, or db when there is none, tagged with
+// the request ID of ctx
+func conn(ctx context.Context, db *sql.DB) dbtx {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return traced(ctx, tx)
+	}
+	return traced(ctx, db)
+}
+
+// TxManager runs functions inside a database transaction. Repositories that look up
+// their connection with conn join the transaction through the context.
+type TxManager struct {
+	db *sql.DB
+}
+
+// NewTxManager creates a new transaction manager
+func NewTxManager(db *sql.DB) *TxManager {
+	return &TxManager{db: db}
+}
+
+// InTx calls fn with a context carrying a new transaction, committing it when fn
+// returns nil and rolling it back otherwise. fn's error is returned unchanged.
+func (m *TxManager) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fmt.Errorf("transaction already in progress")
+	}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	callbacks := &afterCommit{}
+	txCtx := context.WithValue(context.WithValue(ctx, txKey{}, tx), afterCommitKey{}, callbacks)
+	if err := fn(txCtx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	for _, fn := range callbacks.fns {
+		fn()
+	}
+	return nil
+}
+
+// JoinTx calls fn in the transaction carried by ctx, or in a new one, as InTx does,
+// when there is none. Repositories use it for writes that must be atomic on their own
+// and also take part in a caller's transaction.
+func (m *TxManager) JoinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+	return m.InTx(ctx, fn)
+}
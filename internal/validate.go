@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Policies for creating events that already ended: historical logs allow them, other
+// deployments warn about or reject them
+const (
+	PastEventPolicyAllow  = "allow"
+	PastEventPolicyWarn   = "warn"
+	PastEventPolicyReject = "reject"
+)
+
+// MaxDescriptionLength caps event descriptions in bytes, which are rendered on every
+// ?render=html read and on public event pages
+const MaxDescriptionLength = 10000
+
+// ValidateEvent returns a client-facing message when the event fields are invalid.
+// Messages are translation keys, so callers can pass them to Translate.
+func ValidateEvent(e EventDB) string {
+	if strings.TrimSpace(e.Title) == "" {
+		return "title is required"
+	}
+	if len(e.Title) > 100 {
+		return "title must be <= 100 characters"
+	}
+	if e.Description != nil && len(*e.Description) > MaxDescriptionLength {
+		return "description must be <= 10000 characters"
+	}
+	if e.StartTime.IsZero() || e.EndTime.IsZero() {
+		return "start_time and end_time are required (RFC3339)"
+	}
+	if !e.StartTime.Before(e.EndTime) {
+		return "start_time must be before end_time"
+	}
+	if e.DescriptionFormat != "" && e.DescriptionFormat != DescriptionFormatPlain && e.DescriptionFormat != DescriptionFormatMarkdown {
+		return "description_format must be plain or markdown"
+	}
+	if (e.Latitude == nil) != (e.Longitude == nil) {
+		return "latitude and longitude must be provided together"
+	}
+	if e.Latitude != nil && (*e.Latitude < -90 || *e.Latitude > 90 || *e.Longitude < -180 || *e.Longitude > 180) {
+		return "latitude must be within [-90, 90] and longitude within [-180, 180]"
+	}
+	if (e.PriceCents == nil) != (e.Currency == nil) {
+		return "price_cents and currency must be provided together"
+	}
+	if e.PriceCents != nil && *e.PriceCents < 0 {
+		return "price_cents must not be negative"
+	}
+	if e.Currency != nil && !ValidCurrency(*e.Currency) {
+		return "currency must be an ISO 4217 code such as EUR"
+	}
+	if e.TicketQuota != nil && *e.TicketQuota <= 0 {
+		return "ticket_quota must be positive"
+	}
+	if (e.ExternalID == nil) != (e.Source == nil) {
+		return "external_id and source must be provided together"
+	}
+	if e.ExternalID != nil && (*e.ExternalID == "" || *e.Source == "" || len(*e.ExternalID) > 255 || len(*e.Source) > 100) {
+		return "external_id must be 1-255 characters and source 1-100"
+	}
+	return ""
+}
+
+// ValidateEventLimits returns a client-facing message when an event breaks the limits
+// of cfg that apply to every write
+func ValidateEventLimits(cfg Config, e EventDB) string {
+	if cfg.MaxEventDuration > 0 && e.EndTime.Sub(e.StartTime) > cfg.MaxEventDuration {
+		return fmt.Sprintf("events may last at most %s", FormatDuration(cfg.MaxEventDuration))
+	}
+	return ""
+}
+
+// ValidateNewEvent returns a client-facing message when an event may not be created at
+// now under the policies of cfg. The past policy does not apply to updates, so past
+// events can still be corrected; ValidateEventUpdate holds updates to the rest.
+func ValidateNewEvent(cfg Config, e EventDB, now time.Time) string {
+	if msg := ValidateEventLimits(cfg, e); msg != "" {
+		return msg
+	}
+	if cfg.PastEventPolicy == PastEventPolicyReject && e.EndTime.Before(now) {
+		return "events that already ended cannot be created"
+	}
+	if msg := validateHorizon(cfg, e, now); msg != "" {
+		return msg
+	}
+	return validateLeadTime(cfg, e, now)
+}
+
+// ValidateEventUpdate returns a client-facing message when current may not be changed
+// to e at now under the policies of cfg. Moving the start is held to the booking
+// horizon, and for events that have not started yet to the lead time, as creates are.
+func ValidateEventUpdate(cfg Config, current, e EventDB, now time.Time) string {
+	if msg := ValidateEventLimits(cfg, e); msg != "" {
+		return msg
+	}
+	if e.StartTime.Equal(current.StartTime) {
+		return ""
+	}
+	if msg := validateHorizon(cfg, e, now); msg != "" {
+		return msg
+	}
+	if current.StartTime.Before(now) {
+		return ""
+	}
+	return validateLeadTime(cfg, e, now)
+}
+
+func validateHorizon(cfg Config, e EventDB, now time.Time) string {
+	if cfg.BookingHorizon > 0 && e.StartTime.After(now.Add(cfg.BookingHorizon)) {
+		return fmt.Sprintf("events may start at most %s ahead", FormatDuration(cfg.BookingHorizon))
+	}
+	return ""
+}
+
+func validateLeadTime(cfg Config, e EventDB, now time.Time) string {
+	if cfg.MinLeadTime > 0 && e.StartTime.Before(now.Add(cfg.MinLeadTime)) {
+		return fmt.Sprintf("events must start at least %s ahead", FormatDuration(cfg.MinLeadTime))
+	}
+	return ""
+}
+
+// FormatDuration renders d without the zero minutes and seconds of time.Duration's
+// String, e.g. 24h rather than 24h0m0s
+func FormatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateEventDescriptionLength(t *testing.T) {
+	now := time.Date(2025, 10, 3, 12, 0, 0, 0, time.UTC)
+	description := strings.Repeat("x", MaxDescriptionLength)
+	event := EventDB{Title: "Retro", Description: &description, StartTime: now, EndTime: now.Add(time.Hour)}
+	assert.Empty(t, ValidateEvent(event))
+	description += "x"
+	assert.Equal(t, "description must be <= 10000 characters", ValidateEvent(event))
+}
+
+func TestValidateNewEvent(t *testing.T) {
+	now := time.Date(2025, 10, 3, 12, 0, 0, 0, time.UTC)
+	past := EventDB{Title: "Retro", StartTime: now.Add(-2 * time.Hour), EndTime: now.Add(-time.Hour)}
+	ongoing := EventDB{Title: "Retro", StartTime: now.Add(-time.Hour), EndTime: now.Add(time.Hour)}
+
+	for _, policy := range []string{PastEventPolicyAllow, PastEventPolicyWarn, ""} {
+		assert.Empty(t, ValidateNewEvent(Config{PastEventPolicy: policy}, past, now), policy)
+	}
+	reject := Config{PastEventPolicy: PastEventPolicyReject}
+	assert.Equal(t, "events that already ended cannot be created", ValidateNewEvent(reject, past, now))
+	assert.Empty(t, ValidateNewEvent(reject, ongoing, now))
+}
+
+func TestValidateEventLimits(t *testing.T) {
+	now := time.Date(2025, 10, 3, 12, 0, 0, 0, time.UTC)
+	cfg := Config{MaxEventDuration: 8 * time.Hour, BookingHorizon: 90 * 24 * time.Hour, MinLeadTime: 2 * time.Hour}
+	at := func(start, length time.Duration) EventDB {
+		return EventDB{Title: "Room 4", StartTime: now.Add(start), EndTime: now.Add(start + length)}
+	}
+
+	assert.Empty(t, ValidateNewEvent(cfg, at(24*time.Hour, time.Hour), now))
+	assert.Equal(t, "events may last at most 8h", ValidateNewEvent(cfg, at(24*time.Hour, 9*time.Hour), now))
+	assert.Equal(t, "events may last at most 8h", ValidateEventLimits(cfg, at(-24*time.Hour, 9*time.Hour)))
+	assert.Equal(t, "events may start at most 2160h ahead", ValidateNewEvent(cfg, at(91*24*time.Hour, time.Hour), now))
+	assert.Equal(t, "events must start at least 2h ahead", ValidateNewEvent(cfg, at(time.Hour, time.Hour), now))
+	// Updates are only held to the duration
+	assert.Empty(t, ValidateEventLimits(cfg, at(time.Hour, time.Hour)))
+	assert.Empty(t, ValidateNewEvent(Config{}, at(time.Hour, 48*time.Hour), now))
+}
+
+func TestValidateEventUpdate(t *testing.T) {
+	now := time.Date(2025, 10, 3, 12, 0, 0, 0, time.UTC)
+	cfg := Config{MaxEventDuration: 8 * time.Hour, BookingHorizon: 90 * 24 * time.Hour, MinLeadTime: 2 * time.Hour}
+	at := func(start, length time.Duration) EventDB {
+		return EventDB{Title: "Room 4", StartTime: now.Add(start), EndTime: now.Add(start + length)}
+	}
+	upcoming, started := at(24*time.Hour, time.Hour), at(-time.Hour, 2*time.Hour)
+
+	assert.Empty(t, ValidateEventUpdate(cfg, upcoming, at(48*time.Hour, time.Hour), now))
+	assert.Equal(t, "events may last at most 8h", ValidateEventUpdate(cfg, upcoming, at(24*time.Hour, 9*time.Hour), now))
+	assert.Equal(t, "events may start at most 2160h ahead", ValidateEventUpdate(cfg, upcoming, at(91*24*time.Hour, time.Hour), now))
+	assert.Equal(t, "events must start at least 2h ahead", ValidateEventUpdate(cfg, upcoming, at(time.Hour, time.Hour), now))
+	// Events keeping their start, such as those already inside the lead time, can still be edited
+	soon := at(time.Hour, time.Hour)
+	assert.Empty(t, ValidateEventUpdate(cfg, soon, at(time.Hour, 2*time.Hour), now))
+	// and events that already started can be corrected
+	assert.Empty(t, ValidateEventUpdate(cfg, started, at(-2*time.Hour, 2*time.Hour), now))
+	assert.Equal(t, "events may start at most 2160h ahead", ValidateEventUpdate(cfg, started, at(91*24*time.Hour, time.Hour), now))
+}
+
+func TestFormatDuration(t *testing.T) {
+	assert.Equal(t, "24h", FormatDuration(24*time.Hour))
+	assert.Equal(t, "1h30m", FormatDuration(90*time.Minute))
+	assert.Equal(t, "15m", FormatDuration(15*time.Minute))
+	assert.Equal(t, "45s", FormatDuration(45*time.Second))
+	assert.Equal(t, "1h0m5s", FormatDuration(time.Hour+5*time.Second))
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"time"
+	"unicode"
+)
+
+// Codes of the warnings given on event writes
+const (
+	WarningPast         = "past"
+	WarningLongDuration = "long_duration"
+	WarningAllCapsTitle = "all_caps_title"
+)
+
+// minAllCapsLetters keeps short acronyms such as "QA" or "AGM" from counting as shouting
+const minAllCapsLetters = 6
+
+// EventWarning is a hint about a valid event that may still be a mistake, such as one
+// scheduled in the past. Writes succeed regardless; clients decide whether to show it.
+type EventWarning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	format  string
+	args    []any
+}
+
+func newEventWarning(code, format string, args ...any) EventWarning {
+	return EventWarning{Code: code, Message: Translate(DefaultLanguage, format, args...), format: format, args: args}
+}
+
+// Localize returns the warning with its message in lang
+func (w EventWarning) Localize(lang string) EventWarning {
+	if w.format != "" {
+		w.Message = Translate(lang, w.format, w.args...)
+	}
+	return w
+}
+
+// EventWarnings applies the heuristics enabled in cfg to an event written at now
+func EventWarnings(cfg Config, event EventDB, now time.Time) []EventWarning {
+	var warnings []EventWarning
+	if cfg.PastEventPolicy == PastEventPolicyWarn && event.EndTime.Before(now) {
+		warnings = append(warnings, newEventWarning(WarningPast, "event ended in the past"))
+	}
+	if cfg.WarnDurationOver > 0 && event.EndTime.Sub(event.StartTime) > cfg.WarnDurationOver {
+		warnings = append(warnings, newEventWarning(WarningLongDuration, "event lasts longer than %s", FormatDuration(cfg.WarnDurationOver)))
+	}
+	if cfg.WarnAllCapsTitles && allCaps(event.Title) {
+		warnings = append(warnings, newEventWarning(WarningAllCapsTitle, "title is all capitals"))
+	}
+	return warnings
+}
+
+// allCaps reports whether s has enough letters to read as words and none in lowercase
+func allCaps(s string) bool {
+	letters := 0
+	for _, c := range s {
+		if !unicode.IsLetter(c) {
+			continue
+		}
+		if unicode.IsLower(c) {
+			return false
+		}
+		if unicode.IsUpper(c) {
+			letters++
+		}
+	}
+	return letters >= minAllCapsLetters
+}
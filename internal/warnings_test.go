@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventWarnings(t *testing.T) {
+	now := time.Date(2025, 10, 3, 12, 0, 0, 0, time.UTC)
+	cfg := Config{PastEventPolicy: PastEventPolicyWarn, WarnDurationOver: 24 * time.Hour, WarnAllCapsTitles: true}
+	codes := func(cfg Config, e EventDB) []string {
+		var out []string
+		for _, w := range EventWarnings(cfg, e, now) {
+			out = append(out, w.Code)
+		}
+		return out
+	}
+
+	event := EventDB{Title: "Team offsite", StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)}
+	assert.Empty(t, codes(cfg, event))
+
+	event.StartTime, event.EndTime = now.Add(-2*time.Hour), now.Add(-time.Hour)
+	assert.Equal(t, []string{WarningPast}, codes(cfg, event))
+	event.EndTime = now.Add(48 * time.Hour)
+	assert.Equal(t, []string{WarningLongDuration}, codes(cfg, event))
+	event.Title = "TEAM OFFSITE!!"
+	assert.Equal(t, []string{WarningLongDuration, WarningAllCapsTitle}, codes(cfg, event))
+	assert.Empty(t, codes(Config{}, event))
+
+	// Acronyms are not shouting
+	event = EventDB{Title: "QA / AGM", StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)}
+	assert.Empty(t, codes(cfg, event))
+}
+
+func TestEventWarningLocalize(t *testing.T) {
+	w := newEventWarning(WarningAllCapsTitle, "title is all capitals")
+	assert.Equal(t, "title is all capitals", w.Message)
+	assert.NotEqual(t, w.Message, w.Localize("es").Message)
+	assert.Equal(t, WarningAllCapsTitle, w.Localize("es").Code)
+}
@@ -0,0 +1,225 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WeatherHorizon is how far ahead forecasts are attached to events
+const WeatherHorizon = 7 * 24 * time.Hour
+
+// ErrRateLimited is returned by providers when the upstream API throttles us
+var ErrRateLimited = errors.New("weather provider rate limited")
+
+// Forecast is the daily weather forecast for an event location
+type Forecast struct {
+	Date                     string   `json:"date"`
+	TemperatureMaxC          *float64 `json:"temperature_max_c,omitempty"`
+	TemperatureMinC          *float64 `json:"temperature_min_c,omitempty"`
+	PrecipitationProbability *float64 `json:"precipitation_probability,omitempty"`
+	Summary                  string   `json:"summary"`
+	Provider                 string   `json:"provider"`
+}
+
+// WeatherProvider returns the forecast for a coordinate on a given day
+type WeatherProvider interface {
+	Forecast(ctx context.Context, lat, lon float64, day time.Time) (*Forecast, error)
+}
+
+// NewWeatherProvider builds the provider selected by cfg.WeatherProvider, or nil when disabled
+func NewWeatherProvider(cfg Config) WeatherProvider {
+	switch cfg.WeatherProvider {
+	case "openmeteo":
+		return NewCachedWeatherProvider(&openMeteoProvider{
+			client:  &http.Client{Timeout: 5 * time.Second},
+			baseURL: "https://api.open-meteo.com/v1/forecast",
+		}, cfg.WeatherCacheTTL)
+	default:
+		return nil
+	}
+}
+
+// openMeteoProvider queries the keyless Open-Meteo forecast API
+type openMeteoProvider struct {
+	client  *http.Client
+	baseURL string
+}
+
+// rateLimitError carries the upstream Retry-After hint
+type rateLimitError struct {
+	retryAfter time.Duration
+}
+
+func (e *rateLimitError) Error() string { return ErrRateLimited.Error() }
+func (e *rateLimitError) Unwrap() error { return ErrRateLimited }
+
+func (p *openMeteoProvider) Forecast(ctx context.Context, lat, lon float64, day time.Time) (*Forecast, error) {
+	date := day.UTC().Format("2006-01-02")
+	q := url.Values{}
+	q.Set("latitude", strconv.FormatFloat(lat, 'f', 4, 64))
+	q.Set("longitude", strconv.FormatFloat(lon, 'f', 4, 64))
+	q.Set("daily", "temperature_2m_max,temperature_2m_min,precipitation_probability_max,weathercode")
+	q.Set("timezone", "UTC")
+	q.Set("start_date", date)
+	q.Set("end_date", date)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	SetRequestIDHeader(req)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch forecast: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retry := time.Minute
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retry = time.Duration(s) * time.Second
+		}
+		return nil, &rateLimitError{retryAfter: retry}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("weather API returned %s", resp.Status)
+	}
+
+	var payload struct {
+		Daily struct {
+			Time        []string   `json:"time"`
+			Max         []*float64 `json:"temperature_2m_max"`
+			Min         []*float64 `json:"temperature_2m_min"`
+			Precip      []*float64 `json:"precipitation_probability_max"`
+			WeatherCode []*int     `json:"weathercode"`
+		} `json:"daily"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode forecast: %w", err)
+	}
+	d := payload.Daily
+	if len(d.Time) == 0 || len(d.Max) == 0 || len(d.Min) == 0 || len(d.Precip) == 0 || len(d.WeatherCode) == 0 {
+		return nil, nil
+	}
+
+	summary := "unknown"
+	if d.WeatherCode[0] != nil {
+		summary = weatherCodeSummary(*d.WeatherCode[0])
+	}
+	return &Forecast{
+		Date:                     d.Time[0],
+		TemperatureMaxC:          d.Max[0],
+		TemperatureMinC:          d.Min[0],
+		PrecipitationProbability: d.Precip[0],
+		Summary:                  summary,
+		Provider:                 "open-meteo",
+	}, nil
+}
+
+// weatherCodeSummary maps WMO weather interpretation codes to short descriptions
+func weatherCodeSummary(code int) string {
+	switch {
+	case code == 0:
+		return "clear sky"
+	case code <= 3:
+		return "partly cloudy"
+	case code == 45 || code == 48:
+		return "fog"
+	case code >= 51 && code <= 57:
+		return "drizzle"
+	case code >= 61 && code <= 67, code >= 80 && code <= 82:
+		return "rain"
+	case code >= 71 && code <= 77, code == 85 || code == 86:
+		return "snow"
+	case code >= 95:
+		return "thunderstorm"
+	}
+	return "unknown"
+}
+
+// maxCachedForecasts triggers pruning of expired entries; when none have expired, an
+// arbitrary one is dropped so the cache stays bounded
+const maxCachedForecasts = 1000
+
+type cachedForecast struct {
+	forecast *Forecast
+	expires  time.Time
+}
+
+// CachedWeatherProvider memoizes forecasts per rounded coordinate and day,
+// and stops calling the upstream provider while it is rate limited
+type CachedWeatherProvider struct {
+	provider WeatherProvider
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu           sync.Mutex
+	cache        map[string]cachedForecast
+	blockedUntil time.Time
+}
+
+// NewCachedWeatherProvider wraps provider with a TTL cache
+func NewCachedWeatherProvider(provider WeatherProvider, ttl time.Duration) *CachedWeatherProvider {
+	return &CachedWeatherProvider{
+		provider: provider,
+		ttl:      ttl,
+		now:      time.Now,
+		cache:    map[string]cachedForecast{},
+	}
+}
+
+// Forecast implements WeatherProvider
+func (c *CachedWeatherProvider) Forecast(ctx context.Context, lat, lon float64, day time.Time) (*Forecast, error) {
+	// ~1km precision is plenty for a daily forecast and keeps nearby events on one entry
+	key := fmt.Sprintf("%.2f,%.2f,%s", math.Round(lat*100)/100, math.Round(lon*100)/100, day.UTC().Format("2006-01-02"))
+	now := c.now()
+
+	c.mu.Lock()
+	entry, ok := c.cache[key]
+	blocked := now.Before(c.blockedUntil)
+	c.mu.Unlock()
+
+	if ok && now.Before(entry.expires) {
+		return entry.forecast, nil
+	}
+	if blocked {
+		return nil, ErrRateLimited
+	}
+
+	forecast, err := c.provider.Forecast(ctx, lat, lon, day)
+	if err != nil {
+		var rl *rateLimitError
+		if errors.As(err, &rl) {
+			c.mu.Lock()
+			c.blockedUntil = now.Add(rl.retryAfter)
+			c.mu.Unlock()
+		}
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if len(c.cache) >= maxCachedForecasts {
+		for k, e := range c.cache {
+			if !now.Before(e.expires) {
+				delete(c.cache, k)
+			}
+		}
+		for k := range c.cache {
+			if len(c.cache) < maxCachedForecasts {
+				break
+			}
+			delete(c.cache, k)
+		}
+	}
+	c.cache[key] = cachedForecast{forecast: forecast, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return forecast, nil
+}
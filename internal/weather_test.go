@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingWeather answers every forecast with a fixed summary, or err when it is set
+type countingWeather struct {
+	calls int
+	err   error
+}
+
+func (f *countingWeather) Forecast(ctx context.Context, lat, lon float64, day time.Time) (*Forecast, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &Forecast{Date: day.Format(time.DateOnly), Summary: "clear"}, nil
+}
+
+func TestCachedWeatherProvider(t *testing.T) {
+	upstream := &countingWeather{}
+	cached := NewCachedWeatherProvider(upstream, time.Hour)
+	now := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+	cached.now = func() time.Time { return now }
+	ctx := context.Background()
+	day := now.AddDate(0, 0, 2)
+
+	f, err := cached.Forecast(ctx, 40.4168, -3.7038, day)
+	require.NoError(t, err)
+	assert.Equal(t, "clear", f.Summary)
+
+	// Nearby coordinates share the entry until it expires
+	_, err = cached.Forecast(ctx, 40.4171, -3.7041, day)
+	require.NoError(t, err)
+	assert.Equal(t, 1, upstream.calls)
+
+	_, err = cached.Forecast(ctx, 40.4168, -3.7038, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	assert.Equal(t, 2, upstream.calls, "another day is another entry")
+
+	now = now.Add(time.Hour)
+	_, err = cached.Forecast(ctx, 40.4168, -3.7038, day)
+	require.NoError(t, err)
+	assert.Equal(t, 3, upstream.calls, "expired entries are fetched again")
+}
+
+func TestCachedWeatherProviderRateLimit(t *testing.T) {
+	upstream := &countingWeather{}
+	cached := NewCachedWeatherProvider(upstream, time.Hour)
+	now := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+	cached.now = func() time.Time { return now }
+	ctx := context.Background()
+	day := now.AddDate(0, 0, 1)
+
+	_, err := cached.Forecast(ctx, 40.4168, -3.7038, day)
+	require.NoError(t, err)
+
+	upstream.err = &rateLimitError{retryAfter: time.Minute}
+	_, err = cached.Forecast(ctx, 48.8566, 2.3522, day)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, 2, upstream.calls)
+
+	// Until Retry-After has passed, the upstream is left alone but cached entries are served
+	upstream.err = nil
+	now = now.Add(59 * time.Second)
+	_, err = cached.Forecast(ctx, 48.8566, 2.3522, day)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	_, err = cached.Forecast(ctx, 40.4168, -3.7038, day)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, upstream.calls)
+
+	now = now.Add(time.Second)
+	_, err = cached.Forecast(ctx, 48.8566, 2.3522, day)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, upstream.calls)
+
+	// Other failures do not block the upstream
+	upstream.err = errors.New("connection refused")
+	_, err = cached.Forecast(ctx, 51.5072, -0.1276, day)
+	assert.EqualError(t, err, "connection refused")
+	upstream.err = nil
+	_, err = cached.Forecast(ctx, 51.5072, -0.1276, day)
+	assert.NoError(t, err)
+}
+
+func TestCachedWeatherProviderPrunes(t *testing.T) {
+	upstream := &countingWeather{}
+	cached := NewCachedWeatherProvider(upstream, time.Hour)
+	now := time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)
+	cached.now = func() time.Time { return now }
+	ctx := context.Background()
+	day := now.AddDate(0, 0, 1)
+	fill := func(n int, lat float64) {
+		for i := 0; i < n; i++ {
+			_, err := cached.Forecast(ctx, lat, float64(i)/10, day)
+			require.NoError(t, err)
+		}
+	}
+
+	fill(maxCachedForecasts-10, 10)
+	now = now.Add(30 * time.Minute)
+	fill(10, 20)
+	require.Len(t, cached.cache, maxCachedForecasts)
+
+	// The first entries have expired and are dropped when the cache is full
+	now = now.Add(45 * time.Minute)
+	fill(1, 30)
+	assert.Len(t, cached.cache, 11)
+
+	// Without expired entries, the cache still does not grow past the limit
+	fill(maxCachedForecasts, 40)
+	assert.Len(t, cached.cache, maxCachedForecasts)
+}
@@ -0,0 +1,679 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// JobDeliverWebhooks is the scheduler job that retries the webhook deliveries that are due
+const JobDeliverWebhooks = "deliver_webhooks"
+
+// Event types webhooks subscribe to
+const (
+	WebhookEventCreated = "event.created"
+	WebhookEventUpdated = "event.updated"
+	WebhookEventDeleted = "event.deleted"
+)
+
+// WebhookEventTypes lists every event type, in display order
+var WebhookEventTypes = []string{WebhookEventCreated, WebhookEventUpdated, WebhookEventDeleted}
+
+// Delivery statuses. A pending delivery is retried until it succeeds or has been tried
+// maxWebhookAttempts times, when it fails.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderWebhookDelivery = "X-Webhook-Delivery"
+	HeaderWebhookEvent    = "X-Webhook-Event"
+)
+
+// maxWebhookAttempts is how many times a failing delivery is tried automatically
+const maxWebhookAttempts = 5
+
+// webhookBackoff is the wait before each retry, by the number of failed attempts
+var webhookBackoff = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour}
+
+// webhookLease is how long a delivery being sent is kept from the job, which picks it up
+// again after that if the instance sending it went away
+const webhookLease = 2 * time.Minute
+
+// webhookBatch is how many due deliveries one run of the job sends
+const webhookBatch = 500
+
+// webhookTimeout bounds each request to a webhook
+const webhookTimeout = 10 * time.Second
+
+// webhookNotifyTimeout bounds the first attempt of the deliveries of one write, which
+// are sent in the background
+const webhookNotifyTimeout = time.Minute
+
+// ErrWebhookNotFound is returned when a webhook does not exist
+var ErrWebhookNotFound = newDomainError(ErrNotFound, "webhook not found")
+
+// ErrDeliveryNotFound is returned when a delivery does not exist for the webhook
+var ErrDeliveryNotFound = newDomainError(ErrNotFound, "delivery not found")
+
+// Webhook is an endpoint the events of one calendar, or of every calendar when
+// CalendarID is nil, are POSTed to as they are written
+type Webhook struct {
+	ID         uuid.UUID  `json:"id"`
+	OwnerID    string     `json:"owner_id"`
+	URL        string     `json:"url"`
+	Events     []string   `json:"events"`
+	CalendarID *uuid.UUID `json:"calendar_id"`
+	// Secret signs the deliveries; it is only shown when created or rotated
+	Secret string `json:"-"`
+	// PreviousSecret signs the deliveries too until PreviousSecretExpiresAt, after a
+	// rotation
+	PreviousSecret          string     `json:"-"`
+	PreviousSecretExpiresAt *time.Time `json:"previous_secret_expires_at,omitempty"`
+	CreatedAt               time.Time  `json:"created_at"`
+	UpdatedAt               time.Time  `json:"updated_at"`
+}
+
+// Subscribes reports whether the webhook receives eventType
+func (w Webhook) Subscribes(eventType string) bool {
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateWebhook returns a message describing why w is invalid, or "" if it is valid.
+// Repeated event types are removed.
+func ValidateWebhook(w *Webhook) string {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || len(w.URL) > 2048 {
+		return "url must be an http or https URL"
+	}
+	if len(w.Events) == 0 {
+		return fmt.Sprintf("events must list some of %v", WebhookEventTypes)
+	}
+	seen := map[string]bool{}
+	events := make([]string, 0, len(w.Events))
+	for _, e := range w.Events {
+		if !IsWebhookEventType(e) {
+			return fmt.Sprintf("events must list some of %v", WebhookEventTypes)
+		}
+		if !seen[e] {
+			seen[e] = true
+			events = append(events, e)
+		}
+	}
+	w.Events = events
+	return ""
+}
+
+// IsWebhookEventType reports whether webhooks can subscribe to eventType
+func IsWebhookEventType(eventType string) bool {
+	return eventType == WebhookEventCreated || eventType == WebhookEventUpdated || eventType == WebhookEventDeleted
+}
+
+// WebhookDelivery is one payload sent to one webhook, with the requests made for it
+type WebhookDelivery struct {
+	ID            uuid.UUID         `json:"id"`
+	WebhookID     uuid.UUID         `json:"webhook_id"`
+	EventType     string            `json:"event_type"`
+	EventID       uuid.UUID         `json:"event_id"`
+	Payload       json.RawMessage   `json:"payload"`
+	Status        string            `json:"status"`
+	AttemptCount  int               `json:"attempt_count"`
+	NextAttemptAt *time.Time        `json:"next_attempt_at"`
+	Attempts      []DeliveryAttempt `json:"attempts"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// DeliveryAttempt is one request made for a delivery and how the endpoint answered.
+// ResponseCode is nil when no response came back.
+type DeliveryAttempt struct {
+	ID           uuid.UUID `json:"id"`
+	DeliveryID   uuid.UUID `json:"delivery_id"`
+	Attempt      int       `json:"attempt"`
+	ResponseCode *int      `json:"response_code"`
+	LatencyMS    int64     `json:"latency_ms"`
+	Error        string    `json:"error,omitempty"`
+	// Replay is set for the attempts requested through the API
+	Replay      bool      `json:"replay"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// Succeeded reports whether the endpoint accepted the payload
+func (a DeliveryAttempt) Succeeded() bool {
+	return a.ResponseCode != nil && *a.ResponseCode >= 200 && *a.ResponseCode <= 299
+}
+
+// Gone reports whether the endpoint answered 410 Gone, asking to be unsubscribed
+func (a DeliveryAttempt) Gone() bool {
+	return a.ResponseCode != nil && *a.ResponseCode == http.StatusGone
+}
+
+// webhookPayload is the body POSTed to webhooks
+type webhookPayload struct {
+	// ID identifies the write; the deliveries of every webhook share it
+	ID         uuid.UUID     `json:"id"`
+	Type       string        `json:"type"`
+	OccurredAt time.Time     `json:"occurred_at"`
+	Event      webhookEvent  `json:"event"`
+	Changes    []FieldChange `json:"changes,omitempty"`
+}
+
+// webhookEvent is the event a payload is about. The description and location are left
+// out as they are encrypted at rest; deletions only carry the IDs.
+type webhookEvent struct {
+	ID         uuid.UUID  `json:"id"`
+	CalendarID *uuid.UUID `json:"calendar_id"`
+	Title      string     `json:"title,omitempty"`
+	StartTime  *time.Time `json:"start_time,omitempty"`
+	EndTime    *time.Time `json:"end_time,omitempty"`
+	Status     string     `json:"status,omitempty"`
+	Version    int64      `json:"version,omitempty"`
+}
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+// NewWebhookRepository creates a new webhook repository
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+const webhookColumns = `id, owner_id, url, events, calendar_id, secret, previous_secret, previous_secret_expires_at, created_at, updated_at`
+
+func scanWebhook(row rowScanner, w *Webhook) error {
+	var previous sql.NullString
+	if err := row.Scan(&w.ID, &w.OwnerID, &w.URL, pq.Array(&w.Events), &w.CalendarID, &w.Secret, &previous, &w.PreviousSecretExpiresAt, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		return err
+	}
+	w.PreviousSecret = previous.String
+	return nil
+}
+
+const deliveryColumns = `id, webhook_id, event_type, event_id, payload, status, attempt_count, next_attempt_at, created_at, updated_at`
+
+func scanDelivery(row rowScanner, d *WebhookDelivery) error {
+	var payload []byte
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.EventType, &d.EventID, &payload, &d.Status, &d.AttemptCount, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		return err
+	}
+	d.Payload = payload
+	d.Attempts = []DeliveryAttempt{}
+	return nil
+}
+
+const attemptColumns = `id, delivery_id, attempt, response_code, latency_ms, error, replay, attempted_at`
+
+func scanAttempt(row rowScanner, a *DeliveryAttempt) error {
+	return row.Scan(&a.ID, &a.DeliveryID, &a.Attempt, &a.ResponseCode, &a.LatencyMS, &a.Error, &a.Replay, &a.AttemptedAt)
+}
+
+// CreateWebhook stores a new webhook
+func (r *WebhookRepository) CreateWebhook(ctx context.Context, w Webhook) (*Webhook, error) {
+	query := `
+		INSERT INTO webhooks (id, owner_id, url, events, calendar_id, secret)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + webhookColumns
+
+	var created Webhook
+	row := conn(ctx, r.db).QueryRowContext(ctx, query, w.ID, w.OwnerID, w.URL, pq.Array(w.Events), w.CalendarID, w.Secret)
+	if err := scanWebhook(row, &created); err != nil {
+		if isForeignKeyViolation(err, "webhooks_calendar_id_fkey") {
+			return nil, ErrCalendarNotFound
+		}
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return &created, nil
+}
+
+// GetWebhook returns a webhook by ID
+func (r *WebhookRepository) GetWebhook(ctx context.Context, id uuid.UUID) (*Webhook, error) {
+	var w Webhook
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1`
+	if err := scanWebhook(conn(ctx, r.db).QueryRowContext(ctx, query, id), &w); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return &w, nil
+}
+
+// ListWebhooks returns every webhook, oldest first
+func (r *WebhookRepository) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		var w Webhook
+		if err := scanWebhook(rows, &w); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+// RotateWebhookSecret makes secret the signing secret of a webhook. The replaced secret
+// keeps signing deliveries until previousExpiresAt.
+func (r *WebhookRepository) RotateWebhookSecret(ctx context.Context, id uuid.UUID, secret string, previousExpiresAt time.Time) (*Webhook, error) {
+	query := `
+		UPDATE webhooks
+		SET previous_secret = secret, previous_secret_expires_at = $3, secret = $2
+		WHERE id = $1
+		RETURNING ` + webhookColumns
+
+	var w Webhook
+	if err := scanWebhook(conn(ctx, r.db).QueryRowContext(ctx, query, id, secret, previousExpiresAt), &w); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("failed to rotate webhook secret: %w", err)
+	}
+	return &w, nil
+}
+
+// DeleteWebhook deletes a webhook with its deliveries
+func (r *WebhookRepository) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// EnqueueDeliveries creates a pending delivery of payload for every webhook subscribed
+// to eventType on calendarID, first due at dueAt
+func (r *WebhookRepository) EnqueueDeliveries(ctx context.Context, eventType string, eventID uuid.UUID, calendarID *uuid.UUID, payload json.RawMessage, dueAt time.Time) ([]WebhookDelivery, error) {
+	query := `
+		INSERT INTO webhook_deliveries (id, webhook_id, event_type, event_id, payload, next_attempt_at)
+		SELECT uuid_generate_v4(), w.id, $1, $2, $3, $5
+		FROM webhooks w
+		WHERE $1 = ANY(w.events) AND (w.calendar_id IS NULL OR w.calendar_id = $4)
+		RETURNING ` + deliveryColumns
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, eventType, eventID, []byte(payload), calendarID, dueAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue deliveries: %w", err)
+	}
+	return collectDeliveries(rows)
+}
+
+// ListDeliveries returns the latest deliveries of a webhook, newest first, with their
+// attempts. status filters them when set.
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, webhookID uuid.UUID, status string, limit int) ([]WebhookDelivery, error) {
+	query := `
+		SELECT ` + deliveryColumns + ` FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, id
+		LIMIT $3`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, webhookID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deliveries: %w", err)
+	}
+	deliveries, err := collectDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, r.loadAttempts(ctx, deliveries)
+}
+
+// GetDelivery returns a delivery of a webhook with its attempts
+func (r *WebhookRepository) GetDelivery(ctx context.Context, webhookID, id uuid.UUID) (*WebhookDelivery, error) {
+	var d WebhookDelivery
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE webhook_id = $1 AND id = $2`
+	if err := scanDelivery(conn(ctx, r.db).QueryRowContext(ctx, query, webhookID, id), &d); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDeliveryNotFound
+		}
+		return nil, fmt.Errorf("failed to get delivery: %w", err)
+	}
+	deliveries := []WebhookDelivery{d}
+	if err := r.loadAttempts(ctx, deliveries); err != nil {
+		return nil, err
+	}
+	return &deliveries[0], nil
+}
+
+// ClaimDueDeliveries returns up to limit pending deliveries due at now and holds them
+// back until now plus webhookLease. Claimed rows are locked with SKIP LOCKED, so
+// instances never claim the same delivery.
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries
+		SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at, id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + deliveryColumns
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, now, now.Add(webhookLease), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim deliveries: %w", err)
+	}
+	return collectDeliveries(rows)
+}
+
+// RecordAttempt stores an attempt of a delivery and moves the delivery to status, due
+// again at nextAttemptAt when it is still pending
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, a DeliveryAttempt, status string, nextAttemptAt *time.Time) error {
+	insert := `
+		INSERT INTO webhook_delivery_attempts (id, delivery_id, attempt, response_code, latency_ms, error, replay, attempted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	if _, err := conn(ctx, r.db).ExecContext(ctx, insert, a.ID, a.DeliveryID, a.Attempt, a.ResponseCode, a.LatencyMS, a.Error, a.Replay, a.AttemptedAt); err != nil {
+		if isForeignKeyViolation(err, "webhook_delivery_attempts_delivery_id_fkey") {
+			return ErrDeliveryNotFound
+		}
+		return fmt.Errorf("failed to record attempt: %w", err)
+	}
+	update := `UPDATE webhook_deliveries SET status = $2, attempt_count = $3, next_attempt_at = $4 WHERE id = $1`
+	if _, err := conn(ctx, r.db).ExecContext(ctx, update, a.DeliveryID, status, a.Attempt, nextAttemptAt); err != nil {
+		return fmt.Errorf("failed to update delivery: %w", err)
+	}
+	return nil
+}
+
+// loadAttempts fills in the attempts of deliveries, in the order they were made
+func (r *WebhookRepository) loadAttempts(ctx context.Context, deliveries []WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	index := map[uuid.UUID]int{}
+	ids := make([]string, len(deliveries))
+	for i, d := range deliveries {
+		index[d.ID] = i
+		ids[i] = d.ID.String()
+	}
+
+	query := `SELECT ` + attemptColumns + ` FROM webhook_delivery_attempts WHERE delivery_id = ANY($1::uuid[]) ORDER BY attempt, attempted_at`
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to query attempts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a DeliveryAttempt
+		if err := scanAttempt(rows, &a); err != nil {
+			return fmt.Errorf("failed to scan attempt: %w", err)
+		}
+		d := &deliveries[index[a.DeliveryID]]
+		d.Attempts = append(d.Attempts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating attempts: %w", err)
+	}
+	return nil
+}
+
+func collectDeliveries(rows *sql.Rows) ([]WebhookDelivery, error) {
+	defer rows.Close()
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := scanDelivery(rows, &d); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// WebhookDispatcher queues a delivery for every webhook subscribed to an event write
+// and POSTs it, logging each attempt. A failed delivery is retried with backoff by the
+// deliver_webhooks job.
+type WebhookDispatcher struct {
+	repo   WebhookRepositoryInterface
+	events EventRepositoryInterface
+	client *http.Client
+	now    func() time.Time
+	// deleting keeps the calendar of the events being deleted, read before the delete,
+	// to route their deletion
+	deleting sync.Map
+}
+
+// NewWebhookDispatcher stores deliveries in repo; events reads the calendar of deleted
+// events. Webhooks are only called on public addresses.
+func NewWebhookDispatcher(repo WebhookRepositoryInterface, events EventRepositoryInterface) *WebhookDispatcher {
+	return &WebhookDispatcher{repo: repo, events: events, client: PublicHTTPClient(webhookTimeout, nil), now: time.Now}
+}
+
+// NewWebhookDispatcherFromConfig also calls webhooks in cfg.WebhookAllowedNetworks
+func NewWebhookDispatcherFromConfig(repo WebhookRepositoryInterface, events EventRepositoryInterface, cfg Config) (*WebhookDispatcher, error) {
+	allowed, err := ParseNetworks(cfg.WebhookAllowedNetworks)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_ALLOWED_NETWORKS: %w", err)
+	}
+	d := NewWebhookDispatcher(repo, events)
+	d.SetAllowedNetworks(allowed)
+	return d, nil
+}
+
+// SetAllowedNetworks lets webhooks be called on the private addresses in allowed, for
+// receivers on the same network as the server
+func (d *WebhookDispatcher) SetAllowedNetworks(allowed []netip.Prefix) {
+	d.client = PublicHTTPClient(webhookTimeout, allowed)
+}
+
+// Register queues deliveries after every create, update that changed the event, and
+// delete
+func (d *WebhookDispatcher) Register(hooks *EventHooks) {
+	hooks.AfterCreate(func(ctx context.Context, event EventDB) {
+		d.dispatch(ctx, WebhookEventCreated, event.ID, event.CalendarID, webhookPayload{Event: payloadEvent(event)})
+	})
+	hooks.AfterChange(func(ctx context.Context, before *EventDB, after EventDB) {
+		var changes []FieldChange
+		if before != nil {
+			if changes = DiffEvents(*before, after); len(changes) == 0 {
+				return
+			}
+		}
+		d.dispatch(ctx, WebhookEventUpdated, after.ID, after.CalendarID, webhookPayload{Event: payloadEvent(after), Changes: changes})
+	})
+	hooks.BeforeDelete(func(ctx context.Context, id uuid.UUID) error {
+		event, err := d.events.GetEventByID(ctx, id)
+		if err == nil {
+			d.deleting.Store(id, event.CalendarID)
+		}
+		return nil
+	})
+	hooks.AfterDelete(func(ctx context.Context, id uuid.UUID) {
+		calendarID, _ := d.deleting.LoadAndDelete(id)
+		event := webhookEvent{ID: id}
+		event.CalendarID, _ = calendarID.(*uuid.UUID)
+		d.dispatch(ctx, WebhookEventDeleted, id, event.CalendarID, webhookPayload{Event: event})
+	})
+}
+
+// dispatch queues the deliveries of a write. They are sent right away in the
+// background, except inside a transaction, which may still roll back; the job sends
+// those. The write already succeeded, so a failure is only logged.
+func (d *WebhookDispatcher) dispatch(ctx context.Context, eventType string, eventID uuid.UUID, calendarID *uuid.UUID, payload webhookPayload) {
+	now := d.now()
+	payload.ID = uuid.New()
+	payload.Type = eventType
+	payload.OccurredAt = now.UTC()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error encoding %s webhook of event %s: %v", eventType, eventID, err)
+		return
+	}
+
+	_, inTx := ctx.Value(txKey{}).(*sql.Tx)
+	dueAt := now.Add(webhookLease)
+	if inTx {
+		dueAt = now
+	}
+	deliveries, err := d.repo.EnqueueDeliveries(ctx, eventType, eventID, calendarID, body, dueAt)
+	if err != nil {
+		log.Printf("Error queueing %s webhooks of event %s: %v", eventType, eventID, err)
+		return
+	}
+	if inTx || len(deliveries) == 0 {
+		return
+	}
+
+	sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), webhookNotifyTimeout)
+	go func() {
+		defer cancel()
+		d.deliverAll(sendCtx, deliveries)
+	}()
+}
+
+// deliverAll attempts each delivery once and returns how many succeeded
+func (d *WebhookDispatcher) deliverAll(ctx context.Context, deliveries []WebhookDelivery) int {
+	webhooks := map[uuid.UUID]*Webhook{}
+	sent := 0
+	for _, delivery := range deliveries {
+		hook, ok := webhooks[delivery.WebhookID]
+		if !ok {
+			var err error
+			if hook, err = d.repo.GetWebhook(ctx, delivery.WebhookID); err != nil {
+				log.Printf("Error getting webhook %s: %v", delivery.WebhookID, err)
+				continue
+			}
+			webhooks[delivery.WebhookID] = hook
+		}
+		attempt, err := d.Deliver(ctx, *hook, delivery, false)
+		if err != nil {
+			log.Printf("Error recording delivery %s: %v", delivery.ID, err)
+			continue
+		}
+		if attempt.Succeeded() {
+			sent++
+		}
+	}
+	return sent
+}
+
+// Deliver POSTs a delivery to its webhook and records the attempt. A failed automatic
+// attempt is retried with backoff until the delivery runs out of attempts. A replay is
+// made on request whatever the delivery's status, which it then sets to succeeded or
+// failed, ending automatic retries. The error reports a failure to record the attempt,
+// not of the endpoint.
+//
+// As in the REST Hooks pattern, an endpoint answering an automatic attempt with 410
+// Gone is unsubscribed: the webhook is deleted with its delivery log.
+func (d *WebhookDispatcher) Deliver(ctx context.Context, hook Webhook, delivery WebhookDelivery, replay bool) (*DeliveryAttempt, error) {
+	attempt := d.send(ctx, hook, delivery)
+	attempt.Attempt = delivery.AttemptCount + 1
+	attempt.Replay = replay
+
+	status := DeliveryFailed
+	var next *time.Time
+	switch {
+	case attempt.Succeeded():
+		status = DeliverySucceeded
+	case !replay && attempt.Gone():
+		if err := d.repo.DeleteWebhook(ctx, hook.ID); err != nil {
+			return nil, err
+		}
+		return &attempt, nil
+	case !replay && attempt.Attempt < maxWebhookAttempts:
+		status = DeliveryPending
+		at := attempt.AttemptedAt.Add(webhookBackoff[min(attempt.Attempt, len(webhookBackoff))-1])
+		next = &at
+	}
+	if err := d.repo.RecordAttempt(ctx, attempt, status, next); err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// send makes the request of one attempt
+func (d *WebhookDispatcher) send(ctx context.Context, hook Webhook, delivery WebhookDelivery) DeliveryAttempt {
+	attempt := DeliveryAttempt{ID: uuid.New(), DeliveryID: delivery.ID, AttemptedAt: d.now()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookDelivery, delivery.ID.String())
+	req.Header.Set(HeaderWebhookEvent, delivery.EventType)
+	req.Header.Set(HeaderWebhookSignature, SignWebhookPayload(delivery.Payload, hook.SigningSecrets(attempt.AttemptedAt), attempt.AttemptedAt))
+	SetRequestIDHeader(req)
+
+	start := time.Now()
+	resp, err := d.client.Do(req)
+	attempt.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = fmt.Sprintf("failed to call webhook: %v", err)
+		return attempt
+	}
+	resp.Body.Close()
+	code := resp.StatusCode
+	attempt.ResponseCode = &code
+	if !attempt.Succeeded() {
+		attempt.Error = fmt.Sprintf("webhook returned %s", resp.Status)
+	}
+	return attempt
+}
+
+// DeliverWebhooksJob sends every delivery that is due for a retry, or that was queued
+// inside a transaction
+func DeliverWebhooksJob(dispatcher *WebhookDispatcher) JobFunc {
+	return func(ctx context.Context, _ json.RawMessage) (string, error) {
+		due, err := dispatcher.repo.ClaimDueDeliveries(ctx, dispatcher.now(), webhookBatch)
+		if err != nil {
+			return "", err
+		}
+		sent := dispatcher.deliverAll(ctx, due)
+		output := fmt.Sprintf("sent %d webhook deliveries, %d failed", sent, len(due)-sent)
+		if sent < len(due) {
+			return output, fmt.Errorf("%d of %d webhook deliveries failed", len(due)-sent, len(due))
+		}
+		return output, nil
+	}
+}
+
+// payloadEvent is the part of event sent to webhooks
+func payloadEvent(event EventDB) webhookEvent {
+	start, end := event.StartTime.UTC(), event.EndTime.UTC()
+	return webhookEvent{
+		ID:         event.ID,
+		CalendarID: event.CalendarID,
+		Title:      event.Title,
+		StartTime:  &start,
+		EndTime:    &end,
+		Status:     event.Status,
+		Version:    event.Version,
+	}
+}
@@ -0,0 +1,304 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memWebhooks keeps webhooks and deliveries in memory
+type memWebhooks struct {
+	WebhookRepositoryInterface
+	mu         sync.Mutex
+	webhooks   []Webhook
+	deliveries map[uuid.UUID]*WebhookDelivery
+}
+
+func newMemWebhooks(hooks ...Webhook) *memWebhooks {
+	return &memWebhooks{webhooks: hooks, deliveries: map[uuid.UUID]*WebhookDelivery{}}
+}
+
+func (m *memWebhooks) GetWebhook(ctx context.Context, id uuid.UUID) (*Webhook, error) {
+	for _, w := range m.webhooks {
+		if w.ID == id {
+			return &w, nil
+		}
+	}
+	return nil, ErrWebhookNotFound
+}
+
+func (m *memWebhooks) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, w := range m.webhooks {
+		if w.ID == id {
+			m.webhooks = append(m.webhooks[:i], m.webhooks[i+1:]...)
+			for did, d := range m.deliveries {
+				if d.WebhookID == id {
+					delete(m.deliveries, did)
+				}
+			}
+			return nil
+		}
+	}
+	return ErrWebhookNotFound
+}
+
+func (m *memWebhooks) EnqueueDeliveries(ctx context.Context, eventType string, eventID uuid.UUID, calendarID *uuid.UUID, payload json.RawMessage, dueAt time.Time) ([]WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []WebhookDelivery{}
+	for _, w := range m.webhooks {
+		if !w.Subscribes(eventType) || (w.CalendarID != nil && !sameCalendar(w.CalendarID, calendarID)) {
+			continue
+		}
+		d := WebhookDelivery{ID: uuid.New(), WebhookID: w.ID, EventType: eventType, EventID: eventID, Payload: payload, Status: DeliveryPending, NextAttemptAt: &dueAt}
+		m.deliveries[d.ID] = &d
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+func (m *memWebhooks) ClaimDueDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []WebhookDelivery{}
+	for _, d := range m.deliveries {
+		if d.Status == DeliveryPending && !d.NextAttemptAt.After(now) {
+			out = append(out, *d)
+		}
+	}
+	return out, nil
+}
+
+func (m *memWebhooks) RecordAttempt(ctx context.Context, a DeliveryAttempt, status string, next *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d := m.deliveries[a.DeliveryID]
+	d.Attempts = append(d.Attempts, a)
+	d.AttemptCount, d.Status, d.NextAttemptAt = a.Attempt, status, next
+	return nil
+}
+
+func (m *memWebhooks) all() []WebhookDelivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []WebhookDelivery{}
+	for _, d := range m.deliveries {
+		out = append(out, *d)
+	}
+	return out
+}
+
+func TestValidateWebhook(t *testing.T) {
+	w := Webhook{URL: "https://example.com/hook", Events: []string{WebhookEventCreated, WebhookEventCreated}}
+	assert.Empty(t, ValidateWebhook(&w))
+	assert.Equal(t, []string{WebhookEventCreated}, w.Events)
+
+	assert.NotEmpty(t, ValidateWebhook(&Webhook{URL: "ftp://example.com", Events: []string{WebhookEventCreated}}))
+	assert.NotEmpty(t, ValidateWebhook(&Webhook{URL: "https://example.com"}))
+	assert.NotEmpty(t, ValidateWebhook(&Webhook{URL: "https://example.com", Events: []string{"event.moved"}}))
+}
+
+// testServerNetworks lets dispatchers call httptest servers, which listen on loopback
+var testServerNetworks = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+
+func TestWebhookDispatcher(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string][]byte{}
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := VerifyWebhookSignature(body, r.Header.Get(HeaderWebhookSignature), []string{"whsec_test"}, WebhookSignatureTolerance, time.Now()); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		received[r.Header.Get(HeaderWebhookEvent)] = body
+		mu.Unlock()
+	}))
+	defer endpoint.Close()
+
+	work, home := uuid.New(), uuid.New()
+	all := Webhook{ID: uuid.New(), URL: endpoint.URL, Events: WebhookEventTypes, Secret: "whsec_test"}
+	other := Webhook{ID: uuid.New(), URL: endpoint.URL, Events: WebhookEventTypes, CalendarID: &home, Secret: "whsec_test"}
+	repo := newMemWebhooks(all, other)
+	inner := &writeRecorder{}
+	hooks := NewEventHooks()
+	dispatcher := NewWebhookDispatcher(repo, inner)
+	dispatcher.SetAllowedNetworks(testServerNetworks)
+	dispatcher.Register(hooks)
+	events := NewHookedEventRepository(inner, hooks)
+	ctx := context.Background()
+
+	event := EventDB{ID: uuid.New(), CalendarID: &work, Title: "Standup", StartTime: time.Date(2025, 9, 29, 9, 0, 0, 0, time.UTC), EndTime: time.Date(2025, 9, 29, 9, 15, 0, 0, time.UTC)}
+	_, err := events.CreateEvent(ctx, event)
+	require.NoError(t, err)
+	_, err = events.UpdateEvent(ctx, event)
+	require.NoError(t, err)
+	event.StartTime = event.StartTime.Add(time.Hour)
+	_, err = events.UpdateEvent(ctx, event)
+	require.NoError(t, err)
+	require.NoError(t, events.DeleteEvent(ctx, event.ID))
+
+	// Only the webhook for every calendar gets the writes, and the no-op update is skipped
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 3
+	}, time.Second, 10*time.Millisecond)
+	deliveries := repo.all()
+	require.Len(t, deliveries, 3)
+	for _, d := range deliveries {
+		assert.Equal(t, all.ID, d.WebhookID)
+	}
+	require.Eventually(t, func() bool {
+		for _, d := range repo.all() {
+			if d.Status != DeliverySucceeded {
+				return false
+			}
+		}
+		return true
+	}, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	var updated webhookPayload
+	require.NoError(t, json.Unmarshal(received[WebhookEventUpdated], &updated))
+	assert.Equal(t, WebhookEventUpdated, updated.Type)
+	assert.Equal(t, []FieldChange{{Field: "start_time", Old: "2025-09-29T09:00:00Z", New: "2025-09-29T10:00:00Z"}}, updated.Changes)
+	var deleted webhookPayload
+	require.NoError(t, json.Unmarshal(received[WebhookEventDeleted], &deleted))
+	assert.Equal(t, webhookEvent{ID: event.ID, CalendarID: &work}, deleted.Event)
+}
+
+func TestWebhookDeliveryRetries(t *testing.T) {
+	status := http.StatusInternalServerError
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer endpoint.Close()
+
+	hook := Webhook{ID: uuid.New(), URL: endpoint.URL, Events: WebhookEventTypes}
+	repo := newMemWebhooks(hook)
+	d := NewWebhookDispatcher(repo, nil)
+	d.SetAllowedNetworks(testServerNetworks)
+	now := time.Date(2025, 9, 28, 12, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	queued, err := repo.EnqueueDeliveries(ctx, WebhookEventCreated, uuid.New(), nil, json.RawMessage(`{}`), now)
+	require.NoError(t, err)
+	require.Len(t, queued, 1)
+	id := queued[0].ID
+
+	// Failures are retried with backoff until the attempts run out
+	job := DeliverWebhooksJob(d)
+	for i, wait := range webhookBackoff {
+		_, err := job(ctx, nil)
+		require.Error(t, err)
+		delivery := repo.deliveries[id]
+		require.Equal(t, DeliveryPending, delivery.Status)
+		require.Equal(t, i+1, delivery.AttemptCount)
+		assert.Equal(t, now.Add(wait), *delivery.NextAttemptAt)
+		assert.Equal(t, http.StatusInternalServerError, *delivery.Attempts[i].ResponseCode)
+		now = now.Add(wait)
+	}
+	_, err = job(ctx, nil)
+	require.Error(t, err)
+	delivery := repo.deliveries[id]
+	assert.Equal(t, DeliveryFailed, delivery.Status)
+	assert.Nil(t, delivery.NextAttemptAt)
+	assert.Len(t, delivery.Attempts, maxWebhookAttempts)
+
+	// A replay once the endpoint is fixed succeeds
+	status = http.StatusNoContent
+	attempt, err := d.Deliver(ctx, hook, *delivery, true)
+	require.NoError(t, err)
+	assert.True(t, attempt.Replay)
+	assert.Equal(t, maxWebhookAttempts+1, attempt.Attempt)
+	assert.Equal(t, DeliverySucceeded, repo.deliveries[id].Status)
+}
+
+func TestWebhookSignature(t *testing.T) {
+	payload := []byte(`{"type": "event.created"}`)
+	now := time.Date(2025, 9, 29, 12, 0, 0, 0, time.UTC)
+	expires := now.Add(time.Hour)
+	hook := Webhook{Secret: "whsec_new", PreviousSecret: "whsec_old", PreviousSecretExpiresAt: &expires}
+
+	// During the rotation window deliveries verify with either secret
+	header := SignWebhookPayload(payload, hook.SigningSecrets(now), now)
+	assert.NoError(t, VerifyWebhookSignature(payload, header, []string{"whsec_new"}, WebhookSignatureTolerance, now))
+	assert.NoError(t, VerifyWebhookSignature(payload, header, []string{"whsec_old"}, WebhookSignatureTolerance, now))
+	assert.ErrorIs(t, VerifyWebhookSignature(payload, header, []string{"whsec_other"}, WebhookSignatureTolerance, now), ErrWebhookSignature)
+	assert.ErrorIs(t, VerifyWebhookSignature([]byte(`{}`), header, []string{"whsec_new"}, WebhookSignatureTolerance, now), ErrWebhookSignature)
+
+	// Old signatures are rejected, so captured deliveries cannot be replayed
+	assert.ErrorIs(t, VerifyWebhookSignature(payload, header, []string{"whsec_new"}, WebhookSignatureTolerance, now.Add(10*time.Minute)), ErrWebhookSignature)
+	assert.ErrorIs(t, VerifyWebhookSignature(payload, "v1=abc", []string{"whsec_new"}, WebhookSignatureTolerance, now), ErrWebhookSignature)
+
+	// After the window only the new secret signs
+	later := expires.Add(time.Second)
+	assert.Equal(t, []string{"whsec_new"}, hook.SigningSecrets(later))
+	header = SignWebhookPayload(payload, hook.SigningSecrets(later), later)
+	assert.ErrorIs(t, VerifyWebhookSignature(payload, header, []string{"whsec_old"}, WebhookSignatureTolerance, later), ErrWebhookSignature)
+}
+
+func TestWebhookGoneUnsubscribes(t *testing.T) {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer endpoint.Close()
+
+	hook := Webhook{ID: uuid.New(), URL: endpoint.URL, Events: WebhookEventTypes}
+	repo := newMemWebhooks(hook)
+	d := NewWebhookDispatcher(repo, nil)
+	d.SetAllowedNetworks(testServerNetworks)
+	ctx := context.Background()
+	queued, err := repo.EnqueueDeliveries(ctx, WebhookEventCreated, uuid.New(), nil, json.RawMessage(`{}`), time.Now())
+	require.NoError(t, err)
+
+	// As in REST Hooks, a 410 removes the subscription instead of retrying
+	attempt, err := d.Deliver(ctx, hook, queued[0], false)
+	require.NoError(t, err)
+	assert.True(t, attempt.Gone())
+	_, err = repo.GetWebhook(ctx, hook.ID)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+	assert.Empty(t, repo.all())
+}
+
+func TestWebhookSamples(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	var events []EventDB
+	for i := 0; i < 5; i++ {
+		start := now.Add(time.Duration(i) * time.Hour)
+		events = append(events, EventDB{ID: uuid.New(), Title: "Event", StartTime: start, EndTime: start.Add(time.Hour), UpdatedAt: start})
+	}
+
+	// The most recently updated events are sampled, newest first
+	samples := WebhookSamples(WebhookEventCreated, events, now)
+	require.Len(t, samples, WebhookSampleCount)
+	var first webhookPayload
+	require.NoError(t, json.Unmarshal(samples[0], &first))
+	assert.Equal(t, events[4].ID, first.ID)
+	assert.Equal(t, payloadEvent(events[4]), first.Event)
+
+	var deleted webhookPayload
+	require.NoError(t, json.Unmarshal(WebhookSamples(WebhookEventDeleted, events, now)[0], &deleted))
+	assert.Equal(t, webhookEvent{ID: events[4].ID}, deleted.Event)
+
+	// Without events an example stands in
+	samples = WebhookSamples(WebhookEventCreated, nil, now)
+	require.Len(t, samples, 1)
+	require.NoError(t, json.Unmarshal(samples[0], &first))
+	assert.Equal(t, "Example event", first.Event.Title)
+}
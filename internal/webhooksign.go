@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HeaderWebhookSignature carries the signatures of a delivery:
+// "t=<unix seconds>,v1=<hex>[,v1=<hex>]"
+const HeaderWebhookSignature = "X-Webhook-Signature"
+
+// WebhookSignatureTolerance is how old a delivery's timestamp may be when verified, so
+// a captured request cannot be replayed later
+const WebhookSignatureTolerance = 5 * time.Minute
+
+// Bounds of the overlap of the previous secret after a rotation, in minutes
+const (
+	DefaultSecretGraceMinutes = 24 * 60
+	MaxSecretGraceMinutes     = 7 * 24 * 60
+)
+
+// webhookSecretPrefix tells webhook secrets apart from API tokens
+const webhookSecretPrefix = "whsec_"
+
+// GenerateWebhookSecret returns a new random signing secret
+func GenerateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// SigningSecrets returns the secrets deliveries are signed with at now: the current
+// one, then the previous one until it expires
+func (w Webhook) SigningSecrets(now time.Time) []string {
+	secrets := []string{w.Secret}
+	if w.PreviousSecret != "" && w.PreviousSecretExpiresAt != nil && now.Before(*w.PreviousSecretExpiresAt) {
+		secrets = append(secrets, w.PreviousSecret)
+	}
+	return secrets
+}
+
+// SignWebhookPayload returns the signature header of payload sent at now, with one v1
+// signature per secret
+func SignWebhookPayload(payload []byte, secrets []string, now time.Time) string {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	parts := []string{"t=" + timestamp}
+	for _, secret := range secrets {
+		parts = append(parts, "v1="+webhookSignature(secret, timestamp, payload))
+	}
+	return strings.Join(parts, ",")
+}
+
+// VerifyWebhookSignature checks the signature header of a delivery received at now:
+// its timestamp must be within tolerance and one of its v1 signatures must match one
+// of secrets, or it returns ErrWebhookSignature. Receivers pass both secrets while
+// rotating.
+func VerifyWebhookSignature(payload []byte, header string, secrets []string, tolerance time.Duration, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrWebhookSignature
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+		return ErrWebhookSignature
+	}
+
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		expected := webhookSignature(secret, timestamp, payload)
+		for _, sig := range signatures {
+			if hmac.Equal([]byte(expected), []byte(sig)) {
+				return nil
+			}
+		}
+	}
+	return ErrWebhookSignature
+}
+
+// webhookSignature is the hex HMAC-SHA256 of "timestamp.payload"
+func webhookSignature(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
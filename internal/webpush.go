@@ -0,0 +1,224 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ErrPushSubscriptionGone is returned when the push service no longer knows a
+// subscription, which happens when the user revokes the permission or the browser
+// drops it. The subscription should be deleted.
+var ErrPushSubscriptionGone = errors.New("push subscription is gone")
+
+// Web Push message encryption (RFC 8291) with a single aes128gcm record (RFC 8188)
+const (
+	webPushRecordSize = 4096
+	webPushSaltSize   = 16
+	webPushHeaderSize = webPushSaltSize + 4 + 1 + 65
+	// MaxWebPushPayload is the largest payload that fits in one record of the
+	// 4096 bytes push services accept
+	MaxWebPushPayload = webPushRecordSize - webPushHeaderSize - 16 - 1
+)
+
+// vapidTokenTTL is how long the VAPID token of a request is valid; push services
+// reject tokens valid for more than 24 hours
+const vapidTokenTTL = 12 * time.Hour
+
+// WebPush sends messages to browser push subscriptions, identifying the server to
+// push services with a VAPID key pair (RFC 8292)
+type WebPush struct {
+	publicKey  []byte
+	privateKey *ecdsa.PrivateKey
+	subject    string
+	Client     *http.Client
+}
+
+// NewWebPush parses a VAPID key pair, both base64url as printed by the vapid-keys
+// command. subject is a mailto: or https: contact for the push services. Browsers
+// choose the endpoints, so messages are only sent to public addresses.
+func NewWebPush(publicKey, privateKey, subject string) (*WebPush, error) {
+	d, err := base64.RawURLEncoding.DecodeString(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("VAPID private key is not valid base64url: %w", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	pub := key.PublicKey().Bytes()
+	if publicKey != base64.RawURLEncoding.EncodeToString(pub) {
+		return nil, errors.New("VAPID public key does not match the private key")
+	}
+	if u, err := url.Parse(subject); err != nil || (u.Scheme != "mailto" && u.Scheme != "https") {
+		return nil, errors.New("VAPID subject must be a mailto: or https: URL")
+	}
+
+	return &WebPush{
+		publicKey: pub,
+		privateKey: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(pub[1:33]),
+				Y:     new(big.Int).SetBytes(pub[33:]),
+			},
+			D: new(big.Int).SetBytes(d),
+		},
+		subject: subject,
+		Client:  PublicHTTPClient(10*time.Second, nil),
+	}, nil
+}
+
+// NewWebPushFromConfig returns the Web Push sender configured by the VAPID settings,
+// or nil when no VAPID key pair is set
+func NewWebPushFromConfig(cfg Config) (*WebPush, error) {
+	if cfg.VAPIDPrivateKey == "" {
+		return nil, nil
+	}
+	return NewWebPush(cfg.VAPIDPublicKey, cfg.VAPIDPrivateKey, cfg.VAPIDSubject)
+}
+
+// GenerateVAPIDKeys returns a new base64url VAPID key pair
+func GenerateVAPIDKeys() (publicKey, privateKey string, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		base64.RawURLEncoding.EncodeToString(key.Bytes()), nil
+}
+
+// PublicKey is the base64url key browsers pass as applicationServerKey when subscribing
+func (w *WebPush) PublicKey() string {
+	return base64.RawURLEncoding.EncodeToString(w.publicKey)
+}
+
+// Send encrypts payload for the subscription and posts it to its push service, which
+// keeps it for up to ttl while the browser is offline
+func (w *WebPush) Send(ctx context.Context, sub PushSubscription, payload []byte, ttl time.Duration) error {
+	uaPublic, auth, err := sub.Keys.decode()
+	if err != nil {
+		return err
+	}
+	body, err := encryptWebPush(payload, uaPublic, auth)
+	if err != nil {
+		return err
+	}
+	endpoint, err := url.Parse(sub.Endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid push endpoint: %w", err)
+	}
+	token, err := w.vapidToken(endpoint.Scheme+"://"+endpoint.Host, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", fmt.Sprint(int(ttl.Seconds())))
+	req.Header.Set("Urgency", "high")
+	req.Header.Set("Authorization", "vapid t="+token+", k="+w.PublicKey())
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("push service unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrPushSubscriptionGone
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("push service returned %s", resp.Status)
+	}
+	return nil
+}
+
+// vapidToken is the ES256 JWT identifying the server to the push service at audience
+func (w *WebPush) vapidToken(audience string, now time.Time) (string, error) {
+	return signJWT(
+		map[string]any{"typ": "JWT", "alg": "ES256"},
+		map[string]any{"aud": audience, "exp": now.Add(vapidTokenTTL).Unix(), "sub": w.subject},
+		w.privateKey,
+	)
+}
+
+// encryptWebPush encrypts payload for a browser with its P-256 public key and auth
+// secret (RFC 8291), as one aes128gcm record keyed with a fresh ephemeral key
+func encryptWebPush(payload, uaPublic, auth []byte) ([]byte, error) {
+	if len(payload) > MaxWebPushPayload {
+		return nil, fmt.Errorf("push payload is %d bytes, the maximum is %d", len(payload), MaxWebPushPayload)
+	}
+	uaKey, err := ecdh.P256().NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subscription key: %w", err)
+	}
+	asKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := asKey.ECDH(uaKey)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, webPushSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	asPublic := asKey.PublicKey().Bytes()
+
+	keyInfo := append(append([]byte("WebPush: info\x00"), uaPublic...), asPublic...)
+	ikm := hkdf(auth, secret, keyInfo, 32)
+	cek := hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header: salt, record size, key ID length and the ephemeral public key as key ID
+	out := make([]byte, 0, webPushHeaderSize+len(payload)+1+gcm.Overhead())
+	out = append(out, salt...)
+	out = binary.BigEndian.AppendUint32(out, webPushRecordSize)
+	out = append(out, byte(len(asPublic)))
+	out = append(out, asPublic...)
+	// 0x02 delimits the last (and only) record
+	plaintext := append(payload[:len(payload):len(payload)], 0x02)
+	return gcm.Seal(out, nonce, plaintext, nil), nil
+}
+
+// hkdf derives length (at most 32) bytes from ikm with HKDF-SHA256 (RFC 5869)
+func hkdf(salt, ikm, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(ikm)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write(info)
+	expand.Write([]byte{1})
+	return expand.Sum(nil)[:length]
+}
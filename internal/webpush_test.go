@@ -0,0 +1,216 @@
+package internal
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// browser is the receiving side of Web Push, holding a subscription's private key
+type browser struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+func newBrowser(t *testing.T) *browser {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	return &browser{key: key, auth: auth}
+}
+
+func (b *browser) keys() PushKeys {
+	return PushKeys{
+		P256dh: base64.RawURLEncoding.EncodeToString(b.key.PublicKey().Bytes()),
+		Auth:   base64.RawURLEncoding.EncodeToString(b.auth),
+	}
+}
+
+// decrypt reverses encryptWebPush as a browser would
+func (b *browser) decrypt(t *testing.T, body []byte) []byte {
+	salt := body[:16]
+	assert.Equal(t, uint32(webPushRecordSize), binary.BigEndian.Uint32(body[16:20]))
+	idLen := int(body[20])
+	asPublic := body[21 : 21+idLen]
+
+	asKey, err := ecdh.P256().NewPublicKey(asPublic)
+	require.NoError(t, err)
+	secret, err := b.key.ECDH(asKey)
+	require.NoError(t, err)
+
+	keyInfo := append(append([]byte("WebPush: info\x00"), b.key.PublicKey().Bytes()...), asPublic...)
+	ikm := hkdf(b.auth, secret, keyInfo, 32)
+	block, err := aes.NewCipher(hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16))
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+
+	plaintext, err := gcm.Open(nil, hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12), body[21+idLen:], nil)
+	require.NoError(t, err)
+	require.Equal(t, byte(0x02), plaintext[len(plaintext)-1])
+	return plaintext[:len(plaintext)-1]
+}
+
+func newTestWebPush(t *testing.T) *WebPush {
+	public, private, err := GenerateVAPIDKeys()
+	require.NoError(t, err)
+	wp, err := NewWebPush(public, private, "mailto:ops@example.com")
+	require.NoError(t, err)
+	return wp
+}
+
+func TestNewWebPush(t *testing.T) {
+	public, private, err := GenerateVAPIDKeys()
+	require.NoError(t, err)
+	other, _, _ := GenerateVAPIDKeys()
+
+	_, err = NewWebPush(other, private, "mailto:ops@example.com")
+	assert.EqualError(t, err, "VAPID public key does not match the private key")
+	_, err = NewWebPush(public, private, "ops@example.com")
+	assert.EqualError(t, err, "VAPID subject must be a mailto: or https: URL")
+	_, err = NewWebPush(public, "not a key", "mailto:ops@example.com")
+	assert.Error(t, err)
+
+	wp, err := NewWebPushFromConfig(Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, wp)
+}
+
+func TestEncryptWebPush(t *testing.T) {
+	b := newBrowser(t)
+	public, auth, err := b.keys().decode()
+	require.NoError(t, err)
+
+	payload := []byte(`{"title":"Reminder: Standup"}`)
+	body, err := encryptWebPush(payload, public, auth)
+	require.NoError(t, err)
+	assert.Equal(t, payload, b.decrypt(t, body))
+
+	_, err = encryptWebPush(make([]byte, MaxWebPushPayload+1), public, auth)
+	assert.Error(t, err)
+}
+
+func TestVAPIDToken(t *testing.T) {
+	wp := newTestWebPush(t)
+	now := time.Unix(1757930400, 0)
+	token, err := wp.vapidToken("https://push.example.com", now)
+	require.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+	var claims map[string]any
+	raw, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, json.Unmarshal(raw, &claims))
+	assert.Equal(t, "https://push.example.com", claims["aud"])
+	assert.Equal(t, "mailto:ops@example.com", claims["sub"])
+	assert.Equal(t, float64(now.Add(vapidTokenTTL).Unix()), claims["exp"])
+
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	require.Len(t, sig, 64)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	assert.True(t, ecdsa.Verify(&wp.privateKey.PublicKey, digest[:], r, s))
+}
+
+func TestPushKeysValidate(t *testing.T) {
+	valid := newBrowser(t).keys()
+	assert.Equal(t, "", valid.Validate())
+	assert.Equal(t, "keys.p256dh must be a base64url P-256 public key", PushKeys{P256dh: "abc", Auth: valid.Auth}.Validate())
+	assert.Equal(t, "keys.auth must be a 16-byte base64url secret", PushKeys{P256dh: valid.P256dh, Auth: "abc"}.Validate())
+}
+
+// fakePushSubscriptions keeps subscriptions in memory
+type fakePushSubscriptions struct {
+	PushSubscriptionRepositoryInterface
+	subs []PushSubscription
+}
+
+func (f *fakePushSubscriptions) ListPushSubscriptions(ctx context.Context, userID string) ([]PushSubscription, error) {
+	subs := []PushSubscription{}
+	for _, s := range f.subs {
+		if s.UserID == userID {
+			subs = append(subs, s)
+		}
+	}
+	return subs, nil
+}
+
+func (f *fakePushSubscriptions) DeletePushEndpoint(ctx context.Context, endpoint string) error {
+	kept := f.subs[:0]
+	for _, s := range f.subs {
+		if s.Endpoint != endpoint {
+			kept = append(kept, s)
+		}
+	}
+	f.subs = kept
+	return nil
+}
+
+func TestPushReminderSender(t *testing.T) {
+	b := newBrowser(t)
+	var received []byte
+	var auth string
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		received, _ = io.ReadAll(r.Body)
+		auth = r.Header.Get("Authorization")
+		assert.Equal(t, "aes128gcm", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "3600", r.Header.Get("TTL"))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer service.Close()
+
+	subs := &fakePushSubscriptions{subs: []PushSubscription{
+		{ID: uuid.New(), UserID: "alice", Endpoint: service.URL + "/gone", Keys: b.keys()},
+		{ID: uuid.New(), UserID: "alice", Endpoint: service.URL + "/ok", Keys: b.keys()},
+	}}
+	wp := newTestWebPush(t)
+	sender := &PushReminderSender{Push: NewPushNotifier(subs, wp, nil, nil)}
+
+	// Endpoints on private addresses are refused
+	event := EventDB{ID: uuid.New(), Title: "Standup", StartTime: time.Date(2025, 9, 15, 9, 0, 0, 0, time.UTC)}
+	err := sender.SendReminder(context.Background(), Reminder{OwnerID: "alice", Language: "en"}, event)
+	assert.ErrorIs(t, err, ErrPrivateDestination)
+	assert.Nil(t, received)
+	require.Len(t, subs.subs, 2)
+
+	wp.Client = PublicHTTPClient(time.Second, testServerNetworks)
+	require.NoError(t, sender.SendReminder(context.Background(), Reminder{OwnerID: "alice", Language: "en"}, event))
+
+	var msg PushMessage
+	require.NoError(t, json.Unmarshal(b.decrypt(t, received), &msg))
+	assert.Equal(t, "Reminder: Standup", msg.Title)
+	assert.Equal(t, event.ID, *msg.EventID)
+	assert.True(t, strings.HasPrefix(auth, "vapid t="))
+	assert.True(t, strings.HasSuffix(auth, ", k="+wp.PublicKey()))
+
+	// The gone browser was unregistered; bob has no browser at all
+	require.Len(t, subs.subs, 1)
+	assert.Equal(t, service.URL+"/ok", subs.subs[0].Endpoint)
+	err = sender.SendReminder(context.Background(), Reminder{OwnerID: "bob"}, event)
+	assert.ErrorIs(t, err, ErrNoPushSubscriptions)
+
+	assert.Nil(t, NewPushNotifier(subs, nil, nil, nil))
+}
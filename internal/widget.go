@@ -0,0 +1,193 @@
+package internal
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Views of the embeddable calendar widget
+const (
+	WidgetViewMonth  = "month"
+	WidgetViewAgenda = "agenda"
+)
+
+// widgetAgendaLimit is how many upcoming events the agenda view lists
+const widgetAgendaLimit = 20
+
+// WidgetOptions select what the calendar widget shows and how it links
+type WidgetOptions struct {
+	// View is WidgetViewMonth or WidgetViewAgenda
+	View string
+	// Month is any time in the month shown by the month view
+	Month    time.Time
+	Location *time.Location
+	Lang     string
+	Now      time.Time
+	// MonthURL links the month view of another month
+	MonthURL func(month time.Time) string
+	// EventURL links an event's page, or returns "" to leave events unlinked
+	EventURL func(e EventDB) string
+}
+
+type widgetEvent struct {
+	Time     string
+	Title    string
+	Location string
+	URL      string
+}
+
+type widgetDay struct {
+	Day     int
+	InMonth bool
+	Today   bool
+	Events  []widgetEvent
+}
+
+type widgetAgendaDay struct {
+	Day    string
+	Events []widgetEvent
+}
+
+type widgetData struct {
+	Lang      string
+	Title     string
+	Heading   string
+	Month     bool
+	PrevURL   string
+	PrevLabel string
+	NextURL   string
+	NextLabel string
+	Weekdays  []string
+	Weeks     [][]widgetDay
+	Agenda    []widgetAgendaDay
+	Empty     string
+}
+
+var widgetHTML = htmltemplate.Must(htmltemplate.New("widget").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body{font-family:sans-serif;font-size:14px;margin:0;padding:8px;color:#222}
+header{display:flex;align-items:center;justify-content:space-between;margin-bottom:8px}
+header a{text-decoration:none;padding:0 8px;font-size:18px}
+table{width:100%;border-collapse:collapse;table-layout:fixed}
+th{font-weight:normal;color:#777;padding:4px}
+td{vertical-align:top;border:1px solid #eee;height:64px;padding:2px 4px;overflow:hidden}
+td.out{color:#bbb}
+td.today .day{font-weight:bold;color:#1a73e8}
+.ev{font-size:12px;white-space:nowrap;overflow:hidden;text-overflow:ellipsis}
+.ev a{color:inherit}
+h3{font-size:14px;margin:12px 0 4px;color:#555}
+ul{list-style:none;margin:0;padding:0}
+li{padding:2px 0}
+.loc{color:#777}
+</style>
+</head>
+<body>
+<header>{{if .Month}}<a href="{{.PrevURL}}" aria-label="{{.PrevLabel}}">&lsaquo;</a>{{end}}<strong>{{.Heading}}</strong>{{if .Month}}<a href="{{.NextURL}}" aria-label="{{.NextLabel}}">&rsaquo;</a>{{end}}</header>
+{{if .Month}}<table>
+<tr>{{range .Weekdays}}<th>{{.}}</th>{{end}}</tr>
+{{range .Weeks}}<tr>{{range .}}<td class="{{if not .InMonth}}out{{end}}{{if .Today}} today{{end}}"><div class="day">{{.Day}}</div>{{range .Events}}<div class="ev" title="{{.Title}}">{{.Time}} {{if .URL}}<a href="{{.URL}}" target="_blank" rel="noopener">{{.Title}}</a>{{else}}{{.Title}}{{end}}</div>{{end}}</td>{{end}}</tr>
+{{end}}</table>
+{{else}}{{range .Agenda}}<h3>{{.Day}}</h3>
+<ul>{{range .Events}}<li>{{.Time}} {{if .URL}}<a href="{{.URL}}" target="_blank" rel="noopener">{{.Title}}</a>{{else}}{{.Title}}{{end}}{{if .Location}} <span class="loc">{{.Location}}</span>{{end}}</li>{{end}}</ul>
+{{else}}<p>{{.Empty}}</p>
+{{end}}{{end}}<script>
+(function () {
+  function resize() {
+    parent.postMessage({type: "calendar-widget-height", height: document.documentElement.scrollHeight}, "*");
+  }
+  addEventListener("load", resize);
+  addEventListener("resize", resize);
+})();
+</script>
+</body>
+</html>
+`))
+
+// RenderCalendarWidget writes the embeddable HTML view of a calendar's published events
+func RenderCalendarWidget(w io.Writer, c Calendar, events []EventDB, o WidgetOptions) error {
+	names, ok := dateFormats[o.Lang]
+	if !ok {
+		names = dateFormats[DefaultLanguage]
+	}
+	published := make([]EventDB, 0, len(events))
+	for _, e := range events {
+		if e.Published() {
+			published = append(published, e)
+		}
+	}
+	sort.SliceStable(published, func(i, j int) bool { return published[i].StartTime.Before(published[j].StartTime) })
+
+	item := func(e EventDB) widgetEvent {
+		we := widgetEvent{Time: e.StartTime.In(o.Location).Format("15:04"), Title: e.Title}
+		if e.Location != nil {
+			we.Location = *e.Location
+		}
+		if o.EventURL != nil {
+			we.URL = o.EventURL(e)
+		}
+		return we
+	}
+
+	data := widgetData{Lang: o.Lang, Title: c.Name, Month: o.View == WidgetViewMonth}
+	if data.Month {
+		first := time.Date(o.Month.Year(), o.Month.Month(), 1, 0, 0, 0, 0, o.Location)
+		data.Heading = names.months[first.Month()-1] + " " + strconv.Itoa(first.Year())
+		data.PrevURL = o.MonthURL(first.AddDate(0, -1, 0))
+		data.NextURL = o.MonthURL(first.AddDate(0, 1, 0))
+		data.PrevLabel = Translate(o.Lang, "Previous month")
+		data.NextLabel = Translate(o.Lang, "Next month")
+		for i := 1; i <= 7; i++ {
+			data.Weekdays = append(data.Weekdays, string([]rune(names.weekdays[i%7])[:2]))
+		}
+
+		// Weeks start on Monday and cover the whole month
+		byDay := map[string][]widgetEvent{}
+		for _, e := range published {
+			day := e.StartTime.In(o.Location).Format(time.DateOnly)
+			byDay[day] = append(byDay[day], item(e))
+		}
+		today := o.Now.In(o.Location).Format(time.DateOnly)
+		day := first.AddDate(0, 0, -((int(first.Weekday()) + 6) % 7))
+		for day.Before(first.AddDate(0, 1, 0)) {
+			week := make([]widgetDay, 7)
+			for i := range week {
+				key := day.Format(time.DateOnly)
+				week[i] = widgetDay{Day: day.Day(), InMonth: day.Month() == first.Month(), Today: key == today, Events: byDay[key]}
+				day = day.AddDate(0, 0, 1)
+			}
+			data.Weeks = append(data.Weeks, week)
+		}
+	} else {
+		data.Heading = c.Name
+		data.Empty = Translate(o.Lang, "No upcoming events.")
+		listed := 0
+		for _, e := range published {
+			if !e.EndTime.After(o.Now) {
+				continue
+			}
+			if listed == widgetAgendaLimit {
+				break
+			}
+			day := FormatDay(o.Lang, e.StartTime.In(o.Location))
+			if n := len(data.Agenda); n == 0 || data.Agenda[n-1].Day != day {
+				data.Agenda = append(data.Agenda, widgetAgendaDay{Day: day})
+			}
+			data.Agenda[len(data.Agenda)-1].Events = append(data.Agenda[len(data.Agenda)-1].Events, item(e))
+			listed++
+		}
+	}
+
+	if err := widgetHTML.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to render calendar widget: %w", err)
+	}
+	return nil
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"strings"
 	"taller_challenge/api"
 	"taller_challenge/internal"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,19 +23,545 @@ func main() {
 		log.Println("Make sure to set DATABASE_URL environment variable")
 	}
 
-	// Connect to PostgreSQL database
-	app := internal.ConnectionDB()
+	// Get server settings from environment variables
+	cfg := internal.LoadConfig()
+
+	// Credentials, email addresses and user content never reach the logs, unless
+	// payloads are explicitly logged in a debug environment
+	log.SetOutput(internal.NewLogScrubber(os.Stderr, cfg.FullPayloadLogging()))
+	if cfg.LogPayloads && !cfg.DebugEnvironment() {
+		log.Printf("Warning: LOG_PAYLOADS is ignored in the %s environment", cfg.Environment)
+	}
+
+	// Secrets may come from Vault or AWS Secrets Manager instead of the environment
+	provider, err := internal.NewSecretsProvider(cfg)
+	if err != nil {
+		log.Fatalf("Invalid secrets configuration: %v", err)
+	}
+	fetchCtx, cancelFetch := context.WithTimeout(context.Background(), 30*time.Second)
+	secrets, err := internal.LoadSecrets(fetchCtx, provider)
+	cancelFetch()
+	if err != nil {
+		log.Fatalf("Failed to load secrets: %v", err)
+	}
+	secrets.Apply(&cfg)
+	if cfg.SecretsProvider != "env" {
+		go secrets.Watch(context.Background(), cfg.SecretsRefreshInterval)
+	}
+
+	// serve is the default command; serve --mock runs the API against generated events
+	// in memory, without PostgreSQL, for frontend development
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		opts := flag.NewFlagSet("serve", flag.ExitOnError)
+		mock := opts.Bool("mock", false, "serve generated events from memory instead of PostgreSQL")
+		seed := opts.Int64("seed", 1, "seed of the generated events")
+		anchor := opts.String("anchor", "", "date (YYYY-MM-DD) the generated events surround; defaults to today")
+		latency := opts.String("latency", "", "latency added to every request, such as 200ms or 100ms-1s")
+		opts.Parse(os.Args[2:])
+		if *mock {
+			serveMock(cfg, *seed, *anchor, *latency)
+			return
+		}
+	}
+
+	// replay re-applies a replay log to a fresh database, without reaching the outside
+	// world: event changes are not pushed, POSTed to webhooks or purged from the CDN
+	replaying := len(os.Args) > 1 && os.Args[1] == "replay"
+
+	// Field encryption is optional; a bad key must stop startup rather than write plaintext
+	var cipher *internal.FieldCipher
+	if cfg.EncryptionKeys != "" {
+		cipher, err = internal.NewFieldCipher(cfg.EncryptionKeys)
+		if err != nil {
+			log.Fatalf("Invalid ENCRYPTION_KEYS: %v", err)
+		}
+	}
+
+	// Connect to PostgreSQL database, waiting for it to come up
+	connectOpts := internal.ConnectOptions{RetryFor: cfg.DBConnectTimeout, Lazy: cfg.DBLazyConnect}
+	app := internal.ConnectionDB(func() string {
+		if dsn := secrets.Get("DATABASE_URL"); dsn != "" {
+			return dsn
+		}
+		return cfg.DatabaseURL
+	}, connectOpts)
 	defer app.DB.Close()
 
-	// Create events repository
-	eventRepo := internal.NewEventRepository(app.DB)
+	// Fail fast on a database the migrations have not caught up with, listing everything
+	// missing, rather than at the first query to touch it. A database connected lazily is
+	// checked once it appears.
+	if cfg.SchemaCheck {
+		checkSchema := func() {
+			checkCtx, cancelCheck := context.WithTimeout(context.Background(), 10*time.Second)
+			err := internal.CheckSchema(checkCtx, app.DB)
+			cancelCheck()
+			if err != nil {
+				log.Fatalf("Schema check failed: %v", err)
+			}
+		}
+		select {
+		case <-app.Connected:
+			checkSchema()
+		default:
+			go func() {
+				<-app.Connected
+				checkSchema()
+			}()
+		}
+	}
+
+	// Create repositories. Event repository calls are timed and classified for /metrics
+	metrics := internal.NewMetrics()
+	metrics.SetLabeler(internal.NewMetricLabeler(cfg.MetricsTenantBuckets, cfg.MetricsTenantAllowlist))
+	statsd, err := internal.NewStatsDMetrics(cfg)
+	if err != nil {
+		log.Fatalf("Error configuring the metrics sink: %v", err)
+	}
+	statsdDone := make(chan struct{})
+	statsdCtx, stopStatsD := context.WithCancel(context.Background())
+	if statsd != nil {
+		statsd.Register(metrics)
+		go func() {
+			statsd.Run(statsdCtx)
+			close(statsdDone)
+		}()
+		log.Printf("Pushing metrics to %s at %s", cfg.MetricsSink, cfg.StatsDAddr)
+	} else {
+		close(statsdDone)
+	}
+	eventRepo := internal.NewEventRepository(app.DB, cipher)
+
+	// Fault injection for resilience testing, never in production. Faults are injected
+	// below the instrumentation, so they show up in the metrics like real failures.
+	chaos, err := internal.NewChaos(cfg)
+	if err != nil {
+		log.Fatalf("Invalid CHAOS_RULES: %v", err)
+	}
+	if chaos != nil && !cfg.DebugEnvironment() {
+		log.Printf("Warning: CHAOS_RULES is ignored in the %s environment", cfg.Environment)
+		chaos = nil
+	}
+	var baseEvents internal.EventRepositoryInterface = eventRepo
+	if chaos != nil {
+		log.Printf("Warning: injecting faults into requests and event repository calls: %s", cfg.ChaosRules)
+		baseEvents = internal.NewChaosEventRepository(eventRepo, chaos)
+	}
+	instrumentedEvents := internal.NewInstrumentedEventRepository(baseEvents, metrics, cfg.TraceRepository)
+
+	// Push notifications reach browsers through Web Push and mobile apps through FCM and
+	// APNs, each when configured
+	reminderRepo := internal.NewReminderRepository(app.DB)
+	pushRepo := internal.NewPushSubscriptionRepository(app.DB)
+	deviceRepo := internal.NewDeviceTokenRepository(app.DB)
+	webPush, err := internal.NewWebPushFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("Invalid VAPID configuration: %v", err)
+	}
+	pushProviders, err := internal.NewPushProviders(cfg)
+	if err != nil {
+		log.Fatalf("Invalid push provider configuration: %v", err)
+	}
+	push := internal.NewPushNotifier(pushRepo, webPush, deviceRepo, pushProviders)
 
-	// Get server port from environment variables
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080" // Default port
+	// Business rules of compiled-in plugins, the write constraints and the admin-defined
+	// policy rules run around API writes and imports, after the check that the caller may
+	// write the event's calendar. Writes are recorded in the activity feed, pushed to the
+	// users with reminders on the event and POSTed to the webhooks subscribed to it, and
+	// new events get the default reminders their creator prefers; restores bypass the
+	// hooks so a backup always comes back as it was taken
+	calendarRepo := internal.NewCalendarRepository(app.DB)
+	orgRepo := internal.NewOrganizationRepository(app.DB)
+	delegateRepo := internal.NewDelegateRepository(app.DB)
+	hooks := internal.NewEventHooks()
+	internal.NewCalendarAccess(calendarRepo, orgRepo, delegateRepo, instrumentedEvents).Register(hooks)
+	if err := internal.LoadPlugins(hooks, cfg.Plugins); err != nil {
+		log.Fatalf("Invalid PLUGINS: %v", err)
+	}
+	policyRepo := internal.NewPolicyRepository(app.DB)
+	policies, err := internal.NewPolicyEngine(policyRepo)
+	if err != nil {
+		log.Fatalf("Failed to create policy engine: %v", err)
+	}
+	// The constraint pipeline reports every rule a write breaks; policy rules are checked
+	// on their own unless they are one of its constraints
+	constraints, err := internal.NewConstraintPipelineFromConfig(cfg, instrumentedEvents, policies)
+	if err != nil {
+		log.Fatalf("Invalid WRITE_CONSTRAINTS: %v", err)
+	}
+	if constraints != nil {
+		constraints.Register(hooks)
+	}
+	if !constraints.Has(internal.ConstraintPolicy) {
+		policies.Register(hooks)
+	}
+	activityRepo := internal.NewActivityRepository(app.DB)
+	internal.NewActivityLog(activityRepo).Register(hooks)
+	if changes := internal.NewEventChangeNotifier(reminderRepo, push); changes != nil && !replaying {
+		changes.Register(hooks)
+	}
+	preferencesRepo := internal.NewPreferencesRepository(app.DB)
+	if defaults := internal.NewDefaultReminders(preferencesRepo, reminderRepo, push); defaults != nil {
+		defaults.Register(hooks)
+	}
+	webhookRepo := internal.NewWebhookRepository(app.DB)
+	webhooks, err := internal.NewWebhookDispatcherFromConfig(webhookRepo, instrumentedEvents, cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up webhooks: %v", err)
+	}
+	if !replaying {
+		webhooks.Register(hooks)
+	}
+	purger, err := internal.NewCachePurger(cfg)
+	if err != nil {
+		log.Fatalf("Error configuring CDN purges: %v", err)
+	}
+	if purger != nil && !replaying {
+		if cfg.PublicURL == "" {
+			log.Fatal("CDN_PROVIDER needs PUBLIC_URL, the host the CDN serves")
+		}
+		internal.NewCDNPurgeNotifier(purger, instrumentedEvents, calendarRepo, internal.NewFeedSigner(cfg.FeedSigningKey), cfg.PublicURL, cfg.EventPages).Register(hooks)
+		log.Printf("Purging %s caches as events change", cfg.CDNProvider)
+	}
+	// An OpenSearch index, when configured, serves searches; the indexer tails the
+	// sync change feed to keep it up to date
+	searchIndex, err := internal.NewOpenSearchIndex(cfg)
+	if err != nil {
+		log.Fatalf("Error configuring OpenSearch: %v", err)
+	}
+	var searcher internal.EventSearcher
+	if searchIndex != nil {
+		indexer := internal.NewSearchIndexer(searchIndex, instrumentedEvents)
+		indexer.Register(hooks)
+		indexCtx, stopIndexing := context.WithCancel(context.Background())
+		defer stopIndexing()
+		go indexer.Run(indexCtx)
+		searcher = internal.NewOpenSearchSearcher(searchIndex, eventRepo)
+		log.Printf("Searching events in the OpenSearch index %s", cfg.OpenSearchIndex)
+	}
+	hookedEvents := internal.NewHookedEventRepository(instrumentedEvents, hooks)
+	tokenRepo := internal.NewTokenRepository(app.DB)
+	scheduleRepo := internal.NewScheduleRepository(app.DB)
+	digestRepo := internal.NewDigestRepository(app.DB)
+	snapshotRepo := internal.NewSnapshotRepository(app.DB)
+	snapshotRepo.SetIDGenerator(internal.NewIDGenerator(cfg.IDStrategy))
+	operationRepo := internal.NewOperationRepository(app.DB)
+	commentRepo := internal.NewCommentRepository(app.DB)
+	notifier := internal.NewNotifier(cfg)
+
+	// Jobs that schedules can run
+	scheduler := internal.NewScheduler(scheduleRepo, operationRepo)
+	storage, err := internal.NewStorage(cfg)
+	if err != nil {
+		log.Fatalf("Invalid backup storage configuration: %v", err)
+	}
+	covers := internal.NewCoverStore(storage, internal.NewCoverRepository(app.DB), cfg.CoverSizes)
+
+	// Deleting events, calendars and users handles their dependents by DELETE_POLICIES,
+	// and removes the cover files of deleted events once the delete commits
+	cascade, err := internal.NewCascadeEngineFromConfig(app.DB, cfg)
+	if err != nil {
+		log.Fatalf("Invalid DELETE_POLICIES: %v", err)
+	}
+	cascade.SetCoverStore(covers)
+	eventRepo.SetCascade(cascade)
+	calendarRepo.SetCascade(cascade)
+
+	scheduler.Register(internal.JobExportEvents, internal.ExportEventsJob(instrumentedEvents, storage, cipher))
+	scheduler.Register(internal.JobWeeklyDigest, internal.WeeklyDigestJob(instrumentedEvents, digestRepo, notifier))
+	reminderSenders, err := internal.NewReminderSendersFromConfig(notifier, cfg)
+	if err != nil {
+		log.Fatalf("Invalid WEBHOOK_ALLOWED_NETWORKS: %v", err)
+	}
+	if push != nil {
+		reminderSenders[internal.ReminderChannelPush] = &internal.PushReminderSender{Push: push}
+	}
+	scheduler.Register(internal.JobSendReminders, internal.SendRemindersJob(instrumentedEvents, reminderRepo, reminderSenders))
+	ticketRepo := internal.NewTicketRepository(app.DB)
+	scheduler.Register(internal.JobExpireTicketReservations, internal.ExpireTicketReservationsJob(ticketRepo))
+	scheduler.Register(internal.JobDeliverWebhooks, internal.DeliverWebhooksJob(webhooks))
+	scheduler.RegisterOperation(internal.OperationImportEvents, internal.ImportEventsOperation(hookedEvents, cipher))
+	maintenanceRepo := internal.NewMaintenanceRepository(app.DB)
+	scheduler.RegisterOperation(internal.OperationReindex, internal.ReindexOperation(maintenanceRepo))
+	scheduler.RegisterOperation(internal.OperationRefreshStats, internal.RefreshStatsOperation(maintenanceRepo))
+	privacyRepo := internal.NewPrivacyRepository(app.DB, cipher)
+	privacyRepo.SetCascade(cascade)
+	scheduler.RegisterOperation(internal.OperationExportUserData, internal.ExportUserDataOperation(privacyRepo, storage))
+	scheduler.Register(internal.JobRefreshEventStats, internal.RefreshEventStatsJob(maintenanceRepo))
+	scheduler.Register(internal.JobArchiveEvents, internal.ArchiveEventsJob(internal.NewArchiveRepository(app.DB)))
+
+	// Admin commands run instead of the server: go run main.go <command>
+	var replayEntries []internal.ReplayEntry
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			// Served below
+		case "vapid-keys":
+			public, private, err := internal.GenerateVAPIDKeys()
+			if err != nil {
+				log.Fatalf("Failed to generate VAPID keys: %v", err)
+			}
+			fmt.Printf("VAPID_PUBLIC_KEY=%s\nVAPID_PRIVATE_KEY=%s\n", public, private)
+			return
+		case "reencrypt":
+			n, err := eventRepo.ReencryptEvents(context.Background(), 500)
+			if err != nil {
+				log.Fatalf("Re-encryption failed after %d events: %v", n, err)
+			}
+			log.Printf("Re-encryption completed: %d events rewritten with the primary key", n)
+			return
+		case "restore":
+			// go run main.go restore <file or storage key>
+			if len(os.Args) < 3 {
+				log.Fatal("Usage: restore <backup file or storage key>")
+			}
+			data, err := os.ReadFile(os.Args[2])
+			if errors.Is(err, os.ErrNotExist) {
+				data, err = storage.Get(context.Background(), os.Args[2])
+			}
+			if err != nil {
+				log.Fatalf("Failed to read backup %s: %v", os.Args[2], err)
+			}
+			events, err := internal.DecodeBackup(data, cipher)
+			if err != nil {
+				log.Fatalf("Invalid backup %s: %v", os.Args[2], err)
+			}
+			n, err := eventRepo.ImportEvents(context.Background(), events)
+			if err != nil {
+				log.Fatalf("Restore failed: %v", err)
+			}
+			log.Printf("Restore completed: %d of %d events imported, %d already existed", n, len(events), len(events)-n)
+			return
+		case "replay":
+			// go run main.go replay [--force] <replay log>
+			opts := flag.NewFlagSet("replay", flag.ExitOnError)
+			force := opts.Bool("force", false, "replay into a database that already has events")
+			opts.Parse(os.Args[2:])
+			if opts.NArg() != 1 {
+				log.Fatal("Usage: replay [--force] <replay log>")
+			}
+			f, err := os.Open(opts.Arg(0))
+			if err != nil {
+				log.Fatalf("Failed to open replay log: %v", err)
+			}
+			replayEntries, err = internal.ReadReplayLog(f)
+			f.Close()
+			if err != nil {
+				log.Fatalf("Invalid replay log %s: %v", opts.Arg(0), err)
+			}
+			existing, err := eventRepo.GetEvents(context.Background())
+			if err != nil {
+				log.Fatalf("Failed to check the database: %v", err)
+			}
+			if len(existing) > 0 && !*force {
+				log.Fatalf("The database has %d events; replay into a fresh database, or pass --force", len(existing))
+			}
+			// The replay runs through the API but is neither scheduled nor recorded
+			cfg.SchedulerEnabled, cfg.ReplayLog = false, ""
+		default:
+			log.Fatalf("Unknown command %q", os.Args[1])
+		}
+	}
+
+	// Run due schedules and queued operations in the background until the server stops
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	schedulerDone := make(chan struct{})
+	go func() {
+		defer close(schedulerDone)
+		if cfg.SchedulerEnabled {
+			scheduler.Run(schedulerCtx)
+		}
+	}()
+
+	// A read replica serves the API's event reads, except those that must see a write
+	// the replica has not replayed yet
+	var apiEventRepo internal.EventRepositoryInterface = hookedEvents
+	if cfg.ReplicaDatabaseURL != "" {
+		replicaApp := internal.ConnectionDB(func() string { return cfg.ReplicaDatabaseURL }, connectOpts)
+		defer replicaApp.DB.Close()
+		replicaEvents := internal.NewInstrumentedEventRepository(internal.NewEventRepository(replicaApp.DB, cipher), metrics, cfg.TraceRepository)
+		apiEventRepo = internal.NewReplicatedEventRepository(hookedEvents, replicaEvents, app.DB, replicaApp.DB, cfg.ReplicaMaxWait)
+		log.Printf("Reading events from the replica, waiting up to %s for it to catch up", cfg.ReplicaMaxWait)
+	}
+
+	// Shadow reads compare the pgx repository with the lib/pq one on live traffic;
+	// responses always come from the primary
+	if cfg.ShadowReads {
+		shadowDSN := cfg.ShadowDatabaseURL
+		if shadowDSN == "" {
+			shadowDSN = secrets.Get("DATABASE_URL")
+		}
+		if shadowDSN == "" {
+			shadowDSN = cfg.DatabaseURL
+		}
+		pgxEvents, err := internal.NewPgxEventRepository(context.Background(), shadowDSN, cipher)
+		if err != nil {
+			log.Fatalf("Failed to set up shadow reads: %v", err)
+		}
+		defer pgxEvents.Close()
+		apiEventRepo = internal.NewShadowEventRepository(apiEventRepo, pgxEvents, cfg.ShadowReadPercent)
+		log.Printf("Shadowing %d%% of event reads through pgx", cfg.ShadowReadPercent)
+	}
+
+	// Identical concurrent reads share one query
+	if cfg.CoalesceReads {
+		apiEventRepo = internal.NewCoalescedEventRepository(apiEventRepo, metrics)
+	}
+
+	// Heatmaps and stats are served from memory, revalidated in the background once stale
+	if cfg.AggregateCacheTTL > 0 {
+		aggregates := internal.NewCachedAggregateRepository(apiEventRepo, cfg.AggregateCacheTTL, cfg.AggregateStaleWhileRevalidate, metrics)
+		revalidateCtx, stopRevalidating := context.WithCancel(context.Background())
+		defer stopRevalidating()
+		go aggregates.Run(revalidateCtx)
+		apiEventRepo = aggregates
+	}
+
+	// Anonymous usage analytics, disabled by default
+	sink, err := internal.NewAnalyticsSink(cfg)
+	if err != nil {
+		log.Fatalf("Error configuring analytics: %v", err)
+	}
+	var analytics *internal.Analytics
+	analyticsDone := make(chan struct{})
+	analyticsCtx, stopAnalytics := context.WithCancel(context.Background())
+	if sink != nil {
+		analytics = internal.NewAnalytics(sink, cfg.AnalyticsSalt)
+		go func() {
+			analytics.Run(analyticsCtx)
+			close(analyticsDone)
+		}()
+		log.Printf("Sending anonymous usage analytics to %s", cfg.AnalyticsSink)
+	} else {
+		close(analyticsDone)
+	}
+
+	// Mutating requests are recorded for the replay command, to reproduce reported bugs
+	var replayLog *internal.ReplayLog
+	if cfg.ReplayLog != "" {
+		if replayLog, err = internal.OpenReplayLog(cfg.ReplayLog); err != nil {
+			log.Fatalf("Error opening REPLAY_LOG: %v", err)
+		}
+		defer replayLog.Close()
+		log.Printf("Recording mutating requests, with their bodies, to %s", cfg.ReplayLog)
 	}
 
 	// Start HTTP server
-	api.StartServer(eventRepo, port)
+	srv, err := api.NewServer(cfg, api.Dependencies{
+		Tx:                internal.NewTxManager(app.DB),
+		Events:            apiEventRepo,
+		Search:            searcher,
+		Tokens:            tokenRepo,
+		TwoFactor:         internal.NewTwoFactorRepository(app.DB, cipher),
+		Preferences:       preferencesRepo,
+		Privacy:           privacyRepo,
+		Storage:           storage,
+		Schedules:         scheduleRepo,
+		Digests:           digestRepo,
+		Calendars:         calendarRepo,
+		Organizations:     orgRepo,
+		Delegates:         delegateRepo,
+		Snapshots:         snapshotRepo,
+		Operations:        operationRepo,
+		Policies:          policyRepo,
+		PolicyEngine:      policies,
+		Comments:          commentRepo,
+		Activity:          activityRepo,
+		Reminders:         reminderRepo,
+		ReminderSenders:   reminderSenders,
+		PushSubscriptions: pushRepo,
+		DeviceTokens:      deviceRepo,
+		PushProviders:     pushProviders,
+		Covers:            covers,
+		Resources:         internal.NewResourceRepository(app.DB),
+		Tickets:           ticketRepo,
+		Payments:          internal.NewPaymentProvider(cfg),
+		Webhooks:          webhookRepo,
+		IngestSources:     internal.NewIngestRepository(app.DB),
+		WebPush:           webPush,
+		Notifier:          notifier,
+		Scheduler:         scheduler,
+		Maintenance:       internal.NewMaintenanceSwitch(maintenanceRepo),
+		AuthLockout:       internal.NewAuthLockout(internal.NewAuthFailureRepository(app.DB), cfg.AuthLockout),
+		Metrics:           metrics,
+		Analytics:         analytics,
+		Chaos:             chaos,
+		ReplayLog:         replayLog,
+	})
+	if err != nil {
+		log.Fatalf("Error creating server: %v", err)
+	}
+	if replaying {
+		runReplay(srv.Router, replayEntries)
+	} else {
+		srv.Run()
+	}
+
+	// Send the analytics and metrics of the last requests
+	stopAnalytics()
+	<-analyticsDone
+	stopStatsD()
+	<-statsdDone
+
+	// Let running jobs finish before the database connection closes
+	stopScheduler()
+	<-schedulerDone
+}
+
+// serveMock serves the event API from a MemoryEventRepository seeded with
+// internal.MockEvents. Requests wait for latency first, and CHAOS_RULES apply in any
+// environment, as there is no real data to protect.
+func serveMock(cfg internal.Config, seed int64, anchor, latency string) {
+	at := time.Now()
+	if anchor != "" {
+		var err error
+		if at, err = time.Parse(time.DateOnly, anchor); err != nil {
+			log.Fatalf("Invalid --anchor %q: expected YYYY-MM-DD", anchor)
+		}
+	}
+	// Rules of CHAOS_RULES match first, so they still apply to their routes
+	if latency != "" {
+		cfg.ChaosRules = strings.TrimPrefix(cfg.ChaosRules+";*=latency:"+latency, ";")
+	}
+	chaos, err := internal.NewChaos(cfg)
+	if err != nil {
+		log.Fatalf("Invalid --latency or CHAOS_RULES: %v", err)
+	}
+
+	events := internal.MockEvents(seed, at)
+	var repo internal.EventRepositoryInterface = internal.NewMemoryEventRepository(events)
+	if chaos != nil {
+		repo = internal.NewChaosEventRepository(repo, chaos)
+	}
+	metrics := internal.NewMetrics()
+	hooks := internal.NewEventHooks()
+	if err := internal.LoadPlugins(hooks, cfg.Plugins); err != nil {
+		log.Fatalf("Invalid PLUGINS: %v", err)
+	}
+	repo = internal.NewHookedEventRepository(internal.NewInstrumentedEventRepository(repo, metrics, cfg.TraceRepository), hooks)
+
+	srv, err := api.NewServer(cfg, api.Dependencies{Events: repo, Metrics: metrics, Chaos: chaos})
+	if err != nil {
+		log.Fatalf("Error creating server: %v", err)
+	}
+	log.Printf("Mock mode: serving %d generated events (seed %d) from memory; changes are lost on exit", len(events), seed)
+	srv.Run()
+}
+
+// runReplay re-applies the entries of a replay log through the API router, in order,
+// and reports those returning another status than they did when recorded
+func runReplay(router http.Handler, entries []internal.ReplayEntry) {
+	replayer := internal.NewReplayer(router)
+	skipped, mismatched := 0, 0
+	for i, e := range entries {
+		out := replayer.Replay(context.Background(), e)
+		switch {
+		case out.Skipped != "":
+			skipped++
+			log.Printf("Replay %d/%d: skipped %s %s: %s", i+1, len(entries), e.Method, e.Path, out.Skipped)
+		case out.Mismatch():
+			mismatched++
+			log.Printf("Replay %d/%d: %s %s returned %d, recorded %d: %s", i+1, len(entries), e.Method, e.Path, out.Status, e.Status, internal.LogPayload(out.Body))
+		}
+	}
+	log.Printf("Replay completed: %d requests, %d skipped, %d returned another status", len(entries), skipped, mismatched)
 }
@@ -0,0 +1,16 @@
+-- 002_add_event_location.sql
+-- Migration: Add optional location and coordinates to events
+-- Created: 2025-08-25
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS location TEXT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+
+-- Coordinates are either both set or both missing
+ALTER TABLE events DROP CONSTRAINT IF EXISTS events_coordinates_check;
+ALTER TABLE events ADD CONSTRAINT events_coordinates_check CHECK (
+    (latitude IS NULL AND longitude IS NULL)
+    OR (latitude BETWEEN -90 AND 90 AND longitude BETWEEN -180 AND 180)
+);
+
+SELECT 'Migration 002 completed successfully!' as status;
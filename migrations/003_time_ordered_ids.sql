@@ -0,0 +1,35 @@
+-- 003_time_ordered_ids.sql
+-- Migration: Time-ordered primary keys
+-- Created: 2025-08-27
+--
+-- The application now generates IDs itself (ID_STRATEGY=uuidv4|uuidv7|ulid).
+-- Existing UUIDv4 rows stay valid: all strategies share the UUID column type,
+-- so switching strategy only changes how new rows are keyed. The column default
+-- is moved to UUIDv7 so rows inserted outside the API are time-ordered too.
+
+-- gen_random_bytes comes from pgcrypto
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+CREATE OR REPLACE FUNCTION uuid_generate_v7()
+RETURNS UUID AS $$
+DECLARE
+    unix_ms BIGINT := FLOOR(EXTRACT(EPOCH FROM clock_timestamp()) * 1000);
+    bytes BYTEA := gen_random_bytes(16);
+BEGIN
+    -- 48-bit big-endian millisecond timestamp
+    bytes := SET_BYTE(bytes, 0, ((unix_ms >> 40) & 255)::INT);
+    bytes := SET_BYTE(bytes, 1, ((unix_ms >> 32) & 255)::INT);
+    bytes := SET_BYTE(bytes, 2, ((unix_ms >> 24) & 255)::INT);
+    bytes := SET_BYTE(bytes, 3, ((unix_ms >> 16) & 255)::INT);
+    bytes := SET_BYTE(bytes, 4, ((unix_ms >> 8) & 255)::INT);
+    bytes := SET_BYTE(bytes, 5, (unix_ms & 255)::INT);
+    -- version 7 and RFC 4122 variant bits
+    bytes := SET_BYTE(bytes, 6, (GET_BYTE(bytes, 6) & 15) | 112);
+    bytes := SET_BYTE(bytes, 8, (GET_BYTE(bytes, 8) & 63) | 128);
+    RETURN ENCODE(bytes, 'hex')::UUID;
+END;
+$$ LANGUAGE plpgsql VOLATILE;
+
+ALTER TABLE events ALTER COLUMN id SET DEFAULT uuid_generate_v7();
+
+SELECT 'Migration 003 completed successfully!' as status;
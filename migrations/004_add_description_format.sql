@@ -0,0 +1,14 @@
+-- 004_add_description_format.sql
+-- Migration: Track the markup format of event descriptions
+-- Created: 2025-08-28
+--
+-- Descriptions keep their raw source; rendering to HTML happens on read.
+-- Existing rows are plain text.
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS description_format VARCHAR(16) NOT NULL DEFAULT 'plain';
+
+ALTER TABLE events DROP CONSTRAINT IF EXISTS events_description_format_check;
+ALTER TABLE events ADD CONSTRAINT events_description_format_check
+    CHECK (description_format IN ('plain', 'markdown'));
+
+SELECT 'Migration 004 completed successfully!' as status;
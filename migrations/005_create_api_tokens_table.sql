@@ -0,0 +1,19 @@
+-- 005_create_api_tokens_table.sql
+-- Migration: Personal API tokens with scopes and expiry
+-- Created: 2025-08-29
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id UUID PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    -- SHA-256 of the token secret; the secret itself is only shown once at creation
+    token_hash BYTEA NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens(user_id);
+
+SELECT 'Migration 005 completed successfully!' as status;
@@ -0,0 +1,16 @@
+-- 007_create_digest_subscriptions_table.sql
+-- Migration: Weekly digest email subscriptions
+-- Created: 2025-09-03
+
+CREATE TABLE IF NOT EXISTS digest_subscriptions (
+    user_id TEXT PRIMARY KEY,
+    email TEXT NOT NULL,
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    language TEXT NOT NULL DEFAULT 'en',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    last_sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+SELECT 'Migration 007 completed successfully!' as status;
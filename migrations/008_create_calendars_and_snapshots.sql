@@ -0,0 +1,43 @@
+-- 008_create_calendars_and_snapshots.sql
+-- Migration: Calendars, and logical snapshots of the events table
+-- Created: 2025-09-05
+
+CREATE TABLE IF NOT EXISTS calendars (
+    id UUID PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    owner_id TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Existing events stay in the default calendar (NULL)
+ALTER TABLE events ADD COLUMN IF NOT EXISTS calendar_id UUID REFERENCES calendars(id) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS idx_events_calendar_id ON events(calendar_id, start_time);
+
+CREATE TABLE IF NOT EXISTS snapshots (
+    id UUID PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    event_count INTEGER NOT NULL DEFAULT 0,
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Rows are copied verbatim from events, including encrypted column values
+CREATE TABLE IF NOT EXISTS snapshot_events (
+    snapshot_id UUID NOT NULL REFERENCES snapshots(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL,
+    calendar_id UUID,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    description_format TEXT NOT NULL,
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+    location TEXT,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    PRIMARY KEY (snapshot_id, event_id)
+);
+
+SELECT 'Migration 008 completed successfully!' as status;
@@ -0,0 +1,28 @@
+-- 009_create_operations_table.sql
+-- Migration: Long-running operations processed in the background
+-- Created: 2025-09-08
+
+CREATE TABLE IF NOT EXISTS operations (
+    id UUID PRIMARY KEY,
+    kind TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    -- The uploaded payload; dropped once the operation finishes
+    input BYTEA,
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    succeeded INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    errors JSONB NOT NULL DEFAULT '[]',
+    result TEXT NOT NULL DEFAULT '',
+    error TEXT,
+    created_by TEXT NOT NULL DEFAULT '',
+    -- Lease of the instance processing the operation; renewed on every progress save
+    locked_until TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_operations_queue ON operations(created_at) WHERE status IN ('pending', 'running');
+
+SELECT 'Migration 009 completed successfully!' as status;
@@ -0,0 +1,55 @@
+-- 010_add_event_sync.sql
+-- Migration: Change versions and tombstones for offline sync
+-- Created: 2025-09-10
+
+-- version is the ID of the transaction that last wrote the row. Sync pulls changes in
+-- version order and only up to the oldest transaction still running, so a change that
+-- commits late is never skipped.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT txid_current();
+CREATE INDEX IF NOT EXISTS idx_events_version ON events(version, id);
+
+CREATE OR REPLACE FUNCTION set_event_version()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version = txid_current();
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS set_events_version ON events;
+CREATE TRIGGER set_events_version
+    BEFORE INSERT OR UPDATE ON events
+    FOR EACH ROW
+    EXECUTE FUNCTION set_event_version();
+
+-- Deleted events, so clients can be told to drop their copy
+CREATE TABLE IF NOT EXISTS event_tombstones (
+    id UUID PRIMARY KEY,
+    calendar_id UUID,
+    version BIGINT NOT NULL,
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_event_tombstones_version ON event_tombstones(version, id);
+
+CREATE OR REPLACE FUNCTION record_event_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO event_tombstones (id, calendar_id, version)
+        VALUES (OLD.id, OLD.calendar_id, txid_current())
+        ON CONFLICT (id) DO UPDATE SET calendar_id = EXCLUDED.calendar_id, version = EXCLUDED.version, deleted_at = NOW();
+        RETURN OLD;
+    END IF;
+    -- An event recreated with the ID of a deleted one is live again
+    DELETE FROM event_tombstones WHERE id = NEW.id;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS record_events_tombstone ON events;
+CREATE TRIGGER record_events_tombstone
+    AFTER INSERT OR DELETE ON events
+    FOR EACH ROW
+    EXECUTE FUNCTION record_event_tombstone();
+
+SELECT 'Migration 010 completed successfully!' as status;
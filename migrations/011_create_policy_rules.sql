@@ -0,0 +1,19 @@
+-- 011_create_policy_rules.sql
+-- Migration: Admin-defined validation rules (CEL expressions) checked on event writes
+-- Created: 2025-09-11
+
+CREATE TABLE IF NOT EXISTS policy_rules (
+    id UUID PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    -- CEL expression over event and now that must evaluate to true
+    expression TEXT NOT NULL,
+    message TEXT NOT NULL,
+    -- Limits the rule to one calendar; NULL applies it to every event
+    calendar_id UUID REFERENCES calendars(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+SELECT 'Migration 011 completed successfully!' as status;
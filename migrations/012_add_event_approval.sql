@@ -0,0 +1,31 @@
+-- 012_add_event_approval.sql
+-- Migration: Review status of events and the reviewers' decisions
+-- Created: 2025-09-12
+
+-- Existing events are published; events submitted by non-reviewers start as pending
+-- when APPROVAL_REQUIRED is set
+ALTER TABLE events ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'approved';
+ALTER TABLE events ADD COLUMN IF NOT EXISTS submitted_by TEXT NOT NULL DEFAULT '';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'events_status_check') THEN
+        ALTER TABLE events ADD CONSTRAINT events_status_check CHECK (status IN ('pending', 'approved', 'rejected'));
+    END IF;
+END $$;
+
+-- The review queue, oldest submission first
+CREATE INDEX IF NOT EXISTS idx_events_pending ON events(created_at, id) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS event_reviews (
+    id UUID PRIMARY KEY,
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    decision TEXT NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    reviewer TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_reviews_event ON event_reviews(event_id, created_at);
+
+SELECT 'Migration 012 completed successfully!' as status;
@@ -0,0 +1,18 @@
+-- 013_create_event_comments.sql
+-- Migration: Discussion threads on events
+-- Created: 2025-09-13
+
+CREATE TABLE IF NOT EXISTS event_comments (
+    id UUID PRIMARY KEY,
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    author TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    -- User IDs @mentioned in the body, notified when the comment is posted
+    mentions TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Threads are read oldest first, page by page
+CREATE INDEX IF NOT EXISTS idx_event_comments_thread ON event_comments(event_id, created_at, id);
+
+SELECT 'Migration 013 completed successfully!' as status;
@@ -0,0 +1,22 @@
+-- 014_create_event_activity.sql
+-- Migration: Log of event writes for the activity feed
+-- Created: 2025-09-14
+
+-- Rows outlive their event, so a deletion still shows in the feed
+CREATE TABLE IF NOT EXISTS event_activity (
+    id UUID PRIMARY KEY,
+    event_id UUID NOT NULL,
+    calendar_id UUID,
+    action TEXT NOT NULL,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    actor TEXT NOT NULL DEFAULT '',
+    -- false while the event awaits review, so only its submitter and reviewers see it
+    published BOOLEAN NOT NULL DEFAULT true,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- The feed is read newest first, overall or for a set of calendars
+CREATE INDEX IF NOT EXISTS idx_event_activity_feed ON event_activity(occurred_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_event_activity_calendar ON event_activity(calendar_id, occurred_at DESC);
+
+SELECT 'Migration 014 completed successfully!' as status;
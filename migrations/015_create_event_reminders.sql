@@ -0,0 +1,26 @@
+-- 015_create_event_reminders.sql
+-- Migration: Per-user reminders before an event starts
+-- Created: 2025-09-15
+
+CREATE TABLE IF NOT EXISTS event_reminders (
+    id UUID PRIMARY KEY,
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    owner_id TEXT NOT NULL DEFAULT '',
+    -- Minutes before the event's start; the reminder follows the event when it moves
+    offset_minutes INTEGER NOT NULL CHECK (offset_minutes >= 0),
+    channel TEXT NOT NULL,
+    -- Email address or webhook URL, depending on the channel
+    target TEXT NOT NULL DEFAULT '',
+    language TEXT NOT NULL DEFAULT 'en',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    sent_at TIMESTAMPTZ,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_reminders_event ON event_reminders(event_id, owner_id);
+-- Reminders still to send, scanned by the send_reminders job
+CREATE INDEX IF NOT EXISTS idx_event_reminders_unsent ON event_reminders(event_id) WHERE sent_at IS NULL;
+
+SELECT 'Migration 015 completed successfully!' as status;
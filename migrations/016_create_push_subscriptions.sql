@@ -0,0 +1,20 @@
+-- 016_create_push_subscriptions.sql
+-- Migration: Browser push subscriptions (Web Push)
+-- Created: 2025-09-16
+
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+    id UUID PRIMARY KEY,
+    user_id TEXT NOT NULL DEFAULT '',
+    -- The push service URL of one browser; re-registering it moves it to the caller
+    endpoint TEXT NOT NULL UNIQUE,
+    -- The browser's P-256 public key and auth secret, base64url as sent by the browser
+    p256dh TEXT NOT NULL,
+    auth TEXT NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_push_subscriptions_user ON push_subscriptions(user_id);
+
+SELECT 'Migration 016 completed successfully!' as status;
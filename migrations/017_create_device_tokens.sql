@@ -0,0 +1,18 @@
+-- 017_create_device_tokens.sql
+-- Migration: Mobile push device tokens (FCM and APNs)
+-- Created: 2025-09-17
+
+CREATE TABLE IF NOT EXISTS device_tokens (
+    id UUID PRIMARY KEY,
+    user_id TEXT NOT NULL DEFAULT '',
+    platform TEXT NOT NULL CHECK (platform IN ('fcm', 'apns')),
+    -- Registration token of the app on one device; deleted when the provider rejects it
+    token TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (platform, token)
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_tokens_user ON device_tokens(user_id);
+
+SELECT 'Migration 017 completed successfully!' as status;
@@ -0,0 +1,16 @@
+-- 018_create_event_covers.sql
+-- Migration: Cover images of events
+-- Created: 2025-09-18
+
+CREATE TABLE IF NOT EXISTS event_covers (
+    event_id UUID PRIMARY KEY REFERENCES events(id) ON DELETE CASCADE,
+    content_type TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    bytes INTEGER NOT NULL,
+    -- Hash of the original; the files are stored under covers/<event_id>/<etag>/
+    etag TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+SELECT 'Migration 018 completed successfully!' as status;
@@ -0,0 +1,9 @@
+-- 019_add_calendar_feed_version.sql
+-- Migration: Rotatable ICS feed tokens of calendars
+-- Created: 2025-09-19
+
+-- Feed tokens are signed over the calendar ID and this version; rotating the token
+-- increments it, which invalidates every URL handed out before
+ALTER TABLE calendars ADD COLUMN IF NOT EXISTS feed_version INTEGER NOT NULL DEFAULT 1;
+
+SELECT 'Migration 019 completed successfully!' as status;
@@ -0,0 +1,54 @@
+-- 020_create_resources.sql
+-- Migration: Bookable resources such as rooms and projectors
+-- Created: 2025-09-20
+
+-- btree_gist lets the exclusion constraint below compare resource IDs with =
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE TABLE IF NOT EXISTS resources (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL DEFAULT 'room',
+    -- People a room seats; NULL for equipment
+    capacity INTEGER CHECK (capacity > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+DROP TRIGGER IF EXISTS update_resources_updated_at ON resources;
+CREATE TRIGGER update_resources_updated_at
+    BEFORE UPDATE ON resources
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- A resource booked for an event. Resources with bookings cannot be deleted.
+CREATE TABLE IF NOT EXISTS resource_bookings (
+    resource_id UUID NOT NULL REFERENCES resources(id) ON DELETE RESTRICT,
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    -- The event's time, kept in step by the trigger below
+    period TSTZRANGE NOT NULL,
+    PRIMARY KEY (resource_id, event_id),
+    -- No resource is booked twice at the same time, however the bookings are written
+    CONSTRAINT resource_bookings_no_overlap EXCLUDE USING gist (resource_id WITH =, period WITH &&)
+);
+
+CREATE INDEX IF NOT EXISTS idx_resource_bookings_event ON resource_bookings(event_id);
+
+-- Moving an event moves its bookings, failing with the exclusion constraint when a
+-- resource is taken at the new time
+CREATE OR REPLACE FUNCTION sync_resource_bookings()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE resource_bookings SET period = tstzrange(NEW.start_time, NEW.end_time) WHERE event_id = NEW.id;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS sync_events_resource_bookings ON events;
+CREATE TRIGGER sync_events_resource_bookings
+    AFTER UPDATE OF start_time, end_time ON events
+    FOR EACH ROW
+    WHEN (OLD.start_time IS DISTINCT FROM NEW.start_time OR OLD.end_time IS DISTINCT FROM NEW.end_time)
+    EXECUTE FUNCTION sync_resource_bookings();
+
+SELECT 'Migration 020 completed successfully!' as status;
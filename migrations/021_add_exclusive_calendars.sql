@@ -0,0 +1,48 @@
+-- 021_add_exclusive_calendars.sql
+-- Migration: Calendars whose events may not overlap
+-- Created: 2025-09-21
+
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+ALTER TABLE calendars ADD COLUMN IF NOT EXISTS exclusive BOOLEAN NOT NULL DEFAULT false;
+
+-- Copy of the calendar's exclusive flag, since a constraint only sees its own row
+ALTER TABLE events ADD COLUMN IF NOT EXISTS in_exclusive_calendar BOOLEAN NOT NULL DEFAULT false;
+
+CREATE OR REPLACE FUNCTION set_event_in_exclusive_calendar()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.in_exclusive_calendar = COALESCE((SELECT exclusive FROM calendars WHERE id = NEW.calendar_id), false);
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS set_events_in_exclusive_calendar ON events;
+CREATE TRIGGER set_events_in_exclusive_calendar
+    BEFORE INSERT OR UPDATE OF calendar_id ON events
+    FOR EACH ROW
+    EXECUTE FUNCTION set_event_in_exclusive_calendar();
+
+-- Marking a calendar exclusive fails with the constraint below while its events overlap
+CREATE OR REPLACE FUNCTION sync_calendar_exclusive()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE events SET in_exclusive_calendar = NEW.exclusive WHERE calendar_id = NEW.id;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS sync_calendars_exclusive ON calendars;
+CREATE TRIGGER sync_calendars_exclusive
+    AFTER UPDATE OF exclusive ON calendars
+    FOR EACH ROW
+    WHEN (OLD.exclusive IS DISTINCT FROM NEW.exclusive)
+    EXECUTE FUNCTION sync_calendar_exclusive();
+
+-- Rejected events do not take up time
+ALTER TABLE events DROP CONSTRAINT IF EXISTS events_exclusive_no_overlap;
+ALTER TABLE events ADD CONSTRAINT events_exclusive_no_overlap
+    EXCLUDE USING gist (calendar_id WITH =, tstzrange(start_time, end_time) WITH &&)
+    WHERE (in_exclusive_calendar AND status <> 'rejected');
+
+SELECT 'Migration 021 completed successfully!' as status;
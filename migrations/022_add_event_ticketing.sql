@@ -0,0 +1,38 @@
+-- 022_add_event_ticketing.sql
+-- Migration: Event prices, ticket quotas and reservations
+-- Created: 2025-09-22
+
+-- Prices are in the currency's minor unit (cents); a price always has a currency
+ALTER TABLE events ADD COLUMN IF NOT EXISTS price_cents BIGINT CHECK (price_cents >= 0);
+ALTER TABLE events ADD COLUMN IF NOT EXISTS currency TEXT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS ticket_quota INTEGER CHECK (ticket_quota > 0);
+ALTER TABLE events DROP CONSTRAINT IF EXISTS events_price_currency;
+ALTER TABLE events ADD CONSTRAINT events_price_currency CHECK ((price_cents IS NULL) = (currency IS NULL));
+
+-- Tickets taken from each event's quota. The count lives apart from the event so that
+-- reservations do not change the event's version.
+CREATE TABLE IF NOT EXISTS event_ticket_counts (
+    event_id UUID PRIMARY KEY REFERENCES events(id) ON DELETE CASCADE,
+    reserved INTEGER NOT NULL DEFAULT 0 CHECK (reserved >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS ticket_reservations (
+    id UUID PRIMARY KEY,
+    event_id UUID NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    holder_id TEXT NOT NULL DEFAULT '',
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    -- held until paid, then confirmed; held reservations expire at expires_at
+    status TEXT NOT NULL DEFAULT 'held',
+    -- What the reservation costs, at the price when it was made
+    amount_cents BIGINT,
+    currency TEXT,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ticket_reservations_event ON ticket_reservations(event_id, holder_id);
+-- Held reservations, scanned by the expire_ticket_reservations job
+CREATE INDEX IF NOT EXISTS idx_ticket_reservations_held ON ticket_reservations(expires_at) WHERE status = 'held';
+
+SELECT 'Migration 022 completed successfully!' as status;
@@ -0,0 +1,15 @@
+-- 023_add_reservation_payments.sql
+-- Migration: Payment provider references on ticket reservations
+-- Created: 2025-09-23
+
+-- A held reservation paid through a provider records its checkout session; once paid,
+-- the provider's payment, which a refund is issued against
+ALTER TABLE ticket_reservations ADD COLUMN IF NOT EXISTS payment_provider TEXT;
+ALTER TABLE ticket_reservations ADD COLUMN IF NOT EXISTS checkout_session_id TEXT;
+ALTER TABLE ticket_reservations ADD COLUMN IF NOT EXISTS checkout_url TEXT;
+ALTER TABLE ticket_reservations ADD COLUMN IF NOT EXISTS payment_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ticket_reservations_checkout
+    ON ticket_reservations(payment_provider, checkout_session_id) WHERE checkout_session_id IS NOT NULL;
+
+SELECT 'Migration 023 completed successfully!' as status;
@@ -0,0 +1,55 @@
+-- 024_create_organizations.sql
+-- Migration: Organizations, their members and invitations, and calendars they own
+-- Created: 2025-09-24
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+DROP TRIGGER IF EXISTS update_organizations_updated_at ON organizations;
+CREATE TRIGGER update_organizations_updated_at
+    BEFORE UPDATE ON organizations
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Owners manage the organization and its members, admins its members below owner and
+-- its calendars, members see what it owns
+CREATE TABLE IF NOT EXISTS organization_members (
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_members_user ON organization_members(user_id);
+
+-- Invitations are emailed as a token; only its hash is stored
+CREATE TABLE IF NOT EXISTS organization_invitations (
+    id UUID PRIMARY KEY,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    token_hash BYTEA NOT NULL UNIQUE,
+    invited_by TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_by TEXT,
+    accepted_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_invitations_org ON organization_invitations(organization_id);
+
+-- An organization with calendars cannot be deleted until they are deleted or moved
+ALTER TABLE calendars ADD COLUMN IF NOT EXISTS organization_id UUID;
+ALTER TABLE calendars DROP CONSTRAINT IF EXISTS calendars_organization_id_fkey;
+ALTER TABLE calendars ADD CONSTRAINT calendars_organization_id_fkey
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE RESTRICT;
+
+CREATE INDEX IF NOT EXISTS idx_calendars_organization ON calendars(organization_id) WHERE organization_id IS NOT NULL;
+
+SELECT 'Migration 024 completed successfully!' as status;
@@ -0,0 +1,26 @@
+-- 025_create_calendar_delegates.sql
+-- Migration: Delegation grants letting other users write events in a calendar
+-- Created: 2025-09-25
+
+-- A grant lets user_id create, edit or delete the calendar's events, as listed in
+-- permissions, until expires_at when set
+CREATE TABLE IF NOT EXISTS calendar_delegates (
+    calendar_id UUID NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    permissions TEXT[] NOT NULL CHECK (permissions <@ ARRAY['create', 'edit', 'delete'] AND cardinality(permissions) > 0),
+    expires_at TIMESTAMPTZ,
+    granted_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (calendar_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_calendar_delegates_user ON calendar_delegates(user_id);
+
+DROP TRIGGER IF EXISTS update_calendar_delegates_updated_at ON calendar_delegates;
+CREATE TRIGGER update_calendar_delegates_updated_at
+    BEFORE UPDATE ON calendar_delegates
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+SELECT 'Migration 025 completed successfully!' as status;
@@ -0,0 +1,11 @@
+-- 026_add_calendar_visibility.sql
+-- Migration: Calendars whose events other users only see as busy blocks
+-- Created: 2025-09-26
+
+-- full shows every viewer the events; busy shows viewers other than the owner, the
+-- organization's members and delegates only when the calendar is busy
+ALTER TABLE calendars ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'full';
+ALTER TABLE calendars DROP CONSTRAINT IF EXISTS calendars_visibility_check;
+ALTER TABLE calendars ADD CONSTRAINT calendars_visibility_check CHECK (visibility IN ('full', 'busy'));
+
+SELECT 'Migration 026 completed successfully!' as status;
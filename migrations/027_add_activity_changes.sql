@@ -0,0 +1,9 @@
+-- 027_add_activity_changes.sql
+-- Migration: The fields each update of the activity feed changed
+-- Created: 2025-09-27
+
+-- A list of {"field", "old", "new"} objects; NULL for creates, deletes and updates
+-- recorded before this migration
+ALTER TABLE event_activity ADD COLUMN IF NOT EXISTS changes JSONB;
+
+SELECT 'Migration 027 completed successfully!' as status;
@@ -0,0 +1,15 @@
+-- 029_add_webhook_secrets.sql
+-- Migration: Signing secrets of webhooks, with the previous one kept during a rotation
+-- Created: 2025-09-29
+
+-- Deliveries are signed with secret and, until previous_secret_expires_at, with
+-- previous_secret too, so receivers can switch over without missing a delivery.
+-- Existing webhooks get a random secret; rotate it to read it.
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS secret TEXT;
+UPDATE webhooks SET secret = 'whsec_' || replace(uuid_generate_v4()::text || uuid_generate_v4()::text, '-', '') WHERE secret IS NULL;
+ALTER TABLE webhooks ALTER COLUMN secret SET NOT NULL;
+
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS previous_secret TEXT;
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS previous_secret_expires_at TIMESTAMPTZ;
+
+SELECT 'Migration 029 completed successfully!' as status;
@@ -0,0 +1,26 @@
+-- 030_create_ingest_sources.sql
+-- Migration: External sources whose payloads POSTed to /ingest/{name} become events
+-- Created: 2025-09-30
+
+-- mapping holds a CEL expression per event field, evaluated over the payload; filter,
+-- when set, is a boolean expression payloads must match to be ingested
+CREATE TABLE IF NOT EXISTS ingest_sources (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    secret TEXT NOT NULL,
+    calendar_id UUID REFERENCES calendars(id) ON DELETE CASCADE,
+    filter TEXT NOT NULL DEFAULT '',
+    mapping JSONB NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+DROP TRIGGER IF EXISTS update_ingest_sources_updated_at ON ingest_sources;
+CREATE TRIGGER update_ingest_sources_updated_at
+    BEFORE UPDATE ON ingest_sources
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+SELECT 'Migration 030 completed successfully!' as status;
@@ -0,0 +1,13 @@
+-- 031_add_event_client_keys.sql
+-- Migration: Client-chosen keys naming events managed as code
+-- Created: 2025-10-01
+
+-- Set on create and never changed, so a declarative sync can match the events it
+-- manages. Keys are unique per calendar, the default calendar included.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS client_key TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS events_client_key_unique
+    ON events (COALESCE(calendar_id, '00000000-0000-0000-0000-000000000000'::uuid), client_key)
+    WHERE client_key IS NOT NULL;
+
+SELECT 'Migration 031 completed successfully!' as status;
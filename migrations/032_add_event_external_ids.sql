@@ -0,0 +1,14 @@
+-- 032_add_event_external_ids.sql
+-- Migration: The ID of events synced from other systems, such as Jira or Google Calendar
+-- Created: 2025-10-02
+
+-- source names the system and external_id the event in it; imports upsert on the pair,
+-- so syncing the same events again updates them instead of duplicating them
+ALTER TABLE events ADD COLUMN IF NOT EXISTS external_id TEXT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS source TEXT;
+
+ALTER TABLE events ADD CONSTRAINT events_external_id_with_source
+    CHECK ((external_id IS NULL) = (source IS NULL));
+ALTER TABLE events ADD CONSTRAINT events_source_external_id_unique UNIQUE (source, external_id);
+
+SELECT 'Migration 032 completed successfully!' as status;
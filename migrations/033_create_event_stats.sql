@@ -0,0 +1,19 @@
+-- 033_create_event_stats.sql
+-- Migration: Daily event counts per calendar, precomputed for GET /events/stats
+-- Created: 2025-10-03
+
+-- Published events by UTC start day and calendar. Events without a calendar are kept
+-- under the nil UUID so the unique index, which concurrent refreshes need, covers them.
+CREATE MATERIALIZED VIEW IF NOT EXISTS event_stats_daily AS
+SELECT (start_time AT TIME ZONE 'UTC')::date AS day,
+       COALESCE(calendar_id, '00000000-0000-0000-0000-000000000000'::uuid) AS calendar_id,
+       COUNT(*) AS events,
+       SUM(EXTRACT(EPOCH FROM end_time - start_time))::bigint / 60 AS minutes
+FROM events
+WHERE status = 'approved'
+GROUP BY 1, 2;
+
+CREATE UNIQUE INDEX IF NOT EXISTS event_stats_daily_key ON event_stats_daily (day, calendar_id);
+CREATE INDEX IF NOT EXISTS event_stats_daily_calendar ON event_stats_daily (calendar_id, day);
+
+SELECT 'Migration 033 completed successfully!' as status;
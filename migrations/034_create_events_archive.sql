@@ -0,0 +1,19 @@
+-- 034_create_events_archive.sql
+-- Migration: Monthly partitioned archive of past events
+-- Created: 2025-10-04
+
+-- The live events table cannot be partitioned: a partitioned table's unique
+-- constraints must include the partition key, while events are referenced by ID alone
+-- and client keys and external IDs are unique across all time. Ended events are moved
+-- here instead by the archive_events job, which creates a partition per month of
+-- start_time. Old partitions can then be detached and dumped or dropped cheaply, and
+-- date range queries only scan the months they cover.
+--
+-- The archive takes the columns of events in their order, as the job copies whole
+-- rows: migrations adding a column to events must add it here too.
+CREATE TABLE IF NOT EXISTS events_archive (LIKE events INCLUDING DEFAULTS) PARTITION BY RANGE (start_time);
+
+CREATE INDEX IF NOT EXISTS idx_events_archive_id ON events_archive(id);
+CREATE INDEX IF NOT EXISTS idx_events_archive_calendar_id ON events_archive(calendar_id, start_time);
+
+SELECT 'Migration 034 completed successfully!' as status;
@@ -0,0 +1,17 @@
+-- 035_create_maintenance_mode.sql
+-- Migration: Maintenance mode switch shared by every instance
+-- Created: 2025-10-05
+
+-- A single row, so the mode survives restarts and reaches every instance. read_only
+-- rejects writes with a 503; full answers every request but the health checks and the
+-- switch itself with the maintenance message.
+CREATE TABLE IF NOT EXISTS maintenance_mode (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    mode TEXT NOT NULL DEFAULT 'off' CHECK (mode IN ('off', 'read_only', 'full')),
+    message TEXT NOT NULL DEFAULT '',
+    retry_after_seconds INTEGER NOT NULL DEFAULT 300 CHECK (retry_after_seconds > 0),
+    updated_by TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+SELECT 'Migration 035 completed successfully!' as status;
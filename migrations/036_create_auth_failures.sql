@@ -0,0 +1,19 @@
+-- 036_create_auth_failures.sql
+-- Migration: Failed authentication attempts, for brute-force lockouts
+-- Created: 2025-10-06
+
+-- One row per client IP (ip:<address>) or account (account:<id>) that failed to
+-- authenticate. failures counts the failures since window_start; reaching the threshold
+-- locks the key until locked_until, for twice as long at each lockout. The lockout count
+-- is forgotten a day after the last lock ends.
+CREATE TABLE IF NOT EXISTS auth_failures (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    window_start TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    lockouts INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_failures_locked ON auth_failures(locked_until) WHERE locked_until IS NOT NULL;
+
+SELECT 'Migration 036 completed successfully!' as status;
@@ -0,0 +1,27 @@
+-- 037_create_two_factor.sql
+-- Migration: TOTP two-factor authentication with recovery codes, and organizations requiring it
+-- Created: 2025-10-07
+
+-- The TOTP secret of a user, encrypted when ENCRYPTION_KEYS is set. Enrollment is
+-- pending until confirmed_at is set by a first valid code.
+CREATE TABLE IF NOT EXISTS user_two_factor (
+    user_id TEXT PRIMARY KEY,
+    secret TEXT NOT NULL,
+    -- The last time step a code was accepted for; codes are single use
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    confirmed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Single-use recovery codes; only their SHA-256 is stored
+CREATE TABLE IF NOT EXISTS two_factor_recovery_codes (
+    user_id TEXT NOT NULL REFERENCES user_two_factor(user_id) ON DELETE CASCADE,
+    code_hash BYTEA NOT NULL,
+    used_at TIMESTAMPTZ,
+    PRIMARY KEY (user_id, code_hash)
+);
+
+-- Members of an organization requiring two-factor authentication must enroll to use it
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS require_two_factor BOOLEAN NOT NULL DEFAULT FALSE;
+
+SELECT 'Migration 037 completed successfully!' as status;
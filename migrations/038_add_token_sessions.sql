@@ -0,0 +1,11 @@
+-- 038_add_token_sessions.sql
+-- Migration: Track where tokens are created and used, and revoke them without deleting
+-- Created: 2025-10-07
+
+ALTER TABLE api_tokens ADD COLUMN IF NOT EXISTS created_ip TEXT NOT NULL DEFAULT '';
+ALTER TABLE api_tokens ADD COLUMN IF NOT EXISTS user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE api_tokens ADD COLUMN IF NOT EXISTS last_used_ip TEXT NOT NULL DEFAULT '';
+-- Revoked tokens are kept, so the use of a stolen one can be told apart from a typo
+ALTER TABLE api_tokens ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMPTZ;
+
+SELECT 'Migration 038 completed successfully!' as status;
@@ -0,0 +1,14 @@
+-- 039_create_user_preferences.sql
+-- Migration: Store the preferences users apply as defaults to the events they create
+-- Created: 2025-10-07
+
+CREATE TABLE IF NOT EXISTS user_preferences (
+    user_id TEXT PRIMARY KEY,
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    -- Minutes before the start of each reminder added to new events
+    reminder_offsets INTEGER[] NOT NULL DEFAULT '{}',
+    week_start TEXT NOT NULL DEFAULT 'monday',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+SELECT 'Migration 039 completed successfully!' as status;
@@ -0,0 +1,12 @@
+-- 040_add_event_title_search.sql
+-- Migration: Index event titles for type-ahead suggestions
+-- Created: 2025-10-08
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Trigrams of the lowercased title serve LIKE '%text%' for inputs of 3 characters or
+-- more; shorter inputs match prefixes, which the btree serves
+CREATE INDEX IF NOT EXISTS idx_events_title_trgm ON events USING gin (lower(title) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_events_title_prefix ON events (lower(title) text_pattern_ops);
+
+SELECT 'Migration 040 completed successfully!' as status;
@@ -0,0 +1,9 @@
+-- 041_add_event_full_text_search.sql
+-- Migration: Index event titles for full text search
+-- Created: 2025-10-08
+
+-- Serves /events/search when no OpenSearch cluster is configured. The simple
+-- configuration neither stems nor drops stop words, so titles match in any language.
+CREATE INDEX IF NOT EXISTS idx_events_title_fts ON events USING gin (to_tsvector('simple', title));
+
+SELECT 'Migration 041 completed successfully!' as status;
@@ -0,0 +1,11 @@
+-- 042_add_snapshot_event_review.sql
+-- Migration: Keep the review status of events in snapshots
+-- Created: 2025-10-09
+
+-- Restores copy these back, so events that were pending or rejected are not published
+-- by the events.status default. Events of older snapshots were never reviewed through
+-- the copy, so they come back pending.
+ALTER TABLE snapshot_events ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'pending';
+ALTER TABLE snapshot_events ADD COLUMN IF NOT EXISTS submitted_by TEXT NOT NULL DEFAULT '';
+
+SELECT 'Migration 042 completed successfully!' as status;
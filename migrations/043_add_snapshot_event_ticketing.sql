@@ -0,0 +1,9 @@
+-- 043_add_snapshot_event_ticketing.sql
+-- Migration: Keep the prices and ticket quotas of events in snapshots
+-- Created: 2025-10-09
+
+ALTER TABLE snapshot_events ADD COLUMN IF NOT EXISTS price_cents BIGINT;
+ALTER TABLE snapshot_events ADD COLUMN IF NOT EXISTS currency TEXT;
+ALTER TABLE snapshot_events ADD COLUMN IF NOT EXISTS ticket_quota INTEGER;
+
+SELECT 'Migration 043 completed successfully!' as status;